@@ -0,0 +1,409 @@
+// Command loadtest measures refresh latency and broadcast throughput against fake Kubernetes clients
+// It is a separate binary so the fake clients are not linked into the server
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/history"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/k8s/k8sfake"
+	"bitwarden-reader/internal/server"
+	"bitwarden-reader/internal/writebehind"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// loadTestNamespace holds the simulated secrets
+const loadTestNamespace = "loadtest"
+
+// latencyStats summarizes the durations of the measured rounds in milliseconds
+type latencyStats struct {
+	Min float64 `json:"minMs"`
+	P50 float64 `json:"p50Ms"`
+	P95 float64 `json:"p95Ms"`
+	Max float64 `json:"maxMs"`
+}
+
+// loadTestReport is the outcome of a load test
+type loadTestReport struct {
+	Secrets    int `json:"secrets"`
+	Keys       int `json:"keys"`
+	ValueBytes int `json:"valueBytes"`
+	Clients    int `json:"clients"`
+	Rounds     int `json:"rounds"`
+	// Refresh is how long reading, processing, and publishing a changed snapshot took
+	Refresh latencyStats `json:"refresh"`
+	// Broadcast is how long after publishing the last client received the snapshot
+	Broadcast         latencyStats `json:"broadcast"`
+	Delivered         int          `json:"delivered"`
+	Missed            int          `json:"missed"`
+	DeliveredBytes    int64        `json:"deliveredBytes"`
+	MessagesPerSecond float64      `json:"messagesPerSecond"`
+	BytesPerSecond    float64      `json:"bytesPerSecond"`
+}
+
+// delivery is a snapshot received by a simulated client
+type delivery struct {
+	bytes int
+	at    time.Time
+}
+
+func main() {
+	os.Exit(runLoadTest(os.Args[1:]))
+}
+
+// runLoadTest serves simulated secrets from fake Kubernetes clients to simulated WebSocket clients,
+// and reports how long refreshes take and how fast snapshots reach every client
+// It exits 1 when a client missed a snapshot or a --max-*-p95 threshold was exceeded, so it can gate releases
+func runLoadTest(args []string) int {
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	secretCount := flags.Int("secrets", 100, "number of simulated secrets")
+	keyCount := flags.Int("keys", 5, "keys per secret")
+	valueBytes := flags.Int("value-bytes", 64, "size of each value in bytes")
+	clientCount := flags.Int("clients", 50, "number of WebSocket clients")
+	rounds := flags.Int("rounds", 20, "measured refreshes, each after changing one secret")
+	timeout := flags.Duration("timeout", 10*time.Second, "how long a round waits for every client")
+	maxRefresh := flags.Duration("max-refresh-p95", 0, "fail when the 95th percentile refresh latency exceeds this")
+	maxBroadcast := flags.Duration("max-broadcast-p95", 0, "fail when the 95th percentile broadcast latency exceeds this")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	showLog := flags.Bool("log", false, "show the server's log output")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *secretCount <= 0 || *keyCount <= 0 || *valueBytes <= 0 || *clientCount < 0 || *rounds <= 0 {
+		fmt.Fprintln(os.Stderr, "usage: loadtest [--secrets N] [--keys N] [--value-bytes N] [--clients N] [--rounds N] (positive numbers)")
+		return 2
+	}
+
+	if !*showLog {
+		log.SetOutput(io.Discard)
+	}
+
+	// Other settings come from the environment, so a deployment's configuration can be load tested;
+	// the access log goes to stdout with the report, so it is only written with --log
+	cfg := config.LoadConfig()
+	cfg.AccessLogEnabled = *showLog
+	env, err := newLoadTestEnv(cfg, *secretCount, *keyCount, *valueBytes)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer env.close()
+	srv, clients, names := env.server, env.clients, env.names
+
+	httpServer := httptest.NewServer(srv.Handler())
+	defer httpServer.Close()
+
+	deliveries := make(chan delivery, *clientCount*4)
+	conns, err := dialLoadTestClients(httpServer.URL, *clientCount, deliveries)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report := loadTestReport{
+		Secrets:    *secretCount,
+		Keys:       *keyCount,
+		ValueBytes: *valueBytes,
+		Clients:    *clientCount,
+		Rounds:     *rounds,
+	}
+	var refreshes, broadcasts []time.Duration
+	var broadcastTotal time.Duration
+	ctx := context.Background()
+	// Round 0 publishes the first snapshot and warms up; it isn't measured
+	for round := 0; round <= *rounds; round++ {
+		if round > 0 {
+			if err := changeLoadTestSecret(ctx, clients, names[round%len(names)], round); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to change a secret: %v\n", err)
+				return 2
+			}
+		}
+		drainDeliveries(deliveries)
+
+		start := time.Now()
+		srv.Refresh()
+		published := time.Now()
+
+		received, last, bytes := awaitDeliveries(deliveries, *clientCount, *timeout)
+		if round == 0 {
+			continue
+		}
+		refreshes = append(refreshes, published.Sub(start))
+		report.Delivered += received
+		report.Missed += *clientCount - received
+		report.DeliveredBytes += bytes
+		if received > 0 {
+			broadcasts = append(broadcasts, last.Sub(published))
+			broadcastTotal += last.Sub(published)
+		}
+	}
+	report.Refresh = summarizeLatency(refreshes)
+	report.Broadcast = summarizeLatency(broadcasts)
+	if seconds := broadcastTotal.Seconds(); seconds > 0 {
+		report.MessagesPerSecond = float64(report.Delivered) / seconds
+		report.BytesPerSecond = float64(report.DeliveredBytes) / seconds
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printLoadTestReport(os.Stdout, report)
+	}
+
+	failed := false
+	if report.Missed > 0 {
+		fmt.Fprintf(os.Stderr, "%d snapshots did not reach their client within %s\n", report.Missed, *timeout)
+		failed = true
+	}
+	if *maxRefresh > 0 && report.Refresh.P95 > milliseconds(*maxRefresh) {
+		fmt.Fprintf(os.Stderr, "95th percentile refresh latency %.2fms exceeds %s\n", report.Refresh.P95, *maxRefresh)
+		failed = true
+	}
+	if *maxBroadcast > 0 && report.Broadcast.P95 > milliseconds(*maxBroadcast) {
+		fmt.Fprintf(os.Stderr, "95th percentile broadcast latency %.2fms exceeds %s\n", report.Broadcast.P95, *maxBroadcast)
+		failed = true
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// loadTestEnv is a server reading simulated secrets from fake Kubernetes clients
+type loadTestEnv struct {
+	server  *server.Server
+	clients *k8s.K8sClients
+	names   []string
+	history *history.Store
+}
+
+// newLoadTestEnv creates the simulated secrets and a server reading them, scoped to the load test namespace
+func newLoadTestEnv(cfg *config.Config, secrets, keys, valueBytes int) (*loadTestEnv, error) {
+	k8s.SetSyncTimeAnnotations(cfg.SyncTimeAnnotations)
+	k8s.SetMetadataAnnotations(cfg.MetadataAnnotations)
+	names := loadTestNames(secrets)
+	cfg.PodNamespace = loadTestNamespace
+	cfg.SecretNames = names
+	cfg.AllowedNamespaces = []string{loadTestNamespace}
+	cfg.IdentityNamespaces = nil
+	cfg.WSMaxConnections = 0
+	cfg.WSMaxConnsPerClient = 0
+
+	clients := k8sfake.NewClients(loadTestObjects(cfg, names, keys, valueBytes)...)
+	historyStore, err := history.Open("", nil, writebehind.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history store: %w", err)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	return &loadTestEnv{
+		server:  server.NewServer(cfg, clients, nil, historyStore),
+		clients: clients,
+		names:   names,
+		history: historyStore,
+	}, nil
+}
+
+// close releases the history store
+func (e *loadTestEnv) close() {
+	e.history.Close()
+}
+
+// loadTestNames returns the names of the simulated secrets
+func loadTestNames(count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("loadtest-%05d", i)
+	}
+	return names
+}
+
+// loadTestValue returns a distinct value of the given size, so duplicate detection has nothing to group
+func loadTestValue(secret string, key, round, size int) []byte {
+	prefix := fmt.Sprintf("%s/%d/%d/", secret, key, round)
+	if len(prefix) >= size {
+		return []byte(prefix[:size])
+	}
+	return []byte(prefix + strings.Repeat("x", size-len(prefix)))
+}
+
+// loadTestObjects returns a synced Secret and BitwardenSecret for each name
+func loadTestObjects(cfg *config.Config, names []string, keys, valueBytes int) []runtime.Object {
+	now := time.Now().UTC().Format(time.RFC3339)
+	annotations := map[string]string{}
+	if len(cfg.SyncTimeAnnotations) > 0 {
+		annotations[cfg.SyncTimeAnnotations[0]] = now
+	}
+	objects := make([]runtime.Object, 0, 2*len(names))
+	for _, name := range names {
+		data := make(map[string][]byte, keys)
+		for key := 0; key < keys; key++ {
+			data[fmt.Sprintf("key-%d", key)] = loadTestValue(name, key, 0, valueBytes)
+		}
+		objects = append(objects, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: loadTestNamespace, Annotations: annotations},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       data,
+		})
+		objects = append(objects, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": k8s.BitwardenSecretGVR.GroupVersion().String(),
+			"kind":       "BitwardenSecret",
+			"metadata": map[string]interface{}{
+				"name":              name,
+				"namespace":         loadTestNamespace,
+				"creationTimestamp": now,
+			},
+			"spec": map[string]interface{}{
+				"secretName": name,
+			},
+			"status": map[string]interface{}{
+				"lastSuccessfulSyncTime": now,
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":    k8s.SuccessfulSyncCondition,
+						"status":  "True",
+						"reason":  "ReconciliationComplete",
+						"message": "Simulated by loadtest",
+					},
+				},
+			},
+		}})
+	}
+	return objects
+}
+
+// changeLoadTestSecret changes the first value of a secret, so the next refresh publishes a snapshot
+func changeLoadTestSecret(ctx context.Context, clients *k8s.K8sClients, name string, round int) error {
+	secrets := clients.Clientset.CoreV1().Secrets(loadTestNamespace)
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	secret.Data["key-0"] = loadTestValue(name, 0, round, len(secret.Data["key-0"]))
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// dialLoadTestClients connects the simulated WebSocket clients, which report every snapshot they receive
+func dialLoadTestClients(serverURL string, count int, deliveries chan<- delivery) ([]*websocket.Conn, error) {
+	url := "ws" + strings.TrimPrefix(serverURL, "http") + "/ws"
+	conns := make([]*websocket.Conn, 0, count)
+	for i := 0; i < count; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			return conns, fmt.Errorf("failed to connect WebSocket client %d: %w", i+1, err)
+		}
+		conns = append(conns, conn)
+		go func() {
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				var message struct {
+					Type string `json:"type"`
+				}
+				if json.Unmarshal(data, &message) != nil || message.Type != "secrets" {
+					continue
+				}
+				deliveries <- delivery{bytes: len(data), at: time.Now()}
+			}
+		}()
+	}
+	return conns, nil
+}
+
+// drainDeliveries discards snapshots that arrived after their round timed out
+func drainDeliveries(deliveries <-chan delivery) {
+	for {
+		select {
+		case <-deliveries:
+		default:
+			return
+		}
+	}
+}
+
+// awaitDeliveries waits until count snapshots were received or the timeout passed
+func awaitDeliveries(deliveries <-chan delivery, count int, timeout time.Duration) (int, time.Time, int64) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	received := 0
+	var last time.Time
+	var bytes int64
+	for received < count {
+		select {
+		case d := <-deliveries:
+			received++
+			bytes += int64(d.bytes)
+			last = d.at
+		case <-deadline.C:
+			return received, last, bytes
+		}
+	}
+	return received, last, bytes
+}
+
+// summarizeLatency returns the minimum, median, 95th percentile, and maximum of the durations
+func summarizeLatency(durations []time.Duration) latencyStats {
+	if len(durations) == 0 {
+		return latencyStats{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(q float64) time.Duration {
+		return sorted[int(math.Ceil(q*float64(len(sorted))))-1]
+	}
+	return latencyStats{
+		Min: milliseconds(sorted[0]),
+		P50: milliseconds(percentile(0.5)),
+		P95: milliseconds(percentile(0.95)),
+		Max: milliseconds(sorted[len(sorted)-1]),
+	}
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// printLoadTestReport prints the report as text
+func printLoadTestReport(w io.Writer, report loadTestReport) {
+	fmt.Fprintf(w, "%d secrets x %d keys of %d bytes, %d WebSocket clients, %d rounds\n\n",
+		report.Secrets, report.Keys, report.ValueBytes, report.Clients, report.Rounds)
+	fmt.Fprintf(w, "%-10s %10s %10s %10s %10s\n", "", "min", "p50", "p95", "max")
+	for _, row := range []struct {
+		name  string
+		stats latencyStats
+	}{{"refresh", report.Refresh}, {"broadcast", report.Broadcast}} {
+		fmt.Fprintf(w, "%-10s %8.2fms %8.2fms %8.2fms %8.2fms\n", row.name, row.stats.Min, row.stats.P50, row.stats.P95, row.stats.Max)
+	}
+	fmt.Fprintf(w, "\nDelivered %d snapshots (%d missed), %.1f MB: %.0f messages/s, %.1f MB/s\n",
+		report.Delivered, report.Missed, float64(report.DeliveredBytes)/1e6, report.MessagesPerSecond, report.BytesPerSecond/1e6)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/reader"
+)
+
+// Size of the simulated secrets the benchmarks read
+const (
+	benchmarkSecrets    = 100
+	benchmarkKeys       = 5
+	benchmarkValueBytes = 64
+)
+
+// newBenchmarkEnv returns a quiet load test server that has published its first snapshot
+func newBenchmarkEnv(b *testing.B) *loadTestEnv {
+	b.Helper()
+	log.SetOutput(io.Discard)
+
+	cfg := config.LoadConfig()
+	cfg.AccessLogEnabled = false
+	env, err := newLoadTestEnv(cfg, benchmarkSecrets, benchmarkKeys, benchmarkValueBytes)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(env.close)
+	env.server.Refresh()
+	return env
+}
+
+func BenchmarkReadSecrets(b *testing.B) {
+	env := newBenchmarkEnv(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := reader.ReadSecrets(ctx, env.names, loadTestNamespace, env.clients); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRefreshUnchanged(b *testing.B) {
+	env := newBenchmarkEnv(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		env.server.Refresh()
+	}
+}
+
+func BenchmarkRefreshChanged(b *testing.B) {
+	env := newBenchmarkEnv(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		round := i + 1
+		if err := changeLoadTestSecret(ctx, env.clients, env.names[round%len(env.names)], round); err != nil {
+			b.Fatal(err)
+		}
+		env.server.Refresh()
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"bitwarden-reader/internal/agent"
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+)
+
+// runAgent projects secret keys to files and re-renders them when the secrets rotate
+func runAgent(args []string) int {
+	logging.Install()
+	cfg := config.LoadConfig()
+	k8s.SetSyncTimeAnnotations(cfg.SyncTimeAnnotations)
+	k8s.SetMetadataAnnotations(cfg.MetadataAnnotations)
+	k8s.SetAuthTokenExpiryWarning(cfg.AuthTokenExpiryWarning)
+
+	flags := flag.NewFlagSet("agent", flag.ContinueOnError)
+	configFile := flags.String("config", cfg.AgentConfigFile, "agent config file (defaults to AGENT_CONFIG_FILE)")
+	once := flags.Bool("once", false, "render once and exit (for init containers)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *configFile == "" {
+		fmt.Fprintln(os.Stderr, "usage: agent --config FILE [--once] (or set AGENT_CONFIG_FILE)")
+		return 2
+	}
+
+	agentConfig, err := agent.LoadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	k8sClients, err := k8s.NewK8sClient(clientOptions(cfg))
+	if err != nil || k8sClients == nil {
+		fmt.Fprintf(os.Stderr, "Kubernetes client not available: %v\n", err)
+		return 2
+	}
+
+	a := agent.New(agentConfig, cfg.PodNamespace, k8sClients.Clientset)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *once {
+		written, err := a.Sync(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Rendered %d files\n", written)
+		return 0
+	}
+
+	logging.Printf("Agent started: %d projections into %s", len(agentConfig.Projections), agentConfig.OutputDir)
+	if err := a.Run(ctx); err != nil {
+		logging.Printf("Agent stopped: %v", err)
+		return 1
+	}
+	return 0
+}
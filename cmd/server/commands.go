@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// command is a CLI subcommand of the reader binary
+type command struct {
+	usage string
+	run   func(args []string) int
+}
+
+// commands lists the available subcommands by name
+var commands = map[string]command{
+	"agent": {
+		usage: "agent [--config FILE] [--once]                  Project secret keys to files and keep them updated (sidecar)",
+		run:   runAgent,
+	},
+	"config": {
+		usage: "config show|validate [--json] [--standalone]     Print the effective configuration or check it for problems",
+		run:   runConfig,
+	},
+	"decrypt": {
+		usage: "decrypt FILE                                    Print the plaintext of an encrypted audit log",
+		run:   runDecrypt,
+	},
+	"diff": {
+		usage: "diff [--public-key KEY] [--json] OLD.json NEW.json  Compare two exported state bundles",
+		run:   runDiff,
+	},
+	"keygen": {
+		usage: "keygen                                          Generate a recipient key pair for encrypted exports",
+		run:   runKeygen,
+	},
+	"manifests": {
+		usage: "manifests [--namespace NS] [--image IMAGE] [--ingress-host HOST]  Render Kubernetes manifests from the current configuration",
+		run:   runManifests,
+	},
+	"restore": {
+		usage: "restore --key-file KEY [--namespace NS] [--overwrite] [--dry-run] BACKUP.json  Re-create Secrets from an encrypted backup",
+		run:   runRestore,
+	},
+	"wait": {
+		usage: "wait [--timeout D] [--max-sync-age D] [SECRET[:KEY,...]...]  Block until secrets exist and have synced (init containers)",
+		run:   runWait,
+	},
+}
+
+// runCommand runs the named subcommand and returns its exit code
+func runCommand(name string, args []string) int {
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return 0
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage()
+		return 2
+	}
+	return cmd.run(args)
+}
+
+// printUsage prints the list of subcommands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: bitwarden-reader [--standalone] [command]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Without a command the HTTP server is started.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"bitwarden-reader/internal/agent"
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/bundle"
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/envelope"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/mqtt"
+	"bitwarden-reader/internal/notify"
+	"bitwarden-reader/internal/render"
+	"bitwarden-reader/internal/rules"
+	"bitwarden-reader/internal/server"
+	"bitwarden-reader/internal/sources"
+)
+
+// runConfig shows or checks the configuration the server would start with
+func runConfig(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "show":
+			return runConfigShow(args[1:])
+		case "validate":
+			return runConfigValidate(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "usage: config show [--json] [--standalone] | config validate [--json] [--standalone]")
+	return 2
+}
+
+// loadConfigWithFlags loads the configuration as the server would with the given server flags
+func loadConfigWithFlags(name string, args []string) (*config.Config, bool, bool) {
+	flags := flag.NewFlagSet("config "+name, flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print as JSON")
+	standalone := flags.Bool("standalone", false, "load the configuration as with the server's --standalone flag")
+	if err := flags.Parse(args); err != nil {
+		return nil, false, false
+	}
+	cfg := config.LoadConfig()
+	cfg.Standalone = *standalone
+	return cfg, *asJSON, true
+}
+
+// runConfigShow prints the effective configuration and where each value came from; sensitive values are redacted
+func runConfigShow(args []string) int {
+	cfg, asJSON, ok := loadConfigWithFlags("show", args)
+	if !ok {
+		return 2
+	}
+	settings := cfg.Effective()
+	if asJSON {
+		return printJSON(settings)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tSOURCE\tVALUE")
+	for _, setting := range settings {
+		name := setting.Env
+		if name == "" {
+			name = "--" + setting.Flag
+		}
+		value, err := json.Marshal(setting.Value)
+		if err != nil {
+			value = []byte(fmt.Sprint(setting.Value))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, setting.Source, value)
+	}
+	w.Flush()
+	return 0
+}
+
+// runConfigValidate reports every problem in the configuration, exiting 1 when there is any
+// Besides the checks of Config.Validate, the referenced files are parsed by their own loaders
+func runConfigValidate(args []string) int {
+	cfg, asJSON, ok := loadConfigWithFlags("validate", args)
+	if !ok {
+		return 2
+	}
+	problems := validateConfig(cfg)
+	if asJSON {
+		if code := printJSON(map[string]interface{}{"valid": len(problems) == 0, "problems": problems}); code != 0 {
+			return code
+		}
+	} else {
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", problem.Env, problem.Message)
+		}
+		if len(problems) == 0 {
+			fmt.Println("Configuration is valid")
+		} else {
+			fmt.Printf("%d problems found\n", len(problems))
+		}
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// validateConfig returns the problems of Config.Validate plus those found by loading the referenced files
+// A file already reported, e.g. as missing, is not loaded again
+func validateConfig(cfg *config.Config) []config.Problem {
+	problems := cfg.Validate()
+	reported := make(map[string]bool, len(problems))
+	for _, problem := range problems {
+		reported[problem.Env] = true
+	}
+	check := func(env, value string, load func() error) {
+		if value == "" || reported[env] {
+			return
+		}
+		if err := load(); err != nil {
+			problems = append(problems, config.Problem{Env: env, Message: err.Error()})
+		}
+	}
+
+	if !k8s.ValidWatchStrategy(cfg.WatchStrategy) {
+		problems = append(problems, config.Problem{Env: "WATCH_STRATEGY", Message: fmt.Sprintf("unknown watch strategy %q", cfg.WatchStrategy)})
+	}
+	if cfg.VaultAddr != "" {
+		switch cfg.VaultAuthMethod {
+		case sources.VaultAuthToken, sources.VaultAuthKubernetes, sources.VaultAuthAppRole:
+		default:
+			problems = append(problems, config.Problem{Env: "VAULT_AUTH_METHOD", Message: fmt.Sprintf("unknown Vault auth method %q", cfg.VaultAuthMethod)})
+		}
+	}
+	for _, list := range []struct {
+		env    string
+		values []string
+	}{
+		{"TRUSTED_PROXIES", cfg.TrustedProxies},
+		{"IP_ALLOW", cfg.IPAllow},
+		{"IP_DENY", cfg.IPDeny},
+		{"ADMIN_IP_ALLOW", cfg.AdminIPAllow},
+		{"ADMIN_IP_DENY", cfg.AdminIPDeny},
+	} {
+		check(list.env, strings.Join(list.values, ","), func() error {
+			_, err := server.ParseCIDRs(list.values)
+			return err
+		})
+	}
+	if !reported["AUTH_TOKENS_FILE"] && !reported["AUTH_BASIC_FILE"] {
+		check("AUTH_METHODS", "methods", func() error {
+			_, err := server.NewAuthChain(cfg)
+			return err
+		})
+	}
+	if !reported["PERSISTENCE_KEY_FILE"] {
+		check("PERSISTENCE_ENCRYPTION", cfg.PersistenceEncryption, func() error {
+			_, err := envelope.NewProvider(cfg.PersistenceEncryption, cfg.PersistenceKeyFile)
+			return err
+		})
+	}
+	check("NOTIFY_CONFIG_FILE", cfg.NotifyConfigFile, func() error {
+		_, err := notify.LoadConfig(cfg.NotifyConfigFile)
+		return err
+	})
+	check("ALERT_RULES_FILE", cfg.AlertRulesFile, func() error {
+		_, err := rules.LoadFile(cfg.AlertRulesFile)
+		return err
+	})
+	check("AUDIT_SINKS_FILE", cfg.AuditSinksFile, func() error {
+		_, err := audit.LoadSinksConfig(cfg.AuditSinksFile)
+		return err
+	})
+	check("AGENT_CONFIG_FILE", cfg.AgentConfigFile, func() error {
+		_, err := agent.LoadConfig(cfg.AgentConfigFile)
+		return err
+	})
+	check("TEMPLATES_DIR", cfg.TemplatesDir, func() error {
+		_, err := render.LoadDir(cfg.TemplatesDir)
+		return err
+	})
+	check("MQTT_BROKER_URL", cfg.MQTTBrokerURL, func() error {
+		_, _, err := mqtt.ParseBrokerURL(cfg.MQTTBrokerURL)
+		return err
+	})
+	if cfg.MQTTBrokerURL != "" {
+		check("MQTT_TOPIC_TEMPLATE", "template", func() error { return mqtt.ValidateTopic(cfg.MQTTTopicTemplate) })
+		check("MQTT_SUMMARY_TOPIC", "topic", func() error { return mqtt.ValidateTopic(cfg.MQTTSummaryTopic) })
+	}
+	check("EXPORT_SIGNING_KEY_FILE", cfg.ExportSigningKeyFile, func() error {
+		_, _, err := bundle.LoadSigningKey(cfg.ExportSigningKeyFile)
+		return err
+	})
+	return problems
+}
+
+// printJSON writes v as indented JSON to stdout, returning the exit code
+func printJSON(v interface{}) int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
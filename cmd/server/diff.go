@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"bitwarden-reader/internal/bundle"
+)
+
+// runDiff compares two state bundles, exiting 0 when identical, 1 when different, and 2 on error
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	publicKey := flags.String("public-key", "", "base64 ed25519 public key the bundles must be signed with")
+	insecure := flags.Bool("skip-verify", false, "skip signature verification")
+	asJSON := flags.Bool("json", false, "print changes as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "diff requires exactly two bundle files")
+		return 2
+	}
+
+	var trustedKey ed25519.PublicKey
+	if *publicKey != "" {
+		key, err := bundle.ParsePublicKey(*publicKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --public-key: %v\n", err)
+			return 2
+		}
+		trustedKey = key
+	}
+
+	bundles := make([]*bundle.Bundle, 0, 2)
+	for _, path := range flags.Args() {
+		b, err := bundle.Load(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if !*insecure {
+			if err := bundle.Verify(b, trustedKey); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				return 2
+			}
+			if b.Signature.Ephemeral && trustedKey == nil {
+				fmt.Fprintf(os.Stderr, "warning: %s is signed with an ephemeral key; only integrity is verified\n", path)
+			}
+		}
+		bundles = append(bundles, b)
+	}
+
+	if !bundle.HashKeysMatch(bundles[0], bundles[1]) {
+		fmt.Fprintln(os.Stderr, "warning: the bundles hash values with different keys; value changes are not compared")
+	}
+
+	changes := bundle.Diff(bundles[0], bundles[1])
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(changes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	} else {
+		fmt.Printf("--- %s (%s)\n", flags.Arg(0), bundles[0].GeneratedAt.Format("2006-01-02 15:04:05Z07:00"))
+		fmt.Printf("+++ %s (%s)\n", flags.Arg(1), bundles[1].GeneratedAt.Format("2006-01-02 15:04:05Z07:00"))
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		if len(changes) == 0 {
+			fmt.Println("no differences")
+		}
+	}
+
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
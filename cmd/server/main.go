@@ -2,33 +2,120 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"bitwarden-reader/internal/config"
 	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
 	"bitwarden-reader/internal/server"
 )
 
+// exitNoKubernetes is the exit code when REQUIRE_KUBERNETES is set but no cluster configuration was found
+const exitNoKubernetes = 3
+
 func main() {
+	// Dispatch CLI subcommands; without one the HTTP server is started
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	flags := flag.NewFlagSet("bitwarden-reader", flag.ContinueOnError)
+	standalone := flags.Bool("standalone", false, "run without a Kubernetes cluster, even when one is configured")
+	flags.Usage = func() {
+		printUsage()
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Server flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	// Route all process logging through the secret scrubber
+	logging.Install()
+
 	// Initialize configuration
 	cfg := config.LoadConfig()
+	cfg.Standalone = *standalone
+	if cfg.MemoryHygiene {
+		logging.DisableRegistry()
+	}
+
+	// Collect client-go request metrics for /metrics and throttling warnings
+	k8s.RegisterClientMetrics(cfg.KubeThrottleWarning)
+	k8s.SetDiscoveryTTL(cfg.DiscoveryCacheTTL)
+	k8s.SetSyncTimeAnnotations(cfg.SyncTimeAnnotations)
+	k8s.SetMetadataAnnotations(cfg.MetadataAnnotations)
+	k8s.SetAuthTokenExpiryWarning(cfg.AuthTokenExpiryWarning)
 
 	// Setup Kubernetes clients (optional - can be nil for standalone mode)
-	k8sClients, err := k8s.NewK8sClient()
+	if cfg.Standalone && cfg.RequireKubernetes {
+		logging.Printf("--standalone contradicts REQUIRE_KUBERNETES=true; set only one")
+		os.Exit(2)
+	}
+	var k8sClients *k8s.K8sClients
+	var err error
+	if !cfg.Standalone {
+		k8sClients, err = k8s.NewK8sClient(clientOptions(cfg))
+		if err != nil {
+			logging.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+	}
+	if k8sClients == nil && cfg.RequireKubernetes {
+		logging.Printf("REQUIRE_KUBERNETES is set but no Kubernetes configuration was found: not running in a pod with a service account and no kubeconfig (KUBECONFIG or ~/.kube/config)")
+		os.Exit(exitNoKubernetes)
+	}
+	if k8sClients != nil && cfg.TokenRequestExpiration > 0 && cfg.KubeReplayFile == "" {
+		if err := k8sClients.UseTokenRequest(cfg.TokenRequestExpiration, cfg.TokenRequestAudiences); err != nil {
+			logging.Fatalf("Failed to set up TokenRequest tokens: %v", err)
+		}
+	}
+	if k8sClients != nil && cfg.KubeReplayFile == "" {
+		if err := k8sClients.UseNamespaceCredentials(cfg.NamespaceCredentials); err != nil {
+			logging.Fatalf("Failed to set up namespace credentials: %v", err)
+		}
+	}
+	if k8sClients != nil && cfg.KubeReplayFile == "" {
+		if err := k8s.DetectCRDScope(k8sClients.Clientset.Discovery()); err != nil {
+			logging.Printf("Could not read the BitwardenSecret scope from discovery, learning it from the first read: %v", err)
+		}
+	}
+	switch {
+	case cfg.Standalone:
+		logging.Println("Running in standalone mode (--standalone) - Kubernetes features are disabled")
+	case k8sClients == nil:
+		logging.Println("WARNING: Running in standalone mode - Kubernetes features will be limited")
+		logging.Println("To enable Kubernetes features, ensure kubeconfig is available or run in-cluster")
+		logging.Println("Set REQUIRE_KUBERNETES=true to fail instead, or pass --standalone to silence this warning")
+	}
+
+	// Setup audit logging
+	auditLogger, err := newAuditLogger(cfg)
 	if err != nil {
-		log.Fatalf("Failed to create Kubernetes client: %v", err)
+		logging.Fatalf("Failed to create audit logger: %v", err)
 	}
-	if k8sClients == nil {
-		log.Println("WARNING: Running in standalone mode - Kubernetes features will be limited")
-		log.Println("To enable Kubernetes features, ensure kubeconfig is available or run in-cluster")
+	// Give external audit sinks a chance to receive what is still buffered
+	defer auditLogger.Close(10 * time.Second)
+
+	// Setup history persistence
+	historyStore, err := newHistoryStore(cfg)
+	if err != nil {
+		logging.Fatalf("Failed to open history store: %v", err)
 	}
+	defer historyStore.Close()
 
 	// Create server instance
-	srv := server.NewServer(cfg, k8sClients)
+	srv := server.NewServer(cfg, k8sClients, auditLogger, historyStore)
 
 	// Setup graceful shutdown
 	quit := make(chan os.Signal, 1)
@@ -37,25 +124,37 @@ func main() {
 	// Start server in a goroutine
 	go func() {
 		if err := srv.Start(); err != nil {
-			log.Fatalf("Server failed to start: %v", err)
+			logging.Fatalf("Server failed to start: %v", err)
 		}
 	}()
 
-	log.Println("Server started successfully")
-	log.Printf("Listening on port %d", cfg.Port)
+	logging.Println("Server started successfully")
+	logging.Printf("Listening on port %d", cfg.Port)
 
 	// Wait for interrupt signal
 	<-quit
-	log.Println("Shutting down server...")
+	logging.Println("Shutting down server...")
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+		logging.Printf("Server forced to shutdown: %v", err)
 		return
 	}
 
-	log.Println("Server exited")
+	logging.Println("Server exited")
+}
+
+// clientOptions returns the Kubernetes client tuning from the configuration
+func clientOptions(cfg *config.Config) k8s.ClientOptions {
+	return k8s.ClientOptions{
+		Protobuf:   cfg.KubeProtobuf,
+		QPS:        float32(cfg.KubeClientQPS),
+		Burst:      cfg.KubeClientBurst,
+		RecordFile: cfg.KubeRecordFile,
+		ReplayFile: cfg.KubeReplayFile,
+		ReadOnly:   cfg.ReadOnly,
+	}
 }
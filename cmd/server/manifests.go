@@ -0,0 +1,412 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/features"
+	"bitwarden-reader/internal/k8s"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestOptions holds the deployment settings not covered by the reader configuration
+type manifestOptions struct {
+	name         string
+	namespace    string
+	image        string
+	replicas     int32
+	ingressHost  string
+	ingressClass string
+	// hpaMaxReplicas renders a HorizontalPodAutoscaler on WebSocket connections when set
+	hpaMaxReplicas       int32
+	hpaTargetConnections int64
+}
+
+// runManifests renders a deployable set of Kubernetes manifests from the current configuration
+func runManifests(args []string) int {
+	cfg := config.LoadConfig()
+
+	flags := flag.NewFlagSet("manifests", flag.ContinueOnError)
+	opts := manifestOptions{}
+	flags.StringVar(&opts.name, "name", "bitwarden-reader", "name used for all generated resources")
+	flags.StringVar(&opts.namespace, "namespace", cfg.PodNamespace, "namespace to deploy into (defaults to POD_NAMESPACE)")
+	flags.StringVar(&opts.image, "image", "bitwarden-reader:"+cfg.AppVersion, "container image")
+	replicas := flags.Int("replicas", 1, "number of replicas")
+	flags.StringVar(&opts.ingressHost, "ingress-host", "", "render an Ingress for this host")
+	flags.StringVar(&opts.ingressClass, "ingress-class", "", "ingress class name for the Ingress")
+	hpaMaxReplicas := flags.Int("hpa-max-replicas", 0, "render a HorizontalPodAutoscaler scaling up to this many replicas on WebSocket connections")
+	flags.Int64Var(&opts.hpaTargetConnections, "hpa-target-connections", 200, "average WebSocket connections per pod the autoscaler aims for")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	opts.hpaMaxReplicas = int32(*hpaMaxReplicas)
+	if opts.hpaMaxReplicas > 0 && opts.hpaTargetConnections <= 0 {
+		fmt.Fprintln(os.Stderr, "--hpa-target-connections must be positive")
+		return 2
+	}
+	if max(opts.replicas, opts.hpaMaxReplicas) > 1 && cfg.SessionRedisURL == "" {
+		fmt.Fprintln(os.Stderr, "warning: with more than one replica, sessions need SESSION_REDIS_URL or sticky sessions at the ingress")
+	}
+	if opts.namespace == "" {
+		opts.namespace = "default"
+	}
+	opts.replicas = int32(*replicas)
+
+	objects := []interface{}{
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: opts.meta(),
+		},
+	}
+	objects = append(objects, rbacObjects(cfg, opts)...)
+	if cfg.Impersonation {
+		objects = append(objects, impersonationRBACObjects(opts)...)
+	}
+	objects = append(objects, operatorRBACObjects(cfg, opts)...)
+	objects = append(objects, deploymentObject(cfg, opts), serviceObject(cfg, opts), networkPolicyObject(cfg, opts))
+	if opts.ingressHost != "" {
+		objects = append(objects, ingressObject(opts))
+	}
+	if opts.hpaMaxReplicas > 0 {
+		objects = append(objects, autoscalerObject(opts))
+	}
+
+	for i, obj := range objects {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to render manifest: %v\n", err)
+			return 2
+		}
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Print(string(data))
+	}
+	return 0
+}
+
+// labels returns the labels shared by all generated resources
+func (o manifestOptions) labels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":     "bitwarden-reader",
+		"app.kubernetes.io/instance": o.name,
+	}
+}
+
+// meta returns object metadata for a resource in the target namespace
+func (o manifestOptions) meta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: o.name, Namespace: o.namespace, Labels: o.labels()}
+}
+
+// rbacObjects returns the Role/RoleBinding pairs (or a ClusterRole when all namespaces are allowed)
+func rbacObjects(cfg *config.Config, opts manifestOptions) []interface{} {
+	flags := features.New(cfg.FeatureFlags)
+	watching := cfg.WatchStrategy != k8s.WatchGet && flags.Enabled(features.Informers)
+	crdVerbs := []string{"get", "list"}
+	if !cfg.ReadOnly {
+		crdVerbs = append(crdVerbs, "patch")
+	}
+	if cfg.WriteEnabled && flags.Enabled(features.WriteEndpoints) {
+		crdVerbs = append(crdVerbs, "create", "update", "delete")
+	}
+	secretVerbs := []string{"get", "list"}
+	if watching {
+		secretVerbs = append(secretVerbs, "watch")
+	}
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: secretVerbs},
+		{APIGroups: []string{k8s.BitwardenSecretGVR.Group}, Resources: []string{k8s.BitwardenSecretGVR.Resource}, Verbs: crdVerbs},
+	}
+	if watching {
+		// Events name who deleted a watched secret
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"list"}})
+	}
+	if cfg.TokenRequestExpiration > 0 {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"serviceaccounts/token"}, ResourceNames: []string{opts.name}, Verbs: []string{"create"}})
+	}
+	subjects := []rbacv1.Subject{{Kind: "ServiceAccount", Name: opts.name, Namespace: opts.namespace}}
+
+	if cfg.AllNamespacesAllowed() {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"list"}})
+		clusterMeta := metav1.ObjectMeta{Name: opts.name, Labels: opts.labels()}
+		return []interface{}{
+			&rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+				ObjectMeta: clusterMeta,
+				Rules:      rules,
+			},
+			&rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+				ObjectMeta: clusterMeta,
+				RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: opts.name},
+				Subjects:   subjects,
+			},
+		}
+	}
+
+	namespaces := []string{opts.namespace}
+	for _, namespace := range cfg.AllowedNamespaces {
+		if namespace != opts.namespace {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	var objects []interface{}
+	for _, namespace := range namespaces {
+		meta := metav1.ObjectMeta{Name: opts.name, Namespace: namespace, Labels: opts.labels()}
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+				ObjectMeta: meta,
+				Rules:      rules,
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+				ObjectMeta: meta,
+				RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: opts.name},
+				Subjects:   subjects,
+			},
+		)
+	}
+	return objects
+}
+
+// impersonationRBACObjects returns the ClusterRole allowing the reader to impersonate dashboard users
+// Users and groups are cluster-scoped, so this is always a ClusterRole
+func impersonationRBACObjects(opts manifestOptions) []interface{} {
+	meta := metav1.ObjectMeta{Name: opts.name + "-impersonate", Labels: opts.labels()}
+	return []interface{}{
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: meta,
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"users", "groups", "serviceaccounts"}, Verbs: []string{"impersonate"}},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: meta,
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: meta.Name},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: opts.name, Namespace: opts.namespace}},
+		},
+	}
+}
+
+// operatorRBACObjects returns the Role letting the reader read the operator Deployment, whose image
+// version tells whether the operator honors force-sync, and the ClusterRole to read the BitwardenSecret CRD
+// for /api/v1/operator/info
+func operatorRBACObjects(cfg *config.Config, opts manifestOptions) []interface{} {
+	meta := metav1.ObjectMeta{Name: opts.name + "-operator", Namespace: cfg.OperatorNamespace, Labels: opts.labels()}
+	crdMeta := metav1.ObjectMeta{Name: opts.name + "-crd", Labels: opts.labels()}
+	crdName := k8s.BitwardenSecretGVR.Resource + "." + k8s.BitwardenSecretGVR.Group
+	return []interface{}{
+		&rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+			ObjectMeta: meta,
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, ResourceNames: []string{cfg.OperatorDeployment}, Verbs: []string{"get"}},
+			},
+		},
+		&rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: meta,
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: meta.Name},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: opts.name, Namespace: opts.namespace}},
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: crdMeta,
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, ResourceNames: []string{crdName}, Verbs: []string{"get"}},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: crdMeta,
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: crdMeta.Name},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: opts.name, Namespace: opts.namespace}},
+		},
+	}
+}
+
+// containerEnv returns the downward API variables plus every configuration variable set in the environment
+func containerEnv() []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+		{Name: "POD_NAMESPACE", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}}},
+	}
+	for _, key := range config.EnvKeys {
+		if key == "POD_NAME" || key == "POD_NAMESPACE" || key == "PORT" {
+			continue
+		}
+		if value, ok := os.LookupEnv(key); ok && strings.TrimSpace(value) != "" {
+			env = append(env, corev1.EnvVar{Name: key, Value: value})
+		}
+	}
+	// A Deployment without cluster access is misconfigured, so it fails instead of serving an empty dashboard
+	if _, ok := os.LookupEnv("REQUIRE_KUBERNETES"); !ok {
+		env = append(env, corev1.EnvVar{Name: "REQUIRE_KUBERNETES", Value: "true"})
+	}
+	return env
+}
+
+// deploymentObject returns the reader Deployment
+func deploymentObject(cfg *config.Config, opts manifestOptions) *appsv1.Deployment {
+	probe := func(path string, initialDelay int32) *corev1.Probe {
+		return &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: path, Port: intstr.FromString("http")},
+			},
+			InitialDelaySeconds: initialDelay,
+			PeriodSeconds:       10,
+		}
+	}
+	allowEscalation := false
+
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: opts.meta(),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &opts.replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: opts.labels()},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: opts.labels(), Annotations: scrapeAnnotations(cfg, opts)},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: opts.name,
+					Containers: []corev1.Container{{
+						Name:           "bitwarden-reader",
+						Image:          opts.image,
+						Ports:          []corev1.ContainerPort{{Name: "http", ContainerPort: int32(cfg.Port)}},
+						Env:            append(containerEnv(), corev1.EnvVar{Name: "PORT", Value: fmt.Sprint(cfg.Port)}),
+						LivenessProbe:  probe("/livez", 10),
+						ReadinessProbe: probe("/readyz", 5),
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: &allowEscalation,
+							Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// serviceObject returns the ClusterIP Service in front of the Deployment
+func serviceObject(cfg *config.Config, opts manifestOptions) *corev1.Service {
+	return &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: opts.meta(),
+		Spec: corev1.ServiceSpec{
+			Selector: opts.labels(),
+			Ports: []corev1.ServicePort{{
+				Name:       "http",
+				Port:       80,
+				TargetPort: intstr.FromInt(cfg.Port),
+			}},
+		},
+	}
+}
+
+// networkPolicyObject restricts traffic to the HTTP port inbound and DNS plus the API server outbound
+func networkPolicyObject(cfg *config.Config, opts manifestOptions) *networkingv1.NetworkPolicy {
+	tcp := corev1.ProtocolTCP
+	udp := corev1.ProtocolUDP
+	port := func(protocol *corev1.Protocol, number int) networkingv1.NetworkPolicyPort {
+		value := intstr.FromInt(number)
+		return networkingv1.NetworkPolicyPort{Protocol: protocol, Port: &value}
+	}
+
+	return &networkingv1.NetworkPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: opts.meta(),
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: opts.labels()},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{port(&tcp, cfg.Port)},
+			}},
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				{Ports: []networkingv1.NetworkPolicyPort{port(&udp, 53), port(&tcp, 53)}},
+				{Ports: []networkingv1.NetworkPolicyPort{port(&tcp, 443), port(&tcp, 6443)}},
+			},
+		},
+	}
+}
+
+// ingressObject returns an Ingress routing the host to the Service
+func ingressObject(opts manifestOptions) *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: opts.meta(),
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: opts.ingressHost,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/",
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: opts.name,
+									Port: networkingv1.ServiceBackendPort{Name: "http"},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	if opts.ingressClass != "" {
+		ingress.Spec.IngressClassName = &opts.ingressClass
+	}
+	return ingress
+}
+
+// scrapeAnnotations asks Prometheus to scrape the pods when the autoscaler needs their metrics
+func scrapeAnnotations(cfg *config.Config, opts manifestOptions) map[string]string {
+	if opts.hpaMaxReplicas == 0 {
+		return nil
+	}
+	return map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   fmt.Sprint(cfg.Port),
+		"prometheus.io/path":   "/metrics",
+	}
+}
+
+// autoscalerObject returns a HorizontalPodAutoscaler scaling the Deployment on the average
+// bitwarden_reader_websocket_connections per pod, served through a custom metrics adapter
+func autoscalerObject(opts manifestOptions) *autoscalingv2.HorizontalPodAutoscaler {
+	minReplicas := max(min(opts.replicas, opts.hpaMaxReplicas), 1)
+	target := resource.NewQuantity(opts.hpaTargetConnections, resource.DecimalSI)
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler"},
+		ObjectMeta: opts.meta(),
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: opts.name},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    opts.hpaMaxReplicas,
+			Metrics: []autoscalingv2.MetricSpec{{
+				Type: autoscalingv2.PodsMetricSourceType,
+				Pods: &autoscalingv2.PodsMetricSource{
+					Metric: autoscalingv2.MetricIdentifier{Name: "bitwarden_reader_websocket_connections"},
+					Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: target},
+				},
+			}},
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/envelope"
+	"bitwarden-reader/internal/history"
+	"bitwarden-reader/internal/writebehind"
+)
+
+// newEncrypter builds the envelope encrypter for persisted data, or nil when disabled
+func newEncrypter(cfg *config.Config) (*envelope.Encrypter, error) {
+	provider, err := envelope.NewProvider(cfg.PersistenceEncryption, cfg.PersistenceKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		return nil, nil
+	}
+	return envelope.NewEncrypter(provider, cfg.DataKeyRotation), nil
+}
+
+// newAuditLogger creates the audit logger with persistence encryption applied
+func newAuditLogger(cfg *config.Config) (*audit.Logger, error) {
+	encrypter, err := newEncrypter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure persistence encryption: %w", err)
+	}
+	logger, err := audit.NewLogger(cfg.AuditLogFile, encrypter, writeBehindOptions(cfg))
+	if err != nil || cfg.AuditSinksFile == "" {
+		return logger, err
+	}
+	sinks, err := audit.LoadSinksConfig(cfg.AuditSinksFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := logger.Forward(sinks, encrypter); err != nil {
+		return nil, err
+	}
+	return logger, nil
+}
+
+// newHistoryStore opens the history store with persistence encryption applied
+func newHistoryStore(cfg *config.Config) (*history.Store, error) {
+	encrypter, err := newEncrypter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure persistence encryption: %w", err)
+	}
+	return history.Open(cfg.HistoryFile, encrypter, writeBehindOptions(cfg))
+}
+
+// writeBehindOptions returns the queue and fsync policy for the history and audit files
+func writeBehindOptions(cfg *config.Config) writebehind.Options {
+	return writebehind.Options{
+		QueueSize:    cfg.PersistenceQueueSize,
+		Sync:         cfg.PersistenceFsync,
+		SyncInterval: cfg.PersistenceFsyncInterval,
+	}
+}
+
+// runDecrypt prints the plaintext of an envelope-encrypted JSON lines file such as the audit log
+func runDecrypt(args []string) int {
+	flags := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: decrypt FILE")
+		return 2
+	}
+
+	cfg := config.LoadConfig()
+	encrypter, err := newEncrypter(cfg)
+	if err != nil || encrypter == nil {
+		fmt.Fprintf(os.Stderr, "PERSISTENCE_ENCRYPTION must be configured to decrypt: %v\n", err)
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer file.Close()
+
+	return decryptLines(file, os.Stdout, encrypter)
+}
+
+// decryptLines decrypts each sealed JSON line from r and writes the plaintext to w
+func decryptLines(r io.Reader, w io.Writer, encrypter *envelope.Encrypter) int {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	failures := 0
+	for line := 1; scanner.Scan(); line++ {
+		var sealed envelope.Sealed
+		if err := json.Unmarshal(scanner.Bytes(), &sealed); err != nil || sealed.Ciphertext == "" {
+			// Not an encrypted record (e.g. written before encryption was enabled)
+			fmt.Fprintln(w, scanner.Text())
+			continue
+		}
+		plaintext, err := encrypter.Open(&sealed)
+		if err != nil {
+			failures++
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
+			continue
+		}
+		fmt.Fprintln(w, string(plaintext))
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/backup"
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// runKeygen prints a new age recipient and identity for encrypted exports
+func runKeygen(args []string) int {
+	flags := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	recipient, identity, err := backup.GenerateKeyPair()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("EXPORT_RECIPIENT_PUBLIC_KEY=%s\n", recipient)
+	fmt.Printf("# private key - store offline and pass to 'restore --key-file'\n%s\n", identity)
+	return 0
+}
+
+// runRestore decrypts an encrypted backup and re-creates its Secrets in the current cluster
+func runRestore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	keyFile := flags.String("key-file", "", "age identity file, or the base64 private key of a version 1 backup (required)")
+	namespace := flags.String("namespace", "", "restore into this namespace instead of the original one")
+	overwrite := flags.Bool("overwrite", false, "replace the data of Secrets that already exist")
+	dryRun := flags.Bool("dry-run", false, "validate against the API server without persisting")
+	only := flags.String("secrets", "", "comma-separated subset of secret names to restore")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || *keyFile == "" {
+		fmt.Fprintln(os.Stderr, "usage: restore --key-file KEY [--namespace NS] [--overwrite] [--dry-run] BACKUP.json")
+		return 2
+	}
+
+	encrypted, err := backup.Load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	privateKey, err := os.ReadFile(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read key file: %v\n", err)
+		return 2
+	}
+	payload, err := backup.Open(encrypted, string(privateKey))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg := config.LoadConfig()
+	if cfg.ReadOnly && !*dryRun {
+		fmt.Fprintln(os.Stderr, "READ_ONLY is set - only --dry-run restores are allowed")
+		return 2
+	}
+	k8sClients, err := k8s.NewK8sClient(clientOptions(cfg))
+	if err != nil || k8sClients == nil {
+		fmt.Fprintf(os.Stderr, "Kubernetes client not available: %v\n", err)
+		return 2
+	}
+
+	auditLogger, err := newAuditLogger(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer auditLogger.Close(10 * time.Second)
+	actor := "cli:" + os.Getenv("USER")
+
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			selected[name] = true
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	failures := 0
+	for _, data := range payload.Secrets {
+		if len(selected) > 0 && !selected[data.Name] {
+			continue
+		}
+		targetNamespace := data.Namespace
+		if *namespace != "" {
+			targetNamespace = *namespace
+		}
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        data.Name,
+				Namespace:   targetNamespace,
+				Labels:      data.Labels,
+				Annotations: data.Annotations,
+			},
+			Type: corev1.SecretType(data.Type),
+			Data: data.Data,
+		}
+
+		action, err := k8s.RestoreSecret(ctx, secret, *overwrite, *dryRun, k8sClients.Clientset)
+		event := audit.Event{
+			Action:    "backup.restore",
+			Actor:     actor,
+			Resource:  data.Name,
+			Namespace: targetNamespace,
+			Outcome:   audit.OutcomeSuccess,
+			Details:   map[string]string{"action": action, "backup": flags.Arg(0)},
+		}
+		if *dryRun {
+			event.Outcome = audit.OutcomeDryRun
+		}
+		if err != nil {
+			failures++
+			event.Outcome = audit.OutcomeFailure
+			event.Details["error"] = err.Error()
+			fmt.Fprintf(os.Stderr, "%s/%s: %v\n", targetNamespace, data.Name, err)
+		} else {
+			fmt.Printf("%s/%s: %s\n", targetNamespace, data.Name, action)
+		}
+		auditLogger.Record(event)
+	}
+
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
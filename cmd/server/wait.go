@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/reader"
+)
+
+// runWait blocks until the given secrets (or REQUIRED_SECRETS) exist and have synced
+// Each argument may name required keys as SECRET:KEY1,KEY2
+// Intended to run as an init container ahead of applications that need the secrets
+func runWait(args []string) int {
+	cfg := config.LoadConfig()
+	k8s.SetSyncTimeAnnotations(cfg.SyncTimeAnnotations)
+	k8s.SetMetadataAnnotations(cfg.MetadataAnnotations)
+	k8s.SetAuthTokenExpiryWarning(cfg.AuthTokenExpiryWarning)
+
+	flags := flag.NewFlagSet("wait", flag.ContinueOnError)
+	namespace := flags.String("namespace", cfg.PodNamespace, "namespace of the secrets (defaults to POD_NAMESPACE)")
+	timeout := flags.Duration("timeout", 5*time.Minute, "give up after this long")
+	interval := flags.Duration("interval", 5*time.Second, "time between checks")
+	requireSync := flags.Bool("require-sync", true, "require the BitwardenSecret to report SuccessfulSync")
+	maxSyncAge := flags.Duration("max-sync-age", 0, "require the last successful sync to be more recent than this")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	specs := flags.Args()
+	if len(specs) == 0 {
+		specs = cfg.RequiredSecrets
+	}
+	if len(specs) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: wait [--namespace NS] [--timeout D] [--max-sync-age D] SECRET[:KEY,...]... (or set REQUIRED_SECRETS)")
+		return 2
+	}
+	names, requiredKeys := parseWaitSpecs(specs)
+	opts := reader.ReadinessOptions{
+		RequireSync:  *requireSync,
+		RequiredKeys: requiredKeys,
+		MaxSyncAge:   *maxSyncAge,
+	}
+
+	k8sClients, err := k8s.NewK8sClient(clientOptions(cfg))
+	if err != nil || k8sClients == nil {
+		fmt.Fprintf(os.Stderr, "Kubernetes client not available: %v\n", err)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	var results []reader.SecretReadiness
+	for {
+		secrets, err := reader.ReadSecrets(ctx, names, *namespace, k8sClients)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		var ready bool
+		ready, results = reader.CheckReadinessWith(secrets, opts)
+		if ready {
+			fmt.Printf("All %d secrets ready\n", len(results))
+			return 0
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Fprintf(os.Stderr, "Timed out after %s waiting for secrets:\n", *timeout)
+			for _, result := range results {
+				if !result.Ready {
+					fmt.Fprintf(os.Stderr, "  %s: %s\n", result.Name, result.Reason)
+				}
+			}
+			return 1
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseWaitSpecs splits SECRET[:KEY1,KEY2] arguments into secret names and required keys
+func parseWaitSpecs(specs []string) ([]string, map[string][]string) {
+	names := make([]string, 0, len(specs))
+	requiredKeys := make(map[string][]string)
+	for _, spec := range specs {
+		name, keys, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+		for _, key := range strings.Split(keys, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				requiredKeys[name] = append(requiredKeys[name], key)
+			}
+		}
+	}
+	return names, requiredKeys
+}
@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/render"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Agent projects secret keys to files and keeps them up to date
+type Agent struct {
+	cfg       *Config
+	namespace string
+	clientset kubernetes.Interface
+	written   map[string][sha256.Size]byte
+}
+
+// pathData is the data available to path templates
+type pathData struct {
+	Secret    string
+	Key       string
+	Namespace string
+}
+
+// New creates an agent for secrets in namespace
+func New(cfg *Config, namespace string, clientset kubernetes.Interface) *Agent {
+	return &Agent{
+		cfg:       cfg,
+		namespace: namespace,
+		clientset: clientset,
+		written:   make(map[string][sha256.Size]byte),
+	}
+}
+
+// Run syncs immediately and then on every interval until ctx is cancelled
+func (a *Agent) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.cfg.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := a.Sync(ctx); err != nil {
+			logging.Printf("Agent sync error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync renders every projection once and returns the number of files written
+func (a *Agent) Sync(ctx context.Context) (int, error) {
+	var errs []error
+	total := 0
+	for i := range a.cfg.Projections {
+		written, err := a.project(ctx, &a.cfg.Projections[i])
+		total += written
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for i := range a.cfg.Templates {
+		written, err := a.renderTemplate(ctx, &a.cfg.Templates[i])
+		if written {
+			total++
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// renderTemplate renders a template file and writes it when the output changed
+func (a *Agent) renderTemplate(ctx context.Context, t *TemplateFile) (bool, error) {
+	target, err := a.resolvePath(t.Path)
+	if err != nil {
+		return false, err
+	}
+	content, err := render.Execute(ctx, t.tmpl, a.lookup)
+	if err != nil {
+		return false, fmt.Errorf("template %s: %w", t.Source, err)
+	}
+	defer clear(content)
+
+	changed, err := a.writeIfChanged(target, content, t.fileMode)
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		logging.Printf("Agent rendered %s to %s", t.Source, target)
+	}
+	return changed, nil
+}
+
+// lookup reads a secret in the agent namespace for template rendering
+func (a *Agent) lookup(ctx context.Context, name string) (map[string][]byte, error) {
+	secret, err := k8s.ReadSecret(ctx, name, a.namespace, a.clientset)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", name, err)
+	}
+	return secret.Data, nil
+}
+
+// project writes the selected keys of one secret to their files
+func (a *Agent) project(ctx context.Context, p *Projection) (int, error) {
+	secret, err := k8s.ReadSecret(ctx, p.Secret, a.namespace, a.clientset)
+	if err != nil {
+		return 0, fmt.Errorf("secret %s: %w", p.Secret, err)
+	}
+	defer k8s.WipeSecretData(secret.Data)
+
+	keys := p.Keys
+	if len(keys) == 0 {
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	var errs []error
+	written := 0
+	for _, key := range keys {
+		value, ok := secret.Data[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("secret %s: key %s not found", p.Secret, key))
+			continue
+		}
+		target, err := a.renderPath(p, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		changed, err := a.writeIfChanged(target, value, p.fileMode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if changed {
+			written++
+			logging.Printf("Agent projected %s/%s to %s", p.Secret, key, target)
+		}
+	}
+	return written, errors.Join(errs...)
+}
+
+// renderPath expands the projection path template and keeps the result inside OutputDir
+func (a *Agent) renderPath(p *Projection, key string) (string, error) {
+	var buf bytes.Buffer
+	if err := p.pathTemplate.Execute(&buf, pathData{Secret: p.Secret, Key: key, Namespace: a.namespace}); err != nil {
+		return "", fmt.Errorf("secret %s: rendering path for key %s: %w", p.Secret, key, err)
+	}
+	return a.resolvePath(buf.String())
+}
+
+// resolvePath joins a relative path to OutputDir, rejecting paths that escape it
+func (a *Agent) resolvePath(relative string) (string, error) {
+	target := filepath.Join(a.cfg.OutputDir, relative)
+	rel, err := filepath.Rel(a.cfg.OutputDir, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q escapes the output directory", relative)
+	}
+	return target, nil
+}
+
+// writeIfChanged atomically writes content to path unless the last write had the same content
+func (a *Agent) writeIfChanged(path string, content []byte, mode os.FileMode) (bool, error) {
+	sum := sha256.Sum256(content)
+	if previous, ok := a.written[path]; ok && previous == sum {
+		if _, err := os.Stat(path); err == nil {
+			return false, nil
+		}
+	}
+	if err := writeAtomic(path, content, mode); err != nil {
+		return false, err
+	}
+	a.written[path] = sum
+	return true, nil
+}
+
+// writeAtomic writes content to a temporary file in the target directory and renames it into place
+func writeAtomic(path string, content []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	cleanup := func(err error) error {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	if _, err := tmp.Write(content); err != nil {
+		return cleanup(fmt.Errorf("failed to write %s: %w", path, err))
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return cleanup(fmt.Errorf("failed to set mode on %s: %w", path, err))
+	}
+	if err := tmp.Sync(); err != nil {
+		return cleanup(fmt.Errorf("failed to sync %s: %w", path, err))
+	}
+	if err := tmp.Close(); err != nil {
+		return cleanup(fmt.Errorf("failed to close %s: %w", path, err))
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to move %s into place: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"bitwarden-reader/internal/render"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Defaults applied when the agent config leaves a field empty
+const (
+	defaultFileMode     = 0o400
+	defaultInterval     = 30 * time.Second
+	defaultPathTemplate = "{{ .Secret }}/{{ .Key }}"
+)
+
+// Config describes which secret keys the agent projects to files and where
+type Config struct {
+	OutputDir   string         `json:"outputDir"`
+	FileMode    string         `json:"fileMode,omitempty"`
+	Interval    string         `json:"interval,omitempty"`
+	Projections []Projection   `json:"projections"`
+	Templates   []TemplateFile `json:"templates,omitempty"`
+
+	interval time.Duration
+	fileMode os.FileMode
+}
+
+// Projection maps the keys of one secret to files
+type Projection struct {
+	Secret string   `json:"secret"`
+	Keys   []string `json:"keys,omitempty"`
+	// Path is a template relative to OutputDir with .Secret, .Key, and .Namespace available
+	Path string `json:"path,omitempty"`
+	Mode string `json:"mode,omitempty"`
+
+	pathTemplate *template.Template
+	fileMode     os.FileMode
+}
+
+// TemplateFile renders a template referencing several secrets to a single file
+type TemplateFile struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	Mode   string `json:"mode,omitempty"`
+
+	tmpl     *template.Template
+	fileMode os.FileMode
+}
+
+// LoadConfig reads and validates an agent config file (YAML or JSON)
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent config: %w", err)
+	}
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse agent config %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid agent config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// validate checks the config and compiles templates and file modes
+func (c *Config) validate() error {
+	if c.OutputDir == "" {
+		return fmt.Errorf("outputDir is required")
+	}
+
+	c.interval = defaultInterval
+	if c.Interval != "" {
+		interval, err := time.ParseDuration(c.Interval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("interval %q is not a positive duration", c.Interval)
+		}
+		c.interval = interval
+	}
+
+	mode, err := parseFileMode(c.FileMode, defaultFileMode)
+	if err != nil {
+		return err
+	}
+	c.fileMode = mode
+
+	for i := range c.Projections {
+		p := &c.Projections[i]
+		if strings.TrimSpace(p.Secret) == "" {
+			return fmt.Errorf("projections[%d]: secret is required", i)
+		}
+		if p.Path == "" {
+			p.Path = defaultPathTemplate
+		}
+		p.pathTemplate, err = template.New(p.Secret).Option("missingkey=error").Parse(p.Path)
+		if err != nil {
+			return fmt.Errorf("projections[%d]: invalid path template: %w", i, err)
+		}
+		if p.fileMode, err = parseFileMode(p.Mode, c.fileMode); err != nil {
+			return fmt.Errorf("projections[%d]: %w", i, err)
+		}
+	}
+
+	for i := range c.Templates {
+		t := &c.Templates[i]
+		if t.Source == "" || t.Path == "" {
+			return fmt.Errorf("templates[%d]: source and path are required", i)
+		}
+		if t.tmpl, err = render.ParseFile(t.Source); err != nil {
+			return fmt.Errorf("templates[%d]: %w", i, err)
+		}
+		if t.fileMode, err = parseFileMode(t.Mode, c.fileMode); err != nil {
+			return fmt.Errorf("templates[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// parseFileMode parses an octal file mode such as "0440", returning fallback when empty
+func parseFileMode(value string, fallback os.FileMode) (os.FileMode, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("file mode %q is not an octal permission", value)
+	}
+	return os.FileMode(mode), nil
+}
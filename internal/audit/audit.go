@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/envelope"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/writebehind"
+)
+
+// Outcome values recorded on audit events
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+	OutcomeDryRun  = "dry-run"
+)
+
+// Event is a single audit record for a mutating or sensitive operation
+type Event struct {
+	Time      time.Time         `json:"time"`
+	Action    string            `json:"action"`
+	Actor     string            `json:"actor"`
+	Resource  string            `json:"resource"`
+	Namespace string            `json:"namespace,omitempty"`
+	Outcome   string            `json:"outcome"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Sink receives audit events
+type Sink interface {
+	Write(event Event) error
+}
+
+// Logger fans audit events out to all configured sinks
+type Logger struct {
+	sinks []Sink
+	// forwarders are the sinks delivering to external systems, also listed in sinks
+	forwarders []*forwarder
+}
+
+// NewLogger creates an audit logger that always writes to the process log
+// and additionally appends JSON lines to filePath when it is set, through a write-behind queue configured by persist
+// When encrypter is non-nil each file line is an envelope-encrypted record
+func NewLogger(filePath string, encrypter *envelope.Encrypter, persist writebehind.Options) (*Logger, error) {
+	logger := &Logger{
+		sinks: []Sink{logSink{}},
+	}
+	if filePath != "" {
+		sink, err := newFileSink(filePath, encrypter, persist)
+		if err != nil {
+			return nil, err
+		}
+		logger.sinks = append(logger.sinks, sink)
+	}
+	return logger, nil
+}
+
+// Record writes an event to every sink, logging sink failures
+func (l *Logger) Record(event Event) {
+	if l == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	for _, sink := range l.sinks {
+		if err := sink.Write(event); err != nil {
+			logging.Printf("Error writing audit event: %v", err)
+		}
+	}
+}
+
+// logSink writes audit events to the standard logger
+type logSink struct{}
+
+// Write logs the event as a single JSON line
+func (logSink) Write(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+	logging.Printf("AUDIT %s", data)
+	return nil
+}
+
+// fileSink appends audit events as JSON lines to a file
+type fileSink struct {
+	mu        sync.Mutex
+	path      string
+	out       *writebehind.Writer
+	encrypter *envelope.Encrypter
+}
+
+// newFileSink opens the audit file for appending
+func newFileSink(path string, encrypter *envelope.Encrypter, persist writebehind.Options) (*fileSink, error) {
+	out, err := writebehind.Open(path, persist)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	return &fileSink{path: path, out: out, encrypter: encrypter}, nil
+}
+
+// Write queues the event for the file
+func (f *fileSink) Write(event Event) error {
+	var data []byte
+	var err error
+	if f.encrypter != nil {
+		data, err = f.encrypter.SealJSON(event)
+	} else {
+		data, err = json.Marshal(event)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.out.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newDelivery creates the delivery for a validated sink config
+func newDelivery(cfg SinkConfig) delivery {
+	client := &http.Client{Timeout: forwardTimeout}
+	switch cfg.Type {
+	case sinkKafka:
+		return kafkaDelivery{url: cfg.URL, urlFile: cfg.URLFile, authorizationFile: cfg.AuthorizationFile, topic: cfg.Topic, client: client}
+	case sinkSyslog:
+		address, _ := url.Parse(cfg.Address)
+		appName := cfg.AppName
+		if appName == "" {
+			appName = "bitwarden-reader"
+		}
+		return &syslogDelivery{network: address.Scheme, host: address.Host, caFile: cfg.CAFile, appName: appName}
+	default:
+		return webhookDelivery{url: cfg.URL, urlFile: cfg.URLFile, authorizationFile: cfg.AuthorizationFile, client: client}
+	}
+}
+
+// readFile returns the trimmed contents of file, or value when file is empty
+func readFile(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// post sends body to target and fails on non-2xx responses
+func post(ctx context.Context, client *http.Client, target, contentType, authorizationFile string, body interface{}) error {
+	authorization, err := readFile("", authorizationFile)
+	if err != nil {
+		return fmt.Errorf("failed to read authorization file: %w", err)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid URL")
+	}
+	req.Header.Set("Content-Type", contentType)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL may embed a token, so only the underlying failure is reported
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookDelivery posts each batch as a JSON array of events
+type webhookDelivery struct {
+	url               string
+	urlFile           string
+	authorizationFile string
+	client            *http.Client
+}
+
+func (d webhookDelivery) deliver(ctx context.Context, events []Event) error {
+	target, err := readFile(d.url, d.urlFile)
+	if err != nil {
+		return fmt.Errorf("failed to read URL file: %w", err)
+	}
+	return post(ctx, d.client, target, "application/json", d.authorizationFile, events)
+}
+
+// kafkaDelivery produces events to a topic through the Kafka REST Proxy v2 API
+type kafkaDelivery struct {
+	url               string
+	urlFile           string
+	authorizationFile string
+	topic             string
+	client            *http.Client
+}
+
+func (d kafkaDelivery) deliver(ctx context.Context, events []Event) error {
+	base, err := readFile(d.url, d.urlFile)
+	if err != nil {
+		return fmt.Errorf("failed to read URL file: %w", err)
+	}
+	type record struct {
+		Key   string `json:"key"`
+		Value Event  `json:"value"`
+	}
+	records := make([]record, 0, len(events))
+	for _, event := range events {
+		// Keying by resource keeps each resource's events in order on one partition
+		key := event.Resource
+		if event.Namespace != "" {
+			key = event.Namespace + "/" + key
+		}
+		records = append(records, record{Key: key, Value: event})
+	}
+	target := strings.TrimSuffix(base, "/") + "/topics/" + url.PathEscape(d.topic)
+	return post(ctx, d.client, target, "application/vnd.kafka.json.v2+json", d.authorizationFile, map[string]interface{}{"records": records})
+}
+
+// syslogDelivery sends RFC 5424 messages, octet-counted over TCP and TLS (RFC 6587)
+// UDP gives no delivery guarantee
+type syslogDelivery struct {
+	network string
+	host    string
+	caFile  string
+	appName string
+
+	mu       sync.Mutex
+	conn     net.Conn
+	hostname string
+}
+
+// Syslog facility log audit (13) and severities
+const (
+	syslogFacility        = 13
+	syslogSeverityInfo    = 6
+	syslogSeverityWarning = 4
+)
+
+func (d *syslogDelivery) deliver(ctx context.Context, events []Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.conn == nil {
+		conn, err := d.dial(ctx)
+		if err != nil {
+			return err
+		}
+		d.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = d.conn.SetWriteDeadline(deadline)
+	}
+
+	for _, event := range events {
+		message, err := d.format(event)
+		if err != nil {
+			return err
+		}
+		if d.network != "udp" {
+			message = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+		}
+		if _, err := d.conn.Write(message); err != nil {
+			d.conn.Close()
+			d.conn = nil
+			return fmt.Errorf("failed to write to syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+// dial connects to the syslog server
+func (d *syslogDelivery) dial(ctx context.Context) (net.Conn, error) {
+	if d.hostname == "" {
+		d.hostname, _ = os.Hostname()
+		if d.hostname == "" {
+			d.hostname = "-"
+		}
+	}
+	dialer := &net.Dialer{Timeout: forwardTimeout}
+	if d.network != "tls" {
+		conn, err := dialer.DialContext(ctx, d.network, d.host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		return conn, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if d.caFile != "" {
+		pem, err := os.ReadFile(d.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read syslog CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in syslog CA file")
+		}
+	}
+	conn, err := (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", d.host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return conn, nil
+}
+
+// format renders an event as an RFC 5424 message with the event JSON as the message body
+func (d *syslogDelivery) format(event Event) ([]byte, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	severity := syslogSeverityInfo
+	if event.Outcome == OutcomeFailure || event.Outcome == OutcomeDenied {
+		severity = syslogSeverityWarning
+	}
+	msgID := event.Action
+	if msgID == "" || len(msgID) > 32 {
+		msgID = "-"
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s - ",
+		syslogFacility*8+severity, event.Time.UTC().Format(time.RFC3339Nano), d.hostname, d.appName, os.Getpid(), msgID)
+	return append([]byte(header), body...), nil
+}
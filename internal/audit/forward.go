@@ -0,0 +1,210 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/envelope"
+	"bitwarden-reader/internal/logging"
+)
+
+// Delivery tuning for external sinks
+const (
+	forwardBatchSize  = 100
+	forwardTimeout    = 15 * time.Second
+	forwardMinBackoff = time.Second
+	forwardMaxBackoff = time.Minute
+)
+
+// delivery sends a batch of events to an external system
+type delivery interface {
+	deliver(ctx context.Context, events []Event) error
+}
+
+// forwarder is a Sink that buffers events and delivers them to an external system in the background
+// Events stay buffered until the system accepted them, so they are delivered at least once
+type forwarder struct {
+	name      string
+	kind      string
+	delivery  delivery
+	queue     queue
+	limit     int
+	encrypter *envelope.Encrypter
+	wake      chan struct{}
+	closing   chan struct{}
+	done      chan struct{}
+
+	mu           sync.Mutex
+	delivered    uint64
+	failures     uint64
+	dropped      uint64
+	lastError    string
+	lastDelivery time.Time
+}
+
+// newForwarder starts delivering the queue's events
+func newForwarder(name, kind string, d delivery, q queue, limit int, encrypter *envelope.Encrypter) *forwarder {
+	f := &forwarder{
+		name:      name,
+		kind:      kind,
+		delivery:  d,
+		queue:     q,
+		limit:     limit,
+		encrypter: encrypter,
+		wake:      make(chan struct{}, 1),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+// Write buffers the event; it fails when the buffer is full
+func (f *forwarder) Write(event Event) error {
+	if f.queue.len() >= f.limit {
+		f.mu.Lock()
+		f.dropped++
+		f.mu.Unlock()
+		return fmt.Errorf("audit sink %s buffer is full, dropping %s event", f.name, event.Action)
+	}
+
+	var line []byte
+	var err error
+	if f.encrypter != nil {
+		line, err = f.encrypter.SealJSON(event)
+	} else {
+		line, err = json.Marshal(event)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+	if err := f.queue.push(line); err != nil {
+		return err
+	}
+
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// decode reads a buffered event, opening sealed lines
+func (f *forwarder) decode(line []byte) (Event, error) {
+	var sealed envelope.Sealed
+	if err := json.Unmarshal(line, &sealed); err == nil && sealed.Ciphertext != "" {
+		if f.encrypter == nil {
+			return Event{}, fmt.Errorf("buffered event is encrypted but PERSISTENCE_ENCRYPTION is not configured")
+		}
+		plaintext, err := f.encrypter.Open(&sealed)
+		if err != nil {
+			return Event{}, err
+		}
+		line = plaintext
+	}
+	var event Event
+	err := json.Unmarshal(line, &event)
+	return event, err
+}
+
+// run delivers batches, backing off while the sink fails, until it is closed and drained
+func (f *forwarder) run() {
+	defer close(f.done)
+	backoff := forwardMinBackoff
+	for {
+		lines, err := f.queue.peek(forwardBatchSize)
+		if err != nil {
+			logging.Printf("Error reading buffered audit events for %s: %v", f.name, err)
+		}
+		if len(lines) == 0 {
+			select {
+			case <-f.wake:
+				continue
+			case <-f.closing:
+				return
+			}
+		}
+
+		events := make([]Event, 0, len(lines))
+		for _, line := range lines {
+			event, err := f.decode(line)
+			if err != nil {
+				// An unreadable event would block the sink forever
+				logging.Printf("Skipping unreadable buffered audit event for %s: %v", f.name, err)
+				continue
+			}
+			events = append(events, event)
+		}
+
+		err = nil
+		if len(events) > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), forwardTimeout)
+			err = f.delivery.deliver(ctx, events)
+			cancel()
+		}
+		if err == nil {
+			err = f.queue.ack()
+			f.recordResult(len(events), err)
+			backoff = forwardMinBackoff
+			continue
+		}
+
+		f.recordResult(0, err)
+		logging.Printf("Audit sink %s delivery failed, %d events buffered: %v", f.name, f.queue.len(), err)
+		select {
+		case <-time.After(backoff):
+		case <-f.closing:
+			// Give up after the failed attempt when shutting down; with a bufferDir the events wait on disk
+			return
+		}
+		backoff = min(2*backoff, forwardMaxBackoff)
+	}
+}
+
+// recordResult updates the delivery counters
+func (f *forwarder) recordResult(delivered int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		f.failures++
+		f.lastError = err.Error()
+		return
+	}
+	f.delivered += uint64(delivered)
+	f.lastDelivery = time.Now().UTC()
+	f.lastError = ""
+}
+
+// close stops the forwarder once the buffer is drained, a delivery fails, or until passes
+func (f *forwarder) close(until time.Time) {
+	close(f.closing)
+	select {
+	case <-f.done:
+	case <-time.After(time.Until(until)):
+		logging.Printf("Audit sink %s still has %d undelivered events at shutdown", f.name, f.queue.len())
+	}
+}
+
+// status returns the delivery state
+func (f *forwarder) status() SinkStatus {
+	pending := f.queue.len()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := SinkStatus{
+		Name:      f.name,
+		Type:      f.kind,
+		Pending:   pending,
+		Delivered: f.delivered,
+		Failures:  f.failures,
+		Dropped:   f.dropped,
+		LastError: f.lastError,
+	}
+	if !f.lastDelivery.IsZero() {
+		lastDelivery := f.lastDelivery
+		status.LastDelivery = &lastDelivery
+	}
+	return status
+}
@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// queue holds encoded events until a sink acknowledged them
+// Events are removed only by ack, so a crash between delivery and ack delivers them again
+type queue interface {
+	push(line []byte) error
+	// peek returns up to max of the oldest events without removing them
+	peek(max int) ([][]byte, error)
+	// ack removes the events returned by the last peek
+	ack() error
+	len() int
+}
+
+// memoryQueue is a queue that is lost on restart
+type memoryQueue struct {
+	mu     sync.Mutex
+	lines  [][]byte
+	peeked int
+}
+
+func (q *memoryQueue) push(line []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lines = append(q.lines, line)
+	return nil
+}
+
+func (q *memoryQueue) peek(max int) ([][]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.peeked = min(max, len(q.lines))
+	return append([][]byte(nil), q.lines[:q.peeked]...), nil
+}
+
+func (q *memoryQueue) ack() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lines = q.lines[q.peeked:]
+	q.peeked = 0
+	return nil
+}
+
+func (q *memoryQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.lines)
+}
+
+// fileQueue is a queue in a JSON lines spool file with the delivered byte offset in a second file
+// The spool is truncated whenever everything in it was delivered
+type fileQueue struct {
+	mu         sync.Mutex
+	path       string
+	file       *os.File
+	offset     int64
+	size       int64
+	pending    int
+	peekedSize int64
+	peeked     int
+}
+
+// openFileQueue opens the spool of a sink in dir, counting the events not yet delivered
+func openFileQueue(dir, name string) (*fileQueue, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit buffer directory: %w", err)
+	}
+	q := &fileQueue{path: filepath.Join(dir, name+".spool")}
+
+	file, err := os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit buffer: %w", err)
+	}
+	q.file = file
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open audit buffer: %w", err)
+	}
+	q.size = info.Size()
+
+	if data, err := os.ReadFile(q.path + ".offset"); err == nil {
+		q.offset, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		file.Close()
+		return nil, fmt.Errorf("failed to read audit buffer offset: %w", err)
+	}
+	if q.offset < 0 || q.offset > q.size {
+		q.offset = 0
+	}
+
+	lines, _, err := q.read(-1)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	q.pending = len(lines)
+	return q, nil
+}
+
+// read returns up to max lines after the delivered offset (all when max is negative) and their size
+func (q *fileQueue) read(max int) ([][]byte, int64, error) {
+	reader := io.NewSectionReader(q.file, q.offset, q.size-q.offset)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var lines [][]byte
+	var size int64
+	for (max < 0 || len(lines) < max) && scanner.Scan() {
+		size += int64(len(scanner.Bytes())) + 1
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read audit buffer: %w", err)
+	}
+	return lines, size, nil
+}
+
+func (q *fileQueue) push(line []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n, err := q.file.Write(append(line, '\n'))
+	q.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit buffer: %w", err)
+	}
+	q.pending++
+	return nil
+}
+
+func (q *fileQueue) peek(max int) ([][]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lines, size, err := q.read(max)
+	if err != nil {
+		return nil, err
+	}
+	q.peeked, q.peekedSize = len(lines), size
+	return lines, nil
+}
+
+func (q *fileQueue) ack() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.offset += q.peekedSize
+	q.pending -= q.peeked
+	q.peeked, q.peekedSize = 0, 0
+
+	if q.offset >= q.size {
+		if err := q.file.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate audit buffer: %w", err)
+		}
+		q.offset, q.size, q.pending = 0, 0, 0
+	}
+	if err := os.WriteFile(q.path+".offset", []byte(strconv.FormatInt(q.offset, 10)), 0o600); err != nil {
+		return fmt.Errorf("failed to save audit buffer offset: %w", err)
+	}
+	return nil
+}
+
+func (q *fileQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending
+}
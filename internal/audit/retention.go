@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"bitwarden-reader/internal/envelope"
+	"bitwarden-reader/internal/writebehind"
+)
+
+// Compact removes events older than before from the audit file and returns how many were removed
+// Lines whose time can't be read, such as encrypted lines without the key, are kept
+func (l *Logger) Compact(before time.Time) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	removed := 0
+	for _, sink := range l.sinks {
+		if file, ok := sink.(*fileSink); ok {
+			n, err := file.compact(before)
+			removed += n
+			if err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// WriterStats returns the write-behind queue state of the audit file; zero when events are only logged
+func (l *Logger) WriterStats() writebehind.Stats {
+	if l == nil {
+		return writebehind.Stats{}
+	}
+	for _, sink := range l.sinks {
+		if file, ok := sink.(*fileSink); ok {
+			return file.out.Stats()
+		}
+	}
+	return writebehind.Stats{}
+}
+
+// FileSize returns the size of the audit file in bytes; 0 when events are only logged
+func (l *Logger) FileSize() int64 {
+	if l == nil {
+		return 0
+	}
+	var size int64
+	for _, sink := range l.sinks {
+		if file, ok := sink.(*fileSink); ok {
+			size += file.size()
+		}
+	}
+	return size
+}
+
+// compact rewrites the file without the events older than before
+// Writes wait while the file is rewritten, after the queued ones were written
+func (f *fileSink) compact(before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.out.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write queued audit events: %w", err)
+	}
+
+	source, err := os.Open(f.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	defer source.Close()
+
+	temp := f.path + ".compact"
+	target, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create compacted audit log file: %w", err)
+	}
+	writer := bufio.NewWriter(target)
+
+	removed := 0
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if eventTime, ok := f.eventTime(line); ok && eventTime.Before(before) {
+			removed++
+			continue
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	err = scanner.Err()
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = target.Sync()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && removed > 0 {
+		err = os.Rename(temp, f.path)
+	}
+	if err != nil || removed == 0 {
+		os.Remove(temp)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write compacted audit log file: %w", err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	// Appends must go to the new file
+	if err := f.out.Reopen(); err != nil {
+		return removed, fmt.Errorf("failed to reopen audit log file: %w", err)
+	}
+	return removed, nil
+}
+
+// eventTime reads the time of an audit line, decrypting it when it is sealed
+func (f *fileSink) eventTime(line []byte) (time.Time, bool) {
+	var sealed envelope.Sealed
+	if err := json.Unmarshal(line, &sealed); err == nil && sealed.Ciphertext != "" {
+		if f.encrypter == nil {
+			return time.Time{}, false
+		}
+		plaintext, err := f.encrypter.Open(&sealed)
+		if err != nil {
+			return time.Time{}, false
+		}
+		line = plaintext
+	}
+	var event struct {
+		Time time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(line, &event); err != nil || event.Time.IsZero() {
+		return time.Time{}, false
+	}
+	return event.Time, true
+}
+
+// size returns the size of the file in bytes
+func (f *fileSink) size() int64 {
+	return f.out.Size()
+}
@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"bitwarden-reader/internal/envelope"
+	"bitwarden-reader/internal/logging"
+
+	"sigs.k8s.io/yaml"
+)
+
+// External sink types
+const (
+	sinkWebhook = "webhook"
+	sinkKafka   = "kafka"
+	sinkSyslog  = "syslog"
+)
+
+// defaultBufferLimit bounds the events waiting for one sink
+const defaultBufferLimit = 100000
+
+// SinksConfig is the AUDIT_SINKS_FILE format (YAML or JSON)
+type SinksConfig struct {
+	// BufferDir keeps undelivered events on disk so they survive restarts; memory only when empty
+	BufferDir string `json:"bufferDir,omitempty"`
+	// BufferLimit is the number of events buffered per sink before new ones are dropped
+	BufferLimit int          `json:"bufferLimit,omitempty"`
+	Sinks       []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig is one external destination for audit events
+// Credentials are only read from files, which are re-read for every delivery
+type SinkConfig struct {
+	Name string `json:"name"`
+	// Type is webhook (JSON POST of an event array), kafka (Kafka REST Proxy), or syslog (RFC 5424)
+	Type string `json:"type"`
+	// URL is the webhook URL, or the Kafka REST Proxy base URL
+	URL     string `json:"url,omitempty"`
+	URLFile string `json:"urlFile,omitempty"`
+	// AuthorizationFile holds the Authorization header value for webhook and kafka sinks
+	AuthorizationFile string `json:"authorizationFile,omitempty"`
+	Topic             string `json:"topic,omitempty"`
+	// Address is udp://host:port, tcp://host:port, or tls://host:port for syslog
+	Address string `json:"address,omitempty"`
+	// CAFile verifies the syslog server for tls; the system roots are used when empty
+	CAFile  string `json:"caFile,omitempty"`
+	AppName string `json:"appName,omitempty"`
+}
+
+// LoadSinksConfig reads and validates an audit sinks file
+func LoadSinksConfig(path string) (*SinksConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit sinks config: %w", err)
+	}
+	var cfg SinksConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse audit sinks config %s: %w", path, err)
+	}
+	if cfg.BufferLimit <= 0 {
+		cfg.BufferLimit = defaultBufferLimit
+	}
+
+	names := make(map[string]bool, len(cfg.Sinks))
+	for i, sink := range cfg.Sinks {
+		if sink.Name == "" {
+			return nil, fmt.Errorf("invalid audit sinks config %s: sink %d has no name", path, i+1)
+		}
+		if names[sink.Name] {
+			return nil, fmt.Errorf("invalid audit sinks config %s: duplicate sink name %q", path, sink.Name)
+		}
+		names[sink.Name] = true
+		if err := sink.validate(); err != nil {
+			return nil, fmt.Errorf("invalid audit sinks config %s: sink %s: %w", path, sink.Name, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// validate checks that the sink has what its type needs
+func (c SinkConfig) validate() error {
+	switch c.Type {
+	case sinkWebhook, sinkKafka:
+		if (c.URL == "") == (c.URLFile == "") {
+			return fmt.Errorf("exactly one of url and urlFile is required")
+		}
+		if c.Type == sinkKafka && c.Topic == "" {
+			return fmt.Errorf("topic is required")
+		}
+	case sinkSyslog:
+		address, err := url.Parse(c.Address)
+		if err != nil || address.Host == "" {
+			return fmt.Errorf("address must look like tls://host:port")
+		}
+		switch address.Scheme {
+		case "udp", "tcp", "tls":
+		default:
+			return fmt.Errorf("address scheme must be udp, tcp, or tls")
+		}
+	default:
+		return fmt.Errorf("type must be webhook, kafka, or syslog")
+	}
+	return nil
+}
+
+// Forward adds a buffered forwarder to the logger for each configured sink
+// Buffered events on disk are sealed when encrypter is non-nil
+func (l *Logger) Forward(cfg *SinksConfig, encrypter *envelope.Encrypter) error {
+	if cfg.BufferDir == "" {
+		encrypter = nil
+	}
+	for _, sinkConfig := range cfg.Sinks {
+		var q queue = &memoryQueue{}
+		if cfg.BufferDir != "" {
+			fileQ, err := openFileQueue(cfg.BufferDir, sinkConfig.Name)
+			if err != nil {
+				return err
+			}
+			q = fileQ
+		}
+
+		f := newForwarder(sinkConfig.Name, sinkConfig.Type, newDelivery(sinkConfig), q, cfg.BufferLimit, encrypter)
+		l.sinks = append(l.sinks, f)
+		l.forwarders = append(l.forwarders, f)
+	}
+	return nil
+}
+
+// Close delivers what is still buffered for up to deadline, then writes the queued events and closes the audit file
+func (l *Logger) Close(deadline time.Duration) {
+	if l == nil {
+		return
+	}
+	until := time.Now().Add(deadline)
+	for _, f := range l.forwarders {
+		f.close(until)
+	}
+	for _, sink := range l.sinks {
+		if file, ok := sink.(*fileSink); ok {
+			file.mu.Lock()
+			if err := file.out.Close(); err != nil {
+				logging.Printf("Error closing audit log file: %v", err)
+			}
+			file.mu.Unlock()
+		}
+	}
+}
+
+// SinkStatus is the delivery state of one external sink
+type SinkStatus struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Pending      int        `json:"pending"`
+	Delivered    uint64     `json:"delivered"`
+	Failures     uint64     `json:"failures"`
+	Dropped      uint64     `json:"dropped"`
+	LastError    string     `json:"lastError,omitempty"`
+	LastDelivery *time.Time `json:"lastDelivery,omitempty"`
+}
+
+// SinkStatuses returns the state of each external sink
+func (l *Logger) SinkStatuses() []SinkStatus {
+	if l == nil {
+		return nil
+	}
+	statuses := make([]SinkStatus, 0, len(l.forwarders))
+	for _, f := range l.forwarders {
+		statuses = append(statuses, f.status())
+	}
+	return statuses
+}
@@ -0,0 +1,519 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// The age v1 file format (https://age-encryption.org/v1) with X25519 recipients, so backups can also
+// be decrypted with the age CLI and keys from age-keygen can be used
+const (
+	ageVersionLine     = "age-encryption.org/v1"
+	ageX25519Type      = "X25519"
+	ageX25519Label     = "age-encryption.org/v1/X25519"
+	ageRecipientHRP    = "age"
+	ageIdentityHRP     = "age-secret-key-"
+	ageFileKeySize     = 16
+	ageStreamNonceSize = 16
+	ageChunkSize       = 64 * 1024
+	ageColumns         = 64
+)
+
+// ageBase64 is the unpadded, canonical base64 used throughout the age header
+var ageBase64 = base64.RawStdEncoding.Strict()
+
+// ageStanza is a recipient stanza of an age header
+type ageStanza struct {
+	kind string
+	args []string
+	body []byte
+}
+
+// ParseRecipient decodes an age X25519 recipient ("age1...") into its public key
+func ParseRecipient(recipient string) ([]byte, error) {
+	hrp, key, err := bech32Decode(strings.TrimSpace(recipient))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age recipient: %w", err)
+	}
+	if hrp != ageRecipientHRP || len(key) != curve25519.PointSize {
+		return nil, fmt.Errorf("invalid age recipient: not an X25519 recipient")
+	}
+	return key, nil
+}
+
+// ParseIdentity decodes an age X25519 identity ("AGE-SECRET-KEY-1...") into its private key
+func ParseIdentity(identity string) ([]byte, error) {
+	hrp, key, err := bech32Decode(strings.TrimSpace(identity))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identity: %w", err)
+	}
+	if hrp != ageIdentityHRP || len(key) != curve25519.ScalarSize {
+		return nil, fmt.Errorf("invalid age identity: not an X25519 identity")
+	}
+	return key, nil
+}
+
+// ParseIdentities reads the identities of an age key file, one per line, skipping blank and # comment lines
+func ParseIdentities(data string) ([][]byte, error) {
+	var identities [][]byte
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		identities = append(identities, identity)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no age identities found")
+	}
+	return identities, nil
+}
+
+// generateAgeKeyPair returns a new age X25519 recipient and identity
+func generateAgeKeyPair() (recipient, identity string, err error) {
+	secret := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	public, err := curve25519.X25519(secret, curve25519.Basepoint)
+	if err != nil {
+		return "", "", err
+	}
+	recipient, err = bech32Encode(ageRecipientHRP, public)
+	if err != nil {
+		return "", "", err
+	}
+	identity, err = bech32Encode(ageIdentityHRP, secret)
+	if err != nil {
+		return "", "", err
+	}
+	return recipient, strings.ToUpper(identity), nil
+}
+
+// ageEncrypt encrypts plaintext into an age file readable by any of the recipient public keys
+func ageEncrypt(plaintext []byte, recipients [][]byte) ([]byte, error) {
+	fileKey := make([]byte, ageFileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+	defer clear(fileKey)
+
+	var header bytes.Buffer
+	header.WriteString(ageVersionLine + "\n")
+	for _, recipient := range recipients {
+		stanza, err := wrapX25519(fileKey, recipient)
+		if err != nil {
+			return nil, err
+		}
+		writeStanza(&header, stanza)
+	}
+	header.WriteString("---")
+	mac, err := headerMAC(fileKey, header.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	header.WriteString(" " + ageBase64.EncodeToString(mac) + "\n")
+
+	nonce := make([]byte, ageStreamNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	payloadKey, err := hkdfKey(fileKey, nonce, "payload")
+	if err != nil {
+		return nil, err
+	}
+	defer clear(payloadKey)
+	aead, err := chacha20poly1305.New(payloadKey)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append(header.Bytes(), nonce...)
+	var counter [chacha20poly1305.NonceSize]byte
+	for {
+		chunk := plaintext
+		last := len(chunk) <= ageChunkSize
+		if !last {
+			chunk = chunk[:ageChunkSize]
+		}
+		if last {
+			counter[len(counter)-1] = 1
+		}
+		out = aead.Seal(out, counter[:], chunk, nil)
+		if last {
+			return out, nil
+		}
+		plaintext = plaintext[ageChunkSize:]
+		incrementCounter(&counter)
+	}
+}
+
+// ageDecrypt decrypts an age file with the first identity that unwraps its file key
+func ageDecrypt(file []byte, identities [][]byte) ([]byte, error) {
+	stanzas, headerBytes, mac, payload, err := parseAgeHeader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileKey []byte
+	for _, stanza := range stanzas {
+		if stanza.kind != ageX25519Type {
+			continue
+		}
+		for _, identity := range identities {
+			if fileKey, err = unwrapX25519(stanza, identity); err == nil {
+				break
+			}
+		}
+		if fileKey != nil {
+			break
+		}
+	}
+	if fileKey == nil {
+		return nil, fmt.Errorf("no identity matches any recipient of the backup")
+	}
+	defer clear(fileKey)
+
+	expected, err := headerMAC(fileKey, headerBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, expected) {
+		return nil, fmt.Errorf("age header MAC mismatch")
+	}
+
+	if len(payload) < ageStreamNonceSize {
+		return nil, fmt.Errorf("age payload is truncated")
+	}
+	payloadKey, err := hkdfKey(fileKey, payload[:ageStreamNonceSize], "payload")
+	if err != nil {
+		return nil, err
+	}
+	defer clear(payloadKey)
+	aead, err := chacha20poly1305.New(payloadKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := payload[ageStreamNonceSize:]
+	var plaintext []byte
+	var counter [chacha20poly1305.NonceSize]byte
+	for first := true; ; first = false {
+		chunk := ciphertext
+		last := len(chunk) <= ageChunkSize+aead.Overhead()
+		if !last {
+			chunk = chunk[:ageChunkSize+aead.Overhead()]
+		}
+		if last {
+			counter[len(counter)-1] = 1
+		}
+		opened, err := aead.Open(plaintext, counter[:], chunk, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt age payload: %w", err)
+		}
+		if last && !first && len(opened) == len(plaintext) {
+			return nil, fmt.Errorf("age payload ends with an empty chunk")
+		}
+		plaintext = opened
+		if last {
+			return plaintext, nil
+		}
+		ciphertext = ciphertext[ageChunkSize+aead.Overhead():]
+		incrementCounter(&counter)
+	}
+}
+
+// wrapX25519 wraps the file key for an X25519 recipient with a fresh ephemeral key
+func wrapX25519(fileKey, recipient []byte) (ageStanza, error) {
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(ephemeral); err != nil {
+		return ageStanza{}, err
+	}
+	defer clear(ephemeral)
+	share, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return ageStanza{}, err
+	}
+	shared, err := curve25519.X25519(ephemeral, recipient)
+	if err != nil {
+		return ageStanza{}, err
+	}
+	body, err := sealFileKey(fileKey, shared, share, recipient)
+	if err != nil {
+		return ageStanza{}, err
+	}
+	return ageStanza{kind: ageX25519Type, args: []string{ageBase64.EncodeToString(share)}, body: body}, nil
+}
+
+// unwrapX25519 recovers the file key from an X25519 stanza with the identity's private key
+func unwrapX25519(stanza ageStanza, identity []byte) ([]byte, error) {
+	if len(stanza.args) != 1 {
+		return nil, fmt.Errorf("invalid X25519 stanza")
+	}
+	share, err := ageBase64.DecodeString(stanza.args[0])
+	if err != nil || len(share) != curve25519.PointSize {
+		return nil, fmt.Errorf("invalid X25519 stanza share")
+	}
+	if len(stanza.body) != ageFileKeySize+chacha20poly1305.Overhead {
+		return nil, fmt.Errorf("invalid X25519 stanza body")
+	}
+	recipient, err := curve25519.X25519(identity, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(identity, share)
+	if err != nil {
+		return nil, err
+	}
+	wrapKey, err := hkdfKey(shared, append(share, recipient...), ageX25519Label)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(wrapKey)
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), stanza.body, nil)
+}
+
+// sealFileKey encrypts the file key under the key derived from an X25519 exchange
+func sealFileKey(fileKey, shared, share, recipient []byte) ([]byte, error) {
+	wrapKey, err := hkdfKey(shared, append(append([]byte(nil), share...), recipient...), ageX25519Label)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(wrapKey)
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil), nil
+}
+
+// headerMAC authenticates the header up to and including its "---" line prefix
+func headerMAC(fileKey, header []byte) ([]byte, error) {
+	key, err := hkdfKey(fileKey, nil, "header")
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header)
+	return mac.Sum(nil), nil
+}
+
+// hkdfKey derives a 32-byte key with HKDF-SHA256
+func hkdfKey(secret, salt []byte, info string) ([]byte, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// incrementCounter advances the big-endian chunk counter in the first 11 bytes of the STREAM nonce
+func incrementCounter(nonce *[chacha20poly1305.NonceSize]byte) {
+	for i := len(nonce) - 2; i >= 0; i-- {
+		nonce[i]++
+		if nonce[i] != 0 {
+			return
+		}
+	}
+}
+
+// writeStanza writes a stanza with its body wrapped at 64 columns; a body that fills its last line
+// is followed by an empty line, so the end of the body is always a short line
+func writeStanza(w *bytes.Buffer, stanza ageStanza) {
+	w.WriteString("-> " + strings.Join(append([]string{stanza.kind}, stanza.args...), " ") + "\n")
+	encoded := ageBase64.EncodeToString(stanza.body)
+	for len(encoded) >= ageColumns {
+		w.WriteString(encoded[:ageColumns] + "\n")
+		encoded = encoded[ageColumns:]
+	}
+	w.WriteString(encoded + "\n")
+}
+
+// parseAgeHeader splits an age file into its stanzas, the header bytes covered by the MAC, the MAC,
+// and the payload
+func parseAgeHeader(file []byte) (stanzas []ageStanza, header, mac, payload []byte, err error) {
+	rest := file
+	nextLine := func() (string, bool) {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			return "", false
+		}
+		line := string(rest[:i])
+		rest = rest[i+1:]
+		return line, true
+	}
+
+	if line, ok := nextLine(); !ok || line != ageVersionLine {
+		return nil, nil, nil, nil, fmt.Errorf("not an age v1 file")
+	}
+	for {
+		start := len(file) - len(rest)
+		line, ok := nextLine()
+		if !ok {
+			return nil, nil, nil, nil, fmt.Errorf("age header is truncated")
+		}
+		if strings.HasPrefix(line, "---") {
+			encoded, found := strings.CutPrefix(line, "--- ")
+			if !found {
+				return nil, nil, nil, nil, fmt.Errorf("malformed age header MAC line")
+			}
+			if mac, err = ageBase64.DecodeString(encoded); err != nil {
+				return nil, nil, nil, nil, fmt.Errorf("malformed age header MAC: %w", err)
+			}
+			return stanzas, file[:start+len("---")], mac, rest, nil
+		}
+
+		fields := strings.Split(line, " ")
+		if len(fields) < 2 || fields[0] != "->" {
+			return nil, nil, nil, nil, fmt.Errorf("malformed age stanza %q", line)
+		}
+		stanza := ageStanza{kind: fields[1], args: fields[2:]}
+		for {
+			bodyLine, ok := nextLine()
+			if !ok {
+				return nil, nil, nil, nil, fmt.Errorf("age stanza body is truncated")
+			}
+			if len(bodyLine) > ageColumns {
+				return nil, nil, nil, nil, errors.New("age stanza body line is too long")
+			}
+			decoded, err := ageBase64.DecodeString(bodyLine)
+			if err != nil {
+				return nil, nil, nil, nil, fmt.Errorf("malformed age stanza body: %w", err)
+			}
+			stanza.body = append(stanza.body, decoded...)
+			if len(bodyLine) < ageColumns {
+				break
+			}
+		}
+		stanzas = append(stanzas, stanza)
+	}
+}
+
+// bech32Charset is the alphabet of bech32 data characters
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32Polymod computes the BCH checksum over 5-bit values
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	checksum := uint32(1)
+	for _, value := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ uint32(value)
+		for i, g := range generator {
+			if (top>>i)&1 == 1 {
+				checksum ^= g
+			}
+		}
+	}
+	return checksum
+}
+
+// bech32HRPExpand expands the human-readable part for the checksum
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// convertBits regroups data from fromBits- to toBits-wide values
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc, bits uint
+	maxValue := uint(1)<<toBits - 1
+	var out []byte
+	for _, value := range data {
+		if uint(value)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data value")
+		}
+		acc = acc<<fromBits | uint(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return out, nil
+}
+
+// bech32Encode encodes data with the lower-case human-readable part
+func bech32Encode(hrp string, data []byte) (string, error) {
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	polymod := bech32Polymod(append(append(bech32HRPExpand(hrp), values...), 0, 0, 0, 0, 0, 0)) ^ 1
+	var b strings.Builder
+	b.WriteString(hrp + "1")
+	for _, value := range values {
+		b.WriteByte(bech32Charset[value])
+	}
+	for i := 0; i < 6; i++ {
+		b.WriteByte(bech32Charset[polymod>>(5*(5-i))&31])
+	}
+	return b.String(), nil
+}
+
+// bech32Decode decodes a bech32 string into its lower-case human-readable part and data
+func bech32Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, fmt.Errorf("mixed case")
+	}
+	s = strings.ToLower(s)
+	separator := strings.LastIndexByte(s, '1')
+	if separator < 1 || separator+7 > len(s) {
+		return "", nil, fmt.Errorf("missing separator or checksum")
+	}
+	hrp := s[:separator]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("invalid character in prefix")
+		}
+	}
+	values := make([]byte, 0, len(s)-separator-1)
+	for i := separator + 1; i < len(s); i++ {
+		value := strings.IndexByte(bech32Charset, s[i])
+		if value < 0 {
+			return "", nil, fmt.Errorf("invalid character %q", s[i])
+		}
+		values = append(values, byte(value))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, fmt.Errorf("invalid checksum")
+	}
+	data, err := convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
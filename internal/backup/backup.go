@@ -0,0 +1,182 @@
+package backup
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// FormatVersion is the current encrypted backup format version
+// Version 2 replaced the NaCl anonymous box with age
+const FormatVersion = 2
+
+// Encryption schemes of backups; version 1 backups used the NaCl box and can still be restored
+const (
+	Algorithm        = "age"
+	AlgorithmNaClBox = "nacl-box-anonymous"
+)
+
+// EncryptedBackup is the on-disk envelope for an encrypted secret backup
+// Only secret names are visible; values are inside the ciphertext, which is a base64-encoded age file
+// for the recipients
+type EncryptedBackup struct {
+	Version     int       `json:"version"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	Namespace   string    `json:"namespace"`
+	Algorithm   string    `json:"algorithm"`
+	Recipient   string    `json:"recipient,omitempty"`
+	Recipients  []string  `json:"recipients,omitempty"`
+	Secrets     []string  `json:"secrets"`
+	Ciphertext  string    `json:"ciphertext"`
+}
+
+// Payload is the plaintext content of a backup
+type Payload struct {
+	Secrets []SecretData `json:"secrets"`
+}
+
+// SecretData holds everything needed to re-create a Secret
+type SecretData struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Type        string            `json:"type"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Data        map[string][]byte `json:"data"`
+}
+
+// GenerateKeyPair returns a new age X25519 recipient and identity, as age-keygen would
+func GenerateKeyPair() (recipient, identity string, err error) {
+	recipient, identity, err = generateAgeKeyPair()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	return recipient, identity, nil
+}
+
+// ParseKey decodes a base64-encoded 32-byte key of a version 1 backup
+func ParseKey(encoded string) (*[32]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("key must be a base64-encoded 32-byte value")
+	}
+	var key [32]byte
+	copy(key[:], raw)
+	return &key, nil
+}
+
+// Seal encrypts the payload for the comma-separated age recipients
+func Seal(payload *Payload, namespace, recipients string) (*EncryptedBackup, error) {
+	var names []string
+	var keys [][]byte
+	for _, recipient := range strings.Split(recipients, ",") {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" {
+			continue
+		}
+		key, err := ParseRecipient(recipient)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, recipient)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no age recipients configured")
+	}
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backup payload: %w", err)
+	}
+	defer clear(plaintext)
+
+	sealed, err := ageEncrypt(plaintext, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+
+	secrets := make([]string, 0, len(payload.Secrets))
+	for _, secret := range payload.Secrets {
+		secrets = append(secrets, secret.Name)
+	}
+
+	return &EncryptedBackup{
+		Version:     FormatVersion,
+		GeneratedAt: time.Now().UTC(),
+		Namespace:   namespace,
+		Algorithm:   Algorithm,
+		Recipients:  names,
+		Secrets:     secrets,
+		Ciphertext:  base64.StdEncoding.EncodeToString(sealed),
+	}, nil
+}
+
+// Open decrypts a backup with the private key file: age identities, or for a version 1 backup
+// the base64 private key
+func Open(backup *EncryptedBackup, privateKey string) (*Payload, error) {
+	sealed, err := base64.StdEncoding.DecodeString(backup.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext encoding: %w", err)
+	}
+
+	var plaintext []byte
+	switch backup.Algorithm {
+	case Algorithm:
+		identities, err := ParseIdentities(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %w", err)
+		}
+		if plaintext, err = ageDecrypt(sealed, identities); err != nil {
+			return nil, fmt.Errorf("failed to decrypt backup: %w", err)
+		}
+	case AlgorithmNaClBox:
+		if plaintext, err = openNaClBox(backup, sealed, privateKey); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported backup algorithm %q", backup.Algorithm)
+	}
+	defer clear(plaintext)
+
+	var payload Payload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse backup payload: %w", err)
+	}
+	return &payload, nil
+}
+
+// openNaClBox decrypts the ciphertext of a version 1 backup
+func openNaClBox(backup *EncryptedBackup, sealed []byte, privateKey string) ([]byte, error) {
+	recipientKey, err := ParseKey(backup.Recipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient in backup: %w", err)
+	}
+	key, err := ParseKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	plaintext, ok := box.OpenAnonymous(nil, sealed, recipientKey, key)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt backup - wrong private key or corrupted file")
+	}
+	return plaintext, nil
+}
+
+// Load reads an encrypted backup from a file
+func Load(path string) (*EncryptedBackup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	var backup EncryptedBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("failed to parse backup: %w", err)
+	}
+	return &backup, nil
+}
@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestBech32(t *testing.T) {
+	// Valid strings from BIP 173
+	for _, valid := range []string{"A12UEL5L", "abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw"} {
+		if _, _, err := bech32Decode(valid); err != nil {
+			t.Errorf("bech32Decode(%q) = %v", valid, err)
+		}
+	}
+	if _, _, err := bech32Decode("abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxx"); err == nil {
+		t.Error("bech32Decode accepted a bad checksum")
+	}
+}
+
+func TestKeyPair(t *testing.T) {
+	recipient, identity, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(recipient, "age1") || len(recipient) != 62 {
+		t.Errorf("recipient %q is not an age X25519 recipient", recipient)
+	}
+	if !strings.HasPrefix(identity, "AGE-SECRET-KEY-1") || len(identity) != 74 {
+		t.Errorf("identity is not an age X25519 identity")
+	}
+}
+
+func TestAgeTestkitKey(t *testing.T) {
+	// The X25519 key of the age test suite, whose scalar is 32 bytes of 0x42
+	identity, err := ParseIdentity("AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(identity, bytes.Repeat([]byte{0x42}, 32)) {
+		t.Errorf("identity decoded to %x", identity)
+	}
+	public, err := curve25519.X25519(identity, curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := bech32Encode(ageRecipientHRP, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"; recipient != want {
+		t.Errorf("recipient = %s, want %s", recipient, want)
+	}
+}
+
+func TestAgeRoundTrip(t *testing.T) {
+	recipient, identity, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, otherIdentity, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, strangerIdentity, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients := make([][]byte, 0, 2)
+	for _, r := range []string{recipient, other} {
+		key, err := ParseRecipient(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recipients = append(recipients, key)
+	}
+
+	// Empty, single-chunk, exactly one chunk, and multi-chunk payloads
+	for _, size := range []int{0, 100, ageChunkSize, 2*ageChunkSize + 7} {
+		plaintext := bytes.Repeat([]byte{'x'}, size)
+		file, err := ageEncrypt(plaintext, recipients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []string{identity, "# comment\n" + otherIdentity} {
+			identities, err := ParseIdentities(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ageDecrypt(file, identities)
+			if err != nil {
+				t.Fatalf("size %d: %v", size, err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("size %d: decrypted %d bytes that differ from the plaintext", size, len(got))
+			}
+		}
+
+		identities, _ := ParseIdentities(strangerIdentity)
+		if _, err := ageDecrypt(file, identities); err == nil {
+			t.Errorf("size %d: decrypted with an identity that is not a recipient", size)
+		}
+		file[len(file)-1] ^= 1
+		identities, _ = ParseIdentities(identity)
+		if _, err := ageDecrypt(file, identities); err == nil {
+			t.Errorf("size %d: decrypted a tampered payload", size)
+		}
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	recipient, identity, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := &Payload{Secrets: []SecretData{{Name: "db", Namespace: "apps", Data: map[string][]byte{"password": []byte("hunter2")}}}}
+	sealed, err := Seal(payload, "apps", recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed.Ciphertext, "hunter2") || sealed.Algorithm != Algorithm {
+		t.Fatalf("unexpected backup %+v", sealed)
+	}
+	opened, err := Open(sealed, identity+"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(opened.Secrets[0].Data["password"]); got != "hunter2" {
+		t.Errorf("restored password %q, want hunter2", got)
+	}
+}
@@ -0,0 +1,208 @@
+package bundle
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the current bundle format version
+// Version 2 replaced unkeyed sha256 value hashes with HMAC-SHA256 ones
+const FormatVersion = 2
+
+// SignatureAlgorithm is the algorithm used to sign bundles
+const SignatureAlgorithm = "ed25519"
+
+// HashAlgorithm prefixes the value hashes in bundles
+const HashAlgorithm = "hmac-sha256"
+
+// hashKeyLabel separates the value hash key derived from a signing key from other uses of the key
+const hashKeyLabel = "bitwarden-reader bundle value hash"
+
+// Bundle is a point-in-time record of secret metadata and sync state
+// It never contains plaintext secret values; value hashes only compare between bundles with the same HashKeyID
+type Bundle struct {
+	Version     int            `json:"version"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Generator   string         `json:"generator"`
+	Namespace   string         `json:"namespace"`
+	HashKeyID   string         `json:"hashKeyId,omitempty"`
+	Secrets     []SecretRecord `json:"secrets"`
+	Signature   *Signature     `json:"signature,omitempty"`
+}
+
+// SecretRecord holds the exported state of a single secret
+type SecretRecord struct {
+	Name      string                 `json:"name"`
+	Namespace string                 `json:"namespace"`
+	Group     string                 `json:"group,omitempty"`
+	Found     bool                   `json:"found"`
+	Keys      []KeyRecord            `json:"keys"`
+	Sync      SyncRecord             `json:"sync"`
+	CRDSpec   map[string]interface{} `json:"crdSpec,omitempty"`
+	CRDStatus map[string]interface{} `json:"crdStatus,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// KeyRecord holds a key name and the hash of its value
+type KeyRecord struct {
+	Name      string `json:"name"`
+	ValueHash string `json:"valueHash"`
+}
+
+// SyncRecord holds the sync state of a secret at export time
+type SyncRecord struct {
+	CRDFound           bool   `json:"crdFound"`
+	LastSuccessfulSync string `json:"lastSuccessfulSync,omitempty"`
+	SecretSyncTime     string `json:"secretSyncTime,omitempty"`
+	Status             string `json:"status,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+}
+
+// Signature holds a detached signature over the bundle contents
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+	Value     string `json:"value"`
+	Ephemeral bool   `json:"ephemeral,omitempty"`
+}
+
+// HashKey derives the key of the value hashes from the signing key, so bundles signed with the same key
+// can be compared while a bundle alone doesn't let low-entropy values be guessed offline
+func HashKey(signingKey ed25519.PrivateKey) []byte {
+	mac := hmac.New(sha256.New, signingKey.Seed())
+	mac.Write([]byte(hashKeyLabel))
+	return mac.Sum(nil)
+}
+
+// HashKeyID returns an identifier of a value hash key that doesn't reveal the key
+func HashKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// HashValue returns the keyed hash of a secret value used in bundles
+func HashValue(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return HashAlgorithm + ":" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// signedPayload returns the canonical bytes covered by the signature
+func signedPayload(b *Bundle) ([]byte, error) {
+	unsigned := *b
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+// Sign signs the bundle with the private key, replacing any existing signature
+func Sign(b *Bundle, key ed25519.PrivateKey, ephemeral bool) error {
+	payload, err := signedPayload(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	publicKey, ok := key.Public().(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("unexpected public key type")
+	}
+	b.Signature = &Signature{
+		Algorithm: SignatureAlgorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+		Ephemeral: ephemeral,
+	}
+	return nil
+}
+
+// Verify checks the bundle signature against its embedded public key, or against
+// trustedKey when one is provided
+func Verify(b *Bundle, trustedKey ed25519.PublicKey) error {
+	if b.Signature == nil {
+		return fmt.Errorf("bundle is not signed")
+	}
+	if b.Signature.Algorithm != SignatureAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm %q", b.Signature.Algorithm)
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(b.Signature.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key in signature")
+	}
+	if trustedKey != nil && !ed25519.PublicKey(publicKey).Equal(trustedKey) {
+		return fmt.Errorf("bundle was signed by an untrusted key")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(b.Signature.Value)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	payload, err := signedPayload(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	if !ed25519.Verify(publicKey, payload, signature) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// LoadSigningKey reads a base64-encoded ed25519 seed or private key from path
+// When path is empty an ephemeral key is generated and ephemeral is true
+func LoadSigningKey(path string) (key ed25519.PrivateKey, ephemeral bool, err error) {
+	if path == "" {
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		return key, true, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, false, fmt.Errorf("signing key is not valid base64: %w", err)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), false, nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), false, nil
+	default:
+		return nil, false, fmt.Errorf("signing key must be a %d-byte seed or %d-byte private key", ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+}
+
+// ParsePublicKey decodes a base64-encoded ed25519 public key
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be a base64-encoded %d-byte ed25519 key", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Load reads a bundle from a JSON file
+func Load(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle %s: %w", path, err)
+	}
+	return &b, nil
+}
@@ -0,0 +1,134 @@
+package bundle
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Change kinds reported by Diff
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// Change describes a single difference between two bundles
+type Change struct {
+	Secret string `json:"secret"`
+	Kind   string `json:"kind"`
+	Field  string `json:"field,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// String formats the change for terminal output
+func (c Change) String() string {
+	switch {
+	case c.Field == "":
+		return fmt.Sprintf("%-8s %s", c.Kind, c.Secret)
+	case c.Old == "" && c.New == "":
+		return fmt.Sprintf("%-8s %s: %s", c.Kind, c.Secret, c.Field)
+	default:
+		return fmt.Sprintf("%-8s %s: %s %q -> %q", c.Kind, c.Secret, c.Field, c.Old, c.New)
+	}
+}
+
+// recordKey identifies a secret across bundles
+func recordKey(r SecretRecord) string {
+	return r.Namespace + "/" + r.Name
+}
+
+// Diff compares two bundles and returns the differences, ordered by secret
+// Values are only compared when both bundles hash them with the same key
+func Diff(oldBundle, newBundle *Bundle) []Change {
+	compareValues := HashKeysMatch(oldBundle, newBundle)
+	oldRecords := make(map[string]SecretRecord)
+	for _, r := range oldBundle.Secrets {
+		oldRecords[recordKey(r)] = r
+	}
+	newRecords := make(map[string]SecretRecord)
+	for _, r := range newBundle.Secrets {
+		newRecords[recordKey(r)] = r
+	}
+
+	names := make(map[string]bool)
+	for name := range oldRecords {
+		names[name] = true
+	}
+	for name := range newRecords {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, name := range sorted {
+		oldRecord, inOld := oldRecords[name]
+		newRecord, inNew := newRecords[name]
+		switch {
+		case !inOld:
+			changes = append(changes, Change{Secret: name, Kind: ChangeAdded})
+		case !inNew:
+			changes = append(changes, Change{Secret: name, Kind: ChangeRemoved})
+		default:
+			changes = append(changes, diffRecords(name, oldRecord, newRecord, compareValues)...)
+		}
+	}
+	return changes
+}
+
+// HashKeysMatch reports whether the value hashes of the bundles were made with the same key
+func HashKeysMatch(oldBundle, newBundle *Bundle) bool {
+	return oldBundle.HashKeyID != "" && oldBundle.HashKeyID == newBundle.HashKeyID
+}
+
+// diffRecords compares two versions of the same secret
+func diffRecords(name string, oldRecord, newRecord SecretRecord, compareValues bool) []Change {
+	var changes []Change
+	field := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, Change{Secret: name, Kind: ChangeModified, Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	field("found", fmt.Sprint(oldRecord.Found), fmt.Sprint(newRecord.Found))
+	field("group", oldRecord.Group, newRecord.Group)
+	field("sync.crdFound", fmt.Sprint(oldRecord.Sync.CRDFound), fmt.Sprint(newRecord.Sync.CRDFound))
+	field("sync.lastSuccessfulSync", oldRecord.Sync.LastSuccessfulSync, newRecord.Sync.LastSuccessfulSync)
+	field("sync.secretSyncTime", oldRecord.Sync.SecretSyncTime, newRecord.Sync.SecretSyncTime)
+	field("sync.status", oldRecord.Sync.Status, newRecord.Sync.Status)
+	field("sync.reason", oldRecord.Sync.Reason, newRecord.Sync.Reason)
+	field("error", oldRecord.Error, newRecord.Error)
+
+	oldKeys := make(map[string]string)
+	for _, key := range oldRecord.Keys {
+		oldKeys[key.Name] = key.ValueHash
+	}
+	newKeys := make(map[string]string)
+	for _, key := range newRecord.Keys {
+		newKeys[key.Name] = key.ValueHash
+	}
+	for _, key := range oldRecord.Keys {
+		newHash, ok := newKeys[key.Name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Secret: name, Kind: ChangeRemoved, Field: "keys." + key.Name})
+		case compareValues && newHash != key.ValueHash:
+			changes = append(changes, Change{Secret: name, Kind: ChangeModified, Field: "keys." + key.Name + " (value changed)"})
+		}
+	}
+	for _, key := range newRecord.Keys {
+		if _, ok := oldKeys[key.Name]; !ok {
+			changes = append(changes, Change{Secret: name, Kind: ChangeAdded, Field: "keys." + key.Name})
+		}
+	}
+
+	if !reflect.DeepEqual(oldRecord.CRDSpec, newRecord.CRDSpec) {
+		changes = append(changes, Change{Secret: name, Kind: ChangeModified, Field: "crdSpec"})
+	}
+	return changes
+}
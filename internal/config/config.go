@@ -1,34 +1,389 @@
 package config
 
 import (
-	"log"
+	"fmt"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"bitwarden-reader/internal/features"
+	"bitwarden-reader/internal/logging"
 )
 
 // Config holds all configuration for the application
+// Each field names the environment variable or flag it is set from, which Effective reports
 type Config struct {
-	Port                     int
-	PodName                  string
-	PodNamespace             string
-	SecretNames              []string
-	AppTitle                 string
-	AppVersion               string
-	DashboardRefreshInterval time.Duration
-	ShowSecretValues         bool
+	Port                     int                 `env:"PORT"`
+	PodName                  string              `env:"POD_NAME"`
+	PodNamespace             string              `env:"POD_NAMESPACE"`
+	SecretNames              []string            `env:"SECRET_NAMES"`
+	AppTitle                 string              `env:"APP_TITLE"`
+	AppVersion               string              `env:"APP_VERSION"`
+	UIEnabled                bool                `env:"UI_ENABLED"`
+	AssetsDir                string              `env:"ASSETS_DIR"`
+	Language                 string              `env:"LANG"`
+	LocalesDir               string              `env:"LOCALES_DIR"`
+	UIColorScheme            string              `env:"UI_COLOR_SCHEME"`
+	UIAccentColor            string              `env:"UI_ACCENT_COLOR"`
+	UILogoURL                string              `env:"UI_LOGO_URL"`
+	UIBannerText             string              `env:"UI_BANNER_TEXT"`
+	UIBannerColor            string              `env:"UI_BANNER_COLOR"`
+	DashboardRefreshInterval time.Duration       `env:"DASHBOARD_REFRESH_INTERVAL"`
+	ShowSecretValues         bool                `env:"SHOW_SECRET_VALUES"`
+	ValueAutoHide            time.Duration       `env:"VALUE_AUTO_HIDE_SECONDS"`
+	LongPollTimeout          time.Duration       `env:"LONG_POLL_TIMEOUT"`
+	SecretGroups             map[string]string   `env:"SECRET_GROUPS"`
+	KeyVisibility            map[string][]string `env:"KEY_VISIBILITY"`
+	WeakSecretDetectors      []string            `env:"WEAK_SECRET_DETECTORS"`
+	WeakSecretKeys           []string            `env:"WEAK_SECRET_KEYS"`
+	AllowedNamespaces        []string            `env:"ALLOWED_NAMESPACES"`
+	WriteEnabled             bool                `env:"WRITE_ENABLED"`
+	ReadOnly                 bool                `env:"READ_ONLY"`
+	RequireKubernetes        bool                `env:"REQUIRE_KUBERNETES"`
+	AuditLogFile             string              `env:"AUDIT_LOG_FILE"`
+	AccessLogEnabled         bool                `env:"ACCESS_LOG_ENABLED"`
+	ExportSigningKeyFile     string              `env:"EXPORT_SIGNING_KEY_FILE"`
+	EncryptedExportEnabled   bool                `env:"ENCRYPTED_EXPORT_ENABLED"`
+	ExportRecipientKey       string              `env:"EXPORT_RECIPIENT_PUBLIC_KEY"`
+	PersistenceEncryption    string              `env:"PERSISTENCE_ENCRYPTION"`
+	PersistenceKeyFile       string              `env:"PERSISTENCE_KEY_FILE"`
+	DataKeyRotation          time.Duration       `env:"PERSISTENCE_DATA_KEY_ROTATION_HOURS"`
+	PersistenceQueueSize     int                 `env:"PERSISTENCE_QUEUE_SIZE"`
+	PersistenceFsync         string              `env:"PERSISTENCE_FSYNC"`
+	PersistenceFsyncInterval time.Duration       `env:"PERSISTENCE_FSYNC_INTERVAL_MS"`
+	MemoryHygiene            bool                `env:"MEMORY_HYGIENE"`
+	MaxSecretValueBytes      int                 `env:"MAX_SECRET_VALUE_BYTES"`
+	MaxSecretBytes           int                 `env:"MAX_SECRET_BYTES"`
+	MaxSnapshotBytes         int                 `env:"MAX_SNAPSHOT_BYTES"`
+	MaxRequestBodyBytes      int64               `env:"MAX_REQUEST_BODY_BYTES"`
+	APIV1Deprecation         time.Time           `env:"API_V1_DEPRECATION"`
+	APIV1Sunset              time.Time           `env:"API_V1_SUNSET"`
+	FeatureFlags             map[string]bool     `env:"FEATURE_FLAGS"`
+	ChaosEnabled             bool                `env:"CHAOS_ENABLED"`
+	GitOpsManifests          []string            `env:"GITOPS_MANIFESTS"`
+	GitOpsSOPSKeyFile        string              `env:"GITOPS_SOPS_AGE_KEY_FILE"`
+	RequiredSecrets          []string            `env:"REQUIRED_SECRETS"`
+	StatuszDegradedSyncAge   time.Duration       `env:"STATUSZ_DEGRADED_SYNC_AGE_MINUTES"`
+	StatuszFailingSyncAge    time.Duration       `env:"STATUSZ_FAILING_SYNC_AGE_MINUTES"`
+	StatuszFailingUnhealthy  int                 `env:"STATUSZ_FAILING_UNHEALTHY_PERCENT"`
+	StatuszFailOn            string              `env:"STATUSZ_FAIL_ON"`
+	AgentConfigFile          string              `env:"AGENT_CONFIG_FILE"`
+	TemplatesDir             string              `env:"TEMPLATES_DIR"`
+	PluginCommands           []string            `env:"PLUGIN_COMMANDS"`
+	PluginTimeout            time.Duration       `env:"PLUGIN_TIMEOUT"`
+	OnChangeExec             string              `env:"ON_CHANGE_EXEC"`
+	OnChangeExecTimeout      time.Duration       `env:"ON_CHANGE_EXEC_TIMEOUT"`
+	NotifyConfigFile         string              `env:"NOTIFY_CONFIG_FILE"`
+	AlertRulesFile           string              `env:"ALERT_RULES_FILE"`
+	FlapThreshold            int                 `env:"FLAP_THRESHOLD"`
+	FlapWindow               time.Duration       `env:"FLAP_WINDOW_MINUTES"`
+	IdentityNamespaces       map[string][]string `env:"IDENTITY_NAMESPACES"`
+	WSMaxConnections         int                 `env:"WS_MAX_CONNECTIONS"`
+	WSMaxConnsPerClient      int                 `env:"WS_MAX_CONNECTIONS_PER_CLIENT"`
+	WSCompression            bool                `env:"WS_COMPRESSION"`
+	WSAllowedOrigins         []string            `env:"WS_ALLOWED_ORIGINS"`
+	WSCompressionLevel       int                 `env:"WS_COMPRESSION_LEVEL"`
+	WSHeartbeatInterval      time.Duration       `env:"WS_HEARTBEAT_INTERVAL"`
+	WSResumeWindow           time.Duration       `env:"WS_RESUME_WINDOW_SECONDS"`
+	WSResumeBuffer           int                 `env:"WS_RESUME_BUFFER"`
+	WSAckTimeout             time.Duration       `env:"WS_ACK_TIMEOUT_SECONDS"`
+	HubWatchdogInterval      time.Duration       `env:"HUB_WATCHDOG_INTERVAL"`
+	HubAutoRestart           bool                `env:"HUB_AUTO_RESTART"`
+	SoakSampleInterval       time.Duration       `env:"SOAK_SAMPLE_INTERVAL_SECONDS"`
+	SoakSamples              int                 `env:"SOAK_SAMPLES"`
+	SoakGrowthSamples        int                 `env:"SOAK_GROWTH_SAMPLES"`
+	RefreshScheduleFile      string              `env:"REFRESH_SCHEDULE_FILE"`
+	HistoryFile              string              `env:"HISTORY_FILE"`
+	TriggerVerifyTimeout     time.Duration       `env:"TRIGGER_VERIFY_TIMEOUT"`
+	TriggerAnnotationKeys    []string            `env:"TRIGGER_ANNOTATION_KEYS"`
+	TriggerAnnotations       map[string]string   `env:"TRIGGER_ANNOTATIONS"`
+	TriggerConfirmAbove      int                 `env:"TRIGGER_CONFIRM_ABOVE"`
+	TriggerMaxBatch          int                 `env:"TRIGGER_MAX_BATCH_SIZE"`
+	TriggerJitter            time.Duration       `env:"TRIGGER_JITTER_MS"`
+	TriggerDedupWindow       time.Duration       `env:"TRIGGER_DEDUP_WINDOW_SECONDS"`
+	OperatorNamespace        string              `env:"OPERATOR_NAMESPACE"`
+	OperatorDeployment       string              `env:"OPERATOR_DEPLOYMENT"`
+	ForceSyncMinVersion      string              `env:"OPERATOR_FORCE_SYNC_MIN_VERSION"`
+	SyncSampleInterval       time.Duration       `env:"SYNC_SAMPLE_INTERVAL"`
+	SLAMaxSyncAge            time.Duration       `env:"SLA_MAX_SYNC_AGE_MINUTES"`
+	SyncSkewTolerance        time.Duration       `env:"SYNC_CONSISTENCY_TOLERANCE_SECONDS"`
+	SyncTimeAnnotations      []string            `env:"SYNC_TIME_ANNOTATIONS"`
+	MetadataAnnotations      []string            `env:"METADATA_ANNOTATIONS"`
+	AuthTokenExpiryWarning   time.Duration       `env:"AUTH_TOKEN_EXPIRY_WARNING_DAYS"`
+	HistoryRetention         time.Duration       `env:"HISTORY_RETENTION_DAYS"`
+	AuditRetention           time.Duration       `env:"AUDIT_RETENTION_DAYS"`
+	AuditSinksFile           string              `env:"AUDIT_SINKS_FILE"`
+	CompactionInterval       time.Duration       `env:"COMPACTION_INTERVAL_MINUTES"`
+	Impersonation            bool                `env:"IMPERSONATION_ENABLED"`
+	ImpersonationUsers       map[string]string   `env:"IMPERSONATION_USERS"`
+	ImpersonationUserPrefix  string              `env:"IMPERSONATION_USER_PREFIX"`
+	ImpersonationGroups      map[string][]string `env:"IMPERSONATION_GROUPS"`
+	SessionIdleTimeout       time.Duration       `env:"SESSION_IDLE_TIMEOUT_MINUTES"`
+	SessionMaxAge            time.Duration       `env:"SESSION_MAX_AGE_HOURS"`
+	SessionRedisURL          string              `env:"SESSION_REDIS_URL"`
+	SessionRedisPasswordFile string              `env:"SESSION_REDIS_PASSWORD_FILE"`
+	AuthMethods              []string            `env:"AUTH_METHODS"`
+	AuthTokensFile           string              `env:"AUTH_TOKENS_FILE"`
+	AuthBasicFile            string              `env:"AUTH_BASIC_FILE"`
+	AuthBasicMaxFailures     int                 `env:"AUTH_BASIC_MAX_FAILURES"`
+	AuthBasicLockout         time.Duration       `env:"AUTH_BASIC_LOCKOUT_MINUTES"`
+	AuthOIDCIssuer           string              `env:"AUTH_OIDC_ISSUER"`
+	AuthOIDCClientID         string              `env:"AUTH_OIDC_CLIENT_ID"`
+	AuthOIDCIdentityClaim    string              `env:"AUTH_OIDC_IDENTITY_CLAIM"`
+	AuthMTLSHeader           string              `env:"AUTH_MTLS_HEADER"`
+	TrustedProxies           []string            `env:"TRUSTED_PROXIES"`
+	IPAllow                  []string            `env:"IP_ALLOW"`
+	IPDeny                   []string            `env:"IP_DENY"`
+	AdminIPAllow             []string            `env:"ADMIN_IP_ALLOW"`
+	AdminIPDeny              []string            `env:"ADMIN_IP_DENY"`
+	AdminIdentities          []string            `env:"ADMIN_IDENTITIES"`
+	TokenRequestExpiration   time.Duration       `env:"TOKEN_REQUEST_EXPIRATION"`
+	TokenRequestAudiences    []string            `env:"TOKEN_REQUEST_AUDIENCES"`
+	NamespaceCredentials     map[string]string   `env:"NAMESPACE_CREDENTIALS"`
+	DiscoveryCacheTTL        time.Duration       `env:"DISCOVERY_CACHE_TTL_SECONDS"`
+	KubeProtobuf             bool                `env:"KUBE_PROTOBUF"`
+	KubeClientQPS            int                 `env:"KUBE_CLIENT_QPS"`
+	KubeClientBurst          int                 `env:"KUBE_CLIENT_BURST"`
+	KubeThrottleWarning      time.Duration       `env:"KUBE_THROTTLE_WARNING_MS"`
+	KubeRecordFile           string              `env:"KUBE_RECORD_FILE"`
+	KubeReplayFile           string              `env:"KUBE_REPLAY_FILE"`
+	WatchStrategy            string              `env:"WATCH_STRATEGY"`
+	WatchLabelSelector       string              `env:"WATCH_LABEL_SELECTOR"`
+	FileSourceDir            string              `env:"FILE_SOURCE_DIR"`
+	VaultAddr                string              `env:"VAULT_ADDR"`
+	VaultTokenFile           string              `env:"VAULT_TOKEN_FILE"`
+	VaultKVMount             string              `env:"VAULT_KV_MOUNT"`
+	VaultPathPrefix          string              `env:"VAULT_PATH_PREFIX"`
+	VaultPaths               map[string]string   `env:"VAULT_PATH_MAP"`
+	VaultAuthMethod          string              `env:"VAULT_AUTH_METHOD"`
+	VaultAuthMount           string              `env:"VAULT_AUTH_MOUNT"`
+	VaultAuthRole            string              `env:"VAULT_AUTH_ROLE"`
+	VaultRoleID              string              `env:"VAULT_ROLE_ID" redact:"true"`
+	VaultSecretIDFile        string              `env:"VAULT_SECRET_ID_FILE"`
+	AWSSecretsRegion         string              `env:"AWS_SECRETS_MANAGER_REGION"`
+	AWSSecretsPrefix         string              `env:"AWS_SECRETS_MANAGER_PREFIX"`
+	AWSSecretsEndpoint       string              `env:"AWS_SECRETS_MANAGER_ENDPOINT"`
+	LocalSecretsDir          string              `env:"LOCAL_SECRETS_DIR"`
+	LocalSecretsPoll         time.Duration       `env:"LOCAL_SECRETS_POLL_MS"`
+	MQTTBrokerURL            string              `env:"MQTT_BROKER_URL"`
+	MQTTTopicTemplate        string              `env:"MQTT_TOPIC_TEMPLATE"`
+	MQTTSummaryTopic         string              `env:"MQTT_SUMMARY_TOPIC"`
+	MQTTQoS                  int                 `env:"MQTT_QOS"`
+	MQTTRetain               bool                `env:"MQTT_RETAIN"`
+	MQTTUsername             string              `env:"MQTT_USERNAME"`
+	MQTTPasswordFile         string              `env:"MQTT_PASSWORD_FILE"`
+	Standalone               bool                `flag:"standalone"`
+	// Problems lists the environment values LoadConfig ignored
+	Problems                 []Problem
+}
+
+// EnvKeys lists the environment variables read by LoadConfig
+var EnvKeys = []string{
+	"PORT",
+	"POD_NAME",
+	"POD_NAMESPACE",
+	"SECRET_NAMES",
+	"APP_TITLE",
+	"APP_VERSION",
+	"UI_ENABLED",
+	"ASSETS_DIR",
+	"LANG",
+	"LOCALES_DIR",
+	"UI_COLOR_SCHEME",
+	"UI_ACCENT_COLOR",
+	"UI_LOGO_URL",
+	"UI_BANNER_TEXT",
+	"UI_BANNER_COLOR",
+	"DASHBOARD_REFRESH_INTERVAL",
+	"SHOW_SECRET_VALUES",
+	"VALUE_AUTO_HIDE_SECONDS",
+	"LONG_POLL_TIMEOUT",
+	"SECRET_GROUPS",
+	"KEY_VISIBILITY",
+	"WEAK_SECRET_DETECTORS",
+	"WEAK_SECRET_KEYS",
+	"ALLOWED_NAMESPACES",
+	"WRITE_ENABLED",
+	"READ_ONLY",
+	"REQUIRE_KUBERNETES",
+	"AUDIT_LOG_FILE",
+	"ACCESS_LOG_ENABLED",
+	"AUDIT_SINKS_FILE",
+	"EXPORT_SIGNING_KEY_FILE",
+	"ENCRYPTED_EXPORT_ENABLED",
+	"EXPORT_RECIPIENT_PUBLIC_KEY",
+	"PERSISTENCE_ENCRYPTION",
+	"PERSISTENCE_KEY_FILE",
+	"PERSISTENCE_DATA_KEY_ROTATION_HOURS",
+	"PERSISTENCE_QUEUE_SIZE",
+	"PERSISTENCE_FSYNC",
+	"PERSISTENCE_FSYNC_INTERVAL_MS",
+	"MEMORY_HYGIENE",
+	"MAX_SECRET_VALUE_BYTES",
+	"MAX_SECRET_BYTES",
+	"MAX_SNAPSHOT_BYTES",
+	"MAX_REQUEST_BODY_BYTES",
+	"API_V1_DEPRECATION",
+	"API_V1_SUNSET",
+	"FEATURE_FLAGS",
+	"CHAOS_ENABLED",
+	"GITOPS_MANIFESTS",
+	"GITOPS_SOPS_AGE_KEY_FILE",
+	"REQUIRED_SECRETS",
+	"STATUSZ_DEGRADED_SYNC_AGE_MINUTES",
+	"STATUSZ_FAILING_SYNC_AGE_MINUTES",
+	"STATUSZ_FAILING_UNHEALTHY_PERCENT",
+	"STATUSZ_FAIL_ON",
+	"AGENT_CONFIG_FILE",
+	"TEMPLATES_DIR",
+	"PLUGIN_COMMANDS",
+	"PLUGIN_TIMEOUT",
+	"ON_CHANGE_EXEC",
+	"ON_CHANGE_EXEC_TIMEOUT",
+	"NOTIFY_CONFIG_FILE",
+	"ALERT_RULES_FILE",
+	"FLAP_THRESHOLD",
+	"FLAP_WINDOW_MINUTES",
+	"IDENTITY_NAMESPACES",
+	"WS_MAX_CONNECTIONS",
+	"WS_MAX_CONNECTIONS_PER_CLIENT",
+	"WS_COMPRESSION",
+	"WS_ALLOWED_ORIGINS",
+	"WS_COMPRESSION_LEVEL",
+	"WS_HEARTBEAT_INTERVAL",
+	"WS_RESUME_WINDOW_SECONDS",
+	"WS_RESUME_BUFFER",
+	"WS_ACK_TIMEOUT_SECONDS",
+	"HUB_WATCHDOG_INTERVAL",
+	"HUB_AUTO_RESTART",
+	"SOAK_SAMPLE_INTERVAL_SECONDS",
+	"SOAK_SAMPLES",
+	"SOAK_GROWTH_SAMPLES",
+	"REFRESH_SCHEDULE_FILE",
+	"HISTORY_FILE",
+	"TRIGGER_VERIFY_TIMEOUT",
+	"TRIGGER_ANNOTATION_KEYS",
+	"TRIGGER_ANNOTATIONS",
+	"TRIGGER_CONFIRM_ABOVE",
+	"TRIGGER_MAX_BATCH_SIZE",
+	"TRIGGER_JITTER_MS",
+	"TRIGGER_DEDUP_WINDOW_SECONDS",
+	"OPERATOR_NAMESPACE",
+	"OPERATOR_DEPLOYMENT",
+	"OPERATOR_FORCE_SYNC_MIN_VERSION",
+	"SYNC_SAMPLE_INTERVAL",
+	"SLA_MAX_SYNC_AGE_MINUTES",
+	"SYNC_CONSISTENCY_TOLERANCE_SECONDS",
+	"SYNC_TIME_ANNOTATIONS",
+	"METADATA_ANNOTATIONS",
+	"AUTH_TOKEN_EXPIRY_WARNING_DAYS",
+	"HISTORY_RETENTION_DAYS",
+	"AUDIT_RETENTION_DAYS",
+	"COMPACTION_INTERVAL_MINUTES",
+	"IMPERSONATION_ENABLED",
+	"IMPERSONATION_USERS",
+	"IMPERSONATION_USER_PREFIX",
+	"IMPERSONATION_GROUPS",
+	"SESSION_IDLE_TIMEOUT_MINUTES",
+	"SESSION_MAX_AGE_HOURS",
+	"SESSION_REDIS_URL",
+	"SESSION_REDIS_PASSWORD_FILE",
+	"AUTH_METHODS",
+	"AUTH_TOKENS_FILE",
+	"AUTH_BASIC_FILE",
+	"AUTH_BASIC_MAX_FAILURES",
+	"AUTH_BASIC_LOCKOUT_MINUTES",
+	"AUTH_OIDC_ISSUER",
+	"AUTH_OIDC_CLIENT_ID",
+	"AUTH_OIDC_IDENTITY_CLAIM",
+	"AUTH_MTLS_HEADER",
+	"TRUSTED_PROXIES",
+	"IP_ALLOW",
+	"IP_DENY",
+	"ADMIN_IP_ALLOW",
+	"ADMIN_IP_DENY",
+	"ADMIN_IDENTITIES",
+	"TOKEN_REQUEST_EXPIRATION",
+	"TOKEN_REQUEST_AUDIENCES",
+	"NAMESPACE_CREDENTIALS",
+	"DISCOVERY_CACHE_TTL_SECONDS",
+	"KUBE_PROTOBUF",
+	"KUBE_CLIENT_QPS",
+	"KUBE_CLIENT_BURST",
+	"KUBE_THROTTLE_WARNING_MS",
+	"KUBE_RECORD_FILE",
+	"KUBE_REPLAY_FILE",
+	"WATCH_STRATEGY",
+	"WATCH_LABEL_SELECTOR",
+	"FILE_SOURCE_DIR",
+	"VAULT_ADDR",
+	"VAULT_TOKEN_FILE",
+	"VAULT_KV_MOUNT",
+	"VAULT_PATH_PREFIX",
+	"VAULT_PATH_MAP",
+	"VAULT_AUTH_METHOD",
+	"VAULT_AUTH_MOUNT",
+	"VAULT_AUTH_ROLE",
+	"VAULT_ROLE_ID",
+	"VAULT_SECRET_ID_FILE",
+	"AWS_SECRETS_MANAGER_REGION",
+	"AWS_SECRETS_MANAGER_PREFIX",
+	"AWS_SECRETS_MANAGER_ENDPOINT",
+	"LOCAL_SECRETS_DIR",
+	"LOCAL_SECRETS_POLL_MS",
+	"MQTT_BROKER_URL",
+	"MQTT_TOPIC_TEMPLATE",
+	"MQTT_SUMMARY_TOPIC",
+	"MQTT_QOS",
+	"MQTT_RETAIN",
+	"MQTT_USERNAME",
+	"MQTT_PASSWORD_FILE",
 }
 
+// loadProblems collects the values ignored by the LoadConfig call holding loadMu
+var (
+	loadMu       sync.Mutex
+	loadProblems []Problem
+)
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	loadProblems = nil
+
 	cfg := &Config{
 		Port:         getEnvAsInt("PORT", 8080),
 		PodName:      getEnv("POD_NAME", ""),
 		PodNamespace: getEnv("POD_NAMESPACE", ""),
 		AppTitle:     getEnv("APP_TITLE", "Bitwarden Secrets Reader"),
 		AppVersion:   getEnv("APP_VERSION", "1.0.0"),
+		UIEnabled:    getEnvAsBool("UI_ENABLED", true),
+		AssetsDir:    getEnv("ASSETS_DIR", "web/dist"),
+		Language:     getEnv("LANG", "en"),
+		LocalesDir:   getEnv("LOCALES_DIR", ""),
+		UILogoURL:    getEnv("UI_LOGO_URL", ""),
+		UIBannerText: getEnv("UI_BANNER_TEXT", ""),
 		ShowSecretValues: getEnvAsBool("SHOW_SECRET_VALUES", false),
+		WriteEnabled:     getEnvAsBool("WRITE_ENABLED", false),
+		ReadOnly:         getEnvAsBool("READ_ONLY", false),
+		RequireKubernetes: getEnvAsBool("REQUIRE_KUBERNETES", false),
+		AuditLogFile:     getEnv("AUDIT_LOG_FILE", ""),
+		AccessLogEnabled: getEnvAsBool("ACCESS_LOG_ENABLED", true),
+		AuditSinksFile:   getEnv("AUDIT_SINKS_FILE", ""),
+		ExportSigningKeyFile: getEnv("EXPORT_SIGNING_KEY_FILE", ""),
+		EncryptedExportEnabled: getEnvAsBool("ENCRYPTED_EXPORT_ENABLED", false),
+		ExportRecipientKey:     getEnv("EXPORT_RECIPIENT_PUBLIC_KEY", ""),
+		PersistenceEncryption:  getEnv("PERSISTENCE_ENCRYPTION", "none"),
+		PersistenceKeyFile:     getEnv("PERSISTENCE_KEY_FILE", ""),
+		MemoryHygiene:          getEnvAsBool("MEMORY_HYGIENE", false),
+		GitOpsSOPSKeyFile:      getEnv("GITOPS_SOPS_AGE_KEY_FILE", ""),
+		AgentConfigFile:        getEnv("AGENT_CONFIG_FILE", ""),
+		TemplatesDir:           getEnv("TEMPLATES_DIR", ""),
+		OnChangeExec:           getEnv("ON_CHANGE_EXEC", ""),
 	}
 
 	// Parse secret names from comma-separated list
@@ -41,14 +396,470 @@ func LoadConfig() *Config {
 		}
 	}
 
+	// Parse allowed namespaces ("*" allows every namespace the service account can see)
+	cfg.AllowedNamespaces = splitList(getEnv("ALLOWED_NAMESPACES", ""))
+	if len(cfg.AllowedNamespaces) == 0 && cfg.PodNamespace != "" {
+		cfg.AllowedNamespaces = []string{cfg.PodNamespace}
+	}
+
+	// Parse secrets that must exist and be synced before the reader reports ready
+	cfg.RequiredSecrets = splitList(getEnv("REQUIRED_SECRETS", ""))
+
+	// /statusz is DEGRADED when a secret is unhealthy or the oldest sync is older than the degraded age,
+	// and FAILING from the failing age or this share of unhealthy secrets (minutes and percent, 0 disables);
+	// it answers 503 from STATUSZ_FAIL_ON up
+	statuszDegradedSyncAge := getEnvAsInt("STATUSZ_DEGRADED_SYNC_AGE_MINUTES", 60)
+	cfg.StatuszDegradedSyncAge = time.Duration(statuszDegradedSyncAge) * time.Minute
+	statuszFailingSyncAge := getEnvAsInt("STATUSZ_FAILING_SYNC_AGE_MINUTES", 240)
+	cfg.StatuszFailingSyncAge = time.Duration(statuszFailingSyncAge) * time.Minute
+	cfg.StatuszFailingUnhealthy = getEnvAsInt("STATUSZ_FAILING_UNHEALTHY_PERCENT", 50)
+	if cfg.StatuszFailingUnhealthy < 0 || cfg.StatuszFailingUnhealthy > 100 {
+		ignoreValue("STATUSZ_FAILING_UNHEALTHY_PERCENT", "invalid STATUSZ_FAILING_UNHEALTHY_PERCENT %d, using 50", cfg.StatuszFailingUnhealthy)
+		cfg.StatuszFailingUnhealthy = 50
+	}
+	cfg.StatuszFailOn = strings.ToLower(getEnv("STATUSZ_FAIL_ON", "failing"))
+	if cfg.StatuszFailOn != "failing" && cfg.StatuszFailOn != "degraded" {
+		ignoreValue("STATUSZ_FAIL_ON", "invalid STATUSZ_FAIL_ON %q, using failing", cfg.StatuszFailOn)
+		cfg.StatuszFailOn = "failing"
+	}
+
+	// Parse GitOps manifest sources (file paths or raw URLs)
+	cfg.GitOpsManifests = splitList(getEnv("GITOPS_MANIFESTS", ""))
+
+	// Parse post-processing plugin commands and their per-secret timeout (in seconds)
+	cfg.PluginCommands = splitList(getEnv("PLUGIN_COMMANDS", ""))
+	pluginTimeout := getEnvAsInt("PLUGIN_TIMEOUT", 5)
+	cfg.PluginTimeout = time.Duration(pluginTimeout) * time.Second
+
+	// Parse the change hook timeout (in seconds)
+	onChangeExecTimeout := getEnvAsInt("ON_CHANGE_EXEC_TIMEOUT", 30)
+	cfg.OnChangeExecTimeout = time.Duration(onChangeExecTimeout) * time.Second
+
+	// YAML routing of secret events to Slack, webhook, PagerDuty, and Opsgenie channels
+	cfg.NotifyConfigFile = getEnv("NOTIFY_CONFIG_FILE", "")
+	cfg.AlertRulesFile = getEnv("ALERT_RULES_FILE", "")
+
+	// A secret flaps after FLAP_THRESHOLD health transitions within the window (0 disables)
+	cfg.FlapThreshold = getEnvAsInt("FLAP_THRESHOLD", 4)
+	flapWindow := getEnvAsInt("FLAP_WINDOW_MINUTES", 15)
+	cfg.FlapWindow = time.Duration(flapWindow) * time.Minute
+
+	// Parse per-identity namespace access from "alice=apps,web;bob=*"
+	cfg.IdentityNamespaces = parseIdentityLists("IDENTITY_NAMESPACES", getEnv("IDENTITY_NAMESPACES", ""))
+
+	// Impersonate the request identity on Kubernetes API calls, mapped via "alice=alice@example.com"
+	// or prefixed, with groups from "alice=platform,devs"
+	cfg.Impersonation = getEnvAsBool("IMPERSONATION_ENABLED", false)
+	cfg.ImpersonationUsers = parseKeyValues("IMPERSONATION_USERS", getEnv("IMPERSONATION_USERS", ""))
+	cfg.ImpersonationUserPrefix = getEnv("IMPERSONATION_USER_PREFIX", "")
+	cfg.ImpersonationGroups = parseIdentityLists("IMPERSONATION_GROUPS", getEnv("IMPERSONATION_GROUPS", ""))
+
+	// Browser sessions end after the idle timeout (in minutes) or the maximum age (in hours), whichever comes first
+	sessionIdleTimeout := getEnvAsInt("SESSION_IDLE_TIMEOUT_MINUTES", 30)
+	cfg.SessionIdleTimeout = time.Duration(sessionIdleTimeout) * time.Minute
+	sessionMaxAge := getEnvAsInt("SESSION_MAX_AGE_HOURS", 12)
+	cfg.SessionMaxAge = time.Duration(sessionMaxAge) * time.Hour
+	// Share sessions between replicas in Redis, e.g. rediss://redis:6379/0; memory only when unset
+	cfg.SessionRedisURL = getEnv("SESSION_REDIS_URL", "")
+	cfg.SessionRedisPasswordFile = getEnv("SESSION_REDIS_PASSWORD_FILE", "")
+
+	// Basic auth users in htpasswd format; setting the file alone enables basic auth
+	cfg.AuthBasicFile = getEnv("AUTH_BASIC_FILE", "")
+	defaultAuthMethods := "none"
+	if cfg.AuthBasicFile != "" {
+		defaultAuthMethods = "basic"
+	}
+	// Authentication methods tried in order until one accepts the request: none, token, basic, oidc, mtls
+	cfg.AuthMethods = splitList(strings.ToLower(getEnv("AUTH_METHODS", defaultAuthMethods)))
+	// Bearer tokens as "identity:token" lines
+	cfg.AuthTokensFile = getEnv("AUTH_TOKENS_FILE", "")
+	// Lock a client out of basic auth for the lockout period after this many failed logins within it (0 disables)
+	cfg.AuthBasicMaxFailures = getEnvAsInt("AUTH_BASIC_MAX_FAILURES", 5)
+	if cfg.AuthBasicMaxFailures < 0 {
+		ignoreValue("AUTH_BASIC_MAX_FAILURES", "invalid AUTH_BASIC_MAX_FAILURES %d, using 5", cfg.AuthBasicMaxFailures)
+		cfg.AuthBasicMaxFailures = 5
+	}
+	authBasicLockout := getEnvAsInt("AUTH_BASIC_LOCKOUT_MINUTES", 15)
+	if authBasicLockout < 1 {
+		ignoreValue("AUTH_BASIC_LOCKOUT_MINUTES", "invalid AUTH_BASIC_LOCKOUT_MINUTES %d, using 15", authBasicLockout)
+		authBasicLockout = 15
+	}
+	cfg.AuthBasicLockout = time.Duration(authBasicLockout) * time.Minute
+	// OIDC ID tokens are verified against the issuer's published keys and must name the client ID as audience
+	cfg.AuthOIDCIssuer = strings.TrimSuffix(getEnv("AUTH_OIDC_ISSUER", ""), "/")
+	cfg.AuthOIDCClientID = getEnv("AUTH_OIDC_CLIENT_ID", "")
+	cfg.AuthOIDCIdentityClaim = getEnv("AUTH_OIDC_IDENTITY_CLAIM", "email")
+	// Header carrying the verified client certificate subject from a TLS-terminating ingress
+	cfg.AuthMTLSHeader = getEnv("AUTH_MTLS_HEADER", "")
+
+	// Proxies whose X-Forwarded-For is trusted for the client IP, as addresses or CIDRs; all when unset
+	cfg.TrustedProxies = splitList(getEnv("TRUSTED_PROXIES", ""))
+	// Client IPs or CIDRs allowed and denied before authentication, for all requests and additionally
+	// for the admin API; deny wins, and an empty allow list allows everyone
+	cfg.IPAllow = splitList(getEnv("IP_ALLOW", ""))
+	cfg.IPDeny = splitList(getEnv("IP_DENY", ""))
+	cfg.AdminIPAllow = splitList(getEnv("ADMIN_IP_ALLOW", ""))
+	cfg.AdminIPDeny = splitList(getEnv("ADMIN_IP_DENY", ""))
+
+	// Identities allowed to use the admin API, or * for every authenticated identity; unset closes it
+	cfg.AdminIdentities = splitList(getEnv("ADMIN_IDENTITIES", ""))
+
+	// WebSocket connection limits (0 means unlimited)
+	cfg.WSMaxConnections = getEnvAsInt("WS_MAX_CONNECTIONS", 0)
+	cfg.WSMaxConnsPerClient = getEnvAsInt("WS_MAX_CONNECTIONS_PER_CLIENT", 0)
+
+	// Negotiated permessage-deflate compression; level 1 favours speed, 9 size
+	cfg.WSCompression = getEnvAsBool("WS_COMPRESSION", true)
+	cfg.WSCompressionLevel = getEnvAsInt("WS_COMPRESSION_LEVEL", 1)
+
+	// Browser origins allowed to open WebSockets besides the dashboard's own, e.g. "https://ops.example.com"
+	cfg.WSAllowedOrigins = splitList(getEnv("WS_ALLOWED_ORIGINS", ""))
+
+	// Parse secret groups from "group=secret1,secret2;group2=secret3"
+	cfg.SecretGroups = parseSecretGroups(getEnv("SECRET_GROUPS", ""))
+	// Per-key visibility by "secret/key" glob, e.g. "visible=*/username,*/url;hidden=*/password"
+	cfg.KeyVisibility = parseIdentityLists("KEY_VISIBILITY", getEnv("KEY_VISIBILITY", ""))
+	// How long the dashboard shows revealed values before masking them again (0 keeps them shown)
+	valueAutoHide := getEnvAsInt("VALUE_AUTO_HIDE_SECONDS", 60)
+	if valueAutoHide < 0 {
+		ignoreValue("VALUE_AUTO_HIDE_SECONDS", "invalid VALUE_AUTO_HIDE_SECONDS %d, using 60", valueAutoHide)
+		valueAutoHide = 60
+	}
+	cfg.ValueAutoHide = time.Duration(valueAutoHide) * time.Second
+	// Weak value analysis: detectors to run (placeholder, common, entropy) and the credential key name globs
+	cfg.WeakSecretDetectors = splitList(getEnv("WEAK_SECRET_DETECTORS", ""))
+	cfg.WeakSecretKeys = splitList(getEnv("WEAK_SECRET_KEYS", "*pass*,*pwd*,*secret*,*token*,*key*"))
+
 	// Parse dashboard refresh interval (in seconds)
 	refreshInterval := getEnvAsInt("DASHBOARD_REFRESH_INTERVAL", 5)
 	cfg.DashboardRefreshInterval = time.Duration(refreshInterval) * time.Second
 
-	log.Printf("Config loaded: SecretNames=%v (len=%d)", cfg.SecretNames, len(cfg.SecretNames))
+	// Parse WebSocket heartbeat interval (in seconds), independent of the refresh interval
+	heartbeatInterval := getEnvAsInt("WS_HEARTBEAT_INTERVAL", 30)
+	cfg.WSHeartbeatInterval = time.Duration(heartbeatInterval) * time.Second
+
+	// Reconnecting WebSocket clients resume within this window (in seconds, 0 disables) and are sent
+	// the events they missed from a buffer of this many snapshots and events
+	resumeWindow := getEnvAsInt("WS_RESUME_WINDOW_SECONDS", 300)
+	cfg.WSResumeWindow = time.Duration(resumeWindow) * time.Second
+	cfg.WSResumeBuffer = getEnvAsInt("WS_RESUME_BUFFER", 100)
+
+	// Events a WebSocket client acknowledges are sent again when not acknowledged within this time (in seconds, 0 disables)
+	ackTimeout := getEnvAsInt("WS_ACK_TIMEOUT_SECONDS", 10)
+	cfg.WSAckTimeout = time.Duration(ackTimeout) * time.Second
+
+	// Parse WebSocket hub watchdog interval (in seconds, 0 disables) and restart policy
+	hubWatchdogInterval := getEnvAsInt("HUB_WATCHDOG_INTERVAL", 10)
+	cfg.HubWatchdogInterval = time.Duration(hubWatchdogInterval) * time.Second
+	cfg.HubAutoRestart = getEnvAsBool("HUB_AUTO_RESTART", true)
+
+	// Soak-test self-monitor: how often goroutines, heap, WebSocket clients, and queued messages are sampled
+	// (in seconds, 0 disables), how many samples are kept, and after how many consecutive rises growth is reported
+	soakSampleInterval := getEnvAsInt("SOAK_SAMPLE_INTERVAL_SECONDS", 0)
+	cfg.SoakSampleInterval = time.Duration(max(soakSampleInterval, 0)) * time.Second
+	cfg.SoakSamples = getEnvAsInt("SOAK_SAMPLES", 720)
+	if cfg.SoakSamples < 2 {
+		ignoreValue("SOAK_SAMPLES", "invalid SOAK_SAMPLES %d, using 720", cfg.SoakSamples)
+		cfg.SoakSamples = 720
+	}
+	cfg.SoakGrowthSamples = getEnvAsInt("SOAK_GROWTH_SAMPLES", 10)
+	if cfg.SoakGrowthSamples < 2 || cfg.SoakGrowthSamples > cfg.SoakSamples {
+		ignoreValue("SOAK_GROWTH_SAMPLES", "invalid SOAK_GROWTH_SAMPLES %d, use 2 to SOAK_SAMPLES, using %d", cfg.SoakGrowthSamples, min(10, cfg.SoakSamples))
+		cfg.SoakGrowthSamples = min(10, cfg.SoakSamples)
+	}
+
+	// Optional per-group/per-namespace refresh intervals overriding DASHBOARD_REFRESH_INTERVAL
+	cfg.RefreshScheduleFile = getEnv("REFRESH_SCHEDULE_FILE", "")
+
+	// History persistence and how long to wait for a triggered sync to show up (in seconds, 0 disables)
+	cfg.HistoryFile = getEnv("HISTORY_FILE", "")
+	triggerVerifyTimeout := getEnvAsInt("TRIGGER_VERIFY_TIMEOUT", 120)
+	cfg.TriggerVerifyTimeout = time.Duration(triggerVerifyTimeout) * time.Second
+
+	// Annotation keys that receive the trigger timestamp, and extra annotations such as "example.com/triggered-by={user}"
+	cfg.TriggerAnnotationKeys = splitList(getEnv("TRIGGER_ANNOTATION_KEYS", "k8s.bitwarden.com/force-sync"))
+	cfg.TriggerAnnotations = parseKeyValues("TRIGGER_ANNOTATIONS", getEnv("TRIGGER_ANNOTATIONS", ""))
+
+	// Bulk trigger safety: triggers of more secrets than TRIGGER_CONFIRM_ABOVE need a plan's confirmation token (0 disables),
+	// at most TRIGGER_MAX_BATCH_SIZE secrets per request (0 is unlimited), and a random pause between patches (in milliseconds)
+	cfg.TriggerConfirmAbove = getEnvAsInt("TRIGGER_CONFIRM_ABOVE", 5)
+	cfg.TriggerMaxBatch = getEnvAsInt("TRIGGER_MAX_BATCH_SIZE", 50)
+	triggerJitter := getEnvAsInt("TRIGGER_JITTER_MS", 500)
+	cfg.TriggerJitter = time.Duration(triggerJitter) * time.Millisecond
+
+	// Triggers of a BitwardenSecret within this window of the last one, from any replica, don't patch it again (0 disables)
+	triggerDedupWindow := getEnvAsInt("TRIGGER_DEDUP_WINDOW_SECONDS", 10)
+	cfg.TriggerDedupWindow = time.Duration(triggerDedupWindow) * time.Second
+
+	// Where the operator runs, read to tell whether it honors force-sync, and the first operator version that does
+	// (unset leaves it to what past triggers showed)
+	cfg.OperatorNamespace = getEnv("OPERATOR_NAMESPACE", "sm-operator-system")
+	cfg.OperatorDeployment = getEnv("OPERATOR_DEPLOYMENT", "sm-operator-controller-manager")
+	cfg.ForceSyncMinVersion = strings.TrimPrefix(getEnv("OPERATOR_FORCE_SYNC_MIN_VERSION", ""), "v")
+	if cfg.ForceSyncMinVersion != "" && !versionPattern.MatchString(cfg.ForceSyncMinVersion) {
+		ignoreValue("OPERATOR_FORCE_SYNC_MIN_VERSION", "invalid OPERATOR_FORCE_SYNC_MIN_VERSION %q, not checking the operator version", cfg.ForceSyncMinVersion)
+		cfg.ForceSyncMinVersion = ""
+	}
+
+	// Sync state sampling for SLA reports (in seconds, 0 disables) and the SLA's maximum sync age (in minutes)
+	syncSampleInterval := getEnvAsInt("SYNC_SAMPLE_INTERVAL", 60)
+	cfg.SyncSampleInterval = time.Duration(syncSampleInterval) * time.Second
+	slaMaxSyncAge := getEnvAsInt("SLA_MAX_SYNC_AGE_MINUTES", 60)
+	cfg.SLAMaxSyncAge = time.Duration(slaMaxSyncAge) * time.Minute
+
+	// How far a Secret's sync-time annotation may trail the BitwardenSecret's last successful sync
+	syncSkewTolerance := getEnvAsInt("SYNC_CONSISTENCY_TOLERANCE_SECONDS", 60)
+	cfg.SyncSkewTolerance = time.Duration(syncSkewTolerance) * time.Second
+
+	// Operator versions and forks record the Secret's sync time under different annotations, tried in order
+	cfg.SyncTimeAnnotations = splitList(getEnv("SYNC_TIME_ANNOTATIONS", "bitwarden-secrets-operator.io/sync-time"))
+	cfg.MetadataAnnotations = splitList(getEnv("METADATA_ANNOTATIONS", ""))
+
+	// How long before the expiry annotation an auth-token Secret is reported as expiring
+	authTokenExpiryWarning := getEnvAsInt("AUTH_TOKEN_EXPIRY_WARNING_DAYS", 7)
+	cfg.AuthTokenExpiryWarning = time.Duration(authTokenExpiryWarning) * 24 * time.Hour
+
+	// Retention of the history and audit log files (in days, 0 keeps everything) and how often they are compacted (in minutes, 0 disables)
+	historyRetention := getEnvAsInt("HISTORY_RETENTION_DAYS", 90)
+	cfg.HistoryRetention = time.Duration(historyRetention) * 24 * time.Hour
+	auditRetention := getEnvAsInt("AUDIT_RETENTION_DAYS", 0)
+	cfg.AuditRetention = time.Duration(auditRetention) * 24 * time.Hour
+	compactionInterval := getEnvAsInt("COMPACTION_INTERVAL_MINUTES", 60)
+	cfg.CompactionInterval = time.Duration(compactionInterval) * time.Minute
+
+	// Short-lived TokenRequest tokens for the dynamic client (in seconds, 0 keeps the projected token)
+	tokenRequestExpiration := getEnvAsInt("TOKEN_REQUEST_EXPIRATION", 0)
+	cfg.TokenRequestExpiration = time.Duration(tokenRequestExpiration) * time.Second
+	cfg.TokenRequestAudiences = splitList(getEnv("TOKEN_REQUEST_AUDIENCES", ""))
+
+	// Per-namespace credentials, e.g. "apps=context:apps-reader;web=token:/var/run/secrets/web/token"
+	cfg.NamespaceCredentials = parseKeyValues("NAMESPACE_CREDENTIALS", getEnv("NAMESPACE_CREDENTIALS", ""))
+
+	// How long the BitwardenSecret API discovery check is reused (in seconds, 0 checks on every CRD read)
+	discoveryCacheTTL := getEnvAsInt("DISCOVERY_CACHE_TTL_SECONDS", 300)
+	cfg.DiscoveryCacheTTL = time.Duration(discoveryCacheTTL) * time.Second
+
+	// Kubernetes client tuning: protobuf for Secret reads and the client-side rate limit
+	cfg.KubeProtobuf = getEnvAsBool("KUBE_PROTOBUF", true)
+	cfg.KubeClientQPS = getEnvAsInt("KUBE_CLIENT_QPS", 50)
+	cfg.KubeClientBurst = getEnvAsInt("KUBE_CLIENT_BURST", 100)
+	kubeThrottleWarning := getEnvAsInt("KUBE_THROTTLE_WARNING_MS", 1000)
+	cfg.KubeThrottleWarning = time.Duration(kubeThrottleWarning) * time.Millisecond
+
+	// Debug capture of Kubernetes API responses, and serving a capture instead of a cluster
+	cfg.KubeRecordFile = getEnv("KUBE_RECORD_FILE", "")
+	cfg.KubeReplayFile = getEnv("KUBE_REPLAY_FILE", "")
+
+	// How secret changes are noticed: get (polling only), field-selector, label-selector, or namespace
+	cfg.WatchStrategy = getEnv("WATCH_STRATEGY", "get")
+	cfg.WatchLabelSelector = getEnv("WATCH_LABEL_SELECTOR", "")
+
+	// Additional secret sources shown next to the Kubernetes secrets, each enabled by its location
+	cfg.FileSourceDir = getEnv("FILE_SOURCE_DIR", "")
+	cfg.VaultAddr = getEnv("VAULT_ADDR", "")
+	cfg.VaultTokenFile = getEnv("VAULT_TOKEN_FILE", "")
+	cfg.VaultKVMount = getEnv("VAULT_KV_MOUNT", "secret")
+	cfg.VaultPathPrefix = getEnv("VAULT_PATH_PREFIX", "")
+	cfg.VaultPaths = parseKeyValues("VAULT_PATH_MAP", getEnv("VAULT_PATH_MAP", ""))
+	cfg.VaultAuthMethod = getEnv("VAULT_AUTH_METHOD", "token")
+	cfg.VaultAuthMount = getEnv("VAULT_AUTH_MOUNT", "")
+	cfg.VaultAuthRole = getEnv("VAULT_AUTH_ROLE", "")
+	cfg.VaultRoleID = getEnv("VAULT_ROLE_ID", "")
+	cfg.VaultSecretIDFile = getEnv("VAULT_SECRET_ID_FILE", "")
+	cfg.AWSSecretsRegion = getEnv("AWS_SECRETS_MANAGER_REGION", "")
+	cfg.AWSSecretsPrefix = getEnv("AWS_SECRETS_MANAGER_PREFIX", "")
+	cfg.AWSSecretsEndpoint = getEnv("AWS_SECRETS_MANAGER_ENDPOINT", "")
+
+	// Directory standing in for Kubernetes in standalone mode, rescanned every LOCAL_SECRETS_POLL_MS
+	cfg.LocalSecretsDir = getEnv("LOCAL_SECRETS_DIR", "")
+	localSecretsPoll := getEnvAsInt("LOCAL_SECRETS_POLL_MS", 1000)
+	cfg.LocalSecretsPoll = time.Duration(localSecretsPoll) * time.Millisecond
+
+	// Publish secret status to an MQTT broker, e.g. mqtts://broker:8883, on topics filled in with
+	// {namespace} and {secret}; retained by default so status boards get the state when they subscribe
+	cfg.MQTTBrokerURL = getEnv("MQTT_BROKER_URL", "")
+	cfg.MQTTTopicTemplate = getEnv("MQTT_TOPIC_TEMPLATE", "bitwarden-reader/{namespace}/secrets/{secret}")
+	cfg.MQTTSummaryTopic = getEnv("MQTT_SUMMARY_TOPIC", "bitwarden-reader/{namespace}/summary")
+	cfg.MQTTQoS = getEnvAsInt("MQTT_QOS", 1)
+	if cfg.MQTTQoS < 0 || cfg.MQTTQoS > 2 {
+		ignoreValue("MQTT_QOS", "invalid MQTT_QOS %d, using 1", cfg.MQTTQoS)
+		cfg.MQTTQoS = 1
+	}
+	cfg.MQTTRetain = getEnvAsBool("MQTT_RETAIN", true)
+	cfg.MQTTUsername = getEnv("MQTT_USERNAME", "")
+	cfg.MQTTPasswordFile = getEnv("MQTT_PASSWORD_FILE", "")
+
+	// Parse data key rotation period for persisted data (in hours)
+	dataKeyRotation := getEnvAsInt("PERSISTENCE_DATA_KEY_ROTATION_HOURS", 24)
+	cfg.DataKeyRotation = time.Duration(dataKeyRotation) * time.Hour
+
+	// History and audit lines waiting for the background writer (0 writes them during the request),
+	// and when the files are fsynced: always, every PERSISTENCE_FSYNC_INTERVAL_MS, or never
+	cfg.PersistenceQueueSize = getEnvAsInt("PERSISTENCE_QUEUE_SIZE", 1000)
+	if cfg.PersistenceQueueSize < 0 {
+		ignoreValue("PERSISTENCE_QUEUE_SIZE", "invalid PERSISTENCE_QUEUE_SIZE %d, using 1000", cfg.PersistenceQueueSize)
+		cfg.PersistenceQueueSize = 1000
+	}
+	cfg.PersistenceFsync = strings.ToLower(getEnv("PERSISTENCE_FSYNC", "interval"))
+	if cfg.PersistenceFsync != "always" && cfg.PersistenceFsync != "interval" && cfg.PersistenceFsync != "never" {
+		ignoreValue("PERSISTENCE_FSYNC", "invalid PERSISTENCE_FSYNC %q, using interval", cfg.PersistenceFsync)
+		cfg.PersistenceFsync = "interval"
+	}
+	fsyncInterval := getEnvAsInt("PERSISTENCE_FSYNC_INTERVAL_MS", 1000)
+	if fsyncInterval <= 0 {
+		ignoreValue("PERSISTENCE_FSYNC_INTERVAL_MS", "invalid PERSISTENCE_FSYNC_INTERVAL_MS %d, using 1000", fsyncInterval)
+		fsyncInterval = 1000
+	}
+	cfg.PersistenceFsyncInterval = time.Duration(fsyncInterval) * time.Millisecond
+
+	// Memory caps: the largest decoded value kept, all values of a read together, and the size of a
+	// WebSocket snapshot; 0 disables a cap
+	cfg.MaxSecretValueBytes = getEnvAsInt("MAX_SECRET_VALUE_BYTES", 1<<20)
+	if cfg.MaxSecretValueBytes < 0 {
+		ignoreValue("MAX_SECRET_VALUE_BYTES", "invalid MAX_SECRET_VALUE_BYTES %d, using 1048576", cfg.MaxSecretValueBytes)
+		cfg.MaxSecretValueBytes = 1 << 20
+	}
+	cfg.MaxSecretBytes = getEnvAsInt("MAX_SECRET_BYTES", 64<<20)
+	if cfg.MaxSecretBytes < 0 {
+		ignoreValue("MAX_SECRET_BYTES", "invalid MAX_SECRET_BYTES %d, using 67108864", cfg.MaxSecretBytes)
+		cfg.MaxSecretBytes = 64 << 20
+	}
+	cfg.MaxSnapshotBytes = getEnvAsInt("MAX_SNAPSHOT_BYTES", 16<<20)
+	if cfg.MaxSnapshotBytes < 0 {
+		ignoreValue("MAX_SNAPSHOT_BYTES", "invalid MAX_SNAPSHOT_BYTES %d, using 16777216", cfg.MaxSnapshotBytes)
+		cfg.MaxSnapshotBytes = 16 << 20
+	}
+
+	// Largest request body accepted, larger ones get 413 (0 disables the limit)
+	maxRequestBodyBytes := getEnvAsInt("MAX_REQUEST_BODY_BYTES", 1<<20)
+	if maxRequestBodyBytes < 0 {
+		ignoreValue("MAX_REQUEST_BODY_BYTES", "invalid MAX_REQUEST_BODY_BYTES %d, using 1048576", maxRequestBodyBytes)
+		maxRequestBodyBytes = 1 << 20
+	}
+	cfg.MaxRequestBodyBytes = int64(maxRequestBodyBytes)
+
+	// Dates announced in Deprecation and Sunset headers on /api/v1 endpoints that have a /api/v2 successor
+	cfg.APIV1Deprecation = parseDate("API_V1_DEPRECATION", getEnv("API_V1_DEPRECATION", ""))
+	cfg.APIV1Sunset = parseDate("API_V1_SUNSET", getEnv("API_V1_SUNSET", ""))
+
+	// Feature flags overriding the defaults, e.g. "deltaBroadcasts=true;informers=false"
+	cfg.FeatureFlags = make(map[string]bool)
+	for name, value := range parseKeyValues("FEATURE_FLAGS", getEnv("FEATURE_FLAGS", "")) {
+		enabled, err := strconv.ParseBool(value)
+		switch {
+		case !features.Known(name):
+			ignoreValue("FEATURE_FLAGS", "unknown FEATURE_FLAGS flag %q", name)
+		case err != nil:
+			ignoreValue("FEATURE_FLAGS", "invalid FEATURE_FLAGS value %q for %s, use true or false", value, name)
+		default:
+			cfg.FeatureFlags[name] = enabled
+		}
+	}
+
+	// Admin endpoints injecting simulated failures into reads, for exercising alerts and runbooks outside production
+	cfg.ChaosEnabled = getEnvAsBool("CHAOS_ENABLED", false)
+
+	// Parse long-poll timeout (in seconds)
+	longPollTimeout := getEnvAsInt("LONG_POLL_TIMEOUT", 30)
+	if longPollTimeout <= 0 {
+		ignoreValue("LONG_POLL_TIMEOUT", "invalid LONG_POLL_TIMEOUT %d, using 30", longPollTimeout)
+		longPollTimeout = 30
+	}
+	cfg.LongPollTimeout = time.Duration(longPollTimeout) * time.Second
+
+	// Branding: light, dark, or auto (following the browser), and CSS colors for the accent and environment banner
+	cfg.UIColorScheme = getEnv("UI_COLOR_SCHEME", "light")
+	if cfg.UIColorScheme != "light" && cfg.UIColorScheme != "dark" && cfg.UIColorScheme != "auto" {
+		ignoreValue("UI_COLOR_SCHEME", "invalid UI_COLOR_SCHEME %q, using light", cfg.UIColorScheme)
+		cfg.UIColorScheme = "light"
+	}
+	cfg.UIAccentColor = parseColor("UI_ACCENT_COLOR", getEnv("UI_ACCENT_COLOR", ""))
+	cfg.UIBannerColor = parseColor("UI_BANNER_COLOR", getEnv("UI_BANNER_COLOR", "#c62828"))
+
+	// Read-only deployments must stay observation-only whatever else is set
+	if cfg.ReadOnly && cfg.WriteEnabled {
+		ignoreValue("WRITE_ENABLED", "WRITE_ENABLED because READ_ONLY is set")
+		cfg.WriteEnabled = false
+	}
+
+	cfg.Problems = loadProblems
+	logging.Printf("Config loaded: SecretNames=%v (len=%d)", cfg.SecretNames, len(cfg.SecretNames))
 	return cfg
 }
 
+// NamespaceAllowed reports whether the namespace may be browsed
+func (c *Config) NamespaceAllowed(namespace string) bool {
+	for _, allowed := range c.AllowedNamespaces {
+		if allowed == "*" || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// AllNamespacesAllowed reports whether the wildcard namespace is configured
+func (c *Config) AllNamespacesAllowed() bool {
+	return c.NamespaceAllowed("*")
+}
+
+// splitList splits a comma-separated list, trimming whitespace and dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseSecretGroups parses group assignments into a secret name to group name map
+func parseSecretGroups(value string) map[string]string {
+	groups := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			ignoreValue("SECRET_GROUPS", "invalid SECRET_GROUPS entry: %q", entry)
+			continue
+		}
+		group := strings.TrimSpace(parts[0])
+		for _, name := range strings.Split(parts[1], ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && group != "" {
+				groups[name] = group
+			}
+		}
+	}
+	return groups
+}
+
+// parseIdentityLists parses "identity=a,b;identity2=c" into an identity to list map
+func parseIdentityLists(envKey, value string) map[string][]string {
+	lists := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		identity := ""
+		if len(parts) == 2 {
+			identity = strings.TrimSpace(parts[0])
+		}
+		if identity == "" {
+			ignoreValue(envKey, "invalid %s entry: %q", envKey, entry)
+			continue
+		}
+		lists[identity] = splitList(parts[1])
+	}
+	return lists
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -65,6 +876,7 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	value, err := strconv.Atoi(valueStr)
 	if err != nil {
+		ignoreValue(key, "invalid %s %q, using %d", key, valueStr, defaultValue)
 		return defaultValue
 	}
 	return value
@@ -78,7 +890,67 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	value, err := strconv.ParseBool(valueStr)
 	if err != nil {
+		ignoreValue(key, "invalid %s %q, using %t", key, valueStr, defaultValue)
 		return defaultValue
 	}
 	return value
 }
+
+// ignoreValue logs a value LoadConfig didn't use and records it in the Config's Problems
+func ignoreValue(envKey, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	logging.Printf("Ignoring %s", message)
+	loadProblems = append(loadProblems, Problem{Env: envKey, Message: message})
+}
+
+// versionPattern matches dotted release versions such as 0.1.0
+var versionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+// cssColorPattern matches hex colors such as #c62828 and named colors such as darkorange
+var cssColorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// parseColor returns a hex or named CSS color, or "" when value is empty or not a color
+func parseColor(envKey, value string) string {
+	value = strings.TrimSpace(value)
+	if value != "" && !cssColorPattern.MatchString(value) {
+		ignoreValue(envKey, "invalid %s color: %q", envKey, value)
+		return ""
+	}
+	return value
+}
+
+// parseDate parses an RFC3339 timestamp or a date such as 2027-01-31, or returns the zero time when value is
+// empty or invalid
+func parseDate(envKey, value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC()
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		ignoreValue(envKey, "invalid %s date: %q", envKey, value)
+		return time.Time{}
+	}
+	return t
+}
+
+// parseKeyValues parses "key=value;key2=value2" into a map
+func parseKeyValues(envKey, value string) map[string]string {
+	values := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			ignoreValue(envKey, "invalid %s entry: %q", envKey, entry)
+			continue
+		}
+		values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return values
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+)
+
+// Where an effective setting came from
+const (
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+	SourceDefault = "default"
+)
+
+// redactedValue replaces the value of settings tagged redact:"true"
+const redactedValue = "[redacted]"
+
+// Problem is a configuration value that was ignored or can't work as configured
+type Problem struct {
+	Env     string `json:"env"`
+	Message string `json:"message"`
+}
+
+// Setting is one effective configuration value and where it came from
+type Setting struct {
+	Field    string      `json:"field"`
+	Env      string      `json:"env,omitempty"`
+	Flag     string      `json:"flag,omitempty"`
+	Value    interface{} `json:"value"`
+	Source   string      `json:"source"`
+	Redacted bool        `json:"redacted,omitempty"`
+	Problems []string    `json:"problems,omitempty"`
+}
+
+// Effective lists every setting in declaration order, with its value after defaults, parsing, and flags
+// Sensitive values are redacted, passwords in URLs masked, and secret values known to the logger scrubbed
+func (c *Config) Effective() []Setting {
+	fields := reflect.ValueOf(c).Elem()
+	settings := make([]Setting, 0, fields.NumField())
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Type().Field(i)
+		value := fields.Field(i)
+		setting := Setting{Field: field.Name, Env: field.Tag.Get("env"), Flag: field.Tag.Get("flag"), Source: SourceDefault}
+		switch {
+		case setting.Env != "":
+			// An empty variable counts as unset, as in getEnv
+			if os.Getenv(setting.Env) != "" {
+				setting.Source = SourceEnv
+			}
+			for _, problem := range c.Problems {
+				if problem.Env == setting.Env {
+					setting.Problems = append(setting.Problems, problem.Message)
+				}
+			}
+		case setting.Flag != "":
+			if !value.IsZero() {
+				setting.Source = SourceFlag
+			}
+		default:
+			continue
+		}
+		if field.Tag.Get("redact") == "true" && !value.IsZero() {
+			setting.Value, setting.Redacted = redactedValue, true
+		} else {
+			setting.Value, setting.Redacted = scrubSetting(value.Interface())
+		}
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+// scrubSetting returns a value safe to show, durations and dates as text, and whether anything was masked
+func scrubSetting(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.String(), false
+	case time.Time:
+		if v.IsZero() {
+			return "", false
+		}
+		return v.Format(time.RFC3339), false
+	case string:
+		return scrubString(v)
+	case []string:
+		return scrubList(v)
+	case map[string]string:
+		scrubbed := make(map[string]string, len(v))
+		masked := false
+		for key, item := range v {
+			var itemMasked bool
+			scrubbed[key], itemMasked = scrubString(item)
+			masked = masked || itemMasked
+		}
+		return scrubbed, masked
+	case map[string][]string:
+		scrubbed := make(map[string][]string, len(v))
+		masked := false
+		for key, items := range v {
+			var itemsMasked bool
+			scrubbed[key], itemsMasked = scrubList(items)
+			masked = masked || itemsMasked
+		}
+		return scrubbed, masked
+	}
+	return value, false
+}
+
+// scrubList scrubs each string of a list
+func scrubList(items []string) ([]string, bool) {
+	scrubbed := make([]string, len(items))
+	masked := false
+	for i, item := range items {
+		var itemMasked bool
+		scrubbed[i], itemMasked = scrubString(item)
+		masked = masked || itemMasked
+	}
+	return scrubbed, masked
+}
+
+// scrubString masks the password of a URL, such as SESSION_REDIS_URL, and any secret value the logger knows of
+func scrubString(value string) (string, bool) {
+	scrubbed := value
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			scrubbed = u.Redacted()
+		}
+	}
+	scrubbed = logging.Scrub(scrubbed)
+	return scrubbed, scrubbed != value
+}
+
+// inputPaths are the settings naming files or directories that must exist when set
+var inputPaths = []string{
+	"AGENT_CONFIG_FILE",
+	"ALERT_RULES_FILE",
+	"AUDIT_SINKS_FILE",
+	"AUTH_BASIC_FILE",
+	"AUTH_TOKENS_FILE",
+	"EXPORT_SIGNING_KEY_FILE",
+	"FILE_SOURCE_DIR",
+	"GITOPS_SOPS_AGE_KEY_FILE",
+	"KUBE_REPLAY_FILE",
+	"LOCAL_SECRETS_DIR",
+	"LOCALES_DIR",
+	"MQTT_PASSWORD_FILE",
+	"NOTIFY_CONFIG_FILE",
+	"PERSISTENCE_KEY_FILE",
+	"REFRESH_SCHEDULE_FILE",
+	"SESSION_REDIS_PASSWORD_FILE",
+	"TEMPLATES_DIR",
+	"VAULT_SECRET_ID_FILE",
+	"VAULT_TOKEN_FILE",
+}
+
+// ChaosAllowed reports whether the chaos endpoints can be restricted to admins: ADMIN_IDENTITIES is set and
+// AUTH_METHODS has a method that establishes an identity
+func (c *Config) ChaosAllowed() bool {
+	return len(c.AdminIdentities) > 0 && slices.ContainsFunc(c.AuthMethods, func(method string) bool {
+		return method != "none"
+	})
+}
+
+// Validate returns the ignored values plus the settings that can't work as configured:
+// out-of-range numbers, contradicting settings, and missing files
+// Parsing the referenced files is left to the packages that own them
+func (c *Config) Validate() []Problem {
+	problems := append(make([]Problem, 0, len(c.Problems)), c.Problems...)
+	add := func(env, format string, args ...interface{}) {
+		problems = append(problems, Problem{Env: env, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		add("PORT", "port %d is out of range 1-65535", c.Port)
+	}
+	// Compression levels follow compress/flate: -2 (Huffman only) to 9
+	if c.WSCompressionLevel < -2 || c.WSCompressionLevel > 9 {
+		add("WS_COMPRESSION_LEVEL", "compression level %d is out of range -2-9", c.WSCompressionLevel)
+	}
+	if c.Standalone && c.RequireKubernetes {
+		add("REQUIRE_KUBERNETES", "REQUIRE_KUBERNETES contradicts --standalone")
+	}
+	if c.KubeRecordFile != "" && c.KubeReplayFile != "" {
+		add("KUBE_RECORD_FILE", "KUBE_RECORD_FILE is ignored while KUBE_REPLAY_FILE serves a recording")
+	}
+	if !c.APIV1Sunset.IsZero() && c.APIV1Sunset.Before(c.APIV1Deprecation) {
+		add("API_V1_SUNSET", "API_V1_SUNSET is before API_V1_DEPRECATION")
+	}
+	if c.ChaosEnabled && !c.ChaosAllowed() {
+		add("CHAOS_ENABLED", "CHAOS_ENABLED is ignored without ADMIN_IDENTITIES and an AUTH_METHODS method other than none")
+	}
+
+	paths := make(map[string]bool, len(inputPaths))
+	for _, env := range inputPaths {
+		paths[env] = true
+	}
+	fields := reflect.ValueOf(c).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		env := fields.Type().Field(i).Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		switch value := fields.Field(i).Interface().(type) {
+		case int:
+			if value < 0 && env != "WS_COMPRESSION_LEVEL" {
+				add(env, "%s must not be negative", env)
+			}
+		case time.Duration:
+			if value < 0 {
+				add(env, "%s must not be negative", env)
+			}
+		case string:
+			if paths[env] && value != "" {
+				if _, err := os.Stat(value); err != nil {
+					add(env, "%v", err)
+				}
+			}
+		}
+	}
+	return problems
+}
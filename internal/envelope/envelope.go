@@ -0,0 +1,185 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// dataKeySize is the size of generated AES-256 data encryption keys
+const dataKeySize = 32
+
+// KeyProvider wraps and unwraps data encryption keys with a key encryption key
+type KeyProvider interface {
+	// Name identifies the provider type
+	Name() string
+	// WrapKey encrypts a data key with the active key encryption key and returns its ID
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key previously wrapped with the identified key
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Sealed is the serialized form of an encrypted record
+type Sealed struct {
+	Provider   string `json:"provider"`
+	KeyID      string `json:"keyId"`
+	WrappedKey string `json:"wrappedKey"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// Encrypter performs envelope encryption: records are encrypted with a data key that is
+// itself wrapped by the provider, and the data key is rotated periodically
+type Encrypter struct {
+	provider    KeyProvider
+	rotateAfter time.Duration
+
+	mu        sync.Mutex
+	dataKey   []byte
+	keyID     string
+	wrapped   []byte
+	createdAt time.Time
+
+	cacheMu sync.Mutex
+	cache   map[string][]byte
+}
+
+// NewEncrypter creates an encrypter that rotates its data key after rotateAfter
+func NewEncrypter(provider KeyProvider, rotateAfter time.Duration) *Encrypter {
+	return &Encrypter{
+		provider:    provider,
+		rotateAfter: rotateAfter,
+		cache:       make(map[string][]byte),
+	}
+}
+
+// currentKey returns the active data key, generating and wrapping a new one when due
+func (e *Encrypter) currentKey() ([]byte, string, []byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.dataKey != nil && (e.rotateAfter <= 0 || time.Since(e.createdAt) < e.rotateAfter) {
+		return e.dataKey, e.keyID, e.wrapped, nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	keyID, wrapped, err := e.provider.WrapKey(dataKey)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to wrap data key with %s: %w", e.provider.Name(), err)
+	}
+
+	if e.dataKey != nil {
+		clear(e.dataKey)
+	}
+	e.dataKey, e.keyID, e.wrapped, e.createdAt = dataKey, keyID, wrapped, time.Now()
+	return e.dataKey, e.keyID, e.wrapped, nil
+}
+
+// Seal encrypts plaintext into a self-describing record
+func (e *Encrypter) Seal(plaintext []byte) (*Sealed, error) {
+	dataKey, keyID, wrapped, err := e.currentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return &Sealed{
+		Provider:   e.provider.Name(),
+		KeyID:      keyID,
+		WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, []byte(keyID))),
+	}, nil
+}
+
+// SealJSON marshals v and seals it, returning the sealed record as JSON
+func (e *Encrypter) SealJSON(v interface{}) ([]byte, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal record: %w", err)
+	}
+	defer clear(plaintext)
+
+	sealed, err := e.Seal(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealed)
+}
+
+// Open decrypts a sealed record, unwrapping its data key through the provider
+func (e *Encrypter) Open(sealed *Sealed) ([]byte, error) {
+	if sealed.Provider != e.provider.Name() {
+		return nil, fmt.Errorf("record was sealed with provider %q, configured provider is %q", sealed.Provider, e.provider.Name())
+	}
+
+	dataKey, err := e.unwrap(sealed.KeyID, sealed.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(sealed.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(sealed.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(sealed.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt record: %w", err)
+	}
+	return plaintext, nil
+}
+
+// unwrap returns the data key for a wrapped key, caching results to avoid unwrapping the same key per record
+func (e *Encrypter) unwrap(keyID, wrappedKey string) ([]byte, error) {
+	cacheKey := keyID + "/" + wrappedKey
+
+	e.cacheMu.Lock()
+	defer e.cacheMu.Unlock()
+	if dataKey, ok := e.cache[cacheKey]; ok {
+		return dataKey, nil
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	dataKey, err := e.provider.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with %s: %w", e.provider.Name(), err)
+	}
+	e.cache[cacheKey] = dataKey
+	return dataKey, nil
+}
+
+// newGCM creates an AES-GCM cipher for the key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
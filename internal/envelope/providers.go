@@ -0,0 +1,107 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Provider names accepted by NewProvider
+const (
+	ProviderNone    = "none"
+	ProviderKeyFile = "keyfile"
+)
+
+// NewProvider creates the key provider for the configured kind
+// Returns (nil, nil) when encryption is disabled
+func NewProvider(kind, keyFile string) (KeyProvider, error) {
+	switch kind {
+	case "", ProviderNone:
+		return nil, nil
+	case ProviderKeyFile:
+		return newKeyFileProvider(keyFile)
+	default:
+		return nil, fmt.Errorf("unknown encryption provider %q (use none or keyfile)", kind)
+	}
+}
+
+// keyFileProvider wraps data keys with AES-256 keys from a local keyring file
+// The first key in the file is active; older keys are kept to decrypt existing records,
+// so rotation means prepending a new key
+type keyFileProvider struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// newKeyFileProvider loads a keyring with one "id:base64-key" entry per line
+func newKeyFileProvider(path string) (*keyFileProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s requires a key file", ProviderKeyFile)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	provider := &keyFileProvider{keys: make(map[string][]byte)}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("key file line %d: expected id:base64-key", i+1)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != dataKeySize {
+			return nil, fmt.Errorf("key file line %d: key must be %d bytes of base64", i+1, dataKeySize)
+		}
+		id = strings.TrimSpace(id)
+		if provider.activeID == "" {
+			provider.activeID = id
+		}
+		provider.keys[id] = key
+	}
+	if provider.activeID == "" {
+		return nil, fmt.Errorf("key file %s contains no keys", path)
+	}
+	return provider, nil
+}
+
+// Name returns the provider name
+func (p *keyFileProvider) Name() string {
+	return ProviderKeyFile
+}
+
+// WrapKey encrypts the data key with the active keyring key
+func (p *keyFileProvider) WrapKey(dataKey []byte) (string, []byte, error) {
+	gcm, err := newGCM(p.keys[p.activeID])
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return p.activeID, gcm.Seal(nonce, nonce, dataKey, []byte(p.activeID)), nil
+}
+
+// UnwrapKey decrypts a data key with the identified keyring key
+func (p *keyFileProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key %q is not in the keyring", keyID)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	nonce, ciphertext := wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, []byte(keyID))
+}
@@ -0,0 +1,113 @@
+// Package features defines the feature flags that gate experimental subsystems, set per environment
+// through FEATURE_FLAGS
+package features
+
+import "sort"
+
+// Flag names
+const (
+	// Informers watches secret metadata through informers as WATCH_STRATEGY configures; off, secrets are only polled
+	Informers = "informers"
+	// DeltaBroadcasts sends WebSocket clients that ask for it only the secrets changed since the previous snapshot
+	DeltaBroadcasts = "deltaBroadcasts"
+	// WriteEndpoints serves the BitwardenSecret write endpoints enabled by WRITE_ENABLED
+	WriteEndpoints = "writeEndpoints"
+)
+
+// Maturity stages of a flag; alpha flags are off by default, beta flags on
+const (
+	StageAlpha = "alpha"
+	StageBeta  = "beta"
+)
+
+// Flag describes a feature flag
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Stage       string `json:"stage"`
+	Default     bool   `json:"default"`
+}
+
+// Flags are the known feature flags
+var Flags = []Flag{
+	{Name: Informers, Description: "Watch secret metadata with informers as WATCH_STRATEGY configures, instead of only polling", Stage: StageBeta, Default: true},
+	{Name: DeltaBroadcasts, Description: "Send WebSocket clients that connect with ?delta=true only the secrets that changed", Stage: StageAlpha, Default: false},
+	{Name: WriteEndpoints, Description: "Serve the BitwardenSecret create, update, and delete endpoints when WRITE_ENABLED is set", Stage: StageBeta, Default: true},
+}
+
+// Known reports whether name is a feature flag
+func Known(name string) bool {
+	_, ok := lookup(name)
+	return ok
+}
+
+func lookup(name string) (Flag, bool) {
+	for _, flag := range Flags {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// State is a flag with its effective value
+type State struct {
+	Flag
+	Enabled bool `json:"enabled"`
+	// Overridden is set when FEATURE_FLAGS sets the flag
+	Overridden bool `json:"overridden"`
+}
+
+// Set holds the effective value of every flag
+type Set struct {
+	enabled   map[string]bool
+	overrides map[string]bool
+}
+
+// New applies overrides to the flag defaults; unknown names are ignored
+func New(overrides map[string]bool) *Set {
+	s := &Set{enabled: make(map[string]bool, len(Flags)), overrides: make(map[string]bool, len(overrides))}
+	for _, flag := range Flags {
+		s.enabled[flag.Name] = flag.Default
+	}
+	for name, enabled := range overrides {
+		if Known(name) {
+			s.enabled[name] = enabled
+			s.overrides[name] = enabled
+		}
+	}
+	return s
+}
+
+// Enabled reports whether the flag is on; a nil Set has every flag at its default
+func (s *Set) Enabled(name string) bool {
+	if s == nil {
+		flag, _ := lookup(name)
+		return flag.Default
+	}
+	return s.enabled[name]
+}
+
+// Values returns every flag's effective value by name
+func (s *Set) Values() map[string]bool {
+	values := make(map[string]bool, len(Flags))
+	for _, flag := range Flags {
+		values[flag.Name] = s.Enabled(flag.Name)
+	}
+	return values
+}
+
+// States returns every flag with its effective value, by name
+func (s *Set) States() []State {
+	var overrides map[string]bool
+	if s != nil {
+		overrides = s.overrides
+	}
+	states := make([]State, 0, len(Flags))
+	for _, flag := range Flags {
+		_, overridden := overrides[flag.Name]
+		states = append(states, State{Flag: flag, Enabled: s.Enabled(flag.Name), Overridden: overridden})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
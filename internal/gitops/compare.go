@@ -0,0 +1,70 @@
+package gitops
+
+import (
+	"bytes"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Comparison statuses
+const (
+	StatusMatch    = "match"
+	StatusDiverged = "diverged"
+	StatusMissing  = "missing"
+	StatusError    = "error"
+)
+
+// Result reports how a live Secret compares with its GitOps manifest
+type Result struct {
+	Source         string   `json:"source"`
+	Format         string   `json:"format"`
+	Name           string   `json:"name"`
+	Namespace      string   `json:"namespace"`
+	Status         string   `json:"status"`
+	ValuesCompared bool     `json:"valuesCompared"`
+	MissingKeys    []string `json:"missingKeys,omitempty"`
+	ExtraKeys      []string `json:"extraKeys,omitempty"`
+	ChangedKeys    []string `json:"changedKeys,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// Compare checks a live Secret against the expected state; a nil secret is reported as missing
+// Values are only compared when the manifest could be decrypted
+func Compare(expected ExpectedSecret, secret *corev1.Secret) Result {
+	result := Result{
+		Source:         expected.Source,
+		Format:         expected.Format,
+		Name:           expected.Name,
+		Namespace:      expected.Namespace,
+		Status:         StatusMatch,
+		ValuesCompared: expected.Values != nil,
+	}
+	if secret == nil {
+		result.Status = StatusMissing
+		return result
+	}
+
+	expectedKeys := make(map[string]bool, len(expected.Keys))
+	for _, key := range expected.Keys {
+		expectedKeys[key] = true
+		live, ok := secret.Data[key]
+		switch {
+		case !ok:
+			result.MissingKeys = append(result.MissingKeys, key)
+		case expected.Values != nil && !bytes.Equal(live, expected.Values[key]):
+			result.ChangedKeys = append(result.ChangedKeys, key)
+		}
+	}
+	for key := range secret.Data {
+		if !expectedKeys[key] {
+			result.ExtraKeys = append(result.ExtraKeys, key)
+		}
+	}
+	sort.Strings(result.ExtraKeys)
+
+	if len(result.MissingKeys) > 0 || len(result.ExtraKeys) > 0 || len(result.ChangedKeys) > 0 {
+		result.Status = StatusDiverged
+	}
+	return result
+}
@@ -0,0 +1,222 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Manifest formats recognised by the loader
+const (
+	FormatPlain        = "plain"
+	FormatSOPS         = "sops"
+	FormatSealedSecret = "sealedsecret"
+)
+
+// maxManifestSize bounds how much is read from a single manifest source
+const maxManifestSize = 4 << 20
+
+// sopsMarker matches the metadata block SOPS adds to encrypted YAML or JSON files
+var sopsMarker = regexp.MustCompile(`(?m)^sops:|"sops"\s*:`)
+
+// ExpectedSecret is the Secret state declared by a GitOps manifest
+type ExpectedSecret struct {
+	Source    string
+	Format    string
+	Name      string
+	Namespace string
+	Keys      []string
+	// Values holds the expected raw values; nil when they cannot be decrypted (SealedSecrets)
+	Values map[string][]byte
+}
+
+// Loader reads Secret manifests from files or HTTP(S) URLs, decrypting SOPS files on the way
+type Loader struct {
+	SOPSBinary  string
+	SOPSKeyFile string
+	HTTPClient  *http.Client
+}
+
+// NewLoader creates a loader that decrypts SOPS files with the given age key file
+func NewLoader(sopsKeyFile string) *Loader {
+	return &Loader{
+		SOPSBinary:  "sops",
+		SOPSKeyFile: sopsKeyFile,
+		HTTPClient:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Load reads a manifest source and returns the Secrets it declares
+func (l *Loader) Load(ctx context.Context, source string) ([]ExpectedSecret, error) {
+	data, err := l.read(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := sopsMarker.Match(data)
+	if encrypted {
+		data, err = l.decryptSOPS(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	secrets, err := parseManifests(data, encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	for i := range secrets {
+		secrets[i].Source = source
+	}
+	return secrets, nil
+}
+
+// read fetches the raw manifest bytes from a local path or URL
+func (l *Loader) read(ctx context.Context, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest URL: %w", err)
+	}
+	resp, err := l.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch manifest %s: HTTP %d", source, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return data, nil
+}
+
+// decryptSOPS decrypts a SOPS-encrypted document through the sops CLI
+func (l *Loader) decryptSOPS(ctx context.Context, data []byte) ([]byte, error) {
+	format := "yaml"
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		format = "json"
+	}
+
+	cmd := exec.CommandContext(ctx, l.SOPSBinary, "--decrypt", "--input-type", format, "--output-type", format, "/dev/stdin")
+	cmd.Stdin = bytes.NewReader(data)
+	if l.SOPSKeyFile != "" {
+		cmd.Env = append(os.Environ(), "SOPS_AGE_KEY_FILE="+l.SOPSKeyFile)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", l.SOPSBinary, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// parseManifests extracts Secrets and SealedSecrets from a multi-document manifest
+func parseManifests(data []byte, sops bool) ([]ExpectedSecret, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var secrets []ExpectedSecret
+	for {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if obj == nil {
+			continue
+		}
+
+		doc := &unstructured.Unstructured{Object: obj}
+		switch doc.GetKind() {
+		case "Secret":
+			secret, err := expectedFromSecret(doc)
+			if err != nil {
+				return nil, err
+			}
+			if sops {
+				secret.Format = FormatSOPS
+			}
+			secrets = append(secrets, secret)
+		case "SealedSecret":
+			secrets = append(secrets, expectedFromSealedSecret(doc))
+		}
+	}
+	return secrets, nil
+}
+
+// expectedFromSecret reads the expected values of a plain (or decrypted) Secret manifest
+func expectedFromSecret(doc *unstructured.Unstructured) (ExpectedSecret, error) {
+	secret := ExpectedSecret{
+		Format:    FormatPlain,
+		Name:      doc.GetName(),
+		Namespace: doc.GetNamespace(),
+		Values:    make(map[string][]byte),
+	}
+
+	data, _, _ := unstructured.NestedStringMap(doc.Object, "data")
+	for key, encoded := range data {
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return secret, fmt.Errorf("secret %s: data.%s is not valid base64", secret.Name, key)
+		}
+		secret.Values[key] = value
+	}
+	stringData, _, _ := unstructured.NestedStringMap(doc.Object, "stringData")
+	for key, value := range stringData {
+		secret.Values[key] = []byte(value)
+	}
+
+	for key := range secret.Values {
+		secret.Keys = append(secret.Keys, key)
+	}
+	sort.Strings(secret.Keys)
+	return secret, nil
+}
+
+// expectedFromSealedSecret reads the key names of a SealedSecret; values stay sealed
+func expectedFromSealedSecret(doc *unstructured.Unstructured) ExpectedSecret {
+	secret := ExpectedSecret{
+		Format:    FormatSealedSecret,
+		Name:      doc.GetName(),
+		Namespace: doc.GetNamespace(),
+	}
+
+	// The generated Secret may be renamed through the template metadata
+	if name, found, _ := unstructured.NestedString(doc.Object, "spec", "template", "metadata", "name"); found && name != "" {
+		secret.Name = name
+	}
+	if namespace, found, _ := unstructured.NestedString(doc.Object, "spec", "template", "metadata", "namespace"); found && namespace != "" {
+		secret.Namespace = namespace
+	}
+
+	encrypted, _, _ := unstructured.NestedMap(doc.Object, "spec", "encryptedData")
+	for key := range encrypted {
+		secret.Keys = append(secret.Keys, key)
+	}
+	sort.Strings(secret.Keys)
+	return secret
+}
@@ -0,0 +1,53 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// SecretDeletion is a watched Secret that was deleted, with who deleted it when that could be found
+type SecretDeletion struct {
+	Secret    string    `json:"secret"`
+	Namespace string    `json:"namespace"`
+	Time      time.Time `json:"time"`
+	UID       string    `json:"uid,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// SaveDeletion appends a secret deletion
+func (s *Store) SaveDeletion(deletion SecretDeletion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deletions[deletion.Secret] = append(s.deletions[deletion.Secret], deletion)
+	return s.append(entry{Kind: kindDeletion, Deletion: &deletion})
+}
+
+// LastDeletion returns the most recent deletion of a secret
+func (s *Store) LastDeletion(secret string) (SecretDeletion, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deletions := s.deletions[secret]
+	if len(deletions) == 0 {
+		return SecretDeletion{}, false
+	}
+	return deletions[len(deletions)-1], true
+}
+
+// Deletions returns the deletions from since on across all secrets, newest first
+func (s *Store) Deletions(since time.Time) []SecretDeletion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]SecretDeletion, 0)
+	for _, deletions := range s.deletions {
+		for _, deletion := range deletions {
+			if !deletion.Time.Before(since) {
+				result = append(result, deletion)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	return result
+}
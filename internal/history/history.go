@@ -0,0 +1,331 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/envelope"
+	"bitwarden-reader/internal/writebehind"
+)
+
+// Trigger outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomePartial = "partial"
+	OutcomeFailure = "failure"
+)
+
+// Entry kinds stored in the history file
+const (
+	kindTrigger  = "trigger"
+	kindSync     = "sync"
+	kindDeletion = "deletion"
+)
+
+// TriggerRecord is one trigger-sync request and what came of it
+type TriggerRecord struct {
+	ID        string          `json:"id"`
+	Time      time.Time       `json:"time"`
+	Initiator string          `json:"initiator"`
+	RequestID string          `json:"requestId,omitempty"`
+	Namespace string          `json:"namespace"`
+	Outcome   string          `json:"outcome"`
+	Ticket    string          `json:"ticket,omitempty"`
+	Secrets   []TriggerSecret `json:"secrets"`
+	// VerifiedAt is set once the sync times were checked after the trigger
+	VerifiedAt *time.Time `json:"verifiedAt,omitempty"`
+	// Result is the verified outcome across the triggered secrets; empty until verified
+	Result string `json:"result,omitempty"`
+}
+
+// Verified results of a triggered sync
+const (
+	SyncSucceeded = "succeeded"
+	SyncFailed    = "failed"
+	SyncTimedOut  = "timed-out"
+)
+
+// TriggerSecret is the result of a trigger for one secret
+type TriggerSecret struct {
+	Name       string `json:"name"`
+	Triggered  bool   `json:"triggered"`
+	Error      string `json:"error,omitempty"`
+	SyncBefore string `json:"syncBefore,omitempty"`
+	SyncAfter  string `json:"syncAfter,omitempty"`
+	// Advanced reports whether lastSuccessfulSyncTime moved after the trigger; nil until verified
+	Advanced *bool `json:"advanced,omitempty"`
+	// FailureBefore is the failing sync condition the CRD already had when triggered
+	FailureBefore string `json:"failureBefore,omitempty"`
+	// Failure is the failing sync condition that appeared after the trigger
+	Failure string `json:"failure,omitempty"`
+	// Deduplicated describes the earlier trigger this one joined instead of patching the CRD again
+	Deduplicated string `json:"deduplicated,omitempty"`
+	// Result is succeeded, failed, or timed-out once verified
+	Result string `json:"result,omitempty"`
+}
+
+// SyncObservation is the sync state of a secret from the moment it was observed until the next observation
+type SyncObservation struct {
+	Secret    string    `json:"secret"`
+	Namespace string    `json:"namespace"`
+	Time      time.Time `json:"time"`
+	Found     bool      `json:"found"`
+	Status    string    `json:"status,omitempty"`
+	LastSync  string    `json:"lastSync,omitempty"`
+}
+
+// SameState reports whether two observations describe the same sync state
+func (o SyncObservation) SameState(other SyncObservation) bool {
+	return o.Found == other.Found && o.Status == other.Status && o.LastSync == other.LastSync
+}
+
+// entry is one line of the history file
+type entry struct {
+	Kind     string           `json:"kind"`
+	Trigger  *TriggerRecord   `json:"trigger,omitempty"`
+	Sync     *SyncObservation `json:"sync,omitempty"`
+	Deletion *SecretDeletion  `json:"deletion,omitempty"`
+}
+
+// TriggerFilter selects trigger records; zero values match everything
+type TriggerFilter struct {
+	Secret    string
+	Initiator string
+	Since     time.Time
+	Limit     int
+}
+
+// Store keeps history in memory and appends every change as a JSON line to a file
+// Updated records are appended again; the last line for an ID wins when loading
+// When encrypter is non-nil each file line is an envelope-encrypted record
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	out       *writebehind.Writer
+	encrypter *envelope.Encrypter
+	triggers  map[string]TriggerRecord
+	// syncs holds the observations of each secret in time order
+	syncs map[string][]SyncObservation
+	// deletions holds the deletions of each secret in time order
+	deletions map[string][]SecretDeletion
+	// lines counts the lines in the file, including superseded trigger updates
+	lines int
+}
+
+// Open loads the history file and opens it for appending through a write-behind queue configured by persist;
+// an empty path keeps history in memory only
+func Open(path string, encrypter *envelope.Encrypter, persist writebehind.Options) (*Store, error) {
+	store := &Store{
+		encrypter: encrypter,
+		triggers:  make(map[string]TriggerRecord),
+		syncs:     make(map[string][]SyncObservation),
+		deletions: make(map[string][]SecretDeletion),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	if existing, err := os.Open(path); err == nil {
+		err = store.load(existing)
+		existing.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load history file: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+
+	out, err := writebehind.Open(path, persist)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	store.path = path
+	store.out = out
+	return store, nil
+}
+
+// load reads entries, decrypting sealed lines
+func (s *Store) load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		var sealed envelope.Sealed
+		if err := json.Unmarshal(data, &sealed); err == nil && sealed.Ciphertext != "" {
+			if s.encrypter == nil {
+				return fmt.Errorf("line %d is encrypted but PERSISTENCE_ENCRYPTION is not configured", line)
+			}
+			plaintext, err := s.encrypter.Open(&sealed)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			data = plaintext
+		}
+
+		var e entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		s.lines++
+		switch {
+		case e.Kind == kindTrigger && e.Trigger != nil:
+			s.triggers[e.Trigger.ID] = *e.Trigger
+		case e.Kind == kindSync && e.Sync != nil:
+			s.syncs[e.Sync.Secret] = append(s.syncs[e.Sync.Secret], *e.Sync)
+		case e.Kind == kindDeletion && e.Deletion != nil:
+			s.deletions[e.Deletion.Secret] = append(s.deletions[e.Deletion.Secret], *e.Deletion)
+		}
+	}
+	return scanner.Err()
+}
+
+// append queues an entry for the history file
+func (s *Store) append(e entry) error {
+	if s.out == nil {
+		return nil
+	}
+	data, err := s.encode(e)
+	if err != nil {
+		return err
+	}
+	if err := s.out.Write(data); err != nil {
+		return fmt.Errorf("failed to write history entry: %w", err)
+	}
+	s.lines++
+	return nil
+}
+
+// encode returns the file line of an entry, sealed when encryption is configured
+func (s *Store) encode(e entry) ([]byte, error) {
+	var data []byte
+	var err error
+	if s.encrypter != nil {
+		data, err = s.encrypter.SealJSON(e)
+	} else {
+		data, err = json.Marshal(e)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// SaveTrigger stores a new or updated trigger record
+func (s *Store) SaveTrigger(record TriggerRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.triggers[record.ID] = record
+	return s.append(entry{Kind: kindTrigger, Trigger: &record})
+}
+
+// Trigger returns the trigger record with id
+func (s *Store) Trigger(id string) (TriggerRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.triggers[id]
+	return record, ok
+}
+
+// Triggers returns matching trigger records, newest first
+func (s *Store) Triggers(filter TriggerFilter) []TriggerRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]TriggerRecord, 0, len(s.triggers))
+	for _, record := range s.triggers {
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Time.After(records[j].Time)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records
+}
+
+// SaveObservation appends a sync observation
+func (s *Store) SaveObservation(observation SyncObservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncs[observation.Secret] = append(s.syncs[observation.Secret], observation)
+	return s.append(entry{Kind: kindSync, Sync: &observation})
+}
+
+// LatestObservation returns the most recent observation of a secret
+func (s *Store) LatestObservation(secret string) (SyncObservation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	observations := s.syncs[secret]
+	if len(observations) == 0 {
+		return SyncObservation{}, false
+	}
+	return observations[len(observations)-1], true
+}
+
+// Observations returns each secret's observations from since on, preceded by the
+// last observation before since, which describes the state at the start of the range
+func (s *Store) Observations(since time.Time) map[string][]SyncObservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string][]SyncObservation, len(s.syncs))
+	for secret, observations := range s.syncs {
+		start := sort.Search(len(observations), func(i int) bool {
+			return !observations[i].Time.Before(since)
+		})
+		if start > 0 {
+			start--
+		}
+		if start < len(observations) {
+			result[secret] = append([]SyncObservation(nil), observations[start:]...)
+		}
+	}
+	return result
+}
+
+// matches reports whether a record passes the filter
+func (f TriggerFilter) matches(record TriggerRecord) bool {
+	if f.Initiator != "" && record.Initiator != f.Initiator {
+		return false
+	}
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+	if f.Secret == "" {
+		return true
+	}
+	for _, secret := range record.Secrets {
+		if secret.Name == f.Secret {
+			return true
+		}
+	}
+	return false
+}
+
+// WriterStats returns the write-behind queue state; zero when history is kept in memory only
+func (s *Store) WriterStats() writebehind.Stats {
+	if s.out == nil {
+		return writebehind.Stats{}
+	}
+	return s.out.Stats()
+}
+
+// Close writes what is still queued and closes the history file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.out == nil {
+		return nil
+	}
+	return s.out.Close()
+}
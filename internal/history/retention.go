@@ -0,0 +1,162 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Compact drops trigger records, sync observations, and deletions older than before, and rewrites
+// the history file with one line per remaining record, which also drops superseded trigger updates
+// The last observation of each secret before the cutoff is kept because it describes the state
+// at the cutoff, and so is its last deletion, which explains why a secret is missing. A zero before removes nothing and only rewrites the file. It returns the number
+// of records removed
+func (s *Store) Compact(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	if !before.IsZero() {
+		for id, record := range s.triggers {
+			if record.Time.Before(before) {
+				delete(s.triggers, id)
+				removed++
+			}
+		}
+		for secret, observations := range s.syncs {
+			start := sort.Search(len(observations), func(i int) bool {
+				return !observations[i].Time.Before(before)
+			})
+			if start > 0 {
+				start--
+			}
+			if start > 0 {
+				s.syncs[secret] = append([]SyncObservation(nil), observations[start:]...)
+				removed += start
+			}
+		}
+		for secret, deletions := range s.deletions {
+			start := sort.Search(len(deletions), func(i int) bool {
+				return !deletions[i].Time.Before(before)
+			})
+			if start == len(deletions) {
+				start--
+			}
+			if start > 0 {
+				s.deletions[secret] = append([]SecretDeletion(nil), deletions[start:]...)
+				removed += start
+			}
+		}
+	}
+
+	if s.out == nil || s.lines == s.records() {
+		return removed, nil
+	}
+	if err := s.rewrite(); err != nil {
+		return removed, err
+	}
+	return removed, nil
+}
+
+// records counts the records held in memory
+func (s *Store) records() int {
+	count := len(s.triggers)
+	for _, observations := range s.syncs {
+		count += len(observations)
+	}
+	for _, deletions := range s.deletions {
+		count += len(deletions)
+	}
+	return count
+}
+
+// rewrite replaces the history file with the records in memory
+// The new file is written next to the old one and renamed over it, so a crash leaves one of them intact
+func (s *Store) rewrite() error {
+	triggers := make([]TriggerRecord, 0, len(s.triggers))
+	for _, record := range s.triggers {
+		triggers = append(triggers, record)
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		return triggers[i].Time.Before(triggers[j].Time)
+	})
+	secrets := make([]string, 0, len(s.syncs))
+	for secret := range s.syncs {
+		secrets = append(secrets, secret)
+	}
+	sort.Strings(secrets)
+	deleted := make([]string, 0, len(s.deletions))
+	for secret := range s.deletions {
+		deleted = append(deleted, secret)
+	}
+	sort.Strings(deleted)
+
+	temp := s.path + ".compact"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted history file: %w", err)
+	}
+	write := func(e entry) error {
+		data, err := s.encode(e)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(data)
+		return err
+	}
+
+	lines := 0
+	for i := range triggers {
+		if err = write(entry{Kind: kindTrigger, Trigger: &triggers[i]}); err != nil {
+			break
+		}
+		lines++
+	}
+	for _, secret := range secrets {
+		observations := s.syncs[secret]
+		for i := 0; err == nil && i < len(observations); i++ {
+			if err = write(entry{Kind: kindSync, Sync: &observations[i]}); err == nil {
+				lines++
+			}
+		}
+	}
+	for _, secret := range deleted {
+		deletions := s.deletions[secret]
+		for i := 0; err == nil && i < len(deletions); i++ {
+			if err = write(entry{Kind: kindDeletion, Deletion: &deletions[i]}); err == nil {
+				lines++
+			}
+		}
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, s.path)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to write compacted history file: %w", err)
+	}
+
+	// Appends must go to the new file; entries still queued are in memory, so the new file has them already
+	if err := s.out.Reopen(); err != nil {
+		return fmt.Errorf("failed to reopen history file: %w", err)
+	}
+	s.lines = lines
+	return nil
+}
+
+// FileSize returns the size of the history file in bytes; 0 when history is kept in memory only
+func (s *Store) FileSize() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.out == nil {
+		return 0
+	}
+	return s.out.Size()
+}
@@ -0,0 +1,118 @@
+package history
+
+import (
+	"fmt"
+	"time"
+)
+
+// Metrics available as time series
+const (
+	MetricSyncAge = "syncAge"
+	MetricFound   = "found"
+	MetricFailing = "failing"
+)
+
+// MaxSeriesPoints bounds the number of points in one series
+const MaxSeriesPoints = 2000
+
+// Point is one downsampled value; Value is nil when nothing was observed in the step
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value *float64  `json:"value"`
+}
+
+// aggregator folds the observed segments of one step into a value
+type aggregator struct {
+	// segment adds the part [start, end) of an observation
+	segment func(o SyncObservation, start, end time.Time)
+	value   func() *float64
+}
+
+// newAggregator returns the aggregator of a metric
+// syncAge is the largest age of the last successful sync within the step, in seconds;
+// found and failing are the share of the observed time the secret existed or was failing (0 to 1)
+func newAggregator(metric string) (func() aggregator, error) {
+	switch metric {
+	case MetricSyncAge:
+		return func() aggregator {
+			var age *float64
+			return aggregator{
+				segment: func(o SyncObservation, _, end time.Time) {
+					lastSync, err := time.Parse(time.RFC3339, o.LastSync)
+					if err != nil {
+						return
+					}
+					seconds := end.Sub(lastSync).Seconds()
+					if age == nil || seconds > *age {
+						age = &seconds
+					}
+				},
+				value: func() *float64 { return age },
+			}
+		}, nil
+	case MetricFound, MetricFailing:
+		return func() aggregator {
+			var observed, matching time.Duration
+			return aggregator{
+				segment: func(o SyncObservation, start, end time.Time) {
+					observed += end.Sub(start)
+					if (metric == MetricFound && o.Found) || (metric == MetricFailing && o.failing()) {
+						matching += end.Sub(start)
+					}
+				},
+				value: func() *float64 {
+					if observed == 0 {
+						return nil
+					}
+					share := matching.Seconds() / observed.Seconds()
+					return &share
+				},
+			}
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown metric %q (expected %s, %s, or %s)", metric, MetricSyncAge, MetricFound, MetricFailing)
+	}
+}
+
+// Series downsamples the time-ordered observations of one secret into steps over [from, to)
+// Each point is stamped with the start of its step
+func Series(observations []SyncObservation, metric string, from, to time.Time, step time.Duration) ([]Point, error) {
+	newStep, err := newAggregator(metric)
+	if err != nil {
+		return nil, err
+	}
+	if step <= 0 || !to.After(from) {
+		return nil, fmt.Errorf("step and window must be positive")
+	}
+	count := int((to.Sub(from) + step - 1) / step)
+	if count > MaxSeriesPoints {
+		return nil, fmt.Errorf("window/step gives %d points, at most %d are allowed", count, MaxSeriesPoints)
+	}
+
+	points := make([]Point, 0, count)
+	next := 0
+	for stepStart := from; stepStart.Before(to); stepStart = stepStart.Add(step) {
+		stepEnd := minTime(stepStart.Add(step), to)
+		agg := newStep()
+
+		// Start at the observation in effect at the step start
+		for next+1 < len(observations) && !observations[next+1].Time.After(stepStart) {
+			next++
+		}
+		for i := next; i < len(observations); i++ {
+			start := maxTime(observations[i].Time, stepStart)
+			end := stepEnd
+			if i+1 < len(observations) {
+				end = minTime(observations[i+1].Time, stepEnd)
+			}
+			if !start.Before(stepEnd) {
+				break
+			}
+			if end.After(start) {
+				agg.segment(observations[i], start, end)
+			}
+		}
+		points = append(points, Point{Time: stepStart, Value: agg.value()})
+	}
+	return points, nil
+}
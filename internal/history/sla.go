@@ -0,0 +1,113 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// SecretSLA is the sync freshness of one secret over a report window
+type SecretSLA struct {
+	Secret string `json:"secret"`
+	// ObservedSeconds is the part of the window covered by observations
+	ObservedSeconds float64 `json:"observedSeconds"`
+	// WithinSLAPercent is the share of observed time the secret was synced within the maximum age
+	WithinSLAPercent float64 `json:"withinSlaPercent"`
+	// LongestStaleSeconds is the longest continuous period outside the SLA
+	LongestStaleSeconds float64 `json:"longestStaleSeconds"`
+	// Failures counts transitions into a failed sync or a missing secret
+	Failures int    `json:"failures"`
+	LastSync string `json:"lastSync,omitempty"`
+}
+
+// failing reports whether the secret was missing or its sync had failed
+func (o SyncObservation) failing() bool {
+	return !o.Found || o.Status == "False"
+}
+
+// freshUntil returns when the observed state stops meeting the SLA; zero when it never meets it
+func (o SyncObservation) freshUntil(maxAge time.Duration) time.Time {
+	if o.failing() {
+		return time.Time{}
+	}
+	lastSync, err := time.Parse(time.RFC3339, o.LastSync)
+	if err != nil {
+		return time.Time{}
+	}
+	return lastSync.Add(maxAge)
+}
+
+// ComputeSLA evaluates each secret's observations over [from, to)
+// A secret meets the SLA while it exists, its sync condition isn't False, and its last
+// successful sync is at most maxAge old. Time before a secret's first observation is not counted.
+func ComputeSLA(observations map[string][]SyncObservation, from, to time.Time, maxAge time.Duration) []SecretSLA {
+	report := make([]SecretSLA, 0, len(observations))
+	for secret, list := range observations {
+		report = append(report, secretSLA(secret, list, from, to, maxAge))
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Secret < report[j].Secret
+	})
+	return report
+}
+
+// secretSLA evaluates the time-ordered observations of one secret
+func secretSLA(secret string, observations []SyncObservation, from, to time.Time, maxAge time.Duration) SecretSLA {
+	result := SecretSLA{Secret: secret}
+	var observed, fresh, streak, longest time.Duration
+	var previous *SyncObservation
+
+	for i := range observations {
+		current := observations[i]
+		start := maxTime(current.Time, from)
+		end := to
+		if i+1 < len(observations) {
+			end = minTime(observations[i+1].Time, to)
+		}
+
+		if !current.Time.Before(from) && current.failing() && (previous == nil || !previous.failing()) {
+			result.Failures++
+		}
+		previous = &observations[i]
+		result.LastSync = current.LastSync
+
+		if !end.After(start) {
+			continue
+		}
+
+		// Within one observation the secret is fresh first, then stale once its sync ages out
+		freshEnd := minTime(maxTime(current.freshUntil(maxAge), start), end)
+		freshPart := freshEnd.Sub(start)
+		stalePart := end.Sub(freshEnd)
+
+		observed += end.Sub(start)
+		fresh += freshPart
+		if freshPart > 0 {
+			streak = 0
+		}
+		streak += stalePart
+		longest = max(longest, streak)
+	}
+
+	result.ObservedSeconds = observed.Seconds()
+	result.LongestStaleSeconds = longest.Seconds()
+	if observed > 0 {
+		result.WithinSLAPercent = 100 * fresh.Seconds() / observed.Seconds()
+	}
+	return result
+}
+
+// minTime returns the earlier of two times
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// maxTime returns the later of two times
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,161 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/logging"
+)
+
+// Event types passed to hooks in BW_EVENT
+const (
+	EventDataChanged   = "data-changed"
+	EventSyncFailed    = "sync-failed"
+	EventSyncRecovered = "sync-recovered"
+	EventFlapping      = "flapping"
+	EventFlapStopped   = "flapping-stopped"
+	EventAlertFiring   = "alert-firing"
+	EventAlertResolved = "alert-resolved"
+	EventSecretDeleted = "secret-deleted"
+)
+
+// maxOutput bounds how much command output is kept in the audit log
+const maxOutput = 4096
+
+// queueSize is the number of events that may wait for the hook worker
+const queueSize = 64
+
+// Event describes a change to a watched secret
+type Event struct {
+	Type      string
+	Secret    string
+	Namespace string
+	Group     string
+	Detail    string
+	Time      time.Time
+	// Rule and Severity are set for alert rule events
+	Rule     string
+	Severity string
+}
+
+// Runner executes the configured command for each event, one at a time
+type Runner struct {
+	command string
+	timeout time.Duration
+	audit   *audit.Logger
+	queue   chan Event
+}
+
+// NewRunner creates a runner for the shell command; it returns nil when command is empty
+func NewRunner(command string, timeout time.Duration, auditLogger *audit.Logger) *Runner {
+	if command == "" {
+		return nil
+	}
+	r := &Runner{
+		command: command,
+		timeout: timeout,
+		audit:   auditLogger,
+		queue:   make(chan Event, queueSize),
+	}
+	go r.run()
+	return r
+}
+
+// Enabled reports whether a hook command is configured
+func (r *Runner) Enabled() bool {
+	return r != nil
+}
+
+// Notify queues an event without blocking; events are dropped when the queue is full
+func (r *Runner) Notify(event Event) {
+	if r == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	select {
+	case r.queue <- event:
+	default:
+		logging.Printf("Hook queue full, dropping %s event for %s", event.Type, event.Secret)
+	}
+}
+
+// run executes queued events sequentially
+func (r *Runner) run() {
+	for event := range r.queue {
+		r.execute(event)
+	}
+}
+
+// execute runs the command for one event and records the result in the audit log
+func (r *Runner) execute(event Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", r.command)
+	cmd.Env = append(os.Environ(),
+		"BW_EVENT="+event.Type,
+		"BW_SECRET_NAME="+event.Secret,
+		"BW_SECRET_NAMESPACE="+event.Namespace,
+		"BW_SECRET_GROUP="+event.Group,
+		"BW_EVENT_DETAIL="+event.Detail,
+		"BW_EVENT_TIME="+event.Time.Format(time.RFC3339),
+		"BW_ALERT_RULE="+event.Rule,
+		"BW_ALERT_SEVERITY="+event.Severity,
+	)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Don't wait for background children still holding the output pipe after a timeout
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+
+	details := map[string]string{
+		"event":    event.Type,
+		"command":  r.command,
+		"duration": time.Since(start).Round(time.Millisecond).String(),
+		"output":   truncate(logging.Scrub(output.String())),
+	}
+	outcome := audit.OutcomeSuccess
+	if err == nil {
+		details["exitCode"] = "0"
+	} else {
+		outcome = audit.OutcomeFailure
+		var exitErr *exec.ExitError
+		switch {
+		case ctx.Err() != nil:
+			details["error"] = fmt.Sprintf("timed out after %s", r.timeout)
+		case errors.As(err, &exitErr):
+			details["exitCode"] = fmt.Sprint(exitErr.ExitCode())
+		default:
+			details["error"] = err.Error()
+		}
+		logging.Printf("Hook for %s event on %s failed: %v", event.Type, event.Secret, err)
+	}
+
+	r.audit.Record(audit.Event{
+		Action:    "hook.exec",
+		Actor:     "system",
+		Resource:  event.Secret,
+		Namespace: event.Namespace,
+		Outcome:   outcome,
+		Details:   details,
+	})
+}
+
+// truncate keeps at most maxOutput bytes of command output
+func truncate(output string) string {
+	if len(output) <= maxOutput {
+		return output
+	}
+	return output[:maxOutput] + "... (truncated)"
+}
@@ -0,0 +1,173 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is the language messages are written in
+const DefaultLanguage = "en"
+
+//go:embed locales/*.json
+var builtinLocales embed.FS
+
+// Catalogs holds the message translations for each language
+// Messages are written in English and used as their own keys, so a missing translation falls back to English
+type Catalogs struct {
+	fallback string
+	messages map[string]map[string]string
+}
+
+// Load reads the built-in catalogs and the <language>.json files in dir, which add to or override them,
+// and uses lang (a LANG value such as "de_DE.UTF-8" or a tag such as "de") when a request names no known language
+// The returned catalogs are usable even with an error, holding everything that could be read
+func Load(lang, dir string) (*Catalogs, error) {
+	c := &Catalogs{fallback: DefaultLanguage, messages: map[string]map[string]string{DefaultLanguage: {}}}
+	if err := c.loadFS(builtinLocales, "locales"); err != nil {
+		return c, fmt.Errorf("failed to load built-in catalogs: %w", err)
+	}
+	var err error
+	if dir != "" {
+		if loadErr := c.loadFS(os.DirFS(dir), "."); loadErr != nil {
+			err = fmt.Errorf("failed to load catalogs from %s: %w", dir, loadErr)
+		}
+	}
+	if match := c.Match(ParseLANG(lang)); match != "" {
+		c.fallback = match
+	}
+	return c, err
+}
+
+// loadFS merges the catalogs in a directory of an fs.FS
+func (c *Catalogs) loadFS(fsys fs.FS, dir string) error {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		lang := normalizeTag(strings.TrimSuffix(path.Base(name), ".json"))
+		if c.messages[lang] == nil {
+			c.messages[lang] = make(map[string]string, len(messages))
+		}
+		for message, translation := range messages {
+			c.messages[lang][message] = translation
+		}
+	}
+	return nil
+}
+
+// ParseLANG turns a POSIX locale such as "de_DE.UTF-8" into a language tag such as "de-de"; "C" and "POSIX" are English
+func ParseLANG(value string) string {
+	value, _, _ = strings.Cut(value, ".")
+	value, _, _ = strings.Cut(value, "@")
+	if value == "" || value == "C" || value == "POSIX" {
+		return DefaultLanguage
+	}
+	return normalizeTag(value)
+}
+
+// normalizeTag lowercases a language tag and uses dashes, as in "pt-br"
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// Match returns the catalog language for a tag: the tag itself, else its base language, else ""
+func (c *Catalogs) Match(tag string) string {
+	tag = normalizeTag(tag)
+	if _, ok := c.messages[tag]; ok {
+		return tag
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	if _, ok := c.messages[base]; ok {
+		return base
+	}
+	return ""
+}
+
+// Default returns the language used when a request names no known language
+func (c *Catalogs) Default() string {
+	return c.fallback
+}
+
+// Languages lists the languages with a catalog
+func (c *Catalogs) Languages() []string {
+	languages := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Negotiate picks the best language for an Accept-Language header, or the default
+func (c *Catalogs) Negotiate(acceptLanguage string) string {
+	type choice struct {
+		tag     string
+		quality float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality > 0 {
+			choices = append(choices, choice{tag: tag, quality: quality})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool {
+		return choices[i].quality > choices[j].quality
+	})
+	for _, choice := range choices {
+		if choice.tag == "*" {
+			break
+		}
+		if match := c.Match(choice.tag); match != "" {
+			return match
+		}
+	}
+	return c.fallback
+}
+
+// Translate returns the message in lang, formatted with args when given
+func (c *Catalogs) Translate(lang, message string, args ...interface{}) string {
+	if translation, ok := c.messages[lang][message]; ok && translation != "" {
+		message = translation
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Messages returns a copy of the catalog for lang, for clients that translate on their own
+func (c *Catalogs) Messages(lang string) map[string]string {
+	messages := make(map[string]string, len(c.messages[lang]))
+	for message, translation := range c.messages[lang] {
+		messages[message] = translation
+	}
+	return messages
+}
@@ -0,0 +1,96 @@
+package k8s
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultSyncTimeAnnotation is where the Bitwarden operator records when it last wrote a Secret
+const DefaultSyncTimeAnnotation = "bitwarden-secrets-operator.io/sync-time"
+
+// syncTimeAnnotations are the annotation keys tried in order for a Secret's sync time,
+// since operator versions and forks record it under different keys
+var syncTimeAnnotations = struct {
+	sync.RWMutex
+	keys []string
+}{keys: []string{DefaultSyncTimeAnnotation}}
+
+// SetSyncTimeAnnotations sets the sync-time annotation keys to try in order; an empty list keeps the default
+func SetSyncTimeAnnotations(keys []string) {
+	if len(keys) == 0 {
+		keys = []string{DefaultSyncTimeAnnotation}
+	}
+	syncTimeAnnotations.Lock()
+	defer syncTimeAnnotations.Unlock()
+	syncTimeAnnotations.keys = append([]string(nil), keys...)
+}
+
+// SyncTimeAnnotations returns the sync-time annotation keys in the order they are tried
+func SyncTimeAnnotations() []string {
+	syncTimeAnnotations.RLock()
+	defer syncTimeAnnotations.RUnlock()
+	return append([]string(nil), syncTimeAnnotations.keys...)
+}
+
+// metadataAnnotations are the extra annotation keys, or prefixes ending in "/", shown with a Secret's metadata
+var metadataAnnotations = struct {
+	sync.RWMutex
+	keys []string
+}{}
+
+// SetMetadataAnnotations sets the annotation keys, or prefixes ending in "/", shown besides the operator's
+func SetMetadataAnnotations(keys []string) {
+	metadataAnnotations.Lock()
+	defer metadataAnnotations.Unlock()
+	metadataAnnotations.keys = append([]string(nil), keys...)
+}
+
+// SelectAnnotations returns the operator's annotations and those set with SetMetadataAnnotations, or nil
+func SelectAnnotations(annotations map[string]string) map[string]string {
+	selected := GetOperatorAnnotations(annotations)
+	metadataAnnotations.RLock()
+	defer metadataAnnotations.RUnlock()
+	for key, value := range annotations {
+		for _, wanted := range metadataAnnotations.keys {
+			if key == wanted || strings.HasSuffix(wanted, "/") && strings.HasPrefix(key, wanted) {
+				if selected == nil {
+					selected = make(map[string]string)
+				}
+				selected[key] = value
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// operatorAnnotationPrefixes returns the annotation prefixes that belong to the operator:
+// those of the sync-time keys and the BitwardenSecret API group
+func operatorAnnotationPrefixes() []string {
+	prefixes := []string{BitwardenSecretGVR.Group + "/"}
+	for _, key := range SyncTimeAnnotations() {
+		if i := strings.Index(key, "/"); i > 0 {
+			prefixes = append(prefixes, key[:i+1])
+		}
+	}
+	return prefixes
+}
+
+// GetOperatorAnnotations returns a Secret's annotations set by the operator, or nil when there are none
+// Other annotations, such as kubectl's last-applied-configuration, may hold secret data and are left out
+func GetOperatorAnnotations(annotations map[string]string) map[string]string {
+	var operator map[string]string
+	prefixes := operatorAnnotationPrefixes()
+	for key, value := range annotations {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				if operator == nil {
+					operator = make(map[string]string)
+				}
+				operator[key] = value
+				break
+			}
+		}
+	}
+	return operator
+}
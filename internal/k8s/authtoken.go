@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AuthTokenExpiryAnnotation optionally records, as RFC3339, when the machine account token in an auth-token Secret expires
+const AuthTokenExpiryAnnotation = "bitwarden-reader.io/expires-at"
+
+// DefaultAuthTokenExpiryWarning is how long before expiry an auth token is reported as expiring
+const DefaultAuthTokenExpiryWarning = 7 * 24 * time.Hour
+
+// Auth-token states of a BitwardenSecret
+const (
+	AuthTokenOK       = "ok"
+	AuthTokenExpiring = "expiring"
+	AuthTokenExpired  = "expired"
+	// AuthTokenMissing means the referenced Secret doesn't exist, or none is referenced
+	AuthTokenMissing = "missing"
+	// AuthTokenKeyMissing means the Secret exists but the referenced key is absent or empty
+	AuthTokenKeyMissing = "key-missing"
+	// AuthTokenUnknown means the Secret could not be read, such as without permission
+	AuthTokenUnknown = "unknown"
+)
+
+// AuthTokenCheck is the state of the auth-token Secret a BitwardenSecret references; the token is never included
+type AuthTokenCheck struct {
+	SecretName string `json:"secretName"`
+	SecretKey  string `json:"secretKey"`
+	Status     string `json:"status"`
+	ExpiresAt  string `json:"expiresAt,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// Broken reports whether the operator can't authenticate with the token, so syncs fail
+func (c AuthTokenCheck) Broken() bool {
+	return c.Status == AuthTokenMissing || c.Status == AuthTokenKeyMissing || c.Status == AuthTokenExpired
+}
+
+// authTokenExpiryWarning is how long before expiry a token is reported as expiring
+var authTokenExpiryWarning = struct {
+	sync.RWMutex
+	window time.Duration
+}{window: DefaultAuthTokenExpiryWarning}
+
+// SetAuthTokenExpiryWarning sets how long before expiry an auth token is reported as expiring
+func SetAuthTokenExpiryWarning(window time.Duration) {
+	authTokenExpiryWarning.Lock()
+	defer authTokenExpiryWarning.Unlock()
+	authTokenExpiryWarning.window = window
+}
+
+// AuthTokenChecks remembers checked auth-token Secrets; BitwardenSecrets usually share one token,
+// so each referenced Secret is read once
+type AuthTokenChecks map[AuthTokenRef]AuthTokenCheck
+
+// Check returns the remembered check of ref, checking it with CheckAuthToken the first time
+func (checks AuthTokenChecks) Check(ctx context.Context, clientset kubernetes.Interface, namespace string, ref AuthTokenRef) AuthTokenCheck {
+	check, ok := checks[ref]
+	if !ok {
+		check = CheckAuthToken(ctx, clientset, namespace, ref.SecretName, ref.SecretKey)
+		checks[ref] = check
+	}
+	return check
+}
+
+// extractAuthToken extracts the auth-token Secret reference from the CRD spec
+func extractAuthToken(unstructuredObj *unstructured.Unstructured, info *CRDInfo) {
+	if name, found, err := unstructured.NestedString(unstructuredObj.Object, "spec", "authToken", "secretName"); err == nil && found {
+		info.AuthTokenSecretName = name
+	}
+	if key, found, err := unstructured.NestedString(unstructuredObj.Object, "spec", "authToken", "secretKey"); err == nil && found {
+		info.AuthTokenSecretKey = key
+	}
+}
+
+// CheckAuthToken checks that the auth-token Secret exists in namespace, holds a non-empty secretKey,
+// and, when it has the AuthTokenExpiryAnnotation, hasn't expired
+func CheckAuthToken(ctx context.Context, clientset kubernetes.Interface, namespace, secretName, secretKey string) AuthTokenCheck {
+	check := AuthTokenCheck{SecretName: secretName, SecretKey: secretKey}
+	if secretName == "" {
+		check.Status = AuthTokenMissing
+		check.Detail = "The BitwardenSecret has no spec.authToken.secretName"
+		return check
+	}
+	if clientset == nil {
+		check.Status = AuthTokenUnknown
+		check.Detail = "Kubernetes client not available"
+		return check
+	}
+
+	secret, err := ReadSecret(ctx, secretName, namespace, clientset)
+	if err != nil {
+		if IsSecretNotFound(err) {
+			check.Status = AuthTokenMissing
+			check.Detail = fmt.Sprintf("Auth token Secret '%s' not found", secretName)
+		} else {
+			check.Status = AuthTokenUnknown
+			check.Detail = fmt.Sprintf("Error reading auth token Secret: %v", err)
+		}
+		return check
+	}
+	hasToken := len(secret.Data[secretKey]) > 0 || secret.StringData[secretKey] != ""
+	WipeSecretData(secret.Data)
+	if !hasToken {
+		check.Status = AuthTokenKeyMissing
+		check.Detail = fmt.Sprintf("Auth token Secret '%s' has no value for key '%s'", secretName, secretKey)
+		return check
+	}
+
+	check.Status = AuthTokenOK
+	value, ok := secret.Annotations[AuthTokenExpiryAnnotation]
+	if !ok {
+		return check
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		check.Detail = fmt.Sprintf("Unreadable %s annotation %q", AuthTokenExpiryAnnotation, value)
+		return check
+	}
+	check.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	authTokenExpiryWarning.RLock()
+	window := authTokenExpiryWarning.window
+	authTokenExpiryWarning.RUnlock()
+	switch remaining := time.Until(expiresAt); {
+	case remaining <= 0:
+		check.Status = AuthTokenExpired
+		check.Detail = fmt.Sprintf("Auth token expired at %s", check.ExpiresAt)
+	case remaining <= window:
+		check.Status = AuthTokenExpiring
+		check.Detail = fmt.Sprintf("Auth token expires at %s", check.ExpiresAt)
+	}
+	return check
+}
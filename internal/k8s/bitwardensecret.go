@@ -0,0 +1,260 @@
+package k8s
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// uuidPattern matches the UUIDs Bitwarden uses for organization and secret IDs
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// SecretMapping maps a Bitwarden secret ID to a key in the Kubernetes Secret
+type SecretMapping struct {
+	BwSecretID    string `json:"bwSecretId"`
+	SecretKeyName string `json:"secretKeyName"`
+}
+
+// AuthTokenRef references the Secret holding the Bitwarden machine account token
+type AuthTokenRef struct {
+	SecretName string `json:"secretName"`
+	SecretKey  string `json:"secretKey"`
+}
+
+// BitwardenSecretSpec is the spec of a BitwardenSecret CRD
+type BitwardenSecretSpec struct {
+	OrganizationID string          `json:"organizationId"`
+	SecretName     string          `json:"secretName"`
+	Map            []SecretMapping `json:"map"`
+	AuthToken      AuthTokenRef    `json:"authToken"`
+}
+
+// FieldError describes a validation failure for a single field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidateBitwardenSecret validates a BitwardenSecret name and spec before it is sent to the API server
+func ValidateBitwardenSecret(name string, spec BitwardenSecretSpec) []FieldError {
+	var errs []FieldError
+
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		errs = append(errs, FieldError{Field: "name", Message: msg})
+	}
+	if !uuidPattern.MatchString(spec.OrganizationID) {
+		errs = append(errs, FieldError{Field: "spec.organizationId", Message: "must be a UUID"})
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(spec.SecretName) {
+		errs = append(errs, FieldError{Field: "spec.secretName", Message: msg})
+	}
+
+	if len(spec.Map) == 0 {
+		errs = append(errs, FieldError{Field: "spec.map", Message: "must contain at least one entry"})
+	}
+	seenKeys := make(map[string]bool)
+	for i, entry := range spec.Map {
+		field := fmt.Sprintf("spec.map[%d]", i)
+		if !uuidPattern.MatchString(entry.BwSecretID) {
+			errs = append(errs, FieldError{Field: field + ".bwSecretId", Message: "must be a UUID"})
+		}
+		for _, msg := range validation.IsConfigMapKey(entry.SecretKeyName) {
+			errs = append(errs, FieldError{Field: field + ".secretKeyName", Message: msg})
+		}
+		if seenKeys[entry.SecretKeyName] {
+			errs = append(errs, FieldError{Field: field + ".secretKeyName", Message: "duplicate key name"})
+		}
+		seenKeys[entry.SecretKeyName] = true
+	}
+
+	for _, msg := range validation.IsDNS1123Subdomain(spec.AuthToken.SecretName) {
+		errs = append(errs, FieldError{Field: "spec.authToken.secretName", Message: msg})
+	}
+	for _, msg := range validation.IsConfigMapKey(spec.AuthToken.SecretKey) {
+		errs = append(errs, FieldError{Field: "spec.authToken.secretKey", Message: msg})
+	}
+
+	return errs
+}
+
+// toUnstructuredSpec converts a spec into the map form used by the dynamic client
+func toUnstructuredSpec(spec BitwardenSecretSpec) map[string]interface{} {
+	mappings := make([]interface{}, 0, len(spec.Map))
+	for _, entry := range spec.Map {
+		mappings = append(mappings, map[string]interface{}{
+			"bwSecretId":    entry.BwSecretID,
+			"secretKeyName": entry.SecretKeyName,
+		})
+	}
+	return map[string]interface{}{
+		"organizationId": spec.OrganizationID,
+		"secretName":     spec.SecretName,
+		"map":            mappings,
+		"authToken": map[string]interface{}{
+			"secretName": spec.AuthToken.SecretName,
+			"secretKey":  spec.AuthToken.SecretKey,
+		},
+	}
+}
+
+// newBitwardenSecretObject builds an unstructured BitwardenSecret object
+func newBitwardenSecretObject(name, namespace string, spec BitwardenSecretSpec) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": toUnstructuredSpec(spec),
+		},
+	}
+	obj.SetAPIVersion(BitwardenSecretGVR.GroupVersion().String())
+	obj.SetKind(BitwardenSecretKind)
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	obj.SetLabels(map[string]string{
+		"app.kubernetes.io/created-by": "bitwarden-reader",
+	})
+	return obj
+}
+
+// dryRunOption returns the API server dry-run option list
+func dryRunOption(dryRun bool) []string {
+	if dryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+// CreateBitwardenSecret creates a BitwardenSecret CRD in the given namespace
+// When dryRun is set the API server validates and admits the object without persisting it
+func CreateBitwardenSecret(ctx context.Context, name, namespace string, spec BitwardenSecretSpec, dryRun bool, dynamicClient dynamic.Interface) (*unstructured.Unstructured, error) {
+	if dynamicClient == nil {
+		return nil, fmt.Errorf("dynamicClient is nil")
+	}
+
+	obj := newBitwardenSecretObject(name, namespace, spec)
+	created, err := dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace).Create(ctx, obj, metav1.CreateOptions{DryRun: dryRunOption(dryRun)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BitwardenSecret: %w", err)
+	}
+	return created, nil
+}
+
+// UpdateBitwardenSecret replaces the spec of an existing BitwardenSecret CRD
+func UpdateBitwardenSecret(ctx context.Context, name, namespace string, spec BitwardenSecretSpec, dryRun bool, dynamicClient dynamic.Interface) (*unstructured.Unstructured, error) {
+	if dynamicClient == nil {
+		return nil, fmt.Errorf("dynamicClient is nil")
+	}
+
+	existing, err := dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get BitwardenSecret: %w", err)
+	}
+
+	if err := unstructured.SetNestedField(existing.Object, toUnstructuredSpec(spec), "spec"); err != nil {
+		return nil, fmt.Errorf("failed to set spec: %w", err)
+	}
+
+	updated, err := dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace).Update(ctx, existing, metav1.UpdateOptions{DryRun: dryRunOption(dryRun)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update BitwardenSecret: %w", err)
+	}
+	return updated, nil
+}
+
+// DeleteBitwardenSecret deletes a BitwardenSecret CRD
+func DeleteBitwardenSecret(ctx context.Context, name, namespace string, dryRun bool, dynamicClient dynamic.Interface) error {
+	if dynamicClient == nil {
+		return fmt.Errorf("dynamicClient is nil")
+	}
+
+	err := dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{DryRun: dryRunOption(dryRun)})
+	if err != nil {
+		return fmt.Errorf("failed to delete BitwardenSecret: %w", err)
+	}
+	return nil
+}
+
+// ParseBitwardenSecretManifest parses a YAML or JSON BitwardenSecret manifest
+func ParseBitwardenSecretManifest(data []byte) (*unstructured.Unstructured, BitwardenSecretSpec, error) {
+	var spec BitwardenSecretSpec
+
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, spec, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(jsonData); err != nil {
+		return nil, spec, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if obj.GetKind() != BitwardenSecretKind {
+		return nil, spec, fmt.Errorf("expected kind %s, got %q", BitwardenSecretKind, obj.GetKind())
+	}
+	if obj.GroupVersionKind().Group != BitwardenSecretGVR.Group {
+		return nil, spec, fmt.Errorf("expected apiVersion %s, got %q", BitwardenSecretGVR.GroupVersion().String(), obj.GetAPIVersion())
+	}
+
+	rawSpec, found, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil || !found {
+		return nil, spec, fmt.Errorf("manifest has no spec")
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSpec, &spec); err != nil {
+		return nil, spec, fmt.Errorf("failed to decode spec: %w", err)
+	}
+	return obj, spec, nil
+}
+
+// DryRunBitwardenSecret submits the object to the API server as a dry-run create, or a dry-run
+// update when it already exists, and returns which operation was validated
+func DryRunBitwardenSecret(ctx context.Context, obj *unstructured.Unstructured, dynamicClient dynamic.Interface) (string, error) {
+	if dynamicClient == nil {
+		return "", fmt.Errorf("dynamicClient is nil")
+	}
+
+	resource := dynamicClient.Resource(BitwardenSecretGVR).Namespace(obj.GetNamespace())
+	existing, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get BitwardenSecret: %w", err)
+	}
+
+	if err != nil {
+		if _, err := resource.Create(ctx, obj, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}); err != nil {
+			return "create", fmt.Errorf("dry-run create failed: %w", err)
+		}
+		return "create", nil
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	if _, err := resource.Update(ctx, obj, metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}}); err != nil {
+		return "update", fmt.Errorf("dry-run update failed: %w", err)
+	}
+	return "update", nil
+}
+
+// StatusCauses extracts field-level causes from a Kubernetes API status error
+func StatusCauses(err error) []FieldError {
+	var statusErr *errors.StatusError
+	if !stderrors.As(err, &statusErr) || statusErr.ErrStatus.Details == nil {
+		return nil
+	}
+	causes := make([]FieldError, 0, len(statusErr.ErrStatus.Details.Causes))
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		causes = append(causes, FieldError{Field: cause.Field, Message: cause.Message})
+	}
+	return causes
+}
+
+// GetBitwardenSecret retrieves the full BitwardenSecret object from the namespace
+func GetBitwardenSecret(ctx context.Context, name, namespace string, dynamicClient dynamic.Interface) (*unstructured.Unstructured, error) {
+	if dynamicClient == nil {
+		return nil, fmt.Errorf("dynamicClient is nil")
+	}
+	return dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+}
@@ -2,9 +2,11 @@ package k8s
 
 import (
 	"fmt"
-	"log"
 	"os"
 
+	"bitwarden-reader/internal/logging"
+
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -15,6 +17,27 @@ import (
 type K8sClients struct {
 	Clientset    kubernetes.Interface
 	DynamicClient dynamic.Interface
+	// config is kept so impersonating clients can be derived from it
+	config *rest.Config
+	// dynamicConfig is set when the dynamic client authenticates differently, see UseTokenRequest
+	dynamicConfig *rest.Config
+	// namespaces holds clients with their own credentials per namespace, see UseNamespaceCredentials
+	namespaces map[string]*K8sClients
+}
+
+// ClientOptions tunes the Kubernetes API clients
+type ClientOptions struct {
+	// Protobuf negotiates protobuf for built-in resources such as Secrets; CRDs always use JSON
+	Protobuf bool
+	// QPS and Burst bound the client-side request rate; zero keeps client-go's defaults (5 and 10)
+	QPS   float32
+	Burst int
+	// RecordFile, when set, receives every API response with secret values redacted, one JSON line each
+	RecordFile string
+	// ReplayFile, when set, serves API calls from a recording instead of a cluster
+	ReplayFile string
+	// ReadOnly makes every client refuse API writes, see ErrReadOnly
+	ReadOnly bool
 }
 
 // findKubeconfigFile checks if any kubeconfig file exists in the loading rules precedence
@@ -49,7 +72,11 @@ func buildKubeconfig() (*rest.Config, error) {
 
 // NewK8sClient creates Kubernetes clients with in-cluster config or kubeconfig fallback
 // Returns (nil, nil) if no Kubernetes config is found (standalone mode)
-func NewK8sClient() (*K8sClients, error) {
+func NewK8sClient(opts ClientOptions) (*K8sClients, error) {
+	if opts.ReplayFile != "" {
+		return newReplayClients(opts.ReplayFile)
+	}
+
 	var config *rest.Config
 	var err error
 	var isInCluster bool
@@ -68,9 +95,40 @@ func NewK8sClient() (*K8sClients, error) {
 			return nil, nil
 		}
 	} else {
+		// The kubelet rotates the projected token; clients must keep BearerTokenFile set,
+		// which client-go re-reads every minute, rather than copying the token read at startup
 		isInCluster = true
 	}
 
+	// Recordings are JSON so secret values can be redacted and replayed
+	if opts.RecordFile != "" {
+		recording, err := newRecording(opts.RecordFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.Protobuf = false
+		config.Wrap(recording.wrap)
+		logging.Printf("Recording Kubernetes API responses to %s", opts.RecordFile)
+	}
+
+	// Derived clients copy the wrapped transport, so they are read-only too
+	if opts.ReadOnly {
+		config.Wrap(readOnlyWrap)
+		logging.Printf("Kubernetes clients are read-only")
+	}
+
+	// Apply client tuning; the dynamic client overrides the content type with JSON itself
+	if opts.Protobuf {
+		config.ContentType = runtime.ContentTypeProtobuf
+		config.AcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+	}
+	if opts.QPS > 0 {
+		config.QPS = opts.QPS
+	}
+	if opts.Burst > 0 {
+		config.Burst = opts.Burst
+	}
+
 	// Create clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -84,10 +142,11 @@ func NewK8sClient() (*K8sClients, error) {
 	}
 
 	// Log successful client creation
-	log.Printf("Successfully initialized Kubernetes clients (in-cluster: %v)", isInCluster)
+	logging.Printf("Successfully initialized Kubernetes clients (in-cluster: %v)", isInCluster)
 
 	return &K8sClients{
 		Clientset:    clientset,
 		DynamicClient: dynamicClient,
+		config:        config,
 	}, nil
 }
@@ -4,10 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
 	"time"
 
+	"bitwarden-reader/internal/logging"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -31,6 +32,9 @@ type CRDInfo struct {
 	SyncReason            string
 	SyncMessage           string
 	CRDCreationTime       string
+	// AuthTokenSecretName and AuthTokenSecretKey reference the Secret holding the machine account token
+	AuthTokenSecretName   string
+	AuthTokenSecretKey    string
 }
 
 // extractMetadata extracts metadata fields from the CRD
@@ -64,31 +68,31 @@ func extractConditionFields(conditionMap map[string]interface{}, info *CRDInfo)
 func extractConditions(unstructuredObj *unstructured.Unstructured, info *CRDInfo) {
 	conditions, found, err := unstructured.NestedSlice(unstructuredObj.Object, "status", "conditions")
 	if err != nil {
-		log.Printf("Error extracting conditions slice: %v", err)
+		logging.Printf("Error extracting conditions slice: %v", err)
 		return
 	}
 	if !found {
-		log.Printf("No conditions found in CRD status")
+		logging.Printf("No conditions found in CRD status")
 		return
 	}
 
 	for i, condition := range conditions {
 		conditionMap, ok := condition.(map[string]interface{})
 		if !ok {
-			log.Printf("Condition %d is not a map[string]interface{}", i)
+			logging.Printf("Condition %d is not a map[string]interface{}", i)
 			continue
 		}
 
 		conditionType, found, err := unstructured.NestedString(conditionMap, "type")
 		if err != nil {
-			log.Printf("Error extracting condition type: %v", err)
+			logging.Printf("Error extracting condition type: %v", err)
 			continue
 		}
 		if !found {
-			log.Printf("Condition %d has no type field", i)
+			logging.Printf("Condition %d has no type field", i)
 			continue
 		}
-		if conditionType != "SuccessfulSync" {
+		if conditionType != SuccessfulSyncCondition {
 			continue
 		}
 
@@ -108,16 +112,16 @@ func isAPIDiscoveryError(err error) bool {
 		strings.Contains(errMsg, "no matches for kind")
 }
 
-// checkAPIDiscovery verifies API discovery by attempting to list resources
+// checkAPIDiscovery verifies API discovery by attempting to list resources; results are cached by cachedDiscovery
 func checkAPIDiscovery(ctx context.Context, namespace string, dynamicClient dynamic.Interface) error {
-	_, listErr := dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace).List(ctx, metav1.ListOptions{Limit: 1})
+	_, listErr := crdResource(dynamicClient, namespace).List(ctx, metav1.ListOptions{Limit: 1})
 	if listErr != nil {
 		if isAPIDiscoveryError(listErr) {
 			return listErr
 		}
 		// If it's a permission error, continue to try Get() anyway
 		if !errors.IsForbidden(listErr) {
-			log.Printf("List check failed (non-forbidden): %v, continuing with Get()", listErr)
+			logging.Printf("List check failed (non-forbidden): %v, continuing with Get()", listErr)
 		}
 	}
 	return nil
@@ -125,17 +129,18 @@ func checkAPIDiscovery(ctx context.Context, namespace string, dynamicClient dyna
 
 // handleNotFoundError handles 404 errors by trying cluster-scoped access
 func handleNotFoundError(ctx context.Context, name, namespace string, dynamicClient dynamic.Interface) (*CRDInfo, error) {
-	log.Printf("CRD not found (404): %s/%s in namespace %s, trying cluster-scoped access", BitwardenSecretGVR.Group, name, namespace)
+	logging.Printf("CRD not found (404): %s/%s in namespace %s, trying cluster-scoped access", BitwardenSecretGVR.Group, name, namespace)
 
 	// Try cluster-scoped access
 	unstructuredObj, err := dynamicClient.Resource(BitwardenSecretGVR).Get(ctx, name, metav1.GetOptions{})
 	if err == nil {
+		rememberCRDScope(false)
 		return extractCRDInfo(unstructuredObj, name, namespace, "cluster-scoped"), nil
 	}
 
 	// Cluster-scoped also failed
 	if errors.IsNotFound(err) {
-		log.Printf("CRD not found: %s/%s (tried namespace %s and cluster-scoped)", BitwardenSecretGVR.Group, name, namespace)
+		logging.Printf("CRD not found: %s/%s (tried namespace %s and cluster-scoped)", BitwardenSecretGVR.Group, name, namespace)
 		return &CRDInfo{
 			CRDFound:    false,
 			SyncMessage: fmt.Sprintf("CRD not found: %s", name),
@@ -143,7 +148,7 @@ func handleNotFoundError(ctx context.Context, name, namespace string, dynamicCli
 	}
 
 	// Cluster-scoped failed with other error
-	log.Printf("Error reading CRD %s/%s (cluster-scoped): %v", BitwardenSecretGVR.Group, name, err)
+	logging.Printf("Error reading CRD %s/%s (cluster-scoped): %v", BitwardenSecretGVR.Group, name, err)
 	return &CRDInfo{
 		CRDFound:    false,
 		SyncMessage: fmt.Sprintf("Failed to get CRD (cluster-scoped): %v", err),
@@ -153,12 +158,14 @@ func handleNotFoundError(ctx context.Context, name, namespace string, dynamicCli
 // handleGetError processes errors from Get() operation
 func handleGetError(ctx context.Context, name, namespace string, err error, dynamicClient dynamic.Interface) (*CRDInfo, error) {
 	errMsg := err.Error()
-	log.Printf("ERROR reading CRD %s/%s in namespace %s: %v (type: %T, message: %s)",
+	logging.Printf("ERROR reading CRD %s/%s in namespace %s: %v (type: %T, message: %s)",
 		BitwardenSecretGVR.Group, name, namespace, err, err, errMsg)
 
 	// Check for API discovery errors first
 	if isAPIDiscoveryError(err) {
-		log.Printf("API resource discovery issue for %s/%s: %v", BitwardenSecretGVR.Group, name, err)
+		logging.Printf("API resource discovery issue for %s/%s: %v", BitwardenSecretGVR.Group, name, err)
+		// The cached result said the resource is served; check again on the next read
+		forgetDiscovery(dynamicClient)
 		return &CRDInfo{
 			CRDFound:    false,
 			SyncMessage: fmt.Sprintf("API group '%s' not discoverable. CRD may not be installed or API server hasn't discovered it yet. Error: %v", BitwardenSecretGVR.Group, err),
@@ -172,7 +179,7 @@ func handleGetError(ctx context.Context, name, namespace string, err error, dyna
 
 	// Check for permission errors
 	if errors.IsForbidden(err) {
-		log.Printf("Permission denied accessing CRD %s/%s: %v", BitwardenSecretGVR.Group, name, err)
+		logging.Printf("Permission denied accessing CRD %s/%s: %v", BitwardenSecretGVR.Group, name, err)
 		return &CRDInfo{
 			CRDFound:    false,
 			SyncMessage: fmt.Sprintf("Permission denied accessing CRD %s. Check RBAC permissions. Error: %v", name, err),
@@ -181,7 +188,7 @@ func handleGetError(ctx context.Context, name, namespace string, err error, dyna
 
 	// Check for other API-related errors
 	if errors.IsMethodNotSupported(err) || errors.IsInvalid(err) {
-		log.Printf("API group/resource issue: %v", err)
+		logging.Printf("API group/resource issue: %v", err)
 		return &CRDInfo{
 			CRDFound:    false,
 			SyncMessage: fmt.Sprintf("API group/resource issue: %v", err),
@@ -190,7 +197,7 @@ func handleGetError(ctx context.Context, name, namespace string, err error, dyna
 
 	// For unexpected errors, still return info with message (don't fail completely)
 	errorMsg := fmt.Sprintf("Failed to get CRD: %v", err)
-	log.Printf("Unexpected error reading CRD %s/%s in namespace %s: %s", BitwardenSecretGVR.Group, name, namespace, errorMsg)
+	logging.Printf("Unexpected error reading CRD %s/%s in namespace %s: %s", BitwardenSecretGVR.Group, name, namespace, errorMsg)
 	return &CRDInfo{
 		CRDFound:    false,
 		SyncMessage: errorMsg,
@@ -206,52 +213,85 @@ func GetBitwardenSecretCRD(ctx context.Context, name, namespace string, dynamicC
 
 	// Validate inputs
 	if dynamicClient == nil {
-		log.Printf("ERROR: DynamicClient is nil, cannot read CRD %s/%s", namespace, name)
+		logging.Printf("ERROR: DynamicClient is nil, cannot read CRD %s/%s", namespace, name)
 		info.SyncMessage = "DynamicClient not initialized"
 		return info, nil
 	}
 
 	if name == "" {
-		log.Printf("ERROR: CRD name is empty")
+		logging.Printf("ERROR: CRD name is empty")
 		info.SyncMessage = "CRD name is empty"
 		return info, nil
 	}
 
 	if namespace == "" {
-		log.Printf("ERROR: Namespace is empty for CRD %s", name)
+		logging.Printf("ERROR: Namespace is empty for CRD %s", name)
 		info.SyncMessage = "Namespace is empty"
 		return info, nil
 	}
 
-	log.Printf("Attempting to get CRD: group=%s, version=%s, resource=%s, name=%s, namespace=%s",
+	logging.Printf("Attempting to get CRD: group=%s, version=%s, resource=%s, name=%s, namespace=%s",
 		BitwardenSecretGVR.Group, BitwardenSecretGVR.Version, BitwardenSecretGVR.Resource, name, namespace)
 
-	// First, try to verify API discovery by listing resources (this helps refresh discovery cache)
-	if apiErr := checkAPIDiscovery(ctx, namespace, dynamicClient); apiErr != nil {
-		log.Printf("API discovery failed for group %s: %v", BitwardenSecretGVR.Group, apiErr)
+	// First verify the resource is served, reusing the cached discovery result within DISCOVERY_CACHE_TTL_SECONDS
+	if _, apiErr := cachedDiscovery(ctx, namespace, dynamicClient); apiErr != nil {
+		logging.Printf("API discovery failed for group %s: %v", BitwardenSecretGVR.Group, apiErr)
 		info.SyncMessage = fmt.Sprintf("API group '%s' not discoverable. CRD may not be installed or API server hasn't discovered it yet. Error: %v", BitwardenSecretGVR.Group, apiErr)
 		return info, nil
 	}
 
-	// Try namespace-scoped access first
-	unstructuredObj, err := dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	// Read with the remembered scope; while it is unknown, try namespace-scoped access first
+	scope := crdScope.Load()
+	unstructuredObj, err := crdResource(dynamicClient, namespace).Get(ctx, name, metav1.GetOptions{})
 	if err == nil {
-		return extractCRDInfo(unstructuredObj, name, namespace, "namespace-scoped"), nil
+		if scope == scopeUnknown {
+			rememberCRDScope(true)
+		}
+		return extractCRDInfo(unstructuredObj, name, namespace, scopeName(crdScope.Load())), nil
+	}
+
+	// With the scope known there is nowhere else to look for a missing CRD
+	if scope != scopeUnknown && errors.IsNotFound(err) && !isAPIDiscoveryError(err) {
+		logging.Printf("CRD not found: %s/%s in namespace %s", BitwardenSecretGVR.Group, name, namespace)
+		return &CRDInfo{
+			CRDFound:    false,
+			SyncMessage: fmt.Sprintf("CRD not found: %s", name),
+		}, nil
 	}
 
 	// Handle the error
 	return handleGetError(ctx, name, namespace, err, dynamicClient)
 }
 
-// extractCRDInfo extracts all information from a CRD unstructured object
-func extractCRDInfo(unstructuredObj *unstructured.Unstructured, name, namespace, scope string) *CRDInfo {
+// crdInfoFromObject extracts all information from a CRD unstructured object
+func crdInfoFromObject(unstructuredObj *unstructured.Unstructured) *CRDInfo {
 	info := &CRDInfo{
 		CRDFound: true,
 	}
 	extractMetadata(unstructuredObj, info)
 	extractStatusFields(unstructuredObj, info)
 	extractConditions(unstructuredObj, info)
-	log.Printf("Successfully read CRD %s/%s (%s): CRDFound=%v, LastSync=%s, Status=%s",
+	extractAuthToken(unstructuredObj, info)
+	return info
+}
+
+// ListBitwardenSecretCRDs reads every BitwardenSecret in a namespace with one List call, keyed by name
+func ListBitwardenSecretCRDs(ctx context.Context, namespace string, dynamicClient dynamic.Interface) (map[string]*CRDInfo, error) {
+	list, err := crdResource(dynamicClient, namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	infos := make(map[string]*CRDInfo, len(list.Items))
+	for i := range list.Items {
+		infos[list.Items[i].GetName()] = crdInfoFromObject(&list.Items[i])
+	}
+	return infos, nil
+}
+
+// extractCRDInfo extracts all information from a CRD unstructured object and logs the result
+func extractCRDInfo(unstructuredObj *unstructured.Unstructured, name, namespace, scope string) *CRDInfo {
+	info := crdInfoFromObject(unstructuredObj)
+	logging.Printf("Successfully read CRD %s/%s (%s): CRDFound=%v, LastSync=%s, Status=%s",
 		BitwardenSecretGVR.Group, name, scope, info.CRDFound, info.LastSuccessfulSync, info.SyncStatus)
 	return info
 }
@@ -262,22 +302,38 @@ func PatchCRDAnnotation(ctx context.Context, name, namespace string, annotations
 		return fmt.Errorf("dynamicClient is nil")
 	}
 
-	// Try namespace-scoped first, then cluster-scoped
-	unstructuredObj, err := dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
-	isClusterScoped := false
+	resource, unstructuredObj, err := getCRDForPatch(ctx, name, namespace, dynamicClient)
+	if err != nil {
+		return err
+	}
+	return mergeCRDAnnotations(ctx, resource, unstructuredObj, annotations, "")
+}
+
+// getCRDForPatch reads the CRD and returns it with the resource it was found in
+func getCRDForPatch(ctx context.Context, name, namespace string, dynamicClient dynamic.Interface) (dynamic.ResourceInterface, *unstructured.Unstructured, error) {
+	// Use the remembered scope; while it is unknown, try namespace-scoped first, then cluster-scoped
+	scope := crdScope.Load()
+	resource := crdResource(dynamicClient, namespace)
+	unstructuredObj, err := resource.Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
-		if errors.IsNotFound(err) {
-			// Try cluster-scoped
-			unstructuredObj, err = dynamicClient.Resource(BitwardenSecretGVR).Get(ctx, name, metav1.GetOptions{})
-			if err != nil {
-				return fmt.Errorf("failed to get CRD (tried namespace and cluster-scoped): %w", err)
-			}
-			isClusterScoped = true
-		} else {
-			return fmt.Errorf("failed to get CRD: %w", err)
+		if scope != scopeUnknown || !errors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("failed to get CRD: %w", err)
 		}
+		resource = dynamicClient.Resource(BitwardenSecretGVR)
+		unstructuredObj, err = resource.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get CRD (tried namespace and cluster-scoped): %w", err)
+		}
+		rememberCRDScope(false)
+	} else if scope == scopeUnknown {
+		rememberCRDScope(true)
 	}
+	return resource, unstructuredObj, nil
+}
 
+// mergeCRDAnnotations merges annotations into the CRD read with getCRDForPatch
+// With a resourceVersion the patch only applies if the CRD is unchanged since, failing with a conflict otherwise
+func mergeCRDAnnotations(ctx context.Context, resource dynamic.ResourceInterface, unstructuredObj *unstructured.Unstructured, annotations map[string]string, resourceVersion string) error {
 	// Get and merge annotations
 	currentAnnotations, found, err := unstructured.NestedStringMap(unstructuredObj.Object, "metadata", "annotations")
 	if err != nil {
@@ -294,10 +350,14 @@ func PatchCRDAnnotation(ctx context.Context, name, namespace string, annotations
 	}
 
 	// Create patch
+	metadata := map[string]interface{}{
+		"annotations": currentAnnotations,
+	}
+	if resourceVersion != "" {
+		metadata["resourceVersion"] = resourceVersion
+	}
 	patch := map[string]interface{}{
-		"metadata": map[string]interface{}{
-			"annotations": currentAnnotations,
-		},
+		"metadata": metadata,
 	}
 
 	patchBytes, err := json.Marshal(patch)
@@ -305,13 +365,8 @@ func PatchCRDAnnotation(ctx context.Context, name, namespace string, annotations
 		return fmt.Errorf("failed to marshal patch: %w", err)
 	}
 
-	// Apply patch (namespace-scoped or cluster-scoped)
-	if isClusterScoped {
-		_, err = dynamicClient.Resource(BitwardenSecretGVR).Patch(ctx, name, types.MergePatchType, patchBytes, metav1.PatchOptions{})
-	} else {
-		_, err = dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace).Patch(ctx, name, types.MergePatchType, patchBytes, metav1.PatchOptions{})
-	}
-
+	// Apply patch with the scope the CRD was read from
+	_, err = resource.Patch(ctx, unstructuredObj.GetName(), types.MergePatchType, patchBytes, metav1.PatchOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to patch CRD: %w", err)
 	}
@@ -319,10 +374,26 @@ func PatchCRDAnnotation(ctx context.Context, name, namespace string, annotations
 	return nil
 }
 
-// TriggerSync patches the CRD with force-sync annotation
-func TriggerSync(ctx context.Context, name, namespace string, dynamicClient dynamic.Interface) error {
-	annotations := map[string]string{
-		"k8s.bitwarden.com/force-sync": time.Now().Format(time.RFC3339),
+// ForceSyncAnnotation is the annotation the operator watches for a sync request
+const ForceSyncAnnotation = "k8s.bitwarden.com/force-sync"
+
+// TriggerSync patches the CRD with the current time under each force-sync key, alongside the extra annotations
+// Keys default to ForceSyncAnnotation; operator versions that watch another key can be targeted too
+func TriggerSync(ctx context.Context, name, namespace string, keys []string, extra map[string]string, dynamicClient dynamic.Interface) error {
+	return PatchCRDAnnotation(ctx, name, namespace, syncAnnotations(keys, extra, time.Now()), dynamicClient)
+}
+
+// syncAnnotations returns the extra annotations with the trigger time under each force-sync key
+func syncAnnotations(keys []string, extra map[string]string, now time.Time) map[string]string {
+	if len(keys) == 0 {
+		keys = []string{ForceSyncAnnotation}
+	}
+	annotations := make(map[string]string, len(keys)+len(extra))
+	for key, value := range extra {
+		annotations[key] = value
+	}
+	for _, key := range keys {
+		annotations[key] = now.Format(time.RFC3339)
 	}
-	return PatchCRDAnnotation(ctx, name, namespace, annotations, dynamicClient)
+	return annotations
 }
@@ -0,0 +1,106 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// SuccessfulSyncCondition is the BitwardenSecret condition type the sync state is read from
+const SuccessfulSyncCondition = "SuccessfulSync"
+
+// customResourceDefinitionGVR is read with the dynamic client, which avoids a dependency on the apiextensions clientset
+var customResourceDefinitionGVR = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// CRDVersion is one version of the BitwardenSecret CRD
+type CRDVersion struct {
+	Name       string `json:"name"`
+	Served     bool   `json:"served"`
+	Storage    bool   `json:"storage"`
+	Deprecated bool   `json:"deprecated,omitempty"`
+	// StatusFields are the status properties declared by the version's schema
+	StatusFields []string `json:"statusFields,omitempty"`
+}
+
+// CRDDefinition describes the installed BitwardenSecret CustomResourceDefinition
+type CRDDefinition struct {
+	Name     string       `json:"name"`
+	Versions []CRDVersion `json:"versions"`
+	// StoredVersions lists every version objects were ever stored as, from the CRD's status
+	StoredVersions []string `json:"storedVersions"`
+}
+
+// Version returns the definition of a version, or nil when the CRD doesn't have it
+func (d *CRDDefinition) Version(name string) *CRDVersion {
+	for i := range d.Versions {
+		if d.Versions[i].Name == name {
+			return &d.Versions[i]
+		}
+	}
+	return nil
+}
+
+// GetBitwardenSecretDefinition reads the BitwardenSecret CustomResourceDefinition
+func GetBitwardenSecretDefinition(ctx context.Context, dynamicClient dynamic.Interface) (*CRDDefinition, error) {
+	name := BitwardenSecretGVR.Resource + "." + BitwardenSecretGVR.Group
+	obj, err := dynamicClient.Resource(customResourceDefinitionGVR).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CustomResourceDefinition %s: %w", name, err)
+	}
+
+	definition := &CRDDefinition{Name: name}
+	definition.StoredVersions, _, _ = unstructured.NestedStringSlice(obj.Object, "status", "storedVersions")
+	versions, _, _ := unstructured.NestedSlice(obj.Object, "spec", "versions")
+	for _, item := range versions {
+		version, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		v := CRDVersion{}
+		v.Name, _, _ = unstructured.NestedString(version, "name")
+		v.Served, _, _ = unstructured.NestedBool(version, "served")
+		v.Storage, _, _ = unstructured.NestedBool(version, "storage")
+		v.Deprecated, _, _ = unstructured.NestedBool(version, "deprecated")
+		if properties, found, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema", "properties", "status", "properties"); found {
+			for field := range properties {
+				v.StatusFields = append(v.StatusFields, field)
+			}
+			sort.Strings(v.StatusFields)
+		}
+		definition.Versions = append(definition.Versions, v)
+	}
+	return definition, nil
+}
+
+// ConditionTypes counts the BitwardenSecrets in a namespace reporting each condition type
+func ConditionTypes(ctx context.Context, namespace string, dynamicClient dynamic.Interface) (map[string]int, error) {
+	list, err := crdResource(dynamicClient, namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]int)
+	for _, item := range list.Items {
+		conditions, _, _ := unstructured.NestedSlice(item.Object, "status", "conditions")
+		seen := make(map[string]bool)
+		for _, condition := range conditions {
+			conditionMap, ok := condition.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if conditionType, found, _ := unstructured.NestedString(conditionMap, "type"); found && !seen[conditionType] {
+				seen[conditionType] = true
+				types[conditionType]++
+			}
+		}
+	}
+	return types, nil
+}
@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"bitwarden-reader/internal/logging"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Kinds of per-namespace credentials
+const (
+	// CredentialContext names a kubeconfig context, as in "context:apps-reader"
+	CredentialContext = "context"
+	// CredentialToken names a service account token file used against the same API server, as in "token:/var/run/secrets/apps/token"
+	CredentialToken = "token"
+)
+
+// UseNamespaceCredentials gives each listed namespace its own clients, so the reader can hold narrow
+// per-namespace grants instead of one broad service account; ForNamespace picks them per read
+func (c *K8sClients) UseNamespaceCredentials(credentials map[string]string) error {
+	if len(credentials) == 0 {
+		return nil
+	}
+	if c.config == nil {
+		return fmt.Errorf("namespace credentials require clients created by NewK8sClient")
+	}
+
+	namespaces := make(map[string]*K8sClients, len(credentials))
+	for namespace, credential := range credentials {
+		config, err := c.credentialConfig(credential)
+		if err != nil {
+			return fmt.Errorf("credentials for namespace %s: %w", namespace, err)
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create clientset for namespace %s: %w", namespace, err)
+		}
+		dynamicClient, err := dynamic.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create dynamic client for namespace %s: %w", namespace, err)
+		}
+		namespaces[namespace] = &K8sClients{Clientset: clientset, DynamicClient: dynamicClient, config: config}
+		logging.Printf("Namespace %s uses its own credentials (%s)", namespace, credential)
+	}
+	c.namespaces = namespaces
+	return nil
+}
+
+// credentialConfig builds the client config for a "context:<name>" or "token:<path>" credential,
+// keeping the base config's content type and rate limits
+func (c *K8sClients) credentialConfig(credential string) (*rest.Config, error) {
+	kind, value, ok := strings.Cut(credential, ":")
+	if !ok || value == "" {
+		return nil, fmt.Errorf("credential %q must be context:<name> or token:<path>", credential)
+	}
+
+	var config *rest.Config
+	switch kind {
+	case CredentialContext:
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: value}
+		contextConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig context %s: %w", value, err)
+		}
+		config = contextConfig
+		config.ContentType = c.config.ContentType
+		config.AcceptContentTypes = c.config.AcceptContentTypes
+		config.QPS = c.config.QPS
+		config.Burst = c.config.Burst
+		config.Wrap(c.config.WrapTransport)
+	case CredentialToken:
+		if _, err := os.Stat(value); err != nil {
+			return nil, fmt.Errorf("failed to read token file: %w", err)
+		}
+		// Same API server and CA, but only the token authenticates
+		config = rest.AnonymousClientConfig(c.config)
+		config.BearerTokenFile = value
+		config.WrapTransport = c.config.WrapTransport
+	default:
+		return nil, fmt.Errorf("unknown credential kind %q (use context or token)", kind)
+	}
+	return config, nil
+}
+
+// ForNamespace returns the clients configured for namespace, or c itself
+// Impersonating clients have no namespace credentials, so they always return themselves
+func (c *K8sClients) ForNamespace(namespace string) *K8sClients {
+	if c == nil {
+		return nil
+	}
+	if clients, ok := c.namespaces[namespace]; ok {
+		return clients
+	}
+	return c
+}
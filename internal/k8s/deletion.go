@@ -0,0 +1,83 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// garbageCollector is the actor reported when a Secret went with its deleted owner
+const garbageCollector = "garbage-collector"
+
+// DeletionActor works out who deleted a Secret, best effort, returning the actor and a detail
+// It looks for a Kubernetes Event about the deletion first, then for a BitwardenSecret owner
+// that is gone too, which means the garbage collector removed the Secret. Both are empty when unknown
+// Events need list permission on events, which is optional; without it only ownership is checked
+func DeletionActor(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace, name string, owners []metav1.OwnerReference) (string, string) {
+	if actor, detail := deletionEvent(ctx, clientset, namespace, name); actor != "" {
+		return actor, detail
+	}
+	if dynamicClient == nil {
+		return "", ""
+	}
+	for _, owner := range owners {
+		if owner.Kind != BitwardenSecretKind {
+			continue
+		}
+		info, err := GetBitwardenSecretCRD(ctx, owner.Name, namespace, dynamicClient)
+		if err == nil && info != nil && !info.CRDFound {
+			return garbageCollector, "owner BitwardenSecret " + owner.Name + " was deleted"
+		}
+	}
+	return "", ""
+}
+
+// deletionEvent returns the reporting component and message of the newest Event about deleting the Secret
+func deletionEvent(ctx context.Context, clientset kubernetes.Interface, namespace, name string) (string, string) {
+	if clientset == nil {
+		return "", ""
+	}
+	selector := fields.Set{"involvedObject.kind": "Secret", "involvedObject.name": name}.AsSelector().String()
+	events, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return "", ""
+	}
+	var newest *corev1.Event
+	for i := range events.Items {
+		event := &events.Items[i]
+		if !strings.Contains(strings.ToLower(event.Reason+" "+event.Message), "delet") {
+			continue
+		}
+		if newest == nil || eventTime(event).After(eventTime(newest).Time) {
+			newest = event
+		}
+	}
+	if newest == nil {
+		return "", ""
+	}
+	actor := newest.ReportingController
+	if actor == "" {
+		actor = newest.Source.Component
+	}
+	if actor == "" {
+		actor = "unknown"
+	}
+	return actor, newest.Message
+}
+
+// eventTime returns when an Event last happened
+func eventTime(event *corev1.Event) metav1.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp
+	case !event.EventTime.IsZero():
+		return metav1.NewTime(event.EventTime.Time)
+	default:
+		return event.CreationTimestamp
+	}
+}
@@ -0,0 +1,89 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+)
+
+// DefaultDiscoveryTTL is how long a discovery result is reused when SetDiscoveryTTL is not called
+const DefaultDiscoveryTTL = 5 * time.Minute
+
+// discoveryEntry is the outcome of one discovery check
+type discoveryEntry struct {
+	err     error
+	checked time.Time
+}
+
+// discoveryCache remembers per client whether the BitwardenSecret resource is served,
+// so CRD reads do not each start with a List call
+var discoveryCache = struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[dynamic.Interface]discoveryEntry
+}{ttl: DefaultDiscoveryTTL}
+
+// SetDiscoveryTTL sets how long discovery results are reused; 0 checks on every CRD read
+func SetDiscoveryTTL(ttl time.Duration) {
+	discoveryCache.Lock()
+	defer discoveryCache.Unlock()
+	discoveryCache.ttl = ttl
+}
+
+// InvalidateDiscovery drops every cached discovery result, so the next CRD read checks again
+func InvalidateDiscovery() {
+	discoveryCache.Lock()
+	defer discoveryCache.Unlock()
+	discoveryCache.entries = nil
+}
+
+// DiscoveryStatus is a cached discovery result
+type DiscoveryStatus struct {
+	Served  bool      `json:"served"`
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+// CheckDiscovery checks whether the BitwardenSecret resource is served, reusing a cached result within the TTL
+func CheckDiscovery(ctx context.Context, namespace string, dynamicClient dynamic.Interface) DiscoveryStatus {
+	checked, err := cachedDiscovery(ctx, namespace, dynamicClient)
+	status := DiscoveryStatus{Served: err == nil, Checked: checked}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// cachedDiscovery returns the cached discovery result for the client, checking again once it is older than the TTL
+func cachedDiscovery(ctx context.Context, namespace string, dynamicClient dynamic.Interface) (time.Time, error) {
+	now := time.Now()
+	discoveryCache.Lock()
+	entry, ok := discoveryCache.entries[dynamicClient]
+	ttl := discoveryCache.ttl
+	discoveryCache.Unlock()
+	if ok && now.Sub(entry.checked) < ttl {
+		return entry.checked, entry.err
+	}
+
+	entry = discoveryEntry{err: checkAPIDiscovery(ctx, namespace, dynamicClient), checked: now}
+	// A cancelled request says nothing about discovery
+	if ctx.Err() != nil {
+		return entry.checked, entry.err
+	}
+	discoveryCache.Lock()
+	if discoveryCache.entries == nil {
+		discoveryCache.entries = make(map[dynamic.Interface]discoveryEntry)
+	}
+	discoveryCache.entries[dynamicClient] = entry
+	discoveryCache.Unlock()
+	return entry.checked, entry.err
+}
+
+// forgetDiscovery drops the cached result for a client after a read showed it to be wrong
+func forgetDiscovery(dynamicClient dynamic.Interface) {
+	discoveryCache.Lock()
+	defer discoveryCache.Unlock()
+	delete(discoveryCache.entries, dynamicClient)
+}
@@ -0,0 +1,46 @@
+package k8s
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Impersonate returns clients that send impersonation headers for the user and groups,
+// so the API server authorizes each call with that user's RBAC instead of the service account's
+func (c *K8sClients) Impersonate(user string, groups []string) (*K8sClients, error) {
+	if c.config == nil {
+		return nil, fmt.Errorf("impersonation requires clients created by NewK8sClient")
+	}
+
+	config := rest.CopyConfig(c.config)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: user,
+		Groups:   groups,
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create impersonating clientset: %w", err)
+	}
+
+	// Keep the dynamic client's own authentication, such as TokenRequest tokens
+	dynamicConfig := config
+	if c.dynamicConfig != nil {
+		dynamicConfig = rest.CopyConfig(c.dynamicConfig)
+		dynamicConfig.Impersonate = config.Impersonate
+	}
+	dynamicClient, err := dynamic.NewForConfig(dynamicConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create impersonating dynamic client: %w", err)
+	}
+
+	return &K8sClients{
+		Clientset:     clientset,
+		DynamicClient: dynamicClient,
+		config:        config,
+		dynamicConfig: c.dynamicConfig,
+	}, nil
+}
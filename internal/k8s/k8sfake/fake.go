@@ -0,0 +1,34 @@
+// Package k8sfake provides Kubernetes clients served from memory, for the load test and benchmarks
+// It links client-go's fake clientsets, so the server binary must not import it
+package k8sfake
+
+import (
+	"bitwarden-reader/internal/k8s"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// NewClients returns clients served from memory instead of a cluster, holding the given objects
+// BitwardenSecrets are passed as unstructured objects and go to the dynamic client, everything else
+// to the clientset
+func NewClients(objects ...runtime.Object) *k8s.K8sClients {
+	var typed, untyped []runtime.Object
+	for _, obj := range objects {
+		if _, ok := obj.(*unstructured.Unstructured); ok {
+			untyped = append(untyped, obj)
+		} else {
+			typed = append(typed, obj)
+		}
+	}
+	listKinds := map[schema.GroupVersionResource]string{
+		k8s.BitwardenSecretGVR: "BitwardenSecretList",
+	}
+	return &k8s.K8sClients{
+		Clientset:     kubefake.NewSimpleClientset(typed...),
+		DynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, untyped...),
+	}
+}
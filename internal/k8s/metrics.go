@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+
+	"k8s.io/client-go/tools/metrics"
+)
+
+// throttleWarningInterval limits how often client-side throttling is logged
+const throttleWarningInterval = time.Minute
+
+// LatencyStats sums observed latencies per verb
+type LatencyStats struct {
+	Seconds map[string]float64
+	Count   map[string]float64
+}
+
+// ClientMetrics is a snapshot of client-go request metrics
+type ClientMetrics struct {
+	// Results counts responses by status code ("<error>" when no response was received)
+	Results map[string]float64
+	// Requests is the request latency, including rate limiter waits
+	Requests LatencyStats
+	// RateLimiter is the time spent waiting for the client-side rate limiter
+	RateLimiter LatencyStats
+	// Throttled counts requests that waited at least the warning threshold
+	Throttled float64
+}
+
+// clientMetrics collects client-go metrics; client-go allows registering only once per process
+var clientMetrics struct {
+	sync.Mutex
+	registered   bool
+	threshold    time.Duration
+	results      map[string]float64
+	requests     LatencyStats
+	rateLimiter  LatencyStats
+	throttled    float64
+	lastWarning  time.Time
+	sinceWarning int
+}
+
+// newLatencyStats returns empty per-verb latency sums
+func newLatencyStats() LatencyStats {
+	return LatencyStats{Seconds: make(map[string]float64), Count: make(map[string]float64)}
+}
+
+// observe adds one latency for the verb
+func (l LatencyStats) observe(verb string, latency time.Duration) {
+	l.Seconds[verb] += latency.Seconds()
+	l.Count[verb]++
+}
+
+// copy returns an independent copy of the sums
+func (l LatencyStats) copy() LatencyStats {
+	c := newLatencyStats()
+	for verb, seconds := range l.Seconds {
+		c.Seconds[verb] = seconds
+		c.Count[verb] = l.Count[verb]
+	}
+	return c
+}
+
+type requestLatency struct{}
+
+// Observe records the latency of one request
+func (requestLatency) Observe(_ context.Context, verb string, _ url.URL, latency time.Duration) {
+	clientMetrics.Lock()
+	defer clientMetrics.Unlock()
+	clientMetrics.requests.observe(verb, latency)
+}
+
+type rateLimiterLatency struct{}
+
+// Observe records one rate limiter wait and warns when the reader throttles itself
+func (rateLimiterLatency) Observe(_ context.Context, verb string, u url.URL, latency time.Duration) {
+	clientMetrics.Lock()
+	defer clientMetrics.Unlock()
+	clientMetrics.rateLimiter.observe(verb, latency)
+
+	if clientMetrics.threshold <= 0 || latency < clientMetrics.threshold {
+		return
+	}
+	clientMetrics.throttled++
+	clientMetrics.sinceWarning++
+	if time.Since(clientMetrics.lastWarning) < throttleWarningInterval {
+		return
+	}
+	logging.Printf("WARNING: Kubernetes client throttled itself: %s %s waited %s for the client-side rate limiter (%d throttled requests since the last warning); consider raising KUBE_CLIENT_QPS and KUBE_CLIENT_BURST",
+		verb, u.Path, latency.Round(time.Millisecond), clientMetrics.sinceWarning)
+	clientMetrics.lastWarning = time.Now()
+	clientMetrics.sinceWarning = 0
+}
+
+type requestResult struct{}
+
+// Increment counts one response by status code
+func (requestResult) Increment(_ context.Context, code, _, _ string) {
+	clientMetrics.Lock()
+	defer clientMetrics.Unlock()
+	clientMetrics.results[code]++
+}
+
+// RegisterClientMetrics starts collecting client-go request, rate limiter, and result metrics
+// Rate limiter waits of at least throttleWarning are counted and logged (0 disables the warning)
+func RegisterClientMetrics(throttleWarning time.Duration) {
+	clientMetrics.Lock()
+	clientMetrics.registered = true
+	clientMetrics.threshold = throttleWarning
+	clientMetrics.results = make(map[string]float64)
+	clientMetrics.requests = newLatencyStats()
+	clientMetrics.rateLimiter = newLatencyStats()
+	clientMetrics.Unlock()
+
+	metrics.Register(metrics.RegisterOpts{
+		RequestLatency:     requestLatency{},
+		RateLimiterLatency: rateLimiterLatency{},
+		RequestResult:      requestResult{},
+	})
+}
+
+// ClientMetricsSnapshot returns the collected metrics, or false if RegisterClientMetrics was not called
+func ClientMetricsSnapshot() (ClientMetrics, bool) {
+	clientMetrics.Lock()
+	defer clientMetrics.Unlock()
+
+	if !clientMetrics.registered {
+		return ClientMetrics{}, false
+	}
+	results := make(map[string]float64, len(clientMetrics.results))
+	for code, count := range clientMetrics.results {
+		results[code] = count
+	}
+	return ClientMetrics{
+		Results:     results,
+		Requests:    clientMetrics.requests.copy(),
+		RateLimiter: clientMetrics.rateLimiter.copy(),
+		Throttled:   clientMetrics.throttled,
+	}, true
+}
@@ -0,0 +1,111 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// BitwardenSecretKind is the kind of the BitwardenSecret CRD that owns synced Secrets
+const BitwardenSecretKind = "BitwardenSecret"
+
+// ListNamespaces returns the names of all namespaces visible to the client
+func ListNamespaces(ctx context.Context, clientset kubernetes.Interface) ([]string, error) {
+	list, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Namespace states that make every Secret in the namespace unavailable
+const (
+	NamespaceTerminating = "Terminating"
+	NamespaceAbsent      = "Absent"
+)
+
+// NamespaceStatus is a namespace whose Secrets can't be read because it is terminating or doesn't exist
+type NamespaceStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// DeletionTimestamp is when deletion of a terminating namespace was requested
+	DeletionTimestamp string `json:"deletionTimestamp,omitempty"`
+	Message           string `json:"message"`
+}
+
+// GetNamespaceStatus returns the namespace's status when it is terminating or absent, and nil while it is
+// active or can't be read, such as without permission to get namespaces
+func GetNamespaceStatus(ctx context.Context, clientset kubernetes.Interface, namespace string) *NamespaceStatus {
+	if clientset == nil || namespace == "" {
+		return nil
+	}
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return &NamespaceStatus{
+			Name:    namespace,
+			State:   NamespaceAbsent,
+			Message: fmt.Sprintf("Namespace '%s' not found", namespace),
+		}
+	case err != nil:
+		return nil
+	case ns.Status.Phase == corev1.NamespaceTerminating || ns.DeletionTimestamp != nil:
+		status := &NamespaceStatus{
+			Name:    namespace,
+			State:   NamespaceTerminating,
+			Message: fmt.Sprintf("Namespace '%s' is terminating", namespace),
+		}
+		if ns.DeletionTimestamp != nil {
+			status.DeletionTimestamp = ns.DeletionTimestamp.UTC().Format(time.RFC3339)
+		}
+		return status
+	}
+	return nil
+}
+
+// ListOperatorSecrets lists the Secrets in a namespace that are managed by the Bitwarden operator
+func ListOperatorSecrets(ctx context.Context, namespace string, clientset kubernetes.Interface) ([]corev1.Secret, error) {
+	list, err := clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var managed []corev1.Secret
+	for _, secret := range list.Items {
+		if IsOperatorManaged(&secret) {
+			managed = append(managed, secret)
+		}
+	}
+	sort.Slice(managed, func(i, j int) bool {
+		return managed[i].Name < managed[j].Name
+	})
+	return managed, nil
+}
+
+// IsOperatorManaged reports whether a Secret is owned by a BitwardenSecret or carries the operator sync annotation
+func IsOperatorManaged(secret *corev1.Secret) bool {
+	if GetOwningCRDName(secret) != "" {
+		return true
+	}
+	return GetSecretSyncTime(secret) != ""
+}
+
+// GetOwningCRDName returns the name of the BitwardenSecret owning the Secret, if any
+func GetOwningCRDName(secret *corev1.Secret) string {
+	for _, ref := range secret.OwnerReferences {
+		if ref.Kind == BitwardenSecretKind {
+			return ref.Name
+		}
+	}
+	return ""
+}
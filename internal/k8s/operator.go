@@ -0,0 +1,113 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OperatorDeployment describes the running Bitwarden secrets operator
+type OperatorDeployment struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+	// Version is the image tag without a leading "v"; empty for digests and tags such as "latest"
+	Version       string `json:"version,omitempty"`
+	ReadyReplicas int32  `json:"readyReplicas"`
+}
+
+// GetOperatorDeployment reads the operator Deployment and the image of its manager container
+// The container named "manager" is used, as in the operator's own manifests, or else the first one
+func GetOperatorDeployment(ctx context.Context, clients *K8sClients, namespace, name string) (*OperatorDeployment, error) {
+	deployment, err := clients.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operator deployment %s/%s: %w", namespace, name, err)
+	}
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("operator deployment %s/%s has no containers", namespace, name)
+	}
+	image := containers[0].Image
+	for _, container := range containers {
+		if container.Name == "manager" {
+			image = container.Image
+			break
+		}
+	}
+	return &OperatorDeployment{
+		Namespace:     namespace,
+		Name:          name,
+		Image:         image,
+		Version:       ImageVersion(image),
+		ReadyReplicas: deployment.Status.ReadyReplicas,
+	}, nil
+}
+
+// ImageVersion returns the version in an image tag such as "ghcr.io/bitwarden/sm-operator:v0.1.0"
+// Images pinned by digest, and tags that aren't versions, return ""
+func ImageVersion(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	slash := strings.LastIndex(image, "/")
+	colon := strings.LastIndex(image, ":")
+	if colon <= slash {
+		return ""
+	}
+	version := strings.TrimPrefix(image[colon+1:], "v")
+	if _, ok := parseVersion(version); !ok {
+		return ""
+	}
+	return version
+}
+
+// CompareVersions compares two dotted versions numerically, ignoring pre-release and build suffixes
+// It returns -1, 0, or 1, and false when either isn't a version
+func CompareVersions(a, b string) (int, bool) {
+	av, ok := parseVersion(strings.TrimPrefix(a, "v"))
+	if !ok {
+		return 0, false
+	}
+	bv, ok := parseVersion(strings.TrimPrefix(b, "v"))
+	if !ok {
+		return 0, false
+	}
+	for i := 0; i < len(av) || i < len(bv); i++ {
+		var x, y int
+		if i < len(av) {
+			x = av[i]
+		}
+		if i < len(bv) {
+			y = bv[i]
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+// parseVersion splits "1.2.3-rc.1" into [1 2 3]
+func parseVersion(version string) ([]int, bool) {
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	if version == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
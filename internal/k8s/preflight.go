@@ -0,0 +1,177 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Preflight check results
+const (
+	PreflightOK      = "ok"
+	PreflightWarn    = "warn"
+	PreflightFail    = "fail"
+	PreflightSkipped = "skipped"
+)
+
+// PreflightCheck is one thing the reader needs, whether it works, and how to fix it when it does not
+type PreflightCheck struct {
+	Check     string `json:"check"`
+	Namespace string `json:"namespace,omitempty"`
+	Target    string `json:"target,omitempty"`
+	Status    string `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Fix       string `json:"fix,omitempty"`
+}
+
+// PreflightOptions describes what the reader is configured to use
+type PreflightOptions struct {
+	// Namespaces are checked for existence and RBAC
+	Namespaces []string
+	// Secrets are read in SecretNamespace
+	SecretNamespace string
+	Secrets         []string
+	// ListNamespaces, Watch, and Write add the permissions those features need
+	ListNamespaces bool
+	Watch          bool
+	Write          bool
+	// ReadOnly drops the patch permission trigger-sync needs
+	ReadOnly bool
+}
+
+// Preflight checks the CRD installation, namespaces, RBAC, and configured secrets,
+// using each namespace's own credentials where configured
+func Preflight(ctx context.Context, clients *K8sClients, opts PreflightOptions) []PreflightCheck {
+	checks := []PreflightCheck{checkCRDInstalled(clients)}
+
+	if opts.ListNamespaces {
+		checks = append(checks, checkAccess(ctx, clients, "", "", "namespaces", "list"))
+	}
+	for _, namespace := range opts.Namespaces {
+		nsClients := clients.ForNamespace(namespace)
+		checks = append(checks, checkNamespace(ctx, nsClients, namespace))
+
+		verbs := []string{"get", "list"}
+		if opts.Watch {
+			verbs = append(verbs, "watch")
+		}
+		for _, verb := range verbs {
+			checks = append(checks, checkAccess(ctx, nsClients, namespace, "", "secrets", verb))
+		}
+		verbs = []string{"get"}
+		if !opts.ReadOnly {
+			verbs = append(verbs, "patch")
+		}
+		if opts.Write {
+			verbs = append(verbs, "create", "update", "delete")
+		}
+		for _, verb := range verbs {
+			checks = append(checks, checkAccess(ctx, nsClients, namespace, BitwardenSecretGVR.Group, BitwardenSecretGVR.Resource, verb))
+		}
+	}
+
+	secretClients := clients.ForNamespace(opts.SecretNamespace)
+	for _, name := range opts.Secrets {
+		checks = append(checks, checkSecret(ctx, secretClients, opts.SecretNamespace, name))
+	}
+	return checks
+}
+
+// checkCRDInstalled looks for the BitwardenSecret resource in API discovery
+func checkCRDInstalled(clients *K8sClients) PreflightCheck {
+	groupVersion := BitwardenSecretGVR.GroupVersion().String()
+	check := PreflightCheck{Check: "crd", Target: BitwardenSecretGVR.Resource + "." + BitwardenSecretGVR.Group}
+	resources, err := clients.Clientset.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil && !errors.IsNotFound(err) {
+		check.Status = PreflightWarn
+		check.Detail = fmt.Sprintf("API discovery failed: %v", err)
+		return check
+	}
+	if err == nil {
+		for _, resource := range resources.APIResources {
+			if resource.Name == BitwardenSecretGVR.Resource {
+				check.Status = PreflightOK
+				check.Detail = groupVersion + " is served"
+				return check
+			}
+		}
+	}
+	check.Status = PreflightFail
+	check.Detail = groupVersion + " BitwardenSecret is not served by the API server"
+	check.Fix = "Install the Bitwarden sm-operator and its CRDs"
+	return check
+}
+
+// checkNamespace verifies a namespace exists; without permission to get namespaces it is skipped
+func checkNamespace(ctx context.Context, clients *K8sClients, namespace string) PreflightCheck {
+	check := PreflightCheck{Check: "namespace", Namespace: namespace}
+	_, err := clients.Clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		check.Status = PreflightOK
+		check.Detail = "exists"
+	case errors.IsNotFound(err):
+		check.Status = PreflightFail
+		check.Detail = "namespace does not exist"
+		check.Fix = "Create the namespace or fix POD_NAMESPACE/ALLOWED_NAMESPACES"
+	case errors.IsForbidden(err):
+		check.Status = PreflightSkipped
+		check.Detail = "not allowed to get namespaces, existence not verified"
+	default:
+		check.Status = PreflightWarn
+		check.Detail = fmt.Sprintf("failed to get namespace: %v", err)
+	}
+	return check
+}
+
+// checkAccess checks one verb on a resource with a SelfSubjectAccessReview
+func checkAccess(ctx context.Context, clients *K8sClients, namespace, group, resource, verb string) PreflightCheck {
+	check := PreflightCheck{Check: "rbac", Namespace: namespace, Target: verb + " " + resource}
+	allowed, err := CanI(ctx, clients.Clientset, namespace, group, resource, verb)
+	switch {
+	case err != nil:
+		check.Status = PreflightWarn
+		check.Detail = fmt.Sprintf("access review failed: %v", err)
+	case allowed:
+		check.Status = PreflightOK
+		check.Detail = "allowed"
+	default:
+		check.Status = PreflightFail
+		check.Detail = "denied"
+		scope := "a Role in namespace " + namespace
+		if namespace == "" {
+			scope = "a ClusterRole"
+		}
+		check.Fix = fmt.Sprintf("Grant %s on %s through %s bound to the reader's service account", verb, resource, scope)
+	}
+	return check
+}
+
+// checkSecret reads a configured secret to confirm it exists and is readable
+func checkSecret(ctx context.Context, clients *K8sClients, namespace, name string) PreflightCheck {
+	check := PreflightCheck{Check: "secret", Namespace: namespace, Target: name}
+	secret, err := ReadSecret(ctx, name, namespace, clients.Clientset)
+	switch {
+	case err == nil:
+		WipeSecretData(secret.Data)
+		check.Status = PreflightOK
+		check.Detail = "readable"
+		if crdName := GetOwningCRDName(secret); crdName != "" {
+			check.Detail += ", owned by BitwardenSecret " + crdName
+		}
+	case IsSecretNotFound(err):
+		check.Status = PreflightWarn
+		check.Detail = "secret does not exist"
+		check.Fix = "Check SECRET_NAMES for typos, or the BitwardenSecret that should create it"
+	case errors.IsForbidden(err):
+		check.Status = PreflightFail
+		check.Detail = "reading the secret is forbidden"
+		check.Fix = "Grant get on secrets in namespace " + namespace
+	default:
+		check.Status = PreflightWarn
+		check.Detail = fmt.Sprintf("failed to read secret: %v", err)
+	}
+	return check
+}
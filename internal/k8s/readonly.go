@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrReadOnly is returned for Kubernetes API writes while the clients are read-only
+var ErrReadOnly = errors.New("read-only mode: Kubernetes API writes are disabled")
+
+// readOnlyWrap returns a transport refusing writes through rt; it fits rest.Config.Wrap
+func readOnlyWrap(rt http.RoundTripper) http.RoundTripper {
+	return &readOnlyTransport{next: rt}
+}
+
+// readOnlyTransport refuses every request that could change the cluster, so no code path can write
+// Access and token reviews, service account token requests, and server-side dry runs are POSTs
+// that change nothing stored, so they still pass
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip passes reads through and fails writes before they leave the process
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isReadOnlyRequest(req) {
+		return nil, fmt.Errorf("%w (%s %s)", ErrReadOnly, req.Method, req.URL.Path)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// isReadOnlyRequest reports whether a request leaves the cluster unchanged
+func isReadOnlyRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if req.URL.Query().Get("dryRun") == "All" {
+		return true
+	}
+	if req.Method != http.MethodPost {
+		return false
+	}
+	path := req.URL.Path
+	return strings.Contains(path, "/apis/authorization.k8s.io/") ||
+		strings.Contains(path, "/apis/authentication.k8s.io/") ||
+		strings.Contains(path, "/serviceaccounts/") && strings.HasSuffix(path, "/token")
+}
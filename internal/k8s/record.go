@@ -0,0 +1,294 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"bitwarden-reader/internal/logging"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// redactedValue replaces recorded secret values; it is base64 so replayed Secrets still decode
+var redactedValue = base64.StdEncoding.EncodeToString([]byte("<redacted>"))
+
+// lastAppliedAnnotation can hold a copy of a Secret's data and is dropped from recordings
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// RecordedExchange is one Kubernetes API call in a recording, stored as a JSON line
+type RecordedExchange struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Status      int             `json:"status"`
+	ContentType string          `json:"contentType,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+}
+
+// requestKey identifies a call by method, path, and query parameters in sorted order
+func requestKey(req *http.Request) string {
+	path := req.URL.Path
+	if query := req.URL.Query(); len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return req.Method + " " + path
+}
+
+// isWatch reports whether the request is a streaming watch, which is neither recorded nor replayed
+func isWatch(req *http.Request) bool {
+	return req.URL.Query().Get("watch") == "true"
+}
+
+// recording is the file that recorded exchanges are appended to, shared by every client's transport
+type recording struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// newRecording creates or truncates the recording at path
+func newRecording(path string) (*recording, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording %s: %w", path, err)
+	}
+	return &recording{file: file}, nil
+}
+
+// write appends one exchange as a JSON line
+func (r *recording) write(exchange RecordedExchange) {
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		logging.Printf("Error writing Kubernetes API recording: %v", err)
+	}
+}
+
+// wrap returns a transport recording the responses of rt; it fits rest.Config.Wrap
+func (r *recording) wrap(rt http.RoundTripper) http.RoundTripper {
+	return &recorder{next: rt, recording: r}
+}
+
+// recorder is a transport that records every API response, sanitized
+type recorder struct {
+	next      http.RoundTripper
+	recording *recording
+}
+
+// RoundTrip performs the request and records the response; the caller still reads the full body
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil || isWatch(req) {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return resp, nil
+	}
+
+	r.recording.write(RecordedExchange{
+		Method:      req.Method,
+		Path:        strings.TrimPrefix(requestKey(req), req.Method+" "),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        sanitizeBody(body),
+	})
+	return resp, nil
+}
+
+// sanitizeBody redacts secret values in a JSON response; bodies that are not JSON are not recorded
+func sanitizeBody(body []byte) json.RawMessage {
+	var object interface{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return nil
+	}
+	sanitizeObject(object, "")
+	sanitized, err := json.Marshal(object)
+	if err != nil {
+		return nil
+	}
+	return sanitized
+}
+
+// sanitizeObject redacts Secret data and TokenRequest tokens, descending into lists
+// kind is used for list items, which omit their own kind
+func sanitizeObject(value interface{}, kind string) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if objectKind, ok := object["kind"].(string); ok {
+		kind = objectKind
+	}
+
+	switch kind {
+	case "Secret":
+		for _, field := range []string{"data", "stringData"} {
+			if data, ok := object[field].(map[string]interface{}); ok {
+				for key := range data {
+					data[key] = redactedValue
+				}
+			}
+		}
+		if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+			if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+				delete(annotations, lastAppliedAnnotation)
+			}
+		}
+	case "TokenRequest":
+		if status, ok := object["status"].(map[string]interface{}); ok && status["token"] != nil {
+			status["token"] = "<redacted>"
+		}
+	}
+
+	if items, ok := object["items"].([]interface{}); ok {
+		for _, item := range items {
+			sanitizeObject(item, strings.TrimSuffix(kind, "List"))
+		}
+	}
+}
+
+// replayer is a transport that answers requests from a recording instead of an API server
+// Repeated requests get the recorded responses in order and then keep getting the last one
+type replayer struct {
+	mu        sync.Mutex
+	exchanges map[string][]RecordedExchange
+	served    map[string]int
+}
+
+// loadRecording reads a recording written by the recorder
+func loadRecording(path string) (*replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording %s: %w", path, err)
+	}
+	defer file.Close()
+
+	r := &replayer{
+		exchanges: make(map[string][]RecordedExchange),
+		served:    make(map[string]int),
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange RecordedExchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid recorded exchange: %w", path, lineNumber, err)
+		}
+		key := exchange.Method + " " + exchange.Path
+		r.exchanges[key] = append(r.exchanges[key], exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// RoundTrip serves the recorded response for the request, or 404 when none was recorded
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := requestKey(req)
+
+	r.mu.Lock()
+	exchanges := r.exchanges[key]
+	var exchange RecordedExchange
+	found := len(exchanges) > 0 && !isWatch(req)
+	if found {
+		index := r.served[key]
+		if index < len(exchanges)-1 {
+			r.served[key] = index + 1
+		}
+		exchange = exchanges[index]
+	}
+	r.mu.Unlock()
+
+	if !found {
+		exchange = RecordedExchange{
+			Status:      http.StatusNotFound,
+			ContentType: "application/json",
+			Body:        notRecordedStatus(key),
+		}
+	}
+
+	header := make(http.Header)
+	if exchange.ContentType != "" {
+		header.Set("Content-Type", exchange.ContentType)
+	}
+	return &http.Response{
+		StatusCode:    exchange.Status,
+		Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(exchange.Body)),
+		ContentLength: int64(len(exchange.Body)),
+		Request:       req,
+	}, nil
+}
+
+// notRecordedStatus is the Status body returned for requests missing from the recording
+func notRecordedStatus(key string) json.RawMessage {
+	status, _ := json.Marshal(map[string]interface{}{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     "Failure",
+		"message":    fmt.Sprintf("%s was not recorded", key),
+		"reason":     "NotFound",
+		"code":       http.StatusNotFound,
+	})
+	return status
+}
+
+// newReplayClients creates clients served entirely from a recording
+func newReplayClients(path string) (*K8sClients, error) {
+	replay, err := loadRecording(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &rest.Config{
+		Host:      "http://replay.invalid",
+		Transport: replay,
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	count := 0
+	for _, exchanges := range replay.exchanges {
+		count += len(exchanges)
+	}
+	logging.Printf("Replaying %d recorded Kubernetes API responses from %s", count, path)
+
+	return &K8sClients{
+		Clientset:     clientset,
+		DynamicClient: dynamicClient,
+		config:        config,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package k8s
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"bitwarden-reader/internal/logging"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// BitwardenSecret resource scopes
+const (
+	scopeUnknown int32 = iota
+	scopeNamespaced
+	scopeCluster
+)
+
+// crdScope remembers whether BitwardenSecrets are namespaced or cluster-scoped, once known
+// While unknown, a namespaced 404 is retried cluster-scoped and the scope that answers is remembered
+var crdScope atomic.Int32
+
+// DetectCRDScope reads the BitwardenSecret resource's scope from API discovery and remembers it
+func DetectCRDScope(client discovery.DiscoveryInterface) error {
+	groupVersion := BitwardenSecretGVR.GroupVersion().String()
+	resources, err := client.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return fmt.Errorf("failed to discover %s: %w", groupVersion, err)
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == BitwardenSecretGVR.Resource {
+			rememberCRDScope(resource.Namespaced)
+			logging.Printf("BitwardenSecret resource is %s", scopeName(crdScope.Load()))
+			return nil
+		}
+	}
+	return fmt.Errorf("%s does not serve %s", groupVersion, BitwardenSecretGVR.Resource)
+}
+
+// rememberCRDScope records the scope seen in discovery or a successful read
+func rememberCRDScope(namespaced bool) {
+	if namespaced {
+		crdScope.Store(scopeNamespaced)
+	} else {
+		crdScope.Store(scopeCluster)
+	}
+}
+
+// scopeName describes a scope for logs
+func scopeName(scope int32) string {
+	switch scope {
+	case scopeNamespaced:
+		return "namespace-scoped"
+	case scopeCluster:
+		return "cluster-scoped"
+	default:
+		return "of unknown scope"
+	}
+}
+
+// crdResource returns the BitwardenSecret resource in namespace, or cluster-wide once known to be cluster-scoped
+func crdResource(dynamicClient dynamic.Interface, namespace string) dynamic.ResourceInterface {
+	if crdScope.Load() == scopeCluster {
+		return dynamicClient.Resource(BitwardenSecretGVR)
+	}
+	return dynamicClient.Resource(BitwardenSecretGVR).Namespace(namespace)
+}
@@ -10,6 +10,9 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// SecretGroupAnnotation assigns a Secret to a named dashboard group
+const SecretGroupAnnotation = "bitwarden-reader.io/group"
+
 // ReadSecret reads a Kubernetes Secret by name and namespace
 func ReadSecret(ctx context.Context, name, namespace string, clientset kubernetes.Interface) (*corev1.Secret, error) {
 	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
@@ -34,15 +37,72 @@ func DecodeSecretData(data map[string][]byte) map[string]string {
 	return decoded
 }
 
+// WipeSecretData zeroes the raw secret value buffers once they are no longer needed
+func WipeSecretData(data map[string][]byte) {
+	for _, value := range data {
+		clear(value)
+	}
+}
+
 // IsSecretNotFound checks if an error is a "not found" error
 func IsSecretNotFound(err error) bool {
 	return errors.IsNotFound(err)
 }
 
-// GetSecretSyncTime extracts the sync-time annotation from a secret
+// GetSecretSyncTime extracts the sync time from the first sync-time annotation set on a secret
 func GetSecretSyncTime(secret *corev1.Secret) string {
 	if secret.Annotations == nil {
 		return ""
 	}
-	return secret.Annotations["bitwarden-secrets-operator.io/sync-time"]
+	for _, key := range SyncTimeAnnotations() {
+		if value := secret.Annotations[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// GetSecretGroup extracts the group annotation from a secret
+func GetSecretGroup(secret *corev1.Secret) string {
+	if secret.Annotations == nil {
+		return ""
+	}
+	return secret.Annotations[SecretGroupAnnotation]
+}
+
+// RestoreSecret creates a Secret, or replaces its data when it exists and overwrite is set
+// Returns the action taken: "created", "updated", or "skipped"
+func RestoreSecret(ctx context.Context, secret *corev1.Secret, overwrite, dryRun bool, clientset kubernetes.Interface) (string, error) {
+	var dryRunOpts []string
+	if dryRun {
+		dryRunOpts = []string{metav1.DryRunAll}
+	}
+
+	secrets := clientset.CoreV1().Secrets(secret.Namespace)
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{DryRun: dryRunOpts})
+	if err == nil {
+		return "created", nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+	if !overwrite {
+		return "skipped", nil
+	}
+
+	existing, err := secrets.Get(ctx, secret.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	existing.Data = secret.Data
+	if existing.Labels == nil {
+		existing.Labels = make(map[string]string)
+	}
+	for key, value := range secret.Labels {
+		existing.Labels[key] = value
+	}
+	if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{DryRun: dryRunOpts}); err != nil {
+		return "", err
+	}
+	return "updated", nil
 }
@@ -0,0 +1,137 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+
+	"golang.org/x/oauth2"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// MinTokenRequestExpiration is the shortest token lifetime the API server accepts
+const MinTokenRequestExpiration = 10 * time.Minute
+
+// tokenRequestTimeout bounds each TokenRequest call
+const tokenRequestTimeout = 10 * time.Second
+
+// tokenRefreshFraction is the share of a token's lifetime after which a new one is requested,
+// matching the kubelet's refresh of projected tokens
+const tokenRefreshFraction = 0.8
+
+// tokenRequestSource issues short-lived tokens for the service account through the TokenRequest API
+type tokenRequestSource struct {
+	clientset  kubernetes.Interface
+	namespace  string
+	name       string
+	expiration time.Duration
+	audiences  []string
+}
+
+// Token requests a new token; it expires from the cache at 80% of its lifetime
+func (ts *tokenRequestSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenRequestTimeout)
+	defer cancel()
+
+	seconds := int64(ts.expiration / time.Second)
+	request := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &seconds,
+			Audiences:         ts.audiences,
+		},
+	}
+	issued := time.Now()
+	result, err := ts.clientset.CoreV1().ServiceAccounts(ts.namespace).CreateToken(ctx, ts.name, request, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token for service account %s/%s: %w", ts.namespace, ts.name, err)
+	}
+
+	lifetime := result.Status.ExpirationTimestamp.Sub(issued)
+	return &oauth2.Token{
+		AccessToken: result.Status.Token,
+		Expiry:      issued.Add(time.Duration(float64(lifetime) * tokenRefreshFraction)),
+	}, nil
+}
+
+// serviceAccountFromToken reads the namespace and name of the service account a projected token belongs to
+// The token is not verified; it only names the account to request new tokens for
+func serviceAccountFromToken(path string) (string, string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+	parts := strings.Split(strings.TrimSpace(string(raw)), ".")
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("service account token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode service account token: %w", err)
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", "", fmt.Errorf("failed to parse service account token: %w", err)
+	}
+
+	// Subject is system:serviceaccount:<namespace>:<name>
+	fields := strings.Split(claims.Subject, ":")
+	if len(fields) != 4 || fields[0] != "system" || fields[1] != "serviceaccount" {
+		return "", "", fmt.Errorf("token subject %q is not a service account", claims.Subject)
+	}
+	return fields[2], fields[3], nil
+}
+
+// UseTokenRequest switches the dynamic client to short-lived tokens issued through the TokenRequest API
+// Tokens are renewed at 80% of their lifetime and immediately after a 401 response
+func (c *K8sClients) UseTokenRequest(expiration time.Duration, audiences []string) error {
+	if c.config == nil || c.config.BearerTokenFile == "" {
+		return fmt.Errorf("TokenRequest requires a projected service account token")
+	}
+	if expiration < MinTokenRequestExpiration {
+		return fmt.Errorf("token expiration %s is below the minimum of %s", expiration, MinTokenRequestExpiration)
+	}
+
+	namespace, name, err := serviceAccountFromToken(c.config.BearerTokenFile)
+	if err != nil {
+		return err
+	}
+	source := transport.NewCachedTokenSource(&tokenRequestSource{
+		clientset:  c.Clientset,
+		namespace:  namespace,
+		name:       name,
+		expiration: expiration,
+		audiences:  audiences,
+	})
+	// Fail at startup rather than on the first CRD call when the account may not request tokens
+	if _, err := source.Token(); err != nil {
+		return err
+	}
+
+	config := rest.CopyConfig(c.config)
+	config.BearerToken = ""
+	config.BearerTokenFile = ""
+	config.Wrap(transport.ResettableTokenSourceWrapTransport(source))
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	c.DynamicClient = dynamicClient
+	c.dynamicConfig = config
+
+	logging.Printf("Dynamic client uses TokenRequest tokens for %s/%s (expiration: %s)", namespace, name, expiration)
+	return nil
+}
@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/dynamic"
+)
+
+// TriggerClaimAnnotation records the last trigger of a BitwardenSecret: when, from which replica, and by whom
+// Replicas compare-and-set it, so near-simultaneous triggers of the same BitwardenSecret patch it once
+const TriggerClaimAnnotation = "bitwarden-reader.io/trigger-claim"
+
+// maxClaimAttempts bounds the re-reads when the BitwardenSecret changes between reading and claiming it
+const maxClaimAttempts = 3
+
+// TriggerClaim is the value of the TriggerClaimAnnotation
+type TriggerClaim struct {
+	Time    time.Time `json:"time"`
+	Replica string    `json:"replica,omitempty"`
+	User    string    `json:"user,omitempty"`
+}
+
+// String describes the claim for messages
+func (c TriggerClaim) String() string {
+	description := "triggered at " + c.Time.UTC().Format(time.RFC3339)
+	if c.User != "" {
+		description += " by " + c.User
+	}
+	if c.Replica != "" {
+		description += " on " + c.Replica
+	}
+	return description
+}
+
+// TriggerDuplicateError is returned when the BitwardenSecret was already triggered within the dedup window
+type TriggerDuplicateError struct {
+	Claim TriggerClaim
+}
+
+// Error describes the earlier trigger
+func (e *TriggerDuplicateError) Error() string {
+	return "already " + e.Claim.String()
+}
+
+// TriggerSyncOnce is TriggerSync deduplicated across replicas: the BitwardenSecret is only patched when no
+// trigger was claimed within window, and the patch carries the resourceVersion it was read at, so of two
+// replicas claiming it at once one gets a conflict, re-reads it, and finds the other's claim
+// A duplicate trigger returns a *TriggerDuplicateError and patches nothing
+func TriggerSyncOnce(ctx context.Context, name, namespace string, keys []string, extra map[string]string, claim TriggerClaim, window time.Duration, dynamicClient dynamic.Interface) error {
+	if dynamicClient == nil {
+		return fmt.Errorf("dynamicClient is nil")
+	}
+	value, err := json.Marshal(claim)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trigger claim: %w", err)
+	}
+	annotations := syncAnnotations(keys, extra, claim.Time)
+	annotations[TriggerClaimAnnotation] = string(value)
+
+	for attempt := 1; ; attempt++ {
+		resource, unstructuredObj, err := getCRDForPatch(ctx, name, namespace, dynamicClient)
+		if err != nil {
+			return err
+		}
+		var previous TriggerClaim
+		if err := json.Unmarshal([]byte(unstructuredObj.GetAnnotations()[TriggerClaimAnnotation]), &previous); err == nil {
+			if age := claim.Time.Sub(previous.Time); age < window && age > -window {
+				return &TriggerDuplicateError{Claim: previous}
+			}
+		}
+		err = mergeCRDAnnotations(ctx, resource, unstructuredObj, annotations, unstructuredObj.GetResourceVersion())
+		if !errors.IsConflict(err) || attempt == maxClaimAttempts {
+			return err
+		}
+	}
+}
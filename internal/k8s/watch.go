@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"bitwarden-reader/internal/logging"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Secret watch strategies
+const (
+	// WatchGet only polls each configured secret with a GET; nothing is watched
+	WatchGet = "get"
+	// WatchFieldSelector watches each configured secret by name
+	WatchFieldSelector = "field-selector"
+	// WatchLabelSelector watches the secrets matching a label selector
+	WatchLabelSelector = "label-selector"
+	// WatchNamespace watches every secret in the namespace
+	WatchNamespace = "namespace"
+)
+
+// secretsGVR is the core Secret resource
+var secretsGVR = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+// SecretWatchOptions selects which secrets are watched and how
+type SecretWatchOptions struct {
+	Strategy      string
+	Namespace     string
+	Names         []string
+	LabelSelector string
+}
+
+// ValidWatchStrategy reports whether the strategy is known
+func ValidWatchStrategy(strategy string) bool {
+	switch strategy {
+	case WatchGet, WatchFieldSelector, WatchLabelSelector, WatchNamespace:
+		return true
+	}
+	return false
+}
+
+// watchTweaks returns one list option tweak per informer the strategy needs
+func watchTweaks(opts SecretWatchOptions) ([]metadatainformer.TweakListOptionsFunc, error) {
+	switch opts.Strategy {
+	case WatchFieldSelector:
+		tweaks := make([]metadatainformer.TweakListOptionsFunc, 0, len(opts.Names))
+		for _, name := range opts.Names {
+			selector := fields.OneTermEqualSelector("metadata.name", name).String()
+			tweaks = append(tweaks, func(options *metav1.ListOptions) {
+				options.FieldSelector = selector
+			})
+		}
+		return tweaks, nil
+	case WatchLabelSelector:
+		if opts.LabelSelector == "" {
+			return nil, fmt.Errorf("watch strategy %s requires a label selector", opts.Strategy)
+		}
+		return []metadatainformer.TweakListOptionsFunc{func(options *metav1.ListOptions) {
+			options.LabelSelector = opts.LabelSelector
+		}}, nil
+	case WatchNamespace:
+		return []metadatainformer.TweakListOptionsFunc{nil}, nil
+	}
+	return nil, fmt.Errorf("unknown watch strategy %q", opts.Strategy)
+}
+
+// SecretWatchEvent is a watched secret that was added, updated, or deleted
+type SecretWatchEvent struct {
+	Name    string
+	Deleted bool
+	// UID and Owners are the deleted Secret's last known metadata
+	UID    string
+	Owners []metav1.OwnerReference
+}
+
+// WatchSecretMetadata watches the metadata of the named secrets and calls onChange for each
+// secret added, updated, or deleted after the initial list, until ctx is cancelled
+// Only object metadata is cached, never secret data, which keeps the watch cache small
+func (c *K8sClients) WatchSecretMetadata(ctx context.Context, opts SecretWatchOptions, onChange func(event SecretWatchEvent)) error {
+	if c.config == nil {
+		return fmt.Errorf("watching requires clients created by NewK8sClient")
+	}
+	tweaks, err := watchTweaks(opts)
+	if err != nil {
+		return err
+	}
+	client, err := metadata.NewForConfig(c.config)
+	if err != nil {
+		return fmt.Errorf("failed to create metadata client: %w", err)
+	}
+
+	// Selectors may match more than the configured secrets; only those are reported
+	wanted := make(map[string]bool, len(opts.Names))
+	for _, name := range opts.Names {
+		wanted[name] = true
+	}
+	notify := func(obj interface{}, deleted bool) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		object, ok := obj.(metav1.Object)
+		if !ok || !wanted[object.GetName()] {
+			return
+		}
+		event := SecretWatchEvent{Name: object.GetName(), Deleted: deleted}
+		if deleted {
+			event.UID = string(object.GetUID())
+			event.Owners = object.GetOwnerReferences()
+		}
+		onChange(event)
+	}
+	handler := cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				notify(obj, false)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) { notify(obj, false) },
+		DeleteFunc: func(obj interface{}) { notify(obj, true) },
+	}
+
+	for _, tweak := range tweaks {
+		informer := metadatainformer.NewFilteredMetadataInformer(client, secretsGVR, opts.Namespace, 0, cache.Indexers{}, tweak).Informer()
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			return fmt.Errorf("failed to add watch handler: %w", err)
+		}
+		go informer.Run(ctx.Done())
+	}
+	logging.Printf("Watching secret metadata in %s (strategy: %s, informers: %d)", opts.Namespace, opts.Strategy, len(tweaks))
+
+	<-ctx.Done()
+	return nil
+}
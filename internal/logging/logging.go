@@ -0,0 +1,225 @@
+package logging
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+)
+
+// minSecretLength is the shortest value registered for scrubbing; shorter values
+// would redact unrelated text
+const minSecretLength = 6
+
+// Redaction placeholders substituted into log output
+const (
+	redactedValue  = "[REDACTED]"
+	redactedBase64 = "[REDACTED-BASE64]"
+	redactedData   = `"data":"[REDACTED]"`
+)
+
+var (
+	// base64Pattern matches long base64 runs, candidates for encoded secret data or tokens; see isBase64Blob
+	base64Pattern = regexp.MustCompile(`[A-Za-z0-9+/]{32,}={0,2}`)
+
+	// dataFieldPattern matches serialized Secret data maps embedded in API error bodies
+	dataFieldPattern = regexp.MustCompile(`"(data|stringData)"\s*:\s*\{[^{}]*\}`)
+)
+
+// registry holds the secret values currently known to the process, by the secret holding them
+var registry = struct {
+	sync.RWMutex
+	// values counts the owners holding each value, so a value shared by two secrets stays
+	// registered until both dropped it
+	values   map[string]int
+	owners   map[string][]string
+	replacer *strings.Replacer
+	disabled bool
+}{
+	values: make(map[string]int),
+	owners: make(map[string][]string),
+}
+
+// RegisterSecrets records the current values of owner, e.g. "kubernetes/<namespace>/<name>", which must
+// never appear in log output; values owner registered before and no longer holds are forgotten, so
+// rotated values don't pile up
+func RegisterSecrets(owner string, values ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.disabled {
+		return
+	}
+	kept := make([]string, 0, len(values))
+	for _, value := range values {
+		if len(value) >= minSecretLength && !slices.Contains(kept, value) {
+			kept = append(kept, value)
+		}
+	}
+	previous := registry.owners[owner]
+	if slices.Equal(previous, kept) {
+		return
+	}
+	for _, value := range kept {
+		registry.values[value]++
+	}
+	release(previous)
+	if len(kept) > 0 {
+		registry.owners[owner] = kept
+	} else {
+		delete(registry.owners, owner)
+	}
+	registry.replacer = nil
+}
+
+// ForgetSecrets forgets the values of owner, e.g. after its secret was deleted
+func ForgetSecrets(owner string) {
+	registry.Lock()
+	defer registry.Unlock()
+	previous, ok := registry.owners[owner]
+	if !ok {
+		return
+	}
+	release(previous)
+	delete(registry.owners, owner)
+	registry.replacer = nil
+}
+
+// release drops one owner's hold on values; the registry lock must be held
+func release(values []string) {
+	for _, value := range values {
+		if registry.values[value]--; registry.values[value] <= 0 {
+			delete(registry.values, value)
+		}
+	}
+}
+
+// DisableRegistry drops all registered values and stops retaining new ones
+// Scrubbing then relies on the data-map and base64 patterns only
+func DisableRegistry() {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.disabled = true
+	registry.values = make(map[string]int)
+	registry.owners = make(map[string][]string)
+	registry.replacer = nil
+}
+
+// currentReplacer returns a replacer for all registered values, building it lazily
+func currentReplacer() *strings.Replacer {
+	registry.RLock()
+	replacer := registry.replacer
+	registry.RUnlock()
+	if replacer != nil {
+		return replacer
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.replacer == nil {
+		pairs := make([]string, 0, len(registry.values)*2)
+		for value := range registry.values {
+			pairs = append(pairs, value, redactedValue)
+		}
+		registry.replacer = strings.NewReplacer(pairs...)
+	}
+	return registry.replacer
+}
+
+// Scrub removes registered secret values, serialized Secret data, and base64 blobs from a message
+func Scrub(message string) string {
+	message = currentReplacer().Replace(message)
+	message = dataFieldPattern.ReplaceAllString(message, redactedData)
+	return base64Pattern.ReplaceAllStringFunc(message, func(run string) string {
+		if isBase64Blob(run) {
+			return redactedBase64
+		}
+		return run
+	})
+}
+
+// isBase64Blob reports whether a base64Pattern run looks like encoded data: padded, or mixing upper
+// and lower case letters with digits, as random bytes do
+// Hex hashes, UIDs, and lower-case resource names and paths are left alone
+func isBase64Blob(run string) bool {
+	if strings.HasSuffix(run, "=") {
+		return true
+	}
+	var upper, lower, digit bool
+	for _, r := range run {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
+
+// Printf logs a formatted, scrubbed message
+func Printf(format string, args ...interface{}) {
+	_ = log.Output(2, Scrub(fmt.Sprintf(format, args...)))
+}
+
+// Println logs a scrubbed message
+func Println(args ...interface{}) {
+	_ = log.Output(2, Scrub(fmt.Sprintln(args...)))
+}
+
+// Fatalf logs a formatted, scrubbed message and exits
+func Fatalf(format string, args ...interface{}) {
+	_ = log.Output(2, Scrub(fmt.Sprintf(format, args...)))
+	os.Exit(1)
+}
+
+// scrubWriter scrubs everything written through it before passing it on
+type scrubWriter struct {
+	out io.Writer
+}
+
+// NewScrubWriter wraps w so that all output is scrubbed
+// Use it for third-party loggers (standard log, gin, klog) that bypass this package
+func NewScrubWriter(w io.Writer) io.Writer {
+	return &scrubWriter{out: w}
+}
+
+// Write scrubs p and writes it to the underlying writer
+func (w *scrubWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, Scrub(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Install routes the standard logger, klog (client-go), and gin's default writers through the
+// scrubber to stderr, as a backstop for any package that bypasses this one
+func Install() {
+	install(os.Stderr)
+}
+
+// install routes the third-party loggers through the scrubber to w
+func install(w io.Writer) {
+	scrubbed := NewScrubWriter(w)
+	log.SetOutput(scrubbed)
+
+	// klog also copies errors straight to stderr, past the scrubber, at or above stderrthreshold;
+	// no severity reaches 4. one_output stops it writing each error once per lower severity
+	flags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	for name, value := range map[string]string{"logtostderr": "false", "stderrthreshold": "4", "one_output": "true"} {
+		_ = flags.Set(name, value)
+	}
+	klog.SetOutput(scrubbed)
+	gin.DefaultWriter = scrubbed
+	gin.DefaultErrorWriter = scrubbed
+}
@@ -0,0 +1,210 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+)
+
+// resetRegistry empties the registry and re-enables it, now and after the test
+func resetRegistry(t *testing.T) {
+	t.Helper()
+	clearRegistry()
+	t.Cleanup(clearRegistry)
+}
+
+func clearRegistry() {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.values = make(map[string]int)
+	registry.owners = make(map[string][]string)
+	registry.replacer = nil
+	registry.disabled = false
+}
+
+// assertScrubbed fails when any of the values survives in output
+func assertScrubbed(t *testing.T, output string, values ...string) {
+	t.Helper()
+	for _, value := range values {
+		if strings.Contains(output, value) {
+			t.Errorf("output leaks %q: %s", value, output)
+		}
+	}
+}
+
+func TestScrubRegisteredValues(t *testing.T) {
+	resetRegistry(t)
+	RegisterSecrets("kubernetes/default/db", "hunter2-password", "short")
+
+	got := Scrub("login with hunter2-password failed, short is kept")
+	want := "login with [REDACTED] failed, short is kept"
+	if got != want {
+		t.Errorf("Scrub() = %q, want %q", got, want)
+	}
+}
+
+func TestRegisterSecretsForgetsRotatedValues(t *testing.T) {
+	resetRegistry(t)
+	RegisterSecrets("kubernetes/default/db", "old-password", "shared-value")
+	RegisterSecrets("kubernetes/default/api", "shared-value")
+	RegisterSecrets("kubernetes/default/db", "new-password")
+
+	got := Scrub("old-password new-password shared-value")
+	if want := "old-password [REDACTED] [REDACTED]"; got != want {
+		t.Errorf("after rotation Scrub() = %q, want %q", got, want)
+	}
+
+	ForgetSecrets("kubernetes/default/api")
+	if got := Scrub("shared-value"); got != "shared-value" {
+		t.Errorf("after forgetting the last owner Scrub() = %q, want the value kept", got)
+	}
+	ForgetSecrets("kubernetes/default/db")
+	if len(registry.values) != 0 || len(registry.owners) != 0 {
+		t.Errorf("registry not empty after forgetting every owner: %d values, %d owners", len(registry.values), len(registry.owners))
+	}
+}
+
+func TestDisableRegistry(t *testing.T) {
+	resetRegistry(t)
+	RegisterSecrets("kubernetes/default/db", "hunter2-password")
+	DisableRegistry()
+	RegisterSecrets("kubernetes/default/api", "another-password")
+
+	if len(registry.values) != 0 {
+		t.Errorf("disabled registry keeps %d values", len(registry.values))
+	}
+	if got := Scrub(`{"data":{"password":"aHVudGVyMg=="}}`); got != `{"data":"[REDACTED]"}` {
+		t.Errorf("disabled registry Scrub() = %q, want the data map redacted", got)
+	}
+}
+
+func TestScrubDataField(t *testing.T) {
+	resetRegistry(t)
+	message := `secrets "db" is invalid: {"kind":"Secret","data":{"password":"aHVudGVyMg=="},"stringData":{"token":"abc"}}`
+
+	got := Scrub(message)
+	assertScrubbed(t, got, "aHVudGVyMg==", `"token":"abc"`)
+	if strings.Count(got, redactedData) != 2 {
+		t.Errorf("Scrub() = %q, want both data maps redacted", got)
+	}
+}
+
+func TestScrubBase64(t *testing.T) {
+	resetRegistry(t)
+	random := make([]byte, 48)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(random)
+	// Random bytes may, rarely, encode without all three character classes; padding makes sure
+	if !isBase64Blob(encoded) {
+		encoded = base64.StdEncoding.EncodeToString(random[:47])
+	}
+
+	tests := []struct {
+		name   string
+		run    string
+		redact bool
+	}{
+		{"random bytes", encoded, true},
+		{"padded", "dGhpcyBpcyBhIHNlY3JldCB0b2tlbiB2YWx1ZQ==", true},
+		{"mixed classes", "eyJhbGciOiJSUzI1NiIsImtpZCI6IjEyMzQ1Njc4OTAifQ", true},
+		{"sha256 hex", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", false},
+		{"upper hex", "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B", false},
+		{"uid", "3f1c2a9e-8b7d-4c6e-9f0a-1b2c3d4e5f60", false},
+		{"api path", "api/v1/namespaces/default/secrets/database", false},
+		{"letters only", "ThisIsAVeryLongIdentifierWithoutDigits", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Scrub("value " + tt.run + " end")
+			redacted := got == "value "+redactedBase64+" end"
+			if redacted != tt.redact {
+				t.Errorf("Scrub(%q) = %q, want redacted %t", tt.run, got, tt.redact)
+			}
+		})
+	}
+}
+
+func TestScrubWriter(t *testing.T) {
+	resetRegistry(t)
+	RegisterSecrets("kubernetes/default/db", "hunter2-password")
+	var buf bytes.Buffer
+	w := NewScrubWriter(&buf)
+
+	p := []byte("password=hunter2-password\n")
+	n, err := w.Write(p)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if n != len(p) {
+		t.Errorf("Write() = %d, want the unscrubbed length %d", n, len(p))
+	}
+	if got := buf.String(); got != "password=[REDACTED]\n" {
+		t.Errorf("written %q", got)
+	}
+}
+
+func TestInstallRoutesLoggers(t *testing.T) {
+	resetRegistry(t)
+	RegisterSecrets("kubernetes/default/db", "hunter2-password")
+	const blob = "dGhpcyBpcyBhIHNlY3JldCB0b2tlbiB2YWx1ZQ=="
+
+	// Anything written to stderr directly bypassed the scrubber
+	stderr, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	realStderr := os.Stderr
+	os.Stderr = stderr
+
+	var buf bytes.Buffer
+	install(&buf)
+	t.Cleanup(func() {
+		os.Stderr = realStderr
+		log.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+		gin.DefaultWriter = os.Stdout
+		gin.DefaultErrorWriter = os.Stderr
+	})
+
+	loggers := []struct {
+		name  string
+		write func(message string)
+	}{
+		{"log", func(message string) { log.Print(message) }},
+		{"logging", func(message string) { Printf("%s", message) }},
+		{"klog", func(message string) { klog.Info(message); klog.Flush() }},
+		{"klog error", func(message string) { klog.Error(message); klog.Flush() }},
+		{"gin", func(message string) { fmt.Fprintln(gin.DefaultWriter, message) }},
+		{"gin error", func(message string) { fmt.Fprintln(gin.DefaultErrorWriter, message) }},
+	}
+	for _, logger := range loggers {
+		t.Run(logger.name, func(t *testing.T) {
+			buf.Reset()
+			logger.write("via " + logger.name + ": password hunter2-password token " + blob)
+
+			got := buf.String()
+			if !strings.Contains(got, "via "+logger.name) {
+				t.Fatalf("%s output not routed through the scrubber: %q", logger.name, got)
+			}
+			assertScrubbed(t, got, "hunter2-password", blob)
+		})
+	}
+
+	bypassed, err := os.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bypassed) > 0 {
+		t.Errorf("written to stderr past the scrubber: %q", bypassed)
+	}
+}
@@ -0,0 +1,131 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	packetConnect    = 1
+	packetConnAck    = 2
+	packetPublish    = 3
+	packetPubAck     = 4
+	packetPubRec     = 5
+	packetPubRel     = 6
+	packetPubComp    = 7
+	packetPingReq    = 12
+	packetPingResp   = 13
+	packetDisconnect = 14
+)
+
+// maxIncomingPacket bounds the packets read from the broker; the acknowledgements a publisher gets are tiny
+const maxIncomingPacket = 64 * 1024
+
+// connAckReasons explains the CONNACK return codes refusing a connection
+var connAckReasons = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad username or password",
+	5: "not authorized",
+}
+
+// appendString appends a length-prefixed UTF-8 string
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// connectPacket builds a CONNECT packet for a clean session
+func connectPacket(clientID, username, password string, keepAlive uint16) []byte {
+	flags := byte(0x02)
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, keepAlive)
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+		if password != "" {
+			body = appendString(body, password)
+		}
+	}
+	return packet(packetConnect<<4, body)
+}
+
+// publishPacket builds a PUBLISH packet; packetID is only sent for QoS 1 and 2
+func publishPacket(topic string, payload []byte, qos byte, retain bool, packetID uint16) []byte {
+	flags := qos << 1
+	if retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+	body = append(body, payload...)
+	return packet(packetPublish<<4|flags, body)
+}
+
+// idPacket builds a packet whose only content is a packet identifier, such as PUBREL
+func idPacket(header byte, packetID uint16) []byte {
+	return packet(header, binary.BigEndian.AppendUint16(nil, packetID))
+}
+
+// packet prefixes a body with the fixed header: the first byte and the remaining length
+func packet(header byte, body []byte) []byte {
+	b := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(b, body...)
+}
+
+// readPacket reads one packet, returning its type and body
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	if length > maxIncomingPacket {
+		return 0, nil, fmt.Errorf("packet of %d bytes exceeds %d", length, maxIncomingPacket)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
@@ -0,0 +1,426 @@
+// Package mqtt publishes retained status messages to an MQTT 3.1.1 broker
+// It speaks just enough of the protocol to publish: no subscriptions and no persistent sessions
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+)
+
+const (
+	// timeout bounds connecting and each exchange with the broker
+	timeout = 10 * time.Second
+
+	// keepAlive is announced to the broker; the publisher pings after half of it without traffic
+	keepAlive = 60 * time.Second
+
+	// Reconnect backoff after a failure, doubling up to the maximum
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+
+	// closeWait bounds how long Close waits for the last publishes
+	closeWait = 5 * time.Second
+)
+
+// Options configures a Publisher
+type Options struct {
+	// BrokerURL is mqtt://host:1883 or mqtts://host:8883
+	BrokerURL    string
+	ClientID     string
+	Username     string
+	PasswordFile string
+	QoS          byte
+	Retain       bool
+}
+
+// Stats describes the publisher for metrics
+type Stats struct {
+	Connected bool
+	Topics    int
+	Published int64
+	Errors    int64
+}
+
+// Publisher keeps the latest message of every topic and publishes changed ones in the background
+// Messages are coalesced per topic, so a slow or unreachable broker only ever gets the newest state;
+// after reconnecting every topic is published again
+type Publisher struct {
+	opts    Options
+	address string
+	useTLS  bool
+
+	mu     sync.Mutex
+	latest map[string][]byte
+	dirty  map[string]bool
+	stats  Stats
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+
+	// Owned by the worker
+	conn     net.Conn
+	reader   *bufio.Reader
+	packetID uint16
+}
+
+// ParseBrokerURL returns the host:port and whether to use TLS for an mqtt:// or mqtts:// URL
+func ParseBrokerURL(rawURL string) (string, bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", false, fmt.Errorf("MQTT_BROKER_URL must look like mqtt://host:1883")
+	}
+	if u.Scheme != "mqtt" && u.Scheme != "mqtts" {
+		return "", false, fmt.Errorf("MQTT_BROKER_URL scheme must be mqtt or mqtts")
+	}
+	if u.User != nil {
+		return "", false, fmt.Errorf("MQTT_BROKER_URL must not contain credentials, use MQTT_USERNAME and MQTT_PASSWORD_FILE")
+	}
+	useTLS := u.Scheme == "mqtts"
+	address := u.Host
+	if u.Port() == "" {
+		port := "1883"
+		if useTLS {
+			port = "8883"
+		}
+		address = net.JoinHostPort(u.Hostname(), port)
+	}
+	return address, useTLS, nil
+}
+
+// ValidateTopic reports why a topic or topic template can't be published to
+func ValidateTopic(topic string) error {
+	switch {
+	case topic == "":
+		return errors.New("topic is empty")
+	case strings.ContainsAny(topic, "+#\x00"):
+		return fmt.Errorf("topic %q must not contain wildcards", topic)
+	case len(topic) > 65535:
+		return errors.New("topic is too long")
+	}
+	return nil
+}
+
+// NewPublisher validates the options and starts the background worker; the broker is connected on the first publish
+func NewPublisher(opts Options) (*Publisher, error) {
+	address, useTLS, err := ParseBrokerURL(opts.BrokerURL)
+	if err != nil {
+		return nil, err
+	}
+	if opts.QoS > 2 {
+		return nil, fmt.Errorf("QoS %d is not 0, 1 or 2", opts.QoS)
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "bitwarden-reader"
+	}
+	p := &Publisher{
+		opts:    opts,
+		address: address,
+		useTLS:  useTLS,
+		latest:  make(map[string][]byte),
+		dirty:   make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// Enabled reports whether a broker is configured
+func (p *Publisher) Enabled() bool {
+	return p != nil
+}
+
+// Publish sets the message of a topic; unchanged messages are not sent again
+func (p *Publisher) Publish(topic string, payload []byte) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if previous, ok := p.latest[topic]; ok && bytes.Equal(previous, payload) {
+		p.mu.Unlock()
+		return
+	}
+	p.latest[topic] = payload
+	p.dirty[topic] = true
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stats returns the connection state and counters
+func (p *Publisher) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.Topics = len(p.latest)
+	return stats
+}
+
+// Close publishes what is pending, within closeWait, and disconnects
+func (p *Publisher) Close() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	timer := time.NewTimer(closeWait)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+	case <-timer.C:
+	}
+}
+
+// run publishes changed topics as they come in, reconnecting with backoff after failures
+func (p *Publisher) run() {
+	defer close(p.done)
+	ping := time.NewTicker(keepAlive / 2)
+	defer ping.Stop()
+
+	backoff := minBackoff
+	for {
+		if err := p.flush(); err != nil {
+			p.fail(err, backoff)
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = minBackoff
+
+		select {
+		case <-p.stop:
+			if err := p.flush(); err != nil {
+				p.fail(err, 0)
+			}
+			p.disconnect()
+			return
+		case <-p.wake:
+		case <-ping.C:
+			if p.conn != nil {
+				if err := p.ping(); err != nil {
+					p.fail(err, 0)
+				}
+			}
+		}
+	}
+}
+
+// flush publishes every changed topic, connecting first when needed
+// A lost connection is restored even without changes, since connecting publishes every topic again
+func (p *Publisher) flush() error {
+	p.mu.Lock()
+	idle := len(p.dirty) == 0 && (p.conn != nil || len(p.latest) == 0)
+	p.mu.Unlock()
+	if idle {
+		return nil
+	}
+
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	topics := make([]string, 0, len(p.dirty))
+	for topic := range p.dirty {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	payloads := make([][]byte, len(topics))
+	for i, topic := range topics {
+		payloads[i] = p.latest[topic]
+		delete(p.dirty, topic)
+	}
+	p.mu.Unlock()
+
+	for i, topic := range topics {
+		if err := p.publish(topic, payloads[i]); err != nil {
+			// Topics not published yet stay pending for the next connection
+			p.mu.Lock()
+			for _, remaining := range topics[i:] {
+				p.dirty[remaining] = true
+			}
+			p.mu.Unlock()
+			return fmt.Errorf("failed to publish to %s: %w", topic, err)
+		}
+		p.mu.Lock()
+		p.stats.Published++
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// fail drops the connection after an error so the next flush reconnects
+func (p *Publisher) fail(err error, retryIn time.Duration) {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	p.mu.Lock()
+	p.stats.Connected = false
+	p.stats.Errors++
+	p.mu.Unlock()
+	if retryIn > 0 {
+		logging.Printf("MQTT error, retrying in %s: %v", retryIn, err)
+	} else {
+		logging.Printf("MQTT error: %v", err)
+	}
+}
+
+// connect dials the broker and opens a clean session
+func (p *Publisher) connect() error {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if p.useTLS {
+		host, _, _ := net.SplitHostPort(p.address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.address, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	} else {
+		conn, err = dialer.Dial("tcp", p.address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+
+	password := ""
+	if p.opts.PasswordFile != "" {
+		data, err := os.ReadFile(p.opts.PasswordFile)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to read MQTT password file: %w", err)
+		}
+		password = strings.TrimSpace(string(data))
+	}
+
+	p.conn = conn
+	p.reader = bufio.NewReader(conn)
+	if err := p.send(connectPacket(p.opts.ClientID, p.opts.Username, password, uint16(keepAlive/time.Second))); err != nil {
+		return err
+	}
+	kind, body, err := readPacket(p.reader)
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to read CONNACK: %w", err)
+	case kind != packetConnAck || len(body) != 2:
+		return fmt.Errorf("unexpected packet type %d instead of CONNACK", kind)
+	case body[1] != 0:
+		reason, ok := connAckReasons[body[1]]
+		if !ok {
+			reason = fmt.Sprintf("return code %d", body[1])
+		}
+		return fmt.Errorf("MQTT broker refused the connection: %s", reason)
+	}
+
+	// The broker may have lost messages in flight, or restarted without its retained ones
+	p.mu.Lock()
+	for topic := range p.latest {
+		p.dirty[topic] = true
+	}
+	p.stats.Connected = true
+	p.mu.Unlock()
+	logging.Printf("Connected to MQTT broker %s", p.address)
+	return nil
+}
+
+// send writes a packet within the timeout
+func (p *Publisher) send(packet []byte) error {
+	if err := p.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	_, err := p.conn.Write(packet)
+	return err
+}
+
+// publish sends one message and waits for its acknowledgement at QoS 1 and 2
+func (p *Publisher) publish(topic string, payload []byte) error {
+	var id uint16
+	if p.opts.QoS > 0 {
+		p.packetID++
+		if p.packetID == 0 {
+			p.packetID = 1
+		}
+		id = p.packetID
+	}
+	if err := p.send(publishPacket(topic, payload, p.opts.QoS, p.opts.Retain, id)); err != nil {
+		return err
+	}
+	switch p.opts.QoS {
+	case 1:
+		return p.expect(packetPubAck, id)
+	case 2:
+		if err := p.expect(packetPubRec, id); err != nil {
+			return err
+		}
+		if err := p.send(idPacket(packetPubRel<<4|0x02, id)); err != nil {
+			return err
+		}
+		return p.expect(packetPubComp, id)
+	}
+	return nil
+}
+
+// ping checks the connection is alive and keeps the broker from closing it
+func (p *Publisher) ping() error {
+	if err := p.send(packet(packetPingReq<<4, nil)); err != nil {
+		return err
+	}
+	return p.expect(packetPingResp, 0)
+}
+
+// expect reads packets until the one of kind for packet identifier id, skipping ping responses
+func (p *Publisher) expect(kind byte, id uint16) error {
+	for {
+		got, body, err := readPacket(p.reader)
+		if err != nil {
+			return err
+		}
+		if got == packetPingResp && kind != packetPingResp {
+			continue
+		}
+		if got != kind {
+			return fmt.Errorf("unexpected packet type %d instead of %d", got, kind)
+		}
+		if kind == packetPingResp {
+			return nil
+		}
+		if len(body) < 2 || binary.BigEndian.Uint16(body) != id {
+			return fmt.Errorf("acknowledgement for the wrong packet")
+		}
+		return nil
+	}
+}
+
+// disconnect ends the session cleanly
+func (p *Publisher) disconnect() {
+	if p.conn == nil {
+		return
+	}
+	_ = p.send(packet(packetDisconnect<<4, nil))
+	p.conn.Close()
+	p.conn = nil
+	p.mu.Lock()
+	p.stats.Connected = false
+	p.mu.Unlock()
+}
@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// sendTimeout bounds each delivery to a channel
+const sendTimeout = 10 * time.Second
+
+// Notification is a routed event as delivered to channels
+type Notification struct {
+	Type      string    `json:"event"`
+	Secret    string    `json:"secret"`
+	Namespace string    `json:"namespace"`
+	Group     string    `json:"group,omitempty"`
+	Rule      string    `json:"rule,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	Time      time.Time `json:"time"`
+	Severity  string    `json:"severity"`
+	Route     string    `json:"route"`
+	// Escalated is set when the notification was sent during quiet hours because it persisted
+	Escalated bool `json:"escalated,omitempty"`
+}
+
+// summary is a one-line human-readable description
+func (n Notification) summary() string {
+	var b strings.Builder
+	if n.Escalated {
+		b.WriteString("[ESCALATED] ")
+	}
+	fmt.Fprintf(&b, "[%s] %s", strings.ToUpper(n.Severity), n.Type)
+	if n.Rule != "" {
+		fmt.Fprintf(&b, " %s", n.Rule)
+	}
+	fmt.Fprintf(&b, ": secret %s in %s", n.Secret, n.Namespace)
+	if n.Group != "" {
+		fmt.Fprintf(&b, " (group %s)", n.Group)
+	}
+	if n.Detail != "" {
+		b.WriteString(" - " + n.Detail)
+	}
+	return b.String()
+}
+
+// Channel delivers notifications to one destination
+type Channel interface {
+	Send(ctx context.Context, notification Notification) error
+}
+
+// newChannel creates the channel for a validated config
+func newChannel(cfg ChannelConfig, client *http.Client) Channel {
+	target := webhookTarget{url: cfg.URL, urlFile: cfg.URLFile, client: client}
+	switch cfg.Type {
+	case channelSlack:
+		return slackChannel{target}
+	case channelPagerDuty:
+		return newPagerDutyChannel(cfg, client)
+	case channelOpsgenie:
+		return newOpsgenieChannel(cfg, client)
+	default:
+		return webhookChannel{target}
+	}
+}
+
+// webhookTarget posts JSON to a URL, read from a file when one is configured
+type webhookTarget struct {
+	url     string
+	urlFile string
+	client  *http.Client
+}
+
+// post sends body to the configured URL
+func (t webhookTarget) post(ctx context.Context, body interface{}) error {
+	target, err := valueOrFile(t.url, t.urlFile)
+	if err != nil {
+		return fmt.Errorf("failed to read URL file: %w", err)
+	}
+	return postJSON(ctx, t.client, target, nil, body)
+}
+
+// valueOrFile returns value, or the trimmed contents of file when it is set
+func valueOrFile(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// postJSON posts body with the headers and fails on non-2xx responses
+func postJSON(ctx context.Context, client *http.Client, target string, headers map[string]string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL may embed a token, so only the underlying failure is reported
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// slackChannel posts to a Slack incoming webhook
+type slackChannel struct {
+	webhookTarget
+}
+
+// Send posts the summary as the message text
+func (c slackChannel) Send(ctx context.Context, notification Notification) error {
+	return c.post(ctx, map[string]string{"text": notification.summary()})
+}
+
+// webhookChannel posts the notification as JSON
+type webhookChannel struct {
+	webhookTarget
+}
+
+// Send posts the notification
+func (c webhookChannel) Send(ctx context.Context, notification Notification) error {
+	return c.post(ctx, notification)
+}
@@ -0,0 +1,334 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+	// Quiet hours name IANA time zones, which minimal images do not ship
+	_ "time/tzdata"
+
+	"bitwarden-reader/internal/hooks"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Severities, from least to most urgent
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// severityRank orders severities for minSeverity
+var severityRank = map[string]int{
+	SeverityInfo:     0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+}
+
+// defaultSeverities maps event types to severities unless the config overrides them
+var defaultSeverities = map[string]string{
+	hooks.EventDataChanged:   SeverityInfo,
+	hooks.EventSyncFailed:    SeverityCritical,
+	hooks.EventSyncRecovered: SeverityInfo,
+	hooks.EventFlapping:      SeverityWarning,
+	hooks.EventFlapStopped:   SeverityInfo,
+	hooks.EventAlertFiring:   SeverityWarning,
+	hooks.EventAlertResolved: SeverityInfo,
+	hooks.EventSecretDeleted: SeverityWarning,
+}
+
+// defaultDedupWindow suppresses repeats of the same event for a secret on a route
+const defaultDedupWindow = 10 * time.Minute
+
+// Config is the NOTIFY_CONFIG_FILE format (YAML or JSON)
+// Durations use Go syntax, e.g. "30m" or "1h"
+type Config struct {
+	Channels map[string]ChannelConfig `json:"channels"`
+	// Severities overrides the severity of event types, e.g. data-changed: warning
+	Severities  map[string]string `json:"severities,omitempty"`
+	DedupWindow string            `json:"dedupWindow,omitempty"`
+	Routes      []Route           `json:"routes"`
+
+	dedupWindow time.Duration
+}
+
+// Channel types
+const (
+	channelSlack     = "slack"
+	channelWebhook   = "webhook"
+	channelPagerDuty = "pagerduty"
+	channelOpsgenie  = "opsgenie"
+)
+
+// ChannelConfig is one destination for notifications
+// Credentials may be given inline or as a file, which is re-read for every notification
+type ChannelConfig struct {
+	// Type is slack (incoming webhook), webhook (JSON POST), pagerduty (Events API v2), or opsgenie
+	Type string `json:"type"`
+	// URL is the webhook URL; for pagerduty and opsgenie it overrides the API endpoint
+	URL     string `json:"url,omitempty"`
+	URLFile string `json:"urlFile,omitempty"`
+	// RoutingKey is the PagerDuty integration key
+	RoutingKey     string `json:"routingKey,omitempty"`
+	RoutingKeyFile string `json:"routingKeyFile,omitempty"`
+	// APIKey is the Opsgenie API integration key
+	APIKey     string `json:"apiKey,omitempty"`
+	APIKeyFile string `json:"apiKeyFile,omitempty"`
+}
+
+// validate checks that the channel has what its type needs
+func (c ChannelConfig) validate() error {
+	exactlyOne := func(field, value, file string) error {
+		if (value == "") == (file == "") {
+			return fmt.Errorf("exactly one of %s and %sFile is required", field, field)
+		}
+		return nil
+	}
+	switch c.Type {
+	case channelSlack, channelWebhook:
+		return exactlyOne("url", c.URL, c.URLFile)
+	case channelPagerDuty:
+		return exactlyOne("routingKey", c.RoutingKey, c.RoutingKeyFile)
+	case channelOpsgenie:
+		return exactlyOne("apiKey", c.APIKey, c.APIKeyFile)
+	default:
+		return fmt.Errorf("type must be slack, webhook, pagerduty, or opsgenie")
+	}
+}
+
+// Route sends matching events to channels; routes are tried in order and the first match wins
+// unless it sets continue. Empty match lists match everything; Rules lists alert rule names,
+// so a route with rules only matches alert events
+type Route struct {
+	Name string `json:"name,omitempty"`
+	// Secrets are secret names or path.Match patterns such as "prod-*"
+	Secrets     []string `json:"secrets,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+	Namespaces  []string `json:"namespaces,omitempty"`
+	Events      []string `json:"events,omitempty"`
+	Rules       []string `json:"rules,omitempty"`
+	MinSeverity string   `json:"minSeverity,omitempty"`
+	// Severity replaces the event's severity for this route
+	Severity    string      `json:"severity,omitempty"`
+	Channels    []string    `json:"channels"`
+	DedupWindow string      `json:"dedupWindow,omitempty"`
+	QuietHours  *QuietHours `json:"quietHours,omitempty"`
+	Continue    bool        `json:"continue,omitempty"`
+
+	dedupWindow time.Duration
+}
+
+// QuietHours holds notifications between Start and End (HH:MM, wrapping past midnight when End is earlier)
+// Held notifications are sent when quiet hours end, or once they persisted for EscalateAfter
+type QuietHours struct {
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Timezone      string `json:"timezone,omitempty"`
+	EscalateAfter string `json:"escalateAfter,omitempty"`
+	// EscalateTo receives escalated notifications instead of the route's channels
+	EscalateTo []string `json:"escalateTo,omitempty"`
+
+	start, end    int
+	location      *time.Location
+	escalateAfter time.Duration
+}
+
+// LoadConfig reads and validates a notification config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification config: %w", err)
+	}
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse notification config %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid notification config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// validate checks references and parses durations and quiet hours
+func (c *Config) validate() error {
+	for name, channel := range c.Channels {
+		if err := channel.validate(); err != nil {
+			return fmt.Errorf("channel %s: %w", name, err)
+		}
+	}
+	for event, severity := range c.Severities {
+		if _, ok := severityRank[severity]; !ok {
+			return fmt.Errorf("severities: invalid severity %q for %s", severity, event)
+		}
+	}
+
+	var err error
+	c.dedupWindow = defaultDedupWindow
+	if c.DedupWindow != "" {
+		if c.dedupWindow, err = parseDuration("dedupWindow", c.DedupWindow); err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]bool, len(c.Routes))
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route-%d", i+1)
+		}
+		if names[route.Name] {
+			return fmt.Errorf("duplicate route name %q", route.Name)
+		}
+		names[route.Name] = true
+		if err := route.validate(c); err != nil {
+			return fmt.Errorf("%s: %w", route.Name, err)
+		}
+	}
+	return nil
+}
+
+// validate checks one route against the configured channels
+func (r *Route) validate(c *Config) error {
+	if len(r.Channels) == 0 {
+		return fmt.Errorf("at least one channel is required")
+	}
+	if err := checkChannels(c, r.Channels); err != nil {
+		return err
+	}
+	for _, pattern := range r.Secrets {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid secret pattern %q", pattern)
+		}
+	}
+	for _, severity := range []string{r.MinSeverity, r.Severity} {
+		if _, ok := severityRank[severity]; severity != "" && !ok {
+			return fmt.Errorf("invalid severity %q", severity)
+		}
+	}
+
+	var err error
+	r.dedupWindow = c.dedupWindow
+	if r.DedupWindow != "" {
+		if r.dedupWindow, err = parseDuration("dedupWindow", r.DedupWindow); err != nil {
+			return err
+		}
+	}
+	if r.QuietHours != nil {
+		if err := r.QuietHours.validate(c); err != nil {
+			return fmt.Errorf("quietHours: %w", err)
+		}
+	}
+	return nil
+}
+
+// validate parses the quiet hours window and escalation
+func (q *QuietHours) validate(c *Config) error {
+	var err error
+	if q.start, err = parseClock(q.Start); err != nil {
+		return err
+	}
+	if q.end, err = parseClock(q.End); err != nil {
+		return err
+	}
+	q.location = time.UTC
+	if q.Timezone != "" {
+		if q.location, err = time.LoadLocation(q.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", q.Timezone, err)
+		}
+	}
+	if q.EscalateAfter != "" {
+		if q.escalateAfter, err = parseDuration("escalateAfter", q.EscalateAfter); err != nil {
+			return err
+		}
+	}
+	return checkChannels(c, q.EscalateTo)
+}
+
+// active reports whether now falls within the quiet hours
+func (q *QuietHours) active(now time.Time) bool {
+	local := now.In(q.location)
+	minute := local.Hour()*60 + local.Minute()
+	if q.start <= q.end {
+		return minute >= q.start && minute < q.end
+	}
+	return minute >= q.start || minute < q.end
+}
+
+// checkChannels reports the first name that is not a configured channel
+func checkChannels(c *Config, names []string) error {
+	for _, name := range names {
+		if _, ok := c.Channels[name]; !ok {
+			return fmt.Errorf("unknown channel %q", name)
+		}
+	}
+	return nil
+}
+
+// parseClock parses HH:MM into minutes after midnight
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// parseDuration parses a positive Go duration
+func parseDuration(field, value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid %s %q", field, value)
+	}
+	return duration, nil
+}
+
+// severity returns the configured or default severity of an event type
+func (c *Config) severity(eventType string) string {
+	if severity, ok := c.Severities[eventType]; ok {
+		return severity
+	}
+	if severity, ok := defaultSeverities[eventType]; ok {
+		return severity
+	}
+	return SeverityInfo
+}
+
+// matches reports whether the route applies to the event with the given severity
+func (r *Route) matches(event hooks.Event, severity string) bool {
+	if r.MinSeverity != "" && severityRank[severity] < severityRank[r.MinSeverity] {
+		return false
+	}
+	if len(r.Events) > 0 && !contains(r.Events, event.Type) {
+		return false
+	}
+	if len(r.Rules) > 0 && !contains(r.Rules, event.Rule) {
+		return false
+	}
+	if len(r.Groups) > 0 && !contains(r.Groups, event.Group) {
+		return false
+	}
+	if len(r.Namespaces) > 0 && !contains(r.Namespaces, event.Namespace) {
+		return false
+	}
+	if len(r.Secrets) == 0 {
+		return true
+	}
+	for _, pattern := range r.Secrets {
+		if ok, _ := path.Match(pattern, event.Secret); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
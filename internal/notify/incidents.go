@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Default incident API endpoints; ChannelConfig.URL overrides them, e.g. for the Opsgenie EU instance
+const (
+	pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	opsgenieAlertsURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// errNotIncident is returned by incident channels for events that neither open nor resolve an incident
+var errNotIncident = errors.New("event does not open or resolve an incident")
+
+// incidentAction classifies the notification for incident tools: "trigger" for problems,
+// "resolve" for recoveries, and "" for informational events such as data-changed
+func (n Notification) incidentAction() string {
+	if _, ok := resolves[n.Type]; ok {
+		return "resolve"
+	}
+	if isProblem(n.Type) {
+		return "trigger"
+	}
+	return ""
+}
+
+// isProblem reports whether the event type is resolved by a later event
+func isProblem(eventType string) bool {
+	for _, problem := range resolves {
+		if problem == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// IncidentKey is the stable deduplication key of the incident the notification opens or resolves
+// Retriggers while the incident is open are merged by the incident tool
+func (n Notification) IncidentKey() string {
+	problem := n.Type
+	if resolved, ok := resolves[n.Type]; ok {
+		problem = resolved
+	}
+	if n.Rule != "" {
+		problem += "/" + n.Rule
+	}
+	return fmt.Sprintf("bitwarden-reader/%s/%s/%s", n.Namespace, n.Secret, problem)
+}
+
+// pagerDutyChannel sends Events API v2 trigger and resolve events
+type pagerDutyChannel struct {
+	url            string
+	routingKey     string
+	routingKeyFile string
+	client         *http.Client
+}
+
+// newPagerDutyChannel creates a PagerDuty channel
+func newPagerDutyChannel(cfg ChannelConfig, client *http.Client) pagerDutyChannel {
+	endpoint := cfg.URL
+	if endpoint == "" {
+		endpoint = pagerDutyEventsURL
+	}
+	return pagerDutyChannel{url: endpoint, routingKey: cfg.RoutingKey, routingKeyFile: cfg.RoutingKeyFile, client: client}
+}
+
+// pagerDutySeverities maps severities to PagerDuty's
+var pagerDutySeverities = map[string]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+// Send triggers or resolves the secret's incident
+func (c pagerDutyChannel) Send(ctx context.Context, notification Notification) error {
+	action := notification.incidentAction()
+	if action == "" {
+		return errNotIncident
+	}
+	routingKey, err := valueOrFile(c.routingKey, c.routingKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read routing key file: %w", err)
+	}
+
+	event := map[string]interface{}{
+		"routing_key":  routingKey,
+		"event_action": action,
+		"dedup_key":    notification.IncidentKey(),
+	}
+	if action == "trigger" {
+		event["payload"] = map[string]interface{}{
+			"summary":   notification.summary(),
+			"source":    "bitwarden-reader/" + notification.Namespace,
+			"severity":  pagerDutySeverities[notification.Severity],
+			"timestamp": notification.Time.Format(time.RFC3339),
+			"component": notification.Secret,
+			"group":     notification.Group,
+			"class":     notification.Type,
+			"custom_details": map[string]string{
+				"detail": notification.Detail,
+				"route":  notification.Route,
+				"rule":   notification.Rule,
+			},
+		}
+	}
+	return postJSON(ctx, c.client, c.url, nil, event)
+}
+
+// opsgenieChannel creates alerts and closes them by alias
+type opsgenieChannel struct {
+	url        string
+	apiKey     string
+	apiKeyFile string
+	client     *http.Client
+}
+
+// newOpsgenieChannel creates an Opsgenie channel
+func newOpsgenieChannel(cfg ChannelConfig, client *http.Client) opsgenieChannel {
+	endpoint := cfg.URL
+	if endpoint == "" {
+		endpoint = opsgenieAlertsURL
+	}
+	return opsgenieChannel{url: strings.TrimSuffix(endpoint, "/"), apiKey: cfg.APIKey, apiKeyFile: cfg.APIKeyFile, client: client}
+}
+
+// opsgeniePriorities maps severities to Opsgenie priorities
+var opsgeniePriorities = map[string]string{
+	SeverityInfo:     "P5",
+	SeverityWarning:  "P3",
+	SeverityCritical: "P1",
+}
+
+// Send creates the secret's alert, or closes it on recovery
+func (c opsgenieChannel) Send(ctx context.Context, notification Notification) error {
+	action := notification.incidentAction()
+	if action == "" {
+		return errNotIncident
+	}
+	apiKey, err := valueOrFile(c.apiKey, c.apiKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read API key file: %w", err)
+	}
+	headers := map[string]string{"Authorization": "GenieKey " + apiKey}
+	alias := notification.IncidentKey()
+
+	if action == "resolve" {
+		target := c.url + "/" + url.PathEscape(alias) + "/close?identifierType=alias"
+		return postJSON(ctx, c.client, target, headers, map[string]string{
+			"source": "bitwarden-reader",
+			"note":   notification.summary(),
+		})
+	}
+
+	tags := []string{"bitwarden-reader", notification.Type}
+	if notification.Group != "" {
+		tags = append(tags, notification.Group)
+	}
+	if notification.Rule != "" {
+		tags = append(tags, notification.Rule)
+	}
+	return postJSON(ctx, c.client, c.url, headers, map[string]interface{}{
+		"message":     truncateRunes(notification.summary(), 130),
+		"alias":       alias,
+		"description": notification.Detail,
+		"priority":    opsgeniePriorities[notification.Severity],
+		"source":      "bitwarden-reader",
+		"entity":      notification.Namespace + "/" + notification.Secret,
+		"tags":        tags,
+		"details": map[string]string{
+			"secret":    notification.Secret,
+			"namespace": notification.Namespace,
+			"group":     notification.Group,
+			"route":     notification.Route,
+			"rule":      notification.Rule,
+		},
+	})
+}
+
+// truncateRunes shortens s to at most n characters, Opsgenie's limit for alert messages
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,308 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/hooks"
+	"bitwarden-reader/internal/logging"
+)
+
+// queueSize is the number of events that may wait for the notifier
+const queueSize = 256
+
+// releaseInterval is how often held notifications are checked against quiet hours
+const releaseInterval = 30 * time.Second
+
+// resolves maps an event type to the event type it resolves
+var resolves = map[string]string{
+	hooks.EventSyncRecovered: hooks.EventSyncFailed,
+	hooks.EventFlapStopped:   hooks.EventFlapping,
+	hooks.EventAlertResolved: hooks.EventAlertFiring,
+}
+
+// openProblem records where a problem notification was delivered, so its recovery follows it there
+type openProblem struct {
+	route    string
+	channels []string
+}
+
+// heldNotification waits for quiet hours to end
+type heldNotification struct {
+	notification Notification
+	route        *Route
+	heldAt       time.Time
+}
+
+// Notifier routes secret events to channels, with deduplication and quiet hours
+type Notifier struct {
+	config   *Config
+	channels map[string]Channel
+	audit    *audit.Logger
+	queue    chan hooks.Event
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+	held []heldNotification
+	// open holds delivered problems by secret and event type until they are resolved
+	open map[string]openProblem
+}
+
+// NewNotifier starts a notifier for the config; it returns nil when cfg is nil
+func NewNotifier(cfg *Config, auditLogger *audit.Logger) *Notifier {
+	if cfg == nil {
+		return nil
+	}
+	client := &http.Client{Timeout: sendTimeout}
+	n := &Notifier{
+		config:   cfg,
+		channels: make(map[string]Channel, len(cfg.Channels)),
+		audit:    auditLogger,
+		queue:    make(chan hooks.Event, queueSize),
+		sent:     make(map[string]time.Time),
+		open:     make(map[string]openProblem),
+	}
+	for name, channel := range cfg.Channels {
+		n.channels[name] = newChannel(channel, client)
+	}
+	go n.run()
+	return n
+}
+
+// Enabled reports whether notifications are configured
+func (n *Notifier) Enabled() bool {
+	return n != nil
+}
+
+// Notify queues an event without blocking; events are dropped when the queue is full
+func (n *Notifier) Notify(event hooks.Event) {
+	if n == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	select {
+	case n.queue <- event:
+	default:
+		logging.Printf("Notification queue full, dropping %s event for %s", event.Type, event.Secret)
+	}
+}
+
+// run routes queued events and releases held notifications
+func (n *Notifier) run() {
+	ticker := time.NewTicker(releaseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-n.queue:
+			n.route(event, time.Now())
+		case now := <-ticker.C:
+			n.release(now)
+		}
+	}
+}
+
+// route sends the event through the first matching route, and further ones while they set continue
+// Recoveries are not routed: they go to the channels that received the problem, which lets
+// incident channels resolve what they opened
+func (n *Notifier) route(event hooks.Event, now time.Time) {
+	if resolved, ok := resolves[event.Type]; ok {
+		problem, found := n.resolve(event.Secret, resolved, event.Rule)
+		if found {
+			n.deliver(Notification{
+				Type:      event.Type,
+				Secret:    event.Secret,
+				Namespace: event.Namespace,
+				Group:     event.Group,
+				Rule:      event.Rule,
+				Detail:    event.Detail,
+				Time:      event.Time,
+				Severity:  n.config.severity(event.Type),
+				Route:     problem.route,
+			}, problem.channels)
+		}
+		return
+	}
+
+	severity := n.config.severity(event.Type)
+	if event.Severity != "" {
+		severity = event.Severity
+	}
+	for i := range n.config.Routes {
+		route := &n.config.Routes[i]
+		if !route.matches(event, severity) {
+			continue
+		}
+
+		notification := Notification{
+			Type:      event.Type,
+			Secret:    event.Secret,
+			Namespace: event.Namespace,
+			Group:     event.Group,
+			Rule:      event.Rule,
+			Detail:    event.Detail,
+			Time:      event.Time,
+			Severity:  severity,
+			Route:     route.Name,
+		}
+		if route.Severity != "" {
+			notification.Severity = route.Severity
+		}
+		n.dispatch(notification, route, now)
+
+		if !route.Continue {
+			return
+		}
+	}
+}
+
+// resolve forgets the resolved event type (and alert rule) for the secret, so a recurrence notifies
+// right away, and returns where the problem was delivered; a problem still held for quiet hours is dropped
+func (n *Notifier) resolve(secret, eventType, rule string) (openProblem, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	key := problemKey(secret, eventType, rule)
+	for i := range n.config.Routes {
+		delete(n.sent, n.config.Routes[i].Name+"\x00"+key)
+	}
+
+	kept := n.held[:0]
+	for _, held := range n.held {
+		if held.notification.problemKey() != key {
+			kept = append(kept, held)
+		}
+	}
+	n.held = kept
+
+	problem, found := n.open[key]
+	delete(n.open, key)
+	return problem, found
+}
+
+// dispatch applies deduplication and quiet hours to a routed notification
+func (n *Notifier) dispatch(notification Notification, route *Route, now time.Time) {
+	n.mu.Lock()
+	key := route.Name + "\x00" + notification.problemKey()
+	if last, ok := n.sent[key]; ok && now.Sub(last) < route.dedupWindow {
+		n.mu.Unlock()
+		logging.Printf("Suppressed duplicate %s notification for %s on %s", notification.Type, notification.Secret, route.Name)
+		return
+	}
+	n.sent[key] = now
+
+	if route.QuietHours != nil && route.QuietHours.active(now) {
+		n.held = append(n.held, heldNotification{notification: notification, route: route, heldAt: now})
+		n.mu.Unlock()
+		return
+	}
+	n.mu.Unlock()
+
+	n.deliver(notification, route.Channels)
+}
+
+// release sends held notifications whose quiet hours ended or that persisted past EscalateAfter
+func (n *Notifier) release(now time.Time) {
+	type release struct {
+		notification Notification
+		channels     []string
+	}
+	var due []release
+
+	n.mu.Lock()
+	kept := n.held[:0]
+	for _, held := range n.held {
+		quiet := held.route.QuietHours
+		switch {
+		case !quiet.active(now):
+			due = append(due, release{held.notification, held.route.Channels})
+		case quiet.escalateAfter > 0 && now.Sub(held.heldAt) >= quiet.escalateAfter:
+			held.notification.Escalated = true
+			channels := held.route.Channels
+			if len(quiet.EscalateTo) > 0 {
+				channels = quiet.EscalateTo
+			}
+			due = append(due, release{held.notification, channels})
+		default:
+			kept = append(kept, held)
+		}
+	}
+	n.held = kept
+	n.mu.Unlock()
+
+	for _, r := range due {
+		n.deliver(r.notification, r.channels)
+	}
+}
+
+// deliver sends the notification to each channel and records the result in the audit log
+func (n *Notifier) deliver(notification Notification, channels []string) {
+	if isProblem(notification.Type) {
+		n.trackOpen(notification, channels)
+	}
+	for _, name := range channels {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err := n.channels[name].Send(ctx, notification)
+		cancel()
+		if errors.Is(err, errNotIncident) {
+			continue
+		}
+
+		details := map[string]string{
+			"channel":  name,
+			"event":    notification.Type,
+			"route":    notification.Route,
+			"severity": notification.Severity,
+		}
+		outcome := audit.OutcomeSuccess
+		if err != nil {
+			outcome = audit.OutcomeFailure
+			details["error"] = err.Error()
+			logging.Printf("Notification for %s to %s failed: %v", notification.Secret, name, err)
+		}
+		if notification.Escalated {
+			details["escalated"] = "true"
+		}
+		n.audit.Record(audit.Event{
+			Action:    "notify.send",
+			Actor:     "system",
+			Resource:  notification.Secret,
+			Namespace: notification.Namespace,
+			Outcome:   outcome,
+			Details:   details,
+		})
+	}
+}
+
+// trackOpen adds the channels to the problem's open record
+func (n *Notifier) trackOpen(notification Notification, channels []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	key := notification.problemKey()
+	problem := n.open[key]
+	if problem.route == "" {
+		problem.route = notification.Route
+	}
+	for _, channel := range channels {
+		if !contains(problem.channels, channel) {
+			problem.channels = append(problem.channels, channel)
+		}
+	}
+	n.open[key] = problem
+}
+
+// problemKey identifies an event type, and the alert rule for alert events, of a secret
+func problemKey(secret, eventType, rule string) string {
+	return secret + "\x00" + eventType + "\x00" + rule
+}
+
+// problemKey identifies the notification's event for deduplication and open problems
+func (n Notification) problemKey() string {
+	return problemKey(n.Secret, n.Type, n.Rule)
+}
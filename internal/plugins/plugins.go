@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+)
+
+// Processor post-processes a secret after it has been read
+// Processors may transform or add keys and report validation problems
+type Processor interface {
+	Name() string
+	Process(ctx context.Context, namespace string, secret *reader.SecretInfo) error
+}
+
+// Chain runs processors in order for every found secret
+type Chain struct {
+	processors []Processor
+}
+
+// NewChain creates a chain from the given processors
+func NewChain(processors ...Processor) *Chain {
+	return &Chain{processors: processors}
+}
+
+// NewExecChain creates a chain of subprocess processors, one per command
+func NewExecChain(commands []string, timeout time.Duration) *Chain {
+	processors := make([]Processor, 0, len(commands))
+	for _, command := range commands {
+		processors = append(processors, NewExecProcessor(command, timeout))
+	}
+	return NewChain(processors...)
+}
+
+// Len returns the number of processors in the chain
+func (c *Chain) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.processors)
+}
+
+// Apply runs every processor on every found secret
+// A failing processor is recorded as a validation error on the secret rather than aborting the read
+func (c *Chain) Apply(ctx context.Context, namespace string, secrets []reader.SecretInfo) {
+	if c.Len() == 0 {
+		return
+	}
+	for i := range secrets {
+		if !secrets[i].Found {
+			continue
+		}
+		for _, processor := range c.processors {
+			if err := processor.Process(ctx, namespace, &secrets[i]); err != nil {
+				secrets[i].ValidationErrors = append(secrets[i].ValidationErrors, fmt.Sprintf("plugin %s: %v", processor.Name(), err))
+			}
+		}
+	}
+}
+
+// Request is the JSON document written to an exec plugin's stdin
+type Request struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Group     string            `json:"group"`
+	Keys      map[string]string `json:"keys"`
+	SyncInfo  reader.SyncInfo   `json:"syncInfo"`
+}
+
+// Response is the JSON document an exec plugin writes to stdout
+type Response struct {
+	// Keys are added to the secret, replacing existing keys with the same name
+	Keys map[string]string `json:"keys,omitempty"`
+	// RemoveKeys are dropped from the secret, e.g. an inner layer that was decrypted
+	RemoveKeys []string `json:"removeKeys,omitempty"`
+	// Errors are validation failures reported for the secret
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ExecProcessor runs an external command per secret with JSON on stdin and stdout
+type ExecProcessor struct {
+	command string
+	timeout time.Duration
+}
+
+// NewExecProcessor creates a processor for the command
+func NewExecProcessor(command string, timeout time.Duration) *ExecProcessor {
+	return &ExecProcessor{command: command, timeout: timeout}
+}
+
+// Name returns the command's base name
+func (p *ExecProcessor) Name() string {
+	return filepath.Base(p.command)
+}
+
+// Process sends the secret to the command and merges its response
+func (p *ExecProcessor) Process(ctx context.Context, namespace string, secret *reader.SecretInfo) error {
+	input, err := json.Marshal(Request{
+		Name:      secret.Name,
+		Namespace: namespace,
+		Group:     secret.Group,
+		Keys:      secret.Keys,
+		SyncInfo:  secret.SyncInfo,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	defer clear(input)
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.command)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %s", p.timeout)
+		}
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return fmt.Errorf("%w: %s", err, detail)
+		}
+		return err
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	logging.RegisterSecrets("plugin/"+p.Name()+"/"+namespace+"/"+secret.Name, slices.Collect(maps.Values(resp.Keys))...)
+	merge(secret, resp)
+	return nil
+}
+
+// merge applies a plugin response to the secret
+func merge(secret *reader.SecretInfo, resp Response) {
+	if secret.Keys == nil {
+		secret.Keys = make(map[string]string)
+	}
+	for _, key := range resp.RemoveKeys {
+		delete(secret.Keys, key)
+	}
+	for key, value := range resp.Keys {
+		secret.Keys[key] = value
+	}
+	secret.ValidationErrors = append(secret.ValidationErrors, resp.Errors...)
+}
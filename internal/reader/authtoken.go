@@ -0,0 +1,22 @@
+package reader
+
+import (
+	"context"
+
+	"bitwarden-reader/internal/k8s"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// checkAuthTokens checks the auth-token Secret of every secret whose BitwardenSecret was found
+func checkAuthTokens(ctx context.Context, secrets []SecretInfo, namespace string, clientset kubernetes.Interface) {
+	checks := make(k8s.AuthTokenChecks)
+	for i := range secrets {
+		ref := secrets[i].SyncInfo.AuthToken
+		if ref == nil {
+			continue
+		}
+		check := checks.Check(ctx, clientset, namespace, k8s.AuthTokenRef{SecretName: ref.SecretName, SecretKey: ref.SecretKey})
+		secrets[i].SyncInfo.AuthToken = &check
+	}
+}
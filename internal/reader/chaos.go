@@ -0,0 +1,162 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kinds of simulated failures
+const (
+	// FaultSecretMissing reports the secret as not found
+	FaultSecretMissing = "secret-missing"
+	// FaultSyncFailing reports the secret's BitwardenSecret as failing to sync
+	FaultSyncFailing = "sync-failing"
+	// FaultLatency delays every read, as a slow API server would
+	FaultLatency = "latency"
+)
+
+// Fault is a simulated failure applied to reads until it expires
+type Fault struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// Secret and Source limit a secret-missing or sync-failing fault to one secret or source; empty matches all
+	Secret string `json:"secret,omitempty"`
+	Source string `json:"source,omitempty"`
+	// Reason and Message are the sync condition of a sync-failing fault
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// LatencyMs is the delay of a latency fault
+	LatencyMs int       `json:"latencyMs,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// matches reports whether the fault applies to the secret
+func (f Fault) matches(secret SecretInfo) bool {
+	return (f.Secret == "" || f.Secret == secret.Name) && (f.Source == "" || f.Source == secret.Source)
+}
+
+// Chaos holds the simulated failures injected for testing dashboards, alert rules, and runbooks
+// without breaking the operator; sources wrapped with Wrap apply them
+type Chaos struct {
+	mu     sync.Mutex
+	faults []Fault
+	nextID int
+}
+
+// NewChaos returns a Chaos without faults
+func NewChaos() *Chaos {
+	return &Chaos{}
+}
+
+// Inject adds a fault, assigning its ID
+func (c *Chaos) Inject(fault Fault) Fault {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	fault.ID = "fault-" + strconv.Itoa(c.nextID)
+	c.faults = append(c.faults, fault)
+	return fault
+}
+
+// Faults returns the faults that have not expired at now, oldest first
+func (c *Chaos) Faults(now time.Time) []Fault {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := c.faults[:0]
+	for _, fault := range c.faults {
+		if now.Before(fault.ExpiresAt) {
+			active = append(active, fault)
+		}
+	}
+	c.faults = active
+	return append([]Fault{}, active...)
+}
+
+// Remove deletes a fault and reports whether it existed
+func (c *Chaos) Remove(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, fault := range c.faults {
+		if fault.ID == id {
+			c.faults = append(c.faults[:i], c.faults[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clear deletes every fault and returns how many there were
+func (c *Chaos) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cleared := len(c.faults)
+	c.faults = nil
+	return cleared
+}
+
+// Wrap returns a source that applies the faults to what source reads
+func (c *Chaos) Wrap(source SecretSource) SecretSource {
+	return &chaosSource{SecretSource: source, chaos: c}
+}
+
+// chaosSource applies simulated failures to the secrets another source reads
+type chaosSource struct {
+	SecretSource
+	chaos *Chaos
+}
+
+// ReadSecrets waits out the longest latency fault, reads the secrets, and applies the other faults
+func (s *chaosSource) ReadSecrets(ctx context.Context, names []string) ([]SecretInfo, error) {
+	faults := s.chaos.Faults(time.Now())
+	latency := 0
+	for _, fault := range faults {
+		if fault.Kind == FaultLatency {
+			latency = max(latency, fault.LatencyMs)
+		}
+	}
+	if latency > 0 {
+		timer := time.NewTimer(time.Duration(latency) * time.Millisecond)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	secrets, err := s.SecretSource.ReadSecrets(ctx, names)
+	for i := range secrets {
+		secrets[i].Source = s.Name()
+		for _, fault := range faults {
+			if fault.matches(secrets[i]) {
+				applyFault(&secrets[i], fault)
+			}
+		}
+	}
+	return secrets, err
+}
+
+// applyFault changes the secret as the fault simulates
+func applyFault(secret *SecretInfo, fault Fault) {
+	switch fault.Kind {
+	case FaultSecretMissing:
+		*secret = SecretInfo{
+			Name:     secret.Name,
+			Source:   secret.Source,
+			Group:    secret.Group,
+			Keys:     make(map[string]string),
+			SyncInfo: secret.SyncInfo,
+			Error:    fmt.Sprintf("Secret '%s' not found (simulated by %s)", secret.Name, fault.ID),
+		}
+	case FaultSyncFailing:
+		secret.SyncInfo.CRDFound = true
+		secret.SyncInfo.SyncStatus = "False"
+		secret.SyncInfo.SyncReason = fault.Reason
+		secret.SyncInfo.SyncMessage = fmt.Sprintf("%s (simulated by %s)", fault.Message, fault.ID)
+	}
+}
@@ -0,0 +1,66 @@
+package reader
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sync consistency states, comparing a BitwardenSecret's status with the Secret it writes
+const (
+	// SyncConsistent means the Secret was written at or after the last successful sync, within the tolerance
+	SyncConsistent = "consistent"
+	// SyncPartial means the BitwardenSecret reports a successful sync the Secret doesn't show
+	SyncPartial = "partial"
+	// SyncUnknown means a sync time is missing or unreadable
+	SyncUnknown = "unknown"
+)
+
+// SyncConsistency compares the CRD's lastSuccessfulSyncTime with the Secret's sync-time annotation
+type SyncConsistency struct {
+	Status string `json:"status"`
+	// Lag is how much older the Secret's annotation is than the CRD's last successful sync, when partial
+	Lag    string `json:"lag,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// CheckSyncConsistency sets SyncInfo.SyncConsistency on the found Kubernetes secrets
+// The operator writes the Secret before the status, so the annotation may trail the CRD by up to tolerance
+func CheckSyncConsistency(secrets []SecretInfo, tolerance time.Duration) {
+	for i := range secrets {
+		if secrets[i].Source != SourceKubernetes || !secrets[i].Found {
+			continue
+		}
+		secrets[i].SyncInfo.SyncConsistency = syncConsistency(secrets[i].SyncInfo, tolerance)
+	}
+}
+
+// syncConsistency compares the two sync times of a secret
+func syncConsistency(info SyncInfo, tolerance time.Duration) *SyncConsistency {
+	if !info.CRDFound {
+		return &SyncConsistency{Status: SyncUnknown, Detail: "BitwardenSecret not found"}
+	}
+	if info.LastSuccessfulSync == "" {
+		return &SyncConsistency{Status: SyncUnknown, Detail: "BitwardenSecret has no lastSuccessfulSyncTime"}
+	}
+	if info.K8sSecretSyncTime == "" {
+		return &SyncConsistency{Status: SyncUnknown, Detail: "Secret has no sync-time annotation"}
+	}
+	crdSync, err := time.Parse(time.RFC3339, info.LastSuccessfulSync)
+	if err != nil {
+		return &SyncConsistency{Status: SyncUnknown, Detail: fmt.Sprintf("Unreadable lastSuccessfulSyncTime %q", info.LastSuccessfulSync)}
+	}
+	secretSync, err := time.Parse(time.RFC3339, info.K8sSecretSyncTime)
+	if err != nil {
+		return &SyncConsistency{Status: SyncUnknown, Detail: fmt.Sprintf("Unreadable sync-time annotation %q", info.K8sSecretSyncTime)}
+	}
+
+	lag := crdSync.Sub(secretSync)
+	if lag > tolerance {
+		return &SyncConsistency{
+			Status: SyncPartial,
+			Lag:    lag.String(),
+			Detail: fmt.Sprintf("The BitwardenSecret reports a successful sync at %s, but the Secret was last written at %s", info.LastSuccessfulSync, info.K8sSecretSyncTime),
+		}
+	}
+	return &SyncConsistency{Status: SyncConsistent}
+}
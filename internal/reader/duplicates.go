@@ -0,0 +1,120 @@
+package reader
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// valueHashKey keys the value hashes, so they cannot be checked against guessed values and
+// mean nothing outside this process
+var valueHashKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("failed to generate value hash key: " + err.Error())
+	}
+	return key
+}()
+
+// HashValue returns the keyed hash of a secret value used to compare values without keeping them
+func HashValue(value string) string {
+	mac := hmac.New(sha256.New, valueHashKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HashValues sets ValueHashes for every non-empty value
+func HashValues(secrets []SecretInfo) {
+	for i := range secrets {
+		if len(secrets[i].Keys) == 0 {
+			continue
+		}
+		hashes := make(map[string]string, len(secrets[i].Keys))
+		for key, value := range secrets[i].Keys {
+			if value != "" {
+				hashes[key] = HashValue(value)
+			}
+		}
+		secrets[i].ValueHashes = hashes
+	}
+}
+
+// ValueLocation is a key in a secret
+type ValueLocation struct {
+	Source    string `json:"source"`
+	Namespace string `json:"namespace,omitempty"`
+	Secret    string `json:"secret"`
+	Key       string `json:"key"`
+}
+
+// Duplicate is a value shared by keys in more than one secret
+type Duplicate struct {
+	Locations  []ValueLocation `json:"locations"`
+	Secrets    int             `json:"secrets"`
+	Namespaces int             `json:"namespaces"`
+}
+
+// DuplicateIndex groups value locations by value hash
+type DuplicateIndex struct {
+	byHash map[string][]ValueLocation
+	seen   map[ValueLocation]bool
+}
+
+// NewDuplicateIndex creates an empty index
+func NewDuplicateIndex() *DuplicateIndex {
+	return &DuplicateIndex{byHash: make(map[string][]ValueLocation), seen: make(map[ValueLocation]bool)}
+}
+
+// Add records a location holding the value with the given hash; a location added twice counts once
+func (d *DuplicateIndex) Add(location ValueLocation, hash string) {
+	if hash == "" || d.seen[location] {
+		return
+	}
+	d.seen[location] = true
+	d.byHash[hash] = append(d.byHash[hash], location)
+}
+
+// Duplicates returns the values found in more than one secret, most widely shared first
+// Keys sharing a value within a single secret are not reported
+func (d *DuplicateIndex) Duplicates() []Duplicate {
+	duplicates := []Duplicate{}
+	for _, locations := range d.byHash {
+		secrets := make(map[ValueLocation]bool)
+		namespaces := make(map[string]bool)
+		for _, location := range locations {
+			secrets[ValueLocation{Source: location.Source, Namespace: location.Namespace, Secret: location.Secret}] = true
+			namespaces[location.Namespace] = true
+		}
+		if len(secrets) < 2 {
+			continue
+		}
+
+		sorted := append([]ValueLocation(nil), locations...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].less(sorted[j]) })
+		duplicates = append(duplicates, Duplicate{Locations: sorted, Secrets: len(secrets), Namespaces: len(namespaces)})
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].Secrets != duplicates[j].Secrets {
+			return duplicates[i].Secrets > duplicates[j].Secrets
+		}
+		return duplicates[i].Locations[0].less(duplicates[j].Locations[0])
+	})
+	return duplicates
+}
+
+// less orders locations by namespace, secret, source, and key
+func (l ValueLocation) less(other ValueLocation) bool {
+	if l.Namespace != other.Namespace {
+		return l.Namespace < other.Namespace
+	}
+	if l.Secret != other.Secret {
+		return l.Secret < other.Secret
+	}
+	if l.Source != other.Source {
+		return l.Source < other.Source
+	}
+	return l.Key < other.Key
+}
@@ -0,0 +1,95 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ExpandedValue is a JSON object or array value parsed into its structure
+type ExpandedValue struct {
+	// Value is the parsed document
+	Value interface{} `json:"value"`
+	// Flat maps dotted paths such as "db.hosts.0" to the leaf values, with non-strings in JSON form
+	Flat map[string]string `json:"flat"`
+}
+
+// ExpandValues parses the values of each secret that hold a JSON object or array into Expanded
+func ExpandValues(secrets []SecretInfo) {
+	for i := range secrets {
+		for key, value := range secrets[i].Keys {
+			expanded, ok := expandValue(value)
+			if !ok {
+				continue
+			}
+			if secrets[i].Expanded == nil {
+				secrets[i].Expanded = make(map[string]ExpandedValue)
+			}
+			secrets[i].Expanded[key] = expanded
+		}
+	}
+}
+
+// expandValue parses a JSON object or array; other values, including bare JSON scalars, are left alone
+func expandValue(value string) (ExpandedValue, bool) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return ExpandedValue{}, false
+	}
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil || decoder.More() {
+		return ExpandedValue{}, false
+	}
+
+	flat := make(map[string]string)
+	flatten("", parsed, flat)
+	return ExpandedValue{Value: parsed, Flat: flat}, true
+}
+
+// flatten adds the leaves under prefix to flat
+func flatten(prefix string, value interface{}, flat map[string]string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			flat[prefix] = "{}"
+		}
+		for key, child := range v {
+			flatten(join(key), child, flat)
+		}
+	case []interface{}:
+		if len(v) == 0 && prefix != "" {
+			flat[prefix] = "[]"
+		}
+		for i, child := range v {
+			flatten(join(strconv.Itoa(i)), child, flat)
+		}
+	case string:
+		flat[prefix] = v
+	default:
+		data, _ := json.Marshal(v)
+		flat[prefix] = string(data)
+	}
+}
+
+// Pretty returns the key's value indented when it holds a JSON object or array, otherwise as is
+func (s SecretInfo) Pretty(key string) string {
+	value := s.Keys[key]
+	if _, ok := expandValue(value); !ok {
+		return value
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(strings.TrimSpace(value)), "", "  "); err != nil {
+		return value
+	}
+	return out.String()
+}
@@ -0,0 +1,79 @@
+package reader
+
+import "sort"
+
+// UngroupedName is the group assigned to secrets without a configured or annotated group
+const UngroupedName = "ungrouped"
+
+// GroupSummary holds aggregated status for a group of secrets
+type GroupSummary struct {
+	Name        string   `json:"name"`
+	Secrets     []string `json:"secrets"`
+	Total       int      `json:"total"`
+	Found       int      `json:"found"`
+	CRDFound    int      `json:"crdFound"`
+	SyncHealthy int      `json:"syncHealthy"`
+	Failing     int      `json:"failing"`
+}
+
+// AssignGroups sets the group of each secret from the configured mapping
+// Configured groups take precedence over the Secret's group annotation
+func AssignGroups(secrets []SecretInfo, groups map[string]string) {
+	for i := range secrets {
+		if group, ok := groups[secrets[i].Name]; ok {
+			secrets[i].Group = group
+		}
+		if secrets[i].Group == "" {
+			secrets[i].Group = UngroupedName
+		}
+	}
+}
+
+// FilterByGroup returns only the secrets belonging to the given group
+func FilterByGroup(secrets []SecretInfo, group string) []SecretInfo {
+	if group == "" {
+		return secrets
+	}
+	filtered := make([]SecretInfo, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret.Group == group {
+			filtered = append(filtered, secret)
+		}
+	}
+	return filtered
+}
+
+// SummarizeGroups builds per-group summaries sorted by group name
+func SummarizeGroups(secrets []SecretInfo) []GroupSummary {
+	byName := make(map[string]*GroupSummary)
+	for _, secret := range secrets {
+		summary, ok := byName[secret.Group]
+		if !ok {
+			summary = &GroupSummary{Name: secret.Group}
+			byName[secret.Group] = summary
+		}
+		summary.Secrets = append(summary.Secrets, secret.Name)
+		summary.Total++
+		if secret.Found {
+			summary.Found++
+		}
+		if secret.SyncInfo.CRDFound {
+			summary.CRDFound++
+		}
+		if secret.SyncInfo.SyncStatus == "True" {
+			summary.SyncHealthy++
+		}
+		if !secret.Found || secret.SyncInfo.SyncStatus == "False" {
+			summary.Failing++
+		}
+	}
+
+	summaries := make([]GroupSummary, 0, len(byName))
+	for _, summary := range byName {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries
+}
@@ -0,0 +1,67 @@
+package reader
+
+import "fmt"
+
+// Health statuses, named after the Argo CD resource health states
+const (
+	HealthHealthy     = "Healthy"
+	HealthProgressing = "Progressing"
+	HealthDegraded    = "Degraded"
+)
+
+// SecretHealth holds the health of a single secret
+type SecretHealth struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Flapping is set while the secret keeps changing state; the status is the current one
+	Flapping bool `json:"flapping,omitempty"`
+}
+
+// healthRank orders statuses so the worst one wins when aggregating
+var healthRank = map[string]int{
+	HealthHealthy:     0,
+	HealthProgressing: 1,
+	HealthDegraded:    2,
+}
+
+// EvaluateHealth returns the health of a secret from its presence, auth token, and CRD sync condition
+func EvaluateHealth(secret SecretInfo) SecretHealth {
+	health := SecretHealth{Name: secret.Name, Status: HealthHealthy, Flapping: secret.Flapping}
+	switch {
+	case !secret.Found:
+		health.Status = HealthDegraded
+		health.Message = secret.Error
+		if health.Message == "" {
+			health.Message = "Secret not found"
+		}
+	case secret.SyncInfo.AuthToken != nil && secret.SyncInfo.AuthToken.Broken():
+		// The usual root cause of failing syncs, so it is reported before the sync condition
+		health.Status = HealthDegraded
+		health.Message = secret.SyncInfo.AuthToken.Detail
+	case secret.SyncInfo.SyncStatus == "False":
+		health.Status = HealthDegraded
+		health.Message = fmt.Sprintf("Sync failing: %s", secret.SyncInfo.SyncReason)
+		if secret.SyncInfo.SyncMessage != "" {
+			health.Message += " - " + secret.SyncInfo.SyncMessage
+		}
+	case secret.SyncInfo.CRDFound && secret.SyncInfo.SyncStatus != "True":
+		health.Status = HealthProgressing
+		health.Message = "Waiting for first successful sync"
+	}
+	return health
+}
+
+// AggregateHealth evaluates every secret and returns the worst status along with the details
+func AggregateHealth(secrets []SecretInfo) (string, []SecretHealth) {
+	overall := HealthHealthy
+	details := make([]SecretHealth, 0, len(secrets))
+	for _, secret := range secrets {
+		health := EvaluateHealth(secret)
+		if healthRank[health.Status] > healthRank[overall] {
+			overall = health.Status
+		}
+		details = append(details, health)
+	}
+	return overall, details
+}
@@ -0,0 +1,215 @@
+package reader
+
+import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Kinds of key material recognized in secret values
+const (
+	KeySSHPublic       = "ssh-public-key"
+	KeySSHCertificate  = "ssh-certificate"
+	KeyPrivate         = "private-key"
+	KeyPublic          = "public-key"
+	KeyX509Certificate = "x509-certificate"
+)
+
+// KeyMaterial describes a key or certificate found in a secret value without including the material
+type KeyMaterial struct {
+	Kind string `json:"kind"`
+	// Type is the key algorithm: rsa, ecdsa, ed25519, or dsa
+	Type string `json:"type,omitempty"`
+	Bits int    `json:"bits,omitempty"`
+	// Fingerprint is the OpenSSH SHA256 fingerprint of the public key, or for X.509 certificates
+	// the SHA-256 of the certificate as colon-separated hex, as printed by openssl
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Encrypted is set for passphrase-protected private keys, whose public key may be unknown
+	Encrypted bool   `json:"encrypted,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+	// Subject, Issuer, and validity are set for certificates
+	Subject   string     `json:"subject,omitempty"`
+	Issuer    string     `json:"issuer,omitempty"`
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	NotAfter  *time.Time `json:"notAfter,omitempty"`
+}
+
+// DescribeKeyMaterial sets KeyMaterial for the keys whose values hold SSH keys, PEM keys, or certificates
+func DescribeKeyMaterial(secrets []SecretInfo) {
+	for i := range secrets {
+		for key, value := range secrets[i].Keys {
+			material := describeValue(value)
+			if len(material) == 0 {
+				continue
+			}
+			if secrets[i].KeyMaterial == nil {
+				secrets[i].KeyMaterial = make(map[string][]KeyMaterial)
+			}
+			secrets[i].KeyMaterial[key] = material
+		}
+	}
+}
+
+// describeValue returns the key material in a value: PEM blocks, or else authorized_keys lines
+func describeValue(value string) []KeyMaterial {
+	if strings.Contains(value, "-----BEGIN ") {
+		return describePEM([]byte(value))
+	}
+	if !strings.Contains(value, "ssh-") && !strings.Contains(value, "ecdsa-") && !strings.Contains(value, "sk-") {
+		return nil
+	}
+
+	var material []KeyMaterial
+	rest := []byte(value)
+	for len(rest) > 0 {
+		pub, comment, _, next, err := ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			break
+		}
+		item := describeSSHKey(pub)
+		item.Comment = comment
+		material = append(material, item)
+		rest = next
+	}
+	return material
+}
+
+// describePEM describes each recognized PEM block, such as a certificate chain or a key pair
+func describePEM(data []byte) []KeyMaterial {
+	var material []KeyMaterial
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			return material
+		}
+		data = rest
+
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				continue
+			}
+			material = append(material, describeCertificate(cert))
+		case block.Type == "PUBLIC KEY":
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				continue
+			}
+			material = append(material, describePublicKey(KeyPublic, pub))
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			material = append(material, describePrivateKey(block))
+		}
+	}
+}
+
+// describePrivateKey describes a private key through its public key
+func describePrivateKey(block *pem.Block) KeyMaterial {
+	key, err := ssh.ParseRawPrivateKey(pem.EncodeToMemory(block))
+	if err != nil {
+		item := KeyMaterial{Kind: KeyPrivate}
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			item.Encrypted = true
+			// OpenSSH keys keep the public key unencrypted
+			if missing.PublicKey != nil {
+				item = describeSSHKey(missing.PublicKey)
+				item.Kind = KeyPrivate
+				item.Encrypted = true
+			}
+		}
+		return item
+	}
+
+	// OpenSSH ed25519 keys are returned as a pointer, which is not a crypto.Signer
+	if edKey, ok := key.(*ed25519.PrivateKey); ok {
+		key = *edKey
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return KeyMaterial{Kind: KeyPrivate}
+	}
+	return describePublicKey(KeyPrivate, signer.Public())
+}
+
+// describeSSHKey describes a key or certificate parsed from OpenSSH format
+func describeSSHKey(pub ssh.PublicKey) KeyMaterial {
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		item := describeSSHKey(cert.Key)
+		item.Kind = KeySSHCertificate
+		item.Subject = cert.KeyId
+		if cert.ValidAfter != 0 {
+			notBefore := time.Unix(int64(cert.ValidAfter), 0).UTC()
+			item.NotBefore = &notBefore
+		}
+		if cert.ValidBefore != ssh.CertTimeInfinity {
+			notAfter := time.Unix(int64(cert.ValidBefore), 0).UTC()
+			item.NotAfter = &notAfter
+		}
+		return item
+	}
+
+	item := KeyMaterial{Kind: KeySSHPublic, Fingerprint: ssh.FingerprintSHA256(pub)}
+	if cryptoPub, ok := pub.(ssh.CryptoPublicKey); ok {
+		item.Type, item.Bits = publicKeyType(cryptoPub.CryptoPublicKey())
+	}
+	return item
+}
+
+// describePublicKey describes a public key with its OpenSSH fingerprint
+func describePublicKey(kind string, pub crypto.PublicKey) KeyMaterial {
+	item := KeyMaterial{Kind: kind}
+	item.Type, item.Bits = publicKeyType(pub)
+	if sshPub, err := ssh.NewPublicKey(pub); err == nil {
+		item.Fingerprint = ssh.FingerprintSHA256(sshPub)
+	}
+	return item
+}
+
+// describeCertificate describes an X.509 certificate
+func describeCertificate(cert *x509.Certificate) KeyMaterial {
+	sum := sha256.Sum256(cert.Raw)
+	hexBytes := make([]string, len(sum))
+	for i, b := range sum {
+		hexBytes[i] = fmt.Sprintf("%02X", b)
+	}
+	notBefore, notAfter := cert.NotBefore.UTC(), cert.NotAfter.UTC()
+
+	item := KeyMaterial{
+		Kind:        KeyX509Certificate,
+		Fingerprint: strings.Join(hexBytes, ":"),
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		NotBefore:   &notBefore,
+		NotAfter:    &notAfter,
+	}
+	item.Type, item.Bits = publicKeyType(cert.PublicKey)
+	return item
+}
+
+// publicKeyType returns the algorithm and size of a public key
+func publicKeyType(pub crypto.PublicKey) (string, int) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return "rsa", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ecdsa", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "ed25519", 256
+	case *dsa.PublicKey:
+		return "dsa", key.P.BitLen()
+	default:
+		return "", 0
+	}
+}
@@ -0,0 +1,112 @@
+package reader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"sort"
+)
+
+// ValueBudget caps the decoded value bytes kept from one read, so a huge value such as a
+// backup blob synced through Bitwarden can't exhaust the pod's memory
+// A zero cap is unlimited
+type ValueBudget struct {
+	// MaxValue is the largest value kept
+	MaxValue int
+	// MaxTotal caps the values of all secrets together; the largest values are dropped first
+	MaxTotal int
+}
+
+// Truncation reports what a ValueBudget dropped
+type Truncation struct {
+	// Held is the value bytes kept
+	Held int64
+	// Values and Bytes count the values dropped and their size
+	Values int
+	Bytes  int64
+	// ValueCap and TotalCap report which caps dropped values
+	ValueCap bool
+	TotalCap bool
+}
+
+// truncatedValue is a value considered for dropping
+type truncatedValue struct {
+	secret int
+	key    string
+	size   int
+}
+
+// Apply empties the values exceeding the budget, listing them in TruncatedKeys
+// It runs after HashValues, so changes of dropped values are still detected through ValueHashes
+// and folded into HiddenDigest; values emptied by the visibility policy don't count
+func (b ValueBudget) Apply(secrets []SecretInfo) Truncation {
+	var result Truncation
+	var values []truncatedValue
+	for i := range secrets {
+		for key, value := range secrets[i].Keys {
+			if value == "" {
+				continue
+			}
+			if b.MaxValue > 0 && len(value) > b.MaxValue {
+				result.ValueCap = true
+				truncate(&secrets[i], key, &result)
+				continue
+			}
+			result.Held += int64(len(value))
+			values = append(values, truncatedValue{secret: i, key: key, size: len(value)})
+		}
+	}
+
+	if b.MaxTotal > 0 && result.Held > int64(b.MaxTotal) {
+		result.TotalCap = true
+		// Largest first, so a single blob is dropped rather than many small credentials
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].size != values[j].size {
+				return values[i].size > values[j].size
+			}
+			if values[i].secret != values[j].secret {
+				return values[i].secret < values[j].secret
+			}
+			return values[i].key < values[j].key
+		})
+		for _, v := range values {
+			if result.Held <= int64(b.MaxTotal) {
+				break
+			}
+			result.Held -= int64(v.size)
+			truncate(&secrets[v.secret], v.key, &result)
+		}
+	}
+
+	for i := range secrets {
+		if len(secrets[i].TruncatedKeys) > 0 {
+			sealTruncation(&secrets[i])
+		}
+	}
+	return result
+}
+
+// truncate empties a value and records it
+func truncate(secret *SecretInfo, key string, result *Truncation) {
+	result.Values++
+	result.Bytes += int64(len(secret.Keys[key]))
+	secret.Keys[key] = ""
+	secret.TruncatedKeys = append(secret.TruncatedKeys, key)
+	delete(secret.Expanded, key)
+}
+
+// sealTruncation sorts TruncatedKeys and folds the dropped values' hashes into HiddenDigest
+func sealTruncation(secret *SecretInfo) {
+	sort.Strings(secret.TruncatedKeys)
+	digest := sha256.New()
+	digest.Write([]byte(secret.HiddenDigest + "\n"))
+	for _, key := range secret.TruncatedKeys {
+		digest.Write([]byte(key + "=" + secret.ValueHashes[key] + "\n"))
+	}
+	secret.HiddenDigest = hex.EncodeToString(digest.Sum(nil))
+}
+
+// IsTruncated reports whether the key's value was dropped by the memory caps
+func (s SecretInfo) IsTruncated(key string) bool {
+	return slices.Contains(s.TruncatedKeys, key)
+}
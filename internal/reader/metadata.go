@@ -0,0 +1,62 @@
+package reader
+
+import (
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SecretMetadata is a Kubernetes Secret's metadata, for debugging ownership and garbage collection
+type SecretMetadata struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are the operator's and those listed in METADATA_ANNOTATIONS; others may hold secret data
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty"`
+	// OwnedByCRD is set when a BitwardenSecret owns the Secret, so deleting it garbage-collects the Secret
+	OwnedByCRD        bool   `json:"ownedByCRD"`
+	ResourceVersion   string `json:"resourceVersion"`
+	CreationTimestamp string `json:"creationTimestamp"`
+}
+
+// OwnerReference is an owner of a Secret
+type OwnerReference struct {
+	APIVersion         string `json:"apiVersion"`
+	Kind               string `json:"kind"`
+	Name               string `json:"name"`
+	UID                string `json:"uid"`
+	Controller         bool   `json:"controller,omitempty"`
+	BlockOwnerDeletion bool   `json:"blockOwnerDeletion,omitempty"`
+}
+
+// secretMetadata describes a Secret's metadata
+func secretMetadata(secret *corev1.Secret) *SecretMetadata {
+	metadata := &SecretMetadata{
+		Type:              string(secret.Type),
+		Labels:            secret.Labels,
+		Annotations:       k8s.SelectAnnotations(secret.Annotations),
+		OwnedByCRD:        k8s.GetOwningCRDName(secret) != "",
+		ResourceVersion:   secret.ResourceVersion,
+		CreationTimestamp: secret.CreationTimestamp.UTC().Format(time.RFC3339),
+	}
+	for _, ref := range secret.OwnerReferences {
+		metadata.OwnerReferences = append(metadata.OwnerReferences, OwnerReference{
+			APIVersion:         ref.APIVersion,
+			Kind:               ref.Kind,
+			Name:               ref.Name,
+			UID:                string(ref.UID),
+			Controller:         ref.Controller != nil && *ref.Controller,
+			BlockOwnerDeletion: ref.BlockOwnerDeletion != nil && *ref.BlockOwnerDeletion,
+		})
+	}
+	return metadata
+}
+
+// Deletion is when a missing Secret was deleted and by whom, when that could be found
+type Deletion struct {
+	Time   string `json:"time"`
+	Actor  string `json:"actor,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
@@ -0,0 +1,14 @@
+package reader
+
+import "bitwarden-reader/internal/k8s"
+
+// NamespaceIssue returns the terminating or absent namespace that made secrets unavailable, or nil
+// It is reported once for the namespace instead of as the same error on every secret
+func NamespaceIssue(secrets []SecretInfo) *k8s.NamespaceStatus {
+	for _, secret := range secrets {
+		if secret.NamespaceStatus != nil {
+			return secret.NamespaceStatus
+		}
+	}
+	return nil
+}
@@ -3,18 +3,49 @@ package reader
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
 )
 
 // SecretInfo holds information about a Kubernetes secret and its sync status
 type SecretInfo struct {
 	Name     string
+	// Source is the SecretSource the secret was read from
+	Source   string
+	Group    string
 	Found    bool
 	Keys     map[string]string
 	SyncInfo SyncInfo
 	Error    string
+	// ValidationErrors are reported by post-processing plugins
+	ValidationErrors []string
+	// Flapping is set while the secret keeps switching between found, missing, and sync failing
+	Flapping bool
+	// HiddenKeys have their values emptied by the key visibility policy; VisibleKeys are shown unmasked
+	HiddenKeys  []string
+	VisibleKeys []string
+	// TruncatedKeys have their values emptied because they exceeded the memory caps, see ValueBudget
+	TruncatedKeys []string `json:",omitempty"`
+	// HiddenDigest hashes the hidden and truncated values for change detection and is never serialized
+	HiddenDigest string `json:"-" codec:"-"`
+	// Expanded holds the JSON object and array values parsed, when requested with ?expand=true
+	Expanded map[string]ExpandedValue `json:",omitempty"`
+	// KeyMaterial describes the SSH keys, PEM keys, and certificates found in values, by key
+	KeyMaterial map[string][]KeyMaterial `json:",omitempty"`
+	// Weak flags the keys whose values look weak or like placeholders, by key
+	Weak map[string]WeakValue `json:",omitempty"`
+	// ValueHashes holds keyed hashes of the non-empty values for duplicate detection and is never serialized
+	ValueHashes map[string]string `json:"-" codec:"-"`
+	// Metadata is the Kubernetes Secret's type, labels, selected annotations, and ownership
+	Metadata *SecretMetadata `json:",omitempty"`
+	// Deleted is set for a missing Secret that was seen being deleted, as opposed to never existing
+	Deleted *Deletion `json:",omitempty"`
+	// NamespaceStatus is set when the Secret's namespace is terminating or absent; see NamespaceIssue
+	NamespaceStatus *k8s.NamespaceStatus `json:"-" codec:"-"`
 }
 
 // SyncInfo holds synchronization information from the CRD
@@ -26,6 +57,20 @@ type SyncInfo struct {
 	SyncReason          string
 	SyncMessage         string
 	CRDCreationTime     string
+	// OperatorAnnotations are the Secret's annotations set by the operator, see k8s.GetOperatorAnnotations
+	OperatorAnnotations map[string]string `json:",omitempty"`
+	// SyncConsistency compares the two sync times, see CheckSyncConsistency
+	SyncConsistency *SyncConsistency `json:",omitempty"`
+	// AuthToken is the state of the auth-token Secret the BitwardenSecret references, see CheckAuthTokens
+	AuthToken *k8s.AuthTokenCheck `json:",omitempty"`
+}
+
+// crdListThreshold is the number of secrets from which their BitwardenSecrets are read with one List
+const crdListThreshold = 3
+
+// scrubOwner names a Kubernetes Secret in the log scrubber's registry
+func scrubOwner(namespace, name string) string {
+	return "kubernetes/" + namespace + "/" + name
 }
 
 // ReadSecrets reads all specified secrets and combines them with CRD sync information
@@ -41,6 +86,7 @@ func ReadSecrets(ctx context.Context, secretNames []string, namespace string, k8
 			}
 			secrets = append(secrets, SecretInfo{
 				Name:     secretName,
+				Source:   SourceKubernetes,
 				Found:    false,
 				Keys:     make(map[string]string),
 				SyncInfo: SyncInfo{},
@@ -50,6 +96,13 @@ func ReadSecrets(ctx context.Context, secretNames []string, namespace string, k8
 		return secrets, nil
 	}
 
+	// With several secrets, one List of the namespace's BitwardenSecrets replaces a Get per secret
+	crds := listCRDs(ctx, secretNames, namespace, k8sClients)
+
+	// Checked on the first missing secret; once the namespace is known to be gone, the rest aren't read
+	var namespaceStatus *k8s.NamespaceStatus
+	namespaceChecked := false
+
 	for _, secretName := range secretNames {
 		secretName = strings.TrimSpace(secretName)
 		if secretName == "" {
@@ -58,15 +111,32 @@ func ReadSecrets(ctx context.Context, secretNames []string, namespace string, k8
 
 		secretInfo := SecretInfo{
 			Name:     secretName,
+			Source:   SourceKubernetes,
 			Found:    false,
 			Keys:     make(map[string]string),
 			SyncInfo: SyncInfo{},
 		}
+		if namespaceStatus != nil {
+			secretInfo.Error = namespaceStatus.Message
+			secretInfo.NamespaceStatus = namespaceStatus
+			secrets = append(secrets, secretInfo)
+			continue
+		}
 
 		// Read Kubernetes Secret
 		secret, err := k8s.ReadSecret(ctx, secretName, namespace, k8sClients.Clientset)
 		if err != nil {
 			if k8s.IsSecretNotFound(err) {
+				logging.ForgetSecrets(scrubOwner(namespace, secretName))
+			}
+			if k8s.IsSecretNotFound(err) && !namespaceChecked {
+				namespaceStatus = k8s.GetNamespaceStatus(ctx, k8sClients.Clientset, namespace)
+				namespaceChecked = true
+			}
+			if namespaceStatus != nil {
+				secretInfo.Error = namespaceStatus.Message
+				secretInfo.NamespaceStatus = namespaceStatus
+			} else if k8s.IsSecretNotFound(err) {
 				secretInfo.Error = fmt.Sprintf("Secret '%s' not found", secretName)
 			} else {
 				secretInfo.Error = fmt.Sprintf("Error reading secret: %v", err)
@@ -77,18 +147,32 @@ func ReadSecrets(ctx context.Context, secretNames []string, namespace string, k8
 
 		secretInfo.Found = true
 
-		// Decode secret data
+		// Decode secret data and register values so they are scrubbed from logs
 		secretInfo.Keys = k8s.DecodeSecretData(secret.Data)
+		logging.RegisterSecrets(scrubOwner(namespace, secretName), slices.Collect(maps.Values(secretInfo.Keys))...)
+		k8s.WipeSecretData(secret.Data)
 
-		// Extract sync-time annotation
+		// Extract sync-time and group annotations
 		secretInfo.SyncInfo.K8sSecretSyncTime = k8s.GetSecretSyncTime(secret)
+		secretInfo.SyncInfo.OperatorAnnotations = k8s.GetOperatorAnnotations(secret.Annotations)
+		secretInfo.Metadata = secretMetadata(secret)
+		secretInfo.Group = k8s.GetSecretGroup(secret)
 
 		// Always try to read CRD info using the secret name as the CRD name
-		readCRDInfo(ctx, secretName, namespace, secretName, k8sClients, &secretInfo)
+		if crds != nil {
+			crdInfo, ok := crds[secretName]
+			if !ok {
+				crdInfo = &k8s.CRDInfo{SyncMessage: fmt.Sprintf("CRD not found: %s", secretName)}
+			}
+			applyCRDInfo(crdInfo, &secretInfo)
+		} else {
+			readCRDInfo(ctx, secretName, namespace, secretName, k8sClients, &secretInfo)
+		}
 
 		secrets = append(secrets, secretInfo)
 	}
 
+	checkAuthTokens(ctx, secrets, namespace, k8sClients.Clientset)
 	return secrets, nil
 }
 
@@ -104,11 +188,32 @@ func readCRDInfo(ctx context.Context, crdName, namespace, secretName string, k8s
 		secretInfo.SyncInfo.SyncMessage = fmt.Sprintf("Error reading CRD: %v", err)
 		return
 	}
+	applyCRDInfo(crdInfo, secretInfo)
+}
 
+// listCRDs lists the namespace's BitwardenSecrets when reading at least crdListThreshold secrets
+// It returns nil when the List fails, such as without list permission, so each CRD is read with Get instead
+func listCRDs(ctx context.Context, secretNames []string, namespace string, k8sClients *k8s.K8sClients) map[string]*k8s.CRDInfo {
+	if len(secretNames) < crdListThreshold || k8sClients.DynamicClient == nil {
+		return nil
+	}
+	crds, err := k8s.ListBitwardenSecretCRDs(ctx, namespace, k8sClients.DynamicClient)
+	if err != nil {
+		logging.Printf("Listing BitwardenSecrets in %s failed, reading them one by one: %v", namespace, err)
+		return nil
+	}
+	return crds
+}
+
+// applyCRDInfo copies CRD sync information into secretInfo
+func applyCRDInfo(crdInfo *k8s.CRDInfo, secretInfo *SecretInfo) {
 	secretInfo.SyncInfo.CRDFound = crdInfo.CRDFound
 	secretInfo.SyncInfo.LastSuccessfulSync = crdInfo.LastSuccessfulSync
 	secretInfo.SyncInfo.SyncStatus = crdInfo.SyncStatus
 	secretInfo.SyncInfo.SyncReason = crdInfo.SyncReason
 	secretInfo.SyncInfo.SyncMessage = crdInfo.SyncMessage
 	secretInfo.SyncInfo.CRDCreationTime = crdInfo.CRDCreationTime
+	if crdInfo.CRDFound {
+		secretInfo.SyncInfo.AuthToken = &k8s.AuthTokenCheck{SecretName: crdInfo.AuthTokenSecretName, SecretKey: crdInfo.AuthTokenSecretKey}
+	}
 }
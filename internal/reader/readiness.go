@@ -0,0 +1,93 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SecretReadiness reports whether a required secret is available and synced
+type SecretReadiness struct {
+	Name   string `json:"name"`
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReadinessOptions controls which conditions a secret must meet to be ready
+type ReadinessOptions struct {
+	// RequireSync requires the CRD to exist and report SuccessfulSync
+	RequireSync bool
+	// RequiredKeys lists keys that must be present, by secret name
+	RequiredKeys map[string][]string
+	// MaxSyncAge rejects secrets whose last successful sync is older than this (0 disables the check)
+	MaxSyncAge time.Duration
+}
+
+// CheckReadiness reports whether every secret exists and its CRD reports SuccessfulSync
+func CheckReadiness(secrets []SecretInfo) (bool, []SecretReadiness) {
+	return CheckReadinessWith(secrets, ReadinessOptions{RequireSync: true})
+}
+
+// CheckReadinessWith reports whether every secret meets the given conditions
+func CheckReadinessWith(secrets []SecretInfo, opts ReadinessOptions) (bool, []SecretReadiness) {
+	ready := true
+	results := make([]SecretReadiness, 0, len(secrets))
+	for _, secret := range secrets {
+		result := SecretReadiness{Name: secret.Name, Ready: true}
+		if reason := notReadyReason(secret, opts); reason != "" {
+			result.Ready = false
+			result.Reason = reason
+			ready = false
+		}
+		results = append(results, result)
+	}
+	return ready, results
+}
+
+// notReadyReason returns why the secret is not ready, or an empty string when it is
+func notReadyReason(secret SecretInfo, opts ReadinessOptions) string {
+	if !secret.Found {
+		if secret.Error != "" {
+			return secret.Error
+		}
+		return "Secret not found"
+	}
+
+	var missing []string
+	for _, key := range opts.RequiredKeys[secret.Name] {
+		if _, ok := secret.Keys[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Sprintf("Missing keys: %s", strings.Join(missing, ", "))
+	}
+
+	if opts.RequireSync {
+		if !secret.SyncInfo.CRDFound {
+			return "BitwardenSecret CRD not found"
+		}
+		if secret.SyncInfo.SyncStatus != "True" {
+			reason := fmt.Sprintf("SuccessfulSync condition is %q", secret.SyncInfo.SyncStatus)
+			if secret.SyncInfo.SyncReason != "" {
+				reason += " (" + secret.SyncInfo.SyncReason + ")"
+			}
+			return reason
+		}
+	}
+
+	if opts.MaxSyncAge > 0 {
+		syncTime := secret.SyncInfo.LastSuccessfulSync
+		if syncTime == "" {
+			syncTime = secret.SyncInfo.K8sSecretSyncTime
+		}
+		synced, err := time.Parse(time.RFC3339, syncTime)
+		if err != nil {
+			return "Last sync time unknown"
+		}
+		if age := time.Since(synced); age > opts.MaxSyncAge {
+			return fmt.Sprintf("Last synced %s ago (max %s)", age.Truncate(time.Second), opts.MaxSyncAge)
+		}
+	}
+	return ""
+}
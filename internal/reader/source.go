@@ -0,0 +1,90 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+)
+
+// SourceKubernetes names the Kubernetes Secret source
+const SourceKubernetes = "kubernetes"
+
+// SecretSource reads secrets and their sync metadata from one backend
+// Each SecretInfo it returns carries the source's name in Source
+type SecretSource interface {
+	// Name identifies the source on the dashboard, e.g. "kubernetes" or "vault"
+	Name() string
+	// ReadSecrets returns one entry per name, with Found false for names the source does not have
+	ReadSecrets(ctx context.Context, names []string) ([]SecretInfo, error)
+}
+
+// KubernetesSource reads Kubernetes Secrets and their BitwardenSecret sync status
+type KubernetesSource struct {
+	namespace string
+	clients   *k8s.K8sClients
+}
+
+// NewKubernetesSource returns a source reading secrets in namespace; clients may be nil in standalone mode
+func NewKubernetesSource(namespace string, clients *k8s.K8sClients) *KubernetesSource {
+	return &KubernetesSource{namespace: namespace, clients: clients}
+}
+
+// Name returns "kubernetes"
+func (s *KubernetesSource) Name() string {
+	return SourceKubernetes
+}
+
+// ReadSecrets reads the named secrets from the namespace
+func (s *KubernetesSource) ReadSecrets(ctx context.Context, names []string) ([]SecretInfo, error) {
+	return ReadSecrets(ctx, names, s.namespace, s.clients)
+}
+
+// Key identifies the secret across sources
+func (s SecretInfo) Key() string {
+	return s.Source + "/" + s.Name
+}
+
+// ReadFromSources reads the names from every source and orders the results by name, then source
+// A source that fails as a whole reports the error on each of its entries instead of failing the read
+func ReadFromSources(ctx context.Context, sources []SecretSource, names []string) []SecretInfo {
+	perSource := make([]map[string]SecretInfo, len(sources))
+	for i, source := range sources {
+		secrets, err := source.ReadSecrets(ctx, names)
+		if err != nil {
+			logging.Printf("Error reading secrets from %s: %v", source.Name(), err)
+		}
+		perSource[i] = make(map[string]SecretInfo, len(secrets))
+		for _, secret := range secrets {
+			secret.Source = source.Name()
+			perSource[i][secret.Name] = secret
+		}
+		if err == nil {
+			continue
+		}
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if _, ok := perSource[i][name]; name != "" && !ok {
+				perSource[i][name] = SecretInfo{
+					Name:   name,
+					Source: source.Name(),
+					Keys:   make(map[string]string),
+					Error:  fmt.Sprintf("Error reading from %s: %v", source.Name(), err),
+				}
+			}
+		}
+	}
+
+	var secrets []SecretInfo
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		for i := range sources {
+			if secret, ok := perSource[i][name]; ok {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	return secrets
+}
@@ -0,0 +1,129 @@
+package reader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+)
+
+// Key visibility levels
+const (
+	// VisibilityVisible values are shown without revealing them first
+	VisibilityVisible = "visible"
+	// VisibilityMasked values are served but masked until revealed, the default
+	VisibilityMasked = "masked"
+	// VisibilityHidden values are never served
+	VisibilityHidden = "hidden"
+)
+
+// Visibility decides per secret key whether its value is visible, masked, or hidden
+// Patterns are "secret/key" globs; hidden patterns win over visible ones
+type Visibility struct {
+	visible []string
+	hidden  []string
+}
+
+// NewVisibility builds a visibility policy from "secret/key" patterns per level
+// It returns nil when no patterns are configured
+func NewVisibility(levels map[string][]string) (*Visibility, error) {
+	v := &Visibility{}
+	for level, patterns := range levels {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+		switch level {
+		case VisibilityVisible:
+			v.visible = append(v.visible, patterns...)
+		case VisibilityHidden:
+			v.hidden = append(v.hidden, patterns...)
+		case VisibilityMasked:
+			// Masked is the default; listing keys is allowed for documentation
+		default:
+			return nil, fmt.Errorf("unknown visibility %q (use visible, masked or hidden)", level)
+		}
+	}
+	if len(v.visible) == 0 && len(v.hidden) == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// Level returns the visibility of a key in a secret
+func (v *Visibility) Level(secret, key string) string {
+	if v == nil {
+		return VisibilityMasked
+	}
+	name := secret + "/" + key
+	if matchAny(v.hidden, name) {
+		return VisibilityHidden
+	}
+	if matchAny(v.visible, name) {
+		return VisibilityVisible
+	}
+	return VisibilityMasked
+}
+
+// matchAny reports whether name matches one of the patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply empties hidden values so they are never served, listing them in HiddenKeys and
+// visible keys in VisibleKeys
+// HiddenDigest keeps a hash of the hidden values, so their changes can still be detected
+func (v *Visibility) Apply(secrets []SecretInfo) {
+	if v == nil {
+		return
+	}
+	for i := range secrets {
+		secret := &secrets[i]
+		names := make([]string, 0, len(secret.Keys))
+		for key := range secret.Keys {
+			names = append(names, key)
+		}
+		sort.Strings(names)
+
+		var hidden, visible []string
+		digest := sha256.New()
+		for _, key := range names {
+			switch v.Level(secret.Name, key) {
+			case VisibilityHidden:
+				digest.Write([]byte(key + "=" + secret.Keys[key] + "\n"))
+				secret.Keys[key] = ""
+				hidden = append(hidden, key)
+			case VisibilityVisible:
+				visible = append(visible, key)
+			}
+		}
+		if len(hidden) > 0 {
+			secret.HiddenDigest = hex.EncodeToString(digest.Sum(nil))
+		}
+		secret.HiddenKeys = hidden
+		secret.VisibleKeys = visible
+	}
+}
+
+// HidesAll reports whether every value is hidden, as with hidden=*/* or an invalid policy
+func (v *Visibility) HidesAll() bool {
+	return v != nil && slices.Contains(v.hidden, "*/*")
+}
+
+// IsHidden reports whether the key's value was emptied by the visibility policy
+func (s SecretInfo) IsHidden(key string) bool {
+	return slices.Contains(s.HiddenKeys, key)
+}
+
+// IsVisible reports whether the key's value is shown without revealing it first
+func (s SecretInfo) IsVisible(key string) bool {
+	return slices.Contains(s.VisibleKeys, key)
+}
@@ -0,0 +1,212 @@
+package reader
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strings"
+)
+
+// Weak value detectors
+const (
+	// DetectorPlaceholder flags obvious placeholders such as "changeme", "<token>", or "xxxx"
+	DetectorPlaceholder = "placeholder"
+	// DetectorCommon flags common passwords, also with leetspeak and trailing digits or symbols
+	DetectorCommon = "common"
+	// DetectorEntropy flags short or low-entropy values
+	DetectorEntropy = "entropy"
+)
+
+// Weak value severities
+const (
+	WeakHigh   = "high"
+	WeakMedium = "medium"
+)
+
+// minEntropyBits is the Shannon entropy of the whole value below which the entropy detector flags it
+const minEntropyBits = 28
+
+// minSecretLength is the length below which the entropy detector flags a value
+const minSecretLength = 8
+
+// WeakValue reports why a value looks weak, never the value itself
+type WeakValue struct {
+	Severity  string   `json:"severity"`
+	Detectors []string `json:"detectors"`
+}
+
+// WeakAnalyzer flags weak and placeholder values
+// Placeholders are looked for in every key; common passwords and low entropy only in keys matching
+// the credential key patterns, since usernames and URLs are legitimately guessable
+type WeakAnalyzer struct {
+	detectors      map[string]bool
+	credentialKeys []string
+}
+
+// NewWeakAnalyzer creates an analyzer running the named detectors, or nil when none are named
+func NewWeakAnalyzer(detectors, credentialKeys []string) (*WeakAnalyzer, error) {
+	if len(detectors) == 0 {
+		return nil, nil
+	}
+	a := &WeakAnalyzer{detectors: make(map[string]bool, len(detectors))}
+	for _, detector := range detectors {
+		switch detector {
+		case DetectorPlaceholder, DetectorCommon, DetectorEntropy:
+			a.detectors[detector] = true
+		default:
+			return nil, fmt.Errorf("unknown detector %q (use placeholder, common, or entropy)", detector)
+		}
+	}
+	for _, pattern := range credentialKeys {
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q: %w", pattern, err)
+		}
+		a.credentialKeys = append(a.credentialKeys, pattern)
+	}
+	return a, nil
+}
+
+// Apply sets Weak for the keys whose values a detector flags
+func (a *WeakAnalyzer) Apply(secrets []SecretInfo) {
+	if a == nil {
+		return
+	}
+	for i := range secrets {
+		for key, value := range secrets[i].Keys {
+			weak, ok := a.check(key, value)
+			if !ok {
+				continue
+			}
+			if secrets[i].Weak == nil {
+				secrets[i].Weak = make(map[string]WeakValue)
+			}
+			secrets[i].Weak[key] = weak
+		}
+	}
+}
+
+// check runs the detectors on one value
+func (a *WeakAnalyzer) check(key, value string) (WeakValue, bool) {
+	var weak WeakValue
+	flag := func(detector, severity string) {
+		weak.Detectors = append(weak.Detectors, detector)
+		if weak.Severity != WeakHigh {
+			weak.Severity = severity
+		}
+	}
+
+	if a.detectors[DetectorPlaceholder] && isPlaceholder(value) {
+		flag(DetectorPlaceholder, WeakHigh)
+	}
+	if a.isCredential(key) {
+		if a.detectors[DetectorCommon] && isCommonPassword(value) {
+			flag(DetectorCommon, WeakHigh)
+		}
+		if a.detectors[DetectorEntropy] && isLowEntropy(value) {
+			flag(DetectorEntropy, WeakMedium)
+		}
+	}
+	return weak, len(weak.Detectors) > 0
+}
+
+// isCredential reports whether the key name matches a credential key pattern
+func (a *WeakAnalyzer) isCredential(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range a.credentialKeys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholders are values left in place of a real secret
+// Words that are also plausible settings, such as "default" or "test", are left to the common detector
+var placeholders = map[string]bool{
+	"changeme": true, "change_me": true, "change-me": true, "changeit": true,
+	"replaceme": true, "replace_me": true, "replace-me": true,
+	"todo": true, "tbd": true, "fixme": true, "placeholder": true,
+	"example": true, "sample": true, "dummy": true, "fake": true,
+	"null": true, "nil": true, "none": true, "undefined": true,
+	"n/a": true, "na": true, "empty": true, "unset": true,
+}
+
+// isPlaceholder reports whether a value is empty, a known placeholder word, a template
+// reference such as "<token>" or "${TOKEN}", or one character repeated
+func isPlaceholder(value string) bool {
+	v := strings.ToLower(strings.TrimSpace(value))
+	if v == "" || placeholders[v] {
+		return true
+	}
+	if strings.HasPrefix(v, "your-") || strings.HasPrefix(v, "your_") || strings.HasPrefix(v, "<your") {
+		return true
+	}
+	for _, pair := range [][2]string{{"<", ">"}, {"${", "}"}, {"{{", "}}"}, {"%", "%"}} {
+		if len(v) > len(pair[0])+len(pair[1]) && strings.HasPrefix(v, pair[0]) && strings.HasSuffix(v, pair[1]) {
+			return true
+		}
+	}
+	return len(v) >= 4 && strings.Count(v, v[:1]) == len(v)
+}
+
+// commonPasswords are among the most frequently leaked passwords, normalized by normalizePassword
+var commonPasswords = map[string]bool{
+	"password": true, "pass": true, "passwd": true, "admin": true, "administrator": true,
+	"root": true, "toor": true, "letmein": true, "welcome": true, "qwerty": true, "qwertyuiop": true,
+	"asdf": true, "asdfgh": true, "zxcvbn": true, "abc": true, "abcd": true, "iloveyou": true,
+	"monkey": true, "dragon": true, "master": true, "sunshine": true, "princess": true, "football": true,
+	"baseball": true, "shadow": true, "superman": true, "batman": true, "trustno": true, "hello": true,
+	"secret": true, "token": true, "login": true, "guest": true, "user": true, "test": true, "changeme": true,
+	"default": true, "starwars": true, "whatever": true, "freedom": true, "michael": true, "jennifer": true,
+	"summer": true, "winter": true, "spring": true, "autumn": true, "access": true, "mustang": true,
+	"charlie": true, "jordan": true, "hunter": true, "ranger": true, "killer": true, "cheese": true,
+	"computer": true, "internet": true, "service": true, "postgres": true, "mysql": true, "oracle": true,
+	"redis": true, "database": true, "system": true, "manager": true, "operator": true, "support": true,
+}
+
+// commonNumeric are common all-digit passwords, which normalizing would reduce to nothing
+var commonNumeric = map[string]bool{
+	"123456": true, "1234567": true, "12345678": true, "123456789": true, "1234567890": true,
+	"1234": true, "12345": true, "111111": true, "000000": true, "123123": true, "654321": true,
+	"666666": true, "121212": true, "112233": true, "987654321": true, "159753": true,
+}
+
+// leetspeak maps common character substitutions back to letters
+var leetspeak = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+// normalizePassword lowercases a password, strips trailing digits and symbols, and undoes leetspeak
+func normalizePassword(value string) string {
+	v := strings.ToLower(strings.TrimSpace(value))
+	v = strings.TrimRightFunc(v, func(r rune) bool {
+		return (r < 'a' || r > 'z') && r < 0x80
+	})
+	return leetspeak.Replace(v)
+}
+
+// isCommonPassword reports whether a value is a common password or a simple variation of one
+func isCommonPassword(value string) bool {
+	v := strings.TrimSpace(value)
+	if commonNumeric[v] {
+		return true
+	}
+	return commonPasswords[normalizePassword(v)]
+}
+
+// isLowEntropy reports whether a value is short or its Shannon entropy in bits is below minEntropyBits
+func isLowEntropy(value string) bool {
+	runes := []rune(value)
+	if len(runes) < minSecretLength {
+		return true
+	}
+	counts := make(map[rune]int)
+	for _, r := range runes {
+		counts[r]++
+	}
+	perChar := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(runes))
+		perChar -= p * math.Log2(p)
+	}
+	return perChar*float64(len(runes)) < minEntropyBits
+}
@@ -0,0 +1,136 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Extension is the file extension of template files loaded from a directory
+const Extension = ".tmpl"
+
+// Lookup returns the raw data of the named secret
+type Lookup func(ctx context.Context, name string) (map[string][]byte, error)
+
+// Check returns an error for a secret key the template may not reference
+type Check func(name, key string) error
+
+// placeholderFuncs registers the function names at parse time; Execute binds the real implementations
+var placeholderFuncs = template.FuncMap{
+	"secret":     func(string, string) (string, error) { return "", nil },
+	"secretKeys": func(string) ([]string, error) { return nil, nil },
+	"b64enc":     func(string) string { return "" },
+	"b64dec":     func(string) (string, error) { return "", nil },
+}
+
+// Parse parses template text that may reference secrets with {{ secret "name" "key" }}
+func Parse(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Funcs(placeholderFuncs).Parse(text)
+}
+
+// ParseFile parses a template file, naming it after the file without its extension
+func ParseFile(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	tmpl, err := Parse(name, string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// LoadDir parses every template file in dir, keyed by name
+func LoadDir(dir string) (map[string]*template.Template, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+Extension))
+	if err != nil {
+		return nil, err
+	}
+	templates := make(map[string]*template.Template, len(paths))
+	for _, path := range paths {
+		tmpl, err := ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		templates[tmpl.Name()] = tmpl
+	}
+	return templates, nil
+}
+
+// Execute renders the template, resolving each referenced secret at most once
+func Execute(ctx context.Context, tmpl *template.Template, lookup Lookup) ([]byte, error) {
+	return ExecuteChecked(ctx, tmpl, lookup, nil)
+}
+
+// ExecuteChecked renders the template like Execute, failing when it references a key that check rejects
+func ExecuteChecked(ctx context.Context, tmpl *template.Template, lookup Lookup, check Check) ([]byte, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	cache := make(map[string]map[string][]byte)
+	load := func(name string) (map[string][]byte, error) {
+		if data, ok := cache[name]; ok {
+			return data, nil
+		}
+		data, err := lookup(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		cache[name] = data
+		return data, nil
+	}
+
+	clone.Funcs(template.FuncMap{
+		"secret": func(name, key string) (string, error) {
+			if check != nil {
+				if err := check(name, key); err != nil {
+					return "", err
+				}
+			}
+			data, err := load(name)
+			if err != nil {
+				return "", err
+			}
+			value, ok := data[key]
+			if !ok {
+				return "", fmt.Errorf("secret %s has no key %s", name, key)
+			}
+			return string(value), nil
+		},
+		"secretKeys": func(name string) ([]string, error) {
+			data, err := load(name)
+			if err != nil {
+				return nil, err
+			}
+			keys := make([]string, 0, len(data))
+			for key := range data {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			return keys, nil
+		},
+		"b64enc": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+		"b64dec": func(value string) (string, error) {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			return string(decoded), err
+		},
+	})
+
+	var buf bytes.Buffer
+	if err := clone.Execute(&buf, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
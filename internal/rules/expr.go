@@ -0,0 +1,418 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// kind is the type of an expression value
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBool
+	kindString
+	kindNumber
+	kindDuration
+)
+
+// String names the kind in error messages
+func (k kind) String() string {
+	switch k {
+	case kindBool:
+		return "bool"
+	case kindString:
+		return "string"
+	case kindNumber:
+		return "number"
+	case kindDuration:
+		return "duration"
+	default:
+		return "null"
+	}
+}
+
+// value is the result of evaluating an expression; null stands for an unknown field value
+type value struct {
+	kind kind
+	b    bool
+	s    string
+	n    float64
+}
+
+// node is a compiled expression
+type node interface {
+	// kind is the static type of the node
+	kind() kind
+	eval(fields map[string]value) value
+}
+
+// literal is a constant
+type literal struct{ v value }
+
+func (l literal) kind() kind                  { return l.v.kind }
+func (l literal) eval(map[string]value) value { return l.v }
+
+// field reads a secret field
+type field struct {
+	name string
+	k    kind
+}
+
+func (f field) kind() kind { return f.k }
+func (f field) eval(fields map[string]value) value {
+	v, ok := fields[f.name]
+	if !ok {
+		return value{kind: kindNull}
+	}
+	return v
+}
+
+// not negates a boolean
+type not struct{ operand node }
+
+func (n not) kind() kind { return kindBool }
+func (n not) eval(fields map[string]value) value {
+	v := n.operand.eval(fields)
+	return value{kind: kindBool, b: v.kind == kindBool && !v.b}
+}
+
+// logical is && or ||, short-circuiting
+type logical struct {
+	and         bool
+	left, right node
+}
+
+func (l logical) kind() kind { return kindBool }
+func (l logical) eval(fields map[string]value) value {
+	left := l.left.eval(fields).b
+	if l.and && !left {
+		return value{kind: kindBool}
+	}
+	if !l.and && left {
+		return value{kind: kindBool, b: true}
+	}
+	return value{kind: kindBool, b: l.right.eval(fields).b}
+}
+
+// comparison compares two operands of the same kind; any comparison with null is false
+type comparison struct {
+	op          string
+	left, right node
+	pattern     *regexp.Regexp
+}
+
+func (c comparison) kind() kind { return kindBool }
+func (c comparison) eval(fields map[string]value) value {
+	left := c.left.eval(fields)
+	if c.pattern != nil {
+		return value{kind: kindBool, b: left.kind == kindString && c.pattern.MatchString(left.s)}
+	}
+	right := c.right.eval(fields)
+	if left.kind == kindNull || right.kind == kindNull {
+		return value{kind: kindBool}
+	}
+
+	var result bool
+	switch left.kind {
+	case kindBool:
+		result = (left.b == right.b) == (c.op == "==")
+	case kindString:
+		result = compareOrdered(c.op, strings.Compare(left.s, right.s))
+	default:
+		order := 0
+		if left.n < right.n {
+			order = -1
+		} else if left.n > right.n {
+			order = 1
+		}
+		result = compareOrdered(c.op, order)
+	}
+	return value{kind: kindBool, b: result}
+}
+
+// compareOrdered applies op to the result of a three-way comparison
+func compareOrdered(op string, order int) bool {
+	switch op {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+// token is one lexical element of an expression
+type token struct {
+	text string
+	// literal holds the parsed value of string, number, and duration tokens
+	literal *value
+	pos     int
+}
+
+// operators are matched longest first
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "(", ")"}
+
+// tokenize splits an expression into tokens
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		c := rune(input[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(input) && input[end] != byte(c) {
+				if input[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text := input[i : end+1]
+			s := text[1 : len(text)-1]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(text)
+				if err != nil {
+					return nil, fmt.Errorf("invalid string at %d", i)
+				}
+				s = unquoted
+			}
+			tokens = append(tokens, token{text: text, literal: &value{kind: kindString, s: s}, pos: i})
+			i = end + 1
+		case unicode.IsDigit(c):
+			end := i
+			for end < len(input) && (unicode.IsLetter(rune(input[end])) || unicode.IsDigit(rune(input[end])) || input[end] == '.') {
+				end++
+			}
+			text := input[i:end]
+			literal, err := parseNumber(text)
+			if err != nil {
+				return nil, fmt.Errorf("%v at %d", err, i)
+			}
+			tokens = append(tokens, token{text: text, literal: &literal, pos: i})
+			i = end
+		case unicode.IsLetter(c) || c == '_':
+			end := i
+			for end < len(input) && (unicode.IsLetter(rune(input[end])) || unicode.IsDigit(rune(input[end])) || input[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, token{text: input[i:end], pos: i})
+			i = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(input[i:], op) {
+					tokens = append(tokens, token{text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// parseNumber parses a number, or a duration such as 90s, 2h, or 7d
+func parseNumber(text string) (value, error) {
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		return value{kind: kindNumber, n: n}, nil
+	}
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		if n, err := strconv.ParseFloat(days, 64); err == nil {
+			return value{kind: kindDuration, n: n * float64(24*time.Hour)}, nil
+		}
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return value{}, fmt.Errorf("invalid number or duration %q", text)
+	}
+	return value{kind: kindDuration, n: float64(d)}, nil
+}
+
+// parser is a recursive descent parser over the tokens, type checking as it goes
+type parser struct {
+	tokens []token
+	pos    int
+	fields map[string]kind
+}
+
+// compile parses a boolean expression over the fields
+func compile(input string, fields map[string]kind) (node, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, fields: fields}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at %d", p.tokens[p.pos].text, p.tokens[p.pos].pos)
+	}
+	if expr.kind() != kindBool {
+		return nil, fmt.Errorf("expression is a %s, not a condition", expr.kind())
+	}
+	return expr, nil
+}
+
+// peek returns the next token text, or "" at the end
+func (p *parser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+// or := and ("||" and)*
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		if err := requireBool("||", left, right); err != nil {
+			return nil, err
+		}
+		left = logical{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+// and := unary ("&&" unary)*
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if err := requireBool("&&", left, right); err != nil {
+			return nil, err
+		}
+		left = logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+// unary := "!" unary | comparison
+func (p *parser) unary() (node, error) {
+	if p.peek() == "!" {
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if err := requireBool("!", operand); err != nil {
+			return nil, err
+		}
+		return not{operand: operand}, nil
+	}
+	return p.comparison()
+}
+
+// comparison := primary (op primary)?
+func (p *parser) comparison() (node, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "=~":
+	default:
+		return left, nil
+	}
+	p.pos++
+	right, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	if op == "=~" {
+		lit, ok := right.(literal)
+		if left.kind() != kindString || !ok || lit.v.kind != kindString {
+			return nil, fmt.Errorf("=~ needs a string on the left and a regular expression string on the right")
+		}
+		pattern, err := regexp.Compile(lit.v.s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", lit.v.s, err)
+		}
+		return comparison{op: op, left: left, right: right, pattern: pattern}, nil
+	}
+	if left.kind() != right.kind() {
+		return nil, fmt.Errorf("cannot compare %s %s %s", left.kind(), op, right.kind())
+	}
+	if left.kind() == kindBool && op != "==" && op != "!=" {
+		return nil, fmt.Errorf("cannot order booleans with %s", op)
+	}
+	return comparison{op: op, left: left, right: right}, nil
+}
+
+// primary := "(" or ")" | literal | field | true | false
+func (p *parser) primary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch {
+	case tok.text == "(":
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ) for ( at %d", tok.pos)
+		}
+		p.pos++
+		return expr, nil
+	case tok.literal != nil:
+		return literal{v: *tok.literal}, nil
+	case tok.text == "true" || tok.text == "false":
+		return literal{v: value{kind: kindBool, b: tok.text == "true"}}, nil
+	}
+	if k, ok := p.fields[tok.text]; ok {
+		return field{name: tok.text, k: k}, nil
+	}
+	return nil, fmt.Errorf("unknown field %q at %d", tok.text, tok.pos)
+}
+
+// requireBool reports an error unless every operand is boolean
+func requireBool(op string, operands ...node) error {
+	for _, operand := range operands {
+		if operand.kind() != kindBool {
+			return fmt.Errorf("%s needs conditions, not a %s", op, operand.kind())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package rules
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/reader"
+
+	"sigs.k8s.io/yaml"
+)
+
+// fieldKinds are the secret fields rule expressions may use
+var fieldKinds = map[string]kind{
+	"name":       kindString,
+	"source":     kindString,
+	"namespace":  kindString,
+	"group":      kindString,
+	"found":      kindBool,
+	"crdFound":   kindBool,
+	"syncStatus": kindString,
+	"syncReason": kindString,
+	"syncAge":    kindDuration,
+	"keyCount":   kindNumber,
+	"weakKeys":   kindNumber,
+	"flapping":   kindBool,
+	"error":      kindString,
+}
+
+// Rule severities, matching the notification severities
+var severities = map[string]bool{"info": true, "warning": true, "critical": true}
+
+// Rule raises an alert for each secret its expression matches
+type Rule struct {
+	Name string `json:"name"`
+	// Expr is a condition over secret fields, e.g. syncAge > 2h && namespace == "prod"
+	Expr     string `json:"expr"`
+	Severity string `json:"severity,omitempty"`
+	// Message describes the alert; it defaults to the expression
+	Message string `json:"message,omitempty"`
+
+	condition node
+}
+
+// file is the ALERT_RULES_FILE format (YAML or JSON)
+type file struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadFile reads and compiles the rules in a file
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert rules: %w", err)
+	}
+	var f file
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules %s: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(f.Rules))
+	for i := range f.Rules {
+		rule := &f.Rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("alert rule %d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate alert rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("alert rule %s: %w", rule.Name, err)
+		}
+	}
+	return f.Rules, nil
+}
+
+// compile parses the expression and applies defaults
+func (r *Rule) compile() error {
+	if r.Severity == "" {
+		r.Severity = "warning"
+	}
+	if !severities[r.Severity] {
+		return fmt.Errorf("invalid severity %q", r.Severity)
+	}
+	if r.Message == "" {
+		r.Message = r.Expr
+	}
+	condition, err := compile(r.Expr, fieldKinds)
+	if err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+	r.condition = condition
+	return nil
+}
+
+// fields returns the expression values of a secret
+// syncAge is null when the last sync time is unknown, so comparisons with it are false
+func fields(secret reader.SecretInfo, namespace string, now time.Time) map[string]value {
+	str := func(s string) value { return value{kind: kindString, s: s} }
+	boolean := func(b bool) value { return value{kind: kindBool, b: b} }
+
+	values := map[string]value{
+		"name":       str(secret.Name),
+		"source":     str(secret.Source),
+		"namespace":  str(namespace),
+		"group":      str(secret.Group),
+		"found":      boolean(secret.Found),
+		"crdFound":   boolean(secret.SyncInfo.CRDFound),
+		"syncStatus": str(secret.SyncInfo.SyncStatus),
+		"syncReason": str(secret.SyncInfo.SyncReason),
+		"syncAge":    {kind: kindNull},
+		"keyCount":   {kind: kindNumber, n: float64(len(secret.Keys))},
+		"weakKeys":   {kind: kindNumber, n: float64(len(secret.Weak))},
+		"flapping":   boolean(secret.Flapping),
+		"error":      str(secret.Error),
+	}
+	if synced, err := time.Parse(time.RFC3339, secret.SyncInfo.LastSuccessfulSync); err == nil {
+		values["syncAge"] = value{kind: kindDuration, n: float64(now.Sub(synced))}
+	}
+	return values
+}
+
+// Alert is a rule matching a secret
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Severity  string    `json:"severity"`
+	Message   string    `json:"message"`
+	Secret    string    `json:"secret"`
+	Source    string    `json:"source"`
+	Namespace string    `json:"namespace"`
+	Group     string    `json:"group,omitempty"`
+	Since     time.Time `json:"since"`
+	// Firing is false in the transition that resolves the alert
+	Firing bool `json:"firing"`
+}
+
+// Engine evaluates the rules against each refresh and tracks which alerts are firing
+type Engine struct {
+	rules     []Rule
+	namespace string
+
+	mu     sync.Mutex
+	firing map[string]Alert
+}
+
+// NewEngine creates an engine for the rules; it returns nil when there are none
+func NewEngine(rules []Rule, namespace string) *Engine {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &Engine{rules: rules, namespace: namespace, firing: make(map[string]Alert)}
+}
+
+// Enabled reports whether rules are configured
+func (e *Engine) Enabled() bool {
+	return e != nil
+}
+
+// Rules returns the configured rules
+func (e *Engine) Rules() []Rule {
+	if e == nil {
+		return nil
+	}
+	return e.rules
+}
+
+// Evaluate applies the rules to the secrets and returns the alerts that started firing or resolved
+// Alerts of secrets no longer present resolve too
+func (e *Engine) Evaluate(secrets []reader.SecretInfo, now time.Time) []Alert {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changes []Alert
+	matched := make(map[string]bool)
+	for _, secret := range secrets {
+		values := fields(secret, e.namespace, now)
+		for _, rule := range e.rules {
+			if !rule.condition.eval(values).b {
+				continue
+			}
+			key := rule.Name + "\x00" + secret.Key()
+			matched[key] = true
+			if _, ok := e.firing[key]; ok {
+				continue
+			}
+			alert := Alert{
+				Rule:      rule.Name,
+				Severity:  rule.Severity,
+				Message:   rule.Message,
+				Secret:    secret.Name,
+				Source:    secret.Source,
+				Namespace: e.namespace,
+				Group:     secret.Group,
+				Since:     now.UTC(),
+				Firing:    true,
+			}
+			e.firing[key] = alert
+			changes = append(changes, alert)
+		}
+	}
+
+	for key, alert := range e.firing {
+		if matched[key] {
+			continue
+		}
+		delete(e.firing, key)
+		alert.Firing = false
+		changes = append(changes, alert)
+	}
+	sortAlerts(changes)
+	return changes
+}
+
+// Active returns the firing alerts, sorted by rule and secret
+func (e *Engine) Active() []Alert {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.firing))
+	for _, alert := range e.firing {
+		alerts = append(alerts, alert)
+	}
+	sortAlerts(alerts)
+	return alerts
+}
+
+// sortAlerts orders alerts by rule, secret, and source
+func sortAlerts(alerts []Alert) {
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		if alerts[i].Secret != alerts[j].Secret {
+			return alerts[i].Secret < alerts[j].Secret
+		}
+		return alerts[i].Source < alerts[j].Source
+	})
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys shared between middleware and handlers
+const (
+	requestIDKey       = "requestID"
+	identityKey        = "identity"
+	authMethodKey      = "authMethod"
+	sessionKey         = "session"
+	returnedSecretsKey = "returnedSecrets"
+	namespaceKey       = "namespace"
+)
+
+// requestIDHeader carries the request ID in requests and responses
+const requestIDHeader = "X-Request-ID"
+
+// accessLogEntry is one structured access log line
+type accessLogEntry struct {
+	Time      string   `json:"time"`
+	RequestID string   `json:"requestId"`
+	Identity  string   `json:"identity"`
+	ClientIP  string   `json:"clientIp"`
+	Method    string   `json:"method"`
+	Route     string   `json:"route"`
+	Path      string   `json:"path"`
+	Status    int      `json:"status"`
+	LatencyMs float64  `json:"latencyMs"`
+	Bytes     int      `json:"bytes"`
+	Secrets   []string `json:"secrets,omitempty"`
+}
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+// requestIdentity returns the authenticated identity of the request, or "anonymous"
+func requestIdentity(c *gin.Context) string {
+	if identity := c.GetString(identityKey); identity != "" {
+		return identity
+	}
+	return "anonymous"
+}
+
+// setReturnedSecrets records which secrets a handler returned, for the access and audit logs
+func setReturnedSecrets(c *gin.Context, secrets []reader.SecretInfo) {
+	names := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret.Found {
+			names = append(names, secret.Name)
+		}
+	}
+	c.Set(returnedSecretsKey, names)
+}
+
+// accessLogger writes one JSON line per request and audits requests that returned secrets
+func (s *Server) accessLogger(out io.Writer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		c.Set(requestIDKey, requestID)
+		c.Header(requestIDHeader, requestID)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		entry := accessLogEntry{
+			Time:      start.UTC().Format(time.RFC3339Nano),
+			RequestID: requestID,
+			Identity:  requestIdentity(c),
+			ClientIP:  c.ClientIP(),
+			Method:    c.Request.Method,
+			Route:     route,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			Bytes:     max(c.Writer.Size(), 0),
+			Secrets:   c.GetStringSlice(returnedSecretsKey),
+		}
+		if data, err := json.Marshal(entry); err == nil {
+			_, _ = out.Write(append(data, '\n'))
+		}
+
+		if len(entry.Secrets) > 0 {
+			namespace := c.GetString(namespaceKey)
+			if namespace == "" {
+				namespace = s.config.PodNamespace
+			}
+			s.recordAudit(c, "secrets.read", route, namespace, audit.OutcomeSuccess, map[string]string{
+				"secrets": strings.Join(entry.Secrets, ","),
+			})
+		}
+	}
+}
+
+// newAccessLogWriter returns the scrubbed stdout writer used for access logs, or a discarding writer
+// with ACCESS_LOG_ENABLED=false; requests that return secrets are still audited
+func newAccessLogWriter(enabled bool) io.Writer {
+	if !enabled {
+		return io.Discard
+	}
+	return logging.NewScrubWriter(os.Stdout)
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminConfigHandler returns the effective configuration, where each value came from, and the problems
+// found in it; sensitive values are redacted
+func (s *Server) adminConfigHandler(c *gin.Context) {
+	settings := s.config.Effective()
+	problems := s.config.Validate()
+	c.JSON(http.StatusOK, gin.H{
+		"settings":   settings,
+		"count":      len(settings),
+		"problems":   problems,
+		"valid":      len(problems) == 0,
+		"standalone": s.k8sClients == nil,
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+}
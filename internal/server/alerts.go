@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"bitwarden-reader/internal/hooks"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// alertEvents evaluates the alert rules against the secrets, pushes alerts that started firing or
+// resolved to WebSocket clients, and returns them as events for hooks and notifications
+func (s *Server) alertEvents(secrets []reader.SecretInfo, now time.Time) []hooks.Event {
+	changes := s.alerts.Evaluate(secrets, now)
+	events := make([]hooks.Event, 0, len(changes))
+	for _, alert := range changes {
+		eventType := hooks.EventAlertFiring
+		if !alert.Firing {
+			eventType = hooks.EventAlertResolved
+		}
+		events = append(events, hooks.Event{
+			Type:      eventType,
+			Secret:    alert.Secret,
+			Namespace: alert.Namespace,
+			Group:     alert.Group,
+			Detail:    alert.Message,
+			Rule:      alert.Rule,
+			Severity:  alert.Severity,
+		})
+
+		if !s.currentHub().publish(&broadcastPayload{
+			event:     true,
+			namespace: alert.Namespace,
+			fields: map[string]interface{}{
+				"type":      messageTypeAlert,
+				"event":     eventType,
+				"alert":     alert,
+				"timestamp": now.Format(time.RFC3339),
+			},
+		}) {
+			logging.Printf("WebSocket hub busy, dropped %s message for rule %s on %s", eventType, alert.Rule, alert.Secret)
+		}
+	}
+	return events
+}
+
+// alertsHandler returns the configured alert rules and the alerts currently firing
+func (s *Server) alertsHandler(c *gin.Context) {
+	if !s.alerts.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No alert rules configured (ALERT_RULES_FILE)"})
+		return
+	}
+
+	alerts := s.alerts.Active()
+	c.JSON(http.StatusOK, gin.H{
+		"rules":  s.alerts.Rules(),
+		"alerts": alerts,
+		"count":  len(alerts),
+	})
+}
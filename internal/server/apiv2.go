@@ -0,0 +1,404 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"bitwarden-reader/internal/history"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API path prefixes; /api/v1 is frozen, new response formats go to /api/v2
+const (
+	apiV1Prefix = "/api/v1/"
+	apiV2Prefix = "/api/v2/"
+)
+
+// apiVersionHeader names the API version that served a response
+const apiVersionHeader = "API-Version"
+
+// Page sizes of /api/v2 lists
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// apiVersionState counts requests per API version and knows which /api/v1 routes have a /api/v2 successor
+type apiVersionState struct {
+	v1Requests atomic.Int64
+	v2Requests atomic.Int64
+	// successors are the "METHOD /path" of /api/v1 routes also served under /api/v2, set once routes are registered
+	successors map[string]bool
+}
+
+// apiSuccessors returns the /api/v1 routes that have a /api/v2 route with the same method and path
+func apiSuccessors(routes gin.RoutesInfo) map[string]bool {
+	successors := make(map[string]bool)
+	for _, route := range routes {
+		if strings.HasPrefix(route.Path, apiV2Prefix) {
+			successors[route.Method+" "+apiV1Prefix+strings.TrimPrefix(route.Path, apiV2Prefix)] = true
+		}
+	}
+	return successors
+}
+
+// versionAPI is middleware that marks responses with their API version, adds the deprecation headers to
+// /api/v1 routes that have a successor, and turns /api/v2 errors into structured errors
+// It runs before the IP lists and authentication, so their rejections are structured too
+func (s *Server) versionAPI(c *gin.Context) {
+	path := c.Request.URL.Path
+	switch {
+	case strings.HasPrefix(path, apiV1Prefix):
+		s.apiVersions.v1Requests.Add(1)
+		c.Header(apiVersionHeader, "v1")
+		if s.apiVersions.successors[c.Request.Method+" "+c.FullPath()] {
+			s.setDeprecationHeaders(c, apiV2Prefix+strings.TrimPrefix(path, apiV1Prefix))
+		}
+		c.Next()
+	case strings.HasPrefix(path, apiV2Prefix):
+		s.apiVersions.v2Requests.Add(1)
+		c.Header(apiVersionHeader, "v2")
+		writer := &structuredErrorWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		writer.flush(c)
+	default:
+		c.Next()
+	}
+}
+
+// setDeprecationHeaders announces API_V1_DEPRECATION and API_V1_SUNSET, and the successor route, per RFC 9745 and RFC 8594
+func (s *Server) setDeprecationHeaders(c *gin.Context, successor string) {
+	deprecation, sunset := s.config.APIV1Deprecation, s.config.APIV1Sunset
+	if deprecation.IsZero() && sunset.IsZero() {
+		return
+	}
+	if !deprecation.IsZero() {
+		c.Header("Deprecation", "@"+strconv.FormatInt(deprecation.Unix(), 10))
+	}
+	if !sunset.IsZero() {
+		c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+	c.Writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+	c.Writer.Header().Add("Link", `</api/versions>; rel="deprecation"; type="application/json"`)
+}
+
+// structuredErrorWriter holds back error responses so versionAPI can restructure them
+type structuredErrorWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *structuredErrorWriter) Write(data []byte) (int, error) {
+	if w.Status() < http.StatusBadRequest {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *structuredErrorWriter) WriteString(data string) (int, error) {
+	return w.Write([]byte(data))
+}
+
+// apiError is the error body of /api/v2 responses
+type apiError struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Status    int                    `json:"status"`
+	RequestID string                 `json:"requestId,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// flush writes the held back error response, as {"error": {"code", "message", ...}} when it is a v1 style
+// {"error": "message"} body; other fields of that body move to details
+func (w *structuredErrorWriter) flush(c *gin.Context) {
+	if w.body.Len() == 0 {
+		// Errors without a body, such as unknown routes
+		if w.Status() < http.StatusBadRequest || w.Written() {
+			return
+		}
+		message, _ := json.Marshal(http.StatusText(w.Status()))
+		w.body.WriteString(`{"error":` + string(message) + `}`)
+	}
+	data := w.body.Bytes()
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err == nil {
+		if message, ok := body["error"].(string); ok {
+			delete(body, "error")
+			structured := apiError{
+				Code:      apiErrorCode(w.Status()),
+				Message:   message,
+				Status:    w.Status(),
+				RequestID: c.GetString(requestIDKey),
+			}
+			if len(body) > 0 {
+				structured.Details = body
+			}
+			if encoded, err := json.Marshal(gin.H{"error": structured}); err == nil {
+				data = encoded
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			}
+		}
+	}
+	w.Header().Del("Content-Length")
+	_, _ = w.ResponseWriter.Write(data)
+}
+
+// apiErrorCode names the error of a status code
+func apiErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthenticated"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusPreconditionFailed, http.StatusPreconditionRequired:
+		return "precondition_failed"
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusUnprocessableEntity:
+		return "unprocessable"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusBadGateway:
+		return "upstream_error"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	case http.StatusGatewayTimeout:
+		return "timeout"
+	}
+	if status >= http.StatusInternalServerError {
+		return "internal"
+	}
+	return "error"
+}
+
+// pagination describes one page of a /api/v2 list
+type pagination struct {
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// encodeCursor returns the opaque cursor of the item at offset
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset of a cursor from encodeCursor
+func decodeCursor(cursor string) (int, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !bytes.HasPrefix(data, []byte("o:")) {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(string(data[2:]))
+	return offset, err == nil && offset >= 0
+}
+
+// pageRequest reads ?limit= and ?cursor=, or responds with 400
+func (s *Server) pageRequest(c *gin.Context) (offset, limit int, ok bool) {
+	limit = defaultPageLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": s.tr(c, "limit must be an integer from 1 to %d", maxPageLimit)})
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if offset, ok = decodeCursor(cursor); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": s.tr(c, "Invalid cursor")})
+			return 0, 0, false
+		}
+	}
+	return offset, limit, true
+}
+
+// paginate returns the page of items from offset and describes it
+func paginate[T any](items []T, offset, limit int) ([]T, pagination) {
+	page := pagination{Limit: limit, Total: len(items)}
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := min(offset+limit, len(items))
+	if end < len(items) {
+		page.NextCursor = encodeCursor(end)
+	}
+	return items[offset:end], page
+}
+
+// v2Meta returns the metadata of a /api/v2 response, with fields added
+func v2Meta(c *gin.Context, fields gin.H) gin.H {
+	meta := gin.H{
+		"apiVersion": "v2",
+		"requestId":  c.GetString(requestIDKey),
+		"timestamp":  time.Now().Format(time.RFC3339),
+	}
+	for key, value := range fields {
+		meta[key] = value
+	}
+	return meta
+}
+
+// v2List returns a /api/v2 list body, linking the next page in the Link header
+func v2List(c *gin.Context, data interface{}, page pagination, meta gin.H) gin.H {
+	if page.NextCursor != "" {
+		next := *c.Request.URL
+		query := next.Query()
+		query.Set("cursor", page.NextCursor)
+		next.RawQuery = query.Encode()
+		c.Writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+	return gin.H{
+		"data":       data,
+		"pagination": page,
+		"meta":       v2Meta(c, meta),
+	}
+}
+
+// v2SecretsHandler returns a page of the secrets, read from ?namespace= when given
+func (s *Server) v2SecretsHandler(c *gin.Context) {
+	offset, limit, ok := s.pageRequest(c)
+	if !ok {
+		return
+	}
+	namespace, ok := s.requestNamespace(c, "", "secrets", "get")
+	if !ok {
+		return
+	}
+	secrets, err := s.readNamespaceSecrets(c.Request.Context(), namespace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	secrets = reader.FilterByGroup(secrets, c.Query("group"))
+	selected, page := paginate(secrets, offset, limit)
+	setReturnedSecrets(c, selected)
+	if c.Query("expand") == "true" {
+		reader.ExpandValues(selected)
+	}
+
+	s.respondWithSecrets(c, http.StatusOK, v2List(c, selected, page, withNamespaceStatus(gin.H{
+		"namespace":  namespace,
+		"totalFound": countFoundSecrets(secrets),
+	}, secrets)), secrets)
+}
+
+// v2NamespacesHandler returns a page of the namespaces the reader is allowed to browse
+func (s *Server) v2NamespacesHandler(c *gin.Context) {
+	offset, limit, ok := s.pageRequest(c)
+	if !ok {
+		return
+	}
+	namespaces, ok := s.browsableNamespaces(c)
+	if !ok {
+		return
+	}
+	selected, page := paginate(namespaces, offset, limit)
+	c.JSON(http.StatusOK, v2List(c, selected, page, gin.H{"current": s.config.PodNamespace}))
+}
+
+// v2TriggerHistoryHandler returns a page of past trigger-sync requests, newest first
+// Optional filters: secret, initiator, and since (RFC3339 or unix seconds)
+func (s *Server) v2TriggerHistoryHandler(c *gin.Context) {
+	offset, limit, ok := s.pageRequest(c)
+	if !ok {
+		return
+	}
+	filter := history.TriggerFilter{
+		Secret:    c.Query("secret"),
+		Initiator: c.Query("initiator"),
+	}
+	if since := c.Query("since"); since != "" {
+		filter.Since = parseSince(since)
+		if filter.Since.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC3339 timestamp or unix seconds"})
+			return
+		}
+	}
+	selected, page := paginate(s.history.Triggers(filter), offset, limit)
+	c.JSON(http.StatusOK, v2List(c, selected, page, nil))
+}
+
+// v2TriggerHandler returns one trigger-sync request by ID
+func (s *Server) v2TriggerHandler(c *gin.Context) {
+	record, ok := s.history.Trigger(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": s.tr(c, "Trigger not found")})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": record, "meta": v2Meta(c, nil)})
+}
+
+// apiVersionInfo describes an API version at /api/versions
+type apiVersionInfo struct {
+	Version     string `json:"version"`
+	Path        string `json:"path"`
+	Status      string `json:"status"`
+	Deprecation string `json:"deprecation,omitempty"`
+	Sunset      string `json:"sunset,omitempty"`
+}
+
+// apiVersionsHandler documents the API versions, what distinguishes them, and the /api/v1 routes /api/v2 replaces
+func (s *Server) apiVersionsHandler(c *gin.Context) {
+	v1 := apiVersionInfo{Version: "v1", Path: strings.TrimSuffix(apiV1Prefix, "/"), Status: "frozen"}
+	if !s.config.APIV1Deprecation.IsZero() {
+		v1.Status = "deprecated"
+		v1.Deprecation = s.config.APIV1Deprecation.Format(time.RFC3339)
+	}
+	if !s.config.APIV1Sunset.IsZero() {
+		v1.Sunset = s.config.APIV1Sunset.Format(time.RFC3339)
+	}
+
+	successors := make([]string, 0, len(s.apiVersions.successors))
+	for route := range s.apiVersions.successors {
+		successors = append(successors, route)
+	}
+	sort.Strings(successors)
+
+	c.JSON(http.StatusOK, gin.H{
+		"current": "v2",
+		"versions": []apiVersionInfo{
+			v1,
+			{Version: "v2", Path: strings.TrimSuffix(apiV2Prefix, "/"), Status: "current"},
+		},
+		"replacedV1Routes": successors,
+		"negotiation": gin.H{
+			"path":          "The version is part of the path; responses under /api/v1 and /api/v2 name it in the " + apiVersionHeader + " header",
+			"errors":        `v2 errors are {"error": {"code", "message", "status", "requestId", "details"}}; v1 errors are {"error": "message"}`,
+			"pagination":    "v2 lists take limit (1-" + strconv.Itoa(maxPageLimit) + ", default " + strconv.Itoa(defaultPageLimit) + ") and cursor, and return data, pagination, and meta; the next page is also in the Link header",
+			"triggerSync":   "POST /api/v1/trigger-sync returns per-secret results with Accept: application/json; version=" + triggerResultsVersion + "; /api/v2/trigger-sync always does",
+			"deprecation":   "v1 routes with a v2 successor carry Deprecation, Sunset, and Link rel=successor-version headers once API_V1_DEPRECATION or API_V1_SUNSET is set",
+			"compatibility": "v1 response shapes don't change; new fields and formats are added to v2",
+		},
+	})
+}
+
+// writeAPIVersionMetrics writes the requests per API version, to find clients still on /api/v1
+func (s *Server) writeAPIVersionMetrics(w io.Writer) {
+	writeLabelledMetric(w, "bitwarden_reader_api_requests_total", "counter", "API requests by API version.", "version", map[string]float64{
+		"v1": float64(s.apiVersions.v1Requests.Load()),
+		"v2": float64(s.apiVersions.v2Requests.Load()),
+	})
+}
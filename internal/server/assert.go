@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"bitwarden-reader/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keyRequirement asserts that a secret exists and contains the listed keys
+type keyRequirement struct {
+	// Secret is "namespace/name", or "name" in the pod namespace
+	Secret string   `json:"secret"`
+	Keys   []string `json:"keys"`
+}
+
+// assertRequest is the body of POST /api/v1/assert
+type assertRequest struct {
+	Requirements []keyRequirement `json:"requirements"`
+}
+
+// requirementResult reports whether one requirement holds
+type requirementResult struct {
+	Secret      string   `json:"secret"`
+	Namespace   string   `json:"namespace"`
+	Passed      bool     `json:"passed"`
+	Found       bool     `json:"found"`
+	MissingKeys []string `json:"missingKeys,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// checkRequirement evaluates one requirement against the cluster
+func (s *Server) checkRequirement(c *gin.Context, requirement keyRequirement) requirementResult {
+	ref, err := s.parseSecretRef(requirement.Secret)
+	if err != nil {
+		return requirementResult{Secret: requirement.Secret, Error: err.Error()}
+	}
+	result := requirementResult{Secret: ref.Name, Namespace: ref.Namespace}
+	if !s.config.NamespaceAllowed(ref.Namespace) {
+		result.Error = s.tr(c, "Namespace '%s' is not in the allowed namespace list", ref.Namespace)
+		return result
+	}
+
+	secret, err := k8s.ReadSecret(c.Request.Context(), ref.Name, ref.Namespace, s.clientsFor(c.Request.Context(), ref.Namespace).Clientset)
+	if err != nil {
+		if !k8s.IsSecretNotFound(err) {
+			result.Error = err.Error()
+		}
+		return result
+	}
+	defer k8s.WipeSecretData(secret.Data)
+
+	result.Found = true
+	for _, key := range requirement.Keys {
+		key = strings.TrimSpace(key)
+		if _, ok := secret.Data[key]; !ok {
+			result.MissingKeys = append(result.MissingKeys, key)
+		}
+	}
+	result.Passed = len(result.MissingKeys) == 0
+	return result
+}
+
+// assertHandler checks secrets for required keys, for CI gates
+// Responds 200 when every requirement passes and 422 otherwise; values are never returned
+func (s *Server) assertHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	var req assertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	if len(req.Requirements) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one requirement is required"})
+		return
+	}
+
+	passed := true
+	results := make([]requirementResult, 0, len(req.Requirements))
+	for _, requirement := range req.Requirements {
+		result := s.checkRequirement(c, requirement)
+		passed = passed && result.Passed
+		results = append(results, result)
+	}
+
+	code := http.StatusOK
+	if !passed {
+		code = http.StatusUnprocessableEntity
+	}
+	c.JSON(code, gin.H{
+		"passed":  passed,
+		"results": results,
+	})
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminAuditSinksHandler returns the delivery state of each external audit sink
+func (s *Server) adminAuditSinksHandler(c *gin.Context) {
+	sinks := s.audit.SinkStatuses()
+	c.JSON(http.StatusOK, gin.H{
+		"sinks": sinks,
+		"count": len(sinks),
+	})
+}
+
+// writeAuditSinkMetrics writes the per-sink delivery metrics, if any sinks are configured
+func (s *Server) writeAuditSinkMetrics(w io.Writer) {
+	sinks := s.audit.SinkStatuses()
+	if len(sinks) == 0 {
+		return
+	}
+	pending := make(map[string]float64, len(sinks))
+	delivered := make(map[string]float64, len(sinks))
+	failures := make(map[string]float64, len(sinks))
+	dropped := make(map[string]float64, len(sinks))
+	for _, sink := range sinks {
+		pending[sink.Name] = float64(sink.Pending)
+		delivered[sink.Name] = float64(sink.Delivered)
+		failures[sink.Name] = float64(sink.Failures)
+		dropped[sink.Name] = float64(sink.Dropped)
+	}
+	writeLabelledMetric(w, "bitwarden_reader_audit_sink_pending", "gauge", "Audit events buffered for an external sink.", "sink", pending)
+	writeLabelledMetric(w, "bitwarden_reader_audit_sink_delivered_total", "counter", "Audit events delivered to an external sink.", "sink", delivered)
+	writeLabelledMetric(w, "bitwarden_reader_audit_sink_failures_total", "counter", "Failed deliveries to an external audit sink.", "sink", failures)
+	writeLabelledMetric(w, "bitwarden_reader_audit_sink_dropped_total", "counter", "Audit events dropped because a sink's buffer was full.", "sink", dropped)
+}
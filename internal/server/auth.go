@@ -0,0 +1,366 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authenticator establishes who made a request
+// Authenticate returns ok false when the request carries no credentials the authenticator accepts,
+// so the next authenticator in AUTH_METHODS is tried; err explains why presented credentials were
+// rejected and is only logged
+type Authenticator interface {
+	Name() string
+	Authenticate(c *gin.Context) (identity string, ok bool, err error)
+}
+
+// Challenger is implemented by authenticators that tell clients how to authenticate
+// through a WWW-Authenticate header on 401 responses
+type Challenger interface {
+	Challenge() string
+}
+
+// AuthenticatorFactory creates an authenticator from the configuration
+type AuthenticatorFactory func(cfg *config.Config) (Authenticator, error)
+
+var (
+	authenticatorsMu sync.Mutex
+	authenticators   = map[string]AuthenticatorFactory{
+		"none":  newNoneAuthenticator,
+		"token": newTokenAuthenticator,
+		"basic": newBasicAuthenticator,
+		"oidc":  newOIDCAuthenticator,
+		"mtls":  newMTLSAuthenticator,
+	}
+)
+
+// RegisterAuthenticator makes an authenticator available under name in AUTH_METHODS,
+// e.g. for an organization's own SSO; it must be called before NewServer
+func RegisterAuthenticator(name string, factory AuthenticatorFactory) {
+	authenticatorsMu.Lock()
+	defer authenticatorsMu.Unlock()
+	authenticators[strings.ToLower(name)] = factory
+}
+
+// AuthChain tries authenticators in order; the first to accept the request sets its identity
+type AuthChain []Authenticator
+
+// NewAuthChain creates the authenticators listed in AUTH_METHODS
+func NewAuthChain(cfg *config.Config) (AuthChain, error) {
+	authenticatorsMu.Lock()
+	defer authenticatorsMu.Unlock()
+
+	if len(cfg.AuthMethods) == 0 {
+		return nil, errors.New("no authentication methods configured")
+	}
+	chain := make(AuthChain, 0, len(cfg.AuthMethods))
+	for _, method := range cfg.AuthMethods {
+		factory, ok := authenticators[method]
+		if !ok {
+			names := make([]string, 0, len(authenticators))
+			for name := range authenticators {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown authentication method %q, use one of %s", method, strings.Join(names, ", "))
+		}
+		authenticator, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+		chain = append(chain, authenticator)
+	}
+	return chain, nil
+}
+
+// Name lists the chained methods
+func (a AuthChain) Name() string {
+	names := make([]string, len(a))
+	for i, authenticator := range a {
+		names[i] = authenticator.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Authenticate returns the identity from the first authenticator that accepts the request, noting
+// its name in the request, or the errors of all authenticators that rejected credentials when none does
+func (a AuthChain) Authenticate(c *gin.Context) (string, bool, error) {
+	var rejections rejectionError
+	for _, authenticator := range a {
+		identity, ok, err := authenticator.Authenticate(c)
+		if ok {
+			c.Set(authMethodKey, authenticator.Name())
+			return identity, true, nil
+		}
+		if err != nil {
+			rejections = append(rejections, fmt.Errorf("%s: %w", authenticator.Name(), err))
+		}
+	}
+	if len(rejections) > 0 {
+		return "", false, rejections
+	}
+	return "", false, nil
+}
+
+// rejectionError lists why the chained authenticators rejected credentials
+type rejectionError []error
+
+func (e rejectionError) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e rejectionError) Unwrap() []error { return e }
+
+// throttledError rejects credentials without checking them, after too many failed attempts
+type throttledError struct {
+	retryAfter time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("too many failed attempts, retry in %s", e.retryAfter.Round(time.Second))
+}
+
+// Challenge returns the distinct WWW-Authenticate values of the chained authenticators
+func (a AuthChain) Challenge() string {
+	var challenges []string
+	for _, authenticator := range a {
+		if challenger, ok := authenticator.(Challenger); ok && !slices.Contains(challenges, challenger.Challenge()) {
+			challenges = append(challenges, challenger.Challenge())
+		}
+	}
+	return strings.Join(challenges, ", ")
+}
+
+// authExempt are the paths served without authentication, for probes, metrics scrapers, and uptime monitors
+var authExempt = map[string]bool{
+	"/livez":               true,
+	"/readyz":              true,
+	"/statusz":             true,
+	"/metrics":             true,
+	"/metrics/autoscaling": true,
+	"/api/v1/health":       true,
+}
+
+// newAuthenticator creates the AUTH_METHODS chain; a misconfigured chain rejects every request
+// rather than leaving the dashboard open
+func newAuthenticator(cfg *config.Config) Authenticator {
+	chain, err := NewAuthChain(cfg)
+	if err != nil {
+		logging.Printf("Error in AUTH_METHODS, rejecting all requests: %v", err)
+		return denyAuthenticator{}
+	}
+	return chain
+}
+
+// sessionMethods are the AUTH_METHODS whose browser logins start a session
+var sessionMethods = map[string]bool{
+	"basic": true,
+	"oidc":  true,
+}
+
+// authenticate is middleware that sets the request identity from AUTH_METHODS
+// Requests with an identity from their session are not authenticated again, and a browser that
+// logs in with a session method gets a session
+func (s *Server) authenticate(c *gin.Context) {
+	if authExempt[c.Request.URL.Path] || c.GetString(identityKey) != "" {
+		c.Next()
+		return
+	}
+
+	identity, ok, err := s.authenticator.Authenticate(c)
+	if !ok {
+		if err != nil {
+			logging.Printf("Rejected credentials from %s: %v", c.ClientIP(), err)
+			s.recordAudit(c, "auth.reject", c.Request.URL.Path, "", audit.OutcomeDenied, map[string]string{"reason": err.Error()})
+		}
+		var throttled *throttledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(throttled.retryAfter.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": s.tr(c, "Too many failed login attempts, try again later")})
+			return
+		}
+		if challenger, isChallenger := s.authenticator.(Challenger); isChallenger {
+			if challenge := challenger.Challenge(); challenge != "" {
+				c.Header("WWW-Authenticate", challenge)
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": s.tr(c, "Authentication required")})
+		return
+	}
+	if identity != "" {
+		c.Set(identityKey, identity)
+		if sessionMethods[c.GetString(authMethodKey)] && isBrowserRequest(c) {
+			s.startSession(c, identity)
+		}
+	}
+	c.Next()
+}
+
+// isBrowserRequest reports whether a browser made the request; browsers send Sec-Fetch-Mode with
+// every request, scripts and API clients normally don't
+func isBrowserRequest(c *gin.Context) bool {
+	return c.GetHeader("Sec-Fetch-Mode") != ""
+}
+
+// denyAuthenticator rejects every request
+type denyAuthenticator struct{}
+
+func (denyAuthenticator) Name() string { return "deny" }
+
+func (denyAuthenticator) Authenticate(*gin.Context) (string, bool, error) {
+	return "", false, nil
+}
+
+// noneAuthenticator accepts every request without an identity
+// Last in a chain, it makes authentication optional
+type noneAuthenticator struct{}
+
+func newNoneAuthenticator(*config.Config) (Authenticator, error) {
+	return noneAuthenticator{}, nil
+}
+
+func (noneAuthenticator) Name() string { return "none" }
+
+func (noneAuthenticator) Authenticate(*gin.Context) (string, bool, error) {
+	return "", true, nil
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(c *gin.Context) string {
+	scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// readCredentialLines returns the "name:value" lines of a credentials file, skipping blank lines and comments
+func readCredentialLines(path string) ([][2]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines [][2]string
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found || name == "" || value == "" {
+			return nil, fmt.Errorf("%s:%d: expected name:value", path, number)
+		}
+		lines = append(lines, [2]string{name, value})
+	}
+	return lines, scanner.Err()
+}
+
+// tokenAuthenticator accepts bearer tokens listed in AUTH_TOKENS_FILE
+// Only SHA-256 hashes of the tokens are kept in memory
+type tokenAuthenticator struct {
+	identities map[string]string
+}
+
+func newTokenAuthenticator(cfg *config.Config) (Authenticator, error) {
+	if cfg.AuthTokensFile == "" {
+		return nil, errors.New("AUTH_TOKENS_FILE is not set")
+	}
+	lines, err := readCredentialLines(cfg.AuthTokensFile)
+	if err != nil {
+		return nil, err
+	}
+	a := &tokenAuthenticator{identities: make(map[string]string, len(lines))}
+	for _, line := range lines {
+		sum := sha256.Sum256([]byte(line[1]))
+		a.identities[hex.EncodeToString(sum[:])] = line[0]
+	}
+	return a, nil
+}
+
+func (a *tokenAuthenticator) Name() string { return "token" }
+
+func (a *tokenAuthenticator) Challenge() string { return `Bearer realm="bitwarden-reader"` }
+
+func (a *tokenAuthenticator) Authenticate(c *gin.Context) (string, bool, error) {
+	token := bearerToken(c)
+	if token == "" {
+		return "", false, nil
+	}
+	sum := sha256.Sum256([]byte(token))
+	identity, ok := a.identities[hex.EncodeToString(sum[:])]
+	if !ok {
+		return "", false, errors.New("unknown token")
+	}
+	return identity, true, nil
+}
+
+// mtlsAuthenticator takes the identity from the verified client certificate: the common name, or the
+// whole subject without one
+// With TLS terminated at the ingress, the subject comes from AUTH_MTLS_HEADER, e.g. ingress-nginx's
+// ssl-client-subject-dn; the ingress must verify client certificates and overwrite the header
+type mtlsAuthenticator struct {
+	header string
+}
+
+func newMTLSAuthenticator(cfg *config.Config) (Authenticator, error) {
+	return &mtlsAuthenticator{header: cfg.AuthMTLSHeader}, nil
+}
+
+func (a *mtlsAuthenticator) Name() string { return "mtls" }
+
+func (a *mtlsAuthenticator) Authenticate(c *gin.Context) (string, bool, error) {
+	if state := c.Request.TLS; state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		subject := state.VerifiedChains[0][0].Subject
+		if subject.CommonName != "" {
+			return subject.CommonName, true, nil
+		}
+		return subject.String(), true, nil
+	}
+	if a.header == "" {
+		return "", false, nil
+	}
+	subject := strings.TrimSpace(c.GetHeader(a.header))
+	if subject == "" {
+		return "", false, nil
+	}
+	return subjectIdentity(subject), true, nil
+}
+
+// subjectIdentity returns the CN of a distinguished name such as "CN=alice,O=platform" or "/O=platform/CN=alice",
+// or the whole name without one
+func subjectIdentity(subject string) string {
+	separator := ","
+	if strings.HasPrefix(subject, "/") {
+		separator = "/"
+	}
+	for _, part := range strings.Split(subject, separator) {
+		if name, value, found := strings.Cut(strings.TrimSpace(part), "="); found && strings.EqualFold(name, "CN") {
+			return value
+		}
+	}
+	return subject
+}
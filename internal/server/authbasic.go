@@ -0,0 +1,230 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicVerifiedTTL is how long a verified user and password skip bcrypt, since browsers send them on every request
+const basicVerifiedTTL = 5 * time.Minute
+
+// basicAuthenticator accepts HTTP basic auth users from the htpasswd file AUTH_BASIC_FILE
+// Passwords are bcrypt hashes as written by htpasswd -B; unsalted {SHA} hashes from htpasswd -s are
+// still accepted with a warning
+type basicAuthenticator struct {
+	users   map[string]string
+	dummy   []byte
+	limiter *loginLimiter
+
+	mu       sync.Mutex
+	verified map[string]time.Time
+}
+
+func newBasicAuthenticator(cfg *config.Config) (Authenticator, error) {
+	if cfg.AuthBasicFile == "" {
+		return nil, errors.New("AUTH_BASIC_FILE is not set")
+	}
+	lines, err := readCredentialLines(cfg.AuthBasicFile)
+	if err != nil {
+		return nil, err
+	}
+	a := &basicAuthenticator{
+		users:    make(map[string]string, len(lines)),
+		limiter:  newLoginLimiter(cfg.AuthBasicMaxFailures, cfg.AuthBasicLockout),
+		verified: make(map[string]time.Time),
+	}
+	for _, line := range lines {
+		user, hash := line[0], line[1]
+		switch {
+		case isBcryptHash(hash):
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return nil, fmt.Errorf("user %s: %w", user, err)
+			}
+		case strings.HasPrefix(hash, "{SHA}"):
+			logging.Printf("Warning: AUTH_BASIC_FILE user %s has an unsalted {SHA} password hash, use htpasswd -B for bcrypt", user)
+		default:
+			return nil, fmt.Errorf("user %s: unsupported password hash, use htpasswd -B", user)
+		}
+		a.users[user] = hash
+	}
+
+	// Unknown users are checked against a throwaway hash, so they take as long as wrong passwords
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+	if a.dummy, err = bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// isBcryptHash reports whether an htpasswd hash is bcrypt
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2y$") || strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$")
+}
+
+func (a *basicAuthenticator) Name() string { return "basic" }
+
+func (a *basicAuthenticator) Challenge() string {
+	return `Basic realm="bitwarden-reader", charset="UTF-8"`
+}
+
+func (a *basicAuthenticator) Authenticate(c *gin.Context) (string, bool, error) {
+	user, password, ok := c.Request.BasicAuth()
+	if !ok {
+		return "", false, nil
+	}
+	now := time.Now()
+	sum := sha256.Sum256([]byte(user + "\x00" + password))
+	key := hex.EncodeToString(sum[:])
+	if a.cached(key, now) {
+		return user, true, nil
+	}
+
+	// Failures count per client IP and per user, so changing either alone doesn't reset the lockout
+	client := c.ClientIP()
+	limits := []string{"ip:" + client, "user:" + user}
+	for _, limit := range limits {
+		if wait := a.limiter.blocked(limit, now); wait > 0 {
+			return "", false, &throttledError{retryAfter: wait}
+		}
+	}
+	if !a.check(user, password) {
+		for _, limit := range limits {
+			if a.limiter.fail(limit, now) {
+				logging.Printf("Locked out basic auth for %s (from %s) for %s after %d failed logins", limit, client, a.limiter.window, a.limiter.maxFailures)
+			}
+		}
+		return "", false, fmt.Errorf("invalid password for user %q", user)
+	}
+
+	for _, limit := range limits {
+		a.limiter.reset(limit)
+	}
+	a.remember(key, now)
+	logging.Printf("Basic auth login for %s from %s", user, client)
+	return user, true, nil
+}
+
+// check compares the password with the user's hash
+func (a *basicAuthenticator) check(user, password string) bool {
+	hash, known := a.users[user]
+	if !known || isBcryptHash(hash) {
+		if !known {
+			hash = string(a.dummy)
+		}
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil && known
+	}
+	sum := sha1.Sum([]byte(password))
+	presented := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(hash)) == 1
+}
+
+// cached reports whether the user and password were verified within basicVerifiedTTL
+func (a *basicAuthenticator) cached(key string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return now.Before(a.verified[key])
+}
+
+// remember skips bcrypt for the user and password until basicVerifiedTTL passes
+func (a *basicAuthenticator) remember(key string, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for k, expires := range a.verified {
+		if !now.Before(expires) {
+			delete(a.verified, k)
+		}
+	}
+	a.verified[key] = now.Add(basicVerifiedTTL)
+}
+
+// loginLimiter locks out clients after too many failed logins; a client is a client IP or a user name
+// A client that fails maxFailures times within window is rejected until window has passed since its lockout
+type loginLimiter struct {
+	maxFailures int
+	window      time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*loginFailures
+}
+
+// loginFailures are a client's failed logins in the current window
+type loginFailures struct {
+	count  int
+	first  time.Time
+	locked time.Time
+}
+
+// newLoginLimiter creates a limiter; maxFailures 0 disables it
+func newLoginLimiter(maxFailures int, window time.Duration) *loginLimiter {
+	return &loginLimiter{maxFailures: maxFailures, window: window, clients: make(map[string]*loginFailures)}
+}
+
+// blocked returns how long the client remains locked out
+func (l *loginLimiter) blocked(client string, now time.Time) time.Duration {
+	if l.maxFailures <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if failures, ok := l.clients[client]; ok && !failures.locked.IsZero() {
+		if wait := failures.locked.Add(l.window).Sub(now); wait > 0 {
+			return wait
+		}
+		delete(l.clients, client)
+	}
+	return 0
+}
+
+// fail counts a failed login and reports whether it locked the client out
+func (l *loginLimiter) fail(client string, now time.Time) bool {
+	if l.maxFailures <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, failures := range l.clients {
+		since := failures.first
+		if !failures.locked.IsZero() {
+			since = failures.locked
+		}
+		if now.Sub(since) > l.window {
+			delete(l.clients, key)
+		}
+	}
+	failures, ok := l.clients[client]
+	if !ok {
+		failures = &loginFailures{first: now}
+		l.clients[client] = failures
+	}
+	failures.count++
+	if failures.count >= l.maxFailures && failures.locked.IsZero() {
+		failures.locked = now
+		return true
+	}
+	return false
+}
+
+// reset forgets the client's failed logins after a successful one
+func (l *loginLimiter) reset(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.clients, client)
+}
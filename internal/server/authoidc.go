@@ -0,0 +1,287 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcKeyRefreshInterval limits how often the issuer's keys are fetched again for an unknown key ID
+const oidcKeyRefreshInterval = time.Minute
+
+// oidcClockSkew is the leeway allowed on token expiry and not-before times
+const oidcClockSkew = time.Minute
+
+// oidcAuthenticator accepts OIDC ID tokens sent as bearer tokens, e.g. by oauth2-proxy in front of the dashboard
+// Tokens are verified against the keys the issuer publishes, found through its discovery document on first use
+type oidcAuthenticator struct {
+	issuer   string
+	clientID string
+	claim    string
+	client   *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+func newOIDCAuthenticator(cfg *config.Config) (Authenticator, error) {
+	if cfg.AuthOIDCIssuer == "" || cfg.AuthOIDCClientID == "" {
+		return nil, errors.New("AUTH_OIDC_ISSUER and AUTH_OIDC_CLIENT_ID are required")
+	}
+	return &oidcAuthenticator{
+		issuer:   cfg.AuthOIDCIssuer,
+		clientID: cfg.AuthOIDCClientID,
+		claim:    cfg.AuthOIDCIdentityClaim,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (a *oidcAuthenticator) Name() string { return "oidc" }
+
+func (a *oidcAuthenticator) Challenge() string { return `Bearer realm="bitwarden-reader"` }
+
+func (a *oidcAuthenticator) Authenticate(c *gin.Context) (string, bool, error) {
+	token := bearerToken(c)
+	if strings.Count(token, ".") != 2 {
+		// Not a JWT, maybe a token for the token method
+		return "", false, nil
+	}
+	claims, err := a.verify(token, time.Now())
+	if err != nil {
+		return "", false, err
+	}
+	identity, _ := claims[a.claim].(string)
+	if identity == "" {
+		return "", false, fmt.Errorf("token has no %s claim", a.claim)
+	}
+	return identity, true, nil
+}
+
+// verify checks the token's signature, issuer, audience, and validity period and returns its claims
+func (a *oidcAuthenticator) verify(token string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+	key, err := a.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	if issuer, _ := claims["iss"].(string); issuer != a.issuer {
+		return nil, fmt.Errorf("token issued by %q, expected %q", issuer, a.issuer)
+	}
+	if !audienceContains(claims["aud"], a.clientID) {
+		return nil, fmt.Errorf("token audience does not include %q", a.clientID)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(oidcClockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// audienceContains reports whether the aud claim, a string or a list, includes clientID
+func audienceContains(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, item := range aud {
+			if item == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifySignature checks an RS256/384/512 or ES256/384 signature
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %s does not match the RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return fmt.Errorf("algorithm %s does not match the EC key", alg)
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return errors.New("unsupported key type")
+	}
+	return nil
+}
+
+// key returns the issuer's key with the ID, fetching the keys when they are not known yet
+func (a *oidcAuthenticator) key(kid string) (crypto.PublicKey, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(a.fetched) < oidcKeyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	a.fetched = time.Now()
+	keys, err := a.fetchKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the issuer's keys: %w", err)
+	}
+	a.keys = keys
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// Issuers with a single key may leave out the key ID
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// fetchKeys reads the issuer's discovery document and the key set it points to
+func (a *oidcAuthenticator) fetchKeys() (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := a.getJSON(a.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != a.issuer {
+		return nil, fmt.Errorf("discovery document names issuer %q", discovery.Issuer)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := a.getJSON(discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("the issuer publishes no usable signing keys")
+	}
+	return keys, nil
+}
+
+// getJSON decodes the JSON document at url
+func (a *oidcAuthenticator) getJSON(url string, v interface{}) error {
+	resp, err := a.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authTokenSecret is one BitwardenSecret and the state of the auth-token Secret it references
+type authTokenSecret struct {
+	Name      string             `json:"name"`
+	AuthToken k8s.AuthTokenCheck `json:"authToken"`
+}
+
+// authTokensHandler checks the auth-token Secret of every BitwardenSecret in the namespace, so CRDs whose
+// token is missing or expired are found before their syncs fail
+// Without list permission only the BitwardenSecrets named in SECRET_NAMES are checked
+func (s *Server) authTokensHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	namespace := s.config.PodNamespace
+	clients := s.clientsFor(ctx, namespace)
+	crds, err := k8s.ListBitwardenSecretCRDs(ctx, namespace, clients.DynamicClient)
+	listed := err == nil
+	if !listed {
+		crds = make(map[string]*k8s.CRDInfo)
+		for _, name := range s.config.SecretNames {
+			if info, _ := k8s.GetBitwardenSecretCRD(ctx, name, namespace, clients.DynamicClient); info != nil && info.CRDFound {
+				crds[name] = info
+			}
+		}
+	}
+
+	names := make([]string, 0, len(crds))
+	for name := range crds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checks := make(k8s.AuthTokenChecks)
+	secrets := make([]authTokenSecret, 0, len(names))
+	broken := 0
+	for _, name := range names {
+		ref := k8s.AuthTokenRef{SecretName: crds[name].AuthTokenSecretName, SecretKey: crds[name].AuthTokenSecretKey}
+		check := checks.Check(ctx, clients.Clientset, namespace, ref)
+		if check.Broken() {
+			broken++
+		}
+		secrets = append(secrets, authTokenSecret{Name: name, AuthToken: check})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"namespace":        namespace,
+		"bitwardenSecrets": secrets,
+		"count":            len(secrets),
+		"broken":           broken,
+		"listed":           listed,
+		"timestamp":        time.Now().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Metric names served at /metrics/autoscaling
+const (
+	autoscalingConnections = "websocket_connections"
+	autoscalingLag         = "websocket_broadcast_lag_seconds"
+	autoscalingQueued      = "websocket_queued_messages"
+)
+
+// customMetricsAPIVersion is the custom metrics API whose MetricValueList /metrics/autoscaling mirrors
+const customMetricsAPIVersion = "custom.metrics.k8s.io/v1beta2"
+
+// deliveryLag returns how long the slowest client has had a message waiting to be written,
+// and how many messages wait for all clients together
+func (h *Hub) deliveryLag(now time.Time) (time.Duration, int) {
+	var lag time.Duration
+	queued := 0
+	h.live.Range(func(key, _ interface{}) bool {
+		client := key.(*Client)
+		queued += len(client.send)
+		if since := client.waitingSince.Load(); since > 0 {
+			lag = max(lag, now.Sub(time.Unix(0, since)))
+		}
+		return true
+	})
+	return lag, queued
+}
+
+// metricObjectReference is the Pod a metric value describes
+type metricObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// metricIdentifier names a metric
+type metricIdentifier struct {
+	Name string `json:"name"`
+}
+
+// metricValue is one item of a custom metrics API MetricValueList
+type metricValue struct {
+	DescribedObject metricObjectReference `json:"describedObject"`
+	Metric          metricIdentifier      `json:"metric"`
+	Timestamp       string                `json:"timestamp"`
+	Value           string                `json:"value"`
+}
+
+// metricValueList has the shape of the custom metrics API's MetricValueList, so adapters and
+// KEDA's metrics-api scaler can consume it without translation
+type metricValueList struct {
+	Kind       string        `json:"kind"`
+	APIVersion string        `json:"apiVersion"`
+	Metadata   struct{}      `json:"metadata"`
+	Items      []metricValue `json:"items"`
+}
+
+// writeAutoscalingMetrics writes the broadcast lag and queued messages for Prometheus
+func (s *Server) writeAutoscalingMetrics(w io.Writer) {
+	lag, queued := s.currentHub().deliveryLag(time.Now())
+	writeMetric(w, "bitwarden_reader_websocket_broadcast_lag_seconds", "gauge", "How long the slowest WebSocket client has had a message waiting to be written.", lag.Seconds())
+	writeMetric(w, "bitwarden_reader_websocket_queued_messages", "gauge", "Messages waiting to be written to WebSocket clients.", float64(queued))
+}
+
+// autoscalingHandler reports this pod's WebSocket load as a custom metrics API MetricValueList
+// The values are per pod; an autoscaler averages them over the Deployment's pods
+func (s *Server) autoscalingHandler(c *gin.Context) {
+	now := time.Now()
+	connections, _ := s.wsLimits.snapshot()
+	lag, queued := s.currentHub().deliveryLag(now)
+
+	pod := metricObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: s.config.PodNamespace, Name: s.config.PodName}
+	timestamp := now.UTC().Format(time.RFC3339)
+	item := func(name string, value *resource.Quantity) metricValue {
+		return metricValue{DescribedObject: pod, Metric: metricIdentifier{Name: name}, Timestamp: timestamp, Value: value.String()}
+	}
+	c.JSON(http.StatusOK, metricValueList{
+		Kind:       "MetricValueList",
+		APIVersion: customMetricsAPIVersion,
+		Items: []metricValue{
+			item(autoscalingConnections, resource.NewQuantity(int64(connections), resource.DecimalSI)),
+			item(autoscalingLag, resource.NewMilliQuantity(lag.Milliseconds(), resource.DecimalSI)),
+			item(autoscalingQueued, resource.NewQuantity(int64(queued), resource.DecimalSI)),
+		},
+	})
+}
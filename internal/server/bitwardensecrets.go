@@ -0,0 +1,351 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/features"
+	"bitwarden-reader/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// bitwardenSecretRequest represents the request body for creating or updating a BitwardenSecret
+type bitwardenSecretRequest struct {
+	Name      string                  `json:"name"`
+	Namespace string                  `json:"namespace,omitempty"`
+	Spec      k8s.BitwardenSecretSpec `json:"spec"`
+}
+
+// requireWriteEnabled rejects mutating CRD requests unless WRITE_ENABLED is set and the writeEndpoints flag is on
+func (s *Server) requireWriteEnabled(c *gin.Context) {
+	if !s.features.Enabled(features.WriteEndpoints) {
+		s.recordAudit(c, "bitwardensecret."+strings.ToLower(c.Request.Method), c.Param("name"), "", audit.OutcomeDenied, map[string]string{
+			"reason": "writeEndpoints feature flag off",
+		})
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Write endpoints are disabled by the writeEndpoints feature flag"),
+		})
+		return
+	}
+	if !s.config.WriteEnabled {
+		s.recordAudit(c, "bitwardensecret."+strings.ToLower(c.Request.Method), c.Param("name"), "", audit.OutcomeDenied, map[string]string{
+			"reason": "write endpoints disabled",
+		})
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Write endpoints are disabled - set WRITE_ENABLED=true to allow CRD changes"),
+		})
+		return
+	}
+	c.Next()
+}
+
+// recordAudit records an audit event for the current request
+func (s *Server) recordAudit(c *gin.Context, action, resource, namespace, outcome string, details map[string]string) {
+	actor := requestActor(c)
+	if requestID := c.GetString(requestIDKey); requestID != "" {
+		if details == nil {
+			details = make(map[string]string)
+		}
+		details["requestId"] = requestID
+	}
+	s.audit.Record(audit.Event{
+		Action:    action,
+		Actor:     actor,
+		Resource:  resource,
+		Namespace: namespace,
+		Outcome:   outcome,
+		Details:   details,
+	})
+}
+
+// requestActor returns the authenticated identity of the request, or its client IP
+func requestActor(c *gin.Context) string {
+	if identity := c.GetString(identityKey); identity != "" {
+		return identity
+	}
+	return c.ClientIP()
+}
+
+// resolveNamespace returns the requested namespace or the pod namespace, and whether it is allowed
+func (s *Server) resolveNamespace(requested string) (string, bool) {
+	namespace := strings.TrimSpace(requested)
+	if namespace == "" {
+		namespace = s.config.PodNamespace
+	}
+	return namespace, s.config.NamespaceAllowed(namespace)
+}
+
+// isDryRun reports whether the request asked for a server-side dry run
+func isDryRun(c *gin.Context) bool {
+	return c.Query("dryRun") == "true" || c.Query("dryRun") == "All"
+}
+
+// crdWriteStatus maps CRD write errors to HTTP status codes
+func crdWriteStatus(err error) int {
+	switch {
+	case errors.IsAlreadyExists(err):
+		return http.StatusConflict
+	case errors.IsConflict(err):
+		return http.StatusConflict
+	case errors.IsInvalid(err):
+		return http.StatusUnprocessableEntity
+	default:
+		return statusForK8sError(err)
+	}
+}
+
+// auditOutcome returns the audit outcome for a completed write
+func auditOutcome(dryRun bool) string {
+	if dryRun {
+		return audit.OutcomeDryRun
+	}
+	return audit.OutcomeSuccess
+}
+
+// createBitwardenSecretHandler validates a spec and creates the BitwardenSecret CRD
+func (s *Server) createBitwardenSecretHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	var req bitwardenSecretRequest
+	if !s.bindBody(c, bitwardenSecretSchema, &req) {
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	namespace, allowed := s.resolveNamespace(req.Namespace)
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Namespace '%s' is not in the allowed namespace list", namespace),
+		})
+		return
+	}
+
+	if fieldErrors := k8s.ValidateBitwardenSecret(req.Name, req.Spec); len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       "Invalid BitwardenSecret spec",
+			"fieldErrors": fieldErrors,
+		})
+		return
+	}
+
+	dryRun := isDryRun(c)
+	created, err := k8s.CreateBitwardenSecret(c.Request.Context(), req.Name, namespace, req.Spec, dryRun, s.clientsFor(c.Request.Context(), namespace).DynamicClient)
+	if err != nil {
+		s.recordAudit(c, "bitwardensecret.create", req.Name, namespace, audit.OutcomeFailure, map[string]string{"error": err.Error()})
+		c.JSON(crdWriteStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	s.recordAudit(c, "bitwardensecret.create", req.Name, namespace, auditOutcome(dryRun), map[string]string{"secretName": req.Spec.SecretName})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":         "BitwardenSecret created",
+		"name":            created.GetName(),
+		"namespace":       created.GetNamespace(),
+		"resourceVersion": created.GetResourceVersion(),
+		"dryRun":          dryRun,
+	})
+}
+
+// updateBitwardenSecretHandler replaces the spec of an existing BitwardenSecret CRD
+func (s *Server) updateBitwardenSecretHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	var req bitwardenSecretRequest
+	if !s.bindBody(c, bitwardenSecretSchema, &req) {
+		return
+	}
+
+	name := c.Param("name")
+	namespace, allowed := s.resolveNamespace(req.Namespace)
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Namespace '%s' is not in the allowed namespace list", namespace),
+		})
+		return
+	}
+
+	if fieldErrors := k8s.ValidateBitwardenSecret(name, req.Spec); len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       "Invalid BitwardenSecret spec",
+			"fieldErrors": fieldErrors,
+		})
+		return
+	}
+
+	dryRun := isDryRun(c)
+	updated, err := k8s.UpdateBitwardenSecret(c.Request.Context(), name, namespace, req.Spec, dryRun, s.clientsFor(c.Request.Context(), namespace).DynamicClient)
+	if err != nil {
+		s.recordAudit(c, "bitwardensecret.update", name, namespace, audit.OutcomeFailure, map[string]string{"error": err.Error()})
+		c.JSON(crdWriteStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	s.recordAudit(c, "bitwardensecret.update", name, namespace, auditOutcome(dryRun), map[string]string{"secretName": req.Spec.SecretName})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "BitwardenSecret updated",
+		"name":            updated.GetName(),
+		"namespace":       updated.GetNamespace(),
+		"resourceVersion": updated.GetResourceVersion(),
+		"dryRun":          dryRun,
+	})
+}
+
+// deleteBitwardenSecretHandler deletes a BitwardenSecret CRD after a confirmation round-trip
+// The first request returns a confirmation token; repeating it with ?confirm=<token> deletes the CRD
+func (s *Server) deleteBitwardenSecretHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	name := c.Param("name")
+	namespace, allowed := s.resolveNamespace(c.Query("namespace"))
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Namespace '%s' is not in the allowed namespace list", namespace),
+		})
+		return
+	}
+
+	dryRun := isDryRun(c)
+	subject := "delete:" + namespace + "/" + name
+	token := c.Query("confirm")
+	if !dryRun && token == "" {
+		issued, expiresAt, err := s.confirmations.issue(subject, requestActor(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("Failed to issue confirmation token: %v", err),
+			})
+			return
+		}
+		c.JSON(http.StatusPreconditionRequired, gin.H{
+			"message":           fmt.Sprintf("Deleting BitwardenSecret '%s' in namespace '%s' requires confirmation - repeat the request with ?confirm=<token>", name, namespace),
+			"confirmationToken": issued,
+			"expiresAt":         expiresAt.Format(time.RFC3339),
+		})
+		return
+	}
+	if !dryRun && !s.confirmations.consume(token, subject, requestActor(c)) {
+		s.recordAudit(c, "bitwardensecret.delete", name, namespace, audit.OutcomeDenied, map[string]string{"reason": "invalid confirmation token"})
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": s.tr(c, "Confirmation token is invalid, expired, or issued for a different resource or identity"),
+		})
+		return
+	}
+
+	if err := k8s.DeleteBitwardenSecret(c.Request.Context(), name, namespace, dryRun, s.requestClients(c).DynamicClient); err != nil {
+		s.recordAudit(c, "bitwardensecret.delete", name, namespace, audit.OutcomeFailure, map[string]string{"error": err.Error()})
+		c.JSON(crdWriteStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	s.recordAudit(c, "bitwardensecret.delete", name, namespace, auditOutcome(dryRun), nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "BitwardenSecret deleted",
+		"name":      name,
+		"namespace": namespace,
+		"dryRun":    dryRun,
+	})
+}
+
+// validateBitwardenSecretHandler runs local and server-side dry-run validation of a BitwardenSecret manifest
+func (s *Server) validateBitwardenSecretHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to read request body: %v", err),
+		})
+		return
+	}
+
+	obj, spec, err := k8s.ParseBitwardenSecretManifest(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"valid": false,
+			"stage": "parse",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	requested := obj.GetNamespace()
+	if requested == "" {
+		requested = c.Query("namespace")
+	}
+	namespace, allowed := s.resolveNamespace(requested)
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Namespace '%s' is not in the allowed namespace list", namespace),
+		})
+		return
+	}
+	obj.SetNamespace(namespace)
+
+	if fieldErrors := k8s.ValidateBitwardenSecret(obj.GetName(), spec); len(fieldErrors) > 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"valid":       false,
+			"stage":       "local",
+			"name":        obj.GetName(),
+			"namespace":   namespace,
+			"fieldErrors": fieldErrors,
+		})
+		return
+	}
+
+	operation, err := k8s.DryRunBitwardenSecret(c.Request.Context(), obj, s.clientsFor(c.Request.Context(), namespace).DynamicClient)
+	if err != nil {
+		if !errors.IsInvalid(err) && !errors.IsBadRequest(err) {
+			c.JSON(statusForK8sError(err), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"valid":       false,
+			"stage":       "server",
+			"operation":   operation,
+			"name":        obj.GetName(),
+			"namespace":   namespace,
+			"error":       err.Error(),
+			"fieldErrors": k8s.StatusCauses(err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":     true,
+		"operation": operation,
+		"name":      obj.GetName(),
+		"namespace": namespace,
+	})
+}
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodySchema is the subset of JSON Schema used to validate request bodies, served as is at /api/v1/schemas
+type bodySchema struct {
+	Type                 string                 `json:"type"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*bodySchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *bodySchema            `json:"items,omitempty"`
+	MaxLength            int                    `json:"maxLength,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Maximum              *int                   `json:"maximum,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// noAdditionalProperties forbids properties an object schema does not list
+var noAdditionalProperties = new(bool)
+
+// dnsSubdomainPattern matches Kubernetes object names
+const dnsSubdomainPattern = `^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+
+// stringSchema is a string of at most maxLength characters, matching pattern when set
+func stringSchema(description string, maxLength int, pattern string) *bodySchema {
+	schema := &bodySchema{Type: "string", Description: description, MaxLength: maxLength, Pattern: pattern}
+	if pattern != "" {
+		schema.pattern = regexp.MustCompile(pattern)
+	}
+	return schema
+}
+
+// integerSchema is an integer from minimum to maximum
+func integerSchema(description string, minimum, maximum int) *bodySchema {
+	return &bodySchema{Type: "integer", Description: description, Minimum: &minimum, Maximum: &maximum}
+}
+
+// Request body schemas by name
+var (
+	triggerSyncSchema = &bodySchema{
+		Type:                 "object",
+		AdditionalProperties: noAdditionalProperties,
+		Properties: map[string]*bodySchema{
+			"secretNames": {
+				Type:        "array",
+				Description: "BitwardenSecrets to trigger; SECRET_NAMES when empty",
+				Items:       stringSchema("", 253, dnsSubdomainPattern),
+			},
+			"ticket":            stringSchema("Change or incident ID recorded with the trigger", maxTicketLength, ""),
+			"confirmationToken": stringSchema("Token from GET /api/v1/trigger-sync/plan", 128, ""),
+		},
+	}
+
+	bitwardenSecretSchema = &bodySchema{
+		Type:                 "object",
+		AdditionalProperties: noAdditionalProperties,
+		Required:             []string{"spec"},
+		Properties: map[string]*bodySchema{
+			"name":      stringSchema("BitwardenSecret name, taken from the path on updates", 253, ""),
+			"namespace": stringSchema("Namespace; the pod namespace when empty", 63, ""),
+			"spec": {
+				Type:                 "object",
+				AdditionalProperties: noAdditionalProperties,
+				Required:             []string{"organizationId", "secretName", "map", "authToken"},
+				Properties: map[string]*bodySchema{
+					"organizationId": stringSchema("Bitwarden organization UUID", 36, ""),
+					"secretName":     stringSchema("Kubernetes Secret the operator writes", 253, ""),
+					"map": {
+						Type: "array",
+						Items: &bodySchema{
+							Type:                 "object",
+							AdditionalProperties: noAdditionalProperties,
+							Required:             []string{"bwSecretId", "secretKeyName"},
+							Properties: map[string]*bodySchema{
+								"bwSecretId":    stringSchema("Bitwarden secret UUID", 36, ""),
+								"secretKeyName": stringSchema("Key in the Kubernetes Secret", 253, ""),
+							},
+						},
+					},
+					"authToken": {
+						Type:                 "object",
+						AdditionalProperties: noAdditionalProperties,
+						Required:             []string{"secretName", "secretKey"},
+						Properties: map[string]*bodySchema{
+							"secretName": stringSchema("Secret holding the machine account token", 253, ""),
+							"secretKey":  stringSchema("Key of the token in that Secret", 253, ""),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	chaosFaultSchema = &bodySchema{
+		Type:                 "object",
+		AdditionalProperties: noAdditionalProperties,
+		Required:             []string{"kind"},
+		Properties: map[string]*bodySchema{
+			"kind": {
+				Type:        "string",
+				Description: "Failure to simulate",
+				Enum:        []string{reader.FaultSecretMissing, reader.FaultSyncFailing, reader.FaultLatency},
+			},
+			"secret":          stringSchema("Secret the failure applies to; every secret when empty", 253, ""),
+			"source":          stringSchema("Source the failure applies to, e.g. kubernetes; every source when empty", 63, ""),
+			"reason":          stringSchema("Sync condition reason of a sync-failing fault", 128, ""),
+			"message":         stringSchema("Sync condition message of a sync-failing fault", 1024, ""),
+			"latencyMs":       integerSchema("Delay added to every read by a latency fault", 0, maxChaosLatencyMs),
+			"durationSeconds": integerSchema("Seconds until the fault expires", 1, maxChaosDurationSeconds),
+		},
+	}
+
+	requestSchemas = map[string]*bodySchema{
+		"trigger-sync":    triggerSyncSchema,
+		"bitwardensecret": bitwardenSecretSchema,
+		"chaos-fault":     chaosFaultSchema,
+	}
+)
+
+// validate returns the fields of value that don't match the schema, at path
+func (schema *bodySchema) validate(value interface{}, path string) []k8s.FieldError {
+	field := func(message string, args ...interface{}) []k8s.FieldError {
+		name := path
+		if name == "" {
+			name = "(body)"
+		}
+		return []k8s.FieldError{{Field: name, Message: fmt.Sprintf(message, args...)}}
+	}
+	child := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return field("must be an object")
+		}
+		var errs []k8s.FieldError
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				errs = append(errs, k8s.FieldError{Field: child(name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					errs = append(errs, k8s.FieldError{Field: child(name), Message: "unknown field"})
+				}
+				continue
+			}
+			errs = append(errs, property.validate(object[name], child(name))...)
+		}
+		return errs
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return field("must be an array")
+		}
+		var errs []k8s.FieldError
+		for i, item := range items {
+			errs = append(errs, schema.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return field("must be a string")
+		}
+		if schema.MaxLength > 0 && len(s) > schema.MaxLength {
+			return field("must be at most %d characters", schema.MaxLength)
+		}
+		if schema.pattern != nil && !schema.pattern.MatchString(s) {
+			return field("must match %s", schema.Pattern)
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, s) {
+			return field("must be one of %s", strings.Join(schema.Enum, ", "))
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return field("must be an integer")
+		}
+		if (schema.Minimum != nil && n < float64(*schema.Minimum)) || (schema.Maximum != nil && n > float64(*schema.Maximum)) {
+			return field("must be from %d to %d", *schema.Minimum, *schema.Maximum)
+		}
+	}
+	return nil
+}
+
+// limitRequestBody is middleware that rejects request bodies over MAX_REQUEST_BODY_BYTES with 413
+// Bodies without a declared length are cut off at the limit while being read
+func (s *Server) limitRequestBody(c *gin.Context) {
+	limit := s.config.MaxRequestBodyBytes
+	if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		c.Next()
+		return
+	}
+	if c.Request.ContentLength > limit {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": s.tr(c, "Request body exceeds the limit of %d bytes", limit),
+		})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	c.Next()
+}
+
+// bindBody decodes the JSON request body into v after validating it against schema, and reports
+// whether it did; otherwise it has responded with 400 and the field errors, or 413 for a body over
+// the limit
+// An empty body is validated as an empty object and leaves v unchanged, so fields keep their defaults
+func (s *Server) bindBody(c *gin.Context, schema *bodySchema, v interface{}) bool {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if stderrors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": s.tr(c, "Request body exceeds the limit of %d bytes", tooLarge.Limit),
+			})
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to read request body: %v", err),
+		})
+		return false
+	}
+	empty := len(bytes.TrimSpace(body)) == 0
+
+	var value interface{} = map[string]interface{}{}
+	if err := json.Unmarshal(body, &value); err != nil && !empty {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       s.tr(c, "Request body is not valid JSON"),
+			"fieldErrors": []k8s.FieldError{{Field: "(body)", Message: jsonErrorMessage(err)}},
+		})
+		return false
+	}
+	if fieldErrors := schema.validate(value, ""); len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       s.tr(c, "Request body does not match the schema"),
+			"fieldErrors": fieldErrors,
+		})
+		return false
+	}
+	if empty {
+		return true
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return false
+	}
+	return true
+}
+
+// jsonErrorMessage describes a JSON syntax error with its offset
+func jsonErrorMessage(err error) string {
+	var syntax *json.SyntaxError
+	if stderrors.As(err, &syntax) {
+		return fmt.Sprintf("%s at offset %d", strings.TrimPrefix(syntax.Error(), "json: "), syntax.Offset)
+	}
+	return err.Error()
+}
+
+// requestSchemasHandler serves the request body schemas as JSON Schema
+func (s *Server) requestSchemasHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"schemas":             requestSchemas,
+		"maxRequestBodyBytes": s.config.MaxRequestBodyBytes,
+	})
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/reader"
+)
+
+// WebSocket message types
+const (
+	messageTypeSecrets   = "secrets"
+	messageTypeHeartbeat = "heartbeat"
+	messageTypeAlert     = "alert"
+	// messageTypeTriggerResult carries a trigger-sync record once its syncs are verified
+	messageTypeTriggerResult = "trigger-result"
+	// messageTypeSession opens every connection with its resume token
+	messageTypeSession = "session"
+	// messageTypeAck is sent by clients to acknowledge every event up to a sequence number
+	messageTypeAck = "ack"
+)
+
+// broadcastState remembers the last published snapshot so unchanged ones are skipped
+type broadcastState struct {
+	mu          sync.Mutex
+	hash        string
+	publishedAt time.Time
+	// secretHashes hash each secret of the last published snapshot by key, for deltas
+	secretHashes map[string]string
+}
+
+// snapshotDelta is what changed in a snapshot since the last published one, for clients that take deltas
+type snapshotDelta struct {
+	changed []reader.SecretInfo
+	removed []removedSecret
+}
+
+// removedSecret names a secret no longer in the snapshot, with the field names of reader.SecretInfo
+type removedSecret struct {
+	Name   string
+	Source string
+}
+
+// secretHashes returns the hash of each secret by key
+func secretHashes(secrets []reader.SecretInfo) map[string]string {
+	hashes := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		hashes[secret.Key()] = hashSecrets([]reader.SecretInfo{secret})
+	}
+	return hashes
+}
+
+// delta returns the secrets whose hash differs from the last published snapshot and those no longer in it,
+// or nil before the first snapshot
+func (b *broadcastState) delta(secrets []reader.SecretInfo, hashes map[string]string) *snapshotDelta {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.secretHashes == nil {
+		return nil
+	}
+	delta := &snapshotDelta{changed: []reader.SecretInfo{}, removed: []removedSecret{}}
+	current := make(map[string]bool, len(secrets))
+	for _, secret := range secrets {
+		current[secret.Key()] = true
+		if b.secretHashes[secret.Key()] != hashes[secret.Key()] {
+			delta.changed = append(delta.changed, secret)
+		}
+	}
+	for key := range b.secretHashes {
+		if !current[key] {
+			source, name, _ := strings.Cut(key, "/")
+			delta.removed = append(delta.removed, removedSecret{Name: name, Source: source})
+		}
+	}
+	sort.Slice(delta.removed, func(i, j int) bool { return delta.removed[i].Name < delta.removed[j].Name })
+	return delta
+}
+
+// unchanged reports whether the snapshot hash matches the last published one
+func (b *broadcastState) unchanged(hash string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return hash == b.hash
+}
+
+// record marks the snapshot hash as published, with the hashes of its secrets when deltas are sent
+func (b *broadcastState) record(hash string, secretHashes map[string]string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hash = hash
+	b.publishedAt = time.Now()
+	b.secretHashes = secretHashes
+}
+
+// idleSince reports whether nothing was published after t
+func (b *broadcastState) idleSince(t time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.publishedAt.After(t)
+}
+
+// heartbeatPayload is the lightweight message sent when no snapshot was published recently
+func heartbeatPayload() *broadcastPayload {
+	return &broadcastPayload{
+		heartbeat: true,
+		fields: map[string]interface{}{
+			"type":      messageTypeHeartbeat,
+			"timestamp": time.Now().Format(time.RFC3339),
+		},
+	}
+}
+
+// broadcastLoop refreshes the snapshot for WebSocket clients and sends heartbeats until ctx is cancelled
+// Snapshots are only published when they change; heartbeats keep idle connections observably alive
+// With a refresh schedule only the secrets whose interval elapsed are re-read on each tick
+func (s *Server) broadcastLoop(ctx context.Context) {
+	refresh := s.config.DashboardRefreshInterval
+	if refresh <= 0 {
+		refresh = 5 * time.Second
+	}
+	if s.schedule != nil {
+		refresh = s.schedule.tick()
+	}
+	heartbeat := s.config.WSHeartbeatInterval
+	if heartbeat <= 0 {
+		heartbeat = 30 * time.Second
+	}
+
+	refreshTicker := time.NewTicker(refresh)
+	defer refreshTicker.Stop()
+	heartbeatTicker := time.NewTicker(heartbeat)
+	defer heartbeatTicker.Stop()
+
+	lastHeartbeat := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refreshTicker.C:
+			// Nobody to send to; new clients get the last snapshot on connect
+			if total, _ := s.wsLimits.snapshot(); total == 0 {
+				continue
+			}
+			if s.schedule != nil {
+				s.refreshScheduled(ctx)
+			} else {
+				s.broadcastSecrets()
+			}
+		case <-s.secretEvents.broadcast:
+			if total, _ := s.wsLimits.snapshot(); total == 0 {
+				continue
+			}
+			s.broadcastSecrets()
+		case now := <-heartbeatTicker.C:
+			if s.broadcasts.idleSince(lastHeartbeat) {
+				s.currentHub().publish(heartbeatPayload())
+			}
+			lastHeartbeat = now
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// capabilitiesTTL is how long access review results are reused for the same clients
+const capabilitiesTTL = time.Minute
+
+// capabilities are the UI actions the request's user may perform, so the UI can leave out
+// controls that would only fail on click
+type capabilities struct {
+	CanRevealValues bool `json:"canRevealValues"`
+	CanTriggerSync  bool `json:"canTriggerSync"`
+	CanEditCRDs     bool `json:"canEditCRDs"`
+}
+
+// capabilityCache keeps computed capabilities per set of clients; with impersonation there is
+// one set of clients per Kubernetes user, otherwise everyone shares the service account's
+type capabilityCache struct {
+	mu      sync.Mutex
+	entries map[*k8s.K8sClients]capabilityEntry
+}
+
+type capabilityEntry struct {
+	caps    capabilities
+	expires time.Time
+}
+
+// get returns unexpired capabilities for the clients
+func (cc *capabilityCache) get(clients *k8s.K8sClients, now time.Time) (capabilities, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	entry, ok := cc.entries[clients]
+	if !ok || now.After(entry.expires) {
+		return capabilities{}, false
+	}
+	return entry.caps, true
+}
+
+// put stores capabilities for the clients until capabilitiesTTL passes
+func (cc *capabilityCache) put(clients *k8s.K8sClients, caps capabilities, now time.Time) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.entries == nil {
+		cc.entries = make(map[*k8s.K8sClients]capabilityEntry)
+	}
+	for key, entry := range cc.entries {
+		if now.After(entry.expires) {
+			delete(cc.entries, key)
+		}
+	}
+	cc.entries[clients] = capabilityEntry{caps: caps, expires: now.Add(capabilitiesTTL)}
+}
+
+// requestCapabilities works out what the request's user may do from Kubernetes RBAC and the config
+// Without Kubernetes only local secrets are shown, whose values are always readable
+func (s *Server) requestCapabilities(c *gin.Context) capabilities {
+	clients := s.clientsFor(c.Request.Context(), s.config.PodNamespace)
+	if clients == nil {
+		return capabilities{CanRevealValues: true}
+	}
+
+	now := time.Now()
+	if caps, ok := s.capabilities.get(clients, now); ok {
+		return caps
+	}
+
+	ctx := c.Request.Context()
+	crdGroup := k8s.BitwardenSecretGVR.Group
+	crdResource := k8s.BitwardenSecretGVR.Resource
+	caps := capabilities{
+		CanRevealValues: s.canI(ctx, clients, s.config.PodNamespace, "", "secrets", "get"),
+	}
+	// Triggering a sync patches the BitwardenSecret's annotations, which READ_ONLY rules out
+	if !s.config.ReadOnly {
+		caps.CanTriggerSync = s.canI(ctx, clients, s.config.PodNamespace, crdGroup, crdResource, "patch")
+	}
+	if s.writeEnabled() {
+		caps.CanEditCRDs = s.canI(ctx, clients, s.config.PodNamespace, crdGroup, crdResource, "update")
+	}
+	s.capabilities.put(clients, caps, now)
+	return caps
+}
+
+// canI runs an access review in the namespace
+// Only a forbidden review denies: on other errors the control is shown and RBAC still decides on use
+func (s *Server) canI(ctx context.Context, clients *k8s.K8sClients, namespace, group, resource, verb string) bool {
+	allowed, err := k8s.CanI(ctx, clients.Clientset, namespace, group, resource, verb)
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			return false
+		}
+		logging.Printf("Error reviewing access to %s %s: %v", verb, resource, err)
+		return true
+	}
+	return allowed
+}
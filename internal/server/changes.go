@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of change between consecutive reads of a secret
+const (
+	changeAppeared    = "appeared"
+	changeDisappeared = "disappeared"
+	changeKeys        = "keys-changed"
+	changeValues      = "values-changed"
+)
+
+// maxSecretChanges bounds the changes kept in memory for /api/v1/changes
+const maxSecretChanges = 1000
+
+// secretChange is how a secret changed since the previous read; values and their hashes are never included
+type secretChange struct {
+	Secret      string    `json:"secret"`
+	Source      string    `json:"source"`
+	Change      string    `json:"change"`
+	AddedKeys   []string  `json:"addedKeys,omitempty"`
+	RemovedKeys []string  `json:"removedKeys,omitempty"`
+	ChangedKeys []string  `json:"changedKeys,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// observedSecret is a secret in the previous snapshot: whether it was found and the hash of each key's value
+type observedSecret struct {
+	found  bool
+	hashes map[string]string
+}
+
+// changeLog keeps the previous snapshot of every secret and the changes seen between reads
+type changeLog struct {
+	mu         sync.Mutex
+	previous   map[string]observedSecret
+	changes    []secretChange
+	observedAt time.Time
+	// droppedAt is the time of the newest change dropped to stay within maxSecretChanges
+	droppedAt time.Time
+}
+
+// readFailed reports whether a secret could not be read, as opposed to not existing
+// A failed read keeps the previous snapshot, so API errors don't look like deletions;
+// the secrets of a terminating or absent namespace are gone
+func readFailed(secret reader.SecretInfo) bool {
+	return !secret.Found && secret.Error != "" && secret.NamespaceStatus == nil && !strings.Contains(secret.Error, "not found")
+}
+
+// observe compares the secrets with the previous snapshot and records how they changed
+// The first read of a secret only sets its baseline
+func (l *changeLog) observe(secrets []reader.SecretInfo, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.previous == nil {
+		l.previous = make(map[string]observedSecret)
+	}
+	l.observedAt = now
+
+	for _, secret := range secrets {
+		if readFailed(secret) {
+			continue
+		}
+		current := observedSecret{found: secret.Found}
+		if secret.Found {
+			// ValueHashes covers only non-empty values, so an empty value hashes to ""
+			current.hashes = make(map[string]string, len(secret.Keys))
+			for key := range secret.Keys {
+				current.hashes[key] = secret.ValueHashes[key]
+			}
+		}
+
+		previous, seen := l.previous[secret.Key()]
+		l.previous[secret.Key()] = current
+		if !seen {
+			continue
+		}
+		change, ok := diffSecret(previous, current)
+		if !ok {
+			continue
+		}
+		change.Secret = secret.Name
+		change.Source = secret.Source
+		change.Time = now
+		l.changes = append(l.changes, change)
+	}
+
+	if excess := len(l.changes) - maxSecretChanges; excess > 0 {
+		l.droppedAt = l.changes[excess-1].Time
+		l.changes = append([]secretChange(nil), l.changes[excess:]...)
+	}
+}
+
+// diffSecret describes the change between two snapshots of a secret, reporting false when there is none
+func diffSecret(previous, current observedSecret) (secretChange, bool) {
+	switch {
+	case !previous.found && !current.found:
+		return secretChange{}, false
+	case !previous.found:
+		return secretChange{Change: changeAppeared, AddedKeys: sortedKeys(current.hashes)}, true
+	case !current.found:
+		return secretChange{Change: changeDisappeared, RemovedKeys: sortedKeys(previous.hashes)}, true
+	}
+
+	var change secretChange
+	for key, hash := range current.hashes {
+		previousHash, ok := previous.hashes[key]
+		switch {
+		case !ok:
+			change.AddedKeys = append(change.AddedKeys, key)
+		case previousHash != hash:
+			change.ChangedKeys = append(change.ChangedKeys, key)
+		}
+	}
+	for key := range previous.hashes {
+		if _, ok := current.hashes[key]; !ok {
+			change.RemovedKeys = append(change.RemovedKeys, key)
+		}
+	}
+	sort.Strings(change.AddedKeys)
+	sort.Strings(change.RemovedKeys)
+	sort.Strings(change.ChangedKeys)
+
+	switch {
+	case len(change.AddedKeys) > 0 || len(change.RemovedKeys) > 0:
+		change.Change = changeKeys
+	case len(change.ChangedKeys) > 0:
+		change.Change = changeValues
+	default:
+		return secretChange{}, false
+	}
+	return change, true
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(hashes map[string]string) []string {
+	keys := make([]string, 0, len(hashes))
+	for key := range hashes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// since returns the changes after t, oldest first, and whether changes after t were dropped
+func (l *changeLog) since(t time.Time) ([]secretChange, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changes := make([]secretChange, 0)
+	for _, change := range l.changes {
+		if change.Time.After(t) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, l.droppedAt.After(t)
+}
+
+// stale reports whether nothing was read since maxAge ago
+func (l *changeLog) stale(now time.Time, maxAge time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return now.Sub(l.observedAt) >= maxAge
+}
+
+// observeChanges feeds the change log with secrets read using the reader's own permissions;
+// impersonated reads may see less than the service account and would report spurious changes
+func (s *Server) observeChanges(ctx context.Context, secrets []reader.SecretInfo) {
+	if _, impersonated := ctx.Value(clientsContextKey{}).(*k8s.K8sClients); impersonated {
+		return
+	}
+	s.changeLog.observe(secrets, time.Now())
+}
+
+// changesHandler returns how the secrets changed since ?since= (RFC3339 or unix seconds), oldest first
+// Secrets are re-read when nothing else read them within the dashboard refresh interval
+func (s *Server) changesHandler(c *gin.Context) {
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		if since = parseSince(value); since.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": s.tr(c, "Invalid since value - use an RFC3339 timestamp or unix seconds"),
+			})
+			return
+		}
+	}
+
+	interval := s.config.DashboardRefreshInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if s.changeLog.stale(time.Now(), interval) {
+		// Read as the service account, like the background loops, so the log stays consistent
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		secrets, err := s.readSecrets(ctx)
+		cancel()
+		if err != nil {
+			logging.Printf("Error reading secrets for changes: %v", err)
+		}
+		wipeSecretValues(secrets)
+	}
+
+	changes, truncated := s.changeLog.since(since)
+	response := gin.H{
+		"changes":   changes,
+		"count":     len(changes),
+		"truncated": truncated,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	if !since.IsZero() {
+		response["since"] = since.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of an injected fault
+const (
+	defaultChaosDurationSeconds = 900
+	maxChaosDurationSeconds     = 86400
+	maxChaosLatencyMs           = 60000
+)
+
+// chaosFaultRequest is the body of POST /api/v1/admin/chaos
+type chaosFaultRequest struct {
+	Kind            string `json:"kind"`
+	Secret          string `json:"secret"`
+	Source          string `json:"source"`
+	Reason          string `json:"reason"`
+	Message         string `json:"message"`
+	LatencyMs       int    `json:"latencyMs"`
+	DurationSeconds int    `json:"durationSeconds"`
+}
+
+// adminChaosHandler lists the injected faults that have not expired
+func (s *Server) adminChaosHandler(c *gin.Context) {
+	faults := s.chaos.Faults(time.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"faults":    faults,
+		"total":     len(faults),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// adminInjectChaosHandler injects a simulated failure into reads until it expires or is removed
+func (s *Server) adminInjectChaosHandler(c *gin.Context) {
+	req := chaosFaultRequest{DurationSeconds: defaultChaosDurationSeconds}
+	if !s.bindBody(c, chaosFaultSchema, &req) {
+		return
+	}
+	if req.Kind == reader.FaultLatency && req.LatencyMs == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": s.tr(c, "A latency fault needs latencyMs greater than 0"),
+		})
+		return
+	}
+	if req.Kind == reader.FaultSyncFailing {
+		if req.Reason == "" {
+			req.Reason = "SimulatedFailure"
+		}
+		if req.Message == "" {
+			req.Message = "Simulated sync failure"
+		}
+	}
+
+	now := time.Now()
+	fault := s.chaos.Inject(reader.Fault{
+		Kind:      req.Kind,
+		Secret:    req.Secret,
+		Source:    req.Source,
+		Reason:    req.Reason,
+		Message:   req.Message,
+		LatencyMs: req.LatencyMs,
+		CreatedAt: now,
+		CreatedBy: requestActor(c),
+		ExpiresAt: now.Add(time.Duration(req.DurationSeconds) * time.Second),
+	})
+	s.recordAudit(c, "chaos.inject", fault.ID, s.config.PodNamespace, audit.OutcomeSuccess, map[string]string{
+		"kind":            fault.Kind,
+		"secret":          fault.Secret,
+		"source":          fault.Source,
+		"durationSeconds": strconv.Itoa(req.DurationSeconds),
+	})
+	s.secretEvents.notify()
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Fault injected",
+		"fault":   fault,
+	})
+}
+
+// adminRemoveChaosHandler removes an injected fault by its ID
+func (s *Server) adminRemoveChaosHandler(c *gin.Context) {
+	id := c.Param("id")
+	if !s.chaos.Remove(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": s.tr(c, "Fault not found")})
+		return
+	}
+	s.recordAudit(c, "chaos.remove", id, s.config.PodNamespace, audit.OutcomeSuccess, nil)
+	s.secretEvents.notify()
+	c.JSON(http.StatusOK, gin.H{"message": "Fault removed", "id": id})
+}
+
+// adminClearChaosHandler removes every injected fault
+func (s *Server) adminClearChaosHandler(c *gin.Context) {
+	cleared := s.chaos.Clear()
+	s.recordAudit(c, "chaos.clear", "faults", s.config.PodNamespace, audit.OutcomeSuccess, map[string]string{
+		"cleared": strconv.Itoa(cleared),
+	})
+	s.secretEvents.notify()
+	c.JSON(http.StatusOK, gin.H{"message": "Faults cleared", "cleared": cleared})
+}
+
+// writeChaosMetrics writes the injected faults by kind when CHAOS_ENABLED is set, so dashboards can mark
+// simulated failures
+func (s *Server) writeChaosMetrics(w io.Writer) {
+	if s.chaos == nil {
+		return
+	}
+	perKind := map[string]float64{reader.FaultSecretMissing: 0, reader.FaultSyncFailing: 0, reader.FaultLatency: 0}
+	for _, fault := range s.chaos.Faults(time.Now()) {
+		perKind[fault.Kind]++
+	}
+	writeLabelledMetric(w, "bitwarden_reader_chaos_faults", "gauge", "Injected simulated failures by kind.", "kind", perKind)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Key and overall comparison statuses
+const (
+	compareIdentical    = "identical"
+	compareDifferent    = "different"
+	compareMissingLeft  = "missing-left"
+	compareMissingRight = "missing-right"
+	compareMissing      = "missing"
+)
+
+// secretRef identifies a secret as namespace/name
+type secretRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Found     bool   `json:"found"`
+	KeyCount  int    `json:"keyCount"`
+}
+
+// keyComparison is the result for one key
+type keyComparison struct {
+	Key    string `json:"key"`
+	Status string `json:"status"`
+}
+
+// parseSecretRef parses "namespace/name", or "name" in the pod namespace
+func (s *Server) parseSecretRef(value string) (secretRef, error) {
+	parts := strings.Split(strings.TrimSpace(value), "/")
+	var ref secretRef
+	switch len(parts) {
+	case 1:
+		ref = secretRef{Namespace: s.config.PodNamespace, Name: parts[0]}
+	case 2:
+		ref = secretRef{Namespace: parts[0], Name: parts[1]}
+	default:
+		return ref, fmt.Errorf("invalid secret reference %q, expected namespace/name", value)
+	}
+	if ref.Name == "" || ref.Namespace == "" {
+		return ref, fmt.Errorf("invalid secret reference %q, expected namespace/name", value)
+	}
+	return ref, nil
+}
+
+// compareSecretData compares the keys of two secrets by value hash
+func compareSecretData(left, right map[string][]byte) []keyComparison {
+	keys := make(map[string]bool, len(left)+len(right))
+	for key := range left {
+		keys[key] = true
+	}
+	for key := range right {
+		keys[key] = true
+	}
+
+	results := make([]keyComparison, 0, len(keys))
+	for key := range keys {
+		leftValue, inLeft := left[key]
+		rightValue, inRight := right[key]
+		status := compareIdentical
+		switch {
+		case !inLeft:
+			status = compareMissingLeft
+		case !inRight:
+			status = compareMissingRight
+		case reader.HashValue(string(leftValue)) != reader.HashValue(string(rightValue)):
+			status = compareDifferent
+		}
+		results = append(results, keyComparison{Key: key, Status: status})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+	return results
+}
+
+// compareHandler compares the key sets and value hashes of two secrets without returning values
+func (s *Server) compareHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	left, err := s.parseSecretRef(c.Query("left"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	right, err := s.parseSecretRef(c.Query("right"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, ref := range []secretRef{left, right} {
+		if !s.config.NamespaceAllowed(ref.Namespace) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": s.tr(c, "Namespace '%s' is not in the allowed namespace list", ref.Namespace),
+			})
+			return
+		}
+		if !s.identityAllows(c, ref.Namespace) {
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	secrets := make([]*corev1.Secret, 2)
+	for i, ref := range []*secretRef{&left, &right} {
+		secret, err := k8s.ReadSecret(ctx, ref.Name, ref.Namespace, s.clientsFor(ctx, ref.Namespace).Clientset)
+		if err != nil && !k8s.IsSecretNotFound(err) {
+			c.JSON(statusForK8sError(err), gin.H{
+				"error": fmt.Sprintf("Error reading secret %s/%s: %v", ref.Namespace, ref.Name, err),
+			})
+			return
+		}
+		if err == nil {
+			ref.Found = true
+			ref.KeyCount = len(secret.Data)
+			secrets[i] = secret
+		}
+	}
+
+	status := compareMissing
+	var keys []keyComparison
+	if left.Found && right.Found {
+		keys = compareSecretData(secrets[0].Data, secrets[1].Data)
+		status = compareIdentical
+		for _, key := range keys {
+			if key.Status != compareIdentical {
+				status = compareDifferent
+				break
+			}
+		}
+	}
+	for _, secret := range secrets {
+		if secret != nil {
+			k8s.WipeSecretData(secret.Data)
+		}
+	}
+
+	s.recordAudit(c, "secrets.compare", left.Namespace+"/"+left.Name, left.Namespace, audit.OutcomeSuccess, map[string]string{
+		"right":  right.Namespace + "/" + right.Name,
+		"status": status,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"left":   left,
+		"right":  right,
+		"status": status,
+		"keys":   keys,
+	})
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// confirmationTTL is how long an issued confirmation token stays valid
+const confirmationTTL = 2 * time.Minute
+
+// confirmation is a pending confirmation for a destructive operation, by the identity that requested it
+type confirmation struct {
+	subject   string
+	identity  string
+	expiresAt time.Time
+}
+
+// confirmationStore issues single-use tokens that must be echoed back to confirm an operation
+type confirmationStore struct {
+	mu      sync.Mutex
+	pending map[string]confirmation
+}
+
+// newConfirmationStore creates an empty confirmation store
+func newConfirmationStore() *confirmationStore {
+	return &confirmationStore{
+		pending: make(map[string]confirmation),
+	}
+}
+
+// issue creates a token bound to the subject and the requesting identity and returns it with its expiry
+func (s *confirmationStore) issue(subject, identity string) (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(confirmationTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.pending[token] = confirmation{subject: subject, identity: identity, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// consume validates a token for the subject and identity and removes it so it cannot be reused
+// A token presented by another identity is rejected and stays valid for the one it was issued to
+func (s *confirmationStore) consume(token, subject, identity string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	pending, ok := s.pending[token]
+	if !ok || pending.subject != subject || pending.identity != identity {
+		return false
+	}
+	delete(s.pending, token)
+	return true
+}
+
+// prune removes expired tokens; the caller must hold the lock
+func (s *confirmationStore) prune() {
+	now := time.Now()
+	for token, pending := range s.pending {
+		if now.After(pending.expiresAt) {
+			delete(s.pending, token)
+		}
+	}
+}
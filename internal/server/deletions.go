@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/history"
+	"bitwarden-reader/internal/hooks"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deletionLookupTimeout bounds the search for who deleted a secret
+const deletionLookupTimeout = 10 * time.Second
+
+// recordDeletion stores a watched secret's deletion in the history and sends a secret-deleted event
+// to hooks and notifications, naming the deleting actor when Events or ownership reveal it
+func (s *Server) recordDeletion(event k8s.SecretWatchEvent) {
+	now := time.Now().UTC()
+	namespace := s.config.PodNamespace
+	ctx, cancel := context.WithTimeout(context.Background(), deletionLookupTimeout)
+	clients := s.k8sClients.ForNamespace(namespace)
+	actor, detail := k8s.DeletionActor(ctx, clients.Clientset, clients.DynamicClient, namespace, event.Name, event.Owners)
+	cancel()
+
+	deletion := history.SecretDeletion{
+		Secret:    event.Name,
+		Namespace: namespace,
+		Time:      now,
+		UID:       event.UID,
+		Actor:     actor,
+		Detail:    detail,
+	}
+	if err := s.history.SaveDeletion(deletion); err != nil {
+		logging.Printf("Error saving secret deletion: %v", err)
+	}
+
+	message := "deleted"
+	if actor != "" {
+		message += " by " + actor
+	}
+	if detail != "" {
+		message += ": " + detail
+	}
+	logging.Printf("Secret %s/%s %s", namespace, event.Name, message)
+	notice := hooks.Event{
+		Type:      hooks.EventSecretDeleted,
+		Secret:    event.Name,
+		Namespace: namespace,
+		Group:     s.config.SecretGroups[event.Name],
+		Detail:    message,
+		Time:      now,
+	}
+	s.hooks.Notify(notice)
+	s.notifier.Notify(notice)
+}
+
+// markDeletions sets Deleted on the missing Kubernetes secrets with a recorded deletion
+// The not-found error is kept as it is; Deleted only adds when and by whom
+func (s *Server) markDeletions(secrets []reader.SecretInfo) {
+	for i := range secrets {
+		if secrets[i].Found || secrets[i].Source != reader.SourceKubernetes || !strings.Contains(secrets[i].Error, "not found") {
+			continue
+		}
+		if deletion, ok := s.history.LastDeletion(secrets[i].Name); ok {
+			secrets[i].Deleted = &reader.Deletion{
+				Time:   deletion.Time.Format(time.RFC3339),
+				Actor:  deletion.Actor,
+				Detail: deletion.Detail,
+			}
+		}
+	}
+}
+
+// deletionsHandler returns the recorded secret deletions since ?since= (RFC3339 or unix seconds), newest first
+func (s *Server) deletionsHandler(c *gin.Context) {
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		if since = parseSince(value); since.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": s.tr(c, "Invalid since value - use an RFC3339 timestamp or unix seconds"),
+			})
+			return
+		}
+	}
+
+	deletions := s.history.Deletions(since)
+	response := gin.H{
+		"deletions": deletions,
+		"count":     len(deletions),
+		"watching":  s.informersEnabled(),
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	if !since.IsZero() {
+		response["since"] = since.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminDiscoveryRefreshHandler drops the cached BitwardenSecret API discovery results and checks again,
+// e.g. after installing the CRD
+func (s *Server) adminDiscoveryRefreshHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	k8s.InvalidateDiscovery()
+	ctx := c.Request.Context()
+	if err := k8s.DetectCRDScope(s.k8sClients.Clientset.Discovery()); err != nil {
+		logging.Printf("Could not read the BitwardenSecret scope from discovery: %v", err)
+	}
+	status := k8s.CheckDiscovery(ctx, s.config.PodNamespace, s.clientsFor(ctx, s.config.PodNamespace).DynamicClient)
+	s.recordAudit(c, "discovery.refresh", k8s.BitwardenSecretGVR.Resource, s.config.PodNamespace, audit.OutcomeSuccess, map[string]string{
+		"served": strconv.FormatBool(status.Served),
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Discovery cache refreshed",
+		"discovery": status,
+	})
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// duplicatesHandler reports values shared by keys in different secrets, without the values
+// With ?allNamespaces=true, operator-managed secrets in every allowed namespace are compared too
+func (s *Server) duplicatesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	secrets, err := s.readSecrets(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if s.config.MemoryHygiene {
+		defer wipeSecretValues(secrets)
+	}
+
+	index := reader.NewDuplicateIndex()
+	// compared holds each secret once, as watched secrets are also found when scanning their namespace
+	compared := make(map[reader.ValueLocation]bool)
+	for _, secret := range secrets {
+		if !secret.Found {
+			continue
+		}
+		namespace := ""
+		if secret.Source == reader.SourceKubernetes {
+			namespace = s.config.PodNamespace
+		}
+		compared[reader.ValueLocation{Source: secret.Source, Namespace: namespace, Secret: secret.Name}] = true
+		for key, hash := range secret.ValueHashes {
+			index.Add(reader.ValueLocation{Source: secret.Source, Namespace: namespace, Secret: secret.Name, Key: key}, hash)
+		}
+	}
+
+	namespaceErrors := map[string]string{}
+	if c.Query("allNamespaces") == "true" {
+		if s.k8sClients == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+			})
+			return
+		}
+		namespaces := s.config.AllowedNamespaces
+		if s.config.AllNamespacesAllowed() {
+			namespaces, err = k8s.ListNamespaces(ctx, s.requestClients(c).Clientset)
+			if err != nil {
+				c.JSON(statusForK8sError(err), gin.H{
+					"error": fmt.Sprintf("Error listing namespaces: %v", err),
+				})
+				return
+			}
+		}
+
+		for _, namespace := range namespaces {
+			managed, err := k8s.ListOperatorSecrets(ctx, namespace, s.clientsFor(ctx, namespace).Clientset)
+			if err != nil {
+				namespaceErrors[namespace] = err.Error()
+				continue
+			}
+			for i := range managed {
+				location := reader.ValueLocation{Source: reader.SourceKubernetes, Namespace: namespace, Secret: managed[i].Name}
+				compared[location] = true
+				for key, value := range managed[i].Data {
+					location.Key = key
+					if len(value) > 0 {
+						index.Add(location, reader.HashValue(string(value)))
+					}
+				}
+				if s.config.MemoryHygiene {
+					for _, value := range managed[i].Data {
+						clear(value)
+					}
+				}
+			}
+		}
+	}
+
+	duplicates := index.Duplicates()
+	c.JSON(http.StatusOK, gin.H{
+		"duplicates":      duplicates,
+		"total":           len(duplicates),
+		"secretsCompared": len(compared),
+		"namespaceErrors": namespaceErrors,
+		"timestamp":       time.Now().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/backup"
+	"bitwarden-reader/internal/bundle"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// bundleSigner lazily loads the key used to sign exported bundles
+type bundleSigner struct {
+	once      sync.Once
+	key       ed25519.PrivateKey
+	ephemeral bool
+	err       error
+}
+
+// load returns the signing key, reading it on first use
+func (b *bundleSigner) load(path string) (ed25519.PrivateKey, bool, error) {
+	b.once.Do(func() {
+		b.key, b.ephemeral, b.err = bundle.LoadSigningKey(path)
+		if b.err == nil && b.ephemeral {
+			logging.Println("WARNING: EXPORT_SIGNING_KEY_FILE not set - state bundles are signed with an ephemeral key")
+		}
+	})
+	return b.key, b.ephemeral, b.err
+}
+
+// buildStateBundle builds an unsigned bundle from the current secrets, hashing values with hashKey
+func (s *Server) buildStateBundle(ctx context.Context, hashKey []byte) (*bundle.Bundle, error) {
+	secrets, err := s.readSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bundle.Bundle{
+		Version:     bundle.FormatVersion,
+		GeneratedAt: time.Now().UTC(),
+		Generator:   fmt.Sprintf("%s %s", s.config.AppTitle, s.config.AppVersion),
+		Namespace:   s.config.PodNamespace,
+		HashKeyID:   bundle.HashKeyID(hashKey),
+		Secrets:     make([]bundle.SecretRecord, 0, len(secrets)),
+	}
+
+	for _, secret := range secrets {
+		record := bundle.SecretRecord{
+			Name:      secret.Name,
+			Namespace: s.config.PodNamespace,
+			Group:     secret.Group,
+			Found:     secret.Found,
+			Keys:      hashKeys(hashKey, secret.Keys),
+			Sync: bundle.SyncRecord{
+				CRDFound:           secret.SyncInfo.CRDFound,
+				LastSuccessfulSync: secret.SyncInfo.LastSuccessfulSync,
+				SecretSyncTime:     secret.SyncInfo.K8sSecretSyncTime,
+				Status:             secret.SyncInfo.SyncStatus,
+				Reason:             secret.SyncInfo.SyncReason,
+				Message:            secret.SyncInfo.SyncMessage,
+			},
+			Error: secret.Error,
+		}
+		if secret.SyncInfo.CRDFound && s.k8sClients != nil {
+			s.addCRDState(ctx, secret.Name, &record)
+		}
+		b.Secrets = append(b.Secrets, record)
+	}
+	return b, nil
+}
+
+// addCRDState copies the CRD spec and status into the record
+func (s *Server) addCRDState(ctx context.Context, name string, record *bundle.SecretRecord) {
+	obj, err := k8s.GetBitwardenSecret(ctx, name, s.config.PodNamespace, s.clientsFor(ctx, s.config.PodNamespace).DynamicClient)
+	if err != nil {
+		logging.Printf("Error reading CRD %s for export: %v", name, err)
+		return
+	}
+	if spec, found, err := unstructured.NestedMap(obj.Object, "spec"); err == nil && found {
+		record.CRDSpec = spec
+	}
+	if status, found, err := unstructured.NestedMap(obj.Object, "status"); err == nil && found {
+		record.CRDStatus = status
+	}
+}
+
+// hashKeys converts decoded secret values into sorted key records with value hashes
+func hashKeys(hashKey []byte, keys map[string]string) []bundle.KeyRecord {
+	records := make([]bundle.KeyRecord, 0, len(keys))
+	for name, value := range keys {
+		records = append(records, bundle.KeyRecord{Name: name, ValueHash: bundle.HashValue(hashKey, value)})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Name < records[j].Name
+	})
+	return records
+}
+
+// exportStateHandler returns a signed bundle of secret metadata, value hashes, and CRD state
+func (s *Server) exportStateHandler(c *gin.Context) {
+	key, ephemeral, err := s.signer.load(s.config.ExportSigningKeyFile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load signing key: %v", err),
+		})
+		return
+	}
+
+	b, err := s.buildStateBundle(c.Request.Context(), bundle.HashKey(key))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := bundle.Sign(b, key, ephemeral); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to sign bundle: %v", err),
+		})
+		return
+	}
+
+	s.recordAudit(c, "export.state", "secrets", s.config.PodNamespace, audit.OutcomeSuccess, map[string]string{
+		"secretCount": fmt.Sprint(len(b.Secrets)),
+	})
+
+	filename := fmt.Sprintf("bitwarden-state-%s.json", b.GeneratedAt.Format("20060102T150405Z"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.JSON(http.StatusOK, b)
+}
+
+// encryptedExportRequest represents the optional request body for an encrypted export
+type encryptedExportRequest struct {
+	SecretNames []string `json:"secretNames,omitempty"`
+}
+
+// exportEncryptedHandler exports secret values encrypted for the configured recipient key
+// Disabled unless ENCRYPTED_EXPORT_ENABLED is set; every attempt is audit-logged
+func (s *Server) exportEncryptedHandler(c *gin.Context) {
+	if !s.config.EncryptedExportEnabled {
+		s.recordAudit(c, "export.encrypted", "secrets", s.config.PodNamespace, audit.OutcomeDenied, map[string]string{"reason": "encrypted export disabled"})
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Encrypted export is disabled - set ENCRYPTED_EXPORT_ENABLED=true to allow it",
+		})
+		return
+	}
+	if s.config.ExportRecipientKey == "" {
+		s.recordAudit(c, "export.encrypted", "secrets", s.config.PodNamespace, audit.OutcomeFailure, map[string]string{"reason": "no recipient key"})
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "EXPORT_RECIPIENT_PUBLIC_KEY is not configured",
+		})
+		return
+	}
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	var req encryptedExportRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid request body: %v", err),
+			})
+			return
+		}
+	}
+
+	names, unknown := s.selectConfiguredSecrets(req.SecretNames)
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Only configured secrets can be exported",
+			"unknown": unknown,
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	payload := &backup.Payload{}
+	for _, name := range names {
+		secret, err := k8s.ReadSecret(ctx, name, s.config.PodNamespace, s.clientsFor(ctx, s.config.PodNamespace).Clientset)
+		if err != nil {
+			if k8s.IsSecretNotFound(err) {
+				continue
+			}
+			s.recordAudit(c, "export.encrypted", name, s.config.PodNamespace, audit.OutcomeFailure, map[string]string{"error": err.Error()})
+			c.JSON(statusForK8sError(err), gin.H{
+				"error": fmt.Sprintf("Error reading secret '%s': %v", name, err),
+			})
+			return
+		}
+		payload.Secrets = append(payload.Secrets, backup.SecretData{
+			Name:        secret.Name,
+			Namespace:   secret.Namespace,
+			Type:        string(secret.Type),
+			Labels:      secret.Labels,
+			Annotations: secret.Annotations,
+			Data:        secret.Data,
+		})
+	}
+
+	encrypted, err := backup.Seal(payload, s.config.PodNamespace, s.config.ExportRecipientKey)
+	if err != nil {
+		s.recordAudit(c, "export.encrypted", "secrets", s.config.PodNamespace, audit.OutcomeFailure, map[string]string{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	s.recordAudit(c, "export.encrypted", "secrets", s.config.PodNamespace, audit.OutcomeSuccess, map[string]string{
+		"secrets":    strings.Join(encrypted.Secrets, ","),
+		"recipients": strings.Join(encrypted.Recipients, ","),
+	})
+
+	filename := fmt.Sprintf("bitwarden-backup-%s.json", encrypted.GeneratedAt.Format("20060102T150405Z"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.JSON(http.StatusOK, encrypted)
+}
+
+// selectConfiguredSecrets returns the requested names that are configured, or all configured
+// names when none are requested, along with any requested names that are not configured
+func (s *Server) selectConfiguredSecrets(requested []string) (selected, unknown []string) {
+	configured := make(map[string]bool)
+	for _, name := range s.config.SecretNames {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			configured[name] = true
+			if len(requested) == 0 {
+				selected = append(selected, name)
+			}
+		}
+	}
+	for _, name := range requested {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if configured[name] {
+			selected = append(selected, name)
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	return selected, unknown
+}
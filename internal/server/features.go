@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"bitwarden-reader/internal/features"
+	"bitwarden-reader/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// informersEnabled reports whether secret metadata is watched with informers, as WATCH_STRATEGY and the
+// informers flag allow
+func (s *Server) informersEnabled() bool {
+	return s.k8sClients != nil && s.config.WatchStrategy != k8s.WatchGet && s.features.Enabled(features.Informers)
+}
+
+// writeEnabled reports whether the BitwardenSecret write endpoints are served, as WRITE_ENABLED and the
+// writeEndpoints flag allow
+func (s *Server) writeEnabled() bool {
+	return s.config.WriteEnabled && s.features.Enabled(features.WriteEndpoints)
+}
+
+// adminFeaturesHandler lists the feature flags with their stage, default, and effective value
+func (s *Server) adminFeaturesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"flags":     s.features.States(),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/hooks"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health states between which transitions are counted
+const (
+	stateOK      = "ok"
+	stateMissing = "missing"
+	stateFailing = "failing"
+)
+
+// healthState classifies a secret as missing, sync failing, or ok
+func healthState(secret reader.SecretInfo) string {
+	switch {
+	case !secret.Found:
+		return stateMissing
+	case secret.SyncInfo.SyncStatus == "False":
+		return stateFailing
+	default:
+		return stateOK
+	}
+}
+
+// healthTransition is one change of a secret's health state
+type healthTransition struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// flapState is the tracked health of one secret
+type flapState struct {
+	name   string
+	source string
+	state  string
+	since  time.Time
+	// transitions holds the transitions within the window, oldest first
+	transitions []healthTransition
+	flapping    bool
+}
+
+// flapDetector counts health transitions per secret and flags a secret as flapping
+// while at least threshold transitions happened within window
+type flapDetector struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	secrets   map[string]*flapState
+}
+
+// newFlapDetector creates a detector; it returns nil when threshold is not positive
+func newFlapDetector(threshold int, window time.Duration) *flapDetector {
+	if threshold <= 0 || window <= 0 {
+		return nil
+	}
+	return &flapDetector{
+		threshold: threshold,
+		window:    window,
+		secrets:   make(map[string]*flapState),
+	}
+}
+
+// observe records the secret's current health and reports whether it started or stopped flapping
+func (d *flapDetector) observe(secret reader.SecretInfo, now time.Time) (started, stopped bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := healthState(secret)
+	state, seen := d.secrets[secret.Key()]
+	if !seen {
+		d.secrets[secret.Key()] = &flapState{name: secret.Name, source: secret.Source, state: current, since: now}
+		return false, false
+	}
+
+	if state.state != current {
+		state.transitions = append(state.transitions, healthTransition{From: state.state, To: current, Time: now})
+		state.state = current
+		state.since = now
+	}
+
+	cutoff := now.Add(-d.window)
+	kept := state.transitions[:0]
+	for _, t := range state.transitions {
+		if t.Time.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	state.transitions = kept
+
+	flapping := len(state.transitions) >= d.threshold
+	started = flapping && !state.flapping
+	stopped = !flapping && state.flapping
+	state.flapping = flapping
+	return started, stopped
+}
+
+// isFlapping reports whether the secret is flapping; a nil detector never flags anything
+func (d *flapDetector) isFlapping(key string) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state, ok := d.secrets[key]
+	return ok && state.flapping
+}
+
+// mark sets Flapping on each secret the detector flags
+func (d *flapDetector) mark(secrets []reader.SecretInfo) {
+	if d == nil {
+		return
+	}
+	for i := range secrets {
+		secrets[i].Flapping = d.isFlapping(secrets[i].Key())
+	}
+}
+
+// secretTransitions is the health history of one secret in the transitions API
+type secretTransitions struct {
+	Secret      string             `json:"secret"`
+	Source      string             `json:"source"`
+	State       string             `json:"state"`
+	Since       time.Time          `json:"since"`
+	Flapping    bool               `json:"flapping"`
+	Transitions []healthTransition `json:"transitions"`
+}
+
+// snapshot returns the tracked secrets ordered by name, then source
+func (d *flapDetector) snapshot() []secretTransitions {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := make([]secretTransitions, 0, len(d.secrets))
+	for _, state := range d.secrets {
+		result = append(result, secretTransitions{
+			Secret:      state.name,
+			Source:      state.source,
+			State:       state.state,
+			Since:       state.since,
+			Flapping:    state.flapping,
+			Transitions: append([]healthTransition{}, state.transitions...),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Secret != result[j].Secret {
+			return result[i].Secret < result[j].Secret
+		}
+		return result[i].Source < result[j].Source
+	})
+	return result
+}
+
+// flapEvents updates the detector from the secrets and filters the change events
+// A secret that starts flapping produces one flapping event, its other events except recoveries
+// are suppressed while it flaps, and a flapping-stopped event reports the state it settled in
+func (s *Server) flapEvents(secrets []reader.SecretInfo, events []hooks.Event, now time.Time) []hooks.Event {
+	if s.flaps == nil {
+		return events
+	}
+
+	flapping := make(map[string]bool)
+	var notices []hooks.Event
+	for _, secret := range secrets {
+		started, stopped := s.flaps.observe(secret, now)
+		switch {
+		case started:
+			notices = append(notices, hooks.Event{
+				Type:      hooks.EventFlapping,
+				Secret:    secret.Name,
+				Namespace: s.config.PodNamespace,
+				Group:     secret.Group,
+				Detail:    fmt.Sprintf("%d health transitions within %s", s.config.FlapThreshold, s.config.FlapWindow),
+			})
+		case stopped:
+			notices = append(notices, hooks.Event{
+				Type:      hooks.EventFlapStopped,
+				Secret:    secret.Name,
+				Namespace: s.config.PodNamespace,
+				Group:     secret.Group,
+				Detail:    "settled " + healthState(secret),
+			})
+		}
+		if s.flaps.isFlapping(secret.Key()) {
+			flapping[secret.Name] = true
+		}
+	}
+
+	kept := make([]hooks.Event, 0, len(events)+len(notices))
+	for _, event := range events {
+		// Recoveries still pass so incidents opened before the flap get resolved
+		if flapping[event.Secret] && event.Type != hooks.EventSyncRecovered && event.Type != hooks.EventAlertResolved {
+			continue
+		}
+		kept = append(kept, event)
+	}
+	return append(kept, notices...)
+}
+
+// healthTransitionsHandler returns the recent health transitions of each secret and whether it is flapping
+func (s *Server) healthTransitionsHandler(c *gin.Context) {
+	if s.flaps == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flapping detection is disabled (FLAP_THRESHOLD is 0)"})
+		return
+	}
+
+	secrets := s.flaps.snapshot()
+	if name := c.Query("secret"); name != "" {
+		filtered := secrets[:0]
+		for _, secret := range secrets {
+			if secret.Secret == name {
+				filtered = append(filtered, secret)
+			}
+		}
+		secrets = filtered
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"threshold": s.config.FlapThreshold,
+		"window":    s.config.FlapWindow.String(),
+		"secrets":   secrets,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
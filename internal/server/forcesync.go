@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/history"
+	"bitwarden-reader/internal/k8s"
+)
+
+const (
+	// operatorProbeTTL is how long the operator Deployment read is reused
+	operatorProbeTTL = 5 * time.Minute
+
+	// operatorProbeFailureTTL is how long a failed operator Deployment read is reused, so an
+	// unreadable Deployment is not read on every request
+	operatorProbeFailureTTL = 30 * time.Second
+
+	// operatorProbeTimeout bounds reading the operator Deployment
+	operatorProbeTimeout = 5 * time.Second
+
+	// forceSyncIgnoredAfter is how many verified triggers in a row must go without any sync
+	// before force-sync is reported as ignored
+	forceSyncIgnoredAfter = 2
+
+	// forceSyncLookback is how many recent triggers are searched for evidence
+	forceSyncLookback = 20
+)
+
+// Manual sync support states
+const (
+	manualSyncYes     = "yes"
+	manualSyncNo      = "no"
+	manualSyncUnknown = "unknown"
+)
+
+// manualSyncSupport tells the UI whether the operator honors the force-sync annotation,
+// and whether that was observed after past triggers or inferred from the operator version
+type manualSyncSupport struct {
+	Supported       string `json:"supported"`
+	Source          string `json:"source,omitempty"`
+	OperatorVersion string `json:"operatorVersion,omitempty"`
+	Detail          string `json:"detail,omitempty"`
+}
+
+// operatorProbe caches the operator Deployment read for operatorProbeTTL, and a failed read for
+// operatorProbeFailureTTL
+type operatorProbe struct {
+	mu         sync.Mutex
+	deployment *k8s.OperatorDeployment
+	err        error
+	expires    time.Time
+	// reading is closed when the read in flight finishes, nil when none is
+	reading chan struct{}
+}
+
+// get returns the operator Deployment, reading it when the cached result expired
+// The read runs without the lock and detached from ctx, so a canceled request neither stops it nor
+// leaves its error cached; callers arriving during the read wait for it as long as their ctx allows
+func (p *operatorProbe) get(ctx context.Context, clients *k8s.K8sClients, namespace, name string, now time.Time) (*k8s.OperatorDeployment, error) {
+	for {
+		p.mu.Lock()
+		if now.Before(p.expires) {
+			deployment, err := p.deployment, p.err
+			p.mu.Unlock()
+			return deployment, err
+		}
+		reading := p.reading
+		if reading == nil {
+			break
+		}
+		p.mu.Unlock()
+		select {
+		case <-reading:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	reading := make(chan struct{})
+	p.reading = reading
+	p.mu.Unlock()
+
+	readCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), operatorProbeTimeout)
+	deployment, err := k8s.GetOperatorDeployment(readCtx, clients, namespace, name)
+	cancel()
+
+	p.mu.Lock()
+	switch {
+	case err == nil:
+		p.deployment, p.err, p.expires = deployment, nil, now.Add(operatorProbeTTL)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// A timeout says nothing lasting about the Deployment, the next caller reads again
+	default:
+		p.deployment, p.err, p.expires = nil, err, now.Add(operatorProbeFailureTTL)
+	}
+	p.reading = nil
+	p.mu.Unlock()
+	close(reading)
+	return deployment, err
+}
+
+// observedForceSync looks at verified triggers, newest first: a sync that succeeded or failed after
+// a trigger shows the operator reacts, while forceSyncIgnoredAfter triggers in a row without any
+// sync show it doesn't; anything else is unknown
+func observedForceSync(records []history.TriggerRecord) (string, string) {
+	ignored := 0
+	for _, record := range records {
+		if record.Result == "" {
+			continue
+		}
+		reacted := false
+		for _, secret := range record.Secrets {
+			if secret.Result == history.SyncSucceeded || secret.Result == history.SyncFailed {
+				reacted = true
+				break
+			}
+		}
+		if reacted {
+			return manualSyncYes, fmt.Sprintf("The operator synced after trigger %s at %s", record.ID, record.Time.UTC().Format(time.RFC3339))
+		}
+		if ignored++; ignored >= forceSyncIgnoredAfter {
+			return manualSyncNo, fmt.Sprintf("The operator did not sync after the last %d triggers", ignored)
+		}
+	}
+	return manualSyncUnknown, ""
+}
+
+// manualSyncSupport works out whether triggering a sync does anything: trigger history wins over
+// the operator image version, since forks and custom builds don't follow the upstream releases
+func (s *Server) manualSyncSupport(ctx context.Context) manualSyncSupport {
+	support := manualSyncSupport{Supported: manualSyncUnknown}
+	if s.k8sClients == nil {
+		return support
+	}
+
+	if state, detail := observedForceSync(s.history.Triggers(history.TriggerFilter{Limit: forceSyncLookback})); state != manualSyncUnknown {
+		support.Supported, support.Source, support.Detail = state, "observed", detail
+	}
+
+	deployment, err := s.operatorProbe.get(ctx, s.k8sClients, s.config.OperatorNamespace, s.config.OperatorDeployment, time.Now())
+	if err != nil {
+		if support.Source == "" {
+			support.Detail = err.Error()
+		}
+		return support
+	}
+	support.OperatorVersion = deployment.Version
+	if support.Source != "" || s.config.ForceSyncMinVersion == "" {
+		return support
+	}
+
+	cmp, ok := k8s.CompareVersions(deployment.Version, s.config.ForceSyncMinVersion)
+	switch {
+	case !ok:
+		support.Detail = fmt.Sprintf("Operator image %s has no version to compare", deployment.Image)
+	case cmp < 0:
+		support.Supported, support.Source = manualSyncNo, "operator-version"
+		support.Detail = fmt.Sprintf("Operator %s is older than %s, the first version honoring force-sync", deployment.Version, s.config.ForceSyncMinVersion)
+	default:
+		support.Supported, support.Source = manualSyncYes, "operator-version"
+		support.Detail = fmt.Sprintf("Operator %s honors force-sync from %s on", deployment.Version, s.config.ForceSyncMinVersion)
+	}
+	return support
+}
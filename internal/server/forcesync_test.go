@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bitwarden-reader/internal/k8s/k8sfake"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestOperatorProbeFailures(t *testing.T) {
+	clients := k8sfake.NewClients()
+	reads := 0
+	var readErr error
+	clients.Clientset.(*kubefake.Clientset).PrependReactor("get", "deployments",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			reads++
+			return true, nil, readErr
+		})
+	var probe operatorProbe
+	now := time.Now()
+
+	// A timed out read is not cached
+	readErr = context.DeadlineExceeded
+	if _, err := probe.get(context.Background(), clients, "ops", "sm-operator", now); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("get returned %v, want the deadline error", err)
+	}
+	readErr = errors.New("forbidden")
+	if _, err := probe.get(context.Background(), clients, "ops", "sm-operator", now); err == nil || reads != 2 {
+		t.Fatalf("get after a timeout returned %v after %d reads, want a new failed read", err, reads)
+	}
+
+	// Other failures are reused until operatorProbeFailureTTL passes
+	probe.get(context.Background(), clients, "ops", "sm-operator", now.Add(operatorProbeFailureTTL/2))
+	if reads != 2 {
+		t.Errorf("the failed read was repeated within operatorProbeFailureTTL, %d reads", reads)
+	}
+	probe.get(context.Background(), clients, "ops", "sm-operator", now.Add(operatorProbeFailureTTL))
+	if reads != 3 {
+		t.Errorf("the failed read was not repeated after operatorProbeFailureTTL, %d reads", reads)
+	}
+
+	// A canceled request still reads, and its result is kept for others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	readErr = errors.New("not found")
+	if _, err := probe.get(ctx, clients, "ops", "sm-operator", now.Add(time.Minute)); err == nil || errors.Is(err, context.Canceled) {
+		t.Errorf("get with a canceled context returned %v, want the read error", err)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"bitwarden-reader/internal/gitops"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gitopsCompareHandler compares live Secrets with the manifests in GITOPS_MANIFESTS
+func (s *Server) gitopsCompareHandler(c *gin.Context) {
+	if len(s.config.GitOpsManifests) == 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "No GitOps manifests configured - set GITOPS_MANIFESTS",
+		})
+		return
+	}
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := make([]gitops.Result, 0)
+	summary := map[string]int{
+		gitops.StatusMatch:    0,
+		gitops.StatusDiverged: 0,
+		gitops.StatusMissing:  0,
+		gitops.StatusError:    0,
+	}
+
+	for _, source := range s.config.GitOpsManifests {
+		expected, err := s.manifests.Load(ctx, source)
+		if err != nil {
+			results = append(results, gitops.Result{Source: source, Status: gitops.StatusError, Error: err.Error()})
+			summary[gitops.StatusError]++
+			continue
+		}
+
+		for _, secret := range expected {
+			namespace, allowed := s.resolveNamespace(secret.Namespace)
+			secret.Namespace = namespace
+			var result gitops.Result
+			switch {
+			case !allowed:
+				result = gitops.Result{Source: source, Format: secret.Format, Name: secret.Name, Namespace: namespace, Status: gitops.StatusError,
+					Error: s.tr(c, "Namespace '%s' is not in the allowed namespace list", namespace)}
+			default:
+				live, err := k8s.ReadSecret(ctx, secret.Name, namespace, s.clientsFor(ctx, namespace).Clientset)
+				switch {
+				case err == nil:
+					result = gitops.Compare(secret, live)
+					k8s.WipeSecretData(live.Data)
+				case k8s.IsSecretNotFound(err):
+					result = gitops.Compare(secret, nil)
+				default:
+					result = gitops.Result{Source: source, Format: secret.Format, Name: secret.Name, Namespace: namespace, Status: gitops.StatusError, Error: err.Error()}
+				}
+			}
+			if result.Status == gitops.StatusDiverged {
+				logging.Printf("GitOps divergence: %s/%s differs from %s", namespace, secret.Name, source)
+			}
+			summary[result.Status]++
+			results = append(results, result)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":   results,
+		"summary":   summary,
+		"inSync":    summary[gitops.StatusDiverged] == 0 && summary[gitops.StatusMissing] == 0 && summary[gitops.StatusError] == 0,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
@@ -1,11 +1,13 @@
 package server
 
 import (
+	stderrors "errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"bitwarden-reader/internal/history"
 	"bitwarden-reader/internal/k8s"
 	"bitwarden-reader/internal/reader"
 
@@ -15,33 +17,48 @@ import (
 // webHandler renders the HTML template with secret data
 func (s *Server) webHandler(c *gin.Context) {
 	ctx := c.Request.Context()
-	secrets, err := reader.ReadSecrets(ctx, s.config.SecretNames, s.config.PodNamespace, s.k8sClients)
+	secrets, err := s.readSecrets(ctx)
 	if err != nil {
-		c.HTML(http.StatusInternalServerError, "index.html", gin.H{
+		s.renderPage(c, http.StatusInternalServerError, "index.html", gin.H{
 			"Error":      err.Error(),
 			"PodName":    s.config.PodName,
 			"Namespace":  s.config.PodNamespace,
 			"AppTitle":   s.config.AppTitle,
 			"AppVersion": s.config.AppVersion,
+			"Capabilities": capabilities{},
+			"Lang":         c.GetString(languageKey),
+			"Theme":        s.uiTheme(),
 		})
 		return
 	}
 
-	c.HTML(http.StatusOK, "index.html", gin.H{
+	setReturnedSecrets(c, secrets)
+	s.renderPage(c, http.StatusOK, "index.html", gin.H{
 		"Secrets":     secrets,
 		"TotalSecrets": countFoundSecrets(secrets),
+		"NamespaceStatus": reader.NamespaceIssue(secrets),
 		"PodName":     s.config.PodName,
 		"Namespace":   s.config.PodNamespace,
 		"AppTitle":    s.config.AppTitle,
 		"AppVersion":  s.config.AppVersion,
 		"ShowValues":  s.config.ShowSecretValues,
+		"Capabilities": s.requestCapabilities(c),
+		"Lang":         c.GetString(languageKey),
+		"Theme":        s.uiTheme(),
 	})
+	if s.config.MemoryHygiene {
+		wipeSecretValues(secrets)
+	}
 }
 
-// apiSecretsHandler returns JSON response with all secrets
+// apiSecretsHandler returns JSON response with all secrets, read from ?namespace= when given
 func (s *Server) apiSecretsHandler(c *gin.Context) {
 	ctx := c.Request.Context()
-	secrets, err := reader.ReadSecrets(ctx, s.config.SecretNames, s.config.PodNamespace, s.k8sClients)
+	namespace, ok := s.requestNamespace(c, "", "secrets", "get")
+	if !ok {
+		return
+	}
+	secrets, err := s.readNamespaceSecrets(ctx, namespace)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -49,71 +66,178 @@ func (s *Server) apiSecretsHandler(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	secrets = reader.FilterByGroup(secrets, c.Query("group"))
+	setReturnedSecrets(c, secrets)
+	if c.Query("expand") == "true" {
+		reader.ExpandValues(secrets)
+	}
+
+	s.respondWithSecrets(c, http.StatusOK, withNamespaceStatus(gin.H{
 		"secrets":    secrets,
-		"namespace":  s.config.PodNamespace,
+		"namespace":  namespace,
 		"totalFound": countFoundSecrets(secrets),
 		"timestamp":  time.Now().Format(time.RFC3339),
-	})
+	}, secrets), secrets)
+}
+
+// apiGroupsHandler returns per-group summaries of the configured secrets
+func (s *Server) apiGroupsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	secrets, err := s.readSecrets(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, withNamespaceStatus(gin.H{
+		"groups":    reader.SummarizeGroups(secrets),
+		"namespace": s.config.PodNamespace,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, secrets))
 }
 
 // triggerSyncRequest represents the request body for trigger sync
 type triggerSyncRequest struct {
 	SecretNames []string `json:"secretNames,omitempty"`
+	// Ticket is a change or incident ID recorded with the trigger and available to TRIGGER_ANNOTATIONS
+	Ticket string `json:"ticket,omitempty"`
+	// ConfirmationToken comes from GET /api/v1/trigger-sync/plan and is required above TRIGGER_CONFIRM_ABOVE secrets
+	ConfirmationToken string `json:"confirmationToken,omitempty"`
 }
 
-// triggerSyncHandler patches CRD annotations to trigger sync
+// triggerSyncHandler patches CRD annotations to trigger sync, in ?namespace= when given
 func (s *Server) triggerSyncHandler(c *gin.Context) {
 	// Check if Kubernetes clients are available
 	if s.k8sClients == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "Kubernetes client not available - running in standalone mode",
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
 		})
 		return
 	}
 
 	ctx := c.Request.Context()
+	namespace, ok := s.requestNamespace(c, k8s.BitwardenSecretGVR.Group, k8s.BitwardenSecretGVR.Resource, "patch")
+	if !ok {
+		return
+	}
 
+	// An empty body triggers SECRET_NAMES; a body that doesn't match the schema is rejected
 	var req triggerSyncRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		req.SecretNames = s.config.SecretNames
+	if !s.bindBody(c, triggerSyncSchema, &req) {
+		return
+	}
+
+	req.SecretNames = s.triggerSecretNames(req.SecretNames)
+	if msg := s.checkTriggerBatch(len(req.SecretNames)); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+	if s.triggerNeedsConfirmation(len(req.SecretNames)) {
+		if req.ConfirmationToken == "" {
+			c.JSON(http.StatusPreconditionRequired, gin.H{
+				"error": fmt.Sprintf("Triggering %d secrets requires confirmation - get a token from GET /api/v1/trigger-sync/plan and send it as confirmationToken", len(req.SecretNames)),
+			})
+			return
+		}
+		if !s.confirmations.consume(req.ConfirmationToken, triggerSubject(namespace, req.SecretNames), requestActor(c)) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{
+				"error": s.tr(c, "Confirmation token is invalid, expired, or issued for a different set of secrets or identity"),
+			})
+			return
+		}
 	}
 
-	if len(req.SecretNames) == 0 {
-		req.SecretNames = s.config.SecretNames
+	req.Ticket = strings.TrimSpace(req.Ticket)
+	if err := validateTicket(req.Ticket); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
 	}
+	annotations := s.triggerAnnotations(c, req.Ticket)
 
 	var errors []string
 	var successes []string
+	var deduplicated []string
+	var results []history.TriggerSecret
+	var items []triggerItemResult
 
-	for _, secretName := range req.SecretNames {
-		secretName = strings.TrimSpace(secretName)
-		if secretName == "" {
+	for i, secretName := range req.SecretNames {
+		// Stagger the patches so a bulk trigger doesn't stampede the operator and the Bitwarden API
+		if i > 0 && !s.triggerPause(ctx) {
+			errors = append(errors, fmt.Sprintf("%s: %v", secretName, ctx.Err()))
+			results = append(results, history.TriggerSecret{Name: secretName, Error: ctx.Err().Error()})
+			items = append(items, s.triggerItem(secretName, secretName, namespace, ctx.Err(), time.Now()))
 			continue
 		}
 
 		crdName := secretName
-		err := k8s.TriggerSync(ctx, crdName, s.config.PodNamespace, s.k8sClients.DynamicClient)
-		if err != nil {
+		result := history.TriggerSecret{Name: secretName}
+		result.SyncBefore, result.FailureBefore = s.syncState(ctx, namespace, crdName)
+		err := s.triggerSync(c, namespace, crdName, annotations)
+		items = append(items, s.triggerItem(secretName, crdName, namespace, err, time.Now()))
+		var duplicate *k8s.TriggerDuplicateError
+		if stderrors.As(err, &duplicate) {
+			// The earlier trigger's sync is verified like this one's would be
+			successes = append(successes, secretName)
+			deduplicated = append(deduplicated, secretName)
+			result.Triggered = true
+			result.Deduplicated = duplicate.Claim.String()
+		} else if err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", secretName, err))
+			result.Error = err.Error()
 		} else {
 			successes = append(successes, secretName)
+			result.Triggered = true
 		}
+		results = append(results, result)
+	}
+
+	triggerID := newRequestID()
+	verifying := s.saveTrigger(history.TriggerRecord{
+		ID:        triggerID,
+		Time:      time.Now().UTC(),
+		Initiator: requestActor(c),
+		RequestID: c.GetString(requestIDKey),
+		Namespace: namespace,
+		Outcome:   triggerOutcome(results),
+		Ticket:    req.Ticket,
+		Secrets:   results,
+	})
+
+	// Dashboards only show the pod namespace
+	if len(errors) == 0 && namespace == s.config.PodNamespace {
+		s.broadcastSecrets()
+	}
+
+	c.Header("Vary", "Accept")
+	if wantsTriggerResults(c) {
+		respondTriggerResults(c, triggerID, verifying, items)
+		return
 	}
 
+	// Version 1 responses answer partial failures with 206, kept for existing clients
 	if len(errors) > 0 {
 		c.JSON(http.StatusPartialContent, gin.H{
-			"successes": successes,
-			"errors":    errors,
+			"successes":    successes,
+			"deduplicated": deduplicated,
+			"errors":       errors,
+			"triggerId":    triggerID,
+			"verifying":    verifying,
 		})
 		return
 	}
 
-	s.broadcastSecrets()
-
 	c.JSON(http.StatusOK, gin.H{
-		"message":   "Sync triggered successfully",
-		"successes": successes,
+		"message":      "Sync triggered successfully",
+		"successes":    successes,
+		"deduplicated": deduplicated,
+		"triggerId":    triggerID,
+		"verifying":    verifying,
 	})
 }
 
@@ -124,3 +248,70 @@ func (s *Server) healthHandler(c *gin.Context) {
 		"version": s.config.AppVersion,
 	})
 }
+
+// secretsHealthHandler reports aggregated secret health in the Argo CD health format
+// Responds 503 when Degraded so CD hooks and HTTP checks can gate on it
+func (s *Server) secretsHealthHandler(c *gin.Context) {
+	secrets, err := s.readSecrets(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  reader.HealthDegraded,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	status, details := reader.AggregateHealth(secrets)
+	unhealthy := 0
+	for _, health := range details {
+		if health.Status != reader.HealthHealthy {
+			unhealthy++
+		}
+	}
+	message := fmt.Sprintf("All %d secrets healthy", len(details))
+	if issue := reader.NamespaceIssue(secrets); issue != nil {
+		message = issue.Message
+	} else if unhealthy > 0 {
+		message = fmt.Sprintf("%d of %d secrets not healthy", unhealthy, len(details))
+	}
+
+	code := http.StatusOK
+	if status == reader.HealthDegraded {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, withNamespaceStatus(gin.H{
+		"status":    status,
+		"message":   message,
+		"secrets":   details,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, secrets))
+}
+
+// readyzHandler reports ready once every REQUIRED_SECRETS entry exists and has synced
+func (s *Server) readyzHandler(c *gin.Context) {
+	if len(s.config.RequiredSecrets) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"ready": true,
+		})
+		return
+	}
+
+	secrets, err := reader.ReadSecrets(c.Request.Context(), s.config.RequiredSecrets, s.config.PodNamespace, s.k8sClients.ForNamespace(s.config.PodNamespace))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"ready": false,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ready, results := reader.CheckReadiness(secrets)
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, withNamespaceStatus(gin.H{
+		"ready":   ready,
+		"secrets": results,
+	}, secrets))
+}
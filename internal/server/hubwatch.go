@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hubHealth is the watchdog's view of the hub event loop
+type hubHealth struct {
+	mu           sync.Mutex
+	responsive   bool
+	lastResponse time.Time
+	lastCheck    time.Time
+	restarts     int
+	lastPanic    string
+}
+
+// recordProbe stores the result of a watchdog probe
+func (h *hubHealth) recordProbe(ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.responsive = ok
+	h.lastCheck = time.Now()
+	if ok {
+		h.lastResponse = h.lastCheck
+	}
+}
+
+// recordPanic stores a recovered hub panic
+func (h *hubHealth) recordPanic(value interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastPanic = fmt.Sprint(value)
+}
+
+// recordRestart counts an event loop restart
+func (h *hubHealth) recordRestart() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.restarts++
+}
+
+// hubStatus is the hub section of the /livez response
+type hubStatus struct {
+	Alive        bool   `json:"alive"`
+	LastResponse string `json:"lastResponse,omitempty"`
+	LastCheck    string `json:"lastCheck,omitempty"`
+	Restarts     int    `json:"restarts"`
+	LastPanic    string `json:"lastPanic,omitempty"`
+}
+
+// status reports the hub as alive unless the last probe failed or none succeeded within maxSilence
+func (h *hubHealth) status(maxSilence time.Duration) hubStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := hubStatus{
+		// Before the first probe the hub has just been started
+		Alive:     h.lastCheck.IsZero() || (h.responsive && time.Since(h.lastResponse) <= maxSilence),
+		Restarts:  h.restarts,
+		LastPanic: h.lastPanic,
+	}
+	if !h.lastResponse.IsZero() {
+		status.LastResponse = h.lastResponse.UTC().Format(time.RFC3339)
+	}
+	if !h.lastCheck.IsZero() {
+		status.LastCheck = h.lastCheck.UTC().Format(time.RFC3339)
+	}
+	return status
+}
+
+// hubCheckpoint is the replay state of a hub: its sequence number, last snapshot, and replay buffer
+type hubCheckpoint struct {
+	seq    int64
+	last   *broadcastPayload
+	recent []*broadcastPayload
+}
+
+// supervise runs the hub event loop, restarting it after a panic when autoRestart is set
+// Registered clients stay in the hub across restarts, so they keep receiving updates
+func (h *Hub) supervise(autoRestart bool) {
+	for h.runRecovered() {
+		if h.retired() {
+			return
+		}
+		if !autoRestart {
+			logging.Printf("WebSocket hub stopped after panic; auto-restart disabled")
+			return
+		}
+		h.health.recordRestart()
+		logging.Printf("Restarting WebSocket hub with %d registered clients", len(h.clients))
+	}
+}
+
+// runRecovered runs the event loop and reports whether it stopped because of a panic
+func (h *Hub) runRecovered() (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.Printf("WebSocket hub panic: %v\n%s", r, debug.Stack())
+			h.health.recordPanic(r)
+			panicked = true
+		}
+	}()
+	h.run()
+	return false
+}
+
+// retire stops the hub after it was replaced; a loop that is still stalled exits once it resumes
+func (h *Hub) retire() {
+	h.retireOnce.Do(func() { close(h.done) })
+}
+
+// retired reports whether the hub was replaced
+func (h *Hub) retired() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// successor returns a hub to replace this stalled one, starting from its last checkpoint so sequence numbers
+// continue and resuming clients get what they missed; the connected clients and counters carry over
+func (h *Hub) successor() *Hub {
+	next := newHub(0, h.ackTimeout)
+	next.recentSize = h.recentSize
+	next.health = h.health
+	next.live = h.live
+	next.redeliveries.Store(h.redeliveries.Load())
+	next.undelivered.Store(h.undelivered.Load())
+	if checkpoint := h.checkpoint.Load(); checkpoint != nil {
+		next.seq = checkpoint.seq
+		next.last = checkpoint.last
+		next.recent = append([]*broadcastPayload(nil), checkpoint.recent...)
+		next.checkpoint.Store(checkpoint)
+	}
+	return next
+}
+
+// adopt moves the connected clients of a retired hub to this one before its event loop starts
+// The retired loop no longer sends to or closes them, since each send checks the client's owner
+func (h *Hub) adopt(retired *Hub) int {
+	moved := 0
+	h.live.Range(func(key, _ interface{}) bool {
+		client := key.(*Client)
+		client.mu.Lock()
+		if client.owner == retired && !client.closed {
+			client.owner = h
+			h.clients[client] = true
+			moved++
+		}
+		client.mu.Unlock()
+		return true
+	})
+	return moved
+}
+
+// replaceHub replaces a stalled hub with a new one and moves its clients over
+// A goroutine can't be stopped, so the stalled loop is left to exit when it resumes
+func (s *Server) replaceHub(stalled *Hub) {
+	next := stalled.successor()
+	if !s.hub.CompareAndSwap(stalled, next) {
+		return
+	}
+	stalled.retire()
+	moved := next.adopt(stalled)
+	next.health.recordRestart()
+	go next.supervise(s.config.HubAutoRestart)
+	logging.Printf("WebSocket hub stalled; replaced it and moved %d clients to the new hub", moved)
+}
+
+// currentHub returns the hub clients register with and snapshots are published to
+func (s *Server) currentHub() *Hub {
+	return s.hub.Load()
+}
+
+// join registers a client with the current hub, following a replacement that happens meanwhile
+func (s *Server) join(client *Client) {
+	for {
+		hub := s.currentHub()
+		client.mu.Lock()
+		client.owner = hub
+		client.mu.Unlock()
+		select {
+		case hub.register <- client:
+			return
+		case <-hub.done:
+		}
+	}
+}
+
+// leave unregisters the client from its hub; a retired hub is skipped, following the client to its new hub
+func (c *Client) leave() {
+	for {
+		c.mu.Lock()
+		hub := c.owner
+		c.mu.Unlock()
+		select {
+		case hub.unregister <- c:
+			return
+		case <-hub.done:
+		}
+		c.mu.Lock()
+		moved := c.owner != hub
+		c.mu.Unlock()
+		if !moved {
+			return
+		}
+	}
+}
+
+// responsive reports whether the event loop accepts a probe within timeout
+func (h *Hub) responsive(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case h.probe <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// watchHub probes the hub event loop every interval until ctx is cancelled
+func (s *Server) watchHub(ctx context.Context) {
+	interval := s.config.HubWatchdogInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		hub := s.currentHub()
+		ok := hub.responsive(interval)
+		if !ok {
+			logging.Printf("WebSocket hub did not respond within %s; live updates are stalled", interval)
+			if s.config.HubAutoRestart {
+				s.replaceHub(hub)
+			}
+		}
+		hub.health.recordProbe(ok)
+	}
+}
+
+// livezHandler reports whether the process is alive, including the WebSocket hub event loop
+// Responds 503 when the hub has stalled so the kubelet restarts the pod
+func (s *Server) livezHandler(c *gin.Context) {
+	hub := s.currentHub().health.status(3 * s.config.HubWatchdogInterval)
+	code := http.StatusOK
+	if !hub.Alive {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"alive": hub.Alive,
+		"hub":   hub,
+	})
+}
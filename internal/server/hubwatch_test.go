@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/reader"
+)
+
+// newTestClient returns a client without a connection whose messages stay in its send buffer
+func newTestClient() *Client {
+	return &Client{
+		send:        make(chan []byte, clientSendBuffer),
+		access:      clientAccess{identity: "anonymous", namespaces: []string{"*"}},
+		encoding:    encodingJSON,
+		resumeSeq:   -1,
+		resumeAcked: -1,
+	}
+}
+
+// nextMessage returns the type and seq of the client's next message, failing when none arrives in time
+func nextMessage(t *testing.T, client *Client) (string, int64) {
+	t.Helper()
+	select {
+	case data := <-client.send:
+		var message struct {
+			Type string `json:"type"`
+			Seq  int64  `json:"seq"`
+		}
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("message is not JSON: %v", err)
+		}
+		return message.Type, message.Seq
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message arrived")
+		return "", 0
+	}
+}
+
+func snapshotPayload() *broadcastPayload {
+	return &broadcastPayload{
+		namespace: "apps",
+		secrets:   []reader.SecretInfo{{Name: "db", Found: true}},
+		fields:    map[string]interface{}{"type": messageTypeSecrets},
+	}
+}
+
+func TestReplaceStalledHub(t *testing.T) {
+	s := &Server{config: &config.Config{HubAutoRestart: true}}
+	stalled := newHub(8, 0)
+	s.hub.Store(stalled)
+	go stalled.supervise(true)
+
+	client := newTestClient()
+	stalled.live.Store(client, struct{}{})
+	s.join(client)
+	if kind, _ := nextMessage(t, client); kind != messageTypeSession {
+		t.Fatalf("first message is %q, want the session", kind)
+	}
+	if !stalled.publish(snapshotPayload()) {
+		t.Fatal("hub did not accept the first snapshot")
+	}
+	if _, seq := nextMessage(t, client); seq != 1 {
+		t.Fatalf("first snapshot has seq %d, want 1", seq)
+	}
+
+	// Block the event loop on a client whose lock is held, as a stuck render or send would
+	blocker := newTestClient()
+	s.join(blocker)
+	nextMessage(t, blocker)
+	nextMessage(t, blocker)
+	blocker.mu.Lock()
+	if !stalled.publish(snapshotPayload()) {
+		t.Fatal("hub did not accept the second snapshot")
+	}
+	if stalled.responsive(50 * time.Millisecond) {
+		t.Fatal("blocked hub answered the watchdog")
+	}
+
+	s.replaceHub(stalled)
+	replacement := s.currentHub()
+	if replacement == stalled || !stalled.retired() {
+		t.Fatal("stalled hub was not replaced")
+	}
+	if !replacement.responsive(time.Second) {
+		t.Fatal("replacement hub does not answer the watchdog")
+	}
+	client.mu.Lock()
+	owner := client.owner
+	client.mu.Unlock()
+	if owner != replacement {
+		t.Fatal("client was not moved to the replacement hub")
+	}
+
+	// The stalled loop may or may not have reached the client before blocking; drain that snapshot
+	for len(client.send) > 0 {
+		<-client.send
+	}
+	if !replacement.publish(snapshotPayload()) {
+		t.Fatal("replacement hub did not accept a snapshot")
+	}
+	if _, seq := nextMessage(t, client); seq != 3 {
+		t.Errorf("snapshot from the replacement has seq %d, want 3 continuing the stalled hub", seq)
+	}
+
+	// Once it resumes, the stalled loop exits without touching the moved client
+	blocker.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if len(client.send) != 0 {
+		t.Error("the stalled loop sent to a client that moved")
+	}
+	if replacement.health.status(time.Minute).Restarts != 1 {
+		t.Error("the replacement was not counted as a restart")
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hashSecretValues returns a copy of the secrets with every value replaced by its keyed hash, so clients
+// can't check guessed values against them
+func hashSecretValues(secrets []reader.SecretInfo) []reader.SecretInfo {
+	hashed := make([]reader.SecretInfo, len(secrets))
+	for i, secret := range secrets {
+		keys := make(map[string]string, len(secret.Keys))
+		for key, value := range secret.Keys {
+			keys[key] = reader.HashValue(value)
+		}
+		secret.Keys = keys
+		hashed[i] = secret
+	}
+	return hashed
+}
+
+// wipeSecretValues drops decoded values so they are not reachable after the request
+func wipeSecretValues(secrets []reader.SecretInfo) {
+	for i := range secrets {
+		clear(secrets[i].Keys)
+		secrets[i].Expanded = nil
+	}
+}
+
+// respondWithSecrets writes a JSON response containing decoded secret values
+// In memory hygiene mode the serialized buffer is zeroed and the values dropped once written
+func (s *Server) respondWithSecrets(c *gin.Context, status int, body gin.H, secrets []reader.SecretInfo) {
+	if !s.config.MemoryHygiene {
+		c.JSON(status, body)
+		return
+	}
+
+	data, err := json.Marshal(body)
+	wipeSecretValues(secrets)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.Data(status, "application/json; charset=utf-8", data)
+	clear(data)
+}
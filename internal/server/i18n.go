@@ -0,0 +1,38 @@
+package server
+
+import (
+	"bitwarden-reader/internal/i18n"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// languageKey is the gin context key of the request's negotiated language
+const languageKey = "language"
+
+// loadLocales loads the message catalogs, keeping the built-in ones when LOCALES_DIR can't be read
+func loadLocales(lang, dir string) *i18n.Catalogs {
+	locales, err := i18n.Load(lang, dir)
+	if err != nil {
+		logging.Printf("Error loading message catalogs: %v", err)
+	}
+	logging.Printf("Message catalogs: %v, default %s", locales.Languages(), locales.Default())
+	return locales
+}
+
+// negotiateLanguage picks the request's language from ?lang=, else Accept-Language, else LANG
+func (s *Server) negotiateLanguage(c *gin.Context) {
+	lang := s.locales.Match(c.Query("lang"))
+	if lang == "" {
+		lang = s.locales.Negotiate(c.GetHeader("Accept-Language"))
+	}
+	c.Set(languageKey, lang)
+	c.Header("Content-Language", lang)
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	c.Next()
+}
+
+// tr translates a user-facing message into the request's language, formatting it with args when given
+func (s *Server) tr(c *gin.Context, message string, args ...interface{}) string {
+	return s.locales.Translate(c.GetString(languageKey), message, args...)
+}
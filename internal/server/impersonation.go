@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The user and group Kubernetes itself assigns to unauthenticated requests
+const (
+	anonymousUser  = "system:anonymous"
+	anonymousGroup = "system:unauthenticated"
+)
+
+// clientsContextKey carries a request's impersonating clients in its context
+type clientsContextKey struct{}
+
+// impersonationCache keeps one set of impersonating clients per Kubernetes user and groups
+type impersonationCache struct {
+	mu      sync.Mutex
+	clients map[string]*k8s.K8sClients
+}
+
+// get returns cached clients for the user and groups, deriving them from base on first use
+func (ic *impersonationCache) get(base *k8s.K8sClients, user string, groups []string) (*k8s.K8sClients, error) {
+	key := user + "|" + strings.Join(groups, ",")
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	if clients, ok := ic.clients[key]; ok {
+		return clients, nil
+	}
+	clients, err := base.Impersonate(user, groups)
+	if err != nil {
+		return nil, err
+	}
+	if ic.clients == nil {
+		ic.clients = make(map[string]*k8s.K8sClients)
+	}
+	ic.clients[key] = clients
+	return clients, nil
+}
+
+// impersonationTarget maps a request identity to the Kubernetes user and groups to impersonate
+// Mapped identities use IMPERSONATION_USERS, others IMPERSONATION_USER_PREFIX plus the identity
+func (s *Server) impersonationTarget(identity string) (string, []string) {
+	if identity == "" {
+		return anonymousUser, []string{anonymousGroup}
+	}
+	user, ok := s.config.ImpersonationUsers[identity]
+	if !ok {
+		user = s.config.ImpersonationUserPrefix + identity
+	}
+	return user, s.config.ImpersonationGroups[identity]
+}
+
+// impersonate is middleware that attaches clients acting as the request identity to the request context,
+// so Kubernetes RBAC decides what each dashboard user may read or change
+func (s *Server) impersonate(c *gin.Context) {
+	user, groups := s.impersonationTarget(c.GetString(identityKey))
+	clients, err := s.impersonation.get(s.k8sClients, user, groups)
+	if err != nil {
+		logging.Printf("Error creating impersonating clients for %s: %v", user, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to impersonate Kubernetes user %s", user),
+		})
+		return
+	}
+
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), clientsContextKey{}, clients))
+	c.Next()
+}
+
+// clients returns the impersonating clients attached to ctx, or the service account's clients
+// Background loops have no request context, so they keep the service account's permissions
+func (s *Server) clients(ctx context.Context) *k8s.K8sClients {
+	if clients, ok := ctx.Value(clientsContextKey{}).(*k8s.K8sClients); ok {
+		return clients
+	}
+	return s.k8sClients
+}
+
+// clientsFor returns the clients for calls in namespace: the request's impersonating clients when set,
+// otherwise the namespace's own credentials from NAMESPACE_CREDENTIALS or the service account's clients
+func (s *Server) clientsFor(ctx context.Context, namespace string) *k8s.K8sClients {
+	return s.clients(ctx).ForNamespace(namespace)
+}
+
+// requestClients returns the clients to use for the request
+func (s *Server) requestClients(c *gin.Context) *k8s.K8sClients {
+	return s.clients(c.Request.Context())
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminPathPrefix is the admin API, guarded by ADMIN_IP_ALLOW and ADMIN_IP_DENY on top of the general lists,
+// and after authentication by ADMIN_IDENTITIES
+const adminPathPrefix = "/api/v1/admin/"
+
+// ipFilterExempt are the probes served to any address, since kubelet probes come from the node
+var ipFilterExempt = map[string]bool{
+	"/livez":  true,
+	"/readyz": true,
+}
+
+// ParseCIDRs parses addresses and CIDRs such as 10.0.0.0/8 or 192.168.1.10
+func ParseCIDRs(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, item := range list {
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", item)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", item)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ipList allows the addresses in allow, or all when allow is empty, unless they are in deny
+type ipList struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+	// closed denies every address, after a list failed to parse
+	closed bool
+}
+
+// newIPList parses an allow and a deny list; a list that fails to parse denies every address
+func newIPList(allowEnv string, allow []string, denyEnv string, deny []string) ipList {
+	allowed, err := ParseCIDRs(allow)
+	if err != nil {
+		logging.Printf("Error in %s, denying all addresses: %v", allowEnv, err)
+		return ipList{closed: true}
+	}
+	denied, err := ParseCIDRs(deny)
+	if err != nil {
+		logging.Printf("Error in %s, denying all addresses: %v", denyEnv, err)
+		return ipList{closed: true}
+	}
+	return ipList{allow: allowed, deny: denied}
+}
+
+// enabled reports whether the list restricts any address
+func (l ipList) enabled() bool {
+	return l.closed || len(l.allow) > 0 || len(l.deny) > 0
+}
+
+// permits reports whether the address may connect
+func (l ipList) permits(addr netip.Addr) bool {
+	if l.closed || !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range l.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(l.allow) == 0 {
+		return true
+	}
+	for _, prefix := range l.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipFilter enforces IP_ALLOW and IP_DENY on all requests, and ADMIN_IP_ALLOW and ADMIN_IP_DENY on the admin API
+type ipFilter struct {
+	all         ipList
+	admin       ipList
+	denied      atomic.Int64
+	adminDenied atomic.Int64
+}
+
+// newIPFilter creates the filter, or nil when no list is configured
+func newIPFilter(cfg *config.Config) *ipFilter {
+	f := &ipFilter{
+		all:   newIPList("IP_ALLOW", cfg.IPAllow, "IP_DENY", cfg.IPDeny),
+		admin: newIPList("ADMIN_IP_ALLOW", cfg.AdminIPAllow, "ADMIN_IP_DENY", cfg.AdminIPDeny),
+	}
+	if !f.all.enabled() && !f.admin.enabled() {
+		return nil
+	}
+	if len(cfg.TrustedProxies) == 0 {
+		logging.Printf("IP allow and deny lists check the connection's address; behind an ingress, set TRUSTED_PROXIES to its addresses")
+	}
+	return f
+}
+
+// setTrustedProxies limits whose X-Forwarded-For is taken as the client IP to TRUSTED_PROXIES
+// An empty or invalid list trusts no proxy, so clients can't choose their address
+func setTrustedProxies(router *gin.Engine, proxies []string) {
+	if len(proxies) == 0 {
+		_ = router.SetTrustedProxies(nil)
+		return
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		logging.Printf("Error in TRUSTED_PROXIES, trusting no proxy: %v", err)
+		_ = router.SetTrustedProxies(nil)
+	}
+}
+
+// filterIPs is middleware rejecting client IPs outside the allow lists or in the deny lists,
+// before authentication
+func (s *Server) filterIPs(c *gin.Context) {
+	path := c.Request.URL.Path
+	if ipFilterExempt[path] {
+		c.Next()
+		return
+	}
+	addr, _ := netip.ParseAddr(c.ClientIP())
+	addr = addr.Unmap()
+	if !s.ipFilter.all.permits(addr) {
+		s.ipFilter.denied.Add(1)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": s.tr(c, "Access from your address is not allowed")})
+		return
+	}
+	if strings.HasPrefix(path, adminPathPrefix) && !s.ipFilter.admin.permits(addr) {
+		s.ipFilter.adminDenied.Add(1)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": s.tr(c, "Access from your address is not allowed")})
+		return
+	}
+	c.Next()
+}
+
+// isAdmin reports whether an authenticated identity is in ADMIN_IDENTITIES; requests without an identity,
+// as with AUTH_METHODS=none, never are
+func (s *Server) isAdmin(identity string) bool {
+	if identity == "" {
+		return false
+	}
+	return slices.Contains(s.config.AdminIdentities, identity) || slices.Contains(s.config.AdminIdentities, "*")
+}
+
+// requireAdmin is the admin API's middleware rejecting identities that are not in ADMIN_IDENTITIES,
+// after the IP lists and authentication
+func (s *Server) requireAdmin(c *gin.Context) {
+	if !s.isAdmin(c.GetString(identityKey)) {
+		s.recordAudit(c, "admin.deny", c.Request.URL.Path, "", audit.OutcomeDenied, nil)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": s.tr(c, "Admin access required")})
+		return
+	}
+	c.Next()
+}
+
+// writeIPFilterMetrics writes how many requests the IP lists rejected
+func (s *Server) writeIPFilterMetrics(w io.Writer) {
+	if s.ipFilter == nil {
+		return
+	}
+	writeLabelledMetric(w, "bitwarden_reader_ip_denied_total", "counter", "Requests rejected by the IP allow and deny lists.", "list", map[string]float64{
+		"all":   float64(s.ipFilter.denied.Load()),
+		"admin": float64(s.ipFilter.adminDenied.Load()),
+	})
+}
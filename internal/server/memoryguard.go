@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+)
+
+// Memory caps, as reported in the cap label
+const (
+	memoryCapValue    = "value"
+	memoryCapTotal    = "total"
+	memoryCapSnapshot = "snapshot"
+)
+
+// memoryWarnInterval limits how often hitting a cap is logged
+const memoryWarnInterval = 5 * time.Minute
+
+// memoryGuard counts how often MAX_SECRET_VALUE_BYTES, MAX_SECRET_BYTES, and MAX_SNAPSHOT_BYTES
+// truncated secrets and snapshots
+type memoryGuard struct {
+	mu             sync.Mutex
+	heldBytes      int64
+	hits           map[string]int64
+	truncated      int64
+	truncatedBytes int64
+	warned         map[string]time.Time
+}
+
+// hit counts a cap being hit and reports whether it should be logged
+func (g *memoryGuard) hit(limit string, now time.Time) bool {
+	if g.hits == nil {
+		g.hits = make(map[string]int64)
+		g.warned = make(map[string]time.Time)
+	}
+	g.hits[limit]++
+	if now.Sub(g.warned[limit]) < memoryWarnInterval {
+		return false
+	}
+	g.warned[limit] = now
+	return true
+}
+
+// record stores the outcome of capping a read
+func (g *memoryGuard) record(result reader.Truncation, now time.Time) (warnValue, warnTotal bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.heldBytes = result.Held
+	g.truncated += int64(result.Values)
+	g.truncatedBytes += result.Bytes
+	if result.ValueCap {
+		warnValue = g.hit(memoryCapValue, now)
+	}
+	if result.TotalCap {
+		warnTotal = g.hit(memoryCapTotal, now)
+	}
+	return warnValue, warnTotal
+}
+
+// recordSnapshot counts a snapshot truncated by MAX_SNAPSHOT_BYTES
+func (g *memoryGuard) recordSnapshot(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.hit(memoryCapSnapshot, now)
+}
+
+// capSecretValues drops the values exceeding MAX_SECRET_VALUE_BYTES and MAX_SECRET_BYTES from secrets
+// read from namespace
+func (s *Server) capSecretValues(namespace string, secrets []reader.SecretInfo) {
+	budget := reader.ValueBudget{MaxValue: s.config.MaxSecretValueBytes, MaxTotal: s.config.MaxSecretBytes}
+	result := budget.Apply(secrets)
+	warnValue, warnTotal := s.memory.record(result, time.Now())
+	if warnValue {
+		logging.Printf("Dropped values larger than MAX_SECRET_VALUE_BYTES (%d) in namespace %s; see TruncatedKeys", s.config.MaxSecretValueBytes, namespace)
+	}
+	if warnTotal {
+		logging.Printf("Secrets in namespace %s exceed MAX_SECRET_BYTES (%d), dropped %d values (%d bytes), largest first", namespace, s.config.MaxSecretBytes, result.Values, result.Bytes)
+	}
+}
+
+// capSnapshot keeps a WebSocket snapshot within MAX_SNAPSHOT_BYTES: the values are dropped first,
+// keeping names and sync status, then secrets from the end of the list
+// The secrets passed in are left untouched, since they may be cached
+func (s *Server) capSnapshot(secrets []reader.SecretInfo, fields map[string]interface{}) []reader.SecretInfo {
+	limit := s.config.MaxSnapshotBytes
+	if limit <= 0 {
+		return secrets
+	}
+	data, err := json.Marshal(secrets)
+	if err != nil || len(data) <= limit {
+		return secrets
+	}
+
+	stripped := make([]reader.SecretInfo, len(secrets))
+	for i, secret := range secrets {
+		keys := make(map[string]string, len(secret.Keys))
+		truncated := append([]string(nil), secret.TruncatedKeys...)
+		for key, value := range secret.Keys {
+			keys[key] = ""
+			if value != "" && !secret.IsTruncated(key) {
+				truncated = append(truncated, key)
+			}
+		}
+		secret.Keys = keys
+		secret.TruncatedKeys = truncated
+		secret.Expanded = nil
+		stripped[i] = secret
+	}
+	fields["valuesOmitted"] = true
+
+	size := 2
+	kept := len(stripped)
+	for i, secret := range stripped {
+		encoded, err := json.Marshal(secret)
+		if err != nil {
+			continue
+		}
+		if size += len(encoded) + 1; size > limit {
+			kept = i
+			break
+		}
+	}
+	if kept < len(stripped) {
+		fields["omittedSecrets"] = len(stripped) - kept
+		stripped = stripped[:kept]
+	}
+
+	if s.memory.recordSnapshot(time.Now()) {
+		logging.Printf("Snapshot of %d bytes exceeds MAX_SNAPSHOT_BYTES (%d), sent without values and %d of %d secrets", len(data), limit, kept, len(secrets))
+	}
+	return stripped
+}
+
+// writeMemoryMetrics writes the held value bytes and how often the memory caps were hit
+func (s *Server) writeMemoryMetrics(w io.Writer) {
+	s.memory.mu.Lock()
+	held, truncated, truncatedBytes := s.memory.heldBytes, s.memory.truncated, s.memory.truncatedBytes
+	hits := map[string]float64{memoryCapValue: 0, memoryCapTotal: 0, memoryCapSnapshot: 0}
+	for limit, count := range s.memory.hits {
+		hits[limit] = float64(count)
+	}
+	s.memory.mu.Unlock()
+
+	writeMetric(w, "bitwarden_reader_secret_value_bytes", "gauge", "Decoded secret value bytes kept from the last read.", float64(held))
+	writeLabelledMetric(w, "bitwarden_reader_memory_cap_hits_total", "counter", "Reads and snapshots truncated by a memory cap.", "cap", hits)
+	writeMetric(w, "bitwarden_reader_truncated_values_total", "counter", "Secret values dropped by MAX_SECRET_VALUE_BYTES or MAX_SECRET_BYTES.", float64(truncated))
+	writeMetric(w, "bitwarden_reader_truncated_value_bytes_total", "counter", "Bytes of secret values dropped by MAX_SECRET_VALUE_BYTES or MAX_SECRET_BYTES.", float64(truncatedBytes))
+}
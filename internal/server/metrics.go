@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"bitwarden-reader/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsContentType is the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// writeMetric writes one metric family with a single unlabelled sample
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+}
+
+// writeLabelledMetric writes the header of a metric family followed by one sample per label value
+func writeLabelledMetric(w io.Writer, name, kind, help, label string, values map[string]float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	labelValues := make([]string, 0, len(values))
+	for labelValue := range values {
+		labelValues = append(labelValues, labelValue)
+	}
+	sort.Strings(labelValues)
+	for _, labelValue := range labelValues {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %g\n", name, label, labelEscaper.Replace(labelValue), values[labelValue])
+	}
+}
+
+// writeLabelledSummary writes a summary family with only _sum and _count samples per label value
+func writeLabelledSummary(w io.Writer, name, help, label string, stats k8s.LatencyStats) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n", name, help, name)
+	labelValues := make([]string, 0, len(stats.Count))
+	for labelValue := range stats.Count {
+		labelValues = append(labelValues, labelValue)
+	}
+	sort.Strings(labelValues)
+	for _, labelValue := range labelValues {
+		escaped := labelEscaper.Replace(labelValue)
+		fmt.Fprintf(w, "%s_sum{%s=\"%s\"} %g\n", name, label, escaped, stats.Seconds[labelValue])
+		fmt.Fprintf(w, "%s_count{%s=\"%s\"} %g\n", name, label, escaped, stats.Count[labelValue])
+	}
+}
+
+// labelEscaper escapes label values as required by the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsHandler exposes server metrics in the Prometheus text format
+func (s *Server) metricsHandler(c *gin.Context) {
+	var b strings.Builder
+
+	total, clients := s.wsLimits.snapshot()
+	writeMetric(&b, "bitwarden_reader_websocket_connections", "gauge", "Open WebSocket connections.", float64(total))
+	perClient := make(map[string]float64, len(clients))
+	for _, client := range clients {
+		perClient[client.Client] = float64(client.Connections)
+	}
+	writeLabelledMetric(&b, "bitwarden_reader_websocket_client_connections", "gauge", "Open WebSocket connections per identity or IP.", "client", perClient)
+	writeMetric(&b, "bitwarden_reader_websocket_rejected_total", "counter", "WebSocket upgrades rejected by connection limits.", float64(s.wsLimits.rejectedCount()))
+	writeMetric(&b, "bitwarden_reader_websocket_redeliveries_total", "counter", "WebSocket events sent again because the client did not acknowledge them.", float64(s.currentHub().redeliveries.Load()))
+	writeMetric(&b, "bitwarden_reader_websocket_undelivered_events_total", "counter", "WebSocket events given up on without an acknowledgement.", float64(s.currentHub().undelivered.Load()))
+	s.writeAutoscalingMetrics(&b)
+	s.writeIPFilterMetrics(&b)
+	s.writeAPIVersionMetrics(&b)
+	s.writeChaosMetrics(&b)
+	s.writeSoakMetrics(&b)
+
+	hub := s.currentHub().health.status(3 * s.config.HubWatchdogInterval)
+	alive := 0.0
+	if hub.Alive {
+		alive = 1
+	}
+	writeMetric(&b, "bitwarden_reader_hub_alive", "gauge", "Whether the WebSocket hub event loop responds to the watchdog.", alive)
+	writeMetric(&b, "bitwarden_reader_hub_restarts_total", "counter", "WebSocket hub event loop restarts after a panic.", float64(hub.Restarts))
+
+	writeLabelledMetric(&b, "bitwarden_reader_store_size_bytes", "gauge", "Size of the persisted history and audit files.", "store", s.storeSizes())
+	removed, lastCompaction := s.retention.snapshot()
+	writeLabelledMetric(&b, "bitwarden_reader_store_compacted_records_total", "counter", "Records removed from persisted stores by retention.", "store", removed)
+	if !lastCompaction.IsZero() {
+		writeMetric(&b, "bitwarden_reader_store_last_compaction_timestamp_seconds", "gauge", "Unix time of the last retention and compaction run.", float64(lastCompaction.Unix()))
+	}
+	s.writeStoreWriterMetrics(&b)
+
+	s.writeAuditSinkMetrics(&b)
+
+	s.writeMemoryMetrics(&b)
+
+	if s.mqtt.Enabled() {
+		stats := s.mqtt.Stats()
+		connected := 0.0
+		if stats.Connected {
+			connected = 1
+		}
+		writeMetric(&b, "bitwarden_reader_mqtt_connected", "gauge", "Whether the MQTT publisher is connected to the broker.", connected)
+		writeMetric(&b, "bitwarden_reader_mqtt_topics", "gauge", "MQTT topics the publisher keeps a status message for.", float64(stats.Topics))
+		writeMetric(&b, "bitwarden_reader_mqtt_published_total", "counter", "MQTT messages published and acknowledged at the configured QoS.", float64(stats.Published))
+		writeMetric(&b, "bitwarden_reader_mqtt_errors_total", "counter", "MQTT connection and publish failures.", float64(stats.Errors))
+	}
+
+	if client, ok := k8s.ClientMetricsSnapshot(); ok {
+		writeLabelledMetric(&b, "bitwarden_reader_kube_requests_total", "counter", "Kubernetes API responses by status code.", "code", client.Results)
+		writeLabelledSummary(&b, "bitwarden_reader_kube_request_duration_seconds", "Kubernetes API request latency by verb, including rate limiter waits.", "verb", client.Requests)
+		writeLabelledSummary(&b, "bitwarden_reader_kube_rate_limiter_duration_seconds", "Time Kubernetes API requests waited for the client-side rate limiter, by verb.", "verb", client.RateLimiter)
+		writeMetric(&b, "bitwarden_reader_kube_throttled_requests_total", "counter", "Kubernetes API requests that waited at least KUBE_THROTTLE_WARNING_MS for the client-side rate limiter.", client.Throttled)
+	}
+
+	c.Data(http.StatusOK, metricsContentType, []byte(b.String()))
+}
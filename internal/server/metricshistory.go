@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"bitwarden-reader/internal/history"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Defaults for metric history queries: one day in 5-minute steps
+const (
+	defaultHistoryWindow = 24 * time.Hour
+	defaultHistoryStep   = 5 * time.Minute
+)
+
+// metricsHistoryHandler returns a downsampled time series of a secret's sync state for charts
+// Steps are aligned to multiples of the step so repeated queries return the same buckets
+func (s *Server) metricsHistoryHandler(c *gin.Context) {
+	secret := c.Query("secret")
+	if secret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "secret is required"})
+		return
+	}
+	if !slices.Contains(s.configuredSecretNames(), secret) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Secret is not configured: " + secret})
+		return
+	}
+
+	metric := c.DefaultQuery("metric", history.MetricSyncAge)
+	window, step := defaultHistoryWindow, defaultHistoryStep
+	for name, target := range map[string]*time.Duration{"window": &window, "step": &step} {
+		if value := c.Query(name); value != "" {
+			parsed, err := parseWindow(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + ": " + err.Error()})
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-window).Truncate(step)
+	points, err := history.Series(s.history.Observations(from)[secret], metric, from, to, step)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret": secret,
+		"metric": metric,
+		"from":   from.Format(time.RFC3339),
+		"to":     to.Format(time.RFC3339),
+		"step":   step.String(),
+		"points": points,
+	})
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/mqtt"
+	"bitwarden-reader/internal/reader"
+)
+
+// mqttSecretStatus is the message published for each secret; values and their hashes are never included
+type mqttSecretStatus struct {
+	Secret             string `json:"secret"`
+	Namespace          string `json:"namespace"`
+	Group              string `json:"group,omitempty"`
+	Found              bool   `json:"found"`
+	KeyCount           int    `json:"keyCount"`
+	SyncStatus         string `json:"syncStatus,omitempty"`
+	LastSuccessfulSync string `json:"lastSuccessfulSync,omitempty"`
+	Error              string `json:"error,omitempty"`
+	Timestamp          string `json:"timestamp"`
+}
+
+// mqttSummary is the message published for the namespace as a whole
+type mqttSummary struct {
+	Namespace  string `json:"namespace"`
+	Total      int    `json:"total"`
+	Found      int    `json:"found"`
+	Missing    int    `json:"missing"`
+	SyncFailed int    `json:"syncFailed"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// mqttState remembers the last message published per topic, without its timestamp, so only changes are sent
+type mqttState struct {
+	mu   sync.Mutex
+	last map[string]interface{}
+}
+
+// changed records message as the last one of topic and reports whether it differs from the previous one
+func (m *mqttState) changed(topic string, message interface{}) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		m.last = make(map[string]interface{})
+	}
+	if previous, ok := m.last[topic]; ok && previous == message {
+		return false
+	}
+	m.last[topic] = message
+	return true
+}
+
+// newMQTTPublisher creates the publisher for MQTT_BROKER_URL, or returns nil when it is unset or invalid
+func newMQTTPublisher(cfg *config.Config) *mqtt.Publisher {
+	if cfg.MQTTBrokerURL == "" {
+		return nil
+	}
+	for _, topic := range []struct{ env, value string }{
+		{"MQTT_TOPIC_TEMPLATE", cfg.MQTTTopicTemplate},
+		{"MQTT_SUMMARY_TOPIC", cfg.MQTTSummaryTopic},
+	} {
+		if err := mqtt.ValidateTopic(topic.value); err != nil {
+			logging.Printf("Error in %s, MQTT publishing is disabled: %v", topic.env, err)
+			return nil
+		}
+	}
+	clientID := "bitwarden-reader"
+	if cfg.PodName != "" {
+		clientID += "-" + cfg.PodName
+	}
+	publisher, err := mqtt.NewPublisher(mqtt.Options{
+		BrokerURL:    cfg.MQTTBrokerURL,
+		ClientID:     clientID,
+		Username:     cfg.MQTTUsername,
+		PasswordFile: cfg.MQTTPasswordFile,
+		QoS:          byte(cfg.MQTTQoS),
+		Retain:       cfg.MQTTRetain,
+	})
+	if err != nil {
+		logging.Printf("Error in MQTT settings, MQTT publishing is disabled: %v", err)
+		return nil
+	}
+	logging.Printf("Publishing secret status to MQTT broker %s", cfg.MQTTBrokerURL)
+	return publisher
+}
+
+// mqttTopic fills in a topic template
+func mqttTopic(template, namespace, secret string) string {
+	return strings.NewReplacer("{namespace}", namespace, "{secret}", secret).Replace(template)
+}
+
+// publishMQTTStatus publishes the status of every secret that changed, and the summary when it changed
+func (s *Server) publishMQTTStatus(secrets []reader.SecretInfo, now time.Time) {
+	if !s.mqtt.Enabled() {
+		return
+	}
+	namespace := s.config.PodNamespace
+	timestamp := now.UTC().Format(time.RFC3339)
+
+	summary := mqttSummary{Namespace: namespace, Total: len(secrets)}
+	for _, secret := range secrets {
+		status := mqttSecretStatus{
+			Secret:             secret.Name,
+			Namespace:          namespace,
+			Group:              secret.Group,
+			Found:              secret.Found,
+			KeyCount:           len(secret.Keys),
+			SyncStatus:         secret.SyncInfo.SyncStatus,
+			LastSuccessfulSync: secret.SyncInfo.LastSuccessfulSync,
+			Error:              secret.Error,
+		}
+		if secret.Found {
+			summary.Found++
+		} else {
+			summary.Missing++
+		}
+		if secret.SyncInfo.SyncStatus == "False" {
+			summary.SyncFailed++
+		}
+
+		topic := mqttTopic(s.config.MQTTTopicTemplate, namespace, secret.Name)
+		if s.mqttState.changed(topic, status) {
+			status.Timestamp = timestamp
+			s.sendMQTT(topic, status)
+		}
+	}
+
+	topic := mqttTopic(s.config.MQTTSummaryTopic, namespace, "")
+	if s.mqttState.changed(topic, summary) {
+		summary.Timestamp = timestamp
+		s.sendMQTT(topic, summary)
+	}
+}
+
+// sendMQTT hands a JSON message to the publisher
+func (s *Server) sendMQTT(topic string, message interface{}) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		logging.Printf("Error marshaling MQTT message for %s: %v", topic, err)
+		return
+	}
+	s.mqtt.Publish(topic, payload)
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// namespaceSecret summarizes an operator-managed secret without exposing its values
+type namespaceSecret struct {
+	Name      string   `json:"name"`
+	CRDName   string   `json:"crdName,omitempty"`
+	Group     string   `json:"group"`
+	Keys      []string `json:"keys"`
+	KeyCount  int      `json:"keyCount"`
+	SyncTime  string   `json:"syncTime,omitempty"`
+	CreatedAt string   `json:"createdAt"`
+	// Annotations are the Secret's annotations set by the operator
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// apiNamespacesHandler returns the namespaces the reader is allowed to browse
+func (s *Server) apiNamespacesHandler(c *gin.Context) {
+	namespaces, ok := s.browsableNamespaces(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"namespaces": namespaces,
+		"current":    s.config.PodNamespace,
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+}
+
+// browsableNamespaces returns ALLOWED_NAMESPACES, or the namespaces visible to the request identity when all
+// are allowed, or responds with the error
+func (s *Server) browsableNamespaces(c *gin.Context) ([]string, bool) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return nil, false
+	}
+
+	if !s.config.AllNamespacesAllowed() {
+		return s.config.AllowedNamespaces, true
+	}
+	namespaces, err := k8s.ListNamespaces(c.Request.Context(), s.requestClients(c).Clientset)
+	if err != nil {
+		c.JSON(statusForK8sError(err), gin.H{
+			"error": fmt.Sprintf("Error listing namespaces: %v", err),
+		})
+		return nil, false
+	}
+	return namespaces, true
+}
+
+// apiNamespaceSecretsHandler lists operator-managed secrets in a namespace
+func (s *Server) apiNamespaceSecretsHandler(c *gin.Context) {
+	namespace := c.Param("ns")
+	if !s.config.NamespaceAllowed(namespace) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Namespace '%s' is not in the allowed namespace list", namespace),
+		})
+		return
+	}
+	if !s.identityAllows(c, namespace) {
+		return
+	}
+
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	secrets, err := k8s.ListOperatorSecrets(c.Request.Context(), namespace, s.clientsFor(c.Request.Context(), namespace).Clientset)
+	if err != nil {
+		c.JSON(statusForK8sError(err), gin.H{
+			"error": fmt.Sprintf("Error listing secrets: %v", err),
+		})
+		return
+	}
+
+	items := make([]namespaceSecret, 0, len(secrets))
+	for i := range secrets {
+		secret := &secrets[i]
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		group := k8s.GetSecretGroup(secret)
+		if configured, ok := s.config.SecretGroups[secret.Name]; ok {
+			group = configured
+		}
+		if group == "" {
+			group = reader.UngroupedName
+		}
+
+		items = append(items, namespaceSecret{
+			Name:        secret.Name,
+			CRDName:     k8s.GetOwningCRDName(secret),
+			Group:       group,
+			Keys:        keys,
+			KeyCount:    len(keys),
+			SyncTime:    k8s.GetSecretSyncTime(secret),
+			CreatedAt:   secret.CreationTimestamp.Format(time.RFC3339),
+			Annotations: k8s.GetOperatorAnnotations(secret.Annotations),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"namespace": namespace,
+		"secrets":   items,
+		"total":     len(items),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// requestNamespace resolves ?namespace= for the secrets and trigger endpoints, defaulting to POD_NAMESPACE
+// Every namespace, the pod namespace included, must be in the caller's IDENTITY_NAMESPACES (or
+// ALLOWED_NAMESPACES without an entry). Another namespace must also be in ALLOWED_NAMESPACES, and the request's
+// clients must pass an access review of verb on resource there, so impersonated users are held to their own RBAC;
+// pod namespace reads already use those clients
+// When the namespace is refused the error response has been written and ok is false
+func (s *Server) requestNamespace(c *gin.Context, group, resource, verb string) (namespace string, ok bool) {
+	namespace, allowed := s.resolveNamespace(c.Query("namespace"))
+	if namespace == s.config.PodNamespace {
+		return namespace, s.identityAllows(c, namespace)
+	}
+
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return "", false
+	}
+	if len(validation.IsDNS1123Label(namespace)) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": s.tr(c, "Invalid namespace '%s'", namespace),
+		})
+		return "", false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Namespace '%s' is not in the allowed namespace list", namespace),
+		})
+		return "", false
+	}
+	if !s.identityAllows(c, namespace) {
+		return "", false
+	}
+	ctx := c.Request.Context()
+	if !s.canI(ctx, s.clientsFor(ctx, namespace), namespace, group, resource, verb) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Not permitted to %s %s in namespace '%s'", verb, resource, namespace),
+		})
+		return "", false
+	}
+	c.Set(namespaceKey, namespace)
+	return namespace, true
+}
+
+// readNamespaceSecrets reads SECRET_NAMES from namespace, which for any but the pod namespace means its
+// Kubernetes secrets alone: the additional sources, deletion and flapping history, and the change log
+// all belong to the pod namespace
+func (s *Server) readNamespaceSecrets(ctx context.Context, namespace string) ([]reader.SecretInfo, error) {
+	if namespace == s.config.PodNamespace {
+		return s.readSecrets(ctx)
+	}
+	source := reader.NewKubernetesSource(namespace, s.clientsFor(ctx, namespace))
+	secrets := reader.ReadFromSources(ctx, s.withChaos([]reader.SecretSource{source}), s.config.SecretNames)
+	reader.AssignGroups(secrets, s.config.SecretGroups)
+	s.processSecrets(ctx, namespace, secrets)
+	return secrets, nil
+}
+
+// withNamespaceStatus adds namespaceStatus to a response when the secrets' namespace is terminating or absent,
+// the one cause behind all their not-found errors
+func withNamespaceStatus(response gin.H, secrets []reader.SecretInfo) gin.H {
+	if issue := reader.NamespaceIssue(secrets); issue != nil {
+		response["namespaceStatus"] = issue
+	}
+	return response
+}
+
+// statusForK8sError maps Kubernetes API errors to HTTP status codes
+func statusForK8sError(err error) int {
+	switch {
+	case errors.IsForbidden(err):
+		return http.StatusForbidden
+	case errors.IsNotFound(err):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// identityAllows reports whether the request's identity may read secrets in the namespace, as WebSocket
+// snapshots are filtered; otherwise it aborts with 403
+func (s *Server) identityAllows(c *gin.Context, namespace string) bool {
+	identity := requestIdentity(c)
+	if !s.accessFor(identity).allows(namespace) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": s.tr(c, "Namespace '%s' is not allowed for %s", namespace, identity),
+		})
+		return false
+	}
+	return true
+}
+
+// requirePodNamespace is middleware for the routes serving the pod namespace's secrets, refusing
+// identities that may not read it
+func (s *Server) requirePodNamespace(c *gin.Context) {
+	if s.identityAllows(c, s.config.PodNamespace) {
+		c.Next()
+	}
+}
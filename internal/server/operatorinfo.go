@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// operatorAssumptions are what the reader expects of the operator and its CRD
+type operatorAssumptions struct {
+	APIVersion            string   `json:"apiVersion"`
+	StatusFields          []string `json:"statusFields"`
+	ConditionType         string   `json:"conditionType"`
+	TriggerAnnotationKeys []string `json:"triggerAnnotationKeys"`
+	SyncTimeAnnotations   []string `json:"syncTimeAnnotations"`
+}
+
+// operatorInfo is the detected operator and CRD, and where they don't match the reader's assumptions
+type operatorInfo struct {
+	Operator      *k8s.OperatorDeployment `json:"operator,omitempty"`
+	OperatorError string                  `json:"operatorError,omitempty"`
+	CRD           *k8s.CRDDefinition      `json:"crd,omitempty"`
+	CRDError      string                  `json:"crdError,omitempty"`
+	// ConditionTypes counts the BitwardenSecrets in POD_NAMESPACE reporting each condition type
+	ConditionTypes map[string]int      `json:"conditionTypes,omitempty"`
+	Assumptions    operatorAssumptions `json:"assumptions"`
+	ManualSync     manualSyncSupport   `json:"manualSync"`
+	Warnings       []string            `json:"warnings"`
+}
+
+// readerStatusFields are the BitwardenSecret status properties the reader takes the sync state from
+var readerStatusFields = []string{"conditions", "lastSuccessfulSyncTime"}
+
+// warn records an assumption the detected operator doesn't meet
+func (info *operatorInfo) warn(format string, args ...interface{}) {
+	info.Warnings = append(info.Warnings, fmt.Sprintf(format, args...))
+}
+
+// checkCRD warns when the version the reader uses isn't served, is deprecated, or lacks the status fields read from it
+func (info *operatorInfo) checkCRD() {
+	version := info.CRD.Version(k8s.BitwardenSecretGVR.Version)
+	if version == nil || !version.Served {
+		var served []string
+		for _, v := range info.CRD.Versions {
+			if v.Served {
+				served = append(served, v.Name)
+			}
+		}
+		info.warn("The reader uses %s, which the CRD does not serve (served: %s)", info.Assumptions.APIVersion, strings.Join(served, ", "))
+		return
+	}
+	if version.Deprecated {
+		info.warn("%s is deprecated by the installed CRD", info.Assumptions.APIVersion)
+	}
+	// A schema without status properties preserves unknown fields, so nothing can be concluded from it
+	if len(version.StatusFields) == 0 {
+		return
+	}
+	for _, field := range readerStatusFields {
+		if !slices.Contains(version.StatusFields, field) {
+			info.warn("The %s schema has no status.%s, which the reader reads the sync state from", version.Name, field)
+		}
+	}
+}
+
+// checkConditions warns when BitwardenSecrets report conditions, but none of the type the reader reads
+func (info *operatorInfo) checkConditions() {
+	if len(info.ConditionTypes) == 0 || info.ConditionTypes[k8s.SuccessfulSyncCondition] > 0 {
+		return
+	}
+	types := make([]string, 0, len(info.ConditionTypes))
+	for conditionType := range info.ConditionTypes {
+		types = append(types, conditionType)
+	}
+	sort.Strings(types)
+	info.warn("BitwardenSecrets report the condition types %s but not %s, so sync status is not shown", strings.Join(types, ", "), k8s.SuccessfulSyncCondition)
+}
+
+// checkSyncTimes warns when found secrets carry none of the SYNC_TIME_ANNOTATIONS keys
+func (info *operatorInfo) checkSyncTimes(secrets []reader.SecretInfo) {
+	found := 0
+	for _, secret := range secrets {
+		if !secret.Found {
+			continue
+		}
+		if secret.SyncInfo.K8sSecretSyncTime != "" {
+			return
+		}
+		found++
+	}
+	if found > 0 {
+		info.warn("None of the %d secrets found has a %s annotation; set SYNC_TIME_ANNOTATIONS to the key this operator version writes", found, strings.Join(info.Assumptions.SyncTimeAnnotations, " or "))
+	}
+}
+
+// buildOperatorInfo reads the operator Deployment, the CRD, and the BitwardenSecrets, and compares them with the reader's assumptions
+// The Deployment and CRD are cluster facts read with the reader's own service account
+func (s *Server) buildOperatorInfo(ctx context.Context) operatorInfo {
+	info := operatorInfo{
+		Assumptions: operatorAssumptions{
+			APIVersion:            k8s.BitwardenSecretGVR.GroupVersion().String(),
+			StatusFields:          readerStatusFields,
+			ConditionType:         k8s.SuccessfulSyncCondition,
+			TriggerAnnotationKeys: s.config.TriggerAnnotationKeys,
+			SyncTimeAnnotations:   s.config.SyncTimeAnnotations,
+		},
+		Warnings: []string{},
+	}
+	if len(info.Assumptions.TriggerAnnotationKeys) == 0 {
+		info.Assumptions.TriggerAnnotationKeys = []string{k8s.ForceSyncAnnotation}
+	}
+
+	deployment, err := s.operatorProbe.get(ctx, s.k8sClients, s.config.OperatorNamespace, s.config.OperatorDeployment, time.Now())
+	if err != nil {
+		info.OperatorError = err.Error()
+	} else {
+		info.Operator = deployment
+		if deployment.Version == "" {
+			info.warn("Operator image %s has no version tag, so version-specific behavior can't be checked", deployment.Image)
+		}
+		if deployment.ReadyReplicas == 0 {
+			info.warn("Operator deployment %s/%s has no ready replicas, so nothing will sync", deployment.Namespace, deployment.Name)
+		}
+	}
+
+	if definition, err := k8s.GetBitwardenSecretDefinition(ctx, s.k8sClients.DynamicClient); err != nil {
+		info.CRDError = err.Error()
+	} else {
+		info.CRD = definition
+		info.checkCRD()
+	}
+
+	if types, err := k8s.ConditionTypes(ctx, s.config.PodNamespace, s.clientsFor(ctx, s.config.PodNamespace).DynamicClient); err == nil {
+		info.ConditionTypes = types
+		info.checkConditions()
+	}
+
+	if secrets, err := s.readSecrets(ctx); err == nil {
+		info.checkSyncTimes(secrets)
+		wipeSecretValues(secrets)
+	}
+
+	info.ManualSync = s.manualSyncSupport(ctx)
+	if info.ManualSync.Supported == manualSyncNo {
+		info.warn("Triggers set %s, which this operator does not act on: %s", strings.Join(info.Assumptions.TriggerAnnotationKeys, ", "), info.ManualSync.Detail)
+	}
+	return info
+}
+
+// operatorInfoHandler reports the operator version, the CRD's served and stored versions, and mismatched assumptions
+func (s *Server) operatorInfoHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, s.buildOperatorInfo(c.Request.Context()))
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"bitwarden-reader/internal/i18n"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageTemplates is where the dashboard templates are loaded from
+const pageTemplates = "web/templates/*"
+
+// fallbackPage is the built-in status page served when the dashboard templates are disabled, missing, or fail to render
+// It never shows secret values
+var fallbackPage = template.Must(template.New("fallback").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>{{.AppTitle}} - {{.AppVersion}}</title>
+  <style>
+    body { font-family: sans-serif; margin: 2rem; color: #222; }
+    table { border-collapse: collapse; margin: 1rem 0; }
+    th, td { border: 1px solid #ccc; padding: 0.3rem 0.8rem; text-align: left; }
+    .notice { color: #8a5300; }
+    .error { color: #b00020; }
+    .banner { background: #c62828; color: white; font-weight: bold; text-align: center; padding: 0.4rem; }
+  </style>
+</head>
+<body>
+  {{with .Theme}}{{with .Banner}}<p class="banner"{{if .Color}} style="background: {{.Color}}"{{end}}>{{.Text}}</p>{{end}}{{end}}
+  <h1>{{.AppTitle}}</h1>
+  <p>Version {{.AppVersion}}{{if .PodName}} &middot; Pod {{.PodName}}{{end}}{{if .Namespace}} &middot; Namespace {{.Namespace}}{{end}}</p>
+  {{if .Notice}}<p class="notice">{{.Notice}}</p>{{end}}
+  {{if .Error}}<p class="error">Error: {{.Error}}</p>{{end}}
+  {{if .Secrets}}
+  <p>{{.TotalSecrets}} of {{len .Secrets}} secrets found</p>
+  <table>
+    <tr><th>Secret</th><th>Found</th><th>Sync status</th><th>Last successful sync</th></tr>
+    {{range .Secrets}}<tr><td>{{.Name}}</td><td>{{if .Found}}yes{{else}}no{{end}}</td><td>{{.SyncInfo.SyncStatus}}</td><td>{{.SyncInfo.LastSuccessfulSync}}</td></tr>
+    {{end}}
+  </table>
+  {{end}}
+  <p>API: <a href="/api/v1/secrets">/api/v1/secrets</a> &middot; <a href="/api/v1/health">/api/v1/health</a> &middot; <a href="/api/v1/preflight">/api/v1/preflight</a></p>
+</body>
+</html>
+`))
+
+// loadPages parses the dashboard templates, returning nil when the UI is disabled or they can't be loaded
+// A broken or missing template directory is logged instead of stopping the server, since the API works without it
+// Templates translate text with {{t .Lang "message" args...}}
+func loadPages(enabled bool, locales *i18n.Catalogs) *template.Template {
+	if !enabled {
+		logging.Printf("Web UI disabled, / serves the built-in status page")
+		return nil
+	}
+	pages, err := template.New("").Funcs(template.FuncMap{"t": locales.Translate}).ParseGlob(pageTemplates)
+	if err != nil {
+		logging.Printf("Error loading web templates from %s, / serves the built-in status page: %v", pageTemplates, err)
+		return nil
+	}
+	return pages
+}
+
+// renderPage renders a dashboard template into a buffer first, so a failure can still be answered cleanly:
+// JSON clients get an error, browsers the built-in status page
+func (s *Server) renderPage(c *gin.Context, code int, name string, data gin.H) {
+	wantsJSON := c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON
+	notice := ""
+	if s.pages != nil {
+		var buf bytes.Buffer
+		err := s.pages.ExecuteTemplate(&buf, name, data)
+		if err == nil {
+			c.Data(code, "text/html; charset=utf-8", buf.Bytes())
+		}
+		// The page may hold secret values
+		clear(buf.Bytes())
+		if err == nil {
+			return
+		}
+		logging.Printf("Error rendering template %s: %v", name, err)
+		if wantsJSON {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to render page " + name,
+			})
+			return
+		}
+		code, notice = http.StatusInternalServerError, "The dashboard page failed to render; this is the built-in status page."
+	} else if s.config.UIEnabled {
+		if wantsJSON {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": s.tr(c, "Web UI templates are unavailable"),
+			})
+			return
+		}
+		notice = "The dashboard templates could not be loaded; this is the built-in status page."
+	}
+	renderFallback(c, code, notice, data)
+}
+
+// renderFallback renders the built-in status page from the page data
+func renderFallback(c *gin.Context, code int, notice string, data gin.H) {
+	fallback := gin.H{"Notice": notice}
+	for _, key := range []string{"AppTitle", "AppVersion", "PodName", "Namespace", "Error", "Secrets", "TotalSecrets", "Theme"} {
+		fallback[key] = data[key]
+	}
+	var buf bytes.Buffer
+	if err := fallbackPage.Execute(&buf, fallback); err != nil {
+		logging.Printf("Error rendering built-in status page: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to render page")
+		return
+	}
+	c.Data(code, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// changeTracker remembers the hash of the last observed secrets payload and when it changed
+type changeTracker struct {
+	mu        sync.Mutex
+	hash      string
+	changedAt time.Time
+}
+
+// observe records a payload hash and returns the time it last changed
+func (t *changeTracker) observe(hash string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if hash != t.hash {
+		t.hash = hash
+		t.changedAt = time.Now()
+	}
+	return t.changedAt
+}
+
+// hashSecrets computes a stable hash of the secrets payload
+func hashSecrets(secrets []reader.SecretInfo) string {
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseSince interprets the since parameter as a timestamp (RFC3339 with optional fractional seconds, or unix seconds)
+// Returns the zero time if the value is not a timestamp (treated as a hash)
+func parseSince(since string) time.Time {
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t
+	}
+	if unix, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return time.Unix(unix, 0)
+	}
+	return time.Time{}
+}
+
+// hasChanged reports whether the payload differs from the client's since value
+// A timestamp with whole seconds is compared at second precision, so a changedAt echoed back from
+// an RFC3339 or unix-seconds client doesn't count as a change within the same second
+func hasChanged(since, hash string, changedAt time.Time) bool {
+	if since == "" {
+		return true
+	}
+	if sinceTime := parseSince(since); !sinceTime.IsZero() {
+		if sinceTime.Nanosecond() == 0 {
+			changedAt = changedAt.Truncate(time.Second)
+		}
+		return changedAt.After(sinceTime)
+	}
+	return since != hash
+}
+
+// apiSecretsPollHandler blocks until the secrets payload changes or the timeout elapses
+// Intended as a fallback for networks that strip WebSockets and SSE
+func (s *Server) apiSecretsPollHandler(c *gin.Context) {
+	since := c.Query("since")
+
+	timeout := s.config.LongPollTimeout
+	if timeoutStr := c.Query("timeout"); timeoutStr != "" {
+		if seconds, err := strconv.Atoi(timeoutStr); err == nil && seconds > 0 {
+			requested := time.Duration(seconds) * time.Second
+			if requested < timeout {
+				timeout = requested
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	interval := s.config.DashboardRefreshInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		secrets, err := s.readSecrets(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		hash := hashSecrets(secrets)
+		changedAt := s.changes.observe(hash)
+
+		if hasChanged(since, hash, changedAt) {
+			c.Header("ETag", hash)
+			setReturnedSecrets(c, secrets)
+			if c.Query("expand") == "true" {
+				reader.ExpandValues(secrets)
+			}
+			s.respondWithSecrets(c, http.StatusOK, gin.H{
+				"secrets":    secrets,
+				"namespace":  s.config.PodNamespace,
+				"totalFound": countFoundSecrets(secrets),
+				"hash":       hash,
+				"changedAt":  changedAt.Format(time.RFC3339Nano),
+				"timestamp":  time.Now().Format(time.RFC3339),
+			}, secrets)
+			return
+		}
+		if s.config.MemoryHygiene {
+			wipeSecretValues(secrets)
+		}
+
+		select {
+		case <-ctx.Done():
+			c.Header("ETag", hash)
+			c.Status(http.StatusNotModified)
+			return
+		case <-ticker.C:
+		}
+	}
+}
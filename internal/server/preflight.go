@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// preflightTimeout bounds one preflight run
+const preflightTimeout = 30 * time.Second
+
+// preflightReport is the result of a preflight run
+type preflightReport struct {
+	Checks   []k8s.PreflightCheck `json:"checks"`
+	OK       bool                 `json:"ok"`
+	Failed   int                  `json:"failed"`
+	Warnings int                  `json:"warnings"`
+	Time     time.Time            `json:"time"`
+}
+
+// preflightState keeps the latest preflight report
+type preflightState struct {
+	mu     sync.Mutex
+	report *preflightReport
+}
+
+func (p *preflightState) set(report *preflightReport) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.report = report
+}
+
+func (p *preflightState) get() *preflightReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.report
+}
+
+// preflightNamespaces returns POD_NAMESPACE and the explicitly allowed namespaces
+func (s *Server) preflightNamespaces() []string {
+	seen := make(map[string]bool)
+	var namespaces []string
+	for _, namespace := range append([]string{s.config.PodNamespace}, s.config.AllowedNamespaces...) {
+		if namespace == "" || namespace == "*" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces
+}
+
+// runPreflight checks what the reader's configuration needs from the cluster with the service account's clients
+func (s *Server) runPreflight(ctx context.Context) *preflightReport {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	report := &preflightReport{Time: time.Now().UTC()}
+	if s.k8sClients == nil {
+		report.Checks = []k8s.PreflightCheck{{
+			Check:  "kubernetes",
+			Status: k8s.PreflightSkipped,
+			Detail: "no Kubernetes config found, running in standalone mode",
+		}}
+	} else {
+		report.Checks = k8s.Preflight(ctx, s.k8sClients, k8s.PreflightOptions{
+			Namespaces:      s.preflightNamespaces(),
+			SecretNamespace: s.config.PodNamespace,
+			Secrets:         s.config.SecretNames,
+			ListNamespaces:  s.config.AllNamespacesAllowed(),
+			Watch:           s.informersEnabled(),
+			Write:           s.writeEnabled(),
+			ReadOnly:        s.config.ReadOnly,
+		})
+	}
+	for _, check := range report.Checks {
+		switch check.Status {
+		case k8s.PreflightFail:
+			report.Failed++
+		case k8s.PreflightWarn:
+			report.Warnings++
+		}
+	}
+	report.OK = report.Failed == 0
+	s.preflight.set(report)
+	return report
+}
+
+// logPreflight prints the report as a table
+func logPreflight(report *preflightReport) {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tCHECK\tNAMESPACE\tTARGET\tDETAIL")
+	for _, check := range report.Checks {
+		detail := check.Detail
+		if check.Fix != "" {
+			detail += " -> " + check.Fix
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", strings.ToUpper(check.Status), check.Check, check.Namespace, check.Target, detail)
+	}
+	w.Flush()
+	logging.Printf("Preflight: %d failed, %d warnings\n%s", report.Failed, report.Warnings, b.String())
+}
+
+// preflightHandler returns the latest preflight report; ?refresh=true runs it again first
+func (s *Server) preflightHandler(c *gin.Context) {
+	report := s.preflight.get()
+	if c.Query("refresh") == "true" {
+		report = s.runPreflight(c.Request.Context())
+	}
+	if report == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Preflight has not finished yet"),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net/http"
+
+	"bitwarden-reader/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rejectReadOnly returns middleware refusing a mutating endpoint when READ_ONLY is set,
+// recording the attempt as action in the audit log
+func (s *Server) rejectReadOnly(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.config.ReadOnly {
+			c.Next()
+			return
+		}
+		s.recordAudit(c, action, c.Param("name"), "", audit.OutcomeDenied, map[string]string{
+			"reason": "read-only mode",
+		})
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":    s.tr(c, "The reader is in read-only mode (READ_ONLY=true) - changes are disabled"),
+			"readOnly": true,
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/writebehind"
+)
+
+// Persisted stores covered by retention
+const (
+	storeHistory = "history"
+	storeAudit   = "audit"
+)
+
+// retentionStats counts what compaction removed, for metrics
+type retentionStats struct {
+	mu      sync.Mutex
+	removed map[string]float64
+	lastRun time.Time
+}
+
+// record adds the records removed from a store
+func (r *retentionStats) record(store string, removed int, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.removed == nil {
+		r.removed = make(map[string]float64)
+	}
+	r.removed[store] += float64(removed)
+	r.lastRun = now
+}
+
+// snapshot returns the removed counts per store and when compaction last ran
+func (r *retentionStats) snapshot() (map[string]float64, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	removed := map[string]float64{storeHistory: 0, storeAudit: 0}
+	for store, count := range r.removed {
+		removed[store] = count
+	}
+	return removed, r.lastRun
+}
+
+// storeSizes returns the size in bytes of each persisted store
+func (s *Server) storeSizes() map[string]float64 {
+	return map[string]float64{
+		storeHistory: float64(s.history.FileSize()),
+		storeAudit:   float64(s.audit.FileSize()),
+	}
+}
+
+// writeStoreWriterMetrics writes the write-behind queue state of the history and audit files
+func (s *Server) writeStoreWriterMetrics(w io.Writer) {
+	stats := map[string]writebehind.Stats{
+		storeHistory: s.history.WriterStats(),
+		storeAudit:   s.audit.WriterStats(),
+	}
+	family := func(value func(writebehind.Stats) float64) map[string]float64 {
+		values := make(map[string]float64, len(stats))
+		for store, st := range stats {
+			values[store] = value(st)
+		}
+		return values
+	}
+	writeLabelledMetric(w, "bitwarden_reader_store_queue_length", "gauge", "Records waiting for the background writer of a persisted store.", "store",
+		family(func(st writebehind.Stats) float64 { return float64(st.Queued) }))
+	writeLabelledMetric(w, "bitwarden_reader_store_written_records_total", "counter", "Records written to a persisted store file.", "store",
+		family(func(st writebehind.Stats) float64 { return float64(st.Written) }))
+	writeLabelledMetric(w, "bitwarden_reader_store_queue_overflows_total", "counter", "Records that waited for room in a full write-behind queue, adding latency to their request.", "store",
+		family(func(st writebehind.Stats) float64 { return float64(st.Overflows) }))
+	writeLabelledMetric(w, "bitwarden_reader_store_write_errors_total", "counter", "Failed writes and fsyncs of a persisted store file.", "store",
+		family(func(st writebehind.Stats) float64 { return float64(st.Errors) }))
+	writeLabelledMetric(w, "bitwarden_reader_store_fsyncs_total", "counter", "Fsyncs of a persisted store file.", "store",
+		family(func(st writebehind.Stats) float64 { return float64(st.Syncs) }))
+}
+
+// compactStores applies HISTORY_RETENTION_DAYS and AUDIT_RETENTION_DAYS and rewrites the files
+func (s *Server) compactStores(now time.Time) {
+	var historyCutoff time.Time
+	if s.config.HistoryRetention > 0 {
+		historyCutoff = now.Add(-s.config.HistoryRetention)
+	}
+	before := s.history.FileSize()
+	removed, err := s.history.Compact(historyCutoff)
+	s.recordCompaction(storeHistory, removed, before, s.history.FileSize(), err, now)
+
+	if s.config.AuditRetention > 0 {
+		before := s.audit.FileSize()
+		removed, err := s.audit.Compact(now.Add(-s.config.AuditRetention))
+		s.recordCompaction(storeAudit, removed, before, s.audit.FileSize(), err, now)
+	}
+}
+
+// recordCompaction logs and audits one store's compaction when it changed anything
+func (s *Server) recordCompaction(store string, removed int, sizeBefore, sizeAfter int64, err error, now time.Time) {
+	s.retention.record(store, removed, now)
+	if err != nil {
+		logging.Printf("Error compacting %s store: %v", store, err)
+	} else if removed == 0 && sizeBefore == sizeAfter {
+		return
+	}
+
+	details := map[string]string{
+		"removed":    fmt.Sprint(removed),
+		"sizeBefore": fmt.Sprint(sizeBefore),
+		"sizeAfter":  fmt.Sprint(sizeAfter),
+	}
+	outcome := audit.OutcomeSuccess
+	if err != nil {
+		outcome = audit.OutcomeFailure
+		details["error"] = err.Error()
+	} else {
+		logging.Printf("Compacted %s store: removed %d records, %d -> %d bytes", store, removed, sizeBefore, sizeAfter)
+	}
+	s.audit.Record(audit.Event{
+		Action:   "store.compact",
+		Actor:    "system",
+		Resource: store,
+		Outcome:  outcome,
+		Details:  details,
+	})
+}
+
+// compactLoop runs retention and compaction every COMPACTION_INTERVAL_MINUTES until ctx is cancelled
+func (s *Server) compactLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CompactionInterval)
+	defer ticker.Stop()
+
+	for {
+		s.compactStores(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
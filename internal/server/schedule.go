@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+
+	"sigs.k8s.io/yaml"
+)
+
+// minRefreshInterval bounds how often the scheduler wakes up
+const minRefreshInterval = time.Second
+
+// refreshScheduleFile is the REFRESH_SCHEDULE_FILE format (YAML or JSON)
+// Durations use Go syntax, e.g. "10s" or "5m"
+type refreshScheduleFile struct {
+	Default    string            `json:"default,omitempty"`
+	Groups     map[string]string `json:"groups,omitempty"`
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+}
+
+// refreshSchedule resolves the refresh interval of a secret from its group or namespace
+type refreshSchedule struct {
+	defaultInterval time.Duration
+	groups          map[string]time.Duration
+	namespaces      map[string]time.Duration
+}
+
+// loadRefreshSchedule reads a refresh schedule; fallback is used when the file sets no default
+func loadRefreshSchedule(path string, fallback time.Duration) (*refreshSchedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refresh schedule: %w", err)
+	}
+	var file refreshScheduleFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse refresh schedule: %w", err)
+	}
+
+	schedule := &refreshSchedule{
+		defaultInterval: fallback,
+		groups:          make(map[string]time.Duration, len(file.Groups)),
+		namespaces:      make(map[string]time.Duration, len(file.Namespaces)),
+	}
+	if file.Default != "" {
+		if schedule.defaultInterval, err = parseRefreshInterval("default", file.Default); err != nil {
+			return nil, err
+		}
+	}
+	for group, value := range file.Groups {
+		if schedule.groups[group], err = parseRefreshInterval("group "+group, value); err != nil {
+			return nil, err
+		}
+	}
+	for namespace, value := range file.Namespaces {
+		if schedule.namespaces[namespace], err = parseRefreshInterval("namespace "+namespace, value); err != nil {
+			return nil, err
+		}
+	}
+	return schedule, nil
+}
+
+// parseRefreshInterval parses one interval, rejecting values below minRefreshInterval
+func parseRefreshInterval(name, value string) (time.Duration, error) {
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid refresh interval for %s: %w", name, err)
+	}
+	if interval < minRefreshInterval {
+		return 0, fmt.Errorf("refresh interval for %s must be at least %s", name, minRefreshInterval)
+	}
+	return interval, nil
+}
+
+// intervalFor returns the interval of a secret: its group's override, then its namespace's, then the default
+func (r *refreshSchedule) intervalFor(group, namespace string) time.Duration {
+	if interval, ok := r.groups[group]; ok {
+		return interval
+	}
+	if interval, ok := r.namespaces[namespace]; ok {
+		return interval
+	}
+	return r.defaultInterval
+}
+
+// tick returns how often the scheduler checks for due secrets: the shortest configured interval
+func (r *refreshSchedule) tick() time.Duration {
+	tick := r.defaultInterval
+	for _, interval := range r.groups {
+		tick = min(tick, interval)
+	}
+	for _, interval := range r.namespaces {
+		tick = min(tick, interval)
+	}
+	return max(tick, minRefreshInterval)
+}
+
+// cachedSecret is the last read of a secret from each source and when it is due again
+type cachedSecret struct {
+	name  string
+	infos []reader.SecretInfo
+	hash  string
+	next  time.Time
+}
+
+// secretCache holds the per-secret reads the scheduled broadcaster assembles snapshots from
+type secretCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedSecret
+}
+
+// due returns the names that were never read or whose interval elapsed
+func (c *secretCache) due(names []string, now time.Time) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var due []string
+	for _, name := range names {
+		if entry, ok := c.entries[name]; !ok || !now.Before(entry.next) {
+			due = append(due, name)
+		}
+	}
+	return due
+}
+
+// expire marks every cached secret as due
+func (c *secretCache) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// store records a read secret
+func (c *secretCache) store(entry cachedSecret) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedSecret)
+	}
+	c.entries[entry.name] = entry
+}
+
+// snapshot assembles the cached secrets in the given order with a combined hash
+func (c *secretCache) snapshot(names []string) ([]reader.SecretInfo, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	secrets := make([]reader.SecretInfo, 0, len(names))
+	sum := sha256.New()
+	for _, name := range names {
+		entry, ok := c.entries[name]
+		if !ok {
+			continue
+		}
+		secrets = append(secrets, entry.infos...)
+		sum.Write([]byte(name + "=" + entry.hash + "\n"))
+	}
+	return secrets, hex.EncodeToString(sum.Sum(nil))
+}
+
+// configuredSecretNames returns the secret names without blanks, as the reader sees them
+func (s *Server) configuredSecretNames() []string {
+	configured := s.secretNames()
+	names := make([]string, 0, len(configured))
+	for _, name := range configured {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// refreshScheduled re-reads the secrets whose interval elapsed and broadcasts the assembled snapshot
+func (s *Server) refreshScheduled(ctx context.Context) {
+	names := s.configuredSecretNames()
+	now := time.Now()
+	due := s.cache.due(names, now)
+	if len(due) == 0 {
+		return
+	}
+
+	secrets, err := s.readSecretNames(ctx, due)
+	if err != nil {
+		logging.Printf("Error reading secrets: %v", err)
+		return
+	}
+	// Secrets come back grouped by name, one entry per source
+	byName := make(map[string][]reader.SecretInfo, len(due))
+	for _, secret := range secrets {
+		byName[secret.Name] = append(byName[secret.Name], secret)
+	}
+	for _, name := range due {
+		infos, ok := byName[name]
+		if !ok {
+			continue
+		}
+		entry := cachedSecret{
+			name:  name,
+			infos: infos,
+			hash:  hashSecrets(infos),
+			next:  now.Add(s.schedule.intervalFor(infos[0].Group, s.config.PodNamespace)),
+		}
+		// Cached entries outlive the request, so in hygiene mode they only keep value hashes
+		if s.config.MemoryHygiene {
+			entry.infos = hashSecretValues(infos)
+		}
+		s.cache.store(entry)
+	}
+	if s.config.MemoryHygiene {
+		wipeSecretValues(secrets)
+	}
+
+	snapshot, hash := s.cache.snapshot(names)
+	s.publishSnapshot(snapshot, hash)
+}
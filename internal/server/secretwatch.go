@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+)
+
+// secretEvents wakes the loops that re-read secrets when a watched secret changes
+// Each channel holds at most one pending wakeup, so bursts of events coalesce into one re-read
+type secretEvents struct {
+	broadcast chan struct{}
+	hooks     chan struct{}
+}
+
+// newSecretEvents creates the wakeup channels
+func newSecretEvents() secretEvents {
+	return secretEvents{
+		broadcast: make(chan struct{}, 1),
+		hooks:     make(chan struct{}, 1),
+	}
+}
+
+// notify wakes every loop without blocking
+func (e secretEvents) notify() {
+	for _, ch := range []chan struct{}{e.broadcast, e.hooks} {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watchSecretMetadata watches the configured secrets with WATCH_STRATEGY until ctx is cancelled
+// Deletions are recorded as they happen, so a missing secret can be told apart from one that never existed
+func (s *Server) watchSecretMetadata(ctx context.Context) {
+	opts := k8s.SecretWatchOptions{
+		Strategy:      s.config.WatchStrategy,
+		Namespace:     s.config.PodNamespace,
+		Names:         s.config.SecretNames,
+		LabelSelector: s.config.WatchLabelSelector,
+	}
+	err := s.k8sClients.ForNamespace(s.config.PodNamespace).WatchSecretMetadata(ctx, opts, func(event k8s.SecretWatchEvent) {
+		if event.Deleted {
+			go s.recordDeletion(event)
+		}
+		s.secretEvents.notify()
+	})
+	if err != nil {
+		logging.Printf("Error watching secrets, falling back to polling: %v", err)
+	}
+}
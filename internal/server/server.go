@@ -3,15 +3,31 @@ package server
 import (
 	"context"
 	"fmt"
-	"log"
+	htmltemplate "html/template"
 	"net/http"
+	"sync/atomic"
+	"text/template"
 	"time"
 
+	"bitwarden-reader/internal/audit"
 	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/features"
+	"bitwarden-reader/internal/gitops"
+	"bitwarden-reader/internal/history"
+	"bitwarden-reader/internal/hooks"
+	"bitwarden-reader/internal/i18n"
 	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/mqtt"
+	"bitwarden-reader/internal/notify"
+	"bitwarden-reader/internal/plugins"
 	"bitwarden-reader/internal/reader"
+	"bitwarden-reader/internal/sources"
+	"bitwarden-reader/internal/render"
+	"bitwarden-reader/internal/rules"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
 // countFoundSecrets counts the number of found secrets
@@ -30,20 +46,112 @@ type Server struct {
 	router        *gin.Engine
 	k8sClients    *k8s.K8sClients
 	config        *config.Config
-	hub           *Hub
+	hub           atomic.Pointer[Hub]
 	httpServer    *http.Server
+	changes       changeTracker
+	changeLog     changeLog
+	audit         *audit.Logger
+	confirmations *confirmationStore
+	impersonation impersonationCache
+	signer        bundleSigner
+	manifests     *gitops.Loader
+	templates     map[string]*template.Template
+	plugins       *plugins.Chain
+	hooks         *hooks.Runner
+	stopLoops     context.CancelFunc
+	wsLimits      *connLimiter
+	resumeTokens  *resumeTokens
+	upgrader      *websocket.Upgrader
+	broadcasts    broadcastState
+	schedule      *refreshSchedule
+	cache         secretCache
+	history       *history.Store
+	secretEvents  secretEvents
+	sources       []reader.SecretSource
+	vault         *sources.VaultSource
+	local         *sources.FileSource
+	flaps         *flapDetector
+	notifier      *notify.Notifier
+	mqtt          *mqtt.Publisher
+	mqttState     mqttState
+	alerts        *rules.Engine
+	retention     retentionStats
+	capabilities  capabilityCache
+	sessions      *sessionManager
+	authenticator Authenticator
+	ipFilter      *ipFilter
+	visibility    *reader.Visibility
+	weak          *reader.WeakAnalyzer
+	preflight     preflightState
+	operatorProbe operatorProbe
+	memory        memoryGuard
+	pages         *htmltemplate.Template
+	spa           *spaAssets
+	locales       *i18n.Catalogs
+	apiVersions   apiVersionState
+	features      *features.Set
+	chaos         *reader.Chaos
+	soak          *soakMonitor
 }
 
 // NewServer creates a new server instance
-func NewServer(cfg *config.Config, k8sClients *k8s.K8sClients) *Server {
+func NewServer(cfg *config.Config, k8sClients *k8s.K8sClients, auditLogger *audit.Logger, historyStore *history.Store) *Server {
 	// Set Gin mode
 	if gin.Mode() == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.New()
-	router.Use(gin.Logger())
+
+	// Create WebSocket hub
+	hub := newHub(cfg.WSResumeBuffer, cfg.WSAckTimeout)
+	go hub.supervise(cfg.HubAutoRestart)
+
+	server := &Server{
+		router:        router,
+		k8sClients:    k8sClients,
+		config:        cfg,
+		audit:         auditLogger,
+		history:       historyStore,
+		confirmations: newConfirmationStore(),
+		manifests:     gitops.NewLoader(cfg.GitOpsSOPSKeyFile),
+		plugins:       plugins.NewExecChain(cfg.PluginCommands, cfg.PluginTimeout),
+		hooks:         hooks.NewRunner(cfg.OnChangeExec, cfg.OnChangeExecTimeout, auditLogger),
+		wsLimits:      newConnLimiter(cfg.WSMaxConnections, cfg.WSMaxConnsPerClient),
+		resumeTokens:  newResumeTokens(cfg.WSResumeWindow),
+		upgrader:      newUpgrader(cfg.WSCompression, cfg.WSAllowedOrigins),
+		secretEvents:  newSecretEvents(),
+		flaps:         newFlapDetector(cfg.FlapThreshold, cfg.FlapWindow),
+		sessions:      newSessionManager(cfg),
+		locales:       loadLocales(cfg.Language, cfg.LocalesDir),
+		features:      features.New(cfg.FeatureFlags),
+		soak:          newSoakMonitor(cfg),
+	}
+	server.hub.Store(hub)
+
+	// Secret sources read alongside Kubernetes; Vault is also used for comparisons
+	server.vault = newVaultSource(cfg)
+	server.sources = newSecretSources(cfg, server.vault)
+	server.local = newLocalSource(cfg, k8sClients)
+	if cfg.ChaosEnabled && !cfg.ChaosAllowed() {
+		logging.Printf("CHAOS_ENABLED is ignored without ADMIN_IDENTITIES and an AUTH_METHODS method other than none")
+	} else if cfg.ChaosEnabled {
+		logging.Printf("CHAOS_ENABLED is set: simulated failures can be injected through /api/v1/admin/chaos - do not use in production")
+		server.chaos = reader.NewChaos()
+	}
+
+	router.Use(server.accessLogger(newAccessLogWriter(cfg.AccessLogEnabled)))
 	router.Use(gin.Recovery())
+	router.Use(server.negotiateLanguage)
+
+	// Mark API versions, announce /api/v1 deprecations, and structure /api/v2 errors, including those of the middleware below
+	router.Use(server.versionAPI)
+
+	// Reject client IPs outside IP_ALLOW or in IP_DENY, and the admin lists, before authentication
+	setTrustedProxies(router, cfg.TrustedProxies)
+	if server.ipFilter = newIPFilter(cfg); server.ipFilter != nil {
+		router.Use(server.filterIPs)
+	}
 
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
@@ -60,22 +168,93 @@ func NewServer(cfg *config.Config, k8sClients *k8s.K8sClients) *Server {
 		c.Next()
 	})
 
-	// Create WebSocket hub
-	hub := newHub()
-	go hub.run()
+	// Take the identity of logged-in browsers from their session
+	router.Use(server.restoreSession)
 
-	server := &Server{
-		router:     router,
-		k8sClients: k8sClients,
-		config:     cfg,
-		hub:        hub,
+	// Reject request bodies over MAX_REQUEST_BODY_BYTES
+	router.Use(server.limitRequestBody)
+
+	// Authenticate requests without a session through AUTH_METHODS
+	server.authenticator = newAuthenticator(cfg)
+	router.Use(server.authenticate)
+	if len(cfg.AdminIdentities) == 0 {
+		logging.Printf("ADMIN_IDENTITIES is unset: the admin API under %s answers 403", adminPathPrefix)
+	}
+
+	// Make Kubernetes API calls as the request identity instead of the service account
+	if cfg.Impersonation && k8sClients != nil {
+		router.Use(server.impersonate)
+	}
+
+	// Load config templates
+	if cfg.TemplatesDir != "" {
+		templates, err := render.LoadDir(cfg.TemplatesDir)
+		if err != nil {
+			logging.Printf("Error loading templates from %s: %v", cfg.TemplatesDir, err)
+		} else {
+			server.templates = templates
+			logging.Printf("Loaded %d templates from %s", len(templates), cfg.TemplatesDir)
+		}
+	}
+
+	// Load notification routing
+	if cfg.NotifyConfigFile != "" {
+		notifyConfig, err := notify.LoadConfig(cfg.NotifyConfigFile)
+		if err != nil {
+			logging.Printf("Error loading notification config, notifications are disabled: %v", err)
+		} else {
+			server.notifier = notify.NewNotifier(notifyConfig, auditLogger)
+			logging.Printf("Loaded %d notification routes from %s", len(notifyConfig.Routes), cfg.NotifyConfigFile)
+		}
+	}
+	server.mqtt = newMQTTPublisher(cfg)
+
+	// Load the per-key visibility policy; an invalid policy hides every value rather than serving them
+	visibility, err := reader.NewVisibility(cfg.KeyVisibility)
+	if err != nil {
+		logging.Printf("Error in KEY_VISIBILITY, hiding all secret values: %v", err)
+		visibility, _ = reader.NewVisibility(map[string][]string{reader.VisibilityHidden: {"*/*"}})
+	}
+	server.visibility = visibility
+
+	// Load weak value detection
+	weak, err := reader.NewWeakAnalyzer(cfg.WeakSecretDetectors, cfg.WeakSecretKeys)
+	if err != nil {
+		logging.Printf("Error in WEAK_SECRET_DETECTORS or WEAK_SECRET_KEYS, weak value detection is disabled: %v", err)
+	}
+	server.weak = weak
+
+	// Load alert rules
+	if cfg.AlertRulesFile != "" {
+		alertRules, err := rules.LoadFile(cfg.AlertRulesFile)
+		if err != nil {
+			logging.Printf("Error loading alert rules, alerts are disabled: %v", err)
+		} else {
+			server.alerts = rules.NewEngine(alertRules, cfg.PodNamespace)
+			logging.Printf("Loaded %d alert rules from %s", len(alertRules), cfg.AlertRulesFile)
+		}
+	}
+
+	// Load per-group and per-namespace refresh intervals
+	if cfg.RefreshScheduleFile != "" {
+		schedule, err := loadRefreshSchedule(cfg.RefreshScheduleFile, cfg.DashboardRefreshInterval)
+		if err != nil {
+			logging.Printf("Error loading refresh schedule, using DASHBOARD_REFRESH_INTERVAL for all secrets: %v", err)
+		} else {
+			server.schedule = schedule
+		}
+	}
+
+	// A compiled single-page UI in ASSETS_DIR replaces the template dashboard
+	if cfg.UIEnabled {
+		server.spa = loadSPA(cfg.AssetsDir)
 	}
 
 	// Register routes
 	server.registerRoutes()
 
-	// Load HTML templates
-	server.router.LoadHTMLGlob("web/templates/*")
+	// Load HTML templates; without them the built-in status page is served
+	server.pages = loadPages(cfg.UIEnabled, server.locales)
 
 	return server
 }
@@ -83,21 +262,118 @@ func NewServer(cfg *config.Config, k8sClients *k8s.K8sClients) *Server {
 // registerRoutes registers all HTTP routes
 func (s *Server) registerRoutes() {
 	// Static files
-	s.router.Static("/static", "./web/static")
+	if s.config.UIEnabled {
+		s.router.Static("/static", "./web/static")
+	}
 
 	// Web UI
-	s.router.GET("/", s.webHandler)
+	if s.spa != nil {
+		s.router.GET("/", s.spaHandler)
+		s.router.NoRoute(s.noRouteHandler)
+	} else {
+		s.router.GET("/", s.requirePodNamespace, s.webHandler)
+	}
 
 	// API endpoints
 	api := s.router.Group("/api/v1")
 	{
 		api.GET("/secrets", s.apiSecretsHandler)
-		api.POST("/trigger-sync", s.triggerSyncHandler)
+		api.GET("/secrets/poll", s.requirePodNamespace, s.apiSecretsPollHandler)
+		api.GET("/secrets/export", s.requirePodNamespace, s.exportStatusHandler)
+		api.GET("/groups", s.requirePodNamespace, s.apiGroupsHandler)
+		api.GET("/duplicates", s.requirePodNamespace, s.duplicatesHandler)
+		api.GET("/changes", s.requirePodNamespace, s.changesHandler)
+		api.GET("/deletions", s.deletionsHandler)
+		api.GET("/auth-tokens", s.authTokensHandler)
+		api.GET("/namespaces", s.apiNamespacesHandler)
+		api.GET("/namespaces/:ns/secrets", s.apiNamespaceSecretsHandler)
+		api.POST("/trigger-sync", s.rejectReadOnly("trigger.sync"), s.triggerSyncHandler)
+		api.GET("/trigger-sync/plan", s.rejectReadOnly("trigger.plan"), s.triggerPlanHandler)
+		api.GET("/trigger-history", s.triggerHistoryHandler)
+		api.GET("/trigger-history/:id", s.triggerHandler)
+		api.GET("/sla-report", s.slaReportHandler)
+		api.GET("/metrics/history", s.metricsHistoryHandler)
+		api.GET("/compare", s.compareHandler)
+		api.GET("/vault/compare", s.requirePodNamespace, s.vaultCompareHandler)
+		api.POST("/assert", s.requirePodNamespace, s.assertHandler)
+		api.POST("/bitwardensecrets", s.rejectReadOnly("bitwardensecret.create"), s.requireWriteEnabled, s.createBitwardenSecretHandler)
+		api.POST("/bitwardensecrets/validate", s.validateBitwardenSecretHandler)
+		api.GET("/schemas", s.requestSchemasHandler)
+		api.PUT("/bitwardensecrets/:name", s.rejectReadOnly("bitwardensecret.update"), s.requireWriteEnabled, s.updateBitwardenSecretHandler)
+		api.DELETE("/bitwardensecrets/:name", s.rejectReadOnly("bitwardensecret.delete"), s.requireWriteEnabled, s.deleteBitwardenSecretHandler)
 		api.GET("/health", s.healthHandler)
+		api.GET("/preflight", s.preflightHandler)
+		api.GET("/operator/info", s.operatorInfoHandler)
+		api.GET("/health/secrets", s.secretsHealthHandler)
+		api.GET("/health/transitions", s.healthTransitionsHandler)
+		api.GET("/alerts", s.alertsHandler)
+		api.GET("/export/state", s.requirePodNamespace, s.exportStateHandler)
+		api.POST("/export/encrypted", s.requirePodNamespace, s.exportEncryptedHandler)
+		api.GET("/ui-config", s.uiConfigHandler)
+		api.GET("/gitops/compare", s.requirePodNamespace, s.gitopsCompareHandler)
+		api.GET("/templates", s.apiTemplatesHandler)
+		api.GET("/templates/:name/render", s.requirePodNamespace, s.renderTemplateHandler)
+		api.POST("/logout", s.logoutHandler)
+	}
+
+	// Admin API, for the identities in ADMIN_IDENTITIES
+	admin := api.Group("/admin", s.requireAdmin)
+	{
+		admin.GET("/config", s.adminConfigHandler)
+		admin.GET("/websockets", s.adminWebSocketsHandler)
+		admin.GET("/audit-sinks", s.adminAuditSinksHandler)
+		admin.POST("/discovery/refresh", s.adminDiscoveryRefreshHandler)
+		admin.GET("/sessions", s.adminSessionsHandler)
+		admin.DELETE("/sessions/:id", s.adminRevokeSessionHandler)
+		admin.GET("/features", s.adminFeaturesHandler)
+		if s.chaos != nil {
+			admin.GET("/chaos", s.adminChaosHandler)
+			admin.POST("/chaos", s.adminInjectChaosHandler)
+			admin.DELETE("/chaos", s.adminClearChaosHandler)
+			admin.DELETE("/chaos/:id", s.adminRemoveChaosHandler)
+		}
 	}
 
+	// Structured errors, pagination, and response metadata; /api/v1 stays as is for existing clients
+	v2 := s.router.Group("/api/v2")
+	{
+		v2.GET("/secrets", s.v2SecretsHandler)
+		v2.GET("/namespaces", s.v2NamespacesHandler)
+		v2.POST("/trigger-sync", s.rejectReadOnly("trigger.sync"), s.triggerSyncHandler)
+		v2.GET("/trigger-sync/plan", s.rejectReadOnly("trigger.plan"), s.triggerPlanHandler)
+		v2.GET("/trigger-history", s.v2TriggerHistoryHandler)
+		v2.GET("/trigger-history/:id", s.v2TriggerHandler)
+		v2.POST("/bitwardensecrets", s.rejectReadOnly("bitwardensecret.create"), s.requireWriteEnabled, s.createBitwardenSecretHandler)
+		v2.POST("/bitwardensecrets/validate", s.validateBitwardenSecretHandler)
+		v2.PUT("/bitwardensecrets/:name", s.rejectReadOnly("bitwardensecret.update"), s.requireWriteEnabled, s.updateBitwardenSecretHandler)
+		v2.DELETE("/bitwardensecrets/:name", s.rejectReadOnly("bitwardensecret.delete"), s.requireWriteEnabled, s.deleteBitwardenSecretHandler)
+		v2.GET("/schemas", s.requestSchemasHandler)
+	}
+	s.apiVersions.successors = apiSuccessors(s.router.Routes())
+
+	// API versions, their deprecation dates, and how to move between them
+	s.router.GET("/api/versions", s.apiVersionsHandler)
+
+	// Liveness probe covering the WebSocket hub
+	s.router.GET("/livez", s.livezHandler)
+
+	// Readiness probe gated on REQUIRED_SECRETS
+	s.router.GET("/readyz", s.readyzHandler)
+
+	// Sync health summary for external uptime monitors
+	s.router.GET("/statusz", s.statuszHandler)
+
+	// Prometheus metrics
+	s.router.GET("/metrics", s.metricsHandler)
+	s.router.GET("/metrics/autoscaling", s.autoscalingHandler)
+
 	// WebSocket endpoint
 	s.router.GET("/ws", s.wsHandler)
+
+	// Goroutine, heap, and WebSocket backlog trends for soak tests
+	if s.soak != nil {
+		s.router.GET("/debug/soak", s.soakHandler)
+	}
 }
 
 // Start starts the HTTP server
@@ -108,36 +384,169 @@ func (s *Server) Start() error {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	log.Printf("Starting server on port %d", s.config.Port)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopLoops = cancel
+
+	// Report what the configuration needs from the cluster and what will not work
+	go func() {
+		logPreflight(s.runPreflight(ctx))
+	}()
+
+	// Push changed snapshots and heartbeats to WebSocket clients
+	go s.broadcastLoop(ctx)
+
+	// Detect a stalled hub event loop for /livez
+	if s.config.HubWatchdogInterval > 0 {
+		go s.watchHub(ctx)
+	}
+
+	// Sample goroutines, heap, and WebSocket backlog to catch slow leaks in soak tests
+	if s.soak != nil {
+		go s.soakLoop(ctx)
+	}
+
+	// Sample sync state for SLA reports
+	if s.k8sClients != nil && s.config.SyncSampleInterval > 0 {
+		go s.recordSyncHistory(ctx)
+	}
+
+	// Re-read secrets as soon as a watched secret changes, in addition to polling
+	if s.informersEnabled() {
+		go s.watchSecretMetadata(ctx)
+	}
+
+	// Apply retention to the history and audit files and drop superseded records
+	if s.config.CompactionInterval > 0 {
+		go s.compactLoop(ctx)
+	}
+
+	// Push edits to the local secrets directory as they happen
+	if s.local != nil {
+		go s.watchLocalSecrets(ctx)
+	}
+
+	// Watch secrets for changes when something consumes the events or status, tracks flapping, or evaluates alert rules
+	if (s.k8sClients != nil || s.local != nil) && (s.hooks.Enabled() || s.notifier.Enabled() || s.mqtt.Enabled() || s.flaps != nil || s.alerts.Enabled()) {
+		go s.watchSecrets(ctx)
+	}
+
+	logging.Printf("Starting server on port %d", s.config.Port)
 	return s.httpServer.ListenAndServe()
 }
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.stopLoops != nil {
+		s.stopLoops()
+	}
+	s.mqtt.Close()
 	if s.httpServer != nil {
 		return s.httpServer.Shutdown(ctx)
 	}
 	return nil
 }
 
+// Handler returns the HTTP handler, for serving the reader without Start as the loadtest command does
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// Refresh re-reads the secrets and publishes the snapshot to WebSocket clients if it changed,
+// as the broadcast loop does on every tick
+func (s *Server) Refresh() {
+	s.broadcastSecrets()
+}
+
+// readSecrets reads the configured secrets and applies group assignments
+func (s *Server) readSecrets(ctx context.Context) ([]reader.SecretInfo, error) {
+	return s.readSecretNames(ctx, s.secretNames())
+}
+
+// readSecretNames reads the named secrets from every source and applies group assignments
+func (s *Server) readSecretNames(ctx context.Context, names []string) ([]reader.SecretInfo, error) {
+	secrets := reader.ReadFromSources(ctx, s.secretSources(ctx), names)
+	reader.AssignGroups(secrets, s.config.SecretGroups)
+	s.markDeletions(secrets)
+	s.flaps.mark(secrets)
+	s.processSecrets(ctx, s.config.PodNamespace, secrets)
+	s.observeChanges(ctx, secrets)
+	return secrets, nil
+}
+
+// processSecrets runs the plugins, analyses, visibility policy, and memory caps over secrets read from namespace
+func (s *Server) processSecrets(ctx context.Context, namespace string, secrets []reader.SecretInfo) {
+	s.plugins.Apply(ctx, namespace, secrets)
+	// Described and analyzed before the visibility policy, so hidden keys are still covered
+	reader.DescribeKeyMaterial(secrets)
+	reader.HashValues(secrets)
+	reader.CheckSyncConsistency(secrets, s.config.SyncSkewTolerance)
+	s.weak.Apply(secrets)
+	s.visibility.Apply(secrets)
+	s.capSecretValues(namespace, secrets)
+}
+
 // broadcastSecrets broadcasts current secret state to all WebSocket clients
 func (s *Server) broadcastSecrets() {
 	ctx := context.Background()
-	secrets, err := reader.ReadSecrets(ctx, s.config.SecretNames, s.config.PodNamespace, s.k8sClients)
+
+	// With a refresh schedule, re-read everything now and keep the cache consistent
+	if s.schedule != nil {
+		s.cache.expire()
+		s.refreshScheduled(ctx)
+		return
+	}
+
+	secrets, err := s.readSecrets(ctx)
 	if err != nil {
-		log.Printf("Error reading secrets: %v", err)
+		logging.Printf("Error reading secrets: %v", err)
 	}
+	hash := hashSecrets(secrets)
+	s.changes.observe(hash)
 
-	message := map[string]interface{}{
-		"secrets":    secrets,
-		"namespace":  s.config.PodNamespace,
-		"totalFound": countFoundSecrets(secrets),
-		"timestamp":  time.Now().Format(time.RFC3339),
+	// Broadcasts outlive the request, so in hygiene mode they only carry value hashes
+	if s.config.MemoryHygiene {
+		hashed := hashSecretValues(secrets)
+		wipeSecretValues(secrets)
+		secrets = hashed
 	}
 
-	if s.k8sClients == nil {
-		message["error"] = "Kubernetes client not available - running in standalone mode"
+	s.publishSnapshot(secrets, hash)
+}
+
+// publishSnapshot sends the secrets to WebSocket clients unless the hash matches the last snapshot sent
+func (s *Server) publishSnapshot(secrets []reader.SecretInfo, hash string) {
+	// Clients already have this snapshot; heartbeats cover liveness
+	if s.broadcasts.unchanged(hash) {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"type":      messageTypeSecrets,
+		"timestamp": time.Now().Format(time.RFC3339),
 	}
 
-	s.hub.broadcastMessage(message)
+	if s.config.MemoryHygiene {
+		fields["valuesHashed"] = true
+	}
+
+	if s.k8sClients == nil && s.local == nil {
+		fields["error"] = "Kubernetes client not available - running in standalone mode"
+	}
+
+	secrets = s.capSnapshot(secrets, fields)
+
+	payload := &broadcastPayload{
+		namespace: s.config.PodNamespace,
+		secrets:   secrets,
+		fields:    fields,
+	}
+	// Clients that take deltas get only the secrets changed since the last published snapshot
+	var hashes map[string]string
+	if s.features.Enabled(features.DeltaBroadcasts) {
+		hashes = secretHashes(secrets)
+		payload.delta = s.broadcasts.delta(secrets, hashes)
+	}
+	if s.currentHub().publish(payload) {
+		s.broadcasts.record(hash, hashes)
+	}
 }
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisSessionPrefix namespaces session keys in a shared Redis database
+const redisSessionPrefix = "bitwarden-reader:session:"
+
+// redisTimeout bounds connecting to Redis and each command
+const redisTimeout = 5 * time.Second
+
+// redisSessionStore keeps sessions in Redis with their TTL as key expiry, so replicas share them
+// It speaks just enough RESP for its commands over a single connection
+type redisSessionStore struct {
+	address      string
+	useTLS       bool
+	db           int
+	passwordFile string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newRedisSessionStore parses a redis:// or rediss:// URL with an optional database number path
+func newRedisSessionStore(rawURL, passwordFile string) (*redisSessionStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("SESSION_REDIS_URL must look like redis://host:6379/0")
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("SESSION_REDIS_URL scheme must be redis or rediss")
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("SESSION_REDIS_URL must not contain credentials, use SESSION_REDIS_PASSWORD_FILE")
+	}
+	store := &redisSessionStore{address: u.Host, useTLS: u.Scheme == "rediss", passwordFile: passwordFile}
+	if u.Port() == "" {
+		store.address = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		store.db, err = strconv.Atoi(path)
+		if err != nil || store.db < 0 {
+			return nil, fmt.Errorf("SESSION_REDIS_URL database must be a number")
+		}
+	}
+	return store, nil
+}
+
+func (r *redisSessionStore) get(id string) (*session, error) {
+	reply, err := r.do("GET", redisSessionPrefix+id)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	var s session
+	if err := json.Unmarshal(reply.([]byte), &s); err != nil {
+		return nil, fmt.Errorf("invalid session in Redis: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *redisSessionStore) put(s *session, ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return r.delete(s.ID)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = r.do("SET", redisSessionPrefix+s.ID, string(data), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+func (r *redisSessionStore) delete(id string) error {
+	_, err := r.do("DEL", redisSessionPrefix+id)
+	return err
+}
+
+func (r *redisSessionStore) list() ([]session, error) {
+	var sessions []session
+	cursor := "0"
+	for {
+		reply, err := r.do("SCAN", cursor, "MATCH", redisSessionPrefix+"*", "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply")
+		}
+		next, _ := parts[0].([]byte)
+		keys, _ := parts[1].([]interface{})
+		for _, key := range keys {
+			name, _ := key.([]byte)
+			s, err := r.get(strings.TrimPrefix(string(name), redisSessionPrefix))
+			if err != nil {
+				return nil, err
+			}
+			// Sessions may expire between SCAN and GET
+			if s != nil {
+				sessions = append(sessions, *s)
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return sessions, nil
+		}
+	}
+}
+
+// do runs a command, reconnecting once when the connection was lost
+func (r *redisSessionStore) do(args ...string) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reply, err := r.roundTrip(args)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) && r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+		reply, err = r.roundTrip(args)
+	}
+	return reply, err
+}
+
+// roundTrip sends a command on the current connection, connecting first when needed
+func (r *redisSessionStore) roundTrip(args []string) (interface{}, error) {
+	if r.conn == nil {
+		if err := r.connect(); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.conn.SetDeadline(time.Now().Add(redisTimeout)); err != nil {
+		return nil, err
+	}
+	if err := r.write(args); err != nil {
+		return nil, fmt.Errorf("failed to write to Redis: %w", err)
+	}
+	return r.read()
+}
+
+// connect dials Redis, then authenticates and selects the database
+func (r *redisSessionStore) connect() error {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if r.useTLS {
+		host, _, _ := net.SplitHostPort(r.address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", r.address, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	} else {
+		conn, err = dialer.Dial("tcp", r.address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	r.conn = conn
+	r.reader = bufio.NewReader(conn)
+
+	var setup [][]string
+	if r.passwordFile != "" {
+		password, err := os.ReadFile(r.passwordFile)
+		if err != nil {
+			r.conn.Close()
+			r.conn = nil
+			return fmt.Errorf("failed to read Redis password file: %w", err)
+		}
+		setup = append(setup, []string{"AUTH", strings.TrimSpace(string(password))})
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	for _, args := range setup {
+		if _, err := r.roundTrip(args); err != nil {
+			r.conn.Close()
+			r.conn = nil
+			return fmt.Errorf("failed to set up Redis connection: %w", err)
+		}
+	}
+	return nil
+}
+
+// write sends a command as a RESP array of bulk strings
+func (r *redisSessionStore) write(args []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := io.WriteString(r.conn, b.String())
+	return err
+}
+
+// redisError is an error reply from Redis; the connection stays usable after one
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// read parses one reply: strings as []byte, integers as int64, arrays as []interface{}, nil for null
+func (r *redisSessionStore) read() (interface{}, error) {
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from Redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty reply from Redis")
+	}
+
+	switch line[0] {
+	case '+':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply from Redis")
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r.reader, data); err != nil {
+			return nil, fmt.Errorf("failed to read from Redis: %w", err)
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply from Redis")
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			item, err := r.read()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply from Redis")
+	}
+}
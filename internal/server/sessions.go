@@ -0,0 +1,358 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// sessionCookie carries the browser's session token
+const sessionCookie = "bitwarden_reader_session"
+
+// sessionTouchInterval limits how often a request's activity is written back to the store
+const sessionTouchInterval = time.Minute
+
+// sessionExpiredCloseCode closes WebSocket connections whose session ended, so the page reloads
+const sessionExpiredCloseCode = 4401
+
+// session is a logged-in browser
+// The store and the admin API only see the ID, a hash of the cookie token, never the token itself
+type session struct {
+	ID        string    `json:"id"`
+	Identity  string    `json:"identity"`
+	ClientIP  string    `json:"clientIp"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// sessionStore keeps sessions until their TTL passes
+type sessionStore interface {
+	get(id string) (*session, error)
+	put(s *session, ttl time.Duration) error
+	delete(id string) error
+	list() ([]session, error)
+}
+
+// sessionManager issues, restores, and expires browser sessions
+type sessionManager struct {
+	store       sessionStore
+	idleTimeout time.Duration
+	maxAge      time.Duration
+}
+
+// newSessionManager creates the session manager, storing sessions in Redis when SESSION_REDIS_URL is set
+func newSessionManager(cfg *config.Config) *sessionManager {
+	m := &sessionManager{
+		store:       &memorySessionStore{},
+		idleTimeout: cfg.SessionIdleTimeout,
+		maxAge:      cfg.SessionMaxAge,
+	}
+	if cfg.SessionRedisURL != "" {
+		store, err := newRedisSessionStore(cfg.SessionRedisURL, cfg.SessionRedisPasswordFile)
+		if err != nil {
+			logging.Printf("Error configuring Redis session store, keeping sessions in memory: %v", err)
+		} else {
+			m.store = store
+		}
+	}
+	return m
+}
+
+// sessionID returns the store ID for a cookie token
+func sessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// expiry returns when the session ends: after the idle timeout or the maximum age, whichever comes first
+func (m *sessionManager) expiry(s *session) time.Time {
+	expires := s.Created.Add(m.maxAge)
+	if m.idleTimeout > 0 {
+		if idle := s.LastSeen.Add(m.idleTimeout); idle.Before(expires) {
+			expires = idle
+		}
+	}
+	return expires
+}
+
+// valid reports whether the session still exists and has not expired
+func (m *sessionManager) valid(id string, now time.Time) bool {
+	s, err := m.store.get(id)
+	if err != nil {
+		// Keep connections open while the store is unreachable
+		logging.Printf("Error reading session: %v", err)
+		return true
+	}
+	return s != nil && now.Before(m.expiry(s))
+}
+
+// start creates a session for identity after a successful login and sets the browser's cookie
+func (m *sessionManager) start(c *gin.Context, identity string) error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	now := time.Now().UTC()
+	s := &session{
+		ID:        sessionID(token),
+		Identity:  identity,
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Created:   now,
+		LastSeen:  now,
+	}
+	if err := m.store.put(s, m.expiry(s).Sub(now)); err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+	setSessionCookie(c, token, int(m.maxAge.Seconds()))
+	c.Set(sessionKey, s)
+	c.Set(identityKey, identity)
+	return nil
+}
+
+// startSession starts a session for a browser that logged in as identity; the request is served
+// either way, so a failing store only costs the browser its session
+func (s *Server) startSession(c *gin.Context, identity string) {
+	if err := s.sessions.start(c, identity); err != nil {
+		logging.Printf("Error starting session for %s: %v", identity, err)
+		return
+	}
+	s.recordAudit(c, "session.start", requestSession(c).ID[:12], "", audit.OutcomeSuccess, map[string]string{
+		"method": c.GetString(authMethodKey),
+	})
+}
+
+// setSessionCookie sets or, with a negative maxAge, clears the session cookie
+func setSessionCookie(c *gin.Context, token string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// restoreSession is middleware that takes the request identity from the session cookie
+// Expired sessions are deleted and their cookie cleared, so the request continues unauthenticated
+func (s *Server) restoreSession(c *gin.Context) {
+	token, err := c.Cookie(sessionCookie)
+	if err != nil || token == "" {
+		c.Next()
+		return
+	}
+
+	now := time.Now().UTC()
+	id := sessionID(token)
+	sess, err := s.sessions.store.get(id)
+	if err != nil {
+		logging.Printf("Error reading session: %v", err)
+		c.Next()
+		return
+	}
+	if sess == nil || !now.Before(s.sessions.expiry(sess)) {
+		if sess != nil {
+			if err := s.sessions.store.delete(id); err != nil {
+				logging.Printf("Error deleting expired session: %v", err)
+			}
+			s.audit.Record(audit.Event{
+				Action:   "session.expire",
+				Actor:    sess.Identity,
+				Resource: id[:12],
+				Outcome:  audit.OutcomeSuccess,
+			})
+		}
+		setSessionCookie(c, "", -1)
+		c.Next()
+		return
+	}
+
+	// Opening the WebSocket is not user activity, otherwise reconnects would keep an idle tab's session alive
+	if c.Request.URL.Path != "/ws" && now.Sub(sess.LastSeen) >= sessionTouchInterval {
+		sess.LastSeen = now
+		if err := s.sessions.store.put(sess, s.sessions.expiry(sess).Sub(now)); err != nil {
+			logging.Printf("Error updating session: %v", err)
+		}
+	}
+	c.Set(sessionKey, sess)
+	if c.GetString(identityKey) == "" {
+		c.Set(identityKey, sess.Identity)
+	}
+	c.Next()
+}
+
+// requestSession returns the session the request was made in, if any
+func requestSession(c *gin.Context) *session {
+	if sess, ok := c.Get(sessionKey); ok {
+		return sess.(*session)
+	}
+	return nil
+}
+
+// sessionCheck returns a check for the client's session, or nil when the request had none
+func (s *Server) sessionCheck(c *gin.Context) func() bool {
+	sess := requestSession(c)
+	if sess == nil {
+		return nil
+	}
+	return func() bool {
+		return s.sessions.valid(sess.ID, time.Now().UTC())
+	}
+}
+
+// closeExpired closes the client's connection when its session ended
+func (c *Client) closeExpired() bool {
+	if c.sessionValid == nil || c.sessionValid() {
+		return false
+	}
+	message := websocket.FormatCloseMessage(sessionExpiredCloseCode, "session expired")
+	if err := c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait)); err != nil {
+		logging.Printf("Error writing close message: %v", err)
+	}
+	return true
+}
+
+// logoutHandler ends the request's session and clears its cookie
+func (s *Server) logoutHandler(c *gin.Context) {
+	sess := requestSession(c)
+	setSessionCookie(c, "", -1)
+	if sess == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "No active session"})
+		return
+	}
+	if err := s.sessions.store.delete(sess.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to end session: %v", err)})
+		return
+	}
+	s.recordAudit(c, "session.logout", sess.ID[:12], "", audit.OutcomeSuccess, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// adminSessionsHandler lists active sessions
+func (s *Server) adminSessionsHandler(c *gin.Context) {
+	sessions, err := s.sessions.store.list()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to list sessions: %v", err)})
+		return
+	}
+
+	type sessionView struct {
+		session
+		Expires time.Time `json:"expires"`
+	}
+	now := time.Now().UTC()
+	views := make([]sessionView, 0, len(sessions))
+	for i := range sessions {
+		expires := s.sessions.expiry(&sessions[i])
+		if now.Before(expires) {
+			views = append(views, sessionView{session: sessions[i], Expires: expires})
+		}
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].LastSeen.After(views[j].LastSeen)
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"sessions":           views,
+		"count":              len(views),
+		"idleTimeoutSeconds": int(s.sessions.idleTimeout.Seconds()),
+		"maxAgeSeconds":      int(s.sessions.maxAge.Seconds()),
+	})
+}
+
+// adminRevokeSessionHandler ends a session by its ID
+func (s *Server) adminRevokeSessionHandler(c *gin.Context) {
+	id := c.Param("id")
+	sess, err := s.sessions.store.get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to read session: %v", err)})
+		return
+	}
+	if sess == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": s.tr(c, "Session not found")})
+		return
+	}
+	if err := s.sessions.store.delete(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to revoke session: %v", err)})
+		return
+	}
+	s.recordAudit(c, "session.revoke", id[:12], "", audit.OutcomeSuccess, map[string]string{
+		"identity": sess.Identity,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked", "id": id})
+}
+
+// memorySessionStore keeps sessions in memory, so they are lost on restart and not shared between replicas
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	session session
+	expires time.Time
+}
+
+func (m *memorySessionStore) get(id string) (*session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.sessions[id]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, nil
+	}
+	s := entry.session
+	return &s, nil
+}
+
+func (m *memorySessionStore) put(s *session, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions == nil {
+		m.sessions = make(map[string]memorySession)
+	}
+	now := time.Now()
+	for id, entry := range m.sessions {
+		if now.After(entry.expires) {
+			delete(m.sessions, id)
+		}
+	}
+	m.sessions[s.ID] = memorySession{session: *s, expires: now.Add(ttl)}
+	return nil
+}
+
+func (m *memorySessionStore) delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessionStore) list() ([]session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	sessions := make([]session, 0, len(m.sessions))
+	for _, entry := range m.sessions {
+		if now.Before(entry.expires) {
+			sessions = append(sessions, entry.session)
+		}
+	}
+	return sessions, nil
+}
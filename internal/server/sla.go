@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/history"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultSLAWindow is the report window when none is requested
+const defaultSLAWindow = 7 * 24 * time.Hour
+
+// observeSyncState records a sync observation for each secret whose state changed
+func (s *Server) observeSyncState(ctx context.Context) {
+	secrets, err := reader.ReadSecrets(ctx, s.config.SecretNames, s.config.PodNamespace, s.k8sClients.ForNamespace(s.config.PodNamespace))
+	if err != nil {
+		logging.Printf("Error reading secrets for sync history: %v", err)
+		return
+	}
+	if s.config.MemoryHygiene {
+		defer wipeSecretValues(secrets)
+	}
+
+	now := time.Now().UTC()
+	for _, secret := range secrets {
+		observation := history.SyncObservation{
+			Secret:    secret.Name,
+			Namespace: s.config.PodNamespace,
+			Time:      now,
+			Found:     secret.Found,
+			Status:    secret.SyncInfo.SyncStatus,
+			LastSync:  secret.SyncInfo.LastSuccessfulSync,
+		}
+		if latest, ok := s.history.LatestObservation(secret.Name); ok && latest.SameState(observation) {
+			continue
+		}
+		if err := s.history.SaveObservation(observation); err != nil {
+			logging.Printf("Error saving sync observation: %v", err)
+		}
+	}
+}
+
+// recordSyncHistory samples the sync state every SYNC_SAMPLE_INTERVAL until ctx is cancelled
+func (s *Server) recordSyncHistory(ctx context.Context) {
+	ticker := time.NewTicker(s.config.SyncSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		s.observeSyncState(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseWindow parses a report window such as "7d", "12h", or "90m"
+func parseWindow(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid window %q", value)
+	}
+	return window, nil
+}
+
+// slaReportHandler reports per-secret sync freshness over a window, as JSON or CSV (?format=csv)
+func (s *Server) slaReportHandler(c *gin.Context) {
+	window := defaultSLAWindow
+	if value := c.Query("window"); value != "" {
+		parsed, err := parseWindow(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		window = parsed
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-window)
+	report := history.ComputeSLA(s.history.Observations(from), from, to, s.config.SLAMaxSyncAge)
+
+	if c.Query("format") == "csv" {
+		writeSLACSV(c, report, from, to)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":       from.Format(time.RFC3339),
+		"to":         to.Format(time.RFC3339),
+		"maxSyncAge": s.config.SLAMaxSyncAge.String(),
+		"secrets":    report,
+	})
+}
+
+// writeSLACSV writes the report as a CSV attachment
+func writeSLACSV(c *gin.Context, report []history.SecretSLA, from, to time.Time) {
+	filename := fmt.Sprintf("sla-report-%s-%s.csv", from.Format("20060102"), to.Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"secret", "observed_hours", "within_sla_percent", "longest_stale_hours", "failures", "last_sync"})
+	for _, row := range report {
+		_ = w.Write([]string{
+			row.Secret,
+			strconv.FormatFloat(row.ObservedSeconds/3600, 'f', 2, 64),
+			strconv.FormatFloat(row.WithinSLAPercent, 'f', 2, 64),
+			strconv.FormatFloat(row.LongestStaleSeconds/3600, 'f', 2, 64),
+			strconv.Itoa(row.Failures),
+			row.LastSync,
+		})
+	}
+	w.Flush()
+}
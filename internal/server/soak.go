@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// soakSample is one reading of the resources that slowly leak in a long-running reader
+type soakSample struct {
+	Time       time.Time `json:"time"`
+	Goroutines int64     `json:"goroutines"`
+	// HeapLiveBytes is the heap still in use after the last garbage collection, so garbage waiting
+	// to be collected does not look like growth
+	HeapLiveBytes  int64 `json:"heapLiveBytes"`
+	WSClients      int64 `json:"wsClients"`
+	QueuedMessages int64 `json:"queuedMessages"`
+}
+
+// soakSeries are the sampled values checked for growth, by name
+var soakSeries = []struct {
+	name  string
+	value func(soakSample) int64
+}{
+	{"goroutines", func(s soakSample) int64 { return s.Goroutines }},
+	{"heapLiveBytes", func(s soakSample) int64 { return s.HeapLiveBytes }},
+	{"wsClients", func(s soakSample) int64 { return s.WSClients }},
+	{"queuedMessages", func(s soakSample) int64 { return s.QueuedMessages }},
+}
+
+// soakTrend summarizes one series over the kept samples
+type soakTrend struct {
+	First   int64 `json:"first"`
+	Last    int64 `json:"last"`
+	Min     int64 `json:"min"`
+	Max     int64 `json:"max"`
+	Growing bool  `json:"growing"`
+}
+
+// soakWarning reports a series that grew over the last SOAK_GROWTH_SAMPLES samples
+type soakWarning struct {
+	Series  string    `json:"series"`
+	From    int64     `json:"from"`
+	To      int64     `json:"to"`
+	Since   time.Time `json:"since"`
+	Message string    `json:"message"`
+}
+
+// soakMonitor keeps the last SOAK_SAMPLES samples, oldest first, and which series are growing
+type soakMonitor struct {
+	mu            sync.Mutex
+	interval      time.Duration
+	size          int
+	growthSamples int
+	startedAt     time.Time
+	samples       []soakSample
+	growing       map[string]bool
+}
+
+// newSoakMonitor creates the self-monitor, or nil when SOAK_SAMPLE_INTERVAL_SECONDS is 0
+func newSoakMonitor(cfg *config.Config) *soakMonitor {
+	if cfg.SoakSampleInterval <= 0 {
+		return nil
+	}
+	return &soakMonitor{
+		interval:      cfg.SoakSampleInterval,
+		size:          cfg.SoakSamples,
+		growthSamples: cfg.SoakGrowthSamples,
+		growing:       make(map[string]bool),
+	}
+}
+
+// record adds a sample and returns the series that started growing with it
+func (m *soakMonitor) record(sample soakSample) []soakWarning {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.startedAt.IsZero() {
+		m.startedAt = sample.Time
+	}
+	m.samples = append(m.samples, sample)
+	if len(m.samples) > m.size {
+		m.samples = append(m.samples[:0], m.samples[len(m.samples)-m.size:]...)
+	}
+
+	growing := make(map[string]bool, len(soakSeries))
+	var started []soakWarning
+	for _, warning := range m.warnings() {
+		if !m.growing[warning.Series] {
+			started = append(started, warning)
+		}
+		growing[warning.Series] = true
+	}
+	m.growing = growing
+	return started
+}
+
+// warnings returns the series that never fell and rose in at least half the intervals over the last
+// growthSamples samples; a leak shows as such a staircase, while a one-off rise that levels off does not
+func (m *soakMonitor) warnings() []soakWarning {
+	if len(m.samples) < m.growthSamples {
+		return nil
+	}
+	recent := m.samples[len(m.samples)-m.growthSamples:]
+	var warnings []soakWarning
+	for _, series := range soakSeries {
+		rises := 0
+		falls := false
+		for i := 1; i < len(recent); i++ {
+			previous, current := series.value(recent[i-1]), series.value(recent[i])
+			if current < previous {
+				falls = true
+				break
+			}
+			if current > previous {
+				rises++
+			}
+		}
+		if falls || rises*2 < len(recent)-1 {
+			continue
+		}
+		from, to := series.value(recent[0]), series.value(recent[len(recent)-1])
+		warnings = append(warnings, soakWarning{
+			Series: series.name,
+			From:   from,
+			To:     to,
+			Since:  recent[0].Time,
+			Message: fmt.Sprintf("%s rose from %d to %d over the last %d samples (%s) without falling",
+				series.name, from, to, len(recent), recent[len(recent)-1].Time.Sub(recent[0].Time).Round(time.Second)),
+		})
+	}
+	return warnings
+}
+
+// report returns the kept samples, each series' trend, and the growth warnings
+func (m *soakMonitor) report() gin.H {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trends := make(map[string]soakTrend, len(soakSeries))
+	if len(m.samples) > 0 {
+		for _, series := range soakSeries {
+			trend := soakTrend{
+				First:   series.value(m.samples[0]),
+				Last:    series.value(m.samples[len(m.samples)-1]),
+				Growing: m.growing[series.name],
+			}
+			trend.Min, trend.Max = trend.First, trend.First
+			for _, sample := range m.samples {
+				trend.Min = min(trend.Min, series.value(sample))
+				trend.Max = max(trend.Max, series.value(sample))
+			}
+			trends[series.name] = trend
+		}
+	}
+	warnings := m.warnings()
+	if warnings == nil {
+		warnings = []soakWarning{}
+	}
+	return gin.H{
+		"intervalSeconds": int(m.interval.Seconds()),
+		"growthSamples":   m.growthSamples,
+		"startedAt":       m.startedAt,
+		"samples":         append([]soakSample{}, m.samples...),
+		"series":          trends,
+		"warnings":        warnings,
+	}
+}
+
+// sampleSoak reads the goroutines, live heap, connected WebSocket clients, and queued messages
+func (s *Server) sampleSoak(now time.Time) soakSample {
+	values := []metrics.Sample{{Name: "/sched/goroutines:goroutines"}, {Name: "/gc/heap/live:bytes"}}
+	metrics.Read(values)
+	sample := soakSample{Time: now}
+	if values[0].Value.Kind() == metrics.KindUint64 {
+		sample.Goroutines = int64(values[0].Value.Uint64())
+	}
+	if values[1].Value.Kind() == metrics.KindUint64 {
+		sample.HeapLiveBytes = int64(values[1].Value.Uint64())
+	}
+	s.currentHub().live.Range(func(_, _ interface{}) bool {
+		sample.WSClients++
+		return true
+	})
+	_, queued := s.currentHub().deliveryLag(now)
+	sample.QueuedMessages = int64(queued)
+	return sample
+}
+
+// soakLoop samples every SOAK_SAMPLE_INTERVAL_SECONDS and logs series that start growing
+func (s *Server) soakLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.soak.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, warning := range s.soak.record(s.sampleSoak(time.Now())) {
+			logging.Printf("Soak monitor: %s - possible leak, see /debug/soak", warning.Message)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// soakHandler serves the soak-test report
+func (s *Server) soakHandler(c *gin.Context) {
+	report := s.soak.report()
+	report["timestamp"] = time.Now().Format(time.RFC3339)
+	c.JSON(http.StatusOK, report)
+}
+
+// writeSoakMetrics writes which series the soak monitor reports as growing, when it runs
+func (s *Server) writeSoakMetrics(w io.Writer) {
+	if s.soak == nil {
+		return
+	}
+	s.soak.mu.Lock()
+	growing := make(map[string]float64, len(soakSeries))
+	for _, series := range soakSeries {
+		growing[series.name] = 0
+		if s.soak.growing[series.name] {
+			growing[series.name] = 1
+		}
+	}
+	s.soak.mu.Unlock()
+	writeLabelledMetric(w, "bitwarden_reader_soak_growing", "gauge", "Whether the soak monitor sees the series grow without falling.", "series", growing)
+}
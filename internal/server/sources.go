@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+	"bitwarden-reader/internal/sources"
+)
+
+// newVaultSource creates the Vault source, or nil when VAULT_ADDR is unset or the auth settings are invalid
+func newVaultSource(cfg *config.Config) *sources.VaultSource {
+	if cfg.VaultAddr == "" {
+		return nil
+	}
+	auth := sources.VaultAuth{
+		Method:       cfg.VaultAuthMethod,
+		TokenFile:    cfg.VaultTokenFile,
+		Mount:        cfg.VaultAuthMount,
+		Role:         cfg.VaultAuthRole,
+		RoleID:       cfg.VaultRoleID,
+		SecretIDFile: cfg.VaultSecretIDFile,
+	}
+	vault, err := sources.NewVaultSource(cfg.VaultAddr, auth, cfg.VaultKVMount, cfg.VaultPathPrefix, cfg.VaultPaths)
+	if err != nil {
+		logging.Printf("Error configuring Vault source: %v", err)
+		return nil
+	}
+	return vault
+}
+
+// newSecretSources creates the configured sources read alongside Kubernetes
+func newSecretSources(cfg *config.Config, vault *sources.VaultSource) []reader.SecretSource {
+	var extra []reader.SecretSource
+	if cfg.FileSourceDir != "" {
+		extra = append(extra, sources.NewFileSource(cfg.FileSourceDir))
+	}
+	if vault != nil {
+		extra = append(extra, vault)
+	}
+	if cfg.AWSSecretsRegion != "" {
+		source, err := sources.NewAWSSecretsManagerSource(cfg.AWSSecretsRegion, cfg.AWSSecretsPrefix, cfg.AWSSecretsEndpoint)
+		if err != nil {
+			logging.Printf("Error configuring AWS Secrets Manager source: %v", err)
+		} else {
+			extra = append(extra, source)
+		}
+	}
+	for _, source := range extra {
+		logging.Printf("Reading secrets from additional source: %s", source.Name())
+	}
+	return extra
+}
+
+// newLocalSource creates the directory source standing in for Kubernetes, or nil unless running standalone with LOCAL_SECRETS_DIR
+func newLocalSource(cfg *config.Config, k8sClients *k8s.K8sClients) *sources.FileSource {
+	if k8sClients != nil || cfg.LocalSecretsDir == "" {
+		return nil
+	}
+	logging.Printf("Standalone mode: reading secrets from %s", cfg.LocalSecretsDir)
+	return sources.NewLocalSource(cfg.LocalSecretsDir)
+}
+
+// secretSources returns the sources to read for ctx, Kubernetes (or the local directory) first
+func (s *Server) secretSources(ctx context.Context) []reader.SecretSource {
+	all := make([]reader.SecretSource, 0, len(s.sources)+1)
+	if s.local != nil {
+		all = append(all, s.local)
+	} else {
+		all = append(all, reader.NewKubernetesSource(s.config.PodNamespace, s.clientsFor(ctx, s.config.PodNamespace)))
+	}
+	return s.withChaos(append(all, s.sources...))
+}
+
+// withChaos wraps the sources to apply injected failures when CHAOS_ENABLED is set
+func (s *Server) withChaos(sources []reader.SecretSource) []reader.SecretSource {
+	if s.chaos == nil {
+		return sources
+	}
+	for i, source := range sources {
+		sources[i] = s.chaos.Wrap(source)
+	}
+	return sources
+}
+
+// secretNames returns SECRET_NAMES, or every secret in the local directory when SECRET_NAMES is empty
+func (s *Server) secretNames() []string {
+	if s.local == nil || len(s.config.SecretNames) > 0 {
+		return s.config.SecretNames
+	}
+	names, err := s.local.SecretNames()
+	if err != nil {
+		logging.Printf("Error listing local secrets: %v", err)
+	}
+	return names
+}
+
+// watchLocalSecrets wakes the broadcast and hook loops whenever the local directory changes
+func (s *Server) watchLocalSecrets(ctx context.Context) {
+	interval := s.config.LocalSecretsPoll
+	if interval <= 0 {
+		interval = time.Second
+	}
+	s.local.Watch(ctx, interval, func() {
+		logging.Printf("Local secrets changed in %s", s.local.Dir())
+		s.secretEvents.notify()
+	})
+}
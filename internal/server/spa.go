@@ -0,0 +1,115 @@
+package server
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cache-Control for single-page UI files: hashed assets never change, everything else is revalidated
+const (
+	immutableAssetCache = "public, max-age=31536000, immutable"
+	revalidateCache     = "no-cache"
+)
+
+// spaAssets serves a compiled single-page UI, such as a Vite or webpack build
+type spaAssets struct {
+	files fs.FS
+}
+
+// loadSPA returns the single-page UI in dir, or nil when dir has no index.html
+// Any fs.FS works, so the build can also be embedded with embed.FS
+func loadSPA(dir string) *spaAssets {
+	if dir == "" {
+		return nil
+	}
+	files := os.DirFS(dir)
+	if _, err := fs.Stat(files, "index.html"); err != nil {
+		return nil
+	}
+	logging.Printf("Serving the single-page UI from %s", dir)
+	return &spaAssets{files: files}
+}
+
+// isHashedAsset reports whether a file name carries a content hash, as in app.3f9a1c2b.js or index-B4x9k2Qa.css
+// A hash is the last dot- or dash-separated part before the extension, at least 8 letters and digits with a digit among them
+func isHashedAsset(name string) bool {
+	base := path.Base(name)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	i := strings.LastIndexAny(stem, ".-")
+	if i < 0 {
+		return false
+	}
+	hash := stem[i+1:]
+	if len(hash) < 8 || !strings.ContainsAny(hash, "0123456789") {
+		return false
+	}
+	for _, r := range hash {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// serve writes the named file with its cache headers, reporting false when it doesn't exist
+func (a *spaAssets) serve(c *gin.Context, name string) bool {
+	file, err := a.files.Open(name)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+	if isHashedAsset(name) {
+		c.Header("Cache-Control", immutableAssetCache)
+	} else {
+		c.Header("Cache-Control", revalidateCache)
+	}
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), content)
+	return true
+}
+
+// spaHandler serves files of the single-page UI, answering client-side routes with index.html
+func (s *Server) spaHandler(c *gin.Context) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": s.tr(c, "Method not allowed")})
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+c.Request.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	if s.spa.serve(c, name) {
+		return
+	}
+	// A missing file is a 404; only extensionless paths are client-side routes
+	if path.Ext(name) != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": s.tr(c, "Not found")})
+		return
+	}
+	s.spa.serve(c, "index.html")
+}
+
+// noRouteHandler keeps unknown API paths as JSON 404s and hands everything else to the single-page UI
+func (s *Server) noRouteHandler(c *gin.Context) {
+	requestPath := c.Request.URL.Path
+	if strings.HasPrefix(requestPath, "/api/") || requestPath == "/ws" {
+		c.JSON(http.StatusNotFound, gin.H{"error": s.tr(c, "Not found")})
+		return
+	}
+	s.spaHandler(c)
+}
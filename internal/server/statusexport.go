@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"time"
+
+	"bitwarden-reader/internal/reader"
+	"bitwarden-reader/internal/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusColumns are the columns of the exported status table
+var statusColumns = []string{"name", "source", "namespace", "found", "key_count", "sync_status", "last_sync", "age"}
+
+// statusRow builds one table row; values are never exported, only how many keys exist
+func statusRow(secret reader.SecretInfo, namespace string, now time.Time) []interface{} {
+	age := ""
+	if lastSync, err := time.Parse(time.RFC3339, secret.SyncInfo.LastSuccessfulSync); err == nil {
+		age = now.Sub(lastSync).Round(time.Second).String()
+	}
+	return []interface{}{
+		secret.Name,
+		secret.Source,
+		namespace,
+		secret.Found,
+		len(secret.Keys),
+		secret.SyncInfo.SyncStatus,
+		secret.SyncInfo.LastSuccessfulSync,
+		age,
+	}
+}
+
+// exportStatusHandler downloads the secrets status table as CSV (default) or XLSX (?format=xlsx)
+func (s *Server) exportStatusHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+		return
+	}
+
+	secrets, err := s.readSecrets(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now().UTC()
+	rows := make([][]interface{}, 0, len(secrets))
+	for _, secret := range secrets {
+		rows = append(rows, statusRow(secret, s.config.PodNamespace, now))
+	}
+	if s.config.MemoryHygiene {
+		wipeSecretValues(secrets)
+	}
+
+	filename := fmt.Sprintf("secrets-status-%s.%s", now.Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "xlsx" {
+		c.Header("Content-Type", spreadsheet.ContentType)
+		c.Status(http.StatusOK)
+		if err := spreadsheet.WriteXLSX(c.Writer, "Secrets", statusColumns, rows); err != nil {
+			_ = c.Error(err)
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(statusColumns)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = fmt.Sprint(value)
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Overall states reported by /statusz, from best to worst
+const (
+	statuszOK       = "OK"
+	statuszDegraded = "DEGRADED"
+	statuszFailing  = "FAILING"
+)
+
+// statuszRank orders the states so the worst one wins
+var statuszRank = map[string]int{
+	statuszOK:       0,
+	statuszDegraded: 1,
+	statuszFailing:  2,
+}
+
+// statuszReport is the summary served to uptime monitors
+type statuszReport struct {
+	Status               string   `json:"status"`
+	Total                int      `json:"total"`
+	Found                int      `json:"found"`
+	Unhealthy            int      `json:"unhealthy"`
+	SyncFailing          int      `json:"syncFailing"`
+	OldestSync           string   `json:"oldestSync,omitempty"`
+	OldestSyncAgeSeconds int64    `json:"oldestSyncAgeSeconds,omitempty"`
+	Reasons              []string `json:"reasons,omitempty"`
+	Timestamp            string   `json:"timestamp"`
+}
+
+// raise sets the status to state when that is worse, recording why
+func (r *statuszReport) raise(state, format string, args ...interface{}) {
+	if statuszRank[state] > statuszRank[r.Status] {
+		r.Status = state
+	}
+	r.Reasons = append(r.Reasons, fmt.Sprintf(format, args...))
+}
+
+// buildStatuszReport summarizes the secrets against the STATUSZ_* thresholds
+func (s *Server) buildStatuszReport(secrets []reader.SecretInfo, readErr error, now time.Time) statuszReport {
+	report := statuszReport{Status: statuszOK, Total: len(secrets), Timestamp: now.Format(time.RFC3339)}
+	if readErr != nil {
+		report.raise(statuszFailing, "Reading secrets failed: %v", readErr)
+		return report
+	}
+	if issue := reader.NamespaceIssue(secrets); issue != nil {
+		report.raise(statuszFailing, "%s", issue.Message)
+	}
+
+	var oldestAge time.Duration
+	_, details := reader.AggregateHealth(secrets)
+	for i, secret := range secrets {
+		if secret.Found {
+			report.Found++
+		}
+		if details[i].Status == reader.HealthDegraded {
+			report.Unhealthy++
+		}
+		if secret.SyncInfo.SyncStatus == "False" {
+			report.SyncFailing++
+		}
+		if synced, err := time.Parse(time.RFC3339, secret.SyncInfo.LastSuccessfulSync); err == nil {
+			if age := now.Sub(synced); report.OldestSync == "" || age > oldestAge {
+				report.OldestSync, oldestAge = secret.Name, age
+			}
+		}
+	}
+	if report.OldestSync != "" {
+		report.OldestSyncAgeSeconds = int64(oldestAge.Seconds())
+	}
+
+	if report.Unhealthy > 0 {
+		percent := s.config.StatuszFailingUnhealthy
+		if percent > 0 && report.Unhealthy*100 >= percent*report.Total {
+			report.raise(statuszFailing, "%d of %d secrets unhealthy", report.Unhealthy, report.Total)
+		} else {
+			report.raise(statuszDegraded, "%d of %d secrets unhealthy", report.Unhealthy, report.Total)
+		}
+	}
+	switch age := oldestAge.Round(time.Second); {
+	case s.config.StatuszFailingSyncAge > 0 && oldestAge > s.config.StatuszFailingSyncAge:
+		report.raise(statuszFailing, "%s last synced %s ago", report.OldestSync, age)
+	case s.config.StatuszDegradedSyncAge > 0 && oldestAge > s.config.StatuszDegradedSyncAge:
+		report.raise(statuszDegraded, "%s last synced %s ago", report.OldestSync, age)
+	}
+	return report
+}
+
+// statuszHandler serves a minimal sync health summary for uptime monitors, as plain text by default or JSON
+// with ?format=json or Accept: application/json; it answers 503 from the STATUSZ_FAIL_ON state up
+func (s *Server) statuszHandler(c *gin.Context) {
+	format := c.Query("format")
+	if format != "" && format != "text" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be text or json"})
+		return
+	}
+
+	secrets, err := s.readSecrets(c.Request.Context())
+	report := s.buildStatuszReport(secrets, err, time.Now())
+	wipeSecretValues(secrets)
+
+	failOn := statuszFailing
+	if s.config.StatuszFailOn == "degraded" {
+		failOn = statuszDegraded
+	}
+	code := http.StatusOK
+	if statuszRank[report.Status] >= statuszRank[failOn] {
+		code = http.StatusServiceUnavailable
+	}
+
+	if format == "" && c.NegotiateFormat(gin.MIMEPlain, gin.MIMEJSON) == gin.MIMEJSON {
+		format = "json"
+	}
+	if format == "json" {
+		c.JSON(code, report)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(report.Status + "\n")
+	fmt.Fprintf(&b, "secrets: %d total, %d found, %d unhealthy, %d sync failing\n", report.Total, report.Found, report.Unhealthy, report.SyncFailing)
+	if report.OldestSync != "" {
+		fmt.Fprintf(&b, "oldest sync: %s, %s ago\n", report.OldestSync, time.Duration(report.OldestSyncAgeSeconds)*time.Second)
+	}
+	for _, reason := range report.Reasons {
+		b.WriteString("- " + reason + "\n")
+	}
+	c.String(code, b.String())
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/reader"
+	"bitwarden-reader/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lookupConfiguredSecret reads a configured secret for template rendering
+// Templates may only reference secrets listed in SECRET_NAMES
+func (s *Server) lookupConfiguredSecret(ctx context.Context, name string) (map[string][]byte, error) {
+	if _, unknown := s.selectConfiguredSecrets([]string{name}); len(unknown) > 0 {
+		return nil, fmt.Errorf("secret %q is not in SECRET_NAMES", name)
+	}
+	secret, err := k8s.ReadSecret(ctx, name, s.config.PodNamespace, s.clientsFor(ctx, s.config.PodNamespace).Clientset)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", name, err)
+	}
+	// Hidden values never reach the template, even through secretKeys and range
+	data := make(map[string][]byte, len(secret.Data))
+	for key, value := range secret.Data {
+		if s.visibility.Level(name, key) != reader.VisibilityHidden {
+			data[key] = value
+		}
+	}
+	return data, nil
+}
+
+// checkTemplateKey refuses template references to keys the KEY_VISIBILITY policy hides
+func (s *Server) checkTemplateKey(name, key string) error {
+	if s.visibility.Level(name, key) == reader.VisibilityHidden {
+		return fmt.Errorf("key %s of secret %s is hidden by KEY_VISIBILITY", key, name)
+	}
+	return nil
+}
+
+// apiTemplatesHandler lists the templates loaded from TEMPLATES_DIR
+func (s *Server) apiTemplatesHandler(c *gin.Context) {
+	names := make([]string, 0, len(s.templates))
+	for name := range s.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": names,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// renderTemplateHandler renders a template with the current secret values
+func (s *Server) renderTemplateHandler(c *gin.Context) {
+	name := c.Param("name")
+	tmpl, ok := s.templates[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Template '%s' not found", name),
+		})
+		return
+	}
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	content, err := render.ExecuteChecked(c.Request.Context(), tmpl, s.lookupConfiguredSecret, s.checkTemplateKey)
+	if err != nil {
+		s.recordAudit(c, "template.render", name, s.config.PodNamespace, audit.OutcomeFailure, map[string]string{"error": err.Error()})
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("Failed to render template '%s': %v", name, err),
+		})
+		return
+	}
+	s.recordAudit(c, "template.render", name, s.config.PodNamespace, audit.OutcomeSuccess, nil)
+
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", content)
+	clear(content)
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTicketLength bounds the ticket ID accepted with a trigger request
+const maxTicketLength = 128
+
+// validateTicket checks a ticket ID is short and printable, as it ends up in an annotation
+func validateTicket(ticket string) error {
+	if len(ticket) > maxTicketLength {
+		return fmt.Errorf("ticket must be at most %d characters", maxTicketLength)
+	}
+	if strings.IndexFunc(ticket, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		return fmt.Errorf("ticket must not contain control characters")
+	}
+	return nil
+}
+
+// triggerAnnotations expands TRIGGER_ANNOTATIONS for a request, leaving out annotations that expand to nothing
+// Values may use {user}, {ticket}, {requestId}, and {time}
+func (s *Server) triggerAnnotations(c *gin.Context, ticket string) map[string]string {
+	if len(s.config.TriggerAnnotations) == 0 {
+		return nil
+	}
+	replacer := strings.NewReplacer(
+		"{user}", requestActor(c),
+		"{ticket}", ticket,
+		"{requestId}", c.GetString(requestIDKey),
+		"{time}", time.Now().UTC().Format(time.RFC3339),
+	)
+	annotations := make(map[string]string, len(s.config.TriggerAnnotations))
+	for key, template := range s.config.TriggerAnnotations {
+		if value := strings.TrimSpace(replacer.Replace(template)); value != "" {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bitwarden-reader/internal/history"
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Trigger verification polling
+const (
+	triggerVerifyPoll     = 5 * time.Second
+	defaultHistoryResults = 100
+)
+
+// syncState returns the CRD's lastSuccessfulSyncTime and its failing sync condition, both "" when the CRD can't be read
+func (s *Server) syncState(ctx context.Context, namespace, name string) (lastSync, failure string) {
+	info, err := k8s.GetBitwardenSecretCRD(ctx, name, namespace, s.clientsFor(ctx, namespace).DynamicClient)
+	if err != nil || info == nil {
+		return "", ""
+	}
+	return info.LastSuccessfulSync, syncFailure(info)
+}
+
+// syncFailure describes the CRD's sync condition when it reports a failure, or returns ""
+func syncFailure(info *k8s.CRDInfo) string {
+	if info.SyncStatus != "False" {
+		return ""
+	}
+	failure := info.SyncReason
+	if info.SyncMessage != "" {
+		if failure != "" {
+			failure += ": "
+		}
+		failure += info.SyncMessage
+	}
+	if failure == "" {
+		failure = "sync failed"
+	}
+	return failure
+}
+
+// triggerOutcome summarizes the per-secret results of a trigger
+func triggerOutcome(secrets []history.TriggerSecret) string {
+	triggered := 0
+	for _, secret := range secrets {
+		if secret.Triggered {
+			triggered++
+		}
+	}
+	switch triggered {
+	case len(secrets):
+		return history.OutcomeSuccess
+	case 0:
+		return history.OutcomeFailure
+	default:
+		return history.OutcomePartial
+	}
+}
+
+// saveTrigger stores a trigger record and starts verifying that the triggered syncs happened,
+// reporting whether verification started
+func (s *Server) saveTrigger(record history.TriggerRecord) bool {
+	if err := s.history.SaveTrigger(record); err != nil {
+		logging.Printf("Error saving trigger history: %v", err)
+	}
+	if record.Outcome == history.OutcomeFailure || s.config.TriggerVerifyTimeout <= 0 {
+		return false
+	}
+	go s.verifyTrigger(record)
+	return true
+}
+
+// verifyTrigger polls the triggered CRDs until each has synced or reported a failure, or the timeout elapses,
+// then stores and broadcasts the result
+func (s *Server) verifyTrigger(record history.TriggerRecord) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.TriggerVerifyTimeout)
+	defer cancel()
+
+	// The stored record shares the slice; work on a copy
+	record.Secrets = append([]history.TriggerSecret(nil), record.Secrets...)
+
+	ticker := time.NewTicker(triggerVerifyPoll)
+	defer ticker.Stop()
+
+wait:
+	for s.checkSyncsAdvanced(ctx, record.Namespace, record.Secrets) > 0 {
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	// Whatever is still pending did not sync within the timeout
+	for i := range record.Secrets {
+		if record.Secrets[i].Triggered && record.Secrets[i].Result == "" {
+			advanced := false
+			record.Secrets[i].Advanced = &advanced
+			record.Secrets[i].Result = history.SyncTimedOut
+		}
+	}
+	verifiedAt := time.Now().UTC()
+	record.VerifiedAt = &verifiedAt
+	record.Result = verifiedResult(record.Secrets)
+	if err := s.history.SaveTrigger(record); err != nil {
+		logging.Printf("Error saving trigger verification: %v", err)
+	}
+	logging.Printf("Trigger %s %s", record.ID, record.Result)
+	s.publishTriggerResult(record)
+}
+
+// checkSyncsAdvanced marks triggered secrets whose sync time moved or that newly report a failing condition,
+// and returns how many are still pending
+func (s *Server) checkSyncsAdvanced(ctx context.Context, namespace string, secrets []history.TriggerSecret) int {
+	pending := 0
+	for i := range secrets {
+		secret := &secrets[i]
+		if !secret.Triggered || secret.Result != "" {
+			continue
+		}
+		after, failure := s.syncState(ctx, namespace, secret.Name)
+		switch {
+		case after != "" && after != secret.SyncBefore:
+			advanced := true
+			secret.SyncAfter, secret.Advanced, secret.Result = after, &advanced, history.SyncSucceeded
+		case failure != "" && failure != secret.FailureBefore:
+			// A failure the CRD already reported before the trigger says nothing about this sync
+			advanced := false
+			secret.Failure, secret.Advanced, secret.Result = failure, &advanced, history.SyncFailed
+		default:
+			pending++
+		}
+	}
+	return pending
+}
+
+// verifiedResult summarizes the verified secrets: failed if any failed, else timed-out if any timed out
+func verifiedResult(secrets []history.TriggerSecret) string {
+	result := history.SyncSucceeded
+	for _, secret := range secrets {
+		switch secret.Result {
+		case history.SyncFailed:
+			return history.SyncFailed
+		case history.SyncTimedOut:
+			result = history.SyncTimedOut
+		}
+	}
+	return result
+}
+
+// publishTriggerResult sends a verified trigger record to WebSocket clients that may see its namespace
+func (s *Server) publishTriggerResult(record history.TriggerRecord) {
+	if !s.currentHub().publish(&broadcastPayload{
+		event:     true,
+		namespace: record.Namespace,
+		fields: map[string]interface{}{
+			"type":      messageTypeTriggerResult,
+			"trigger":   record,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		},
+	}) {
+		logging.Printf("WebSocket hub busy, dropped trigger result for %s", record.ID)
+	}
+}
+
+// triggerHandler returns one trigger-sync request by ID, including its verified result once known
+func (s *Server) triggerHandler(c *gin.Context) {
+	record, ok := s.history.Trigger(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": s.tr(c, "Trigger not found")})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// triggerHistoryHandler lists past trigger-sync requests, newest first
+// Optional filters: secret, initiator, since (RFC3339 or unix seconds), and limit
+func (s *Server) triggerHistoryHandler(c *gin.Context) {
+	filter := history.TriggerFilter{
+		Secret:    c.Query("secret"),
+		Initiator: c.Query("initiator"),
+		Limit:     defaultHistoryResults,
+	}
+	if since := c.Query("since"); since != "" {
+		filter.Since = parseSince(since)
+		if filter.Since.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC3339 timestamp or unix seconds"})
+			return
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	records := s.history.Triggers(filter)
+	c.JSON(http.StatusOK, gin.H{
+		"triggers": records,
+		"count":    len(records),
+	})
+}
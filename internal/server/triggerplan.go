@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// triggerPlanSecret is one BitwardenSecret a trigger-sync would patch
+type triggerPlanSecret struct {
+	Name               string `json:"name"`
+	CRDFound           bool   `json:"crdFound"`
+	LastSuccessfulSync string `json:"lastSuccessfulSync,omitempty"`
+	SyncStatus         string `json:"syncStatus,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+// triggerSecretNames trims the requested names and drops empty and repeated ones, defaulting to SECRET_NAMES
+func (s *Server) triggerSecretNames(requested []string) []string {
+	if len(requested) == 0 {
+		requested = s.config.SecretNames
+	}
+	seen := make(map[string]bool, len(requested))
+	var names []string
+	for _, name := range requested {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// triggerSubject binds a confirmation token to the namespace and the exact set of secrets, in any order
+func triggerSubject(namespace string, names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return "trigger-sync:" + namespace + ":" + strings.Join(sorted, ",")
+}
+
+// triggerNeedsConfirmation reports whether triggering count secrets needs a plan's confirmation token
+func (s *Server) triggerNeedsConfirmation(count int) bool {
+	return s.config.TriggerConfirmAbove > 0 && count > s.config.TriggerConfirmAbove
+}
+
+// checkTriggerBatch returns an error message when count exceeds TRIGGER_MAX_BATCH_SIZE
+func (s *Server) checkTriggerBatch(count int) string {
+	if s.config.TriggerMaxBatch > 0 && count > s.config.TriggerMaxBatch {
+		return fmt.Sprintf("Trigger of %d secrets exceeds the maximum batch size of %d - split it into smaller requests", count, s.config.TriggerMaxBatch)
+	}
+	return ""
+}
+
+// triggerPause waits a random part of TRIGGER_JITTER_MS between patches, returning false when ctx ends first
+func (s *Server) triggerPause(ctx context.Context) bool {
+	if s.config.TriggerJitter <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(time.Duration(rand.Int64N(int64(s.config.TriggerJitter))))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// triggerSync patches one BitwardenSecret to sync; within TRIGGER_DEDUP_WINDOW_SECONDS of the last trigger
+// from any replica it patches nothing and returns a *k8s.TriggerDuplicateError
+func (s *Server) triggerSync(c *gin.Context, namespace, name string, annotations map[string]string) error {
+	ctx := c.Request.Context()
+	dynamicClient := s.clientsFor(ctx, namespace).DynamicClient
+	if s.config.TriggerDedupWindow <= 0 {
+		return k8s.TriggerSync(ctx, name, namespace, s.config.TriggerAnnotationKeys, annotations, dynamicClient)
+	}
+	claim := k8s.TriggerClaim{Time: time.Now().UTC(), Replica: s.config.PodName, User: requestActor(c)}
+	return k8s.TriggerSyncOnce(ctx, name, namespace, s.config.TriggerAnnotationKeys, annotations, claim, s.config.TriggerDedupWindow, dynamicClient)
+}
+
+// triggerPlanHandler lists what a trigger-sync of ?secretNames=a,b (default SECRET_NAMES) in ?namespace=
+// (default POD_NAMESPACE) would patch, with the confirmation token the trigger needs when it is a bulk trigger
+func (s *Server) triggerPlanHandler(c *gin.Context) {
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	namespace, ok := s.requestNamespace(c, k8s.BitwardenSecretGVR.Group, k8s.BitwardenSecretGVR.Resource, "patch")
+	if !ok {
+		return
+	}
+	var requested []string
+	if list := c.Query("secretNames"); list != "" {
+		requested = strings.Split(list, ",")
+	}
+	names := s.triggerSecretNames(requested)
+	if msg := s.checkTriggerBatch(len(names)); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	dynamicClient := s.clientsFor(ctx, namespace).DynamicClient
+	// One List answers for every secret; without list permission each CRD is read on its own
+	crds, err := k8s.ListBitwardenSecretCRDs(ctx, namespace, dynamicClient)
+	secrets := make([]triggerPlanSecret, 0, len(names))
+	for _, name := range names {
+		info := crds[name]
+		if err != nil {
+			info, _ = k8s.GetBitwardenSecretCRD(ctx, name, namespace, dynamicClient)
+		}
+		secret := triggerPlanSecret{Name: name}
+		if info != nil && info.CRDFound {
+			secret.CRDFound = true
+			secret.LastSuccessfulSync = info.LastSuccessfulSync
+			secret.SyncStatus = info.SyncStatus
+		} else {
+			secret.Error = "BitwardenSecret not found"
+		}
+		secrets = append(secrets, secret)
+	}
+
+	response := gin.H{
+		"namespace":            namespace,
+		"secrets":              secrets,
+		"count":                len(secrets),
+		"maxBatchSize":         s.config.TriggerMaxBatch,
+		"jitterMs":             s.config.TriggerJitter.Milliseconds(),
+		"confirmationRequired": s.triggerNeedsConfirmation(len(names)),
+	}
+	if s.triggerNeedsConfirmation(len(names)) {
+		token, expiresAt, err := s.confirmations.issue(triggerSubject(namespace, names), requestActor(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("Failed to issue confirmation token: %v", err),
+			})
+			return
+		}
+		response["confirmationToken"] = token
+		response["expiresAt"] = expiresAt.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, response)
+}
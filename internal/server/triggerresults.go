@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	stderrors "errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// triggerResultsVersion is the trigger-sync response with per-secret results, requested with
+// Accept: application/json; version=2
+const triggerResultsVersion = "2"
+
+// Statuses of a secret in a trigger-sync response
+const (
+	triggerStatusTriggered    = "triggered"
+	triggerStatusDeduplicated = "deduplicated"
+	triggerStatusFailed       = "failed"
+)
+
+// triggerItemResult is the outcome of triggering one secret
+type triggerItemResult struct {
+	Secret    string `json:"secret"`
+	CRD       string `json:"crd"`
+	Namespace string `json:"namespace"`
+	Status    string `json:"status"`
+	// HTTPStatus is the status code the secret would have been answered with on its own
+	HTTPStatus int              `json:"httpStatus"`
+	ErrorCode  string           `json:"errorCode,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	Cooldown   *triggerCooldown `json:"cooldown,omitempty"`
+}
+
+// triggerCooldown describes the earlier trigger a deduplicated secret joined, and when it can be triggered again
+type triggerCooldown struct {
+	TriggeredAt       time.Time `json:"triggeredAt"`
+	TriggeredBy       string    `json:"triggeredBy,omitempty"`
+	Replica           string    `json:"replica,omitempty"`
+	RetryAfterSeconds int       `json:"retryAfterSeconds"`
+}
+
+// wantsTriggerResults reports whether the client asked for the per-secret trigger-sync response, which
+// /api/v2 always returns
+func wantsTriggerResults(c *gin.Context) bool {
+	if strings.HasPrefix(c.Request.URL.Path, apiV2Prefix) {
+		return true
+	}
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && params["version"] == triggerResultsVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// triggerItem describes the outcome of triggering crd for secret
+func (s *Server) triggerItem(secret, crd, namespace string, err error, now time.Time) triggerItemResult {
+	item := triggerItemResult{Secret: secret, CRD: crd, Namespace: namespace, Status: triggerStatusTriggered, HTTPStatus: http.StatusOK}
+	var duplicate *k8s.TriggerDuplicateError
+	switch {
+	case err == nil:
+	case stderrors.As(err, &duplicate):
+		item.Status = triggerStatusDeduplicated
+		retryAfter := duplicate.Claim.Time.Add(s.config.TriggerDedupWindow).Sub(now)
+		item.Cooldown = &triggerCooldown{
+			TriggeredAt:       duplicate.Claim.Time,
+			TriggeredBy:       duplicate.Claim.User,
+			Replica:           duplicate.Claim.Replica,
+			RetryAfterSeconds: int(max(retryAfter, 0).Round(time.Second).Seconds()),
+		}
+	default:
+		item.Status = triggerStatusFailed
+		item.Error = err.Error()
+		item.ErrorCode, item.HTTPStatus = triggerErrorCode(err)
+	}
+	return item
+}
+
+// triggerErrorCode classifies a failed trigger
+func triggerErrorCode(err error) (string, int) {
+	switch {
+	case stderrors.Is(err, context.Canceled), stderrors.Is(err, context.DeadlineExceeded):
+		return "cancelled", http.StatusServiceUnavailable
+	case errors.IsNotFound(err):
+		return "not_found", http.StatusNotFound
+	case errors.IsForbidden(err):
+		return "forbidden", http.StatusForbidden
+	case errors.IsConflict(err):
+		return "conflict", http.StatusConflict
+	default:
+		return "patch_failed", http.StatusBadGateway
+	}
+}
+
+// triggerResultsStatus returns the response status for the results: 200 when no secret failed, 207 when some
+// did, and the failures' own status when all failed alike, otherwise 207
+func triggerResultsStatus(items []triggerItemResult) int {
+	failed := 0
+	status := 0
+	for _, item := range items {
+		if item.Status != triggerStatusFailed {
+			continue
+		}
+		failed++
+		if status == 0 {
+			status = item.HTTPStatus
+		} else if status != item.HTTPStatus {
+			status = http.StatusMultiStatus
+		}
+	}
+	switch {
+	case failed == 0:
+		return http.StatusOK
+	case failed < len(items):
+		return http.StatusMultiStatus
+	default:
+		return status
+	}
+}
+
+// respondTriggerResults writes the per-secret trigger-sync response
+func respondTriggerResults(c *gin.Context, triggerID string, verifying bool, items []triggerItemResult) {
+	summary := map[string]int{
+		triggerStatusTriggered:    0,
+		triggerStatusDeduplicated: 0,
+		triggerStatusFailed:       0,
+	}
+	for _, item := range items {
+		summary[item.Status]++
+	}
+	c.Header("Content-Type", "application/json; charset=utf-8; version="+triggerResultsVersion)
+	c.JSON(triggerResultsStatus(items), gin.H{
+		"triggerId": triggerID,
+		"verifying": verifying,
+		"total":     len(items),
+		"summary":   summary,
+		"results":   items,
+	})
+}
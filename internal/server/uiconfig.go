@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"bitwarden-reader/internal/reader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactionPolicy summarizes how secret values are exposed to the UI, from SHOW_SECRET_VALUES,
+// KEY_VISIBILITY, and VALUE_AUTO_HIDE_SECONDS
+type redactionPolicy struct {
+	// Mode is how values are shown by default: visible, masked, or hidden when none are served
+	Mode            string `json:"mode"`
+	ValuesInPayload bool   `json:"valuesInPayload"`
+	// PerKey tells that KEY_VISIBILITY overrides the mode for some keys, named in VisibleKeys and HiddenKeys
+	PerKey          bool `json:"perKey"`
+	AutoHideSeconds int  `json:"autoHideSeconds"`
+}
+
+// uiFeatures lists which optional UI capabilities are enabled
+type uiFeatures struct {
+	TriggerSync bool `json:"triggerSync"`
+	WebSocket   bool `json:"webSocket"`
+	LongPolling bool `json:"longPolling"`
+	ReadOnly    bool `json:"readOnly"`
+	// Flags are the feature flags by name, so the UI can follow them too
+	Flags map[string]bool `json:"flags"`
+}
+
+// uiBanner is the environment banner shown above the dashboard, such as "PRODUCTION" in red
+type uiBanner struct {
+	Text  string `json:"text"`
+	Color string `json:"color,omitempty"`
+}
+
+// uiTheme is the branding the UI renders with
+type uiTheme struct {
+	ColorScheme string    `json:"colorScheme"`
+	AccentColor string    `json:"accentColor,omitempty"`
+	LogoURL     string    `json:"logoUrl,omitempty"`
+	Banner      *uiBanner `json:"banner,omitempty"`
+}
+
+// uiConfigResponse is the payload served to the frontend at startup
+type uiConfigResponse struct {
+	Title                  string          `json:"title"`
+	Version                string          `json:"version"`
+	Namespace              string          `json:"namespace"`
+	PodName                string          `json:"podName"`
+	RefreshIntervalSeconds int             `json:"refreshIntervalSeconds"`
+	HeartbeatSeconds       int             `json:"heartbeatSeconds"`
+	ShowValues             bool            `json:"showValues"`
+	Redaction              redactionPolicy `json:"redaction"`
+	Features               uiFeatures      `json:"features"`
+	Capabilities           capabilities    `json:"capabilities"`
+	Theme                  uiTheme         `json:"theme"`
+	// ManualSync tells whether the operator acts on triggered syncs, so the UI can warn before a no-op
+	ManualSync manualSyncSupport `json:"manualSync"`
+	// Language is the negotiated language, and Messages its catalog for texts the frontend renders itself
+	Language  string            `json:"language"`
+	Languages []string          `json:"languages"`
+	Messages  map[string]string `json:"messages"`
+}
+
+// buildUIConfig assembles the UI configuration from the server config, the user's capabilities, and their language
+func (s *Server) buildUIConfig(ctx context.Context, caps capabilities, lang string) uiConfigResponse {
+	return uiConfigResponse{
+		Title:                  s.config.AppTitle,
+		Version:                s.config.AppVersion,
+		Namespace:              s.config.PodNamespace,
+		PodName:                s.config.PodName,
+		RefreshIntervalSeconds: int(s.config.DashboardRefreshInterval.Seconds()),
+		HeartbeatSeconds:       int(s.config.WSHeartbeatInterval.Seconds()),
+		ShowValues:             s.config.ShowSecretValues,
+		Redaction:              s.redactionPolicy(),
+		Features: uiFeatures{
+			TriggerSync: s.k8sClients != nil && !s.config.ReadOnly,
+			WebSocket:   true,
+			LongPolling: true,
+			ReadOnly:    s.config.ReadOnly,
+			Flags:       s.features.Values(),
+		},
+		Capabilities: caps,
+		Theme:        s.uiTheme(),
+		ManualSync:   s.manualSyncSupport(ctx),
+		Language:     lang,
+		Languages:    s.locales.Languages(),
+		Messages:     s.locales.Messages(lang),
+	}
+}
+
+// redactionPolicy derives the UI's redaction policy from the visibility settings
+func (s *Server) redactionPolicy() redactionPolicy {
+	if s.visibility.HidesAll() {
+		return redactionPolicy{Mode: reader.VisibilityHidden}
+	}
+	policy := redactionPolicy{
+		Mode:            reader.VisibilityMasked,
+		ValuesInPayload: true,
+		PerKey:          s.visibility != nil,
+		AutoHideSeconds: int(s.config.ValueAutoHide.Seconds()),
+	}
+	if s.config.ShowSecretValues {
+		policy.Mode = reader.VisibilityVisible
+	}
+	return policy
+}
+
+// uiTheme returns the configured branding
+func (s *Server) uiTheme() uiTheme {
+	theme := uiTheme{
+		ColorScheme: s.config.UIColorScheme,
+		AccentColor: s.config.UIAccentColor,
+		LogoURL:     s.config.UILogoURL,
+	}
+	if s.config.UIBannerText != "" {
+		theme.Banner = &uiBanner{Text: s.config.UIBannerText, Color: s.config.UIBannerColor}
+	}
+	return theme
+}
+
+// uiConfigHandler returns the configuration the frontend needs to render itself
+func (s *Server) uiConfigHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.buildUIConfig(c.Request.Context(), s.requestCapabilities(c), c.GetString(languageKey)))
+}
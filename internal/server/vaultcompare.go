@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"bitwarden-reader/internal/audit"
+	"bitwarden-reader/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// vaultComparison compares one configured secret with its Vault counterpart; left is Kubernetes, right is Vault
+type vaultComparison struct {
+	Secret    string          `json:"secret"`
+	VaultPath string          `json:"vaultPath"`
+	Status    string          `json:"status"`
+	Keys      []keyComparison `json:"keys,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// compareWithVault reads one secret from Kubernetes and Vault and compares them by value hash
+func (s *Server) compareWithVault(c *gin.Context, name string) vaultComparison {
+	ctx := c.Request.Context()
+	result := vaultComparison{Secret: name, VaultPath: s.vault.Path(name)}
+
+	secret, err := k8s.ReadSecret(ctx, name, s.config.PodNamespace, s.clientsFor(ctx, s.config.PodNamespace).Clientset)
+	if err != nil && !k8s.IsSecretNotFound(err) {
+		result.Error = fmt.Sprintf("Error reading secret: %v", err)
+		return result
+	}
+	var left map[string][]byte
+	if err == nil {
+		left = secret.Data
+		defer k8s.WipeSecretData(secret.Data)
+	}
+
+	values, found, err := s.vault.ReadData(ctx, name)
+	if err != nil {
+		result.Error = fmt.Sprintf("Error reading from Vault: %v", err)
+		return result
+	}
+	var right map[string][]byte
+	if found {
+		right = make(map[string][]byte, len(values))
+		for key, value := range values {
+			right[key] = []byte(value)
+		}
+		defer k8s.WipeSecretData(right)
+	}
+
+	switch {
+	case left == nil && right == nil:
+		result.Status = compareMissing
+	case left == nil:
+		result.Status = compareMissingLeft
+	case right == nil:
+		result.Status = compareMissingRight
+	default:
+		result.Keys = compareSecretData(left, right)
+		result.Status = compareIdentical
+		for _, key := range result.Keys {
+			if key.Status != compareIdentical {
+				result.Status = compareDifferent
+				break
+			}
+		}
+	}
+	return result
+}
+
+// vaultCompareHandler compares the configured secrets, or ?secret=, with Vault by key set and value hash
+func (s *Server) vaultCompareHandler(c *gin.Context) {
+	if s.vault == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Vault is not configured - set VAULT_ADDR",
+		})
+		return
+	}
+	if s.k8sClients == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": s.tr(c, "Kubernetes client not available - running in standalone mode"),
+		})
+		return
+	}
+
+	names := s.configuredSecretNames()
+	if requested := c.Query("secret"); requested != "" {
+		configured := false
+		for _, name := range names {
+			configured = configured || name == requested
+		}
+		if !configured {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Secret '%s' is not in SECRET_NAMES", requested),
+			})
+			return
+		}
+		names = []string{requested}
+	}
+
+	summary := make(map[string]int)
+	results := make([]vaultComparison, 0, len(names))
+	for _, name := range names {
+		result := s.compareWithVault(c, name)
+		if result.Error != "" {
+			summary["error"]++
+		} else {
+			summary[result.Status]++
+		}
+		results = append(results, result)
+	}
+	consistent := summary[compareIdentical] == len(results)
+
+	s.recordAudit(c, "secrets.vault-compare", s.config.PodNamespace, s.config.PodNamespace, audit.OutcomeSuccess, map[string]string{
+		"secrets":    strconv.Itoa(len(results)),
+		"consistent": strconv.FormatBool(consistent),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"vault":      s.vault.Address(),
+		"namespace":  s.config.PodNamespace,
+		"consistent": consistent,
+		"summary":    summary,
+		"secrets":    results,
+	})
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"bitwarden-reader/internal/hooks"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+)
+
+// secretState is the last observed state of a watched secret
+type secretState struct {
+	found      bool
+	dataHash   string
+	syncStatus string
+}
+
+// dataHash returns a hash over the secret's keys and value hashes
+func dataHash(keys map[string]string) string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sum := sha256.New()
+	for _, name := range names {
+		sum.Write([]byte(name + "=" + reader.HashValue(keys[name]) + "\n"))
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// detectEvents compares the secrets with the previous states and returns the change events
+// The states map is updated in place; the first observation of a secret only sets the baseline
+func detectEvents(states map[string]secretState, secrets []reader.SecretInfo, namespace string) []hooks.Event {
+	var events []hooks.Event
+	for _, secret := range secrets {
+		current := secretState{
+			found:      secret.Found,
+			syncStatus: secret.SyncInfo.SyncStatus,
+		}
+		if secret.Found {
+			current.dataHash = dataHash(secret.Keys) + secret.HiddenDigest
+		}
+
+		previous, seen := states[secret.Key()]
+		states[secret.Key()] = current
+		if !seen {
+			continue
+		}
+
+		if previous.found && current.found && previous.dataHash != current.dataHash {
+			events = append(events, hooks.Event{
+				Type:      hooks.EventDataChanged,
+				Secret:    secret.Name,
+				Namespace: namespace,
+				Group:     secret.Group,
+			})
+		}
+		if current.syncStatus == "False" && previous.syncStatus != "False" {
+			events = append(events, hooks.Event{
+				Type:      hooks.EventSyncFailed,
+				Secret:    secret.Name,
+				Namespace: namespace,
+				Group:     secret.Group,
+				Detail:    secret.SyncInfo.SyncReason + ": " + secret.SyncInfo.SyncMessage,
+			})
+		}
+		if previous.syncStatus == "False" && current.syncStatus == "True" {
+			events = append(events, hooks.Event{
+				Type:      hooks.EventSyncRecovered,
+				Secret:    secret.Name,
+				Namespace: namespace,
+				Group:     secret.Group,
+				Detail:    secret.SyncInfo.SyncReason,
+			})
+		}
+	}
+	return events
+}
+
+// watchSecrets polls the configured secrets, and re-reads them when a watched secret changes,
+// dispatching change events until ctx is cancelled
+func (s *Server) watchSecrets(ctx context.Context) {
+	interval := s.config.DashboardRefreshInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	states := make(map[string]secretState)
+	for {
+		secrets, err := s.readSecrets(ctx)
+		if err != nil {
+			logging.Printf("Error reading secrets for change detection: %v", err)
+		} else {
+			now := time.Now()
+			events := detectEvents(states, secrets, s.config.PodNamespace)
+			events = append(events, s.alertEvents(secrets, now)...)
+			for _, event := range s.flapEvents(secrets, events, now) {
+				logging.Printf("Secret event: %s %s/%s", event.Type, event.Namespace, event.Secret)
+				s.hooks.Notify(event)
+				s.notifier.Notify(event)
+			}
+			s.publishMQTTStatus(secrets, now)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.secretEvents.hooks:
+		}
+	}
+}
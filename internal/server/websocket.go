@@ -1,11 +1,17 @@
 package server
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"bitwarden-reader/internal/features"
+	"bitwarden-reader/internal/logging"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
@@ -22,14 +28,43 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 512 * 1024
+
+	// Messages queued for a client before it counts as too slow and is dropped
+	clientSendBuffer = 256
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins
-	},
+// newUpgrader creates the WebSocket upgrader, optionally negotiating permessage-deflate
+func newUpgrader(enableCompression bool, allowedOrigins []string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: enableCompression,
+		CheckOrigin: func(r *http.Request) bool {
+			return originAllowed(r, allowedOrigins)
+		},
+	}
+}
+
+// originAllowed reports whether a WebSocket handshake comes from the dashboard's own origin or one in
+// WS_ALLOWED_ORIGINS; browsers send session cookies and basic credentials to any page's connection,
+// so other origins could read secrets as the logged-in user
+// Clients that send no Origin, which browsers always do, are not browsers and are allowed
+func originAllowed(r *http.Request, allowedOrigins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	logging.Printf("Rejected WebSocket from origin %s for host %s", origin, r.Host)
+	return false
 }
 
 // Hub maintains the set of active clients and broadcasts messages to the clients
@@ -37,56 +72,182 @@ type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
-	// Inbound messages from the clients
-	broadcast chan []byte
+	// Snapshots to render per client view and send
+	broadcast chan *broadcastPayload
 
 	// Register requests from the clients
 	register chan *Client
 
 	// Unregister requests from clients
 	unregister chan *Client
+
+	// Last published snapshot, sent to clients when they register
+	last *broadcastPayload
+
+	// Sequence number of the last published snapshot or event
+	seq int64
+
+	// Recently published snapshots and events, oldest first, replayed to resuming clients
+	recent     []*broadcastPayload
+	recentSize int
+
+	// Time a client has to acknowledge an event before it is sent again, 0 disables redelivery
+	ackTimeout time.Duration
+
+	// Events sent again for lack of an ack, and events given up on after maxRedeliveries
+	redeliveries atomic.Int64
+	undelivered  atomic.Int64
+
+	// Watchdog probes; receiving one proves the event loop is running
+	probe chan struct{}
+
+	// Event loop health as seen by the watchdog, shared with the hubs that replace this one
+	health *hubHealth
+
+	// Connected clients, readable outside the event loop for the broadcast lag and shared with replacements
+	live *sync.Map
+
+	// Replay state a replacement starts from, stored by the event loop after each publish
+	checkpoint atomic.Pointer[hubCheckpoint]
+
+	// Closed when the hub is replaced after a stall; its event loop then exits and its clients have moved
+	done       chan struct{}
+	retireOnce sync.Once
 }
 
 // Client is a middleman between the websocket connection and the hub
 type Client struct {
-	hub *Hub
+	// Guards owner, closed, pending, and sends on send, so a hub replaced after a stall can't touch the client
+	mu sync.Mutex
+
+	// Hub the client is registered with; it changes when a stalled hub is replaced
+	owner *Hub
+
+	// Whether send has been closed
+	closed bool
+
+	// Releases the connection's slot in the connection limiter
+	release func()
 
 	// The websocket connection
 	conn *websocket.Conn
 
 	// Buffered channel of outbound messages
 	send chan []byte
+
+	// Namespaces the client may receive secrets from
+	access clientAccess
+
+	// Message encoding negotiated on connect
+	encoding string
+
+	// Reports whether the browser session the connection was opened in is still valid, nil without one
+	sessionValid func() bool
+
+	// Token the client presents to resume this session after reconnecting, empty when resuming is disabled
+	resumeToken string
+
+	// Sequence number the client last received before reconnecting, -1 unless it presented a valid resume token
+	resumeSeq int64
+
+	// Sequence number the client last acknowledged before reconnecting, -1 unless it acknowledged events then
+	resumeAcked int64
+
+	// Whether the client acknowledges events, so unacknowledged ones are sent again
+	acks bool
+
+	// Whether the client takes snapshot deltas; it still gets whole snapshots on connect and resume
+	delta bool
+
+	// Highest sequence number the client acknowledged, set by readPump
+	acked atomic.Int64
+
+	// Events sent but not yet acknowledged, oldest first; used by the owner's event loop under mu
+	pending []pendingEvent
+
+	// Unix nanoseconds since a message has been waiting in send, 0 when writePump caught up
+	waitingSince atomic.Int64
 }
 
-// newHub creates a new Hub
-func newHub() *Hub {
+// newHub creates a new Hub keeping up to resumeBuffer snapshots and events for resuming clients,
+// and sending events again to acknowledging clients that don't acknowledge them within ackTimeout
+func newHub(resumeBuffer int, ackTimeout time.Duration) *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan *broadcastPayload),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		probe:      make(chan struct{}),
+		health:     &hubHealth{},
+		live:       &sync.Map{},
+		done:       make(chan struct{}),
+		recentSize: min(max(resumeBuffer, 0), maxResumeBuffer),
+		ackTimeout: ackTimeout,
 	}
 }
 
 // run starts the hub
 func (h *Hub) run() {
+	var redeliver <-chan time.Time
+	if h.ackTimeout > 0 {
+		ticker := time.NewTicker(h.ackTimeout)
+		defer ticker.Stop()
+		redeliver = ticker.C
+	}
+
 	for {
 		select {
+		case <-h.done:
+			return
+
+		case <-h.probe:
+
 		case client := <-h.register:
 			h.clients[client] = true
+			h.welcome(client)
 
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				close(client.send)
+				h.closeClient(client)
 			}
 
-		case message := <-h.broadcast:
+		case now := <-redeliver:
+			h.redeliver(now)
+
+		case payload := <-h.broadcast:
+			now := time.Now()
+			if !payload.heartbeat {
+				h.seq++
+				payload.seq = h.seq
+				h.remember(payload)
+			}
+			if !payload.heartbeat && !payload.event {
+				h.last = payload
+			}
+			if !payload.heartbeat {
+				h.checkpoint.Store(&hubCheckpoint{seq: h.seq, last: h.last, recent: h.recent})
+			}
+			// Render each distinct view once and share it between clients with the same access
+			views := make(map[string][]byte)
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
+				key := client.access.key() + "|" + client.encoding
+				if client.delta {
+					key += "|delta"
+				}
+				message, ok := views[key]
+				if !ok {
+					if client.delta {
+						message = payload.renderDelta(client.access, client.encoding)
+					} else {
+						message = payload.render(client.access, client.encoding)
+					}
+					views[key] = message
+				}
+				if message == nil {
+					continue
+				}
+				if !h.deliver(client, message, payload, now) {
 					delete(h.clients, client)
 				}
 			}
@@ -94,50 +255,102 @@ func (h *Hub) run() {
 	}
 }
 
-// broadcastMessage sends a message to all registered clients
-func (h *Hub) broadcastMessage(data interface{}) {
-	message, err := json.Marshal(data)
-	if err != nil {
-		log.Printf("Error marshaling broadcast message: %v", err)
-		return
+// deliver queues a message for the client without blocking, tracking it when it is an event the client
+// acknowledges; a client whose buffer is full is closed
+// It reports false when the client is closed or has moved to another hub, so the caller forgets it
+func (h *Hub) deliver(client *Client, message []byte, payload *broadcastPayload, now time.Time) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if !h.sendLocked(client, message, now) {
+		return false
+	}
+	if payload != nil && payload.event && client.acks {
+		h.track(client, payload, now)
+	}
+	return true
+}
+
+// sendLocked queues a message for a client of this hub, closing it when its buffer is full; client.mu is held
+func (h *Hub) sendLocked(client *Client, message []byte, now time.Time) bool {
+	if client.owner != h || client.closed {
+		return false
+	}
+	select {
+	case client.send <- message:
+		client.queued(now)
+		return true
+	default:
+		client.closed = true
+		close(client.send)
+		return false
+	}
+}
+
+// closeClient closes the send channel of a client of this hub
+func (h *Hub) closeClient(client *Client) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.owner == h && !client.closed {
+		client.closed = true
+		close(client.send)
+	}
+}
+
+// publish sends a snapshot to all registered clients, filtered per client
+// It reports whether the hub accepted the payload; events wait up to eventPublishWait for a busy hub,
+// since unlike snapshots no later message supersedes them
+func (h *Hub) publish(payload *broadcastPayload) bool {
+	if payload.event {
+		timer := time.NewTimer(eventPublishWait)
+		defer timer.Stop()
+		select {
+		case h.broadcast <- payload:
+			return true
+		case <-timer.C:
+			return false
+		}
 	}
 
 	select {
-	case h.broadcast <- message:
+	case h.broadcast <- payload:
+		return true
 	default:
 		// Channel is full, skip this broadcast
+		return false
 	}
 }
 
 // readPump pumps messages from the websocket connection to the hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.leave()
+		c.release()
 		if err := c.conn.Close(); err != nil {
-			log.Printf("Error closing websocket connection: %v", err)
+			logging.Printf("Error closing websocket connection: %v", err)
 		}
 	}()
 
 	if err := c.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
-		log.Printf("Error setting read deadline: %v", err)
+		logging.Printf("Error setting read deadline: %v", err)
 		return
 	}
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetPongHandler(func(string) error {
 		if err := c.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
-			log.Printf("Error setting read deadline in pong handler: %v", err)
+			logging.Printf("Error setting read deadline in pong handler: %v", err)
 		}
 		return nil
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				logging.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+		c.readAck(message)
 	}
 }
 
@@ -148,7 +361,7 @@ func (c *Client) writePump() {
 	defer func() {
 		ticker.Stop()
 		if err := c.conn.Close(); err != nil {
-			log.Printf("Error closing websocket connection: %v", err)
+			logging.Printf("Error closing websocket connection: %v", err)
 		}
 	}()
 
@@ -162,43 +375,57 @@ func (c *Client) writePump() {
 			if !c.writeMessage(message) {
 				return
 			}
+			c.caughtUp()
 
 		case <-ticker.C:
-			if !c.writePing() {
+			if c.closeExpired() || !c.writePing() {
 				return
 			}
 		}
 	}
 }
 
+// queued notes that a message is waiting in send, unless an older one already is
+func (c *Client) queued(now time.Time) {
+	c.waitingSince.CompareAndSwap(0, now.UnixNano())
+}
+
+// caughtUp clears the waiting time after a write, restarting it for messages queued meanwhile
+func (c *Client) caughtUp() {
+	c.waitingSince.Store(0)
+	if len(c.send) > 0 {
+		c.queued(time.Now())
+	}
+}
+
 // handleChannelClose handles the case when the send channel is closed
 func (c *Client) handleChannelClose() {
 	if err := c.conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
-		log.Printf("Error writing close message: %v", err)
+		logging.Printf("Error writing close message: %v", err)
 	}
 }
 
 // writeMessage writes a message and any queued messages to the connection
 func (c *Client) writeMessage(message []byte) bool {
 	if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
-		log.Printf("Error setting write deadline: %v", err)
+		logging.Printf("Error setting write deadline: %v", err)
 		return false
 	}
 
-	w, err := c.conn.NextWriter(websocket.TextMessage)
+	w, err := c.conn.NextWriter(frameType(c.encoding))
 	if err != nil {
 		return false
 	}
 
 	if !c.writeMessageAndQueued(w, message) {
 		if err := w.Close(); err != nil {
-			log.Printf("Error closing writer: %v", err)
+			logging.Printf("Error closing writer: %v", err)
 		}
 		return false
 	}
 
 	if err := w.Close(); err != nil {
-		log.Printf("Error closing writer: %v", err)
+		logging.Printf("Error closing writer: %v", err)
 		return false
 	}
 
@@ -210,19 +437,24 @@ func (c *Client) writeMessageAndQueued(w interface {
 	Write([]byte) (int, error)
 }, message []byte) bool {
 	if _, err := w.Write(message); err != nil {
-		log.Printf("Error writing message: %v", err)
+		logging.Printf("Error writing message: %v", err)
 		return false
 	}
 
+	// Binary messages can't be joined, so they are sent one per frame
+	if c.encoding != encodingJSON {
+		return true
+	}
+
 	// Add queued messages to the current websocket message
 	n := len(c.send)
 	for i := 0; i < n; i++ {
 		if _, err := w.Write([]byte{'\n'}); err != nil {
-			log.Printf("Error writing newline: %v", err)
+			logging.Printf("Error writing newline: %v", err)
 			return false
 		}
 		if _, err := w.Write(<-c.send); err != nil {
-			log.Printf("Error writing queued message: %v", err)
+			logging.Printf("Error writing queued message: %v", err)
 			return false
 		}
 	}
@@ -243,19 +475,81 @@ func (c *Client) writePing() bool {
 
 // wsHandler handles websocket requests from the peer
 func (s *Server) wsHandler(c *gin.Context) {
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	encoding, err := parseEncoding(c.Query("encoding"))
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	acks := false
+	if value := c.Query("ack"); value != "" {
+		if acks, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": s.tr(c, "Invalid ack value - use true or false")})
+			return
+		}
+	}
+
+	delta := false
+	if value := c.Query("delta"); value != "" {
+		if delta, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": s.tr(c, "Invalid delta value - use true or false")})
+			return
+		}
+	}
+
+	identity := requestIdentity(c)
+	access := s.reviewAccess(c.Request.Context(), s.accessFor(identity))
+	if s.config.Impersonation && len(access.namespaces) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": s.tr(c, "Not permitted to get secrets in any allowed namespace")})
 		return
 	}
 
+	key := connectionKey(c)
+	if ok, reason := s.wsLimits.acquire(key); !ok {
+		logging.Printf("Rejecting WebSocket connection from %s: %s", key, reason)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": reason})
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		s.wsLimits.release(key)
+		logging.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+	if s.config.WSCompression {
+		// Only takes effect when the client negotiated permessage-deflate
+		if err := conn.SetCompressionLevel(s.config.WSCompressionLevel); err != nil {
+			logging.Printf("Invalid WebSocket compression level %d: %v", s.config.WSCompressionLevel, err)
+		}
+	}
+
+	token, resumeSeq, resumeAcked := s.resumeTokens.claim(c.Query("resume"), c.Query("lastSeq"), identity, time.Now())
 	client := &Client{
-		hub:  s.hub,
-		conn: conn,
-		send: make(chan []byte, 256),
+		conn:         conn,
+		send:         make(chan []byte, clientSendBuffer),
+		access:       access,
+		encoding:     encoding,
+		sessionValid: s.sessionCheck(c),
+		resumeToken:  token,
+		resumeSeq:    resumeSeq,
+		resumeAcked:  resumeAcked,
+		acks:         acks && s.config.WSAckTimeout > 0,
+		delta:        delta && s.features.Enabled(features.DeltaBroadcasts),
+	}
+	hub := s.currentHub()
+	client.release = func() {
+		hub.live.Delete(client)
+		s.wsLimits.release(key)
+		acked := int64(-1)
+		if client.acks {
+			acked = client.acked.Load()
+		}
+		s.resumeTokens.release(token, acked, time.Now())
 	}
 
-	client.hub.register <- client
+	hub.live.Store(client, struct{}{})
+	s.join(client)
 
 	go client.writePump()
 	go client.readPump()
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"bitwarden-reader/internal/k8s"
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+)
+
+// clientAccess describes which namespaces a WebSocket client may receive secrets from
+type clientAccess struct {
+	identity   string
+	namespaces []string
+}
+
+// allows reports whether secrets from the namespace may be sent to the client
+func (a clientAccess) allows(namespace string) bool {
+	for _, allowed := range a.namespaces {
+		if allowed == "*" || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// key identifies the view; clients with the same key receive identical messages
+func (a clientAccess) key() string {
+	namespaces := append([]string(nil), a.namespaces...)
+	sort.Strings(namespaces)
+	return strings.Join(namespaces, ",")
+}
+
+// accessFor returns the WebSocket access of an identity
+// Identities without an IDENTITY_NAMESPACES entry get the globally allowed namespaces, or without
+// ALLOWED_NAMESPACES the pod namespace the snapshots are read from, even when it is unset as in local runs
+func (s *Server) accessFor(identity string) clientAccess {
+	if namespaces, ok := s.config.IdentityNamespaces[identity]; ok {
+		return clientAccess{identity: identity, namespaces: namespaces}
+	}
+	if len(s.config.AllowedNamespaces) == 0 {
+		return clientAccess{identity: identity, namespaces: []string{s.config.PodNamespace}}
+	}
+	return clientAccess{identity: identity, namespaces: s.config.AllowedNamespaces}
+}
+
+// reviewAccess narrows a WebSocket client's access to the namespaces in which the request's impersonated user
+// may get secrets, since snapshots and events are read with the service account's clients
+// "*" is kept only for users who may get secrets cluster-wide, and otherwise falls back to the pod namespace;
+// failed reviews deny. The access is reviewed when the connection opens, so RBAC changes apply on reconnect
+func (s *Server) reviewAccess(ctx context.Context, access clientAccess) clientAccess {
+	if !s.config.Impersonation || s.k8sClients == nil {
+		return access
+	}
+	canGet := func(namespace string) bool {
+		allowed, err := k8s.CanI(ctx, s.clientsFor(ctx, namespace).Clientset, namespace, "", "secrets", "get")
+		if err != nil {
+			logging.Printf("Error reviewing WebSocket access of %s to namespace %q: %v", access.identity, namespace, err)
+			return false
+		}
+		return allowed
+	}
+
+	reviewed := clientAccess{identity: access.identity}
+	add := func(namespace string) {
+		for _, existing := range reviewed.namespaces {
+			if existing == namespace {
+				return
+			}
+		}
+		if canGet(namespace) {
+			reviewed.namespaces = append(reviewed.namespaces, namespace)
+		}
+	}
+	for _, namespace := range access.namespaces {
+		if namespace != "*" {
+			add(namespace)
+			continue
+		}
+		if canGet("") {
+			reviewed.namespaces = append(reviewed.namespaces, "*")
+		} else {
+			add(s.config.PodNamespace)
+		}
+	}
+	return reviewed
+}
+
+// broadcastPayload is a secrets snapshot rendered separately for each client view
+// Event payloads such as alerts are sent as they are to clients allowed the namespace, and are
+// not replayed to clients that connect later; clients that acknowledge events get them at least once
+type broadcastPayload struct {
+	heartbeat bool
+	event     bool
+	// seq is assigned by the hub when the snapshot or event is published; heartbeats have none
+	seq int64
+	// redelivered marks an event sent again because the client didn't acknowledge it
+	redelivered bool
+	namespace   string
+	secrets     []reader.SecretInfo
+	fields      map[string]interface{}
+	// delta is the change since the previous snapshot, sent instead of the secrets to clients that take deltas
+	delta *snapshotDelta
+}
+
+// render builds the message for a client view in its encoding, dropping secrets the view may not see
+func (p *broadcastPayload) render(access clientAccess, encoding string) []byte {
+	if p.heartbeat {
+		return p.encode(p.fields, encoding)
+	}
+	if p.event && !access.allows(p.namespace) {
+		return nil
+	}
+
+	message := make(map[string]interface{}, len(p.fields)+4)
+	for key, value := range p.fields {
+		message[key] = value
+	}
+	if p.seq > 0 {
+		message["seq"] = p.seq
+	}
+	if p.redelivered {
+		message["redelivered"] = true
+	}
+	if p.event {
+		return p.encode(message, encoding)
+	}
+
+	secrets := p.secrets
+	if !access.allows(p.namespace) {
+		secrets = []reader.SecretInfo{}
+	}
+	message["namespace"] = p.namespace
+	message["secrets"] = secrets
+	message["totalFound"] = countFoundSecrets(secrets)
+
+	return p.encode(message, encoding)
+}
+
+// renderDelta builds the message for a client view that takes deltas: the secrets changed since the
+// previous snapshot and those no longer in it, or the whole snapshot when the payload has no delta
+func (p *broadcastPayload) renderDelta(access clientAccess, encoding string) []byte {
+	if p.delta == nil || p.heartbeat || p.event || !access.allows(p.namespace) {
+		return p.render(access, encoding)
+	}
+
+	message := make(map[string]interface{}, len(p.fields)+6)
+	for key, value := range p.fields {
+		message[key] = value
+	}
+	if p.seq > 0 {
+		message["seq"] = p.seq
+	}
+	message["namespace"] = p.namespace
+	message["delta"] = true
+	message["secrets"] = p.delta.changed
+	message["removed"] = p.delta.removed
+	message["totalFound"] = countFoundSecrets(p.secrets)
+
+	return p.encode(message, encoding)
+}
+
+// encode serializes a rendered message, logging failures
+func (p *broadcastPayload) encode(message map[string]interface{}, encoding string) []byte {
+	data, err := encodeMessage(encoding, message)
+	if err != nil {
+		logging.Printf("Error marshaling broadcast message: %v", err)
+		return nil
+	}
+	return data
+}
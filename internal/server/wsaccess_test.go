@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"bitwarden-reader/internal/config"
+	"bitwarden-reader/internal/k8s/k8sfake"
+	"bitwarden-reader/internal/reader"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// renderedSecrets renders the snapshot for the identity and returns the names of the secrets it received
+func renderedSecrets(t *testing.T, s *Server, identity string, payload *broadcastPayload) []string {
+	t.Helper()
+	var message struct {
+		Secrets []reader.SecretInfo `json:"secrets"`
+	}
+	if err := json.Unmarshal(payload.render(s.accessFor(identity), encodingJSON), &message); err != nil {
+		t.Fatalf("rendered message is not JSON: %v", err)
+	}
+	names := make([]string, len(message.Secrets))
+	for i, secret := range message.Secrets {
+		names[i] = secret.Name
+	}
+	return names
+}
+
+func TestRenderWithoutAllowedNamespaces(t *testing.T) {
+	tests := []struct {
+		name         string
+		podNamespace string
+	}{
+		{"pod namespace unset", ""},
+		{"pod namespace set", "apps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{config: &config.Config{PodNamespace: tt.podNamespace}}
+			payload := &broadcastPayload{
+				namespace: tt.podNamespace,
+				secrets:   []reader.SecretInfo{{Name: "db", Found: true}},
+			}
+			if got := renderedSecrets(t, s, "anonymous", payload); len(got) != 1 || got[0] != "db" {
+				t.Errorf("without ALLOWED_NAMESPACES the client received %v, want [db]", got)
+			}
+
+			other := &broadcastPayload{
+				namespace: "other",
+				secrets:   []reader.SecretInfo{{Name: "api", Found: true}},
+			}
+			if got := renderedSecrets(t, s, "anonymous", other); len(got) != 0 {
+				t.Errorf("a snapshot of another namespace reached the client: %v", got)
+			}
+		})
+	}
+}
+
+func TestRenderAllowedNamespaces(t *testing.T) {
+	s := &Server{config: &config.Config{
+		PodNamespace:       "apps",
+		AllowedNamespaces:  []string{"apps"},
+		IdentityNamespaces: map[string][]string{"alice": {"web"}},
+	}}
+	payload := &broadcastPayload{
+		namespace: "apps",
+		secrets:   []reader.SecretInfo{{Name: "db", Found: true}},
+	}
+	if got := renderedSecrets(t, s, "bob", payload); len(got) != 1 {
+		t.Errorf("bob received %v, want the apps secrets", got)
+	}
+	if got := renderedSecrets(t, s, "alice", payload); len(got) != 0 {
+		t.Errorf("alice received %v outside the IDENTITY_NAMESPACES entry", got)
+	}
+}
+
+func TestReviewAccess(t *testing.T) {
+	// The impersonated user may get secrets in apps only
+	clients := k8sfake.NewClients()
+	clients.Clientset.(*kubefake.Clientset).PrependReactor("create", "selfsubjectaccessreviews",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			review.Status.Allowed = review.Spec.ResourceAttributes.Namespace == "apps"
+			return true, review, nil
+		})
+	s := &Server{
+		config:     &config.Config{PodNamespace: "apps", Impersonation: true},
+		k8sClients: k8sfake.NewClients(),
+	}
+	ctx := context.WithValue(context.Background(), clientsContextKey{}, clients)
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		want       []string
+	}{
+		{"allowed namespace kept", []string{"apps", "web"}, []string{"apps"}},
+		{"wildcard falls back to the pod namespace", []string{"*"}, []string{"apps"}},
+		{"nothing allowed", []string{"web"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.reviewAccess(ctx, clientAccess{identity: "alice", namespaces: tt.namespaces})
+			if !reflect.DeepEqual(got.namespaces, tt.want) {
+				t.Errorf("reviewed namespaces = %v, want %v", got.namespaces, tt.want)
+			}
+		})
+	}
+}
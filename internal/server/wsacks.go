@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+)
+
+const (
+	// Time an event waits for a busy hub before it is dropped
+	eventPublishWait = time.Second
+
+	// Times an unacknowledged event is sent again before the hub gives up on it
+	maxRedeliveries = 5
+
+	// Unacknowledged events kept per client; older ones are given up on
+	maxPendingEvents = maxResumeBuffer
+)
+
+// pendingEvent is an event sent to an acknowledging client and not yet acknowledged
+type pendingEvent struct {
+	payload  *broadcastPayload
+	sentAt   time.Time
+	attempts int
+}
+
+// clientAck is the message a client sends to acknowledge every event up to Seq
+type clientAck struct {
+	Type string `json:"type"`
+	Seq  int64  `json:"seq"`
+}
+
+// redelivery returns a copy of the payload marked as sent again
+func (p *broadcastPayload) redelivery() *broadcastPayload {
+	redelivered := *p
+	redelivered.redelivered = true
+	return &redelivered
+}
+
+// ack records that the client acknowledged every event up to seq; acknowledgements never go back
+func (c *Client) ack(seq int64) {
+	for {
+		acked := c.acked.Load()
+		if seq <= acked || c.acked.CompareAndSwap(acked, seq) {
+			return
+		}
+	}
+}
+
+// readAck applies an ack message from the client; other messages are ignored
+// Acks are JSON text messages whatever encoding the client receives
+func (c *Client) readAck(message []byte) {
+	if !c.acks {
+		return
+	}
+	var ack clientAck
+	if err := json.Unmarshal(message, &ack); err != nil || ack.Type != messageTypeAck {
+		return
+	}
+	c.ack(ack.Seq)
+}
+
+// track remembers an event sent to an acknowledging client until it is acknowledged; client.mu is held
+func (h *Hub) track(client *Client, payload *broadcastPayload, now time.Time) {
+	client.pending = append(client.pending, pendingEvent{payload: payload, sentAt: now, attempts: 1})
+	if excess := len(client.pending) - maxPendingEvents; excess > 0 {
+		h.undelivered.Add(int64(excess))
+		logging.Printf("WebSocket client %s has %d unacknowledged events, giving up on the oldest %d", client.access.identity, len(client.pending), excess)
+		client.pending = append([]pendingEvent(nil), client.pending[excess:]...)
+	}
+}
+
+// redeliver forgets acknowledged events and sends the others again once they waited ackTimeout,
+// giving up on an event after maxRedeliveries
+// A client too slow to take a redelivery is dropped, like on broadcast
+func (h *Hub) redeliver(now time.Time) {
+	for client := range h.clients {
+		if !h.redeliverTo(client, now) {
+			delete(h.clients, client)
+		}
+	}
+}
+
+// redeliverTo redelivers the client's pending events, reporting false when it was dropped or has moved
+func (h *Hub) redeliverTo(client *Client, now time.Time) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.owner != h || client.closed {
+		return false
+	}
+	if len(client.pending) == 0 {
+		return true
+	}
+
+	acked := client.acked.Load()
+	pending := client.pending[:0]
+	dropped := false
+	for _, event := range client.pending {
+		switch {
+		case dropped || event.payload.seq <= acked:
+			continue
+		case now.Sub(event.sentAt) < h.ackTimeout:
+			pending = append(pending, event)
+			continue
+		case event.attempts > maxRedeliveries:
+			h.undelivered.Add(1)
+			logging.Printf("WebSocket client %s did not acknowledge event %d after %d redeliveries, giving up", client.access.identity, event.payload.seq, maxRedeliveries)
+			continue
+		}
+
+		message := event.payload.redelivery().render(client.access, client.encoding)
+		if message == nil {
+			continue
+		}
+		if !h.sendLocked(client, message, now) {
+			dropped = true
+			continue
+		}
+		h.redeliveries.Add(1)
+		event.sentAt = now
+		event.attempts++
+		pending = append(pending, event)
+	}
+	client.pending = pending
+	return !dropped
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// WebSocket message encodings a client may request with ?encoding=
+const (
+	encodingJSON    = "json"
+	encodingMsgPack = "msgpack"
+	encodingCBOR    = "cbor"
+)
+
+var (
+	msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+	cborHandle    = &codec.CborHandle{}
+)
+
+// parseEncoding validates the encoding requested by a client; empty means JSON
+func parseEncoding(value string) (string, error) {
+	switch value {
+	case "", encodingJSON:
+		return encodingJSON, nil
+	case encodingMsgPack, encodingCBOR:
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported encoding %q (use json, msgpack or cbor)", value)
+	}
+}
+
+// encodeMessage serializes a message in the given encoding
+func encodeMessage(encoding string, message interface{}) ([]byte, error) {
+	var handle codec.Handle
+	switch encoding {
+	case encodingMsgPack:
+		handle = msgpackHandle
+	case encodingCBOR:
+		handle = cborHandle
+	default:
+		return json.Marshal(message)
+	}
+
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, handle).Encode(message); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// frameType returns the WebSocket frame type used for an encoding
+func frameType(encoding string) int {
+	if encoding == encodingJSON {
+		return websocket.TextMessage
+	}
+	return websocket.BinaryMessage
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// connLimiter counts open WebSocket connections in total and per client key
+type connLimiter struct {
+	mu        sync.Mutex
+	maxTotal  int
+	maxPerKey int
+	total     int
+	perKey    map[string]int
+	rejected  int64
+}
+
+// newConnLimiter creates a limiter; a limit of 0 disables that check
+func newConnLimiter(maxTotal, maxPerKey int) *connLimiter {
+	return &connLimiter{
+		maxTotal:  maxTotal,
+		maxPerKey: maxPerKey,
+		perKey:    make(map[string]int),
+	}
+}
+
+// acquire reserves a connection slot for key; it returns a reason when a limit is reached
+func (l *connLimiter) acquire(key string) (bool, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxTotal > 0 && l.total >= l.maxTotal {
+		l.rejected++
+		return false, "WebSocket connection limit reached"
+	}
+	if l.maxPerKey > 0 && l.perKey[key] >= l.maxPerKey {
+		l.rejected++
+		return false, "WebSocket connection limit per client reached"
+	}
+	l.total++
+	l.perKey[key]++
+	return true, ""
+}
+
+// release frees a slot reserved by acquire
+func (l *connLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	if l.perKey[key] <= 1 {
+		delete(l.perKey, key)
+	} else {
+		l.perKey[key]--
+	}
+}
+
+// rejectedCount returns how many connections were refused by a limit
+func (l *connLimiter) rejectedCount() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rejected
+}
+
+// connectionCount is the number of open connections for one client key
+type connectionCount struct {
+	Client      string `json:"client"`
+	Connections int    `json:"connections"`
+}
+
+// snapshot returns the total and per-client counts, busiest clients first
+func (l *connLimiter) snapshot() (int, []connectionCount) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make([]connectionCount, 0, len(l.perKey))
+	for key, n := range l.perKey {
+		counts = append(counts, connectionCount{Client: key, Connections: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Connections != counts[j].Connections {
+			return counts[i].Connections > counts[j].Connections
+		}
+		return counts[i].Client < counts[j].Client
+	})
+	return l.total, counts
+}
+
+// connectionKey identifies the client for per-client limits: its identity, or its IP when anonymous
+func connectionKey(c *gin.Context) string {
+	if identity := c.GetString(identityKey); identity != "" {
+		return identity
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// adminWebSocketsHandler returns current WebSocket connection counts and limits
+func (s *Server) adminWebSocketsHandler(c *gin.Context) {
+	total, clients := s.wsLimits.snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"connections":             total,
+		"rejected":                s.wsLimits.rejectedCount(),
+		"maxConnections":          s.config.WSMaxConnections,
+		"maxConnectionsPerClient": s.config.WSMaxConnsPerClient,
+		"clients":                 clients,
+		"resumableSessions":       s.resumeTokens.count(time.Now()),
+		"redeliveries":            s.currentHub().redeliveries.Load(),
+		"undeliveredEvents":       s.currentHub().undelivered.Load(),
+	})
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+)
+
+// maxResumeBuffer caps WS_RESUME_BUFFER so a full replay fits in a client's send buffer
+const maxResumeBuffer = clientSendBuffer - 16
+
+// resumeTokens maps the resume token of each WebSocket session to the identity it was issued to
+// A token stays valid while a connection uses it and for WS_RESUME_WINDOW_SECONDS after the last one closed;
+// tokens are kept in memory, so a client reconnecting to another replica starts over
+type resumeTokens struct {
+	mu     sync.Mutex
+	window time.Duration
+	tokens map[string]*resumeSession
+}
+
+// resumeSession is the state behind one resume token
+type resumeSession struct {
+	identity string
+	open     int
+	closedAt time.Time
+	// acked is the last sequence number acknowledged before the connection closed, -1 without acks
+	acked int64
+}
+
+// newResumeTokens creates the token store; a window of 0 disables resuming
+func newResumeTokens(window time.Duration) *resumeTokens {
+	return &resumeTokens{window: window, tokens: make(map[string]*resumeSession)}
+}
+
+// expired reports whether a session can no longer be resumed
+func (r *resumeTokens) expired(session *resumeSession, now time.Time) bool {
+	return session.open == 0 && now.Sub(session.closedAt) > r.window
+}
+
+// claim returns the token for a new connection, the sequence number to resume from, and the last one
+// the client acknowledged
+// A presented token that is still valid for identity is reused and lastSeq returned; otherwise a new
+// token is issued and both sequence numbers are -1, so the client gets the full snapshot
+func (r *resumeTokens) claim(presented, lastSeq, identity string, now time.Time) (string, int64, int64) {
+	if r.window <= 0 {
+		return "", -1, -1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for token, session := range r.tokens {
+		if r.expired(session, now) {
+			delete(r.tokens, token)
+		}
+	}
+
+	if session, ok := r.tokens[presented]; ok && session.identity == identity {
+		if seq, err := strconv.ParseInt(lastSeq, 10, 64); err == nil && seq >= 0 {
+			session.open++
+			return presented, seq, session.acked
+		}
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		logging.Printf("Error generating WebSocket resume token: %v", err)
+		return "", -1, -1
+	}
+	token := hex.EncodeToString(buf)
+	r.tokens[token] = &resumeSession{identity: identity, open: 1, acked: -1}
+	return token, -1, -1
+}
+
+// release marks a connection using token closed, starting the resume window once none is left,
+// and keeps the last sequence number it acknowledged
+func (r *resumeTokens) release(token string, acked int64, now time.Time) {
+	if token == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.tokens[token]; ok && session.open > 0 {
+		session.open--
+		session.closedAt = now
+		session.acked = acked
+	}
+}
+
+// count returns how many sessions are connected or can still be resumed
+func (r *resumeTokens) count(now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, session := range r.tokens {
+		if !r.expired(session, now) {
+			n++
+		}
+	}
+	return n
+}
+
+// remember adds a published snapshot or event to the replay buffer, dropping the oldest beyond its size
+func (h *Hub) remember(payload *broadcastPayload) {
+	if h.recentSize == 0 {
+		return
+	}
+	h.recent = append(h.recent, payload)
+	if excess := len(h.recent) - h.recentSize; excess > 0 {
+		h.recent = append([]*broadcastPayload(nil), h.recent[excess:]...)
+	}
+}
+
+// missed returns the snapshots and events published after seq in order, of the snapshots only the newest,
+// or false when the buffer no longer reaches back to seq
+// Events received but not acknowledged, those after acked unless it is -1, are included again
+func (h *Hub) missed(seq, acked int64) ([]*broadcastPayload, bool) {
+	if seq < 0 || seq > h.seq {
+		return nil, false
+	}
+	if seq < h.seq && (len(h.recent) == 0 || h.recent[0].seq > seq+1) {
+		return nil, false
+	}
+	from := resumeFrom(seq, acked)
+
+	var newestSnapshot int64
+	for _, payload := range h.recent {
+		if payload.seq > seq && !payload.event {
+			newestSnapshot = payload.seq
+		}
+	}
+	var missed []*broadcastPayload
+	for _, payload := range h.recent {
+		if payload.event && payload.seq > from || payload.seq == newestSnapshot {
+			missed = append(missed, payload)
+		}
+	}
+	return missed, true
+}
+
+// resumeFrom returns the sequence number after which events are sent to a resuming client:
+// the last it received, or the last it acknowledged when that is older
+func resumeFrom(seq, acked int64) int64 {
+	if acked >= 0 && acked < seq {
+		return acked
+	}
+	return seq
+}
+
+// welcome sends a registering client its session message, followed by what it missed when it resumes
+// within the buffer, or else the last snapshot
+func (h *Hub) welcome(client *Client) {
+	missed, resumed := h.missed(client.resumeSeq, client.resumeAcked)
+	session := map[string]interface{}{
+		"type":    messageTypeSession,
+		"resumed": resumed,
+		"seq":     h.seq,
+		"acks":    client.acks,
+	}
+	if client.resumeToken != "" {
+		session["resumeToken"] = client.resumeToken
+	}
+	// The buffer of a registering client is empty and holds the session message and a full replay buffer
+	now := time.Now()
+	message, err := encodeMessage(client.encoding, session)
+	if err != nil {
+		logging.Printf("Error marshaling WebSocket session message: %v", err)
+	} else if !h.deliver(client, message, nil, now) {
+		delete(h.clients, client)
+		return
+	}
+
+	// Nothing published before the connection is owed to it, but a resumed session's unacknowledged events are
+	if resumed {
+		client.ack(resumeFrom(client.resumeSeq, client.resumeAcked))
+	} else {
+		client.ack(h.seq)
+	}
+
+	if !resumed && h.last != nil {
+		missed = []*broadcastPayload{h.last}
+	}
+	for _, payload := range missed {
+		if payload.seq <= client.resumeSeq {
+			payload = payload.redelivery()
+		}
+		if message := payload.render(client.access, client.encoding); message != nil {
+			if !h.deliver(client, message, payload, now) {
+				delete(h.clients, client)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+)
+
+// awsService is the Secrets Manager signing name
+const awsService = "secretsmanager"
+
+// awsCredentials are static or temporary AWS credentials
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// AWSSecretsManagerSource reads secrets from AWS Secrets Manager
+// A secret named <name> is read from the secret ID <prefix><name>
+type AWSSecretsManagerSource struct {
+	region      string
+	prefix      string
+	endpoint    string
+	credentials awsCredentials
+	client      *http.Client
+}
+
+// NewAWSSecretsManagerSource returns a source for the region using credentials from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN; endpoint overrides the regional endpoint when set
+func NewAWSSecretsManagerSource(region, prefix, endpoint string) (*AWSSecretsManagerSource, error) {
+	credentials := awsCredentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if credentials.accessKeyID == "" || credentials.secretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for AWS Secrets Manager")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+	}
+	return &AWSSecretsManagerSource{
+		region:      region,
+		prefix:      prefix,
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		credentials: credentials,
+		client:      &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// Name returns "aws-secrets-manager"
+func (s *AWSSecretsManagerSource) Name() string {
+	return "aws-secrets-manager"
+}
+
+// getSecretValueResponse is the body of a GetSecretValue response
+type getSecretValueResponse struct {
+	Name         string  `json:"Name"`
+	VersionID    string  `json:"VersionId"`
+	SecretString *string `json:"SecretString"`
+	SecretBinary string  `json:"SecretBinary"`
+	CreatedDate  float64 `json:"CreatedDate"`
+}
+
+// awsError is the body of an error response
+type awsError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// ReadSecrets reads each named secret from Secrets Manager
+func (s *AWSSecretsManagerSource) ReadSecrets(ctx context.Context, names []string) ([]reader.SecretInfo, error) {
+	secrets := make([]reader.SecretInfo, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		secrets = append(secrets, s.readSecret(ctx, name))
+	}
+	return secrets, nil
+}
+
+// readSecret reads the current version of one secret; its creation time is reported as the last sync
+// JSON object secrets become one key per field, other secrets a single "value" key
+func (s *AWSSecretsManagerSource) readSecret(ctx context.Context, name string) reader.SecretInfo {
+	info := reader.SecretInfo{Name: name, Keys: make(map[string]string)}
+	secretID := s.prefix + name
+
+	value, found, err := s.getSecretValue(ctx, secretID)
+	if err != nil {
+		info.Error = fmt.Sprintf("Error reading AWS secret %s: %v", secretID, err)
+		return info
+	}
+	if !found {
+		logging.ForgetSecrets("aws/" + secretID)
+		info.Error = fmt.Sprintf("Secret '%s' not found in AWS Secrets Manager as %s", name, secretID)
+		return info
+	}
+
+	switch {
+	case value.SecretString != nil:
+		var fields map[string]interface{}
+		if json.Unmarshal([]byte(*value.SecretString), &fields) == nil {
+			for key, field := range fields {
+				text, ok := field.(string)
+				if !ok {
+					encoded, _ := json.Marshal(field)
+					text = string(encoded)
+				}
+				info.Keys[key] = text
+			}
+		} else {
+			info.Keys["value"] = *value.SecretString
+		}
+	case value.SecretBinary != "":
+		decoded, err := base64.StdEncoding.DecodeString(value.SecretBinary)
+		if err != nil {
+			info.Error = fmt.Sprintf("Error decoding AWS secret %s: %v", secretID, err)
+			return info
+		}
+		info.Keys["value"] = string(decoded)
+	}
+	logging.RegisterSecrets("aws/"+secretID, slices.Collect(maps.Values(info.Keys))...)
+
+	info.Found = true
+	info.SyncInfo = reader.SyncInfo{
+		SyncStatus:  "True",
+		SyncReason:  "SecretsManagerRead",
+		SyncMessage: fmt.Sprintf("%s version %s", secretID, value.VersionID),
+	}
+	if value.CreatedDate > 0 {
+		seconds, fraction := math.Modf(value.CreatedDate)
+		created := time.Unix(int64(seconds), int64(fraction*1e9)).UTC()
+		info.SyncInfo.LastSuccessfulSync = created.Format(time.RFC3339)
+	}
+	return info
+}
+
+// getSecretValue calls GetSecretValue; a missing secret is reported as not found rather than an error
+func (s *AWSSecretsManagerSource) getSecretValue(ctx context.Context, secretID string) (*getSecretValueResponse, bool, error) {
+	body, _ := json.Marshal(map[string]string{"SecretId": secretID})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signV4(req, body, s.credentials, s.region, awsService, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr awsError
+		_ = json.Unmarshal(data, &apiErr)
+		if strings.HasSuffix(apiErr.Type, "ResourceNotFoundException") {
+			return nil, false, nil
+		}
+		if apiErr.Type != "" {
+			return nil, false, fmt.Errorf("%s: %s", apiErr.Type, apiErr.Message)
+		}
+		return nil, false, fmt.Errorf("%s", resp.Status)
+	}
+
+	var value getSecretValueResponse
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, false, fmt.Errorf("invalid response: %w", err)
+	}
+	return &value, true, nil
+}
+
+// signV4 adds AWS Signature Version 4 headers to a request with a root path and no query string
+func signV4(req *http.Request, body []byte, credentials awsCredentials, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.accessKeyID, scope, signedHeaders, signature))
+}
+
+// sha256Hex returns the hex-encoded SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,199 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+)
+
+// FileSource reads secrets from a local directory
+// A secret is either a subdirectory with one file per key or a dotenv file named <secret>.env
+type FileSource struct {
+	name string
+	dir  string
+}
+
+// NewFileSource returns a source reading secrets from dir
+func NewFileSource(dir string) *FileSource {
+	return &FileSource{name: "files", dir: dir}
+}
+
+// NewLocalSource returns a source reading secrets from dir in place of Kubernetes, for standalone development
+func NewLocalSource(dir string) *FileSource {
+	return &FileSource{name: "local", dir: dir}
+}
+
+// Name returns "files", or "local" for a standalone source
+func (s *FileSource) Name() string {
+	return s.name
+}
+
+// Dir returns the directory secrets are read from
+func (s *FileSource) Dir() string {
+	return s.dir
+}
+
+// SecretNames lists the secrets in the directory: each visible subdirectory and each <name>.env file
+func (s *FileSource) SecretNames() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("secrets directory unavailable: %w", err)
+	}
+
+	seen := make(map[string]bool, len(entries))
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if !entry.IsDir() {
+			var ok bool
+			if name, ok = strings.CutSuffix(name, ".env"); !ok || name == "" || !entry.Type().IsRegular() {
+				continue
+			}
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReadSecrets reads each named secret from the directory
+func (s *FileSource) ReadSecrets(_ context.Context, names []string) ([]reader.SecretInfo, error) {
+	if _, err := os.Stat(s.dir); err != nil {
+		return nil, fmt.Errorf("secrets directory unavailable: %w", err)
+	}
+
+	secrets := make([]reader.SecretInfo, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		secrets = append(secrets, s.readSecret(name))
+	}
+	return secrets, nil
+}
+
+// readSecret reads one secret; the file modification time is reported as its last sync
+func (s *FileSource) readSecret(name string) reader.SecretInfo {
+	info := reader.SecretInfo{Name: name, Keys: make(map[string]string)}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		info.Error = fmt.Sprintf("Invalid secret name '%s'", name)
+		return info
+	}
+
+	var (
+		keys     map[string]string
+		modified time.Time
+		path     string
+		err      error
+	)
+	dirPath := filepath.Join(s.dir, name)
+	envPath := dirPath + ".env"
+	if stat, statErr := os.Stat(dirPath); statErr == nil && stat.IsDir() {
+		path = dirPath
+		keys, modified, err = readKeyFiles(dirPath)
+	} else if stat, statErr := os.Stat(envPath); statErr == nil {
+		path = envPath
+		modified = stat.ModTime()
+		keys, err = readDotenv(envPath)
+	} else {
+		logging.ForgetSecrets("file/" + dirPath)
+		info.Error = fmt.Sprintf("Secret '%s' not found in %s", name, s.dir)
+		return info
+	}
+	if err != nil {
+		info.Error = fmt.Sprintf("Error reading secret: %v", err)
+		return info
+	}
+
+	logging.RegisterSecrets("file/"+dirPath, slices.Collect(maps.Values(keys))...)
+	info.Found = true
+	info.Keys = keys
+	info.SyncInfo = reader.SyncInfo{
+		LastSuccessfulSync: modified.UTC().Format(time.RFC3339),
+		SyncStatus:         "True",
+		SyncReason:         "FileRead",
+		SyncMessage:        path,
+	}
+	return info
+}
+
+// readKeyFiles reads every regular, non-hidden file in dir as a key and returns the newest modification time
+func readKeyFiles(dir string) (map[string]string, time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	keys := make(map[string]string)
+	var newest time.Time
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		value, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		keys[entry.Name()] = string(value)
+		if stat, err := entry.Info(); err == nil && stat.ModTime().After(newest) {
+			newest = stat.ModTime()
+		}
+	}
+	return keys, newest, nil
+}
+
+// readDotenv parses KEY=VALUE lines, ignoring blank lines, comments, and a leading "export"
+// Double-quoted values are unquoted with Go escapes, single-quoted values literally
+func readDotenv(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", filepath.Base(path), lineNumber)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid quoted value", filepath.Base(path), lineNumber)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		}
+		keys[key] = value
+	}
+	return keys, scanner.Err()
+}
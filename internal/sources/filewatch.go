@@ -0,0 +1,64 @@
+package sources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Watch calls onChange whenever a file in the directory is added, removed, or modified, until ctx is cancelled
+// The directory is scanned every interval, comparing paths, sizes, and modification times, so it also works
+// on bind mounts and network filesystems that do not deliver change notifications
+func (s *FileSource) Watch(ctx context.Context, interval time.Duration, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := s.fingerprint()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := s.fingerprint()
+		if current != last {
+			last = current
+			onChange()
+		}
+	}
+}
+
+// fingerprint hashes the path, size, and modification time of every visible entry in the directory
+// A missing directory has an empty fingerprint, so creating it counts as a change
+func (s *FileSource) fingerprint() string {
+	sum := sha256.New()
+	found := false
+	_ = filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != s.dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		found = true
+		fmt.Fprintf(sum, "%s\x00%d\x00%d\x00%s\n", path, info.Size(), info.ModTime().UnixNano(), info.Mode())
+		return nil
+	})
+	if !found {
+		return ""
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
@@ -0,0 +1,353 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+	"bitwarden-reader/internal/reader"
+)
+
+// requestTimeout bounds each request to an external secret store
+const requestTimeout = 10 * time.Second
+
+// maxResponseBytes caps the response bodies read from external secret stores
+const maxResponseBytes = 1 << 20
+
+// Vault auth methods
+const (
+	VaultAuthToken      = "token"
+	VaultAuthKubernetes = "kubernetes"
+	VaultAuthAppRole    = "approle"
+)
+
+// defaultJWTFile is the projected service account token used for Kubernetes auth
+const defaultJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// VaultAuth configures how the source authenticates to Vault
+type VaultAuth struct {
+	// Method is token, kubernetes, or approle
+	Method string
+	// TokenFile holds the token for the token method
+	TokenFile string
+	// Mount is the auth method's mount path; it defaults to the method name
+	Mount string
+	// Role is the Vault role for the kubernetes method
+	Role string
+	// JWTFile holds the service account token for the kubernetes method
+	JWTFile string
+	// RoleID and SecretIDFile are the credentials for the approle method
+	RoleID       string
+	SecretIDFile string
+}
+
+// VaultSource reads secrets from a HashiCorp Vault KV version 2 engine
+// A secret named <name> is read from <mount>/data/<path>, where the path comes from the path map
+// or defaults to <prefix><name>
+type VaultSource struct {
+	address string
+	auth    VaultAuth
+	mount   string
+	prefix  string
+	paths   map[string]string
+	client  *http.Client
+
+	mu      sync.Mutex
+	token   string
+	renewAt time.Time
+}
+
+// NewVaultSource returns a Vault source
+// With the token method the token file is re-read for every refresh, so tokens renewed by a Vault agent are picked up
+func NewVaultSource(address string, auth VaultAuth, mount, prefix string, paths map[string]string) (*VaultSource, error) {
+	switch auth.Method {
+	case VaultAuthToken:
+		if auth.TokenFile == "" {
+			return nil, fmt.Errorf("vault token auth requires a token file")
+		}
+	case VaultAuthKubernetes:
+		if auth.Role == "" {
+			return nil, fmt.Errorf("vault kubernetes auth requires a role")
+		}
+		if auth.JWTFile == "" {
+			auth.JWTFile = defaultJWTFile
+		}
+	case VaultAuthAppRole:
+		if auth.RoleID == "" || auth.SecretIDFile == "" {
+			return nil, fmt.Errorf("vault approle auth requires a role ID and a secret ID file")
+		}
+	default:
+		return nil, fmt.Errorf("unknown vault auth method %q", auth.Method)
+	}
+	if auth.Mount == "" {
+		auth.Mount = auth.Method
+	}
+
+	return &VaultSource{
+		address: strings.TrimRight(address, "/"),
+		auth:    auth,
+		mount:   strings.Trim(mount, "/"),
+		prefix:  strings.TrimLeft(prefix, "/"),
+		paths:   paths,
+		client:  &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// Name returns "vault"
+func (s *VaultSource) Name() string {
+	return "vault"
+}
+
+// Address returns the Vault address
+func (s *VaultSource) Address() string {
+	return s.address
+}
+
+// Path returns the KV path a secret is read from, without the mount
+func (s *VaultSource) Path(name string) string {
+	if path, ok := s.paths[name]; ok {
+		return strings.Trim(path, "/")
+	}
+	return s.prefix + name
+}
+
+// vaultKVResponse is the body of a KV version 2 read
+type vaultKVResponse struct {
+	Data struct {
+		Data     map[string]interface{} `json:"data"`
+		Metadata struct {
+			CreatedTime  string `json:"created_time"`
+			DeletionTime string `json:"deletion_time"`
+			Destroyed    bool   `json:"destroyed"`
+			Version      int    `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+// vaultLoginResponse is the body of an auth method login
+type vaultLoginResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+}
+
+// readTrimmed reads a credential file
+func readTrimmed(path, what string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// currentToken returns a Vault token, logging in again when the cached one is due for renewal
+func (s *VaultSource) currentToken(ctx context.Context) (string, error) {
+	if s.auth.Method == VaultAuthToken {
+		return readTrimmed(s.auth.TokenFile, "Vault token file")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.renewAt) {
+		return s.token, nil
+	}
+
+	var body map[string]string
+	if s.auth.Method == VaultAuthKubernetes {
+		jwt, err := readTrimmed(s.auth.JWTFile, "service account token")
+		if err != nil {
+			return "", err
+		}
+		body = map[string]string{"role": s.auth.Role, "jwt": jwt}
+	} else {
+		secretID, err := readTrimmed(s.auth.SecretIDFile, "AppRole secret ID file")
+		if err != nil {
+			return "", err
+		}
+		body = map[string]string{"role_id": s.auth.RoleID, "secret_id": secretID}
+	}
+
+	var login vaultLoginResponse
+	if err := s.do(ctx, http.MethodPost, "auth/"+strings.Trim(s.auth.Mount, "/")+"/login", "", body, &login); err != nil {
+		return "", fmt.Errorf("vault %s login failed: %w", s.auth.Method, err)
+	}
+	if login.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault %s login returned no token", s.auth.Method)
+	}
+
+	// Log in again at 80% of the lease, like the Vault agent
+	s.token = login.Auth.ClientToken
+	s.renewAt = time.Now().Add(time.Duration(login.Auth.LeaseDuration) * time.Second * 4 / 5)
+	return s.token, nil
+}
+
+// forgetToken drops a login token Vault rejected, so the next read logs in again
+func (s *VaultSource) forgetToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+// ReadSecrets reads each named secret from Vault
+func (s *VaultSource) ReadSecrets(ctx context.Context, names []string) ([]reader.SecretInfo, error) {
+	token, err := s.currentToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]reader.SecretInfo, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		secrets = append(secrets, s.readSecret(ctx, name, token))
+	}
+	return secrets, nil
+}
+
+// readKV reads the current version of a secret; found is false when it does not exist or was deleted
+func (s *VaultSource) readKV(ctx context.Context, name, token string) (*vaultKVResponse, bool, error) {
+	var kv vaultKVResponse
+	err := s.do(ctx, http.MethodGet, s.mount+"/data/"+s.Path(name), token, nil, &kv)
+	if errors.Is(err, errVaultForbidden) && s.auth.Method != VaultAuthToken {
+		s.forgetToken()
+	}
+	if errors.Is(err, errVaultNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if kv.Data.Metadata.DeletionTime != "" || kv.Data.Metadata.Destroyed {
+		return nil, false, nil
+	}
+	return &kv, true, nil
+}
+
+// values converts the secret's fields to strings, encoding non-string fields as JSON
+func (kv *vaultKVResponse) values() map[string]string {
+	values := make(map[string]string, len(kv.Data.Data))
+	for key, value := range kv.Data.Data {
+		text, ok := value.(string)
+		if !ok {
+			encoded, _ := json.Marshal(value)
+			text = string(encoded)
+		}
+		values[key] = text
+	}
+	return values
+}
+
+// ReadData reads the values of one secret; found is false when Vault does not have it
+func (s *VaultSource) ReadData(ctx context.Context, name string) (map[string]string, bool, error) {
+	token, err := s.currentToken(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	kv, found, err := s.readKV(ctx, name, token)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	values := kv.values()
+	logging.RegisterSecrets("vault/"+s.mount+"/data/"+s.Path(name), slices.Collect(maps.Values(values))...)
+	return values, true, nil
+}
+
+// readSecret reads one secret; the version's creation time is reported as its last sync
+func (s *VaultSource) readSecret(ctx context.Context, name, token string) reader.SecretInfo {
+	info := reader.SecretInfo{Name: name, Keys: make(map[string]string)}
+	path := s.mount + "/data/" + s.Path(name)
+
+	kv, found, err := s.readKV(ctx, name, token)
+	if err != nil {
+		info.Error = fmt.Sprintf("Error reading Vault path %s: %v", path, err)
+		return info
+	}
+	if !found {
+		logging.ForgetSecrets("vault/" + path)
+		info.Error = fmt.Sprintf("Secret '%s' not found at Vault path %s", name, path)
+		return info
+	}
+
+	info.Found = true
+	info.Keys = kv.values()
+	logging.RegisterSecrets("vault/"+path, slices.Collect(maps.Values(info.Keys))...)
+	info.SyncInfo = reader.SyncInfo{
+		LastSuccessfulSync: kv.Data.Metadata.CreatedTime,
+		SyncStatus:         "True",
+		SyncReason:         "VaultRead",
+		SyncMessage:        fmt.Sprintf("%s version %d", path, kv.Data.Metadata.Version),
+	}
+	return info
+}
+
+// Errors for Vault responses the source handles specially
+var (
+	errVaultNotFound  = errors.New("not found")
+	errVaultForbidden = errors.New("permission denied")
+)
+
+// do sends a request to a Vault API path and decodes the JSON response into out
+func (s *VaultSource) do(ctx context.Context, method, path, token string, body interface{}, out interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.address+"/v1/"+(&url.URL{Path: path}).EscapedPath(), payload)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		return nil
+	case http.StatusNotFound:
+		return errVaultNotFound
+	}
+
+	var apiErr struct {
+		Errors []string `json:"errors"`
+	}
+	_ = json.Unmarshal(data, &apiErr)
+	if resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: %s", errVaultForbidden, strings.Join(apiErr.Errors, "; "))
+	}
+	if len(apiErr.Errors) > 0 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.Join(apiErr.Errors, "; "))
+	}
+	return fmt.Errorf("%s", resp.Status)
+}
@@ -0,0 +1,114 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Static parts of a minimal single-sheet workbook
+const (
+	contentTypesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+
+	rootRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+	workbookRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+
+	workbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+)
+
+// ContentType is the MIME type of XLSX workbooks
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// WriteXLSX writes a workbook with a single sheet holding the header row and rows
+// Cells may be strings, bools, or integer and float numbers; other values are formatted as text
+func WriteXLSX(w io.Writer, sheetName string, header []string, rows [][]interface{}) error {
+	archive := zip.NewWriter(w)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", rootRelsXML},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML},
+		{"xl/workbook.xml", fmt.Sprintf(workbookXML, escape(sheetName))},
+	}
+	for _, part := range parts {
+		if err := writePart(archive, part.name, part.content); err != nil {
+			return err
+		}
+	}
+
+	headerRow := make([]interface{}, len(header))
+	for i, name := range header {
+		headerRow[i] = name
+	}
+	if err := writePart(archive, "xl/worksheets/sheet1.xml", sheetXML(append([][]interface{}{headerRow}, rows...))); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// writePart adds one file to the workbook archive
+func writePart(archive *zip.Writer, name, content string) error {
+	part, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	if _, err := io.WriteString(part, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// sheetXML renders the worksheet with inline strings, so no shared string table is needed
+func sheetXML(rows [][]interface{}) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, value := range row {
+			ref := columnName(c) + fmt.Sprint(r+1)
+			switch v := value.(type) {
+			case bool:
+				flag := 0
+				if v {
+					flag = 1
+				}
+				fmt.Fprintf(&b, `<c r="%s" t="b"><v>%d</v></c>`, ref, flag)
+			case int, int32, int64, float32, float64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%v</v></c>`, ref, v)
+			default:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(fmt.Sprint(v)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// columnName converts a zero-based column index to its letter reference (A, B, ..., AA)
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// escape escapes text for XML content and attributes
+func escape(value string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
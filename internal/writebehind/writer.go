@@ -0,0 +1,359 @@
+// Package writebehind appends lines to a file from a background goroutine, so persisting a record
+// doesn't add disk latency to the request that produced it
+package writebehind
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bitwarden-reader/internal/logging"
+)
+
+// Fsync policies
+const (
+	// SyncAlways syncs after every batch of lines
+	SyncAlways = "always"
+	// SyncInterval syncs written lines at most every SyncInterval
+	SyncInterval = "interval"
+	// SyncNever leaves syncing to the operating system
+	SyncNever = "never"
+)
+
+// maxBatch bounds the lines written with one write call
+const maxBatch = 256
+
+// ErrClosed is returned for lines written after Close
+var ErrClosed = errors.New("writer is closed")
+
+// Options configures a Writer
+type Options struct {
+	// QueueSize is how many lines may wait for the background writer; 0 writes them in Write
+	QueueSize int
+	// Sync is the fsync policy: SyncAlways, SyncInterval, or SyncNever
+	Sync         string
+	SyncInterval time.Duration
+}
+
+// Stats describes a writer for metrics
+type Stats struct {
+	Queued int
+	// Written counts the lines written to the file
+	Written int64
+	// Overflows counts the lines whose Write waited for a full queue
+	Overflows int64
+	Errors    int64
+	Syncs     int64
+}
+
+// request is a line to write, or a flush when done is set
+type request struct {
+	line []byte
+	done chan error
+}
+
+// Writer appends lines to a file in the order they were written
+// Write only queues a line; a full queue makes Write wait, so lines are never dropped
+type Writer struct {
+	path string
+	opts Options
+
+	// mu guards the file, which the background goroutine writes and Reopen replaces
+	mu    sync.Mutex
+	file  *os.File
+	dirty bool
+
+	queue     chan request
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	written   atomic.Int64
+	overflows atomic.Int64
+	errors    atomic.Int64
+	syncs     atomic.Int64
+}
+
+// Open opens path for appending and starts the background writer
+func Open(path string, opts Options) (*Writer, error) {
+	if opts.Sync == "" {
+		opts.Sync = SyncInterval
+	}
+	if opts.Sync == SyncInterval && opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		path: path,
+		opts: opts,
+		file: file,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if opts.QueueSize > 0 {
+		w.queue = make(chan request, opts.QueueSize)
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write appends a line, which must end in a newline
+// With a queue the line is written in the background and write errors are only logged and counted
+func (w *Writer) Write(line []byte) error {
+	if w.queue == nil {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.file == nil {
+			return ErrClosed
+		}
+		err := w.write([][]byte{line})
+		if err == nil && w.opts.Sync == SyncAlways {
+			err = w.sync()
+		}
+		return err
+	}
+
+	r := request{line: line}
+	select {
+	case <-w.stop:
+		return ErrClosed
+	default:
+	}
+	select {
+	case w.queue <- r:
+		return nil
+	default:
+	}
+	w.overflows.Add(1)
+	select {
+	case <-w.stop:
+		return ErrClosed
+	case w.queue <- r:
+		return nil
+	}
+}
+
+// Flush waits until every line written before it is in the file, and synced unless the policy is SyncNever
+func (w *Writer) Flush() error {
+	if w.queue == nil {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.flushed()
+	}
+	r := request{done: make(chan error, 1)}
+	select {
+	case <-w.stop:
+		return ErrClosed
+	default:
+	}
+	select {
+	case <-w.stop:
+		return ErrClosed
+	case w.queue <- r:
+	}
+	// A flush queued while Close stops the writer may not be handled
+	select {
+	case err := <-r.done:
+		return err
+	case <-w.done:
+		select {
+		case err := <-r.done:
+			return err
+		default:
+			return ErrClosed
+		}
+	}
+}
+
+// Reopen flushes and opens the file again, so lines go to a file that was renamed over it
+func (w *Writer) Reopen() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.file.Close()
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		w.file = nil
+		return fmt.Errorf("failed to reopen %s: %w", w.path, err)
+	}
+	w.file = file
+	return nil
+}
+
+// Size returns the size of the file in bytes, without lines still queued
+func (w *Writer) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Stats returns the queue length and counters
+func (w *Writer) Stats() Stats {
+	return Stats{
+		Queued:    len(w.queue),
+		Written:   w.written.Load(),
+		Overflows: w.overflows.Load(),
+		Errors:    w.errors.Load(),
+		Syncs:     w.syncs.Load(),
+	}
+}
+
+// Close writes and syncs what is queued and closes the file
+func (w *Writer) Close() error {
+	err := w.Flush()
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+	})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return err
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	return err
+}
+
+// run writes queued lines in batches and syncs on the interval policy
+func (w *Writer) run() {
+	defer close(w.done)
+	var tick <-chan time.Time
+	if w.opts.Sync == SyncInterval {
+		ticker := time.NewTicker(w.opts.SyncInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case r := <-w.queue:
+			w.handle(r)
+		case <-tick:
+			w.mu.Lock()
+			if w.file != nil {
+				w.sync()
+			}
+			w.mu.Unlock()
+		case <-w.stop:
+			// Lines queued by writes racing Close
+			for {
+				select {
+				case r := <-w.queue:
+					w.handle(r)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle writes a line together with what else is queued, up to maxBatch lines, answering flushes once
+// the lines before them are written
+func (w *Writer) handle(first request) {
+	var batch [][]byte
+	var flushes []chan error
+	r := first
+collect:
+	for {
+		if r.done != nil {
+			flushes = append(flushes, r.done)
+		} else {
+			batch = append(batch, r.line)
+		}
+		if len(batch) >= maxBatch {
+			break
+		}
+		select {
+		case r = <-w.queue:
+		default:
+			break collect
+		}
+	}
+
+	w.mu.Lock()
+	var err error
+	if w.file == nil {
+		err = ErrClosed
+	} else {
+		err = w.write(batch)
+		switch {
+		case len(flushes) > 0:
+			if flushErr := w.flushed(); err == nil {
+				err = flushErr
+			}
+		case w.opts.Sync == SyncAlways:
+			w.sync()
+		}
+	}
+	w.mu.Unlock()
+	for _, done := range flushes {
+		done <- err
+	}
+}
+
+// write appends lines with one write call; the caller holds mu
+func (w *Writer) write(lines [][]byte) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	var data []byte
+	if len(lines) == 1 {
+		data = lines[0]
+	} else {
+		for _, line := range lines {
+			data = append(data, line...)
+		}
+	}
+	w.dirty = true
+	if _, err := w.file.Write(data); err != nil {
+		w.errors.Add(1)
+		logging.Printf("Error writing %d lines to %s: %v", len(lines), w.path, err)
+		return fmt.Errorf("failed to write %s: %w", w.path, err)
+	}
+	w.written.Add(int64(len(lines)))
+	return nil
+}
+
+// flushed syncs what was written unless the policy is SyncNever; the caller holds mu
+func (w *Writer) flushed() error {
+	if w.file == nil {
+		return ErrClosed
+	}
+	if w.opts.Sync == SyncNever {
+		return nil
+	}
+	return w.sync()
+}
+
+// sync fsyncs the file when lines were written since the last sync; the caller holds mu
+func (w *Writer) sync() error {
+	if !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	if err := w.file.Sync(); err != nil {
+		w.errors.Add(1)
+		logging.Printf("Error syncing %s: %v", w.path, err)
+		return fmt.Errorf("failed to sync %s: %w", w.path, err)
+	}
+	w.syncs.Add(1)
+	return nil
+}
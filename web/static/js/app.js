@@ -7,42 +7,145 @@ let reconnectAttempts = 0;
 const maxReconnectAttempts = 5;
 let reconnectTimeout = null;
 
+// Resuming: reconnects present the session's token and the last sequence number received,
+// so the server sends only what was missed instead of starting over
+let resumeToken = null;
+let lastSeq = 0;
+
+// Liveness tracking: the server sends a heartbeat when no snapshot changed
+let lastMessageAt = 0;
+let livenessInterval = null;
+
 const secretVisibilityState = new Map();
 const autoHideTimeouts = new Map();
 
+// UI configuration served by /api/v1/ui-config
+let uiConfig = null;
+let autoHideMs = 60000;
+
+// Translations of the negotiated language, keyed by the English text
+let messages = {};
+
+// t translates text, replacing each %s or %d with the next argument
+function t(text, ...args) {
+    let i = 0;
+    return (messages[text] || text).replace(/%[sd]/g, () => String(args[i++]));
+}
+
+async function loadUIConfig() {
+    try {
+        const response = await fetch('/api/v1/ui-config');
+        if (!response.ok) return;
+        uiConfig = await response.json();
+        applyUIConfig(uiConfig);
+    } catch (error) {
+        console.error('Error loading UI config:', error);
+    }
+}
+
+function applyUIConfig(config) {
+    if (config.messages) {
+        messages = config.messages;
+    }
+    if (config.title) {
+        document.title = `${config.title} - ${config.version}`;
+        const heading = document.querySelector('header h1');
+        if (heading) heading.textContent = config.title;
+    }
+    if (config.version) {
+        const version = document.querySelector('header .version');
+        if (version) version.textContent = t('Version %s', config.version);
+    }
+    if (config.heartbeatSeconds > 0) {
+        startLivenessCheck(config.heartbeatSeconds);
+    }
+    if (config.redaction && config.redaction.autoHideSeconds >= 0) {
+        autoHideMs = config.redaction.autoHideSeconds * 1000;
+    }
+    if (config.features && !config.features.triggerSync) {
+        document.querySelectorAll('#trigger-sync-btn, .sync-item .btn').forEach(btn => {
+            btn.disabled = true;
+            btn.title = t('Sync trigger is not available');
+        });
+    } else if (config.manualSync && config.manualSync.supported === 'no') {
+        // The operator ignores force-sync, so a trigger would only look like it worked
+        document.querySelectorAll('#trigger-sync-btn, .sync-item .btn').forEach(btn => {
+            btn.disabled = true;
+            btn.title = t('The operator does not act on manual syncs');
+        });
+    }
+}
+
 function connectWebSocket() {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws`;
+    // Cards are updated one by one, so snapshot deltas are enough when the deltaBroadcasts flag is on
+    let wsUrl = `${protocol}//${window.location.host}/ws?delta=true`;
+    if (resumeToken) {
+        wsUrl += `&resume=${encodeURIComponent(resumeToken)}&lastSeq=${lastSeq}`;
+    }
 
-    updateConnectionStatus('connecting', 'Connecting...');
+    updateConnectionStatus('connecting', t('Connecting...'));
 
     ws = new WebSocket(wsUrl);
 
     ws.onopen = function() {
         reconnectAttempts = 0;
-        updateConnectionStatus('connected', 'Connected');
+        lastMessageAt = Date.now();
+        updateConnectionStatus('connected', t('Connected'));
     };
 
-    ws.onclose = function() {
-        updateConnectionStatus('disconnected', 'Disconnected');
+    ws.onclose = function(event) {
+        // The browser session ended; reload so secrets are no longer shown without logging in again
+        if (event.code === 4401) {
+            window.location.reload();
+            return;
+        }
+        updateConnectionStatus('disconnected', t('Disconnected'));
         attemptReconnect();
     };
 
     ws.onerror = function(error) {
         console.error('WebSocket error:', error);
-        updateConnectionStatus('disconnected', 'Connection Error');
+        updateConnectionStatus('disconnected', t('Connection Error'));
     };
 
     ws.onmessage = function(event) {
-        try {
-            const data = JSON.parse(event.data);
-            updateSecrets(data);
-        } catch (error) {
-            console.error('Error parsing WebSocket message:', error);
-        }
+        lastMessageAt = Date.now();
+        // Messages queued on the server arrive together, one per line
+        event.data.split('\n').forEach(handleMessage);
     };
 }
 
+function handleMessage(text) {
+    try {
+        const data = JSON.parse(text);
+        if (data.type === 'heartbeat') return;
+        if (data.type === 'session') {
+            resumeToken = data.resumeToken || null;
+            // Not resumed: the last snapshot follows, and anything older is no longer relevant
+            if (!data.resumed) lastSeq = data.seq;
+            return;
+        }
+        if (data.seq > lastSeq) lastSeq = data.seq;
+        updateSecrets(data);
+        if (data.type === 'secrets') loadChanges();
+    } catch (error) {
+        console.error('Error parsing WebSocket message:', error);
+    }
+}
+
+// Reconnect when neither a snapshot nor a heartbeat arrived for three heartbeat intervals
+function startLivenessCheck(heartbeatSeconds) {
+    if (livenessInterval || !heartbeatSeconds) return;
+    const timeoutMs = heartbeatSeconds * 3000;
+    livenessInterval = setInterval(() => {
+        if (ws && ws.readyState === WebSocket.OPEN && Date.now() - lastMessageAt > timeoutMs) {
+            console.warn('No WebSocket messages received, reconnecting');
+            ws.close();
+        }
+    }, heartbeatSeconds * 1000);
+}
+
 function updateConnectionStatus(status, message) {
     const statusElement = document.getElementById('ws-status');
     if (statusElement) {
@@ -59,7 +162,7 @@ function attemptReconnect() {
             connectWebSocket();
         }, delay);
     } else {
-        updateConnectionStatus('disconnected', 'Connection Lost - Please refresh');
+        updateConnectionStatus('disconnected', t('Connection Lost - Please refresh'));
     }
 }
 
@@ -73,7 +176,7 @@ function updateSecrets(data) {
     // Update total found count
     const h2 = document.querySelector('.secrets-section h2');
     if (h2) {
-        h2.textContent = `Secrets (${data.totalFound} found)`;
+        h2.textContent = t('Secrets (%d found)', data.totalFound);
     }
 
     // Update each secret card
@@ -85,10 +188,10 @@ function updateSecrets(data) {
         const statusBadge = card.querySelector('.status-badge');
         if (statusBadge) {
             if (secret.found) {
-                statusBadge.textContent = 'Found';
+                statusBadge.textContent = t('Found');
                 statusBadge.className = 'status-badge status-found';
             } else {
-                statusBadge.textContent = 'Not Found';
+                statusBadge.textContent = t('Not Found');
                 statusBadge.className = 'status-badge status-not-found';
             }
         }
@@ -99,10 +202,10 @@ function updateSecrets(data) {
             if (!errorDiv) {
                 const errorElement = document.createElement('div');
                 errorElement.className = 'error-message';
-                errorElement.innerHTML = `<strong>Error:</strong> ${escapeHtml(secret.error)}`;
+                errorElement.innerHTML = `<strong>${escapeHtml(t('Error:'))}</strong> ${escapeHtml(secret.error)}`;
                 card.insertBefore(errorElement, card.firstChild.nextSibling);
             } else {
-                errorDiv.innerHTML = `<strong>Error:</strong> ${escapeHtml(secret.error)}`;
+                errorDiv.innerHTML = `<strong>${escapeHtml(t('Error:'))}</strong> ${escapeHtml(secret.error)}`;
             }
         } else if (errorDiv) {
             errorDiv.remove();
@@ -113,13 +216,57 @@ function updateSecrets(data) {
             updateSyncInfo(card, secret.syncInfo);
         }
 
-        // Update secret keys
-        if (secret.found && secret.keys) {
-            updateSecretKeys(card, secret.name, secret.keys);
+        // Update secret keys (hygiene-mode broadcasts carry hashes, not values)
+        if (secret.found && secret.keys && !data.valuesHashed) {
+            updateSecretKeys(card, secret.name, secret.keys, secret.hiddenKeys || [], secret.truncatedKeys || []);
         }
     });
 }
 
+// Show the changes of the last hour from /api/v1/changes, newest first
+const changesWindowMs = 60 * 60 * 1000;
+
+async function loadChanges() {
+    const list = document.getElementById('changes-list');
+    if (!list) return;
+    try {
+        const since = new Date(Date.now() - changesWindowMs).toISOString();
+        const response = await fetch(`/api/v1/changes?since=${encodeURIComponent(since)}`);
+        if (!response.ok) return;
+        const data = await response.json();
+        const changes = (data.changes || []).slice().reverse();
+        if (changes.length === 0) {
+            list.innerHTML = `<li>${escapeHtml(t('No changes in the last hour'))}</li>`;
+            return;
+        }
+        list.innerHTML = changes.map(change => {
+            const time = new Date(change.time).toLocaleTimeString();
+            return `<li><span class="change-time">${escapeHtml(time)}</span>${escapeHtml(describeChange(change))}</li>`;
+        }).join('');
+    } catch (error) {
+        console.error('Error loading changes:', error);
+    }
+}
+
+// describeChange lists added keys with +, removed ones with -, and keys with new values with ~
+function describeChange(change) {
+    const keys = [
+        ...(change.addedKeys || []).map(key => `+${key}`),
+        ...(change.removedKeys || []).map(key => `-${key}`),
+        ...(change.changedKeys || []).map(key => `~${key}`),
+    ].join(', ');
+    switch (change.change) {
+    case 'appeared':
+        return t('%s appeared', change.secret);
+    case 'disappeared':
+        return t('%s disappeared', change.secret);
+    case 'keys-changed':
+        return t('%s: keys changed (%s)', change.secret, keys);
+    default:
+        return t('%s: values changed (%s)', change.secret, keys);
+    }
+}
+
 function updateSyncInfo(card, syncInfo) {
     const syncInfoDiv = card.querySelector('.sync-info');
     if (!syncInfoDiv) return;
@@ -132,19 +279,49 @@ function updateSyncInfo(card, syncInfo) {
     }
 }
 
-function updateSecretKeys(card, secretName, keys) {
+function updateSecretKeys(card, secretName, keys, hiddenKeys, truncatedKeys) {
     const keysList = card.querySelector(`#keys-${secretName}`);
     if (!keysList) return;
 
     const existingItems = keysList.querySelectorAll('.key-item');
     const keysArray = Object.entries(keys);
 
+    // Users who may not read Secret values only see key names, as rendered by the server
+    const container = document.getElementById('secrets-container');
+    const canReveal = !container || container.dataset.canRevealValues !== 'false';
+
     if (existingItems.length !== keysArray.length) {
         const isVisible = secretVisibilityState.get(secretName) || false;
         keysList.innerHTML = '';
         keysArray.forEach(([key, value]) => {
             const keyItem = document.createElement('div');
             keyItem.className = 'key-item';
+            // Hidden values are never sent; only the key name is shown
+            if (hiddenKeys.includes(key)) {
+                keyItem.innerHTML = `
+                <strong>${escapeHtml(key)}:</strong>
+                <span class="secret-hidden-value" title="${escapeHtml(t('Hidden by the key visibility policy'))}">${escapeHtml(t('hidden'))}</span>
+            `;
+                keysList.appendChild(keyItem);
+                return;
+            }
+            // Values over the memory caps are dropped on the server
+            if (truncatedKeys.includes(key)) {
+                keyItem.innerHTML = `
+                <strong>${escapeHtml(key)}:</strong>
+                <span class="secret-hidden-value" title="${escapeHtml(t('Value exceeds the memory limit and was not loaded'))}">${escapeHtml(t('too large'))}</span>
+            `;
+                keysList.appendChild(keyItem);
+                return;
+            }
+            if (!canReveal) {
+                keyItem.innerHTML = `
+                <strong>${escapeHtml(key)}:</strong>
+                <span class="secret-masked-value">••••••••</span>
+            `;
+                keysList.appendChild(keyItem);
+                return;
+            }
             keyItem.innerHTML = `
                 <strong>${escapeHtml(key)}:</strong>
                 <span class="secret-display" data-secret="${escapeHtml(secretName)}" data-key="${escapeHtml(key)}" data-value="${escapeHtml(value)}" data-hidden="${isVisible ? 'false' : 'true'}">
@@ -156,7 +333,7 @@ function updateSecretKeys(card, secretName, keys) {
         });
         const toggleBtn = card.querySelector('.btn-toggle');
         if (toggleBtn) {
-            toggleBtn.textContent = isVisible ? 'Hide Values' : 'Show Values';
+            toggleBtn.textContent = isVisible ? t('Hide Values') : t('Show Values');
         }
     } else {
         keysArray.forEach(([key, value], index) => {
@@ -222,7 +399,7 @@ window.toggleSecretValues = function(secretName) {
     });
 
     if (toggleBtn) {
-        toggleBtn.textContent = willBeVisible ? 'Hide Values' : 'Show Values';
+        toggleBtn.textContent = willBeVisible ? t('Hide Values') : t('Show Values');
     }
 
     secretVisibilityState.set(secretName, willBeVisible);
@@ -233,12 +410,12 @@ window.toggleSecretValues = function(secretName) {
         autoHideTimeouts.delete(secretName);
     }
 
-    // If showing values, set auto-hide after 60 seconds
-    if (willBeVisible) {
+    // If showing values, set auto-hide after VALUE_AUTO_HIDE_SECONDS (0 keeps them shown)
+    if (willBeVisible && autoHideMs > 0) {
         const timeoutId = setTimeout(() => {
             toggleSecretValues(secretName);
             autoHideTimeouts.delete(secretName);
-        }, 60000);
+        }, autoHideMs);
         autoHideTimeouts.set(secretName, timeoutId);
     }
 
@@ -252,7 +429,7 @@ window.toggleSecretValues = function(secretName) {
 async function triggerSyncForSecret(secretName) {
     const statusSpan = document.getElementById('sync-status');
     if (statusSpan) {
-        statusSpan.textContent = `Triggering sync for ${secretName}...`;
+        statusSpan.textContent = t('Triggering sync for %s...', secretName);
         statusSpan.className = '';
     }
 
@@ -261,27 +438,31 @@ async function triggerSyncForSecret(secretName) {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
+                'Accept': 'application/json; version=2',
             },
             body: JSON.stringify({secretNames: [secretName]})
         });
 
         const data = await response.json();
+        const result = (data.results || [])[0];
 
         if (response.ok) {
             if (statusSpan) {
-                statusSpan.textContent = `Sync triggered for ${secretName}`;
+                // Another user or replica triggered it moments ago, so it was not patched again
+                const duplicate = result && result.status === 'deduplicated';
+                statusSpan.textContent = duplicate ? t('Sync for %s was already triggered moments ago', secretName) : t('Sync triggered for %s', secretName);
                 statusSpan.className = 'success';
             }
             pollSyncStatus();
         } else {
             if (statusSpan) {
-                statusSpan.textContent = `Error: ${data.error || 'Unknown error'}`;
+                statusSpan.textContent = `${t('Error:')} ${data.error || (result && result.error) || t('Unknown error')}`;
                 statusSpan.className = 'error';
             }
         }
     } catch (error) {
         if (statusSpan) {
-            statusSpan.textContent = `Error: ${error.message}`;
+            statusSpan.textContent = `${t('Error:')} ${error.message}`;
             statusSpan.className = 'error';
         }
     } finally {
@@ -301,32 +482,56 @@ async function triggerSync() {
     if (!btn || !statusSpan) return;
 
     btn.disabled = true;
-    statusSpan.textContent = 'Triggering sync for all secrets...';
+    statusSpan.textContent = t('Triggering sync for all secrets...');
     statusSpan.className = '';
 
     try {
+        // Bulk triggers need the confirmation token from the plan
+        const planResponse = await fetch('/api/v1/trigger-sync/plan');
+        const plan = await planResponse.json();
+        if (!planResponse.ok) {
+            throw new Error(plan.error || 'Failed to plan sync');
+        }
+        const body = {secretNames: plan.secrets.map(secret => secret.name)};
+        if (plan.confirmationRequired) {
+            if (!confirm(`${t('Trigger sync for %d secrets?', plan.count)}\n\n${body.secretNames.join(', ')}`)) {
+                statusSpan.textContent = t('Sync cancelled');
+                statusSpan.className = '';
+                return;
+            }
+            body.confirmationToken = plan.confirmationToken;
+        }
+
         const response = await fetch('/api/v1/trigger-sync', {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
+                'Accept': 'application/json; version=2',
             },
-            body: JSON.stringify({})
+            body: JSON.stringify(body)
         });
 
         const data = await response.json();
+        const failed = (data.results || []).filter(result => result.status === 'failed');
 
-        if (response.ok) {
-            statusSpan.textContent = 'Sync triggered successfully';
+        if (response.ok && failed.length > 0) {
+            // 207: some secrets were triggered, others failed
+            statusSpan.textContent = `${t('Sync failed for %d of %d secrets:', failed.length, data.total)} ${failed.map(result => result.secret).join(', ')}`;
+            statusSpan.className = 'error';
+
+            pollSyncStatus();
+        } else if (response.ok) {
+            statusSpan.textContent = t('Sync triggered successfully');
             statusSpan.className = 'success';
 
             // Poll for sync completion
             pollSyncStatus();
         } else {
-            statusSpan.textContent = `Error: ${data.error || 'Unknown error'}`;
+            statusSpan.textContent = `${t('Error:')} ${data.error || (failed[0] && failed[0].error) || t('Unknown error')}`;
             statusSpan.className = 'error';
         }
     } catch (error) {
-        statusSpan.textContent = `Error: ${error.message}`;
+        statusSpan.textContent = `${t('Error:')} ${error.message}`;
         statusSpan.className = 'error';
     } finally {
         btn.disabled = false;
@@ -366,9 +571,15 @@ async function pollSyncStatus() {
 
 // Initialize on page load
 document.addEventListener('DOMContentLoaded', function() {
+    // Load UI configuration
+    loadUIConfig();
+
     // Connect WebSocket
     connectWebSocket();
 
+    // Show recent changes
+    loadChanges();
+
     // Setup trigger sync button
     const triggerBtn = document.getElementById('trigger-sync-btn');
     if (triggerBtn) {